@@ -0,0 +1,96 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Program loadgen sends synthetic spans to a collector or an agent, over the
+// OpenCensus protocol, to load test it.
+//
+//	go run ./cmd/loadgen -address localhost:55678 -rate 5000 -depth 4
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/ocagent"
+
+	"github.com/census-instrumentation/opencensus-service/loadgen"
+)
+
+func main() {
+	address := flag.String("address", "localhost:55678", "address of the OpenCensus receiver the spans are sent to")
+	rate := flag.Float64("rate", 1000, "spans generated per second")
+	attributes := flag.Int("attributes", 4, "attributes per span")
+	annotations := flag.Int("annotations", 1, "annotations per span")
+	depth := flag.Int("depth", 1, "spans per trace, each one the child of the previous one")
+	duration := flag.Duration("duration", 0, "duration of the load, until interrupted if 0")
+	report := flag.Duration("report", 10*time.Second, "interval of the reports of the span counts")
+	flag.Parse()
+
+	exporter, err := ocagent.NewExporter(
+		ocagent.WithInsecure(),
+		ocagent.WithAddress(*address),
+		ocagent.WithServiceName(fmt.Sprintf("loadgen-%d", os.Getpid())))
+	if err != nil {
+		log.Fatalf("Failed to create the OpenCensus exporter: %v", err)
+	}
+	defer exporter.Stop()
+
+	gen, err := loadgen.NewGenerator(exporter, loadgen.Config{
+		SpansPerSecond:     *rate,
+		AttributesPerSpan:  *attributes,
+		AnnotationsPerSpan: *annotations,
+		TraceDepth:         *depth,
+	})
+	if err != nil {
+		log.Fatalf("Invalid load: %v", err)
+	}
+
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+	signalsChan := make(chan os.Signal, 1)
+	signal.Notify(signalsChan, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*report)
+	defer ticker.Stop()
+
+	log.Printf("Sending %g spans/s to %s", *rate, *address)
+	gen.Start()
+	start := time.Now()
+	defer func() {
+		gen.Stop()
+		logStats(gen.Stats(), time.Since(start))
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+			logStats(gen.Stats(), time.Since(start))
+		case <-timeout:
+			return
+		case <-signalsChan:
+			return
+		}
+	}
+}
+
+func logStats(stats loadgen.Stats, elapsed time.Duration) {
+	log.Printf("%v: %d spans generated (%.0f/s), %d dropped",
+		elapsed.Round(time.Second), stats.Generated, float64(stats.Generated)/elapsed.Seconds(), stats.Dropped)
+}
@@ -29,8 +29,11 @@ import (
 	"go.opencensus.io/stats/view"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/internal/adminserver"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/config"
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 	"github.com/census-instrumentation/opencensus-service/internal/pprofserver"
@@ -40,7 +43,9 @@ import (
 	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
 	"github.com/census-instrumentation/opencensus-service/receiver/jaegerreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/otlphttpreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/prometheusreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/statsdreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/vmmetricsreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver/zipkinscribereceiver"
@@ -112,12 +117,30 @@ func runOCAgent() {
 		log.Fatalf("Failed to start net/http/pprof: %v", err)
 	}
 
+	// If the admin server is enabled, run it before anything else so that it
+	// reports the process as alive, but not ready, during the startup.
+	var adminServer *adminserver.Server
+	adminPort, adminEnabled := agentConfig.AdminPort()
+	if adminEnabled {
+		adminServer, err = adminserver.Run(asyncErrorChan, adminPort, agentConfig.AdminPprofEnabled())
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Running the admin server on port %d", adminPort)
+	}
+
 	traceExporters, metricsExporters, closeFns, err := config.ExportersFromViperConfig(logger, viperCfg)
 	if err != nil {
 		log.Fatalf("Config: failed to create exporters from YAML: %v", err)
 	}
 
-	commonSpanSink := multiconsumer.NewTraceProcessor(traceExporters)
+	var commonSpanSink consumer.TraceConsumer = multiconsumer.NewTraceProcessor(traceExporters)
+	if agentConfig.BatchingEnabled() {
+		batcher := newBatcher(logger, agentConfig.Batching, commonSpanSink)
+		// The batches in progress must be sent before the exporters are closed.
+		closeFns = append([]func() error{batcher.Close}, closeFns...)
+		commonSpanSink = batcher
+	}
 	commonMetricsSink := multiconsumer.NewMetricsProcessor(metricsExporters)
 
 	// Add other receivers here as they are implemented
@@ -149,6 +172,15 @@ func runOCAgent() {
 		closeFns = append(closeFns, zipkinReceiverDoneFn)
 	}
 
+	if agentConfig.OTLPHTTPReceiverEnabled() {
+		otlpHTTPReceiverAddr := agentConfig.OTLPHTTPReceiverAddress()
+		otlpHTTPReceiverDoneFn, err := runOTLPHTTPReceiver(otlpHTTPReceiverAddr, commonSpanSink, asyncErrorChan)
+		if err != nil {
+			log.Fatal(err)
+		}
+		closeFns = append(closeFns, otlpHTTPReceiverDoneFn)
+	}
+
 	if agentConfig.ZipkinScribeReceiverEnabled() {
 		zipkinScribeDoneFn, err := runZipkinScribeReceiver(agentConfig.ZipkinScribeConfig(), commonSpanSink, asyncErrorChan)
 		if err != nil {
@@ -184,6 +216,21 @@ func runOCAgent() {
 		closeFns = append(closeFns, vmmDoneFn)
 	}
 
+	// If the StatsD receiver is enabled, then run it.
+	if agentConfig.StatsDReceiverEnabled() {
+		statsdDoneFn, err := runStatsDReceiver(agentConfig.StatsDConfig(), commonMetricsSink, asyncErrorChan)
+		if err != nil {
+			log.Fatal(err)
+		}
+		closeFns = append(closeFns, statsdDoneFn)
+	}
+
+	if adminServer != nil {
+		// Stop serving the admin endpoints before the pipeline is shut down.
+		closeFns = append([]func() error{adminServer.Close}, closeFns...)
+		adminServer.SetReady()
+	}
+
 	// Always cleanup finally
 	defer func() {
 		for _, closeFn := range closeFns {
@@ -205,17 +252,17 @@ func runOCAgent() {
 }
 
 func runOCReceiver(logger *zap.Logger, acfg *config.Config, tc consumer.TraceConsumer, mc consumer.MetricsConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
-	tlsCredsOption, hasTLSCreds, err := acfg.OpenCensusReceiverTLSCredentialsServerOption()
+	tlsCredsOption, hasTLSCreds, err := acfg.OpenCensusReceiverTLSCredentialsServerOption(logger)
 	if err != nil {
 		return nil, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err)
 	}
 	addr := acfg.OpenCensusReceiverAddress()
 	corsOrigins := acfg.OpenCensusReceiverCorsAllowedOrigins()
-	ocr, err := opencensusreceiver.New(addr,
-		tc,
-		mc,
-		tlsCredsOption,
-		opencensusreceiver.WithCorsOrigins(corsOrigins))
+	opts := []opencensusreceiver.Option{tlsCredsOption, opencensusreceiver.WithCorsOrigins(corsOrigins)}
+	if maxRecvMsgSizeMiB := acfg.OpenCensusReceiverMaxRecvMsgSizeMiB(); maxRecvMsgSizeMiB > 0 {
+		opts = append(opts, opencensusreceiver.WithGRPCServerOptions(grpc.MaxRecvMsgSize(int(maxRecvMsgSizeMiB*1024*1024))))
+	}
+	ocr, err := opencensusreceiver.New(addr, tc, mc, opts...)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create the OpenCensus receiver on address %q: error %v", addr, err)
@@ -302,6 +349,22 @@ func runZipkinReceiver(addr string, next consumer.TraceConsumer, asyncErrorChan
 	return doneFn, nil
 }
 
+func runOTLPHTTPReceiver(addr string, next consumer.TraceConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
+	otr, err := otlphttpreceiver.New(addr, next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP/HTTP receiver: %v", err)
+	}
+
+	if err := otr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+		return nil, fmt.Errorf("cannot start OTLP/HTTP receiver with address %q: %v", addr, err)
+	}
+	doneFn = func() error {
+		return otr.StopTraceReception(context.Background())
+	}
+	log.Printf("Running OTLP/HTTP receiver with address %q", addr)
+	return doneFn, nil
+}
+
 func runZipkinScribeReceiver(config *config.ScribeReceiverConfig, next consumer.TraceConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
 	zs, err := zipkinscribereceiver.NewReceiver(config.Address, config.Port, config.Category, next)
 	if err != nil {
@@ -347,3 +410,30 @@ func runVMMetricsReceiver(v *viper.Viper, next consumer.MetricsConsumer, asyncEr
 	log.Print("Running VMMetrics receiver")
 	return doneFn, nil
 }
+
+func newBatcher(logger *zap.Logger, config *config.Batching, next consumer.TraceConsumer) nodebatcher.Batcher {
+	var opts []nodebatcher.Option
+	if config.SendBatchSize > 0 {
+		opts = append(opts, nodebatcher.WithSendBatchSize(config.SendBatchSize))
+	}
+	if config.Timeout > 0 {
+		opts = append(opts, nodebatcher.WithTimeout(config.Timeout))
+	}
+	log.Printf("Batching the spans with %+v", *config)
+	return nodebatcher.NewBatcher("agent", logger, next, opts...)
+}
+
+func runStatsDReceiver(config *config.StatsDReceiverConfig, next consumer.MetricsConsumer, asyncErrorChan chan<- error) (doneFn func() error, err error) {
+	sr, err := statsdreceiver.New(config.Address, config.FlushInterval, next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the StatsD receiver: %v", err)
+	}
+	if err := sr.StartMetricsReception(context.Background(), asyncErrorChan); err != nil {
+		return nil, fmt.Errorf("cannot start StatsD receiver with %+v: %v", *config, err)
+	}
+	doneFn = func() error {
+		return sr.StopMetricsReception(context.Background())
+	}
+	log.Printf("Running StatsD receiver with %+v", *config)
+	return doneFn, nil
+}
@@ -28,6 +28,7 @@ const (
 	receiversRoot     = "receivers"
 	jaegerEntry       = "jaeger"
 	opencensusEntry   = "opencensus"
+	otlpHTTPEntry     = "otlphttp"
 	zipkinEntry       = "zipkin"
 	zipkinScribeEntry = "zipkin-scribe"
 
@@ -35,10 +36,15 @@ const (
 	configCfg                   = "config"
 	jaegerReceiverFlg           = "receive-jaeger"
 	ocReceiverFlg               = "receive-oc-trace"
+	otlpHTTPReceiverFlg         = "receive-otlphttp"
 	zipkinReceiverFlg           = "receive-zipkin"
 	zipkinScribeReceiverFlg     = "receive-zipkin-scribe"
 	loggingExporterFlg          = "logging-exporter"
 	useTailSamplingAlwaysSample = "tail-sampling-always-sample"
+	configReloadFlg             = "config-reload"
+	configReloadGracePeriodFlg  = "config-reload-grace-period"
+	shutdownDrainTimeoutFlg     = "shutdown-drain-timeout"
+	selfTracingFlg              = "self-tracing"
 )
 
 // Flags adds flags related to basic building of the collector application to the given flagset.
@@ -48,6 +54,8 @@ func Flags(flags *flag.FlagSet) {
 		fmt.Sprintf("Flag to run the Jaeger receiver (i.e.: Jaeger Collector), default settings: %+v", *NewDefaultJaegerReceiverCfg()))
 	flags.Bool(ocReceiverFlg, true,
 		fmt.Sprintf("Flag to run the OpenCensus trace receiver, default settings: %+v", *NewDefaultOpenCensusReceiverCfg()))
+	flags.Bool(otlpHTTPReceiverFlg, false,
+		fmt.Sprintf("Flag to run the OTLP/HTTP trace receiver, default settings: %+v", *NewDefaultOTLPHTTPReceiverCfg()))
 	flags.Bool(zipkinReceiverFlg, false,
 		fmt.Sprintf("Flag to run the Zipkin receiver, default settings: %+v", *NewDefaultZipkinReceiverCfg()))
 	flags.Bool(zipkinScribeReceiverFlg, false,
@@ -55,6 +63,13 @@ func Flags(flags *flag.FlagSet) {
 	flags.Bool(loggingExporterFlg, false, "Flag to add a logging exporter (combine with log level DEBUG to log incoming spans)")
 	flags.Bool(useTailSamplingAlwaysSample, false, "Flag to use a tail-based sampling processor with an always sample policy, "+
 		"unless tail sampling setting is present on configuration file.")
+	flags.Bool(configReloadFlg, false, "Flag to rebuild the exporters and processors, without restarting, when the config file changes")
+	flags.Duration(configReloadGracePeriodFlg, 5*time.Second,
+		"Maximum time to wait for the spans being processed when the exporters and processors are rebuilt")
+	flags.Duration(shutdownDrainTimeoutFlg, 10*time.Second,
+		"Maximum time to wait, on shutdown, for the spans being processed before the exporters are closed")
+	flags.Bool(selfTracingFlg, false,
+		"Flag to trace the pipeline of the collector itself, the internal spans are logged at debug level")
 }
 
 // GetConfigFile gets the config file from the config file flag.
@@ -62,6 +77,29 @@ func GetConfigFile(v *viper.Viper) string {
 	return v.GetString(configCfg)
 }
 
+// ConfigReloadEnabled returns true if the exporters and processors must be rebuilt when the config
+// file changes, and false otherwise.
+func ConfigReloadEnabled(v *viper.Viper) bool {
+	return v.GetBool(configReloadFlg)
+}
+
+// ConfigReloadGracePeriod returns the maximum time to wait for the spans being processed by the
+// previous exporters and processors, before closing them, when the config file changes.
+func ConfigReloadGracePeriod(v *viper.Viper) time.Duration {
+	return v.GetDuration(configReloadGracePeriodFlg)
+}
+
+// ShutdownDrainTimeout returns the maximum time to wait, once the receivers are stopped, for the
+// spans being processed before closing the exporters.
+func ShutdownDrainTimeout(v *viper.Viper) time.Duration {
+	return v.GetDuration(shutdownDrainTimeoutFlg)
+}
+
+// SelfTracingEnabled returns true if the collector must trace its own pipeline, and false otherwise.
+func SelfTracingEnabled(v *viper.Viper) bool {
+	return v.GetBool(selfTracingFlg)
+}
+
 // LoggingExporterEnabled returns true if the debug processor is enabled, and false otherwise
 func LoggingExporterEnabled(v *viper.Viper) bool {
 	return v.GetBool(loggingExporterFlg)
@@ -116,6 +154,23 @@ type OpenCensusReceiverCfg struct {
 
 	// MaxConcurrentStreams sets the limit on the number of concurrent streams to each ServerTransport.
 	MaxConcurrentStreams uint32 `mapstructure:"max-concurrent-streams"`
+
+	// MaxHTTPRequestBodyBytes sets the maximum size (in bytes) of the HTTP/JSON requests accepted by the server.
+	MaxHTTPRequestBodyBytes int64 `mapstructure:"max-http-request-body-bytes"`
+
+	// HTTPRateLimit limits the rate of the HTTP/JSON requests accepted by the server.
+	HTTPRateLimit *httpRateLimit `mapstructure:"http-rate-limit,omitempty"`
+
+	// APIKeys if not empty, restricts the gRPC calls accepted by the server to
+	// those passing one of these keys in their x-api-key metadata, and the
+	// binary protobuf requests to those passing it in their X-Api-Key header.
+	APIKeys []string `mapstructure:"api-keys"`
+}
+
+// httpRateLimit configures a token bucket rate limiter.
+type httpRateLimit struct {
+	RequestsPerSecond float64 `mapstructure:"requests-per-second"`
+	Burst             int     `mapstructure:"burst"`
 }
 
 type serverParametersAndEnforcementPolicy struct {
@@ -159,6 +214,31 @@ func (cfg *OpenCensusReceiverCfg) InitFromViper(v *viper.Viper) (*OpenCensusRece
 	return cfg, initFromViper(cfg, v, receiversRoot, opencensusEntry)
 }
 
+// OTLPHTTPReceiverCfg holds configuration for the OTLP/HTTP receiver.
+type OTLPHTTPReceiverCfg struct {
+	// Port is the port that the receiver will use
+	Port int `mapstructure:"port"`
+}
+
+// OTLPHTTPReceiverEnabled checks if the OTLP/HTTP receiver is enabled, via a command-line flag, environment
+// variable, or configuration file.
+func OTLPHTTPReceiverEnabled(v *viper.Viper) bool {
+	return featureEnabled(v, otlpHTTPReceiverFlg, receiversRoot, otlpHTTPEntry)
+}
+
+// NewDefaultOTLPHTTPReceiverCfg returns an instance of OTLPHTTPReceiverCfg with default values
+func NewDefaultOTLPHTTPReceiverCfg() *OTLPHTTPReceiverCfg {
+	opts := &OTLPHTTPReceiverCfg{
+		Port: 4318,
+	}
+	return opts
+}
+
+// InitFromViper returns a OTLPHTTPReceiverCfg according to the configuration.
+func (cfg *OTLPHTTPReceiverCfg) InitFromViper(v *viper.Viper) (*OTLPHTTPReceiverCfg, error) {
+	return cfg, initFromViper(cfg, v, receiversRoot, otlpHTTPEntry)
+}
+
 // ZipkinReceiverCfg holds configuration for Zipkin receiver.
 type ZipkinReceiverCfg struct {
 	// Port is the port that the receiver will use
@@ -67,6 +67,17 @@ func TestReceiversEnabledByPresenceWithDefaultSettings(t *testing.T) {
 	} else if !reflect.DeepEqual(wscrb, gscrb) {
 		t.Errorf("Incorrect config for Zipkin Scribe receiver, want %v got %v", wscrb, gscrb)
 	}
+
+	if !OTLPHTTPReceiverEnabled(v) {
+		t.Fatalf("OTLP/HTTP receiver was not enabled")
+	}
+	wotlp := NewDefaultOTLPHTTPReceiverCfg()
+	gotlp, err := wotlp.InitFromViper(v)
+	if err != nil {
+		t.Errorf("Failed to InitFromViper for OTLP/HTTP receiver: %v", err)
+	} else if !reflect.DeepEqual(wotlp, gotlp) {
+		t.Errorf("Incorrect config for OTLP/HTTP receiver, want %v got %v", wotlp, gotlp)
+	}
 }
 
 func TestReceiversDisabledByPresenceWithDefaultSettings(t *testing.T) {
@@ -80,6 +91,9 @@ func TestReceiversDisabledByPresenceWithDefaultSettings(t *testing.T) {
 	if jaegerEnabled || opencensusEnabled || zipkinEnabled {
 		t.Fatalf("Not all receivers were disabled j:%v oc:%v z:%v scribe:%v", jaegerEnabled, opencensusEnabled, zipkinEnabled, scribeEnabled)
 	}
+	if OTLPHTTPReceiverEnabled(v) {
+		t.Fatalf("OTLP/HTTP receiver was not disabled")
+	}
 }
 
 func TestMultiAndQueuedSpanProcessorConfig(t *testing.T) {
@@ -196,6 +210,63 @@ func TestTailSamplingPoliciesConfiguration(t *testing.T) {
 	}
 }
 
+func TestCompositeSamplingPolicyConfiguration(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/composite_sampling_config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	wCfg := NewDefaultSamplingCfg()
+	wCfg.Mode = TailSampling
+	wCfg.Policies = []*PolicyCfg{
+		{
+			Name:      "slow-checkout",
+			Type:      And,
+			Exporters: []string{"jaeger1"},
+			Configuration: &CompositeCfg{
+				Policies: []*PolicyCfg{
+					{
+						Type: StringAttributeFilter,
+						Configuration: &StringAttributeFilterCfg{
+							Key:    "http.route",
+							Values: []string{"/checkout"},
+						},
+					},
+					{
+						Type: Or,
+						Configuration: &CompositeCfg{
+							Policies: []*PolicyCfg{
+								{
+									Type: NumericAttributeFilter,
+									Configuration: &NumericAttributeFilterCfg{
+										Key:      "http.status_code",
+										MinValue: 500,
+										MaxValue: 599,
+									},
+								},
+								{
+									Type:          RateLimiting,
+									Configuration: &RateLimitingCfg{SpansPerSecond: 100},
+								},
+								{
+									Type:          TraceIDRatio,
+									Configuration: &TraceIDRatioCfg{Fraction: 0.1},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	gCfg := NewDefaultSamplingCfg().InitFromViper(v)
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		gb, _ := json.MarshalIndent(gCfg, "", " ")
+		t.Fatalf("Wanted %+v but got %+v\ngot json:\n%s", *wCfg, *gCfg, string(gb))
+	}
+}
+
 func TestTailSamplingConfig(t *testing.T) {
 	v, err := loadViperFromFile("./testdata/sampling_config.yaml")
 	if err != nil {
@@ -212,6 +283,45 @@ func TestTailSamplingConfig(t *testing.T) {
 	}
 }
 
+func TestRoutingConfig(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/routing_config.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+	if !RoutingEnabled(v) {
+		t.Fatalf("Routing should be enabled")
+	}
+	if RoutingEnabled(viper.New()) {
+		t.Fatalf("Routing should be disabled without configuration")
+	}
+
+	wCfg := &RoutingCfg{
+		DefaultExporters: []string{"honeycomb"},
+		Routes: []RouteCfg{
+			{
+				Name:        "errors",
+				Key:         "error",
+				Values:      []string{"true"},
+				FallThrough: true,
+				Exporters:   []string{"alerting"},
+			},
+			{
+				Name:      "http",
+				Key:       "http.method",
+				Exporters: []string{"honeycomb", "debug"},
+			},
+		},
+	}
+
+	gCfg, err := NewDefaultRoutingCfg().InitFromViper(v)
+	if err != nil {
+		t.Fatalf("got '%v', want nil", err)
+	}
+	if !reflect.DeepEqual(gCfg, wCfg) {
+		t.Fatalf("Wanted %+v but got %+v", *wCfg, *gCfg)
+	}
+}
+
 func TestOpencensusReceiverKeepaliveSettings(t *testing.T) {
 	v, err := loadViperFromFile("./testdata/oc_keepalive_config.yaml")
 	if err != nil {
@@ -35,6 +35,11 @@ type builtExporter struct {
 
 // Stop the exporter.
 func (exp *builtExporter) Stop() error {
+	// The exporters not in any pipeline, or without anything to stop, have
+	// no stop function.
+	if exp.stop == nil {
+		return nil
+	}
 	return exp.stop()
 }
 
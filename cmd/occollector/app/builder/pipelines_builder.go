@@ -32,6 +32,12 @@ type builtProcessor struct {
 	mc consumer.MetricsConsumer
 }
 
+// TraceConsumer returns the consumer of a traces pipeline, nil for the other
+// pipelines.
+func (bp *builtProcessor) TraceConsumer() consumer.TraceConsumer {
+	return bp.tc
+}
+
 // PipelineProcessors is a map of entry-point processors created from pipeline configs.
 // Each element of the map points to the first processor of the pipeline.
 type PipelineProcessors map[*configmodels.Pipeline]*builtProcessor
@@ -19,7 +19,10 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/census-instrumentation/opencensus-service/processor/attributefilterprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/attributeredactionprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/truncatorprocessor"
 )
 
 // SenderType indicates the type of sender
@@ -131,11 +134,84 @@ type AttributesCfg struct {
 	Overwrite       bool                                   `mapstructure:"overwrite"`
 	Values          map[string]interface{}                 `mapstructure:"values"`
 	KeyReplacements []attributekeyprocessor.KeyReplacement `mapstructure:"key-mapping,omitempty"`
+	// Redactions are applied after the key mapping, so they refer to the new keys.
+	Redactions []attributeredactionprocessor.RedactionRule `mapstructure:"redaction,omitempty"`
+	// Filter is applied after the redactions and before the values are added,
+	// the added values are always kept.
+	Filter *attributefilterprocessor.Config `mapstructure:"filter,omitempty"`
+}
+
+// RateLimitCfg holds the configuration of the limit on the rate of spans sent
+// to the exporters, the spans over the limit are dropped.
+type RateLimitCfg struct {
+	// SpansPerSecond is the sustained rate of spans allowed.
+	SpansPerSecond float64 `mapstructure:"spans-per-second"`
+	// Burst is the number of spans allowed above SpansPerSecond in a short
+	// period of time.
+	Burst int `mapstructure:"burst"`
+}
+
+// K8sMetadataCfg holds the configuration for adding the Kubernetes metadata of
+// the pod, read from a Downward API volume, to all spans.
+type K8sMetadataCfg struct {
+	// MetadataDir is where the Downward API volume is mounted.
+	MetadataDir string `mapstructure:"metadata-dir"`
+	// RefreshInterval is how often the metadata is read again.
+	RefreshInterval time.Duration `mapstructure:"refresh-interval"`
+}
+
+// DeduplicationCfg holds the configuration for dropping the spans received
+// multiple times, e.g. from clients retrying their exports.
+type DeduplicationCfg struct {
+	// CacheSize is the maximum number of span IDs remembered.
+	CacheSize int `mapstructure:"cache-size"`
+	// TTL is how long a span ID is remembered.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// AdmissionControlCfg holds the configuration for rejecting the spans too
+// large to go through the pipeline.
+type AdmissionControlCfg struct {
+	// MaxSpanBytes is the maximum size of the JSON encoding of a span.
+	MaxSpanBytes int `mapstructure:"max-span-bytes"`
+}
+
+// SpanLoggingCfg holds the configuration for logging each span sent to the
+// exporters, with its trace and span IDs.
+type SpanLoggingCfg struct {
+	// Level is the level the spans are logged at, e.g. "debug" or "info".
+	Level string `mapstructure:"level"`
+}
+
+// CircuitBreakerCfg holds the configuration of the circuit breakers put in
+// front of each exporter, so that a failing backend does not back up the
+// pipeline.
+type CircuitBreakerCfg struct {
+	// FailureThreshold is the number of consecutive errors that opens the
+	// circuit.
+	FailureThreshold int `mapstructure:"failure-threshold"`
+	// RecoveryTimeout is how long the spans are dropped before the exporter is
+	// tried again.
+	RecoveryTimeout time.Duration `mapstructure:"recovery-timeout"`
 }
 
 // GlobalProcessorCfg holds global configuration values that apply to all processors
 type GlobalProcessorCfg struct {
-	Attributes *AttributesCfg `mapstructure:"attributes"`
+	Attributes    *AttributesCfg    `mapstructure:"attributes"`
+	RateLimit     *RateLimitCfg     `mapstructure:"rate-limit"`
+	K8sMetadata   *K8sMetadataCfg   `mapstructure:"k8s-metadata"`
+	Deduplication *DeduplicationCfg `mapstructure:"deduplication"`
+	// AdmissionControl rejects the spans too large before any processing.
+	AdmissionControl *AdmissionControlCfg `mapstructure:"admission-control"`
+	// CircuitBreaker wraps each of the exporters in a circuit breaker.
+	CircuitBreaker *CircuitBreakerCfg `mapstructure:"circuit-breaker"`
+	// Truncation limits the length of the string values of the spans.
+	Truncation *truncatorprocessor.Config `mapstructure:"truncation"`
+	// DeduplicateAnnotations drops the annotations repeating the message of a
+	// previous annotation of the same span.
+	DeduplicateAnnotations bool `mapstructure:"deduplicate-annotations"`
+	// SpanLogging logs each span sent to the exporters.
+	SpanLogging *SpanLoggingCfg `mapstructure:"span-logging"`
 }
 
 // NewDefaultQueuedSpanProcessorCfg returns an instance of QueuedSpanProcessorCfg with default values
@@ -16,10 +16,14 @@ package builder
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/census-instrumentation/opencensus-service/processor/attributefilterprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/attributeredactionprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/truncatorprocessor"
 )
 
 func TestGlobalProcessorCfg_InitFromViper(t *testing.T) {
@@ -46,6 +50,33 @@ func TestGlobalProcessorCfg_InitFromViper(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "redaction",
+			file: "./testdata/global_attributes_redaction.yaml",
+			want: &AttributesCfg{
+				Redactions: []attributeredactionprocessor.RedactionRule{
+					{
+						Key:    "password",
+						Action: attributeredactionprocessor.Drop,
+					},
+					{
+						Key:         "http.url",
+						Action:      attributeredactionprocessor.Regex,
+						Pattern:     "token=[^&]*",
+						Replacement: "token=REDACTED",
+					},
+				},
+			},
+		},
+		{
+			name: "filter",
+			file: "./testdata/global_attributes_filter.yaml",
+			want: &AttributesCfg{
+				Filter: &attributefilterprocessor.Config{
+					Denylist: []string{"http.*", "db.statement"},
+				},
+			},
+		},
 		{
 			name: "all_settings",
 			file: "./testdata/global_attributes_all.yaml",
@@ -87,3 +118,142 @@ func TestGlobalProcessorCfg_InitFromViper(t *testing.T) {
 		})
 	}
 }
+
+func TestGlobalRateLimitCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_rate_limit.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	want := &RateLimitCfg{SpansPerSecond: 5000, Burst: 10000}
+	if diff := cmp.Diff(cfg.Global.RateLimit, want); diff != "" {
+		t.Errorf("Mismatched rate limit configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
+
+func TestGlobalK8sMetadataCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_k8s_metadata.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	want := &K8sMetadataCfg{MetadataDir: "/etc/podinfo", RefreshInterval: 30 * time.Second}
+	if diff := cmp.Diff(cfg.Global.K8sMetadata, want); diff != "" {
+		t.Errorf("Mismatched k8s metadata configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
+
+func TestGlobalDeduplicationCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_deduplication.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	want := &DeduplicationCfg{CacheSize: 100000, TTL: 5 * time.Minute}
+	if diff := cmp.Diff(cfg.Global.Deduplication, want); diff != "" {
+		t.Errorf("Mismatched deduplication configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
+
+func TestGlobalAdmissionControlCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_admission_control.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	want := &AdmissionControlCfg{MaxSpanBytes: 65536}
+	if diff := cmp.Diff(cfg.Global.AdmissionControl, want); diff != "" {
+		t.Errorf("Mismatched admission control configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
+
+func TestGlobalDeduplicateAnnotations_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_deduplicate_annotations.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+	if !cfg.Global.DeduplicateAnnotations {
+		t.Errorf("got DeduplicateAnnotations false, want true")
+	}
+}
+
+func TestGlobalSpanLoggingCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_span_logging.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	want := &SpanLoggingCfg{Level: "info"}
+	if diff := cmp.Diff(cfg.Global.SpanLogging, want); diff != "" {
+		t.Errorf("Mismatched span logging configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
+
+func TestGlobalCircuitBreakerCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_circuit_breaker.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	want := &CircuitBreakerCfg{FailureThreshold: 5, RecoveryTimeout: 30 * time.Second}
+	if diff := cmp.Diff(cfg.Global.CircuitBreaker, want); diff != "" {
+		t.Errorf("Mismatched circuit breaker configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
+
+func TestGlobalTruncationCfg_InitFromViper(t *testing.T) {
+	v, err := loadViperFromFile("./testdata/global_truncation.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load viper from test file: %v", err)
+	}
+
+	cfg := NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if cfg.Global == nil {
+		t.Fatalf("got nil, want non-nil")
+	}
+
+	suffix := "..."
+	want := &truncatorprocessor.Config{
+		MaxAttributeValueBytes:    4096,
+		MaxAnnotationMessageBytes: 256,
+		Suffix:                    &suffix,
+	}
+	if diff := cmp.Diff(cfg.Global.Truncation, want); diff != "" {
+		t.Errorf("Mismatched truncation configuration\n-Got +Want:\n\t%s", diff)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"github.com/spf13/viper"
+)
+
+const routingTag = "routing"
+
+// RouteCfg holds the configuration of a route of the routing processor.
+type RouteCfg struct {
+	// Name given to the route to make easy to identify it in logs.
+	Name string `mapstructure:"name"`
+	// Key of the attribute that the route is going to be matching against.
+	Key string `mapstructure:"key"`
+	// Values if set, restricts the route to the spans with the attribute equal,
+	// in its string form, to one of them. Otherwise any span with the attribute
+	// matches.
+	Values []string `mapstructure:"values"`
+	// FallThrough allows the spans matched by the route to be matched by the
+	// routes after it too.
+	FallThrough bool `mapstructure:"fall-through"`
+	// Exporters hold the name of the exporters receiving the matched spans.
+	Exporters []string `mapstructure:"exporters"`
+}
+
+// RoutingCfg holds the configuration of the routing processor, which sends
+// spans to different exporters according to their attributes.
+type RoutingCfg struct {
+	// DefaultExporters receive the spans that matched no route, if empty all
+	// the exporters receive them.
+	DefaultExporters []string `mapstructure:"default-exporters"`
+	// Routes evaluated in order for each span.
+	Routes []RouteCfg `mapstructure:"routes"`
+}
+
+// RoutingEnabled checks if the routing processor is enabled, via a config file.
+func RoutingEnabled(v *viper.Viper) bool {
+	return v.Sub(routingTag) != nil
+}
+
+// NewDefaultRoutingCfg creates a RoutingCfg with the default values.
+func NewDefaultRoutingCfg() *RoutingCfg {
+	return &RoutingCfg{}
+}
+
+// InitFromViper initializes RoutingCfg with properties from viper.
+func (rCfg *RoutingCfg) InitFromViper(v *viper.Viper) (*RoutingCfg, error) {
+	return rCfg, initFromViper(rCfg, v, routingTag)
+}
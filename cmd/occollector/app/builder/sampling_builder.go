@@ -17,6 +17,7 @@ package builder
 import (
 	"time"
 
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 )
 
@@ -55,6 +56,13 @@ const (
 	StringAttributeFilter PolicyType = "string-attribute-filter"
 	// RateLimiting allows all traces until the specified limits are satisfied.
 	RateLimiting PolicyType = "rate-limiting"
+	// TraceIDRatio samples a fraction of the traces, deciding from their trace IDs like the
+	// probability sampler of the OpenCensus libraries.
+	TraceIDRatio PolicyType = "trace-id-ratio"
+	// And samples the traces sampled by all of the policies listed in its configuration.
+	And PolicyType = "and"
+	// Or samples the traces sampled by any of the policies listed in its configuration.
+	Or PolicyType = "or"
 )
 
 // PolicyCfg holds the common configuration to all policies.
@@ -97,6 +105,20 @@ type RateLimitingCfg struct {
 	SpansPerSecond int64 `mapstructure:"spans-per-second"`
 }
 
+// TraceIDRatioCfg holds the configurable settings to create a trace ID ratio sampling
+// policy evaluator.
+type TraceIDRatioCfg struct {
+	// Fraction of the traces to be sampled, between 0 and 1.
+	Fraction float64 `mapstructure:"fraction"`
+}
+
+// CompositeCfg holds the configurable settings to create an and or an or
+// sampling policy evaluator.
+type CompositeCfg struct {
+	// Policies combined by the policy, only their Type and Configuration are used.
+	Policies []*PolicyCfg
+}
+
 // SamplingCfg holds the sampling configuration.
 type SamplingCfg struct {
 	// Mode specifies the sampling mode to be used.
@@ -127,36 +149,60 @@ func (sCfg *SamplingCfg) InitFromViper(v *viper.Viper) *SamplingCfg {
 	}
 
 	for policyName := range sv.GetStringMap(policiesTag) {
-		polSub := pv.Sub(policyName)
-		polCfg := &PolicyCfg{}
+		polCfg := policyCfgFromViper(pv.Sub(policyName))
 		polCfg.Name = policyName
-		polCfg.Type = PolicyType(polSub.GetString("policy"))
-		polCfg.Exporters = polSub.GetStringSlice("exporters")
-
-		cfgSub := polSub.Sub("configuration")
-		if cfgSub != nil {
-			// As the number of polices grow this likely should be in a map.
-			var cfg interface{}
-			switch polCfg.Type {
-			case NumericAttributeFilter:
-				numAttributeFilterCfg := &NumericAttributeFilterCfg{}
-				cfg = numAttributeFilterCfg
-			case StringAttributeFilter:
-				strAttributeFilterCfg := &StringAttributeFilterCfg{}
-				cfg = strAttributeFilterCfg
-			case RateLimiting:
-				rateLimitingCfg := &RateLimitingCfg{}
-				cfg = rateLimitingCfg
-			}
-			cfgSub.Unmarshal(cfg)
-			polCfg.Configuration = cfg
-		}
-
 		sCfg.Policies = append(sCfg.Policies, polCfg)
 	}
 	return sCfg
 }
 
+func policyCfgFromViper(polSub *viper.Viper) *PolicyCfg {
+	polCfg := &PolicyCfg{}
+	polCfg.Type = PolicyType(polSub.GetString("policy"))
+	polCfg.Exporters = polSub.GetStringSlice("exporters")
+
+	cfgSub := polSub.Sub("configuration")
+	if cfgSub != nil {
+		// As the number of polices grow this likely should be in a map.
+		var cfg interface{}
+		switch polCfg.Type {
+		case NumericAttributeFilter:
+			numAttributeFilterCfg := &NumericAttributeFilterCfg{}
+			cfg = numAttributeFilterCfg
+		case StringAttributeFilter:
+			strAttributeFilterCfg := &StringAttributeFilterCfg{}
+			cfg = strAttributeFilterCfg
+		case RateLimiting:
+			rateLimitingCfg := &RateLimitingCfg{}
+			cfg = rateLimitingCfg
+		case TraceIDRatio:
+			traceIDRatioCfg := &TraceIDRatioCfg{}
+			cfg = traceIDRatioCfg
+		case And, Or:
+			polCfg.Configuration = compositeCfgFromViper(cfgSub)
+			return polCfg
+		}
+		cfgSub.Unmarshal(cfg)
+		polCfg.Configuration = cfg
+	}
+	return polCfg
+}
+
+// compositeCfgFromViper reads the list of policies of an and or an or policy,
+// each one is given as the policy and configuration of a regular policy.
+func compositeCfgFromViper(cfgSub *viper.Viper) *CompositeCfg {
+	compositeCfg := &CompositeCfg{}
+	policies, _ := cfgSub.Get(policiesTag).([]interface{})
+	for _, policy := range policies {
+		subPolicy := viper.New()
+		for key, value := range cast.ToStringMap(policy) {
+			subPolicy.Set(key, value)
+		}
+		compositeCfg.Policies = append(compositeCfg.Policies, policyCfgFromViper(subPolicy))
+	}
+	return compositeCfg
+}
+
 // TailBasedCfg holds the configuration for tail-based sampling.
 type TailBasedCfg struct {
 	// DecisionWait is the desired wait time from the arrival of the first span of
@@ -165,6 +211,9 @@ type TailBasedCfg struct {
 	// NumTraces is the number of traces kept on memory. Typically most of the data
 	// of a trace is released after a sampling decision is taken.
 	NumTraces uint64 `mapstructure:"num-traces"`
+	// NumSpans if set, limits the number of spans kept on memory waiting for the
+	// sampling decisions. The oldest traces are dropped to respect the limit.
+	NumSpans uint64 `mapstructure:"num-spans"`
 }
 
 // NewDefaultTailBasedCfg creates a TailBasedCfg with the default values.
@@ -30,7 +30,9 @@ import (
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/selftracing"
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/configmodels"
 	"github.com/census-instrumentation/opencensus-service/internal/configv2"
 	"github.com/census-instrumentation/opencensus-service/internal/pprofserver"
 	"github.com/census-instrumentation/opencensus-service/internal/zpagesserver"
@@ -48,6 +50,7 @@ type Application struct {
 	logger      *zap.Logger
 	healthCheck *healthcheck.HealthCheck
 	processor   consumer.TraceConsumer
+	inFlight    *inFlightProcessor
 	receivers   []receiver.TraceReceiver
 	exporters   builder.Exporters
 
@@ -63,6 +66,10 @@ type Application struct {
 	// Various components can add their own functions that they need to be
 	// called for cleanup during shutdown.
 	closeFns []func()
+
+	// processorCloseFns are the functions closing the current exporters and
+	// processors, when they are rebuilt on configuration changes.
+	processorCloseFns []func()
 }
 
 func newApp() *Application {
@@ -119,6 +126,14 @@ func (app *Application) setupZPages() {
 	}
 }
 
+func (app *Application) setupSelfTracing() {
+	if builder.SelfTracingEnabled(app.v) {
+		app.logger.Info("Tracing the collector pipeline, the internal spans are logged at debug level")
+		disable := selftracing.Enable(selftracing.NewLoggingExporter(app.logger))
+		app.closeFns = append(app.closeFns, disable)
+	}
+}
+
 func (app *Application) setupTelemetry() {
 	err := AppTelemetry.init(app.asyncErrorChannel, app.v, app.logger)
 	if err != nil {
@@ -152,9 +167,7 @@ func (app *Application) runAndWaitForShutdownEvent() {
 }
 
 func (app *Application) shutdownReceivers() {
-	for _, receiver := range app.receivers {
-		receiver.StopTraceReception(context.Background())
-	}
+	receiver.NewReceiverGroup(app.receivers...).Close()
 }
 
 func (app *Application) shutdownClosableComponents() {
@@ -172,8 +185,11 @@ func (app *Application) execute() {
 
 	app.setupPProf()
 	app.setupHealthCheck()
-	app.processor, app.closeFns = startProcessor(app.v, app.logger)
+	app.setupProcessor()
+	app.inFlight = newInFlightProcessor(app.processor)
+	app.processor = app.inFlight
 	app.setupZPages()
+	app.setupSelfTracing()
 	app.receivers = createReceivers(app.v, app.logger, app.processor, app.asyncErrorChannel)
 	app.setupTelemetry()
 
@@ -186,12 +202,13 @@ func (app *Application) execute() {
 	app.healthCheck.Set(healthcheck.Unavailable)
 	app.logger.Info("Starting shutdown...")
 
-	// TODO: orderly shutdown: first receivers, then flushing pipelines giving
-	// senders a chance to send all their data. This may take time, the allowed
-	// time should be part of configuration.
-	app.shutdownReceivers()
-
-	app.shutdownClosableComponents()
+	drainTimeout := builder.ShutdownDrainTimeout(app.v)
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	if err := app.Shutdown(ctx); err != nil {
+		app.logger.Warn("Closing the exporters while spans are still being processed",
+			zap.Duration("drain-timeout", drainTimeout), zap.Error(err))
+	}
+	cancel()
 
 	AppTelemetry.shutdown()
 
@@ -222,8 +239,15 @@ func (app *Application) Start() error {
 }
 
 func (app *Application) setupPipelines() {
-	// Load configuration.
-	config, err := configv2.Load(app.v)
+	// Load configuration, from the file if one was given to also replace the
+	// environment variables and warn about the unknown settings.
+	var config *configmodels.ConfigV2
+	var err error
+	if file := builder.GetConfigFile(app.v); file != "" {
+		config, err = configv2.LoadFromFile(file, app.logger)
+	} else {
+		config, err = configv2.Load(app.v)
+	}
 	if err != nil {
 		log.Fatalf("Cannot load configuration: %v", err)
 	}
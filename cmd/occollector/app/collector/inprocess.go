@@ -0,0 +1,170 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/configmodels"
+	"github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+// inProcessSourceFormat is the source format of the spans sent to an
+// InProcess collector.
+const inProcessSourceFormat = "inprocess"
+
+var (
+	errInProcessStarted = errors.New("the in-process collector is already started")
+	errNoPipelinesCfg   = errors.New("the configuration of the pipelines is missing")
+)
+
+// Config is the configuration of an InProcess collector.
+type Config struct {
+	// Pipelines holds the processors, exporters and pipelines, as loaded by
+	// configv2. Its receivers are ignored, the spans enter the traces
+	// pipelines through the exporters returned by InProcess.ExporterFor.
+	Pipelines *configmodels.ConfigV2
+	// Node, if set, describes the process of the spans.
+	Node *commonpb.Node
+	// Logger is the logger of the components, a no-op one by default.
+	Logger *zap.Logger
+}
+
+// InProcess is a collector embedded in a process, e.g. by a library, which
+// sends its OpenCensus spans to the pipelines without any receiver or
+// network hop in between. The zero value is ready to be started.
+type InProcess struct {
+	mu        sync.Mutex
+	node      *commonpb.Node
+	logger    *zap.Logger
+	exporters builder.Exporters
+	pipelines map[string]*inFlightProcessor
+}
+
+// Start builds the exporters and pipelines of cfg.
+func (ip *InProcess) Start(cfg Config) error {
+	if cfg.Pipelines == nil {
+		return errNoPipelinesCfg
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+	if ip.pipelines != nil {
+		return errInProcessStarted
+	}
+
+	exporters, err := builder.NewExportersBuilder(logger, cfg.Pipelines).Build()
+	if err != nil {
+		return err
+	}
+	pipelineProcessors, err := builder.NewPipelinesBuilder(logger, cfg.Pipelines, exporters).Build()
+	if err != nil {
+		exporters.StopAll()
+		return err
+	}
+
+	ip.node = cfg.Node
+	ip.logger = logger
+	ip.exporters = exporters
+	ip.pipelines = make(map[string]*inFlightProcessor)
+	for pipeline, firstProcessor := range pipelineProcessors {
+		if tc := firstProcessor.TraceConsumer(); tc != nil {
+			ip.pipelines[pipeline.Name] = newInFlightProcessor(tc)
+		}
+	}
+	return nil
+}
+
+// ExporterFor returns the trace.Exporter sending the spans to the traces
+// pipeline of the given name, to be registered with trace.RegisterExporter.
+// It returns nil if the collector isn't started or has no such pipeline. The
+// exporter drops the spans once the collector is stopped.
+func (ip *InProcess) ExporterFor(name string) trace.Exporter {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	pipeline, ok := ip.pipelines[name]
+	if !ok {
+		return nil
+	}
+	return &inProcessExporter{
+		pipelineName: name,
+		node:         ip.node,
+		logger:       ip.logger,
+		pipeline:     pipeline,
+	}
+}
+
+// Stop rejects the spans from now on, waits for the spans being processed to
+// be passed to the exporters, for as long as ctx allows, and then stops the
+// exporters. The exporters are stopped even if ctx is done first, its error
+// is then returned. The collector can be started again once stopped.
+func (ip *InProcess) Stop(ctx context.Context) error {
+	ip.mu.Lock()
+	pipelines, exporters := ip.pipelines, ip.exporters
+	ip.pipelines, ip.exporters = nil, nil
+	ip.mu.Unlock()
+
+	var err error
+	for _, pipeline := range pipelines {
+		if drainErr := pipeline.drain(ctx); drainErr != nil {
+			err = drainErr
+		}
+	}
+	exporters.StopAll()
+	return err
+}
+
+// inProcessExporter sends the OpenCensus spans to a pipeline, one at a time
+// as the OpenCensus library exports them.
+type inProcessExporter struct {
+	pipelineName string
+	node         *commonpb.Node
+	logger       *zap.Logger
+	pipeline     *inFlightProcessor
+}
+
+var _ trace.Exporter = (*inProcessExporter)(nil)
+
+func (ipe *inProcessExporter) ExportSpan(sd *trace.SpanData) {
+	span, err := spandata.OCSpanDataToProtoSpan(sd)
+	if err != nil {
+		ipe.logger.Warn("Failed to convert a span of the in-process pipeline",
+			zap.String("pipeline", ipe.pipelineName), zap.Error(err))
+		return
+	}
+	td := data.TraceData{
+		Node:         ipe.node,
+		Spans:        []*tracepb.Span{span},
+		SourceFormat: inProcessSourceFormat,
+	}
+	if err := ipe.pipeline.ConsumeTraceData(context.Background(), td); err != nil {
+		ipe.logger.Warn("Failed to send a span to the in-process pipeline",
+			zap.String("pipeline", ipe.pipelineName), zap.Error(err))
+	}
+}
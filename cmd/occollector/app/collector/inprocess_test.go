@@ -0,0 +1,173 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/configmodels"
+	"github.com/census-instrumentation/opencensus-service/internal/factories"
+	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
+)
+
+const sinkExporterType = "inprocesssink"
+
+var _ = factories.RegisterExporterFactory(&sinkExporterFactory{})
+
+// sinkExporterCfg configures an exporter passing the spans to its sink.
+type sinkExporterCfg struct {
+	configmodels.ExporterSettings
+	sink    *exportertest.SinkTraceExporter
+	stopped bool
+}
+
+type sinkExporterFactory struct{}
+
+func (f *sinkExporterFactory) Type() string {
+	return sinkExporterType
+}
+
+func (f *sinkExporterFactory) CreateDefaultConfig() configmodels.Exporter {
+	return &sinkExporterCfg{ExporterSettings: configmodels.ExporterSettings{TypeVal: sinkExporterType}}
+}
+
+func (f *sinkExporterFactory) CreateTraceExporter(cfg configmodels.Exporter) (consumer.TraceConsumer, factories.StopFunc, error) {
+	sc := cfg.(*sinkExporterCfg)
+	return sc.sink, func() error {
+		sc.stopped = true
+		return nil
+	}, nil
+}
+
+func (f *sinkExporterFactory) CreateMetricsExporter(cfg configmodels.Exporter) (consumer.MetricsConsumer, factories.StopFunc, error) {
+	return nil, nil, factories.ErrDataTypeIsNotSupported
+}
+
+func TestInProcess(t *testing.T) {
+	sinkCfg := &sinkExporterCfg{
+		ExporterSettings: configmodels.ExporterSettings{TypeVal: sinkExporterType, NameVal: "sink", Enabled: true},
+		sink:             new(exportertest.SinkTraceExporter),
+	}
+	cfg := Config{
+		Pipelines: &configmodels.ConfigV2{
+			Exporters: configmodels.Exporters{"sink": sinkCfg},
+			Processors: configmodels.Processors{
+				"attributes": &addattributesprocessor.ConfigV2{
+					ProcessorSettings: configmodels.ProcessorSettings{TypeVal: "attributes", Enabled: true},
+					Values:            map[string]interface{}{"embedded": "yes"},
+				},
+			},
+			Pipelines: configmodels.Pipelines{
+				"traces": &configmodels.Pipeline{
+					Name:       "traces",
+					InputType:  configmodels.TracesDataType,
+					Processors: []string{"attributes"},
+					Exporters:  []string{"sink"},
+				},
+			},
+		},
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "library"}},
+	}
+
+	ip := new(InProcess)
+	if exp := ip.ExporterFor("traces"); exp != nil {
+		t.Errorf("ExporterFor() before Start() = %v, want nil", exp)
+	}
+	if err := ip.Start(cfg); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := ip.Start(cfg); err != errInProcessStarted {
+		t.Errorf("Start() when started error = %v, want %v", err, errInProcessStarted)
+	}
+	if exp := ip.ExporterFor("unknown"); exp != nil {
+		t.Errorf("ExporterFor() of an unknown pipeline = %v, want nil", exp)
+	}
+
+	exp := ip.ExporterFor("traces")
+	if exp == nil {
+		t.Fatal("ExporterFor() = nil, want the exporter of the traces pipeline")
+	}
+	trace.RegisterExporter(exp)
+	defer trace.UnregisterExporter(exp)
+
+	_, span := trace.StartSpan(context.Background(), "embedded-op", trace.WithSampler(trace.AlwaysSample()))
+	span.End()
+
+	got := sinkCfg.sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 1 {
+		t.Fatalf("Exported %+v, want a single span", got)
+	}
+	td := got[0]
+	if td.SourceFormat != inProcessSourceFormat {
+		t.Errorf("SourceFormat = %q, want %q", td.SourceFormat, inProcessSourceFormat)
+	}
+	if name := td.Node.GetServiceInfo().GetName(); name != "library" {
+		t.Errorf("Node service name = %q, want %q", name, "library")
+	}
+	exported := td.Spans[0]
+	if exported.Name.GetValue() != "embedded-op" {
+		t.Errorf("Span name = %q, want %q", exported.Name.GetValue(), "embedded-op")
+	}
+	wantSC := span.SpanContext()
+	if string(exported.TraceId) != string(wantSC.TraceID[:]) || string(exported.SpanId) != string(wantSC.SpanID[:]) {
+		t.Errorf("Span IDs = %x/%x, want %v/%v", exported.TraceId, exported.SpanId, wantSC.TraceID, wantSC.SpanID)
+	}
+	// The spans went through the processors of the pipeline.
+	if v := exported.Attributes.GetAttributeMap()["embedded"].GetStringValue().GetValue(); v != "yes" {
+		t.Errorf("Attribute added by the pipeline = %q, want %q", v, "yes")
+	}
+
+	if err := ip.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if !sinkCfg.stopped {
+		t.Error("The exporter wasn't stopped")
+	}
+	_, span = trace.StartSpan(context.Background(), "after-stop", trace.WithSampler(trace.AlwaysSample()))
+	span.End()
+	if n := len(sinkCfg.sink.AllTraces()); n != 1 {
+		t.Errorf("Got %d TraceData after Stop(), want the span dropped", n)
+	}
+}
+
+func TestInProcessWithoutPipelines(t *testing.T) {
+	if err := new(InProcess).Start(Config{}); err != errNoPipelinesCfg {
+		t.Errorf("Start() error = %v, want %v", err, errNoPipelinesCfg)
+	}
+}
+
+func TestInProcessExporterLogsConversionErrors(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	ipe := &inProcessExporter{pipelineName: "traces", logger: zap.New(core)}
+
+	ipe.ExportSpan(nil)
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("Got %d log entries, want 1", len(entries))
+	}
+	if got := entries[0].ContextMap()["pipeline"]; got != "traces" {
+		t.Errorf("Got pipeline %v, want traces", got)
+	}
+}
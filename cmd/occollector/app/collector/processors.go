@@ -15,6 +15,7 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -22,27 +23,42 @@ import (
 	tchReporter "github.com/jaegertracing/jaeger/cmd/agent/app/reporter/tchannel"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
 	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/sender"
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/loggingexporter"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/tailsampling"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/selftracing"
 	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/processor"
 	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/admissioncontrolprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/annotationdeduplicatorprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/attributefilterprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/attributeredactionprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/circuitbreakerprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/deduplicatorprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/k8senricherprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/loggingprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
+	"github.com/census-instrumentation/opencensus-service/processor/ratelimiterprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/routerprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/tracesamplerprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/truncatorprocessor"
 )
 
-func createExporters(v *viper.Viper, logger *zap.Logger) ([]func(), []consumer.TraceConsumer, []consumer.MetricsConsumer) {
+func createExporters(v *viper.Viper, logger *zap.Logger) ([]func(), []consumer.TraceConsumer, []consumer.MetricsConsumer, error) {
 	// TODO: (@pjanotti) this is slightly modified from agent but in the end duplication, need to consolidate style and visibility.
 	traceExporters, metricsExporters, doneFns, err := config.ExportersFromViperConfig(logger, v)
 	if err != nil {
-		logger.Fatal("Failed to create config for exporters", zap.Error(err))
+		return nil, nil, nil, fmt.Errorf("failed to create config for exporters: %v", err)
 	}
 
 	wrappedDoneFns := make([]func(), 0, len(doneFns))
@@ -56,7 +72,7 @@ func createExporters(v *viper.Viper, logger *zap.Logger) ([]func(), []consumer.T
 		wrappedDoneFns = append(wrappedDoneFns, wrapperFn)
 	}
 
-	return wrappedDoneFns, traceExporters, metricsExporters
+	return wrappedDoneFns, traceExporters, metricsExporters, nil
 }
 
 func buildQueuedSpanProcessor(
@@ -77,8 +93,7 @@ func buildQueuedSpanProcessor(
 		}
 		tchreporter, err := tchrepbuilder.CreateReporter(logger)
 		if err != nil {
-			logger.Fatal("Cannot create tchannel reporter.", zap.Error(err))
-			return nil, nil, err
+			return nil, nil, fmt.Errorf("cannot create tchannel reporter: %v", err)
 		}
 		spanSender = sender.NewJaegerThriftTChannelSender(tchreporter, logger)
 	case builder.ThriftHTTPSenderType:
@@ -100,13 +115,16 @@ func buildQueuedSpanProcessor(
 			logger,
 		)
 	}
-	doneFns, traceExporters, _ := createExporters(opts.RawConfig, logger)
+	doneFns, traceExporters, _, err := createExporters(opts.RawConfig, logger)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if spanSender == nil && len(traceExporters) == 0 {
 		if opts.SenderType != "" {
-			logger.Fatal("Unrecognized sender type", zap.String("SenderType", string(opts.SenderType)))
+			return doneFns, nil, fmt.Errorf("unrecognized sender type %q", opts.SenderType)
 		}
-		logger.Fatal("No senders or exporters configured.")
+		return doneFns, nil, fmt.Errorf("no senders or exporters configured")
 	}
 
 	allSendersAndExporters := make([]consumer.TraceConsumer, 0, 1+len(traceExporters))
@@ -166,6 +184,45 @@ func buildQueuedSpanProcessor(
 	return doneFns, multiconsumer.NewTraceProcessor(queuedConsumers), nil
 }
 
+func buildPolicyEvaluator(polCfg *builder.PolicyCfg) (sampling.PolicyEvaluator, error) {
+	// As the number of sampling policies grow this should be changed to a map.
+	switch polCfg.Type {
+	case builder.AlwaysSample:
+		return sampling.NewAlwaysSample(), nil
+	case builder.NumericAttributeFilter:
+		numAttributeFilterCfg := polCfg.Configuration.(*builder.NumericAttributeFilterCfg)
+		return sampling.NewNumericAttributeFilter(numAttributeFilterCfg.Key, numAttributeFilterCfg.MinValue, numAttributeFilterCfg.MaxValue), nil
+	case builder.StringAttributeFilter:
+		strAttributeFilterCfg := polCfg.Configuration.(*builder.StringAttributeFilterCfg)
+		return sampling.NewStringAttributeFilter(strAttributeFilterCfg.Key, strAttributeFilterCfg.Values), nil
+	case builder.RateLimiting:
+		rateLimitingCfg := polCfg.Configuration.(*builder.RateLimitingCfg)
+		return sampling.NewRateLimiting(rateLimitingCfg.SpansPerSecond), nil
+	case builder.TraceIDRatio:
+		traceIDRatioCfg := polCfg.Configuration.(*builder.TraceIDRatioCfg)
+		return sampling.NewTraceIDRatio(traceIDRatioCfg.Fraction), nil
+	case builder.And, builder.Or:
+		compositeCfg, _ := polCfg.Configuration.(*builder.CompositeCfg)
+		if compositeCfg == nil || len(compositeCfg.Policies) == 0 {
+			return nil, fmt.Errorf("no policies for sampling policy %q", polCfg.Name)
+		}
+		var evaluators []sampling.PolicyEvaluator
+		for _, subPolicyCfg := range compositeCfg.Policies {
+			subPolicyCfg.Name = polCfg.Name
+			evaluator, err := buildPolicyEvaluator(subPolicyCfg)
+			if err != nil {
+				return nil, err
+			}
+			evaluators = append(evaluators, evaluator)
+		}
+		if polCfg.Type == builder.And {
+			return sampling.NewAnd(evaluators...), nil
+		}
+		return sampling.NewOr(evaluators...), nil
+	}
+	return nil, fmt.Errorf("unknown sampling policy %s", polCfg.Name)
+}
+
 func buildSamplingProcessor(cfg *builder.SamplingCfg, nameToTraceConsumer map[string]consumer.TraceConsumer, v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer, error) {
 	var policies []*tailsampling.Policy
 	seenExporter := make(map[string]bool)
@@ -174,22 +231,11 @@ func buildSamplingProcessor(cfg *builder.SamplingCfg, nameToTraceConsumer map[st
 			Name: string(polCfg.Name),
 		}
 
-		// As the number of sampling policies grow this should be changed to a map.
-		switch polCfg.Type {
-		case builder.AlwaysSample:
-			policy.Evaluator = sampling.NewAlwaysSample()
-		case builder.NumericAttributeFilter:
-			numAttributeFilterCfg := polCfg.Configuration.(*builder.NumericAttributeFilterCfg)
-			policy.Evaluator = sampling.NewNumericAttributeFilter(numAttributeFilterCfg.Key, numAttributeFilterCfg.MinValue, numAttributeFilterCfg.MaxValue)
-		case builder.StringAttributeFilter:
-			strAttributeFilterCfg := polCfg.Configuration.(*builder.StringAttributeFilterCfg)
-			policy.Evaluator = sampling.NewStringAttributeFilter(strAttributeFilterCfg.Key, strAttributeFilterCfg.Values)
-		case builder.RateLimiting:
-			rateLimitingCfg := polCfg.Configuration.(*builder.RateLimitingCfg)
-			policy.Evaluator = sampling.NewRateLimiting(rateLimitingCfg.SpansPerSecond)
-		default:
-			return nil, fmt.Errorf("unknown sampling policy %s", polCfg.Name)
+		evaluator, err := buildPolicyEvaluator(polCfg)
+		if err != nil {
+			return nil, err
 		}
+		policy.Evaluator = evaluator
 
 		var policyProcessors []consumer.TraceConsumer
 		for _, exporter := range polCfg.Exporters {
@@ -229,18 +275,108 @@ func buildSamplingProcessor(cfg *builder.SamplingCfg, nameToTraceConsumer map[st
 		tailCfg.NumTraces,
 		128,
 		tailCfg.DecisionWait,
-		logger)
+		logger,
+		tailsampling.WithMaxNumSpans(tailCfg.NumSpans))
 	return tailSamplingProcessor, err
 }
 
+func buildRoutingProcessor(cfg *builder.RoutingCfg, nameToTraceConsumer map[string]consumer.TraceConsumer, allConsumers []consumer.TraceConsumer) (consumer.TraceConsumer, error) {
+	resolve := func(exporters []string, routeName string) (consumer.TraceConsumer, error) {
+		var consumers []consumer.TraceConsumer
+		for _, exporter := range exporters {
+			tc, ok := nameToTraceConsumer[exporter]
+			if !ok {
+				return nil, fmt.Errorf("invalid exporter %q for route %q", exporter, routeName)
+			}
+			consumers = append(consumers, tc)
+		}
+		if len(consumers) == 1 {
+			return consumers[0], nil
+		}
+		return multiconsumer.NewTraceProcessor(consumers), nil
+	}
+
+	var routes []routerprocessor.Route
+	for _, routeCfg := range cfg.Routes {
+		if len(routeCfg.Exporters) == 0 {
+			return nil, fmt.Errorf("no exporters for route %q", routeCfg.Name)
+		}
+		destination, err := resolve(routeCfg.Exporters, routeCfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, routerprocessor.Route{
+			Name:        routeCfg.Name,
+			Key:         routeCfg.Key,
+			Values:      routeCfg.Values,
+			FallThrough: routeCfg.FallThrough,
+			Destination: destination,
+		})
+	}
+
+	defaultConsumer := multiconsumer.NewTraceProcessor(allConsumers)
+	if len(cfg.DefaultExporters) > 0 {
+		var err error
+		if defaultConsumer, err = resolve(cfg.DefaultExporters, "default"); err != nil {
+			return nil, err
+		}
+	}
+	return routerprocessor.NewTraceProcessor(defaultConsumer, routes...)
+}
+
 func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer, []func()) {
+	tp, closeFns, err := buildProcessor(v, logger)
+	if err != nil {
+		logger.Error("Failed to build the processors", zap.Error(err))
+		os.Exit(1)
+	}
+	return tp, closeFns
+}
+
+// buildProcessor builds the exporters and the processors described by the
+// configuration. If it fails the returned functions close the components that
+// were already built.
+func buildProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer, []func(), error) {
 	// Build pipeline from its end: 1st exporters, the OC-proto queue processor, and
 	// finally the receivers.
 	var closeFns []func()
 	var traceConsumers []consumer.TraceConsumer
 	nameToTraceConsumer := make(map[string]consumer.TraceConsumer)
-	exportersCloseFns, traceExporters, metricsExporters := createExporters(v, logger)
+	exportersCloseFns, traceExporters, metricsExporters, err := createExporters(v, logger)
+	if err != nil {
+		return nil, closeFns, err
+	}
 	closeFns = append(closeFns, exportersCloseFns...)
+	traced := selfTracer(v)
+	for i, traceExporter := range traceExporters {
+		var step string
+		if te, ok := traceExporter.(exporter.TraceExporter); ok {
+			step = te.TraceExportFormat()
+		}
+		traceExporters[i] = traced(traceExporter, selftracing.ExportSpanName, step)
+	}
+
+	multiProcessorCfg := builder.NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.CircuitBreaker != nil {
+		circuitBreakerCfg := multiProcessorCfg.Global.CircuitBreaker
+		logger.Info(
+			"Wrapping the exporters in circuit breakers",
+			zap.Int("failure-threshold", circuitBreakerCfg.FailureThreshold),
+			zap.Duration("recovery-timeout", circuitBreakerCfg.RecoveryTimeout),
+		)
+		for i, traceExporter := range traceExporters {
+			traceExporters[i], err = circuitbreakerprocessor.NewTraceProcessor(
+				traceExporter,
+				circuitBreakerCfg.FailureThreshold,
+				circuitBreakerCfg.RecoveryTimeout,
+				circuitbreakerprocessor.WithLogger(logger),
+			)
+			if err != nil {
+				return nil, closeFns, fmt.Errorf("failed to create the circuit breaker processor: %v", err)
+			}
+		}
+	}
+
 	if len(traceExporters) > 0 {
 		// Exporters need an extra hop from OC-proto to span data: to workaround that for now
 		// we will use a special processor that transforms the data to a format that they can consume.
@@ -258,26 +394,25 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 	if builder.LoggingExporterEnabled(v) {
 		dbgProc, _ := loggingexporter.NewTraceExporter(logger)
 		// TODO: Add this to the exporters list and avoid treating it specially. Don't know all the implications.
-		nameToTraceConsumer["debug"] = dbgProc
-		traceConsumers = append(traceConsumers, dbgProc)
+		tracedDbgProc := traced(dbgProc, selftracing.ExportSpanName, "logging")
+		nameToTraceConsumer["debug"] = tracedDbgProc
+		traceConsumers = append(traceConsumers, tracedDbgProc)
 	}
 
-	multiProcessorCfg := builder.NewDefaultMultiSpanProcessorCfg().InitFromViper(v)
 	for _, queuedJaegerProcessorCfg := range multiProcessorCfg.Processors {
 		logger.Info("Queued Jaeger Sender Enabled")
 		doneFns, queuedJaegerProcessor, err := buildQueuedSpanProcessor(logger, queuedJaegerProcessorCfg)
 		if err != nil {
-			logger.Error("Failed to build the queued span processor", zap.Error(err))
-			os.Exit(1)
+			return nil, append(closeFns, doneFns...), fmt.Errorf("failed to build the queued span processor: %v", err)
 		}
-		nameToTraceConsumer[queuedJaegerProcessorCfg.Name] = queuedJaegerProcessor
-		traceConsumers = append(traceConsumers, queuedJaegerProcessor)
+		tracedQueuedProcessor := traced(queuedJaegerProcessor, selftracing.ExportSpanName, "queued-exporter."+queuedJaegerProcessorCfg.Name)
+		nameToTraceConsumer[queuedJaegerProcessorCfg.Name] = tracedQueuedProcessor
+		traceConsumers = append(traceConsumers, tracedQueuedProcessor)
 		closeFns = append(closeFns, doneFns...)
 	}
 
 	if len(traceConsumers) == 0 {
-		logger.Warn("Nothing to do: no processor was enabled. Shutting down.")
-		os.Exit(1)
+		return nil, closeFns, errors.New("nothing to do: no processor was enabled")
 	}
 
 	var tailSamplingProcessor consumer.TraceConsumer
@@ -291,8 +426,7 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 		var err error
 		tailSamplingProcessor, err = buildSamplingProcessor(samplingProcessorCfg, nameToTraceConsumer, v, logger)
 		if err != nil {
-			logger.Error("Falied to build the sampling processor", zap.Error(err))
-			os.Exit(1)
+			return nil, closeFns, fmt.Errorf("failed to build the sampling processor: %v", err)
 		}
 	} else if builder.DebugTailSamplingEnabled(v) {
 		policy := []*tailsampling.Policy{
@@ -305,25 +439,88 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 		var err error
 		tailSamplingProcessor, err = tailsampling.NewTailSamplingSpanProcessor(policy, 50000, 128, 10*time.Second, logger)
 		if err != nil {
-			logger.Error("Falied to build the debug tail-sampling processor", zap.Error(err))
-			os.Exit(1)
+			return nil, closeFns, fmt.Errorf("failed to build the debug tail-sampling processor: %v", err)
 		}
 		logger.Info("Debugging tail-sampling with always sample policy (num_traces: 50000; decision_wait: 10s)")
 	}
 
 	if tailSamplingProcessor != nil {
 		// SpanProcessors are going to go all via the tail sampling processor.
-		traceConsumers = []consumer.TraceConsumer{tailSamplingProcessor}
+		traceConsumers = []consumer.TraceConsumer{traced(tailSamplingProcessor, selftracing.ProcessSpanName, "tail-sampling")}
+	}
+
+	if builder.RoutingEnabled(v) {
+		if tailSamplingProcessor != nil {
+			return nil, closeFns, errors.New("routing can't be used together with tail-sampling, both dispatch spans to the exporters")
+		}
+		routingCfg, err := builder.NewDefaultRoutingCfg().InitFromViper(v)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to read the routing configuration: %v", err)
+		}
+		routingProcessor, err := buildRoutingProcessor(routingCfg, nameToTraceConsumer, traceConsumers)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to build the routing processor: %v", err)
+		}
+		logger.Info("Routing enabled", zap.Int("routes", len(routingCfg.Routes)))
+		traceConsumers = []consumer.TraceConsumer{traced(routingProcessor, selftracing.ProcessSpanName, "routing")}
 	}
 
 	// Wraps processors in a single one to be connected to all enabled receivers.
 	tp := multiconsumer.NewTraceProcessor(traceConsumers)
+
+	// The spans are logged right before being exported, with all the processing applied.
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.SpanLogging != nil {
+		level := zapcore.DebugLevel
+		if lvl := multiProcessorCfg.Global.SpanLogging.Level; lvl != "" {
+			if err := level.UnmarshalText([]byte(lvl)); err != nil {
+				return nil, closeFns, fmt.Errorf("invalid span logging level %q: %v", lvl, err)
+			}
+		}
+		logger.Info("Logging the spans sent to the exporters", zap.Stringer("level", level))
+		var err error
+		tp, err = loggingprocessor.NewTraceProcessor(tp, logger, loggingprocessor.WithLevel(level))
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the logging processor: %v", err)
+		}
+		tp = traced(tp, selftracing.ProcessSpanName, "logging")
+	}
+
+	// Truncation wraps the exporters directly, so it runs after all the processors
+	// adding or changing attributes.
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.Truncation != nil {
+		truncationCfg := multiProcessorCfg.Global.Truncation
+		logger.Info(
+			"Truncating long span values",
+			zap.Int("max-attribute-value-bytes", truncationCfg.MaxAttributeValueBytes),
+			zap.Int("max-annotation-message-bytes", truncationCfg.MaxAnnotationMessageBytes),
+		)
+		var err error
+		tp, err = truncatorprocessor.NewTraceProcessor(tp, *truncationCfg)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the truncator processor: %v", err)
+		}
+		tp = traced(tp, selftracing.ProcessSpanName, "truncator")
+	}
+
+	// The repeated annotations are dropped before the remaining ones are truncated.
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.DeduplicateAnnotations {
+		logger.Info("Dropping the repeated annotations of the spans")
+		var err error
+		tp, err = annotationdeduplicatorprocessor.NewTraceProcessor(tp)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the annotation deduplicator processor: %v", err)
+		}
+		tp = traced(tp, selftracing.ProcessSpanName, "annotation-deduplicator")
+	}
+
 	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.Attributes != nil {
 		logger.Info(
 			"Found global attributes config",
 			zap.Bool("overwrite", multiProcessorCfg.Global.Attributes.Overwrite),
 			zap.Any("values", multiProcessorCfg.Global.Attributes.Values),
 			zap.Any("key-mapping", multiProcessorCfg.Global.Attributes.KeyReplacements),
+			zap.Int("redaction-rules", len(multiProcessorCfg.Global.Attributes.Redactions)),
+			zap.Any("filter", multiProcessorCfg.Global.Attributes.Filter),
 		)
 
 		if len(multiProcessorCfg.Global.Attributes.Values) > 0 {
@@ -332,31 +529,133 @@ func startProcessor(v *viper.Viper, logger *zap.Logger) (consumer.TraceConsumer,
 				addattributesprocessor.WithAttributes(multiProcessorCfg.Global.Attributes.Values),
 				addattributesprocessor.WithOverwrite(multiProcessorCfg.Global.Attributes.Overwrite),
 			)
+			tp = traced(tp, selftracing.ProcessSpanName, "add-attributes")
+		}
+		if multiProcessorCfg.Global.Attributes.Filter != nil {
+			var err error
+			tp, err = attributefilterprocessor.NewTraceProcessor(tp, *multiProcessorCfg.Global.Attributes.Filter)
+			if err != nil {
+				return nil, closeFns, fmt.Errorf("failed to create the attribute filter processor: %v", err)
+			}
+			tp = traced(tp, selftracing.ProcessSpanName, "attribute-filter")
+		}
+		if len(multiProcessorCfg.Global.Attributes.Redactions) > 0 {
+			var err error
+			tp, err = attributeredactionprocessor.NewTraceProcessor(tp, multiProcessorCfg.Global.Attributes.Redactions...)
+			if err != nil {
+				return nil, closeFns, fmt.Errorf("failed to create the attribute redaction processor: %v", err)
+			}
+			tp = traced(tp, selftracing.ProcessSpanName, "attribute-redaction")
 		}
 		if len(multiProcessorCfg.Global.Attributes.KeyReplacements) > 0 {
 			tp, _ = attributekeyprocessor.NewTraceProcessor(tp, multiProcessorCfg.Global.Attributes.KeyReplacements...)
+			tp = traced(tp, selftracing.ProcessSpanName, "attribute-key")
+		}
+	}
+
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.K8sMetadata != nil {
+		k8sMetadataCfg := multiProcessorCfg.Global.K8sMetadata
+		opts := []k8senricherprocessor.Option{}
+		if k8sMetadataCfg.MetadataDir != "" {
+			opts = append(opts, k8senricherprocessor.WithMetadataDir(k8sMetadataCfg.MetadataDir))
+		}
+		if k8sMetadataCfg.RefreshInterval > 0 {
+			opts = append(opts, k8senricherprocessor.WithRefreshInterval(k8sMetadataCfg.RefreshInterval))
+		}
+		var err error
+		tp, err = k8senricherprocessor.NewTraceProcessor(tp, opts...)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the k8s metadata processor: %v", err)
 		}
+		tp = traced(tp, selftracing.ProcessSpanName, "k8s-metadata")
+		logger.Info("Adding the k8s metadata of the pod to all spans")
+	}
+
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.RateLimit != nil {
+		rateLimitCfg := multiProcessorCfg.Global.RateLimit
+		logger.Info(
+			"Rate limiting the spans sent to the exporters",
+			zap.Float64("spans-per-second", rateLimitCfg.SpansPerSecond),
+			zap.Int("burst", rateLimitCfg.Burst),
+		)
+		var err error
+		tp, err = ratelimiterprocessor.NewTraceProcessor(
+			tp,
+			rateLimitCfg.SpansPerSecond,
+			rateLimitCfg.Burst,
+			ratelimiterprocessor.WithLogger(logger, 0),
+		)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the rate limiter processor: %v", err)
+		}
+		tp = traced(tp, selftracing.ProcessSpanName, "rate-limiter")
+	}
+
+	// Duplicates are dropped before taking part of the rate limit.
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.Deduplication != nil {
+		deduplicationCfg := multiProcessorCfg.Global.Deduplication
+		logger.Info(
+			"Dropping duplicate spans",
+			zap.Int("cache-size", deduplicationCfg.CacheSize),
+			zap.Duration("ttl", deduplicationCfg.TTL),
+		)
+		var err error
+		tp, err = deduplicatorprocessor.NewTraceProcessor(tp, deduplicationCfg.CacheSize, deduplicationCfg.TTL)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the deduplicator processor: %v", err)
+		}
+		tp = traced(tp, selftracing.ProcessSpanName, "deduplicator")
+	}
+
+	// The spans too large are rejected before any other processing.
+	if multiProcessorCfg.Global != nil && multiProcessorCfg.Global.AdmissionControl != nil {
+		admissionControlCfg := multiProcessorCfg.Global.AdmissionControl
+		logger.Info(
+			"Rejecting the spans too large",
+			zap.Int("max-span-bytes", admissionControlCfg.MaxSpanBytes),
+		)
+		var err error
+		tp, err = admissioncontrolprocessor.NewTraceProcessor(
+			tp,
+			admissionControlCfg.MaxSpanBytes,
+			admissioncontrolprocessor.WithLogger(logger),
+		)
+		if err != nil {
+			return nil, closeFns, fmt.Errorf("failed to create the admission control processor: %v", err)
+		}
+		tp = traced(tp, selftracing.ProcessSpanName, "admission-control")
 	}
 
 	if useHeadSamplingProcessor {
 		vTraceSampler := v.Sub("sampling.policies.probabilistic.configuration")
 		if vTraceSampler == nil {
-			logger.Error("Trace head-based sampling mode is enabled but there is no valid policy section defined")
-			os.Exit(1)
+			return nil, closeFns, errors.New("trace head-based sampling mode is enabled but there is no valid policy section defined")
 		}
 
 		cfg := &tracesamplerprocessor.TraceSamplerCfg{}
 		samplerCfg, err := cfg.InitFromViper(vTraceSampler)
 		if err != nil {
-			logger.Error("Trace head-based sampling configuration error", zap.Error(err))
-			os.Exit(1)
+			return nil, closeFns, fmt.Errorf("trace head-based sampling configuration error: %v", err)
 		}
 		logger.Info(
 			"Trace head-sampling enabled",
 			zap.Float32("sampling-percentage", samplerCfg.SamplingPercentage),
 		)
 		tp, _ = tracesamplerprocessor.NewTraceProcessor(tp, *samplerCfg)
+		tp = traced(tp, selftracing.ProcessSpanName, "head-sampling")
 	}
 
-	return tp, closeFns
+	return traced(tp, selftracing.ReceiveSpanName, ""), closeFns, nil
+}
+
+// selfTracer returns the function wrapping a step of the pipeline in the
+// internal spans of the collector if self-tracing is enabled, and returning
+// the step as is otherwise.
+func selfTracer(v *viper.Viper) func(tc consumer.TraceConsumer, spanName, step string) processor.TraceProcessor {
+	if !builder.SelfTracingEnabled(v) {
+		return func(tc consumer.TraceConsumer, spanName, step string) processor.TraceProcessor {
+			return tc
+		}
+	}
+	return selftracing.NewTraceProcessor
 }
@@ -15,12 +15,19 @@
 package collector
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
 	"go.uber.org/zap"
 
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/selftracing"
 	"github.com/census-instrumentation/opencensus-service/processor/addattributesprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/attributekeyprocessor"
 	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
@@ -123,3 +130,88 @@ func Test_startProcessor(t *testing.T) {
 		})
 	}
 }
+
+func Test_buildProcessorErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		setupViperCfg func() *viper.Viper
+	}{
+		{
+			name: "nothing_enabled",
+			setupViperCfg: func() *viper.Viper {
+				return viper.New()
+			},
+		},
+		{
+			name: "head_sampling_without_policy",
+			setupViperCfg: func() *viper.Viper {
+				v := viper.New()
+				v.Set("logging-exporter", true)
+				v.Set("sampling.mode", "head")
+				return v
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer, closeFns, err := buildProcessor(tt.setupViperCfg(), zap.NewNop())
+			if err == nil || consumer != nil {
+				t.Errorf("buildProcessor() = (%v, %v), want an error", consumer, err)
+			}
+			for _, closeFn := range closeFns {
+				closeFn()
+			}
+		})
+	}
+}
+
+func Test_buildProcessorSelfTracing(t *testing.T) {
+	v := viper.New()
+	v.Set("self-tracing", true)
+	v.Set("logging-exporter", true)
+	v.Set("global.deduplicate-annotations", true)
+
+	internalExporter := exportertest.NewSpanDataExporterT(t)
+	disable := selftracing.Enable(internalExporter)
+	defer disable()
+
+	tp, closeFns, err := buildProcessor(v, zap.NewNop())
+	for _, closeFn := range closeFns {
+		defer closeFn()
+	}
+	if err != nil {
+		t.Fatalf("buildProcessor() error = %v", err)
+	}
+
+	td := data.TraceData{Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "user-span"}}}}
+	if err := tp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	spans, err := internalExporter.WaitFor(3, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to get the internal spans: %v", err)
+	}
+	if len(spans) != 3 {
+		t.Fatalf("Got %d internal spans, want 3: %v", len(spans), spans)
+	}
+	byName := make(map[string]*trace.SpanData)
+	for _, sd := range spans {
+		byName[sd.Name] = sd
+	}
+	receive := byName[selftracing.ReceiveSpanName]
+	process := byName[selftracing.ProcessSpanName]
+	export := byName[selftracing.ExportSpanName]
+	if receive == nil || process == nil || export == nil {
+		t.Fatalf("Got internal spans %v, want the pipeline, processor and exporter ones", spans)
+	}
+	if got := process.Attributes["step"]; got != "annotation-deduplicator" {
+		t.Errorf("Got processor step %v, want annotation-deduplicator", got)
+	}
+	if got := export.Attributes["step"]; got != "logging" {
+		t.Errorf("Got exporter step %v, want logging", got)
+	}
+	if process.ParentSpanID != receive.SpanID || export.ParentSpanID != process.SpanID {
+		t.Errorf("The internal spans are not nested as pipeline > processor > exporter: %v", spans)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	jaegerreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/jaeger"
 	ocreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/opencensus"
+	otlphttpreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/otlphttp"
 	zipkinreceiver "github.com/census-instrumentation/opencensus-service/internal/collector/zipkin"
 	zipkinscribereceiver "github.com/census-instrumentation/opencensus-service/internal/collector/zipkin/scribe"
 	"github.com/census-instrumentation/opencensus-service/receiver"
@@ -39,6 +40,7 @@ func createReceivers(v *viper.Viper, logger *zap.Logger, traceConsumers consumer
 	}{
 		{jaegerreceiver.Start, builder.JaegerReceiverEnabled(v), "Jaeger"},
 		{ocreceiver.Start, builder.OpenCensusReceiverEnabled(v), "OpenCensus"},
+		{otlphttpreceiver.Start, builder.OTLPHTTPReceiverEnabled(v), "OTLP/HTTP"},
 		{zipkinreceiver.Start, builder.ZipkinReceiverEnabled(v), "Zipkin"},
 		{zipkinscribereceiver.Start, builder.ZipkinScribeReceiverEnabled(v), "Zipkin-Scribe"},
 	}
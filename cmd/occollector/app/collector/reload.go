@@ -0,0 +1,96 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"log"
+
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/processor/swapprocessor"
+)
+
+// setupProcessor builds the exporters and processors. If requested they are
+// rebuilt, and swapped under the receivers, every time the config file changes.
+func (app *Application) setupProcessor() {
+	tp, closeFns := startProcessor(app.v, app.logger)
+	if !builder.ConfigReloadEnabled(app.v) {
+		app.processor, app.closeFns = tp, closeFns
+		return
+	}
+	file := builder.GetConfigFile(app.v)
+	if file == "" {
+		app.logger.Warn("Configuration reload requested without a config file, it is disabled")
+		app.processor, app.closeFns = tp, closeFns
+		return
+	}
+
+	swapProcessor, _ := swapprocessor.NewTraceProcessor(tp)
+	app.processor = swapProcessor
+	app.processorCloseFns = closeFns
+	watcher, err := config.NewWatcher(file, app.logger, func() error {
+		return app.reloadProcessor(swapProcessor)
+	})
+	if err != nil {
+		log.Fatalf("Failed to watch the config file %q: %v", file, err)
+	}
+	app.closeFns = []func(){func() {
+		// Stop the reloads before closing the current exporters.
+		watcher.Close()
+		closeAll(app.processorCloseFns)
+	}}
+	app.logger.Info("Reloading the exporters and processors when the config file changes", zap.String("file", file))
+}
+
+// reloadProcessor rebuilds the exporters and processors from the config file
+// and swaps them with the current ones, that are closed after the spans they
+// are processing are done. The current ones are kept if the new configuration
+// is invalid. The receivers are not rebuilt.
+func (app *Application) reloadProcessor(swapProcessor swapprocessor.TraceProcessor) error {
+	if err := app.v.ReadInConfig(); err != nil {
+		return err
+	}
+	tp, closeFns, err := buildProcessor(app.v, app.logger)
+	if err != nil {
+		closeAll(closeFns)
+		return err
+	}
+
+	gracePeriod := builder.ConfigReloadGracePeriod(app.v)
+	drained, err := swapProcessor.Swap(tp, gracePeriod)
+	if err != nil {
+		closeAll(closeFns)
+		return err
+	}
+	if !drained {
+		app.logger.Warn("Closing the previous exporters while spans are still being processed",
+			zap.Duration("grace-period", gracePeriod))
+	}
+	// Reloads are sequential, only the watcher calls this function.
+	previousCloseFns := app.processorCloseFns
+	app.processorCloseFns = closeFns
+	closeAll(previousCloseFns)
+
+	app.logger.Info("Exporters and processors reloaded")
+	return nil
+}
+
+func closeAll(closeFns []func()) {
+	for _, closeFn := range closeFns {
+		closeFn()
+	}
+}
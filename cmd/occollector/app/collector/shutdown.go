@@ -0,0 +1,91 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+var errShuttingDown = errors.New("the collector is shutting down")
+
+// inFlightProcessor passes the spans to the next consumer and keeps track of
+// the calls still in progress, so that they can be waited for on shutdown.
+type inFlightProcessor struct {
+	nextConsumer consumer.TraceConsumer
+
+	mu       sync.Mutex
+	draining bool
+	inFlight sync.WaitGroup
+}
+
+var _ consumer.TraceConsumer = (*inFlightProcessor)(nil)
+
+func newInFlightProcessor(nextConsumer consumer.TraceConsumer) *inFlightProcessor {
+	return &inFlightProcessor{nextConsumer: nextConsumer}
+}
+
+func (ifp *inFlightProcessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	ifp.mu.Lock()
+	if ifp.draining {
+		ifp.mu.Unlock()
+		return errShuttingDown
+	}
+	ifp.inFlight.Add(1)
+	ifp.mu.Unlock()
+	defer ifp.inFlight.Done()
+
+	return ifp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+// drain rejects the spans from now on and waits for the calls in progress to
+// be done, or for ctx to be done in which case its error is returned.
+func (ifp *inFlightProcessor) drain(ctx context.Context) error {
+	ifp.mu.Lock()
+	ifp.draining = true
+	ifp.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ifp.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops the receivers from accepting new spans, waits for the spans
+// being processed to be passed to the exporters, for as long as ctx allows,
+// and then closes the exporters and all the other components. The exporters
+// are closed even if ctx is done first, its error is then returned.
+func (app *Application) Shutdown(ctx context.Context) error {
+	app.shutdownReceivers()
+
+	var err error
+	if app.inFlight != nil {
+		err = app.inFlight.drain(ctx)
+	}
+
+	app.shutdownClosableComponents()
+	return err
+}
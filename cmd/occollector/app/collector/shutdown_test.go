@@ -0,0 +1,157 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+// fakeReceiver sends spans to the pipeline until it is stopped.
+type fakeReceiver struct {
+	next    consumer.TraceConsumer
+	mu      sync.Mutex
+	stopped bool
+}
+
+var _ receiver.TraceReceiver = (*fakeReceiver)(nil)
+
+func (fr *fakeReceiver) TraceSource() string { return "fake" }
+
+func (fr *fakeReceiver) StartTraceReception(ctx context.Context, asyncErrorChannel chan<- error) error {
+	return nil
+}
+
+func (fr *fakeReceiver) StopTraceReception(ctx context.Context) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.stopped = true
+	return nil
+}
+
+func (fr *fakeReceiver) isStopped() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.stopped
+}
+
+// blockingExporter blocks every export until it is released.
+type blockingExporter struct {
+	sink     exportertest.SinkTraceExporter
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (be *blockingExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	select {
+	case be.started <- struct{}{}:
+	default:
+	}
+	<-be.released
+	return be.sink.ConsumeTraceData(ctx, td)
+}
+
+func newShutdownTestApp(next consumer.TraceConsumer) (*Application, *fakeReceiver, *bool) {
+	app := newApp()
+	app.inFlight = newInFlightProcessor(next)
+	app.processor = app.inFlight
+	fr := &fakeReceiver{next: app.processor}
+	app.receivers = []receiver.TraceReceiver{fr}
+	closed := new(bool)
+	app.closeFns = []func(){func() { *closed = true }}
+	return app, fr, closed
+}
+
+func TestApplication_ShutdownDrainsInFlightSpans(t *testing.T) {
+	exp := &blockingExporter{started: make(chan struct{}, 1), released: make(chan struct{})}
+	app, fr, closed := newShutdownTestApp(exp)
+
+	const numBatches = 5
+	var wg sync.WaitGroup
+	for i := 0; i < numBatches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			td := data.TraceData{Spans: []*tracepb.Span{{}, {}}}
+			if err := fr.next.ConsumeTraceData(context.Background(), td); err != nil {
+				t.Errorf("ConsumeTraceData() error = %v", err)
+			}
+		}()
+	}
+	// Shutdown while the exports are in progress.
+	<-exp.started
+	time.Sleep(10 * time.Millisecond)
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- app.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("Shutdown() = %v returned before the in-flight spans were exported", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if !fr.isStopped() {
+		t.Error("The receivers were not stopped before draining the pipeline")
+	}
+	if *closed {
+		t.Error("The exporters were closed before the pipeline was drained")
+	}
+
+	close(exp.released)
+	if err := <-shutdownDone; err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	wg.Wait()
+
+	if !*closed {
+		t.Error("The exporters were not closed")
+	}
+	if got, want := len(exp.sink.AllTraces()), numBatches; got != want {
+		t.Errorf("Got %d batches exported, want %d", got, want)
+	}
+
+	if err := app.processor.ConsumeTraceData(context.Background(), data.TraceData{}); err != errShuttingDown {
+		t.Errorf("ConsumeTraceData() after Shutdown() = %v, want %v", err, errShuttingDown)
+	}
+}
+
+func TestApplication_ShutdownDrainTimeout(t *testing.T) {
+	exp := &blockingExporter{started: make(chan struct{}, 1), released: make(chan struct{})}
+	defer close(exp.released)
+	app, fr, closed := newShutdownTestApp(exp)
+
+	go fr.next.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{{}}})
+	<-exp.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !*closed {
+		t.Error("The exporters must be closed once the drain timeout expires")
+	}
+}
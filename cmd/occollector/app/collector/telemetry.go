@@ -25,12 +25,21 @@ import (
 	"go.opencensus.io/stats/view"
 	"go.uber.org/zap"
 
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/nodebatcher"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/queued"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/processor/tailsampling"
 	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
 	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/processor/admissioncontrolprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/annotationdeduplicatorprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/circuitbreakerprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/deduplicatorprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
+	"github.com/census-instrumentation/opencensus-service/processor/processorchain"
+	"github.com/census-instrumentation/opencensus-service/processor/ratelimiterprocessor"
+	"github.com/census-instrumentation/opencensus-service/processor/truncatorprocessor"
 )
 
 const (
@@ -70,6 +79,15 @@ func (tel *appTelemetry) init(asyncErrorChannel chan<- error, v *viper.Viper, lo
 	views = append(views, nodebatcher.MetricViews(level)...)
 	views = append(views, observability.AllViews...)
 	views = append(views, tailsampling.SamplingProcessorMetricViews(level)...)
+	views = append(views, multiconsumer.MetricViews(level)...)
+	views = append(views, ratelimiterprocessor.MetricViews(level)...)
+	views = append(views, deduplicatorprocessor.MetricViews(level)...)
+	views = append(views, circuitbreakerprocessor.MetricViews(level)...)
+	views = append(views, exporterhelper.MetricViews(level)...)
+	views = append(views, truncatorprocessor.MetricViews(level)...)
+	views = append(views, admissioncontrolprocessor.MetricViews(level)...)
+	views = append(views, annotationdeduplicatorprocessor.MetricViews(level)...)
+	views = append(views, processorchain.MetricViews(level)...)
 	processMetricsViews := telemetry.NewProcessMetricsViews()
 	views = append(views, processMetricsViews.Views()...)
 	tel.views = views
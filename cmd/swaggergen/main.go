@@ -0,0 +1,134 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Program swaggergen generates the OpenAPI specification of the HTTP
+// endpoints of the receivers, from the openapi annotations of their handlers.
+//
+//	go run ./cmd/swaggergen -o openapi.yaml
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+
+	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+
+	"github.com/census-instrumentation/opencensus-service/internal/openapi"
+	"github.com/census-instrumentation/opencensus-service/internal/version"
+	"github.com/census-instrumentation/opencensus-service/translator/trace/otlp"
+)
+
+// receiverDirs are the directories, relative to the root of the repository,
+// of the receivers serving HTTP.
+var receiverDirs = []string{
+	"receiver/jaegerreceiver",
+	"receiver/opencensusreceiver",
+	"receiver/otlphttpreceiver",
+	"receiver/zipkinreceiver",
+}
+
+// gatewayError is the body of the errors returned by the grpc-gateway of the
+// OpenCensus receiver, as encoded by its default error handler.
+type gatewayError struct {
+	Error   string        `json:"error"`
+	Code    int32         `json:"code"`
+	Message string        `json:"message"`
+	Details []interface{} `json:"details"`
+}
+
+var builder = &openapi.Builder{
+	Info: openapi.Info{
+		Title:       "OpenCensus Service receivers",
+		Description: "The HTTP endpoints of the receivers of the OpenCensus Agent and Collector.",
+		Version:     version.Version,
+	},
+	Types: map[string]reflect.Type{
+		"agenttracepb.ExportTraceServiceRequest":      reflect.TypeOf(agenttracepb.ExportTraceServiceRequest{}),
+		"agenttracepb.ExportTraceServiceResponse":     reflect.TypeOf(agenttracepb.ExportTraceServiceResponse{}),
+		"agentmetricspb.ExportMetricsServiceRequest":  reflect.TypeOf(agentmetricspb.ExportMetricsServiceRequest{}),
+		"agentmetricspb.ExportMetricsServiceResponse": reflect.TypeOf(agentmetricspb.ExportMetricsServiceResponse{}),
+		"gateway.Error":      reflect.TypeOf(gatewayError{}),
+		"otlp.ExportRequest": reflect.TypeOf(otlp.ExportRequest{}),
+		"zipkin.SpanModels":  reflect.TypeOf([]*zipkinmodel.SpanModel{}),
+	},
+	Overrides: map[reflect.Type]*openapi.Schema{
+		// The well-known protobuf types have their own JSON encodings.
+		reflect.TypeOf(timestamp.Timestamp{}):  {Type: "string", Format: "date-time"},
+		reflect.TypeOf(duration.Duration{}):    {Type: "string", Description: "A duration in seconds with the s suffix, e.g. 1.5s."},
+		reflect.TypeOf(wrappers.BoolValue{}):   {Type: "boolean"},
+		reflect.TypeOf(wrappers.DoubleValue{}): {Type: "number", Format: "double"},
+		reflect.TypeOf(wrappers.Int64Value{}):  {Type: "string", Format: "int64"},
+		reflect.TypeOf(wrappers.UInt32Value{}): {Type: "integer", Format: "int64"},
+		// The OTLP/JSON types decoding several encodings.
+		reflect.TypeOf(otlp.HexBytes(nil)): {Type: "string", Description: "Hex encoded bytes."},
+		reflect.TypeOf(otlp.Uint64(0)):     {Type: "string", Format: "uint64", Description: "Also accepted as a number."},
+		reflect.TypeOf(otlp.Int64(0)):      {Type: "string", Format: "int64", Description: "Also accepted as a number."},
+		reflect.TypeOf(otlp.SpanKind(0)):   {Type: "integer", Description: "The number or name of the span kind."},
+		reflect.TypeOf(otlp.StatusCode(0)): {Type: "integer", Description: "The number or name of the status code."},
+		reflect.TypeOf(zipkinmodel.SpanModel{}): {
+			Type:        "object",
+			Description: "A Zipkin v2 span, see https://zipkin.io/zipkin-api/.",
+		},
+	},
+	SecuritySchemes: map[string]*openapi.SecurityScheme{
+		"apiKey": {
+			Type: "apiKey",
+			In:   "header",
+			// The gateway forwards the Grpc-Metadata- headers as the
+			// metadata checked by the API key interceptor.
+			Name:        "Grpc-Metadata-X-Api-Key",
+			Description: "Required when the receiver is configured with api-keys.",
+		},
+	},
+}
+
+func main() {
+	root := flag.String("root", ".", "Root directory of the repository")
+	out := flag.String("o", "openapi.yaml", "File written with the specification")
+	flag.Parse()
+
+	spec, err := generate(*root)
+	if err != nil {
+		log.Fatalf("Failed to generate the OpenAPI specification: %v", err)
+	}
+	if err := ioutil.WriteFile(*out, spec, 0644); err != nil {
+		log.Fatalf("Failed to write the OpenAPI specification: %v", err)
+	}
+}
+
+// generate returns the YAML OpenAPI specification of the receivers of the
+// repository at root.
+func generate(root string) ([]byte, error) {
+	var ops []*openapi.Operation
+	for _, dir := range receiverDirs {
+		dirOps, err := openapi.ParseDir(filepath.Join(root, dir))
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, dirOps...)
+	}
+	doc, err := builder.Build(ops)
+	if err != nil {
+		return nil, err
+	}
+	return doc.YAML()
+}
@@ -0,0 +1,83 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGenerate(t *testing.T) {
+	out, err := generate("../..")
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	var spec struct {
+		OpenAPI    string                                       `yaml:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `yaml:"paths"`
+		Components struct {
+			Schemas         map[string]interface{} `yaml:"schemas"`
+			SecuritySchemes map[string]interface{} `yaml:"securitySchemes"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(out, &spec); err != nil {
+		t.Fatalf("The generated spec isn't valid YAML: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.0.") {
+		t.Errorf("openapi = %q, want a 3.0 version", spec.OpenAPI)
+	}
+
+	wantOperations := map[string]string{
+		"/v1/trace":     "exportOpenCensusTraces",
+		"/v1/metrics":   "exportOpenCensusMetrics",
+		"/v1/traces":    "exportOTLPTraces",
+		"/api/v1/spans": "exportZipkinV1Spans",
+		"/api/v2/spans": "exportZipkinV2Spans",
+		"/api/traces":   "exportJaegerTraces",
+	}
+	if len(spec.Paths) != len(wantOperations) {
+		t.Errorf("Got %d paths, want %d", len(spec.Paths), len(wantOperations))
+	}
+	for path, id := range wantOperations {
+		op := spec.Paths[path]["post"]
+		if op == nil {
+			t.Errorf("POST %s is missing", path)
+			continue
+		}
+		if op["operationId"] != id {
+			t.Errorf("POST %s operationId = %v, want %q", path, op["operationId"], id)
+		}
+		for _, field := range []string{"requestBody", "responses"} {
+			if op[field] == nil {
+				t.Errorf("POST %s has no %s", path, field)
+			}
+		}
+	}
+	for _, name := range []string{
+		"agenttracepb.ExportTraceServiceRequest",
+		"agentmetricspb.ExportMetricsServiceRequest",
+		"otlp.ExportRequest",
+		"gateway.Error",
+	} {
+		if spec.Components.Schemas[name] == nil {
+			t.Errorf("The schema %s is missing", name)
+		}
+	}
+	if spec.Components.SecuritySchemes["apiKey"] == nil {
+		t.Error("The apiKey security scheme is missing")
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package appdynamicsexporter publishes the received spans to the Events API
+// of AppDynamics Analytics, as events shaped like the business transactions
+// of the AppDynamics agents.
+package appdynamicsexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultEventsURL = "https://analytics.api.appdynamics.com"
+	defaultSchema    = "ocservice_spans"
+	defaultBatchSize = 1000
+
+	// eventsContentType is the content type of the requests of version 2 of
+	// the Events API.
+	eventsContentType = "application/vnd.appd.events+json;v=2"
+)
+
+// The environment variables of the AppDynamics agents the blank settings are
+// read from.
+const (
+	accountNameEnvVar     = "APPDYNAMICS_AGENT_ACCOUNT_NAME"
+	applicationNameEnvVar = "APPDYNAMICS_AGENT_APPLICATION_NAME"
+	tierNameEnvVar        = "APPDYNAMICS_AGENT_TIER_NAME"
+	nodeNameEnvVar        = "APPDYNAMICS_AGENT_NODE_NAME"
+)
+
+type appDynamicsConfig struct {
+	// EventsURL is the URL of the Events Service,
+	// https://analytics.api.appdynamics.com by default.
+	EventsURL string `mapstructure:"events_url"`
+
+	// AccountName is the global account name of the controller, from the
+	// APPDYNAMICS_AGENT_ACCOUNT_NAME environment variable if blank.
+	AccountName string `mapstructure:"account_name"`
+
+	// APIKey is the key of the Events API.
+	APIKey string `mapstructure:"api_key"`
+
+	// ApplicationName is the application of the events, from the
+	// APPDYNAMICS_AGENT_APPLICATION_NAME environment variable if blank.
+	ApplicationName string `mapstructure:"application_name"`
+
+	// TierName and NodeName are the tier and the node of the events, from
+	// the APPDYNAMICS_AGENT_TIER_NAME and APPDYNAMICS_AGENT_NODE_NAME
+	// environment variables if blank. They default to the service name and
+	// the host name of the node the spans were received from.
+	TierName string `mapstructure:"tier_name"`
+	NodeName string `mapstructure:"node_name"`
+
+	// Schema is the schema of the events, ocservice_spans by default.
+	Schema string `mapstructure:"schema"`
+
+	// SkipSchemaCreation disables the creation of the schema on startup.
+	SkipSchemaCreation bool `mapstructure:"skip_schema_creation"`
+
+	// BatchSize is the maximum number of events of each request, 1000 by
+	// default.
+	BatchSize int `mapstructure:"batch_size"`
+
+	// Timeout of each request, 10s by default.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+var (
+	errMissingAPIKey      = errors.New("expecting a non-blank api_key for the appdynamics exporter")
+	errMissingAccountName = fmt.Errorf("expecting a non-blank account_name or %s for the appdynamics exporter", accountNameEnvVar)
+	errMissingApplication = fmt.Errorf("expecting a non-blank application_name or %s for the appdynamics exporter", applicationNameEnvVar)
+
+	// schemaNameRegexp matches the schema names accepted by the Events API.
+	schemaNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_]{1,40}$`)
+)
+
+// AppDynamicsExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// publishing the spans to AppDynamics according to the configuration settings.
+func AppDynamicsExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		AppDynamics *appDynamicsConfig `mapstructure:"appdynamics"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	ac := cfg.AppDynamics
+	if ac == nil {
+		return nil, nil, nil, nil
+	}
+
+	ae, err := newAppDynamicsExporter(ac)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure appdynamics exporter: %v", err)
+	}
+	if !ac.SkipSchemaCreation {
+		ctx, cancel := context.WithTimeout(context.Background(), ae.client.Timeout)
+		defer cancel()
+		if err := ae.createSchema(ctx); err != nil {
+			return nil, nil, nil, fmt.Errorf("Cannot create the schema of the appdynamics exporter: %v", err)
+		}
+	}
+
+	ate, err := exporterhelper.NewTraceExporter(
+		"appdynamics",
+		ae.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.AppDynamics.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, ate)
+	return
+}
+
+// appDynamicsExporter publishes an event per span, at most batchSize of them
+// per request.
+type appDynamicsExporter struct {
+	eventsURL   string
+	accountName string
+	apiKey      string
+	application string
+	tier        string
+	node        string
+	schema      string
+	batchSize   int
+	client      *http.Client
+}
+
+func newAppDynamicsExporter(ac *appDynamicsConfig) (*appDynamicsExporter, error) {
+	if ac.APIKey == "" {
+		return nil, errMissingAPIKey
+	}
+	accountName := settingOrEnv(ac.AccountName, accountNameEnvVar)
+	if accountName == "" {
+		return nil, errMissingAccountName
+	}
+	application := settingOrEnv(ac.ApplicationName, applicationNameEnvVar)
+	if application == "" {
+		return nil, errMissingApplication
+	}
+
+	eventsURL := ac.EventsURL
+	if eventsURL == "" {
+		eventsURL = defaultEventsURL
+	}
+	u, err := url.Parse(eventsURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the appdynamics events_url %q", u.Scheme, eventsURL)
+	}
+	schema := ac.Schema
+	if schema == "" {
+		schema = defaultSchema
+	}
+	if !schemaNameRegexp.MatchString(schema) {
+		return nil, fmt.Errorf("invalid schema %q, expecting up to 40 letters, digits and underscores", schema)
+	}
+
+	batchSize := ac.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &appDynamicsExporter{
+		eventsURL:   strings.TrimSuffix(eventsURL, "/"),
+		accountName: accountName,
+		apiKey:      ac.APIKey,
+		application: application,
+		tier:        settingOrEnv(ac.TierName, tierNameEnvVar),
+		node:        settingOrEnv(ac.NodeName, nodeNameEnvVar),
+		schema:      schema,
+		batchSize:   batchSize,
+		client:      exporterhelper.NewHTTPClient(ac.Timeout),
+	}, nil
+}
+
+// settingOrEnv returns the setting, or the value of the environment variable
+// if it is blank.
+func settingOrEnv(setting, envVar string) string {
+	if setting != "" {
+		return setting
+	}
+	return os.Getenv(envVar)
+}
+
+func (ae *appDynamicsExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	dropped := 0
+	tier := ae.tier
+	if tier == "" {
+		tier = td.Node.GetServiceInfo().GetName()
+	}
+	node := ae.node
+	if node == "" {
+		node = td.Node.GetIdentifier().GetHostName()
+	}
+
+	events := make([]*btEvent, 0, len(td.Spans))
+	flush := func() {
+		if len(events) == 0 {
+			return
+		}
+		if err := ae.publish(ctx, events); err != nil {
+			errs = append(errs, err)
+			dropped += len(events)
+		}
+		events = events[:0]
+	}
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		events = append(events, spanDataToEvent(sd, ae.application, tier, node))
+		if len(events) == ae.batchSize {
+			flush()
+		}
+	}
+	flush()
+
+	return dropped, internal.CombineErrors(errs)
+}
+
+func (ae *appDynamicsExporter) publish(ctx context.Context, events []*btEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	if err := ae.post(ctx, "/events/publish/"+ae.schema, body); err != nil {
+		return fmt.Errorf("appdynamics publish failed: %v", err)
+	}
+	return nil
+}
+
+// createSchema creates the schema of the events unless it already exists.
+func (ae *appDynamicsExporter) createSchema(ctx context.Context) error {
+	body, err := json.Marshal(map[string]interface{}{"schema": btEventSchema})
+	if err != nil {
+		return err
+	}
+	err = ae.post(ctx, "/events/schema/"+ae.schema, body)
+	if statusErr, ok := err.(*exporterhelper.HTTPStatusError); ok && statusErr.StatusCode == http.StatusConflict {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("appdynamics schema creation failed: %v", err)
+	}
+	return nil
+}
+
+func (ae *appDynamicsExporter) post(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, ae.eventsURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", eventsContentType)
+	req.Header.Set("Accept", eventsContentType)
+	req.Header.Set("X-Events-API-AccountName", ae.accountName)
+	req.Header.Set("X-Events-API-Key", ae.apiKey)
+	return exporterhelper.SendHTTPRequest(ctx, ae.client, req)
+}
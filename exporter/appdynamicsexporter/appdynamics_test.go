@@ -0,0 +1,211 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appdynamicsexporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+// newFakeEventsAPI returns a fake Events API replying to the schema creations
+// with schemaStatus, and to the publications with publishStatus.
+func newFakeEventsAPI(schemaStatus, publishStatus int) *testutils.HTTPRecorder {
+	return &testutils.HTTPRecorder{Reply: func(w http.ResponseWriter, r *http.Request) {
+		status := publishStatus
+		if strings.HasPrefix(r.URL.Path, "/events/schema/") {
+			status = schemaStatus
+		}
+		if status/100 != 2 {
+			http.Error(w, `{"statusCode":401,"code":"Unauthorized","message":"invalid api key"}`, status)
+			return
+		}
+		w.WriteHeader(status)
+	}}
+}
+
+// setenv sets the environment variable and returns the function restoring it.
+func setenv(t *testing.T, key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("Failed to set %s: %v", key, err)
+	}
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestAppDynamicsExporterPublishesEvents(t *testing.T) {
+	fake := newFakeEventsAPI(http.StatusCreated, http.StatusOK)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	defer setenv(t, accountNameEnvVar, "customer1_abc")()
+	defer setenv(t, applicationNameEnvVar, "shop")()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("appdynamics:\n  events_url: " + srv.URL + "/\n  api_key: secret\n"))
+	tps, _, _, err := AppDynamicsExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+
+	td := data.TraceData{
+		Node: &commonpb.Node{
+			Identifier:  &commonpb.ProcessIdentifier{HostName: "web-1"},
+			ServiceInfo: &commonpb.ServiceInfo{Name: "frontend"},
+		},
+		Spans: []*tracepb.Span{{
+			TraceId:      []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			ParentSpanId: []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+			Name:         &tracepb.TruncatableString{Value: "/checkout"},
+			Kind:         tracepb.Span_SERVER,
+			StartTime:    &timestamp.Timestamp{Seconds: 1564617600, Nanos: 2000000},
+			EndTime:      &timestamp.Timestamp{Seconds: 1564617600, Nanos: 254000000},
+			Status:       &tracepb.Status{Code: trace.StatusCodeUnavailable, Message: "payment service down"},
+		}},
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	requests, bodies := fake.Requests(), fake.Bodies()
+	if len(requests) != 2 {
+		t.Fatalf("Got %d requests, want 2", len(requests))
+	}
+	for i, path := range []string{"/events/schema/ocservice_spans", "/events/publish/ocservice_spans"} {
+		r := requests[i]
+		if r.Method != http.MethodPost || r.URL.Path != path {
+			t.Errorf("Request #%d: got %s %s, want POST %s", i, r.Method, r.URL.Path, path)
+		}
+		for header, want := range map[string]string{
+			"Content-Type":             "application/vnd.appd.events+json;v=2",
+			"X-Events-API-AccountName": "customer1_abc",
+			"X-Events-API-Key":         "secret",
+		} {
+			if got := r.Header.Get(header); got != want {
+				t.Errorf("Request #%d: got %s %q, want %q", i, header, got, want)
+			}
+		}
+	}
+
+	var schema struct {
+		Schema map[string]string `json:"schema"`
+	}
+	if err := json.Unmarshal([]byte(bodies[0]), &schema); err != nil {
+		t.Fatalf("Failed to decode the schema: %v", err)
+	}
+	if !reflect.DeepEqual(schema.Schema, btEventSchema) {
+		t.Errorf("Got schema %v, want %v", schema.Schema, btEventSchema)
+	}
+
+	want := `[{"eventTimestamp":"2019-08-01T00:00:00.002Z","application":"shop","tier":"frontend","node":"web-1",` +
+		`"businessTransaction":"/checkout","traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0102030405060708",` +
+		`"parentSpanId":"0807060504030201","spanKind":"SERVER","responseTime":252,"userExperience":"ERROR",` +
+		`"errorSeverity":"ERROR","statusCode":14,"errorMessage":"payment service down"}]`
+	if got := bodies[1]; got != want {
+		t.Errorf("Got events\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAppDynamicsExporterExistingSchema(t *testing.T) {
+	fake := newFakeEventsAPI(http.StatusConflict, http.StatusOK)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("appdynamics:\n  events_url: " + srv.URL + "\n  api_key: secret\n" +
+		"  account_name: customer1\n  application_name: shop\n  schema: spans\n"))
+	if _, _, _, err := AppDynamicsExportersFromViper(v); err != nil {
+		t.Fatalf("Got error %v with an existing schema, want none", err)
+	}
+	if got := fake.Requests()[0].URL.Path; got != "/events/schema/spans" {
+		t.Errorf("Got schema path %q, want /events/schema/spans", got)
+	}
+}
+
+func TestAppDynamicsExporterPublishError(t *testing.T) {
+	fake := newFakeEventsAPI(0, http.StatusUnauthorized)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ae, err := newAppDynamicsExporter(&appDynamicsConfig{EventsURL: srv.URL, APIKey: "wrong", AccountName: "a", ApplicationName: "shop", BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	dropped, err := ae.pushTraceData(context.Background(), testutils.TraceDataWithSpans(3))
+	testutils.CheckPushError(t, dropped, err, 3, "status 401", "invalid api key")
+	if got := len(fake.Requests()); got != 2 {
+		t.Errorf("Got %d requests, want 2 batches", got)
+	}
+}
+
+func TestErrorSeverity(t *testing.T) {
+	tests := []struct {
+		code           int32
+		severity       string
+		userExperience string
+	}{
+		{trace.StatusCodeOK, "", "NORMAL"},
+		{trace.StatusCodeNotFound, "WARNING", "NORMAL"},
+		{trace.StatusCodeInvalidArgument, "WARNING", "NORMAL"},
+		{trace.StatusCodeUnauthenticated, "WARNING", "NORMAL"},
+		{trace.StatusCodeUnknown, "ERROR", "ERROR"},
+		{trace.StatusCodeDeadlineExceeded, "ERROR", "ERROR"},
+		{trace.StatusCodeInternal, "ERROR", "ERROR"},
+	}
+	for _, tt := range tests {
+		sd := &trace.SpanData{Status: trace.Status{Code: tt.code, Message: "message"}}
+		ev := spanDataToEvent(sd, "shop", "", "")
+		if ev.ErrorSeverity != tt.severity || ev.UserExperience != tt.userExperience {
+			t.Errorf("Code %d: got severity %q and user experience %q, want %q and %q",
+				tt.code, ev.ErrorSeverity, ev.UserExperience, tt.severity, tt.userExperience)
+		}
+		if wantMessage := tt.severity != ""; (ev.ErrorMessage != "") != wantMessage {
+			t.Errorf("Code %d: got error message %q", tt.code, ev.ErrorMessage)
+		}
+	}
+}
+
+func TestAppDynamicsExportersFromViperErrors(t *testing.T) {
+	defer setenv(t, accountNameEnvVar, "")()
+	defer setenv(t, applicationNameEnvVar, "")()
+	testutils.CheckExportersFromViperErrors(t, AppDynamicsExportersFromViper, []testutils.InvalidConfig{
+		{Name: "missing api key", Config: "appdynamics:\n  account_name: a\n  application_name: shop\n"},
+		{Name: "missing account name", Config: "appdynamics:\n  api_key: k\n  application_name: shop\n"},
+		{Name: "missing application name", Config: "appdynamics:\n  api_key: k\n  account_name: a\n"},
+		{Name: "invalid scheme", Config: "appdynamics:\n  events_url: tcp://localhost:9080\n  api_key: k\n  account_name: a\n  application_name: shop\n"},
+		{Name: "invalid schema", Config: "appdynamics:\n  api_key: k\n  account_name: a\n  application_name: shop\n  schema: oc-spans\n"},
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package appdynamicsexporter
+
+import (
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// btEvent is the event of a span, with the fields of the business transaction
+// events the AppDynamics agents publish.
+type btEvent struct {
+	// EventTimestamp is the start of the span, in ISO 8601 format with
+	// milliseconds.
+	EventTimestamp      string `json:"eventTimestamp"`
+	Application         string `json:"application"`
+	Tier                string `json:"tier,omitempty"`
+	Node                string `json:"node,omitempty"`
+	BusinessTransaction string `json:"businessTransaction"`
+	TraceID             string `json:"traceId"`
+	SpanID              string `json:"spanId"`
+	ParentSpanID        string `json:"parentSpanId,omitempty"`
+	SpanKind            string `json:"spanKind"`
+	// ResponseTime is the duration of the span in milliseconds.
+	ResponseTime   int64  `json:"responseTime"`
+	UserExperience string `json:"userExperience"`
+	ErrorSeverity  string `json:"errorSeverity,omitempty"`
+	StatusCode     int32  `json:"statusCode"`
+	ErrorMessage   string `json:"errorMessage,omitempty"`
+}
+
+// btEventSchema is the schema of btEvent, eventTimestamp being implicit.
+var btEventSchema = map[string]string{
+	"application":         "string",
+	"tier":                "string",
+	"node":                "string",
+	"businessTransaction": "string",
+	"traceId":             "string",
+	"spanId":              "string",
+	"parentSpanId":        "string",
+	"spanKind":            "string",
+	"responseTime":        "integer",
+	"userExperience":      "string",
+	"errorSeverity":       "string",
+	"statusCode":          "integer",
+	"errorMessage":        "string",
+}
+
+// The error severities of the AppDynamics SDKs.
+const (
+	severityWarning = "WARNING"
+	severityError   = "ERROR"
+)
+
+// errorSeverity maps the status of a span to the severity of its error, blank
+// if the status is OK. The statuses blaming the caller are warnings, the
+// others errors.
+func errorSeverity(code int32) string {
+	switch code {
+	case trace.StatusCodeOK:
+		return ""
+	case trace.StatusCodeCancelled,
+		trace.StatusCodeInvalidArgument,
+		trace.StatusCodeNotFound,
+		trace.StatusCodeAlreadyExists,
+		trace.StatusCodePermissionDenied,
+		trace.StatusCodeFailedPrecondition,
+		trace.StatusCodeOutOfRange,
+		trace.StatusCodeUnauthenticated:
+		return severityWarning
+	}
+	return severityError
+}
+
+func spanDataToEvent(sd *trace.SpanData, application, tier, node string) *btEvent {
+	ev := &btEvent{
+		EventTimestamp:      sd.StartTime.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Application:         application,
+		Tier:                tier,
+		Node:                node,
+		BusinessTransaction: sd.Name,
+		TraceID:             sd.TraceID.String(),
+		SpanID:              sd.SpanID.String(),
+		SpanKind:            spanKind(sd.SpanKind),
+		ResponseTime:        int64(sd.EndTime.Sub(sd.StartTime) / time.Millisecond),
+		UserExperience:      "NORMAL",
+		ErrorSeverity:       errorSeverity(sd.Code),
+		StatusCode:          sd.Code,
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		ev.ParentSpanID = sd.ParentSpanID.String()
+	}
+	if ev.ErrorSeverity != "" {
+		ev.ErrorMessage = sd.Message
+	}
+	if ev.ErrorSeverity == severityError {
+		ev.UserExperience = "ERROR"
+	}
+	return ev
+}
+
+func spanKind(kind int) string {
+	switch kind {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	}
+	return "UNSPECIFIED"
+}
@@ -25,6 +25,7 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
@@ -44,6 +45,18 @@ type awsXRayConfig struct {
 	// DestinationRegion is an optional field that if set defines
 	// the region to which the X-Ray payloads will be sent.
 	DestinationRegion string `mapstructure:"destination_region"`
+
+	// DaemonAddress is an optional field that if set, sends the spans as
+	// segment documents to the X-Ray daemon listening on UDP at this address,
+	// e.g. "127.0.0.1:2000", instead of calling the X-Ray API. The regexes,
+	// buffer and region options are then ignored.
+	DaemonAddress string `mapstructure:"daemon_address"`
+}
+
+// spanFlusher is an exporter of the spans of a service to AWS X-Ray.
+type spanFlusher interface {
+	exporterwrapper.OCSpanExporter
+	Flush()
 }
 
 type awsXRayExporter struct {
@@ -52,15 +65,31 @@ type awsXRayExporter struct {
 	// exportersByServiceName shards AWS X-Ray OpenCensus-Go
 	// Trace exporters by serviceName that's derived
 	// from each Node of spans that this exporter receives.
-	exportersByServiceName map[string]*xray.Exporter
+	exportersByServiceName map[string]spanFlusher
 
 	defaultServiceName string
 	defaultOptions     []xray.Option
+
+	// daemon if set, sends the spans to the X-Ray daemon instead of the API.
+	daemon  *daemonSender
+	version string
+
+	// reencoder is shared by all the exporters so that a trace spanning
+	// multiple services maps to a single X-Ray trace ID.
+	reencoder *traceIDReencoder
 }
 
 // AWSXRayTraceExportersFromViper unmarshals the viper and returns an consumer.TraceConsumer targeting
-// AWS X-Ray according to the configuration settings.
+// AWS X-Ray according to the configuration settings. The failures to send to
+// the X-Ray daemon are logged to the global zap logger, see
+// AWSXRayTraceExportersFromViperWithLogger.
 func AWSXRayTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	return AWSXRayTraceExportersFromViperWithLogger(v, zap.L())
+}
+
+// AWSXRayTraceExportersFromViperWithLogger is AWSXRayTraceExportersFromViper
+// logging the failures to send to the X-Ray daemon to logger.
+func AWSXRayTraceExportersFromViperWithLogger(v *viper.Viper, logger *zap.Logger) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
 	var cfg struct {
 		AWSXRay *awsXRayConfig `mapstructure:"aws-xray"`
 	}
@@ -78,9 +107,19 @@ func AWSXRayTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsume
 	}
 
 	axe := &awsXRayExporter{
-		exportersByServiceName: make(map[string]*xray.Exporter),
+		exportersByServiceName: make(map[string]spanFlusher),
 		defaultOptions:         defaultOptions,
 		defaultServiceName:     xc.DefaultServiceName,
+		reencoder:              newTraceIDReencoder(),
+		version:                xc.Version,
+	}
+	if axe.version == "" {
+		axe.version = defaultVersionForAWSXRayApplications
+	}
+	if xc.DaemonAddress != "" {
+		if axe.daemon, err = newDaemonSender(xc.DaemonAddress, logger); err != nil {
+			return nil, nil, nil, fmt.Errorf("AWS-Xray: %v", err)
+		}
 	}
 
 	axte, err := exporterhelper.NewTraceExporter(
@@ -90,12 +129,18 @@ func AWSXRayTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsume
 		exporterhelper.WithRecordMetrics(true),
 	)
 	if err != nil {
+		if axe.daemon != nil {
+			axe.daemon.Close()
+		}
 		return nil, nil, nil, err
 	}
 
 	tps = append(tps, axte)
 	doneFns = append(doneFns, func() error {
 		axe.Flush()
+		if axe.daemon != nil {
+			return axe.daemon.Close()
+		}
 		return nil
 	})
 	return
@@ -172,10 +217,10 @@ func (axe *awsXRayExporter) PushTraceData(ctx context.Context, td data.TraceData
 	if err != nil {
 		return len(td.Spans), err
 	}
-	return exporterwrapper.PushOcProtoSpansToOCTraceExporter(exp, td)
+	return exporterwrapper.PushOcProtoSpansToOCTraceExporter(ctx, &reencodingExporter{reencoder: axe.reencoder, exporter: exp}, td)
 }
 
-func (axe *awsXRayExporter) getOrMakeExporterByServiceName(serviceName string) (*xray.Exporter, error) {
+func (axe *awsXRayExporter) getOrMakeExporterByServiceName(serviceName string) (spanFlusher, error) {
 	axe.mu.Lock()
 	defer axe.mu.Unlock()
 
@@ -186,10 +231,15 @@ func (axe *awsXRayExporter) getOrMakeExporterByServiceName(serviceName string) (
 
 	// Otherwise, this is the our first time creating this exporter,
 	// so create it with the default options but finally the prescribed serviceName.
-	opts := append(axe.defaultOptions, xray.WithServiceName(serviceName))
-	exp, err := xray.NewExporter(opts...)
-	if err != nil {
-		return nil, err
+	if axe.daemon != nil {
+		exp = &daemonExporter{sender: axe.daemon, serviceName: serviceName, version: axe.version}
+	} else {
+		opts := append(axe.defaultOptions, xray.WithServiceName(serviceName))
+		xexp, err := xray.NewExporter(opts...)
+		if err != nil {
+			return nil, err
+		}
+		exp = xexp
 	}
 
 	// Now memoize the newly created AWS X-Ray exporter, for later lookups.
@@ -15,13 +15,22 @@
 package awsexporter
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	xray "contrib.go.opencensus.io/exporter/aws"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 )
 
 func TestTransformConfigToXRayOptions(t *testing.T) {
@@ -94,3 +103,75 @@ func TestTransformConfigToXRayOptions(t *testing.T) {
 		}
 	}
 }
+
+func TestAWSXRayExporterSendsToDaemon(t *testing.T) {
+	daemon, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on UDP: %v", err)
+	}
+	defer daemon.Close()
+
+	config := []byte(fmt.Sprintf(`
+aws-xray:
+    default_service_name: "backend"
+    daemon_address: %q
+`, daemon.LocalAddr().String()))
+	v, _ := viperutils.ViperFromYAMLBytes(config)
+	tps, _, doneFns, err := AWSXRayTraceExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer func() {
+		for _, doneFn := range doneFns {
+			doneFn()
+		}
+	}()
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+
+	now := time.Now()
+	td := data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "frontend"}},
+		Spans: []*tracepb.Span{
+			{
+				TraceId:   []byte{0xff, 0xfe, 0xfd, 0xfc, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:      &tracepb.TruncatableString{Value: "checkout"},
+				StartTime: &timestamp.Timestamp{Seconds: now.Unix()},
+				EndTime:   &timestamp.Timestamp{Seconds: now.Unix() + 1},
+				Status:    &tracepb.Status{Code: 8, Message: "quota exceeded"},
+			},
+		},
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to consume the spans: %v", err)
+	}
+
+	buf := make([]byte, maxDaemonDocumentSize)
+	daemon.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := daemon.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read the document sent to the daemon: %v", err)
+	}
+	packet := string(buf[:n])
+	if !strings.HasPrefix(packet, daemonHeader) {
+		t.Fatalf("Got document %q, want the prefix %q", packet, daemonHeader)
+	}
+	var got segment
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(packet, daemonHeader)), &got); err != nil {
+		t.Fatalf("Failed to decode the segment document: %v", err)
+	}
+	if got.Name != "frontend" || got.ID != "0102030405060708" {
+		t.Errorf("Got segment %q with ID %q, want frontend with ID 0102030405060708", got.Name, got.ID)
+	}
+	if !xrayTraceIDRegexp.MatchString(got.TraceID) {
+		t.Errorf("Trace ID %q does not match the X-Ray format", got.TraceID)
+	}
+	if got.Fault || !got.Error || !got.Throttle {
+		t.Errorf("Got fault %t, error %t, throttle %t, want a throttled error", got.Fault, got.Error, got.Throttle)
+	}
+	if got.Service == nil || got.Service.Version != defaultVersionForAWSXRayApplications {
+		t.Errorf("Got service %+v, want version %q", got.Service, defaultVersionForAWSXRayApplications)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awsexporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// daemonHeader precedes every document sent to the X-Ray daemon.
+	daemonHeader = `{"format": "json", "version": 1}` + "\n"
+	// maxDaemonDocumentSize is the maximum size of the UDP datagrams read by
+	// the X-Ray daemon.
+	maxDaemonDocumentSize = 64 * 1024
+)
+
+// daemonSender sends segment documents to the X-Ray daemon listening on UDP.
+type daemonSender struct {
+	conn   net.Conn
+	logger *zap.Logger
+}
+
+func newDaemonSender(address string, logger *zap.Logger) (*daemonSender, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("dialing the X-Ray daemon at %q: %v", address, err)
+	}
+	return &daemonSender{conn: conn, logger: logger}, nil
+}
+
+func (ds *daemonSender) send(seg *segment) error {
+	doc, err := json.Marshal(seg)
+	if err != nil {
+		return err
+	}
+	packet := append([]byte(daemonHeader), doc...)
+	if len(packet) > maxDaemonDocumentSize {
+		return fmt.Errorf("the segment document is %d bytes, more than the %d bytes read by the X-Ray daemon", len(packet), maxDaemonDocumentSize)
+	}
+	_, err = ds.conn.Write(packet)
+	return err
+}
+
+func (ds *daemonSender) Close() error {
+	return ds.conn.Close()
+}
+
+// daemonExporter exports the spans of a service to the X-Ray daemon, a span
+// per document.
+type daemonExporter struct {
+	sender      *daemonSender
+	serviceName string
+	version     string
+}
+
+var _ spanFlusher = (*daemonExporter)(nil)
+
+func (de *daemonExporter) ExportSpan(sd *trace.SpanData) {
+	if err := de.sender.send(spanToSegment(sd, de.serviceName, de.version)); err != nil {
+		de.sender.logger.Warn("Failed to send a segment to the X-Ray daemon",
+			zap.String("service", de.serviceName), zap.Error(err))
+	}
+}
+
+// Flush does nothing, the documents are sent as soon as the spans are exported.
+func (de *daemonExporter) Flush() {}
@@ -0,0 +1,147 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awsexporter
+
+import (
+	"encoding/hex"
+	"regexp"
+
+	"go.opencensus.io/trace"
+)
+
+// maxSegmentNameLength is the maximum length of the name of X-Ray segments.
+const maxSegmentNameLength = 200
+
+var (
+	// invalidSegmentNameChars are the characters X-Ray rejects in the names
+	// of segments.
+	invalidSegmentNameChars = regexp.MustCompile(`[^\p{L}\p{N}\s_.:/%&#=+\-@]`)
+	// invalidAnnotationKeyChars are the characters X-Ray rejects in the keys
+	// of annotations.
+	invalidAnnotationKeyChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// segment is the X-Ray segment document of a span, see
+// https://docs.aws.amazon.com/xray/latest/devguide/xray-api-segmentdocuments.html
+type segment struct {
+	Name        string                 `json:"name"`
+	ID          string                 `json:"id"`
+	TraceID     string                 `json:"trace_id"`
+	ParentID    string                 `json:"parent_id,omitempty"`
+	StartTime   float64                `json:"start_time"`
+	EndTime     float64                `json:"end_time"`
+	Type        string                 `json:"type,omitempty"`
+	Namespace   string                 `json:"namespace,omitempty"`
+	Fault       bool                   `json:"fault,omitempty"`
+	Error       bool                   `json:"error,omitempty"`
+	Throttle    bool                   `json:"throttle,omitempty"`
+	Cause       *segmentCause          `json:"cause,omitempty"`
+	Service     *segmentService        `json:"service,omitempty"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+}
+
+type segmentCause struct {
+	Exceptions []segmentException `json:"exceptions"`
+}
+
+type segmentException struct {
+	Message string `json:"message"`
+}
+
+type segmentService struct {
+	Version string `json:"version"`
+}
+
+// spanToSegment returns the X-Ray document of sd. The spans with a local parent
+// are subsegments of their parent, the other ones are the segments of
+// serviceName, their remote parent if any being the parent segment.
+func spanToSegment(sd *trace.SpanData, serviceName, version string) *segment {
+	seg := &segment{
+		ID:        hex.EncodeToString(sd.SpanID[:]),
+		TraceID:   xrayTraceID(sd.TraceID),
+		StartTime: epochSeconds(sd.StartTime.UnixNano()),
+		EndTime:   epochSeconds(sd.EndTime.UnixNano()),
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		seg.ParentID = hex.EncodeToString(sd.ParentSpanID[:])
+	}
+	if seg.ParentID != "" && !sd.HasRemoteParent {
+		seg.Name = segmentName(sd.Name)
+		seg.Type = "subsegment"
+		if sd.SpanKind == trace.SpanKindClient {
+			seg.Namespace = "remote"
+		}
+	} else {
+		seg.Name = segmentName(serviceName)
+		seg.Service = &segmentService{Version: version}
+	}
+
+	seg.Fault, seg.Error, seg.Throttle = statusFlags(sd.Status.Code)
+	if sd.Status.Code != trace.StatusCodeOK && sd.Status.Message != "" {
+		seg.Cause = &segmentCause{Exceptions: []segmentException{{Message: sd.Status.Message}}}
+	}
+
+	for key, value := range sd.Attributes {
+		switch value.(type) {
+		case string, bool, int64, float64:
+			if seg.Annotations == nil {
+				seg.Annotations = make(map[string]interface{}, len(sd.Attributes))
+			}
+			seg.Annotations[invalidAnnotationKeyChars.ReplaceAllString(key, "_")] = value
+		}
+	}
+	return seg
+}
+
+// statusFlags maps the code of the status of a span to the flags of X-Ray
+// segments: fault for server errors, error for client errors, and both error
+// and throttle when the resources are exhausted, like an HTTP 429.
+func statusFlags(code int32) (fault, isError, throttle bool) {
+	switch code {
+	case trace.StatusCodeOK:
+		return false, false, false
+	case trace.StatusCodeResourceExhausted:
+		return false, true, true
+	case trace.StatusCodeCancelled,
+		trace.StatusCodeInvalidArgument,
+		trace.StatusCodeNotFound,
+		trace.StatusCodeAlreadyExists,
+		trace.StatusCodePermissionDenied,
+		trace.StatusCodeFailedPrecondition,
+		trace.StatusCodeAborted,
+		trace.StatusCodeOutOfRange,
+		trace.StatusCodeUnauthenticated:
+		return false, true, false
+	}
+	return true, false, false
+}
+
+// xrayTraceID formats a trace ID the way X-Ray does, the version 1 followed by
+// the epoch seconds and the unique part of the ID.
+func xrayTraceID(traceID trace.TraceID) string {
+	return "1-" + hex.EncodeToString(traceID[0:4]) + "-" + hex.EncodeToString(traceID[4:16])
+}
+
+func segmentName(name string) string {
+	name = invalidSegmentNameChars.ReplaceAllString(name, "_")
+	if runes := []rune(name); len(runes) > maxSegmentNameLength {
+		name = string(runes[:maxSegmentNameLength])
+	}
+	return name
+}
+
+func epochSeconds(nanos int64) float64 {
+	return float64(nanos) / 1e9
+}
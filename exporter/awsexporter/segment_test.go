@@ -0,0 +1,126 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awsexporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestStatusFlags(t *testing.T) {
+	tests := []struct {
+		code                         int32
+		wantFault, wantError, wantTh bool
+	}{
+		{code: trace.StatusCodeOK},
+		{code: trace.StatusCodeCancelled, wantError: true},
+		{code: trace.StatusCodeUnknown, wantFault: true},
+		{code: trace.StatusCodeInvalidArgument, wantError: true},
+		{code: trace.StatusCodeDeadlineExceeded, wantFault: true},
+		{code: trace.StatusCodeNotFound, wantError: true},
+		{code: trace.StatusCodeAlreadyExists, wantError: true},
+		{code: trace.StatusCodePermissionDenied, wantError: true},
+		{code: trace.StatusCodeResourceExhausted, wantError: true, wantTh: true},
+		{code: trace.StatusCodeFailedPrecondition, wantError: true},
+		{code: trace.StatusCodeAborted, wantError: true},
+		{code: trace.StatusCodeOutOfRange, wantError: true},
+		{code: trace.StatusCodeUnimplemented, wantFault: true},
+		{code: trace.StatusCodeInternal, wantFault: true},
+		{code: trace.StatusCodeUnavailable, wantFault: true},
+		{code: trace.StatusCodeDataLoss, wantFault: true},
+		{code: trace.StatusCodeUnauthenticated, wantError: true},
+		{code: 42, wantFault: true},
+	}
+
+	for _, tt := range tests {
+		fault, isError, throttle := statusFlags(tt.code)
+		if fault != tt.wantFault || isError != tt.wantError || throttle != tt.wantTh {
+			t.Errorf("statusFlags(%d) = fault %t, error %t, throttle %t, want fault %t, error %t, throttle %t",
+				tt.code, fault, isError, throttle, tt.wantFault, tt.wantError, tt.wantTh)
+		}
+	}
+}
+
+func TestSpanToSegment(t *testing.T) {
+	traceID := trace.TraceID{0x5c, 0xfd, 0x2b, 0x80, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	start := time.Unix(1560226688, 500000000)
+	span := func(parentSpanID trace.SpanID, hasRemoteParent bool) *trace.SpanData {
+		return &trace.SpanData{
+			SpanContext:     trace.SpanContext{TraceID: traceID, SpanID: trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+			ParentSpanID:    parentSpanID,
+			HasRemoteParent: hasRemoteParent,
+			SpanKind:        trace.SpanKindClient,
+			Name:            "GET /users/{id}",
+			StartTime:       start,
+			EndTime:         start.Add(250 * time.Millisecond),
+			Attributes:      map[string]interface{}{"http.method": "GET", "retried": false, "attempts": int64(2)},
+			Status:          trace.Status{Code: trace.StatusCodeUnavailable, Message: "backend down"},
+		}
+	}
+	parentSpanID := trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}
+
+	tests := []struct {
+		name string
+		sd   *trace.SpanData
+		want *segment
+	}{
+		{
+			name: "root span",
+			sd:   span(trace.SpanID{}, false),
+			want: &segment{
+				Name:        "frontend",
+				Service:     &segmentService{Version: "1.0"},
+				Annotations: map[string]interface{}{"http_method": "GET", "retried": false, "attempts": int64(2)},
+			},
+		},
+		{
+			name: "remote parent",
+			sd:   span(parentSpanID, true),
+			want: &segment{
+				Name:        "frontend",
+				ParentID:    "1112131415161718",
+				Service:     &segmentService{Version: "1.0"},
+				Annotations: map[string]interface{}{"http_method": "GET", "retried": false, "attempts": int64(2)},
+			},
+		},
+		{
+			name: "local parent",
+			sd:   span(parentSpanID, false),
+			want: &segment{
+				Name:        "GET /users/_id_",
+				ParentID:    "1112131415161718",
+				Type:        "subsegment",
+				Namespace:   "remote",
+				Annotations: map[string]interface{}{"http_method": "GET", "retried": false, "attempts": int64(2)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.want.ID = "0102030405060708"
+		tt.want.TraceID = "1-5cfd2b80-05060708090a0b0c0d0e0f10"
+		tt.want.StartTime = 1560226688.5
+		tt.want.EndTime = 1560226688.75
+		tt.want.Fault = true
+		tt.want.Cause = &segmentCause{Exceptions: []segmentException{{Message: "backend down"}}}
+
+		if got := spanToSegment(tt.sd, "frontend", "1.0"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awsexporter
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
+)
+
+const (
+	// AWS X-Ray rejects trace IDs whose first 4 bytes, interpreted as Unix epoch
+	// seconds, are more than 30 days in the past or too far in the future.
+	maxTraceIDAge        = 30 * 24 * time.Hour
+	maxTraceIDClockSkew  = 5 * time.Minute
+	maxReencodedTraceIDs = 100000
+)
+
+// traceIDReencoder rewrites the first 4 bytes of OpenCensus trace IDs, which
+// are random, with the epoch seconds of when the trace was first seen, so that
+// they are accepted by AWS X-Ray. The assigned epoch is remembered so that all
+// spans of a trace keep mapping to the same X-Ray trace ID.
+type traceIDReencoder struct {
+	mu     sync.Mutex
+	epochs map[trace.TraceID]uint32
+	now    func() time.Time
+}
+
+func newTraceIDReencoder() *traceIDReencoder {
+	return &traceIDReencoder{
+		epochs: make(map[trace.TraceID]uint32),
+		now:    time.Now,
+	}
+}
+
+func (r *traceIDReencoder) reencode(traceID trace.TraceID) trace.TraceID {
+	now := r.now()
+	epoch := time.Unix(int64(binary.BigEndian.Uint32(traceID[0:4])), 0)
+	if !epoch.Before(now.Add(-maxTraceIDAge)) && !epoch.After(now.Add(maxTraceIDClockSkew)) {
+		// Generated by an X-Ray compatible ID generator, keep it as is.
+		return traceID
+	}
+
+	r.mu.Lock()
+	seconds, ok := r.epochs[traceID]
+	if !ok {
+		if len(r.epochs) >= maxReencodedTraceIDs {
+			// Traces are short lived, forgetting all of them at once is
+			// simpler than tracking their age.
+			r.epochs = make(map[trace.TraceID]uint32)
+		}
+		seconds = uint32(now.Unix())
+		r.epochs[traceID] = seconds
+	}
+	r.mu.Unlock()
+
+	binary.BigEndian.PutUint32(traceID[0:4], seconds)
+	return traceID
+}
+
+// reencodingExporter re-encodes the trace IDs of spans, and of their links,
+// before handing them to the wrapped exporter.
+type reencodingExporter struct {
+	reencoder *traceIDReencoder
+	exporter  exporterwrapper.OCSpanExporter
+}
+
+var _ exporterwrapper.OCSpanExporter = (*reencodingExporter)(nil)
+
+func (re *reencodingExporter) ExportSpan(sd *trace.SpanData) {
+	// Copy the span since the original is shared with other exporters.
+	reencoded := *sd
+	reencoded.TraceID = re.reencoder.reencode(sd.TraceID)
+	if len(sd.Links) > 0 {
+		reencoded.Links = make([]trace.Link, len(sd.Links))
+		for i, link := range sd.Links {
+			link.TraceID = re.reencoder.reencode(link.TraceID)
+			reencoded.Links[i] = link
+		}
+	}
+	re.exporter.ExportSpan(&reencoded)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awsexporter
+
+import (
+	"encoding/binary"
+	"regexp"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+var xrayTraceIDRegexp = regexp.MustCompile(`^1-[0-9a-f]{8}-[0-9a-f]{24}$`)
+
+func TestTraceIDReencoder(t *testing.T) {
+	now := time.Unix(1560000000, 0)
+	r := newTraceIDReencoder()
+	r.now = func() time.Time { return now }
+
+	random := trace.TraceID{0xff, 0xfe, 0xfd, 0xfc, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	got := r.reencode(random)
+
+	if id := xrayTraceID(got); !xrayTraceIDRegexp.MatchString(id) {
+		t.Errorf("Re-encoded trace ID %q does not match the X-Ray format", id)
+	}
+	if epoch := binary.BigEndian.Uint32(got[0:4]); int64(epoch) != now.Unix() {
+		t.Errorf("Re-encoded epoch = %d, want %d", epoch, now.Unix())
+	}
+	if got[4:] != random[4:] {
+		t.Errorf("Unique part of the trace ID changed: got %x want %x", got[4:], random[4:])
+	}
+
+	// Spans of the same trace arriving later must map to the same ID.
+	now = now.Add(time.Minute)
+	if again := r.reencode(random); again != got {
+		t.Errorf("Trace ID re-encoded inconsistently: %x then %x", got, again)
+	}
+
+	// IDs generated by an X-Ray compatible generator are kept.
+	var valid trace.TraceID
+	binary.BigEndian.PutUint32(valid[0:4], uint32(now.Add(-time.Hour).Unix()))
+	copy(valid[4:], random[4:])
+	if kept := r.reencode(valid); kept != valid {
+		t.Errorf("Valid X-Ray trace ID was re-encoded: got %x want %x", kept, valid)
+	}
+}
+
+type recordingExporter struct {
+	spans []*trace.SpanData
+}
+
+func (re *recordingExporter) ExportSpan(sd *trace.SpanData) {
+	re.spans = append(re.spans, sd)
+}
+
+func TestReencodingExporter(t *testing.T) {
+	rec := &recordingExporter{}
+	exp := &reencodingExporter{reencoder: newTraceIDReencoder(), exporter: rec}
+
+	traceID := trace.TraceID{0xff, 0xfe, 0xfd, 0xfc, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{TraceID: traceID},
+		Links:       []trace.Link{{TraceID: traceID, Type: trace.LinkTypeParent}},
+	}
+	exp.ExportSpan(sd)
+
+	if len(rec.spans) != 1 {
+		t.Fatalf("Got %d exported spans, want 1", len(rec.spans))
+	}
+	got := rec.spans[0]
+	if !xrayTraceIDRegexp.MatchString(xrayTraceID(got.TraceID)) {
+		t.Errorf("Exported trace ID %q does not match the X-Ray format", xrayTraceID(got.TraceID))
+	}
+	if got.Links[0].TraceID != got.TraceID {
+		t.Errorf("Link trace ID = %x, want %x", got.Links[0].TraceID, got.TraceID)
+	}
+	if sd.TraceID != traceID || sd.Links[0].TraceID != traceID {
+		t.Error("The original span was modified")
+	}
+}
@@ -15,6 +15,9 @@
 package datadogexporter
 
 import (
+	"net"
+	"strconv"
+
 	datadog "github.com/DataDog/opencensus-go-exporter-datadog"
 	"github.com/spf13/viper"
 
@@ -22,6 +25,11 @@ import (
 	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
 )
 
+const (
+	defaultAgentHost = "localhost"
+	defaultAgentPort = 8126
+)
+
 type datadogConfig struct {
 	// ServiceName specifies the service name used for tracing.
 	ServiceName string `mapstructure:"service_name,omitempty"`
@@ -33,6 +41,12 @@ type datadogConfig struct {
 	// It defaults to localhost:8126.
 	TraceAddr string `mapstructure:"trace_addr,omitempty"`
 
+	// AgentHost and AgentPort are an alternative to TraceAddr, for
+	// deployments that get them from separate settings such as the
+	// DD_AGENT_HOST environment variable. TraceAddr takes precedence.
+	AgentHost string `mapstructure:"agent_host,omitempty"`
+	AgentPort int    `mapstructure:"agent_port,omitempty"`
+
 	// MetricsAddr specifies the host[:port] address for DogStatsD. It defaults
 	// to localhost:8125.
 	MetricsAddr string `mapstructure:"metrics_addr,omitempty"`
@@ -66,7 +80,7 @@ func DatadogTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsume
 	de := datadog.NewExporter(datadog.Options{
 		Service:   dc.ServiceName,
 		Namespace: dc.Namespace,
-		TraceAddr: dc.TraceAddr,
+		TraceAddr: traceAddr(dc),
 		StatsAddr: dc.MetricsAddr,
 		Tags:      dc.Tags,
 	})
@@ -83,10 +97,29 @@ func DatadogTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsume
 	// TODO: Examine the Datadog exporter to see
 	// if trace.ExportSpan was constraining and if perhaps the
 	// upload can use the context and information from the Node.
-	tps = append(tps, dgte)
+	if dc.EnableTracing {
+		tps = append(tps, dgte)
+	}
 
 	// TODO: (@odeke-em, @songya23) implement ExportMetrics for Datadog.
 	// mes = append(mes, oexp)
 
 	return
 }
+
+// traceAddr returns the address of the Datadog Trace Agent, an empty string
+// lets the Datadog exporter pick its default.
+func traceAddr(dc *datadogConfig) string {
+	if dc.TraceAddr != "" || (dc.AgentHost == "" && dc.AgentPort == 0) {
+		return dc.TraceAddr
+	}
+	host := dc.AgentHost
+	if host == "" {
+		host = defaultAgentHost
+	}
+	port := dc.AgentPort
+	if port == 0 {
+		port = defaultAgentPort
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
@@ -14,4 +14,114 @@
 
 package datadogexporter
 
-// TODO: Add tests.
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+func TestTraceAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  datadogConfig
+		want string
+	}{
+		{name: "nothing set", cfg: datadogConfig{}, want: ""},
+		{name: "trace_addr wins", cfg: datadogConfig{TraceAddr: "agent:1234", AgentHost: "other", AgentPort: 1}, want: "agent:1234"},
+		{name: "host and port", cfg: datadogConfig{AgentHost: "dd-agent", AgentPort: 9126}, want: "dd-agent:9126"},
+		{name: "host only", cfg: datadogConfig{AgentHost: "dd-agent"}, want: "dd-agent:8126"},
+		{name: "port only", cfg: datadogConfig{AgentPort: 9126}, want: "localhost:9126"},
+	}
+	for _, tt := range tests {
+		if got := traceAddr(&tt.cfg); got != tt.want {
+			t.Errorf("%s: traceAddr() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDatadogExportersFromViper_tracingDisabled(t *testing.T) {
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(`
+datadog:
+  enable_metrics: true
+`))
+	tps, _, doneFns, err := DatadogTraceExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer func() {
+		for _, fn := range doneFns {
+			fn()
+		}
+	}()
+	if len(tps) != 0 {
+		t.Errorf("Got %d trace exporters with tracing disabled, want 0", len(tps))
+	}
+}
+
+func TestDatadogExportersFromViper_agentHostAndPort(t *testing.T) {
+	requests := make(chan *http.Request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer srv.Close()
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to split the server address: %v", err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(fmt.Sprintf(`
+datadog:
+  service_name: test-service
+  agent_host: %q
+  agent_port: %d
+  enable_tracing: true
+`, host, portNum)))
+	tps, _, doneFns, err := DatadogTraceExportersFromViper(v)
+	if err != nil || len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters and error %v, want 1 and nil", len(tps), err)
+	}
+
+	td := data.TraceData{
+		Spans: []*tracepb.Span{
+			{
+				TraceId:   []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:      &tracepb.TruncatableString{Value: "operation"},
+				StartTime: &timestamp.Timestamp{Seconds: 1550000001},
+				EndTime:   &timestamp.Timestamp{Seconds: 1550000002},
+			},
+		},
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the span: %v", err)
+	}
+	// Stopping the exporter flushes the buffered spans.
+	for _, fn := range doneFns {
+		fn()
+	}
+
+	select {
+	case r := <-requests:
+		if g, w := r.URL.Path, "/v0.4/traces"; g != w {
+			t.Errorf("Request path: got %q want %q", g, w)
+		}
+		if g, w := r.Header.Get("Content-Type"), "application/msgpack"; g != w {
+			t.Errorf("Content-Type: got %q want %q", g, w)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the spans to reach the agent")
+	}
+}
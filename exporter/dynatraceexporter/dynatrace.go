@@ -0,0 +1,256 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dynatraceexporter sends the received spans to the trace API of the
+// local Dynatrace OneAgent.
+package dynatraceexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultEndpoint = "http://localhost:14499/api/v1/spans"
+
+	// maxBatchSize is the maximum number of spans the OneAgent takes per
+	// request.
+	maxBatchSize = 512
+)
+
+type dynatraceConfig struct {
+	// Endpoint is the URL of the trace API of the OneAgent,
+	// http://localhost:14499/api/v1/spans by default.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// APIToken is sent in the Authorization header if not blank, the local
+	// OneAgent doesn't need one.
+	APIToken string `mapstructure:"api_token"`
+
+	// BatchSize is the maximum number of spans of each request, up to and by
+	// default 512.
+	BatchSize int `mapstructure:"batch_size"`
+
+	// Timeout of each request, 10s by default.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// DynatraceExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// sending the spans to the OneAgent according to the configuration settings.
+func DynatraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		Dynatrace *dynatraceConfig `mapstructure:"dynatrace"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	dc := cfg.Dynatrace
+	if dc == nil {
+		return nil, nil, nil, nil
+	}
+
+	de, err := newDynatraceExporter(dc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure dynatrace exporter: %v", err)
+	}
+
+	dte, err := exporterhelper.NewTraceExporter(
+		"dynatrace",
+		de.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Dynatrace.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, dte)
+	return
+}
+
+// dynatraceExporter posts the span records to the OneAgent, at most batchSize
+// of them per request.
+type dynatraceExporter struct {
+	endpoint  string
+	apiToken  string
+	batchSize int
+	client    *http.Client
+}
+
+func newDynatraceExporter(dc *dynatraceConfig) (*dynatraceExporter, error) {
+	endpoint := dc.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the dynatrace endpoint %q", u.Scheme, endpoint)
+	}
+
+	batchSize := dc.BatchSize
+	if batchSize > maxBatchSize {
+		return nil, fmt.Errorf("batch_size %d exceeds the limit of %d spans of the OneAgent", batchSize, maxBatchSize)
+	}
+	if batchSize <= 0 {
+		batchSize = maxBatchSize
+	}
+	return &dynatraceExporter{
+		endpoint:  endpoint,
+		apiToken:  dc.APIToken,
+		batchSize: batchSize,
+		client:    exporterhelper.NewHTTPClient(dc.Timeout),
+	}, nil
+}
+
+// spanRecord is a span of the trace API. The IDs are hex encoded, the times
+// are in nanoseconds since the epoch.
+type spanRecord struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	SpanKind     string                 `json:"dt.span_kind"`
+	ServiceName  string                 `json:"dt.service_name,omitempty"`
+	StartTime    int64                  `json:"start_time"`
+	EndTime      int64                  `json:"end_time"`
+	Status       spanStatus             `json:"status"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Events       []spanEvent            `json:"events,omitempty"`
+	Resource     map[string]string      `json:"resource,omitempty"`
+}
+
+type spanStatus struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// spanEvent is an annotation of the span.
+type spanEvent struct {
+	Time       int64                  `json:"time"`
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (de *dynatraceExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	dropped := 0
+	serviceName := td.Node.GetServiceInfo().GetName()
+	var resource map[string]string
+	if res := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource); res != nil {
+		resource = res.Labels
+	}
+
+	records := make([]*spanRecord, 0, len(td.Spans))
+	flush := func() {
+		if len(records) == 0 {
+			return
+		}
+		if err := de.post(ctx, records); err != nil {
+			errs = append(errs, err)
+			dropped += len(records)
+		}
+		records = records[:0]
+	}
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		records = append(records, spanDataToRecord(sd, serviceName, resource))
+		if len(records) == de.batchSize {
+			flush()
+		}
+	}
+	flush()
+
+	return dropped, internal.CombineErrors(errs)
+}
+
+func spanDataToRecord(sd *trace.SpanData, serviceName string, resource map[string]string) *spanRecord {
+	r := &spanRecord{
+		TraceID:     sd.TraceID.String(),
+		SpanID:      sd.SpanID.String(),
+		Name:        sd.Name,
+		SpanKind:    spanKind(sd.SpanKind),
+		ServiceName: serviceName,
+		StartTime:   sd.StartTime.UnixNano(),
+		EndTime:     sd.EndTime.UnixNano(),
+		Status:      spanStatus{Code: sd.Code, Message: sd.Message},
+		Attributes:  sd.Attributes,
+		Resource:    resource,
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		r.ParentSpanID = sd.ParentSpanID.String()
+	}
+	for _, a := range sd.Annotations {
+		r.Events = append(r.Events, spanEvent{
+			Time:       a.Time.UnixNano(),
+			Name:       a.Message,
+			Attributes: a.Attributes,
+		})
+	}
+	return r
+}
+
+// spanKind maps the kind of a span to a dt.span_kind, the spans of
+// unspecified kind being internal.
+func spanKind(kind int) string {
+	switch kind {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	}
+	return "INTERNAL"
+}
+
+func (de *dynatraceExporter) post(ctx context.Context, records []*spanRecord) error {
+	body, err := json.Marshal(struct {
+		Spans []*spanRecord `json:"spans"`
+	}{records})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, de.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if de.apiToken != "" {
+		req.Header.Set("Authorization", "Api-Token "+de.apiToken)
+	}
+	if err := exporterhelper.SendHTTPRequest(ctx, de.client, req); err != nil {
+		return fmt.Errorf("dynatrace request of %d spans failed: %v", len(records), err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynatraceexporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+// newFakeOneAgent returns a fake OneAgent replying with status.
+func newFakeOneAgent(status int) *testutils.HTTPRecorder {
+	return &testutils.HTTPRecorder{Status: status, ErrorBody: `{"error":{"code":400,"message":"too many spans"}}`}
+}
+
+// spanKinds returns the dt.span_kind of the spans of body.
+func spanKinds(t *testing.T, body string) []string {
+	var payload struct {
+		Spans []map[string]interface{} `json:"spans"`
+	}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("Failed to decode the spans: %v", err)
+	}
+	kinds := make([]string, 0, len(payload.Spans))
+	for _, span := range payload.Spans {
+		kind, _ := span["dt.span_kind"].(string)
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+func TestDynatraceExporterSpanRecords(t *testing.T) {
+	fake := newFakeOneAgent(http.StatusAccepted)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("dynatrace:\n  endpoint: " + srv.URL + "/api/v1/spans\n"))
+	tps, _, _, err := DynatraceExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+
+	td := data.TraceData{
+		Node:     &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "frontend"}},
+		Resource: &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "staging"}},
+		Spans: []*tracepb.Span{{
+			TraceId:      []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			ParentSpanId: []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+			Name:         &tracepb.TruncatableString{Value: "GET /api"},
+			Kind:         tracepb.Span_SERVER,
+			StartTime:    &timestamp.Timestamp{Seconds: 1, Nanos: 500},
+			EndTime:      &timestamp.Timestamp{Seconds: 2},
+			Attributes: &tracepb.Span_Attributes{
+				AttributeMap: map[string]*tracepb.AttributeValue{
+					"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 200}},
+				},
+			},
+			TimeEvents: &tracepb.Span_TimeEvents{
+				TimeEvent: []*tracepb.Span_TimeEvent{{
+					Time: &timestamp.Timestamp{Seconds: 1, Nanos: 1000},
+					Value: &tracepb.Span_TimeEvent_Annotation_{
+						Annotation: &tracepb.Span_TimeEvent_Annotation{
+							Description: &tracepb.TruncatableString{Value: "cache miss"},
+						},
+					},
+				}},
+			},
+		}, {
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x09},
+			Name:    &tracepb.TruncatableString{Value: "db.query"},
+			Kind:    tracepb.Span_CLIENT,
+		}, {
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x0a},
+			Name:    &tracepb.TruncatableString{Value: "render"},
+		}},
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	requests, bodies := fake.Requests(), fake.Bodies()
+	if len(requests) != 1 {
+		t.Fatalf("Got %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/api/v1/spans" {
+		t.Errorf("Got %s %s, want POST /api/v1/spans", r.Method, r.URL.Path)
+	}
+	if got := r.Header.Get("Authorization"); got != "" {
+		t.Errorf("Got Authorization %q without token, want none", got)
+	}
+
+	kinds := spanKinds(t, bodies[0])
+	if want := []string{"SERVER", "CLIENT", "INTERNAL"}; strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Errorf("Got dt.span_kind %v, want %v", kinds, want)
+	}
+	want := `{"spans":[{"trace_id":"0102030405060708090a0b0c0d0e0f10","span_id":"0102030405060708","parent_span_id":"0807060504030201",` +
+		`"name":"GET /api","dt.span_kind":"SERVER","dt.service_name":"frontend","start_time":1000000500,"end_time":2000000000,` +
+		`"status":{"code":0},"attributes":{"http.status_code":200},"events":[{"time":1000001000,"name":"cache miss"}],` +
+		`"resource":{"k8s.namespace":"staging"}},`
+	if got := bodies[0]; !strings.HasPrefix(got, want) {
+		t.Errorf("Got body\n%s\nwant it to start with\n%s", got, want)
+	}
+}
+
+func TestDynatraceExporterBatchLimit(t *testing.T) {
+	fake := newFakeOneAgent(http.StatusAccepted)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	de, err := newDynatraceExporter(&dynatraceConfig{Endpoint: srv.URL, APIToken: "dt0c01.token"})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	dropped, err := de.pushTraceData(context.Background(), testutils.TraceDataWithSpans(1100))
+	if err != nil || dropped != 0 {
+		t.Fatalf("Got %d dropped spans and error %v, want none", dropped, err)
+	}
+
+	requests, bodies := fake.Requests(), fake.Bodies()
+	if len(bodies) != 3 {
+		t.Fatalf("Got %d requests, want 3", len(bodies))
+	}
+	for i, want := range []int{512, 512, 76} {
+		if got := len(spanKinds(t, bodies[i])); got != want {
+			t.Errorf("Request #%d: got %d spans, want %d", i, got, want)
+		}
+		if got, want := requests[i].Header.Get("Authorization"), "Api-Token dt0c01.token"; got != want {
+			t.Errorf("Request #%d: got Authorization %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestDynatraceExporterRequestError(t *testing.T) {
+	srv := httptest.NewServer(newFakeOneAgent(http.StatusBadRequest))
+	defer srv.Close()
+
+	de, err := newDynatraceExporter(&dynatraceConfig{Endpoint: srv.URL, BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	dropped, err := de.pushTraceData(context.Background(), testutils.TraceDataWithSpans(3))
+	testutils.CheckPushError(t, dropped, err, 3, "status 400", "too many spans")
+}
+
+func TestDynatraceExportersFromViperErrors(t *testing.T) {
+	testutils.CheckExportersFromViperErrors(t, DynatraceExportersFromViper, []testutils.InvalidConfig{
+		{Name: "batch size over the limit", Config: "dynatrace:\n  batch_size: 1000\n"},
+		{Name: "invalid scheme", Config: "dynatrace:\n  endpoint: udp://localhost:14499\n"},
+	})
+}
@@ -0,0 +1,182 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearchexporter
+
+import (
+	"fmt"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// spanDocument is the document of a span. The IDs are hex encoded, the times
+// are in RFC 3339 format with nanoseconds.
+type spanDocument struct {
+	Timestamp    string                 `json:"@timestamp"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	ServiceName  string                 `json:"service_name,omitempty"`
+	StartTime    string                 `json:"start_time"`
+	EndTime      string                 `json:"end_time"`
+	DurationMs   float64                `json:"duration_ms"`
+	Status       statusDocument         `json:"status"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Annotations  []annotationDocument   `json:"annotations,omitempty"`
+	Links        []linkDocument         `json:"links,omitempty"`
+	Resource     map[string]string      `json:"resource,omitempty"`
+}
+
+type statusDocument struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type annotationDocument struct {
+	Timestamp  string                 `json:"@timestamp"`
+	Message    string                 `json:"message"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type linkDocument struct {
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+	Type    string `json:"type"`
+}
+
+// spanDataToDocument returns the document of sd, sent by the service with
+// the resource labels.
+func spanDataToDocument(sd *trace.SpanData, serviceName string, resource map[string]string) *spanDocument {
+	doc := &spanDocument{
+		Timestamp:   formatTime(sd.StartTime),
+		TraceID:     sd.TraceID.String(),
+		SpanID:      sd.SpanID.String(),
+		Name:        sd.Name,
+		Kind:        spanKind(sd.SpanKind),
+		ServiceName: serviceName,
+		StartTime:   formatTime(sd.StartTime),
+		EndTime:     formatTime(sd.EndTime),
+		DurationMs:  float64(sd.EndTime.Sub(sd.StartTime)) / float64(time.Millisecond),
+		Status:      statusDocument{Code: sd.Code, Message: sd.Message},
+		Attributes:  sd.Attributes,
+		Resource:    resource,
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		doc.ParentSpanID = sd.ParentSpanID.String()
+	}
+	for _, a := range sd.Annotations {
+		doc.Annotations = append(doc.Annotations, annotationDocument{
+			Timestamp:  formatTime(a.Time),
+			Message:    a.Message,
+			Attributes: a.Attributes,
+		})
+	}
+	for _, l := range sd.Links {
+		doc.Links = append(doc.Links, linkDocument{
+			TraceID: l.TraceID.String(),
+			SpanID:  l.SpanID.String(),
+			Type:    linkType(l.Type),
+		})
+	}
+	return doc
+}
+
+// indexName returns the daily index of the spans started at t, in UTC.
+func indexName(prefix string, t time.Time) string {
+	return prefix + "-" + t.UTC().Format("2006.01.02")
+}
+
+// documentID returns the ID of the document of sd, so that the retried
+// bulk requests don't index it twice.
+func documentID(sd *trace.SpanData) string {
+	return fmt.Sprintf("%s-%s", sd.TraceID, sd.SpanID)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func spanKind(kind int) string {
+	switch kind {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	}
+	return "UNSPECIFIED"
+}
+
+func linkType(t trace.LinkType) string {
+	switch t {
+	case trace.LinkTypeChild:
+		return "CHILD_LINKED_SPAN"
+	case trace.LinkTypeParent:
+		return "PARENT_LINKED_SPAN"
+	}
+	return "UNSPECIFIED"
+}
+
+// indexTemplate returns the index template of the indices with prefix,
+// mapping the IDs and enums as keywords and the names and messages as
+// text for full-text search. The attributes and resource labels are mapped
+// dynamically.
+func indexTemplate(prefix string) map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword"}
+	date := map[string]interface{}{"type": "date_nanos"}
+	text := map[string]interface{}{
+		"type":   "text",
+		"fields": map[string]interface{}{"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256}},
+	}
+	return map[string]interface{}{
+		"index_patterns": []string{prefix + "-*"},
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"@timestamp":     date,
+					"trace_id":       keyword,
+					"span_id":        keyword,
+					"parent_span_id": keyword,
+					"name":           text,
+					"kind":           keyword,
+					"service_name":   keyword,
+					"start_time":     date,
+					"end_time":       date,
+					"duration_ms":    map[string]interface{}{"type": "double"},
+					"status": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"code":    map[string]interface{}{"type": "integer"},
+							"message": text,
+						},
+					},
+					"annotations": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"@timestamp": date,
+							"message":    text,
+						},
+					},
+					"links": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"trace_id": keyword,
+							"span_id":  keyword,
+							"type":     keyword,
+						},
+					},
+				},
+			},
+		},
+	}
+}
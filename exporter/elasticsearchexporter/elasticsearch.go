@@ -0,0 +1,372 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elasticsearchexporter indexes the received spans as documents in
+// Elasticsearch, in daily indices, for their full-text search.
+package elasticsearchexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultEndpoint     = "http://localhost:9200"
+	defaultIndexPrefix  = "traces"
+	defaultBulkSize     = 1000
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+type elasticsearchConfig struct {
+	// Endpoint is the URL of the Elasticsearch cluster,
+	// http://localhost:9200 by default.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Username and Password if set, authenticate the requests.
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// IndexPrefix is the prefix of the daily indices, named
+	// <prefix>-YYYY.MM.DD, "traces" by default.
+	IndexPrefix string `mapstructure:"index_prefix"`
+
+	// SkipTemplateCreation disables the creation of the index template of
+	// the indices, named after IndexPrefix, on startup.
+	SkipTemplateCreation bool `mapstructure:"skip_template_creation"`
+
+	// BulkSize is the maximum number of documents of each bulk request, 1000
+	// by default.
+	BulkSize int `mapstructure:"bulk_size"`
+
+	// Timeout of each request, 10s by default.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxRetries is the number of retries of the documents refused with a
+	// 429 or 5xx status, 3 by default. RetryBackoff is the delay before the
+	// first retry, doubled before each of the next ones.
+	MaxRetries   int           `mapstructure:"max_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+}
+
+// ElasticsearchExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// indexing the spans in Elasticsearch according to the configuration settings.
+func ElasticsearchExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		Elasticsearch *elasticsearchConfig `mapstructure:"elasticsearch"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	ec := cfg.Elasticsearch
+	if ec == nil {
+		return nil, nil, nil, nil
+	}
+
+	ee, err := newElasticsearchExporter(ec)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure elasticsearch exporter: %v", err)
+	}
+	if !ec.SkipTemplateCreation {
+		ctx, cancel := context.WithTimeout(context.Background(), ee.client.Timeout)
+		defer cancel()
+		if err := ee.putIndexTemplate(ctx); err != nil {
+			return nil, nil, nil, fmt.Errorf("Cannot create the index template of the elasticsearch exporter: %v", err)
+		}
+	}
+
+	ete, err := exporterhelper.NewTraceExporter(
+		"elasticsearch",
+		ee.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Elasticsearch.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, ete)
+	return
+}
+
+// elasticsearchExporter indexes the spans with the bulk API.
+type elasticsearchExporter struct {
+	endpoint     string
+	username     string
+	password     string
+	indexPrefix  string
+	bulkSize     int
+	maxRetries   int
+	retryBackoff time.Duration
+	client       *http.Client
+}
+
+func newElasticsearchExporter(ec *elasticsearchConfig) (*elasticsearchExporter, error) {
+	endpoint := ec.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the elasticsearch endpoint %q", u.Scheme, endpoint)
+	}
+	if strings.ContainsAny(ec.IndexPrefix, `*\/?"<>| ,#:`) || ec.IndexPrefix != strings.ToLower(ec.IndexPrefix) {
+		return nil, fmt.Errorf("invalid elasticsearch index prefix %q", ec.IndexPrefix)
+	}
+
+	ee := &elasticsearchExporter{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		username:     ec.Username,
+		password:     ec.Password,
+		indexPrefix:  ec.IndexPrefix,
+		bulkSize:     ec.BulkSize,
+		maxRetries:   ec.MaxRetries,
+		retryBackoff: ec.RetryBackoff,
+		client:       exporterhelper.NewHTTPClient(ec.Timeout),
+	}
+	if ee.indexPrefix == "" {
+		ee.indexPrefix = defaultIndexPrefix
+	}
+	if ee.bulkSize <= 0 {
+		ee.bulkSize = defaultBulkSize
+	}
+	if ee.maxRetries <= 0 {
+		ee.maxRetries = defaultMaxRetries
+	}
+	if ee.retryBackoff <= 0 {
+		ee.retryBackoff = defaultRetryBackoff
+	}
+	return ee, nil
+}
+
+// putIndexTemplate creates, or updates, the index template of the indices.
+func (ee *elasticsearchExporter) putIndexTemplate(ctx context.Context) error {
+	body, err := json.Marshal(indexTemplate(ee.indexPrefix))
+	if err != nil {
+		return err
+	}
+	resp, err := ee.do(ctx, http.MethodPut, "/_index_template/"+ee.indexPrefix, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+// bulkItem is a document to index, as its action and source lines of the
+// bulk request.
+type bulkItem struct {
+	action []byte
+	source []byte
+}
+
+func (ee *elasticsearchExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	dropped := 0
+	serviceName := td.Node.GetServiceInfo().GetName()
+	var resource map[string]string
+	if res := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource); res != nil {
+		resource = res.Labels
+	}
+
+	items := make([]bulkItem, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		item, err := newBulkItem(indexName(ee.indexPrefix, sd.StartTime), documentID(sd), spanDataToDocument(sd, serviceName, resource))
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		items = append(items, item)
+	}
+
+	for len(items) > 0 {
+		n := ee.bulkSize
+		if n > len(items) {
+			n = len(items)
+		}
+		failed, err := ee.bulk(ctx, items[:n])
+		if err != nil {
+			errs = append(errs, err)
+		}
+		dropped += failed
+		items = items[n:]
+	}
+	return dropped, internal.CombineErrors(errs)
+}
+
+func newBulkItem(index, id string, doc *spanDocument) (bulkItem, error) {
+	action, err := json.Marshal(map[string]interface{}{
+		"index": map[string]string{"_index": index, "_id": id},
+	})
+	if err != nil {
+		return bulkItem{}, err
+	}
+	source, err := json.Marshal(doc)
+	if err != nil {
+		return bulkItem{}, err
+	}
+	return bulkItem{action: action, source: source}, nil
+}
+
+// bulkResponse is the part of the response of the bulk API telling which
+// items failed.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk indexes the items and returns the number of them that failed. The
+// whole request is retried if refused with a 429 or 5xx status, and the
+// items refused with such a status are retried otherwise, with an
+// exponential backoff.
+func (ee *elasticsearchExporter) bulk(ctx context.Context, items []bulkItem) (int, error) {
+	failed := 0
+	var errs []error
+	backoff := ee.retryBackoff
+	for retries := 0; ; retries++ {
+		retry, permanent, err := ee.bulkOnce(ctx, items)
+		failed += len(permanent)
+		giveUp := len(retry) > 0 && (retries >= ee.maxRetries || ctx.Err() != nil)
+		if giveUp {
+			failed += len(retry)
+		}
+		// The errors of the retried items are only returned if they are
+		// eventually dropped.
+		if err != nil && (len(permanent) > 0 || giveUp) {
+			errs = append(errs, err)
+		}
+		if len(retry) == 0 || giveUp {
+			return failed, internal.CombineErrors(errs)
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		backoff *= 2
+		items = retry
+	}
+}
+
+// bulkOnce sends a bulk request of the items and returns the items to retry
+// and the ones that failed permanently.
+func (ee *elasticsearchExporter) bulkOnce(ctx context.Context, items []bulkItem) (retry, permanent []bulkItem, err error) {
+	var body bytes.Buffer
+	for _, item := range items {
+		body.Write(item.action)
+		body.WriteByte('\n')
+		body.Write(item.source)
+		body.WriteByte('\n')
+	}
+
+	resp, err := ee.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return items, nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		if retryableStatus(resp.StatusCode) {
+			return items, nil, err
+		}
+		return nil, items, err
+	}
+
+	var br bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		return nil, items, fmt.Errorf("invalid elasticsearch bulk response: %v", err)
+	}
+	if !br.Errors {
+		return nil, nil, nil
+	}
+	if len(br.Items) != len(items) {
+		return nil, items, fmt.Errorf("elasticsearch bulk response has %d items, want %d", len(br.Items), len(items))
+	}
+
+	var reasons []string
+	for i, result := range br.Items {
+		for _, r := range result {
+			if r.Status/100 == 2 {
+				continue
+			}
+			if r.Error != nil {
+				reasons = append(reasons, fmt.Sprintf("%s: %s", r.Error.Type, r.Error.Reason))
+			}
+			if retryableStatus(r.Status) {
+				retry = append(retry, items[i])
+			} else {
+				permanent = append(permanent, items[i])
+			}
+		}
+	}
+	return retry, permanent, fmt.Errorf("elasticsearch failed to index %d documents: %s",
+		len(retry)+len(permanent), strings.Join(reasons, "; "))
+}
+
+func (ee *elasticsearchExporter) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, ee.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	if ee.username != "" || ee.password != "" {
+		req.SetBasicAuth(ee.username, ee.password)
+	}
+	return ee.client.Do(req)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code/100 == 5
+}
+
+// checkStatus returns an error with the part of the body of the non 2xx
+// responses.
+func checkStatus(resp *http.Response) error {
+	if err := exporterhelper.CheckHTTPResponse(resp); err != nil {
+		return fmt.Errorf("elasticsearch request failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearchexporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+// fakeResponse is a response of fakeElasticsearch to a bulk request.
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// fakeElasticsearch replies to the index template requests with
+// templateStatus if set, and to the bulk ones with the responses, then with
+// successes.
+type fakeElasticsearch struct {
+	testutils.HTTPRecorder
+
+	mu             sync.Mutex
+	templateStatus int
+	responses      []fakeResponse
+}
+
+func newFakeElasticsearch(templateStatus int, responses ...fakeResponse) *fakeElasticsearch {
+	fe := &fakeElasticsearch{templateStatus: templateStatus, responses: responses}
+	fe.Reply = fe.reply
+	return fe
+}
+
+func (fe *fakeElasticsearch) reply(w http.ResponseWriter, r *http.Request) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/_index_template/traces":
+		if fe.templateStatus != 0 {
+			http.Error(w, `{"error":"unauthorized"}`, fe.templateStatus)
+			return
+		}
+		w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			http.Error(w, "not ndjson", http.StatusNotAcceptable)
+			return
+		}
+		if len(fe.responses) > 0 {
+			resp := fe.responses[0]
+			fe.responses = fe.responses[1:]
+			w.WriteHeader(resp.status)
+			w.Write([]byte(resp.body))
+			return
+		}
+		w.Write([]byte(`{"took":1,"errors":false,"items":[]}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// bodies returns the bodies of the requests received with method on path.
+func (fe *fakeElasticsearch) bodies(method, path string) []string {
+	var bodies []string
+	all := fe.Bodies()
+	for i, r := range fe.Requests() {
+		if r.Method == method && r.URL.Path == path {
+			bodies = append(bodies, all[i])
+		}
+	}
+	return bodies
+}
+
+func (fe *fakeElasticsearch) templates() []string {
+	return fe.bodies(http.MethodPut, "/_index_template/traces")
+}
+
+func (fe *fakeElasticsearch) bulks() []string {
+	return fe.bodies(http.MethodPost, "/_bulk")
+}
+
+// bulkLines returns the JSON lines of a bulk request.
+func bulkLines(t *testing.T, body string) []map[string]interface{} {
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Invalid bulk line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+var testTraceData = data.TraceData{
+	Node:     &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"}},
+	Resource: &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "prod"}},
+	Spans: []*tracepb.Span{
+		{
+			TraceId:      []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			ParentSpanId: []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			Name:         &tracepb.TruncatableString{Value: "GET /cart"},
+			Kind:         tracepb.Span_SERVER,
+			StartTime:    &timestamp.Timestamp{Seconds: 1564617600},
+			EndTime:      &timestamp.Timestamp{Seconds: 1564617600, Nanos: 2500000},
+			Attributes: &tracepb.Span_Attributes{
+				AttributeMap: map[string]*tracepb.AttributeValue{
+					"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 200}},
+				},
+			},
+			TimeEvents: &tracepb.Span_TimeEvents{
+				TimeEvent: []*tracepb.Span_TimeEvent{{
+					Time: &timestamp.Timestamp{Seconds: 1564617600, Nanos: 1000},
+					Value: &tracepb.Span_TimeEvent_Annotation_{
+						Annotation: &tracepb.Span_TimeEvent_Annotation{
+							Description: &tracepb.TruncatableString{Value: "cart loaded"},
+						},
+					},
+				}},
+			},
+		},
+		{
+			TraceId:   []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:    []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			Name:      &tracepb.TruncatableString{Value: "root"},
+			StartTime: &timestamp.Timestamp{Seconds: 1564704000},
+			EndTime:   &timestamp.Timestamp{Seconds: 1564704001},
+		},
+	},
+}
+
+func TestElasticsearchExporterIndexesSpans(t *testing.T) {
+	fake := newFakeElasticsearch(0)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("elasticsearch:\n  endpoint: " + srv.URL + "/\n  username: elastic\n  password: changeme\n"))
+	tps, _, _, err := ElasticsearchExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+
+	templates := fake.templates()
+	if len(templates) != 1 {
+		t.Fatalf("Got %d index templates, want 1 on startup", len(templates))
+	}
+	var template struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Template      struct {
+			Mappings struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+			} `json:"mappings"`
+		} `json:"template"`
+	}
+	if err := json.Unmarshal([]byte(templates[0]), &template); err != nil {
+		t.Fatalf("Invalid index template: %v", err)
+	}
+	if !reflect.DeepEqual(template.IndexPatterns, []string{"traces-*"}) {
+		t.Errorf("Got index patterns %v, want [traces-*]", template.IndexPatterns)
+	}
+	if got := template.Template.Mappings.Properties["trace_id"]["type"]; got != "keyword" {
+		t.Errorf("Got trace_id mapped as %v, want keyword", got)
+	}
+
+	if err := tps[0].ConsumeTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+	bulks := fake.bulks()
+	if len(bulks) != 1 {
+		t.Fatalf("Got %d bulk requests, want 1", len(bulks))
+	}
+	for i, r := range fake.Requests() {
+		if user, password, _ := r.BasicAuth(); user+":"+password != "elastic:changeme" {
+			t.Errorf("Request #%d: got basic auth %q, want elastic:changeme", i, user+":"+password)
+		}
+	}
+
+	lines := bulkLines(t, bulks[0])
+	if len(lines) != 4 {
+		t.Fatalf("Got %d bulk lines, want 4", len(lines))
+	}
+	wantActions := []map[string]interface{}{
+		{"index": map[string]interface{}{"_index": "traces-2019.08.01", "_id": "0102030405060708090a0b0c0d0e0f10-0102030405060708"}},
+		{"index": map[string]interface{}{"_index": "traces-2019.08.02", "_id": "0102030405060708090a0b0c0d0e0f10-1112131415161718"}},
+	}
+	for i, want := range wantActions {
+		if got := lines[2*i]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Got action %v, want %v", got, want)
+		}
+	}
+
+	wantDoc := map[string]interface{}{
+		"@timestamp":     "2019-08-01T00:00:00Z",
+		"trace_id":       "0102030405060708090a0b0c0d0e0f10",
+		"span_id":        "0102030405060708",
+		"parent_span_id": "1112131415161718",
+		"name":           "GET /cart",
+		"kind":           "SERVER",
+		"service_name":   "checkout",
+		"start_time":     "2019-08-01T00:00:00Z",
+		"end_time":       "2019-08-01T00:00:00.0025Z",
+		"duration_ms":    2.5,
+		"status":         map[string]interface{}{"code": float64(0)},
+		"attributes":     map[string]interface{}{"http.status_code": float64(200)},
+		"annotations": []interface{}{
+			map[string]interface{}{"@timestamp": "2019-08-01T00:00:00.000001Z", "message": "cart loaded"},
+		},
+		"resource": map[string]interface{}{"k8s.namespace": "prod"},
+	}
+	if got := lines[1]; !reflect.DeepEqual(got, wantDoc) {
+		t.Errorf("Got document\n%v\nwant\n%v", got, wantDoc)
+	}
+	if got := lines[3]; got["parent_span_id"] != nil || got["kind"] != "UNSPECIFIED" || got["duration_ms"] != float64(1000) {
+		t.Errorf("Got root span document %v", got)
+	}
+}
+
+func newTestExporter(t *testing.T, url string) *elasticsearchExporter {
+	ee, err := newElasticsearchExporter(&elasticsearchConfig{Endpoint: url, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	return ee
+}
+
+func TestElasticsearchExporterRetries(t *testing.T) {
+	fake := newFakeElasticsearch(0,
+		fakeResponse{status: http.StatusTooManyRequests, body: `{"error":"circuit_breaking_exception"}`},
+		fakeResponse{status: http.StatusOK, body: `{"errors":true,"items":[` +
+			`{"index":{"status":201}},` +
+			`{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}]}`},
+	)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	dropped, err := newTestExporter(t, srv.URL).pushTraceData(context.Background(), testTraceData)
+	if err != nil || dropped != 0 {
+		t.Fatalf("Got %d dropped spans and error %v, want none", dropped, err)
+	}
+	bulks := fake.bulks()
+	if len(bulks) != 3 {
+		t.Fatalf("Got %d bulk requests, want 3", len(bulks))
+	}
+	if bulks[1] != bulks[0] {
+		t.Error("The refused bulk request wasn't retried as is")
+	}
+	lines := bulkLines(t, bulks[2])
+	if len(lines) != 2 || lines[1]["name"] != "root" {
+		t.Errorf("Got last bulk request %v, want only the refused document", lines)
+	}
+}
+
+func TestElasticsearchExporterPermanentFailures(t *testing.T) {
+	fake := newFakeElasticsearch(0, fakeResponse{status: http.StatusOK, body: `{"errors":true,"items":[` +
+		`{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field"}}},` +
+		`{"index":{"status":201}}]}`})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	dropped, err := newTestExporter(t, srv.URL).pushTraceData(context.Background(), testTraceData)
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception: failed to parse field") {
+		t.Errorf("Got error %v, want the reason of the failure", err)
+	}
+	if dropped != 1 {
+		t.Errorf("Got %d dropped spans, want 1", dropped)
+	}
+	bulks := fake.bulks()
+	if len(bulks) != 1 {
+		t.Errorf("Got %d bulk requests, want no retry", len(bulks))
+	}
+}
+
+func TestElasticsearchExporterGivesUpRetrying(t *testing.T) {
+	unavailable := fakeResponse{status: http.StatusServiceUnavailable, body: `{"error":"unavailable"}`}
+	fake := newFakeElasticsearch(0, unavailable, unavailable, unavailable, unavailable)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	dropped, err := newTestExporter(t, srv.URL).pushTraceData(context.Background(), testTraceData)
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("Got error %v, want the status of the last attempt", err)
+	}
+	if dropped != 2 {
+		t.Errorf("Got %d dropped spans, want 2", dropped)
+	}
+	bulks := fake.bulks()
+	if len(bulks) != 1+defaultMaxRetries {
+		t.Errorf("Got %d bulk requests, want %d", len(bulks), 1+defaultMaxRetries)
+	}
+}
+
+func TestElasticsearchExporterBulkSize(t *testing.T) {
+	fake := newFakeElasticsearch(0)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ee, err := newElasticsearchExporter(&elasticsearchConfig{Endpoint: srv.URL, BulkSize: 1})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if _, err := ee.pushTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+	bulks := fake.bulks()
+	if len(bulks) != 2 {
+		t.Errorf("Got %d bulk requests, want 2", len(bulks))
+	}
+}
+
+func TestElasticsearchExportersFromViperTemplate(t *testing.T) {
+	fake := newFakeElasticsearch(http.StatusUnauthorized)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("elasticsearch:\n  endpoint: " + srv.URL + "\n"))
+	if _, _, _, err := ElasticsearchExportersFromViper(v); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Got error %v, want the failure of the index template creation", err)
+	}
+
+	v, _ = viperutils.ViperFromYAMLBytes([]byte("elasticsearch:\n  endpoint: " + srv.URL + "\n  skip_template_creation: true\n"))
+	if _, _, _, err := ElasticsearchExportersFromViper(v); err != nil {
+		t.Errorf("Got error %v with skip_template_creation, want none", err)
+	}
+	templates := fake.templates()
+	if len(templates) != 1 {
+		t.Errorf("Got %d index template requests, want only the first one", len(templates))
+	}
+}
+
+func TestElasticsearchExportersFromViperErrors(t *testing.T) {
+	testutils.CheckExportersFromViperErrors(t, ElasticsearchExportersFromViper, []testutils.InvalidConfig{
+		{Name: "invalid scheme", Config: "elasticsearch:\n  endpoint: tcp://localhost:9300\n  skip_template_creation: true\n"},
+		{Name: "uppercase index prefix", Config: "elasticsearch:\n  index_prefix: Traces\n  skip_template_creation: true\n"},
+		{Name: "invalid index prefix", Config: "elasticsearch:\n  index_prefix: a,b\n  skip_template_creation: true\n"},
+	})
+}
@@ -0,0 +1,84 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterhelper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultHTTPTimeout is the timeout of each request of the HTTP exporters
+	// configured without one.
+	DefaultHTTPTimeout = 10 * time.Second
+
+	// MaxHTTPErrorBodySize bounds the part of the body of a failed request
+	// kept in its HTTPStatusError.
+	MaxHTTPErrorBodySize = 1024
+)
+
+// NewHTTPClient returns the client of an HTTP exporter, with the timeout of
+// each request, DefaultHTTPTimeout if it isn't positive.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// HTTPStatusError is the error of a request answered with a non 2xx status.
+type HTTPStatusError struct {
+	StatusCode int
+	// Body is the beginning of the body of the response, up to
+	// MaxHTTPErrorBodySize bytes.
+	Body []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, bytes.TrimSpace(e.Body))
+}
+
+// CheckHTTPResponse returns nil if resp has a 2xx status, and a
+// *HTTPStatusError otherwise. The body of the successful responses is left
+// to the caller.
+func CheckHTTPResponse(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, MaxHTTPErrorBodySize))
+	return &HTTPStatusError{StatusCode: resp.StatusCode, Body: body}
+}
+
+// SendHTTPRequest sends req with ctx and checks the response with
+// CheckHTTPResponse. The body of the response is drained and closed, so that
+// the connection is reused.
+func SendHTTPRequest(ctx context.Context, client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := CheckHTTPResponse(resp); err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterhelper
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendHTTPRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.Write([]byte("ignored"))
+			return
+		}
+		http.Error(w, strings.Repeat("x", 2*MaxHTTPErrorBodySize), http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	client := NewHTTPClient(0)
+	if client.Timeout != DefaultHTTPTimeout {
+		t.Errorf("Got timeout %v, want %v", client.Timeout, DefaultHTTPTimeout)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ok", nil)
+	if err := SendHTTPRequest(context.Background(), client, req); err != nil {
+		t.Errorf("Got error %v for a 200 response, want none", err)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/fail", nil)
+	err := SendHTTPRequest(context.Background(), client, req)
+	statusErr, ok := err.(*HTTPStatusError)
+	if !ok {
+		t.Fatalf("Got error %v, want a *HTTPStatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Got status %d, want %d", statusErr.StatusCode, http.StatusBadRequest)
+	}
+	if len(statusErr.Body) != MaxHTTPErrorBodySize {
+		t.Errorf("Got %d bytes of body, want %d", len(statusErr.Body), MaxHTTPErrorBodySize)
+	}
+	if !strings.HasPrefix(err.Error(), "status 400: xxx") {
+		t.Errorf("Got error %q, want the status and the body", err)
+	}
+}
+
+func TestSendHTTPRequest_cancelledContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, nil)
+	if err := SendHTTPRequest(ctx, NewHTTPClient(0), req); err == nil {
+		t.Error("Got no error with a cancelled context")
+	}
+}
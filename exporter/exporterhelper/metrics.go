@@ -0,0 +1,55 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterhelper
+
+import (
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+// MetricViews return the per exporter metrics views, of the exporters created
+// with WithRecordMetrics, according to given telemetry level.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	exporterTagKeys := []tag.Key{observability.TagKeyExporter}
+	spansSentView := &view.View{
+		Name:        "spans_sent",
+		Measure:     observability.ViewExporterExportedSpans.Measure,
+		Description: "Count of spans sent by each exporter",
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.Sum(),
+	}
+	spansFailedView := &view.View{
+		Name:        "spans_failed",
+		Measure:     observability.ViewExporterDroppedSpans.Measure,
+		Description: "Count of spans each exporter failed to send",
+		TagKeys:     exporterTagKeys,
+		Aggregation: view.Sum(),
+	}
+	exportLatencyView := &view.View{
+		Name:        "export_latency_ms",
+		Measure:     observability.ViewExporterLatency.Measure,
+		Description: "Latency (in milliseconds) of the exports of each exporter",
+		TagKeys:     exporterTagKeys,
+		Aggregation: observability.ExporterLatencyDistribution,
+	}
+	return []*view.View{spansSentView, spansFailedView, exportLatencyView}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterhelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"contrib.go.opencensus.io/exporter/prometheus"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+func TestMetricViews(t *testing.T) {
+	if views := MetricViews(telemetry.None); len(views) != 0 {
+		t.Errorf("MetricViews(None) = %v, want none", views)
+	}
+
+	views := MetricViews(telemetry.Basic)
+	if err := view.Register(views...); err != nil {
+		t.Fatalf("Failed to register the views: %v", err)
+	}
+	defer view.Unregister(views...)
+
+	registry := promclient.NewRegistry()
+	pe, err := prometheus.NewExporter(prometheus.Options{Registry: registry})
+	if err != nil {
+		t.Fatalf("Failed to create the Prometheus exporter: %v", err)
+	}
+	view.RegisterExporter(pe)
+	defer view.UnregisterExporter(pe)
+	view.SetReportingPeriod(10 * time.Millisecond)
+	defer view.SetReportingPeriod(10 * time.Second)
+
+	okExporter, _ := NewTraceExporter("ok_exporter", newPushTraceData(0, nil), WithRecordMetrics(true))
+	failingExporter, _ := NewTraceExporter("failing_exporter", newPushTraceData(2, errors.New("send failed")), WithRecordMetrics(true))
+	td := data.TraceData{Spans: make([]*tracepb.Span, 3)}
+	for i := 0; i < 2; i++ {
+		okExporter.ConsumeTraceData(context.Background(), td)
+		failingExporter.ConsumeTraceData(context.Background(), td)
+	}
+
+	want := map[string]float64{
+		"spans_sent/ok_exporter":             6,
+		"spans_failed/ok_exporter":           0,
+		"spans_sent/failing_exporter":        2,
+		"spans_failed/failing_exporter":      4,
+		"export_latency_ms/ok_exporter":      2,
+		"export_latency_ms/failing_exporter": 2,
+	}
+	var got map[string]float64
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		got = gatherByExporter(t, registry)
+		if equalValues(got, want) {
+			return
+		}
+	}
+	t.Fatalf("Got metrics %v, want %v", got, want)
+}
+
+// gatherByExporter returns the value of the counters, and the number of
+// samples of the histograms, keyed by metric name and exporter.
+func gatherByExporter(t *testing.T, registry *promclient.Registry) map[string]float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather the metrics: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			var exporterName string
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "oc_exporter" {
+					exporterName = label.GetValue()
+				}
+			}
+			key := family.GetName() + "/" + exporterName
+			switch {
+			case m.GetHistogram() != nil:
+				values[key] = float64(m.GetHistogram().GetSampleCount())
+			case m.GetCounter() != nil:
+				values[key] = m.GetCounter().GetValue()
+			default:
+				values[key] = m.GetUntyped().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+func equalValues(got, want map[string]float64) bool {
+	for key, value := range want {
+		if got[key] != value {
+			return false
+		}
+	}
+	return true
+}
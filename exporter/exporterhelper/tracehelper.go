@@ -16,6 +16,7 @@ package exporterhelper
 
 import (
 	"context"
+	"time"
 
 	"go.opencensus.io/trace"
 
@@ -75,9 +76,14 @@ func NewTraceExporter(exporterFormat string, pushTraceData PushTraceData, option
 func pushTraceDataWithMetrics(next PushTraceData) PushTraceData {
 	return func(ctx context.Context, td data.TraceData) (int, error) {
 		// TOOD: Add retry logic here if we want to support because we need to record special metrics.
+		start := time.Now()
 		droppedSpans, err := next(ctx, td)
+		observability.RecordTraceExporterLatency(ctx, time.Since(start))
 		// TODO: How to record the reason of dropping?
 		observability.RecordTraceExporterMetrics(ctx, len(td.Spans), droppedSpans)
+		if err != nil {
+			observability.RecordTraceExporterError(ctx)
+		}
 		return droppedSpans, err
 	}
 }
@@ -0,0 +1,177 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportertest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// SpanDataExporter is a trace.Exporter storing the OpenCensus-Go spans it
+// receives, for the tests of the components recording their own spans or
+// wrapping a trace.Exporter. It is safe for concurrent use. The zero value is
+// ready to use as an exporter handed to the code under test, see
+// NewSpanDataExporter to receive the spans ended through the OpenCensus library.
+type SpanDataExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+	// added is closed, and replaced, every time a span is received.
+	added chan struct{}
+}
+
+var _ trace.Exporter = (*SpanDataExporter)(nil)
+
+// NewSpanDataExporter creates a SpanDataExporter and registers it with the
+// OpenCensus library, so it receives the sampled spans ended from then on.
+// It must be closed at the end of the test to unregister it.
+func NewSpanDataExporter() *SpanDataExporter {
+	sde := &SpanDataExporter{added: make(chan struct{})}
+	trace.RegisterExporter(sde)
+	return sde
+}
+
+// NewSpanDataExporterT is NewSpanDataExporter closing the exporter when tb
+// and its subtests complete.
+func NewSpanDataExporterT(tb testing.TB) *SpanDataExporter {
+	sde := NewSpanDataExporter()
+	tb.Cleanup(func() { sde.Close() })
+	return sde
+}
+
+// ExportSpan stores sd for tests.
+func (sde *SpanDataExporter) ExportSpan(sd *trace.SpanData) {
+	sde.mu.Lock()
+	defer sde.mu.Unlock()
+
+	sde.spans = append(sde.spans, sd)
+	if sde.added != nil {
+		close(sde.added)
+	}
+	sde.added = make(chan struct{})
+}
+
+// AllSpans returns the spans received since the creation of the exporter or
+// the last Reset.
+func (sde *SpanDataExporter) AllSpans() []*trace.SpanData {
+	sde.mu.Lock()
+	defer sde.mu.Unlock()
+
+	return append([]*trace.SpanData(nil), sde.spans...)
+}
+
+// WaitFor waits until at least count spans are received and returns them. It
+// returns an error, along with the spans received so far, if they aren't
+// received within timeout.
+func (sde *SpanDataExporter) WaitFor(count int, timeout time.Duration) ([]*trace.SpanData, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		sde.mu.Lock()
+		spans := append([]*trace.SpanData(nil), sde.spans...)
+		if sde.added == nil {
+			sde.added = make(chan struct{})
+		}
+		added := sde.added
+		sde.mu.Unlock()
+		if len(spans) >= count {
+			return spans, nil
+		}
+
+		select {
+		case <-added:
+		case <-deadline.C:
+			return spans, fmt.Errorf("got %d spans after %v, want %d", len(spans), timeout, count)
+		}
+	}
+}
+
+// SpansByTraceID returns the received spans of the given trace.
+func (sde *SpanDataExporter) SpansByTraceID(traceID trace.TraceID) []*trace.SpanData {
+	return sde.filter(func(sd *trace.SpanData) bool {
+		return sd.TraceID == traceID
+	})
+}
+
+// SpanByID returns the received span with the given ID, the first one
+// received if several have it, or nil if there isn't any.
+func (sde *SpanDataExporter) SpanByID(spanID trace.SpanID) *trace.SpanData {
+	spans := sde.filter(func(sd *trace.SpanData) bool {
+		return sd.SpanID == spanID
+	})
+	if len(spans) == 0 {
+		return nil
+	}
+	return spans[0]
+}
+
+// SpansWithAttribute returns the received spans with the attribute key set
+// to value. The attributes that aren't strings are compared in their
+// fmt.Sprint form, e.g. "true" or "42".
+func (sde *SpanDataExporter) SpansWithAttribute(key, value string) []*trace.SpanData {
+	return sde.filter(func(sd *trace.SpanData) bool {
+		attr, ok := sd.Attributes[key]
+		return ok && fmt.Sprint(attr) == value
+	})
+}
+
+// AssertSpanCount reports an error to tb if the number of received spans
+// isn't n.
+func (sde *SpanDataExporter) AssertSpanCount(tb testing.TB, n int) {
+	tb.Helper()
+	if got := len(sde.AllSpans()); got != n {
+		tb.Errorf("Got %d spans, want %d", got, n)
+	}
+}
+
+// AssertSpanAttribute reports an error to tb if none of the received spans
+// has the attribute key set to value, as matched by SpansWithAttribute.
+func (sde *SpanDataExporter) AssertSpanAttribute(tb testing.TB, key, value string) {
+	tb.Helper()
+	if len(sde.SpansWithAttribute(key, value)) == 0 {
+		tb.Errorf("Got no span with the attribute %q = %q", key, value)
+	}
+}
+
+// Reset drops the spans received so far, e.g. between the cases of a test.
+func (sde *SpanDataExporter) Reset() {
+	sde.mu.Lock()
+	defer sde.mu.Unlock()
+
+	sde.spans = nil
+}
+
+// Close unregisters the exporter from the OpenCensus library.
+func (sde *SpanDataExporter) Close() error {
+	trace.UnregisterExporter(sde)
+	return nil
+}
+
+func (sde *SpanDataExporter) filter(keep func(*trace.SpanData) bool) []*trace.SpanData {
+	sde.mu.Lock()
+	defer sde.mu.Unlock()
+
+	var spans []*trace.SpanData
+	for _, sd := range sde.spans {
+		if keep(sd) {
+			spans = append(spans, sd)
+		}
+	}
+	return spans
+}
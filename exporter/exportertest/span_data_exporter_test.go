@@ -0,0 +1,152 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportertest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestSpanDataExporter(t *testing.T) {
+	sde := NewSpanDataExporterT(t)
+
+	go func() {
+		for _, name := range []string{"first", "second", "third"} {
+			_, span := trace.StartSpan(context.Background(), name, trace.WithSampler(trace.AlwaysSample()))
+			span.End()
+		}
+	}()
+
+	spans, err := sde.WaitFor(3, 5*time.Second)
+	if err != nil {
+		t.Fatalf("WaitFor failed: %v", err)
+	}
+	if len(spans) != 3 || spans[0].Name != "first" || spans[2].Name != "third" {
+		t.Errorf("Got spans %v, want first, second and third", spans)
+	}
+	if got := sde.AllSpans(); len(got) != 3 {
+		t.Errorf("Got %d spans, want 3", len(got))
+	}
+
+	sde.Reset()
+	if got := sde.AllSpans(); len(got) != 0 {
+		t.Errorf("Got %d spans after Reset, want none", len(got))
+	}
+
+	// Spans not sampled are not exported.
+	_, span := trace.StartSpan(context.Background(), "unsampled", trace.WithSampler(trace.NeverSample()))
+	span.End()
+	spans, err = sde.WaitFor(1, 10*time.Millisecond)
+	if err == nil {
+		t.Errorf("WaitFor returned %v, want a timeout error", spans)
+	}
+	if len(spans) != 0 {
+		t.Errorf("Got %d spans on timeout, want none", len(spans))
+	}
+}
+
+func TestSpanDataExporterClose(t *testing.T) {
+	sde := NewSpanDataExporter()
+	if err := sde.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	_, span := trace.StartSpan(context.Background(), "after-close", trace.WithSampler(trace.AlwaysSample()))
+	span.End()
+	if got := sde.AllSpans(); len(got) != 0 {
+		t.Errorf("Got %d spans after Close, want none", len(got))
+	}
+}
+
+func TestSpanDataExporterConcurrentSpans(t *testing.T) {
+	sde := NewSpanDataExporterT(t)
+
+	tests := []struct {
+		name       string
+		goroutines int
+		spansEach  int
+	}{
+		{name: "single", goroutines: 1, spansEach: 1},
+		{name: "concurrent", goroutines: 8, spansEach: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sde.Reset()
+
+			var wg sync.WaitGroup
+			for g := 0; g < tt.goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < tt.spansEach; i++ {
+						_, span := trace.StartSpan(context.Background(), "op", trace.WithSampler(trace.AlwaysSample()))
+						span.AddAttributes(trace.Int64Attribute("goroutine", int64(g)))
+						span.AddAttributes(trace.StringAttribute("kind", "test"))
+						span.End()
+					}
+				}(g)
+			}
+			wg.Wait()
+
+			sde.AssertSpanCount(t, tt.goroutines*tt.spansEach)
+			sde.AssertSpanAttribute(t, "kind", "test")
+			sde.AssertSpanAttribute(t, "goroutine", "0")
+			if got := len(sde.SpansWithAttribute("goroutine", "0")); got != tt.spansEach {
+				t.Errorf("SpansWithAttribute() returned %d spans, want %d", got, tt.spansEach)
+			}
+			if got := sde.SpansWithAttribute("kind", "other"); len(got) != 0 {
+				t.Errorf("SpansWithAttribute() with another value returned %d spans, want 0", len(got))
+			}
+		})
+	}
+}
+
+func TestSpanDataExporterQueriesByID(t *testing.T) {
+	sde := NewSpanDataExporterT(t)
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	_, child := trace.StartSpan(ctx, "child")
+	child.End()
+	parent.End()
+	_, other := trace.StartSpan(context.Background(), "other", trace.WithSampler(trace.AlwaysSample()))
+	other.End()
+
+	if got := sde.SpansByTraceID(parent.SpanContext().TraceID); len(got) != 2 {
+		t.Errorf("SpansByTraceID() returned %d spans, want 2", len(got))
+	}
+	if got := sde.SpanByID(child.SpanContext().SpanID); got == nil || got.Name != "child" {
+		t.Errorf("SpanByID() = %v, want the child span", got)
+	}
+	if got := sde.SpanByID(trace.SpanID{1}); got != nil {
+		t.Errorf("SpanByID() with an unknown ID = %v, want nil", got)
+	}
+}
+
+func TestSpanDataExporterZeroValue(t *testing.T) {
+	sde := new(SpanDataExporter)
+	go sde.ExportSpan(&trace.SpanData{Name: "direct"})
+
+	spans, err := sde.WaitFor(1, 5*time.Second)
+	if err != nil {
+		t.Fatalf("WaitFor failed: %v", err)
+	}
+	if spans[0].Name != "direct" {
+		t.Errorf("Got span %q, want direct", spans[0].Name)
+	}
+}
@@ -24,6 +24,7 @@ package exporterwrapper
 import (
 	"context"
 
+	"go.opencensus.io/resource"
 	"go.opencensus.io/trace"
 
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
@@ -40,6 +41,22 @@ type OCSpanExporter interface {
 	ExportSpan(sd *trace.SpanData)
 }
 
+// Option customizes the trace exporter returned by NewExporterWrapper.
+type Option func(*wrapperOptions)
+
+type wrapperOptions struct {
+	resourceAttributes bool
+}
+
+// WithResourceAttributes adds the node attributes and resource labels of the
+// TraceData to the span attributes. It is meant for the exporters with no
+// notion of resource, since trace.SpanData has none.
+func WithResourceAttributes() Option {
+	return func(o *wrapperOptions) {
+		o.resourceAttributes = true
+	}
+}
+
 // NewExporterWrapper returns a consumer.TraceConsumer that converts OpenCensus Proto TraceData
 // to OpenCensus-Go SpanData and calls into the given trace.Exporter.
 //
@@ -48,11 +65,15 @@ type OCSpanExporter interface {
 // by various vendors and contributors. Eventually the goal is to
 // get those exporters converted to directly receive
 // OpenCensus Proto TraceData.
-func NewExporterWrapper(exporterName string, spanName string, ocExporter OCSpanExporter) (exporter.TraceExporter, error) {
+func NewExporterWrapper(exporterName string, spanName string, ocExporter OCSpanExporter, options ...Option) (exporter.TraceExporter, error) {
+	var opts wrapperOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
 	return exporterhelper.NewTraceExporter(
 		exporterName,
 		func(ctx context.Context, td data.TraceData) (int, error) {
-			return PushOcProtoSpansToOCTraceExporter(ocExporter, td)
+			return pushOcProtoSpans(ctx, ocExporter, td, opts.resourceAttributes)
 		},
 		exporterhelper.WithSpanName(spanName),
 		exporterhelper.WithRecordMetrics(true),
@@ -62,13 +83,27 @@ func NewExporterWrapper(exporterName string, spanName string, ocExporter OCSpanE
 // TODO: Remove PushOcProtoSpansToOCTraceExporter after aws-xray is changed to ExporterWrapper.
 
 // PushOcProtoSpansToOCTraceExporter pushes TraceData to the given trace.Exporter by converting the
-// protos to trace.SpanData.
-func PushOcProtoSpansToOCTraceExporter(ocExporter OCSpanExporter, td data.TraceData) (int, error) {
+// protos to trace.SpanData. Once ctx is done, e.g. because the request of the receiver timed out or
+// was cancelled, the remaining spans are dropped instead of exported and the context error is returned.
+func PushOcProtoSpansToOCTraceExporter(ctx context.Context, ocExporter OCSpanExporter, td data.TraceData) (int, error) {
+	return pushOcProtoSpans(ctx, ocExporter, td, false)
+}
+
+func pushOcProtoSpans(ctx context.Context, ocExporter OCSpanExporter, td data.TraceData, resourceAttributes bool) (int, error) {
 	var errs []error
 	var goodSpans []*tracepb.Span
+	var res *resource.Resource
+	if resourceAttributes {
+		res = spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource)
+	}
 	for _, span := range td.Spans {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
 		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
 		if err == nil {
+			spandatatranslator.AddResourceAttributes(sd, res)
 			ocExporter.ExportSpan(sd)
 			goodSpans = append(goodSpans, span)
 		} else {
@@ -14,4 +14,176 @@
 
 package exporterwrapper
 
-// TODO: Add tests.
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/trace"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// fakeOCSpanExporter records the exported spans, and calls onExport after
+// each of them if set.
+type fakeOCSpanExporter struct {
+	spans    []*trace.SpanData
+	onExport func()
+}
+
+func (e *fakeOCSpanExporter) ExportSpan(sd *trace.SpanData) {
+	e.spans = append(e.spans, sd)
+	if e.onExport != nil {
+		e.onExport()
+	}
+}
+
+var testTraceData = data.TraceData{
+	Spans: []*tracepb.Span{
+		{
+			TraceId: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanId:  []byte{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+			Name:    &tracepb.TruncatableString{Value: "first"},
+		},
+		{
+			TraceId: []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanId:  []byte{0xE1, 0xE2, 0xE3, 0xE4, 0xE5, 0xE6, 0xE7, 0xE8},
+			Name:    &tracepb.TruncatableString{Value: "second"},
+		},
+	},
+}
+
+func TestPushOcProtoSpansToOCTraceExporter(t *testing.T) {
+	exp := new(fakeOCSpanExporter)
+	dropped, err := PushOcProtoSpansToOCTraceExporter(context.Background(), exp, testTraceData)
+	if err != nil {
+		t.Fatalf("Failed to push the spans: %v", err)
+	}
+	if dropped != 0 {
+		t.Errorf("Got %d dropped spans, want 0", dropped)
+	}
+	if len(exp.spans) != 2 || exp.spans[0].Name != "first" || exp.spans[1].Name != "second" {
+		t.Errorf("Got exported spans %v, want the first and second ones", exp.spans)
+	}
+}
+
+var testResourceTraceData = data.TraceData{
+	Node: &commonpb.Node{
+		Attributes: map[string]string{"host.name": "h1", "zone": "us-east1"},
+	},
+	Resource: &resourcepb.Resource{
+		Type:   "k8s",
+		Labels: map[string]string{"k8s.namespace": "staging", "zone": "us-east1-b"},
+	},
+	Spans: []*tracepb.Span{
+		{
+			TraceId: testTraceData.Spans[0].TraceId,
+			SpanId:  testTraceData.Spans[0].SpanId,
+			Attributes: &tracepb.Span_Attributes{
+				AttributeMap: map[string]*tracepb.AttributeValue{
+					"k8s.namespace": {
+						Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "span"}},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestPushOcProtoSpansToOCTraceExporter_noResourceAttributes(t *testing.T) {
+	exp := new(fakeOCSpanExporter)
+	if _, err := PushOcProtoSpansToOCTraceExporter(context.Background(), exp, testResourceTraceData); err != nil {
+		t.Fatalf("Failed to push the spans: %v", err)
+	}
+	if len(exp.spans) != 1 {
+		t.Fatalf("Got %d exported spans, want 1", len(exp.spans))
+	}
+	want := map[string]interface{}{"k8s.namespace": "span"}
+	if got := exp.spans[0].Attributes; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got span attributes %v, want %v", got, want)
+	}
+}
+
+func TestNewExporterWrapper_resourceAttributes(t *testing.T) {
+	exp := new(fakeOCSpanExporter)
+	te, err := NewExporterWrapper("fake", "ocservice.exporter.Fake.ConsumeTraceData", exp, WithResourceAttributes())
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if err := te.ConsumeTraceData(context.Background(), testResourceTraceData); err != nil {
+		t.Fatalf("Failed to consume the spans: %v", err)
+	}
+	if len(exp.spans) != 1 {
+		t.Fatalf("Got %d exported spans, want 1", len(exp.spans))
+	}
+	want := map[string]interface{}{
+		"host.name":     "h1",
+		"zone":          "us-east1-b",
+		"k8s.namespace": "span",
+	}
+	if got := exp.spans[0].Attributes; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got span attributes %v, want %v", got, want)
+	}
+}
+
+func TestPushOcProtoSpansToOCTraceExporter_cancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	exp := new(fakeOCSpanExporter)
+	dropped, err := PushOcProtoSpansToOCTraceExporter(ctx, exp, testTraceData)
+	if err != context.Canceled {
+		t.Errorf("Got error %v, want %v", err, context.Canceled)
+	}
+	if dropped != 2 {
+		t.Errorf("Got %d dropped spans, want 2", dropped)
+	}
+	if len(exp.spans) != 0 {
+		t.Errorf("ExportSpan called with %v, want no calls for a cancelled context", exp.spans)
+	}
+}
+
+func TestPushOcProtoSpansToOCTraceExporter_cancelledWhileExporting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The upstream request is cancelled while the first span is exported.
+	exp := &fakeOCSpanExporter{onExport: cancel}
+	dropped, err := PushOcProtoSpansToOCTraceExporter(ctx, exp, testTraceData)
+	if err != context.Canceled {
+		t.Errorf("Got error %v, want %v", err, context.Canceled)
+	}
+	if dropped != 1 {
+		t.Errorf("Got %d dropped spans, want 1", dropped)
+	}
+	if len(exp.spans) != 1 || exp.spans[0].Name != "first" {
+		t.Errorf("Got exported spans %v, want only the first one", exp.spans)
+	}
+}
+
+func TestNewExporterWrapper_cancelledContext(t *testing.T) {
+	exp := new(fakeOCSpanExporter)
+	te, err := NewExporterWrapper("fake", "ocservice.exporter.Fake.ConsumeTraceData", exp)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := te.ConsumeTraceData(ctx, testTraceData); err != context.Canceled {
+		t.Errorf("Got error %v, want %v", err, context.Canceled)
+	}
+	if len(exp.spans) != 0 {
+		t.Errorf("ExportSpan called with %v, want no calls for a cancelled context", exp.spans)
+	}
+
+	if err := te.ConsumeTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to consume the spans: %v", err)
+	}
+	if len(exp.spans) != 2 {
+		t.Errorf("Got %d exported spans, want 2", len(exp.spans))
+	}
+}
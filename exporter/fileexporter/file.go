@@ -0,0 +1,258 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fileexporter writes the received spans to a local file, one JSON
+// object per line, for offline analysis.
+package fileexporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/spf13/viper"
+
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+type fileConfig struct {
+	// Path of the file the spans are written to.
+	Path string `mapstructure:"path"`
+
+	// MaxSizeBytes if set, rotates the file before a write would make it
+	// larger than this size.
+	MaxSizeBytes int64 `mapstructure:"max_size_bytes"`
+
+	// RotationInterval if set, rotates the file once it has been written to
+	// for this long.
+	RotationInterval time.Duration `mapstructure:"rotation_interval"`
+}
+
+var errBlankFilePath = errors.New("expecting a non-blank path for the file exporter")
+
+// FileExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// writing to a local file according to the configuration settings.
+func FileExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		File *fileConfig `mapstructure:"file"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	fc := cfg.File
+	if fc == nil {
+		return nil, nil, nil, nil
+	}
+	if fc.Path == "" {
+		return nil, nil, nil, errBlankFilePath
+	}
+
+	fe, err := newFileExporter(fc.Path, fc.MaxSizeBytes, fc.RotationInterval)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure file exporter: %v", err)
+	}
+
+	fte, err := exporterhelper.NewTraceExporter(
+		"file",
+		fe.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.File.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		fe.Close()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, fte)
+	doneFns = append(doneFns, fe.Close)
+	return
+}
+
+// fileExporter writes every span as an ExportTraceServiceRequest, holding the
+// span and the node and resource it was received with, on its own line.
+type fileExporter struct {
+	path             string
+	maxSizeBytes     int64
+	rotationInterval time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+	rotated  int
+	closed   bool
+}
+
+func newFileExporter(path string, maxSizeBytes int64, rotationInterval time.Duration) (*fileExporter, error) {
+	fe := &fileExporter{
+		path:             path,
+		maxSizeBytes:     maxSizeBytes,
+		rotationInterval: rotationInterval,
+		now:              time.Now,
+	}
+	if err := fe.open(); err != nil {
+		return nil, err
+	}
+	return fe, nil
+}
+
+func (fe *fileExporter) open() error {
+	f, err := os.OpenFile(fe.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fe.file = f
+	fe.writer = bufio.NewWriter(f)
+	fe.size = fi.Size()
+	fe.openedAt = fe.now()
+	return nil
+}
+
+func (fe *fileExporter) closeFile() error {
+	flushErr := fe.writer.Flush()
+	closeErr := fe.file.Close()
+	fe.file, fe.writer = nil, nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// rotate moves the current file aside, with a name made unique by the time
+// of the rotation and a sequence number, and starts a new one. The current
+// file is kept if its records can't be flushed, and written to again if it
+// can't be moved aside. The file is only unset if it can't be opened again.
+func (fe *fileExporter) rotate() error {
+	if err := fe.writer.Flush(); err != nil {
+		return err
+	}
+	var errs []error
+	if err := fe.file.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	fe.file, fe.writer = nil, nil
+	fe.rotated++
+	rotatedPath := fmt.Sprintf("%s.%s-%d", fe.path, fe.now().UTC().Format("20060102T150405"), fe.rotated)
+	if err := os.Rename(fe.path, rotatedPath); err != nil {
+		errs = append(errs, err)
+	}
+	if err := fe.open(); err != nil {
+		errs = append(errs, err)
+	}
+	return internal.CombineErrors(errs)
+}
+
+func (fe *fileExporter) needsRotation(recordSize int64) bool {
+	if fe.size == 0 {
+		// Never rotate an empty file, a single record larger than
+		// MaxSizeBytes would otherwise rotate forever.
+		return false
+	}
+	if fe.maxSizeBytes > 0 && fe.size+recordSize > fe.maxSizeBytes {
+		return true
+	}
+	return fe.rotationInterval > 0 && fe.now().Sub(fe.openedAt) >= fe.rotationInterval
+}
+
+func (fe *fileExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	if fe.closed {
+		return len(td.Spans), errors.New("file exporter is closed")
+	}
+	if fe.file == nil {
+		// The file could not be opened again by the last rotation.
+		if err := fe.open(); err != nil {
+			return len(td.Spans), err
+		}
+	}
+
+	var errs []error
+	dropped := 0
+	rotationFailed := false
+	marshaler := &jsonpb.Marshaler{}
+	for i, span := range td.Spans {
+		var buf bytes.Buffer
+		err := marshaler.Marshal(&buf, &agenttracepb.ExportTraceServiceRequest{
+			Node:     td.Node,
+			Resource: td.Resource,
+			Spans:    []*tracepb.Span{span},
+		})
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		buf.WriteByte('\n')
+
+		// A failed rotation is retried with the next batch, not on every
+		// record of this one.
+		if !rotationFailed && fe.needsRotation(int64(buf.Len())) {
+			if err := fe.rotate(); err != nil {
+				errs = append(errs, fmt.Errorf("rotating %s: %v", fe.path, err))
+				rotationFailed = true
+				if fe.file == nil {
+					return dropped + len(td.Spans) - i, internal.CombineErrors(errs)
+				}
+			}
+		}
+		n, err := fe.writer.Write(buf.Bytes())
+		fe.size += int64(n)
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+		}
+	}
+
+	// The records still buffered are written before the batch is reported
+	// as exported.
+	if err := fe.writer.Flush(); err != nil {
+		errs = append(errs, err)
+	}
+	return dropped, internal.CombineErrors(errs)
+}
+
+// Close flushes the buffered records and closes the file.
+func (fe *fileExporter) Close() error {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+
+	if fe.closed {
+		return nil
+	}
+	fe.closed = true
+	if fe.file == nil {
+		return nil
+	}
+	return fe.closeFile()
+}
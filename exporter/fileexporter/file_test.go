@@ -0,0 +1,308 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileexporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+func traceDataWithSpans(names ...string) data.TraceData {
+	td := data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "file-svc"}},
+	}
+	for i, name := range names {
+		td.Spans = append(td.Spans, &tracepb.Span{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, byte(i)},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, byte(i)},
+			Name:    &tracepb.TruncatableString{Value: name},
+		})
+	}
+	return td
+}
+
+// readSpanNames parses the complete lines of the file and returns the name
+// of the span on each one. A trailing partial line is ignored.
+func readSpanNames(t *testing.T, blob []byte) []string {
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(blob))
+	complete := bytes.Count(blob, []byte("\n"))
+	for i := 0; i < complete && scanner.Scan(); i++ {
+		var req agenttracepb.ExportTraceServiceRequest
+		if err := jsonpb.UnmarshalString(scanner.Text(), &req); err != nil {
+			t.Fatalf("Line #%d is not a valid record: %v\n%s", i, err, scanner.Text())
+		}
+		if len(req.Spans) != 1 {
+			t.Fatalf("Line #%d has %d spans, want 1", i, len(req.Spans))
+		}
+		if g, w := req.Node.GetServiceInfo().GetName(), "file-svc"; g != w {
+			t.Errorf("Line #%d service name: got %q want %q", i, g, w)
+		}
+		names = append(names, req.Spans[0].Name.GetValue())
+	}
+	return names
+}
+
+func TestFileExporterWritesJSONL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileexporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.jsonl")
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(fmt.Sprintf("file:\n  path: %q\n", path)))
+	tps, _, doneFns, err := FileExportersFromViper(v)
+	if err != nil || len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters and error %v, want 1 and nil", len(tps), err)
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), traceDataWithSpans("a", "b", "c")); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	// The records of a batch are on disk before Close.
+	blob, _ := ioutil.ReadFile(path)
+	if got := readSpanNames(t, blob); len(got) != 3 {
+		t.Errorf("Got %d records before Close, want 3", len(got))
+	}
+
+	// A crash in the middle of a write leaves a partial record behind, the
+	// complete records before it must still be readable.
+	truncated := blob[:len(blob)-10]
+	if got := readSpanNames(t, truncated); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("Got %v from the truncated file, want [a b]", got)
+	}
+
+	for _, fn := range doneFns {
+		if err := fn(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	}
+}
+
+func TestFileExporterBlankPath(t *testing.T) {
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("file:\n  max_size_bytes: 10\n"))
+	if _, _, _, err := FileExportersFromViper(v); err != errBlankFilePath {
+		t.Errorf("Got error %v, want %v", err, errBlankFilePath)
+	}
+}
+
+func rotatedFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "spans.jsonl.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Order by the sequence number that ends the name of rotated files.
+	seq := func(name string) int {
+		n, _ := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+		return n
+	}
+	sort.Slice(files, func(i, j int) bool { return seq(files[i]) < seq(files[j]) })
+	return files
+}
+
+func TestFileExporterRotatesBySize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileexporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.jsonl")
+
+	// Measure the size of a single record to put exactly two in each file.
+	probe, err := newFileExporter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe.pushTraceData(context.Background(), traceDataWithSpans("x"))
+	probe.Close()
+	fi, _ := os.Stat(path)
+	recordSize := fi.Size()
+	os.Remove(path)
+
+	fe, err := newFileExporter(path, 2*recordSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := fe.pushTraceData(context.Background(), traceDataWithSpans(name)); err != nil {
+			t.Fatalf("Failed to export %q: %v", name, err)
+		}
+	}
+	if err := fe.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := rotatedFiles(t, dir)
+	if len(rotated) != 2 {
+		t.Fatalf("Got %d rotated files, want 2: %v", len(rotated), rotated)
+	}
+	var all []string
+	for _, f := range append(rotated, path) {
+		fi, _ := os.Stat(f)
+		if fi.Size() > 2*recordSize {
+			t.Errorf("%s is %d bytes, larger than the %d bytes limit", f, fi.Size(), 2*recordSize)
+		}
+		blob, _ := ioutil.ReadFile(f)
+		all = append(all, readSpanNames(t, blob)...)
+	}
+	if g, w := fmt.Sprint(all), "[a b c d e]"; g != w {
+		t.Errorf("Records across the files: got %s want %s", g, w)
+	}
+}
+
+func TestFileExporterRotatesByTime(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileexporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.jsonl")
+
+	now := time.Unix(1560000000, 0)
+	fe, err := newFileExporter(path, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fe.now = func() time.Time { return now }
+	fe.openedAt = now
+
+	fe.pushTraceData(context.Background(), traceDataWithSpans("a"))
+	now = now.Add(59 * time.Minute)
+	fe.pushTraceData(context.Background(), traceDataWithSpans("b"))
+	if n := len(rotatedFiles(t, dir)); n != 0 {
+		t.Fatalf("Rotated %d files before the interval elapsed", n)
+	}
+	now = now.Add(time.Minute)
+	fe.pushTraceData(context.Background(), traceDataWithSpans("c"))
+	fe.Close()
+
+	rotated := rotatedFiles(t, dir)
+	if len(rotated) != 1 {
+		t.Fatalf("Got %d rotated files, want 1", len(rotated))
+	}
+	old, _ := ioutil.ReadFile(rotated[0])
+	cur, _ := ioutil.ReadFile(path)
+	if g, w := fmt.Sprint(readSpanNames(t, old)), "[a b]"; g != w {
+		t.Errorf("Rotated file: got %s want %s", g, w)
+	}
+	if g, w := fmt.Sprint(readSpanNames(t, cur)), "[c]"; g != w {
+		t.Errorf("Current file: got %s want %s", g, w)
+	}
+}
+
+func TestFileExporterKeepsWritingWhenRotationFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileexporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.jsonl")
+
+	now := time.Unix(1560000000, 0)
+	fe, err := newFileExporter(path, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fe.now = func() time.Time { return now }
+	defer fe.Close()
+
+	// The file can't be moved onto a directory that isn't empty.
+	rotatedPath := func(seq int) string {
+		return fmt.Sprintf("%s.%s-%d", path, now.UTC().Format("20060102T150405"), seq)
+	}
+	if err := os.MkdirAll(filepath.Join(rotatedPath(1), "taken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fe.pushTraceData(context.Background(), traceDataWithSpans("a")); err != nil {
+		t.Fatalf("Failed to export a: %v", err)
+	}
+	dropped, err := fe.pushTraceData(context.Background(), traceDataWithSpans("b", "c"))
+	if err == nil || !strings.Contains(err.Error(), "rotating") {
+		t.Errorf("Got error %v, want a rotation error", err)
+	}
+	if dropped != 0 {
+		t.Errorf("Got %d spans dropped, want 0", dropped)
+	}
+	blob, _ := ioutil.ReadFile(path)
+	if g, w := fmt.Sprint(readSpanNames(t, blob)), "[a b c]"; g != w {
+		t.Errorf("Current file after the failed rotation: got %s want %s", g, w)
+	}
+
+	// The rotation is retried with the next batch.
+	if _, err := fe.pushTraceData(context.Background(), traceDataWithSpans("d")); err != nil {
+		t.Fatalf("Failed to export d: %v", err)
+	}
+	old, _ := ioutil.ReadFile(rotatedPath(2))
+	cur, _ := ioutil.ReadFile(path)
+	if g, w := fmt.Sprint(readSpanNames(t, old)), "[a b c]"; g != w {
+		t.Errorf("Rotated file: got %s want %s", g, w)
+	}
+	if g, w := fmt.Sprint(readSpanNames(t, cur)), "[d]"; g != w {
+		t.Errorf("Current file: got %s want %s", g, w)
+	}
+}
+
+func TestFileExporterConcurrentWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileexporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.jsonl")
+
+	fe, err := newFileExporter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fe.pushTraceData(context.Background(), traceDataWithSpans(fmt.Sprintf("s%d-0", i), fmt.Sprintf("s%d-1", i)))
+		}(i)
+	}
+	wg.Wait()
+	fe.Close()
+
+	blob, _ := ioutil.ReadFile(path)
+	if got := readSpanNames(t, blob); len(got) != 40 {
+		t.Errorf("Got %d records, want 40", len(got))
+	}
+	if _, err := fe.pushTraceData(context.Background(), traceDataWithSpans("late")); err == nil {
+		t.Error("Expected an error when exporting after Close")
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package honeycombexporter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// newBenchmarkHoneycomb returns a Honeycomb batch API accepting all the
+// events right away, doing the minimum work to reply with a status per event.
+func newBenchmarkHoneycomb() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			body = gz
+		}
+		var batch []json.RawMessage
+		if err := json.NewDecoder(body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		statuses := make([]map[string]int, len(batch))
+		for i := range statuses {
+			statuses[i] = map[string]int{"status": http.StatusAccepted}
+		}
+		json.NewEncoder(w).Encode(statuses)
+	}))
+}
+
+// benchmarkFlushEvery is the number of spans exported between two flushes,
+// kept low enough for the events not to overflow the pending work queue of
+// libhoney, which would drop them instead of sending them.
+const benchmarkFlushEvery = 1000
+
+func benchmarkSpan(numAttributes, numAnnotations int) *trace.SpanData {
+	start := time.Now()
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanID:  trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		},
+		ParentSpanID: trace.SpanID{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "GET /cart",
+		StartTime:    start,
+		EndTime:      start.Add(10 * time.Millisecond),
+	}
+	if numAttributes > 0 {
+		sd.Attributes = make(map[string]interface{}, numAttributes)
+		for i := 0; i < numAttributes; i++ {
+			sd.Attributes[fmt.Sprintf("attribute.%d", i)] = fmt.Sprintf("value-%d", i)
+		}
+	}
+	for i := 0; i < numAnnotations; i++ {
+		sd.Annotations = append(sd.Annotations, trace.Annotation{
+			Time:       start.Add(time.Duration(i) * time.Millisecond),
+			Message:    fmt.Sprintf("annotation %d", i),
+			Attributes: map[string]interface{}{"index": int64(i)},
+		})
+	}
+	return sd
+}
+
+func newBenchmarkExporter(b *testing.B) (*Exporter, func()) {
+	server := newBenchmarkHoneycomb()
+	exp, err := NewExporterWithConfig(ExporterConfig{
+		WriteKey: "bench",
+		Dataset:  "bench",
+		APIHost:  server.URL,
+	})
+	if err != nil {
+		server.Close()
+		b.Fatalf("NewExporterWithConfig() error = %v", err)
+	}
+	exp.ServiceName = "bench"
+	return exp, func() {
+		exp.Close()
+		server.Close()
+	}
+}
+
+// benchmarkExportSpan measures the export of sd, including the upload of the
+// events to the backend.
+func benchmarkExportSpan(b *testing.B, sd *trace.SpanData) {
+	exp, closeFn := newBenchmarkExporter(b)
+	defer closeFn()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 1; i <= b.N; i++ {
+		exp.ExportSpan(sd)
+		if i%benchmarkFlushEvery == 0 {
+			if err := exp.Flush(); err != nil {
+				b.Fatalf("Flush() error = %v", err)
+			}
+		}
+	}
+	if err := exp.Flush(); err != nil {
+		b.Fatalf("Flush() error = %v", err)
+	}
+}
+
+func BenchmarkExportSpan(b *testing.B) {
+	benchmarkExportSpan(b, benchmarkSpan(0, 0))
+}
+
+func BenchmarkExportSpanWithAttributes(b *testing.B) {
+	benchmarkExportSpan(b, benchmarkSpan(20, 0))
+}
+
+func BenchmarkExportSpanWithAnnotations(b *testing.B) {
+	benchmarkExportSpan(b, benchmarkSpan(0, 5))
+}
+
+func BenchmarkExportSpanParallel(b *testing.B) {
+	exp, closeFn := newBenchmarkExporter(b)
+	defer closeFn()
+	sd := benchmarkSpan(5, 1)
+	var exported int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			exp.ExportSpan(sd)
+			if atomic.AddInt64(&exported, 1)%benchmarkFlushEvery == 0 {
+				exp.Flush()
+			}
+		}
+	})
+	if err := exp.Flush(); err != nil {
+		b.Fatalf("Flush() error = %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package honeycombexporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The compressions of the uploaded batches of events.
+const (
+	// CompressionNone sends the batches uncompressed.
+	CompressionNone = "none"
+	// CompressionGzip compresses the batches with gzip.
+	CompressionGzip = "gzip"
+	// CompressionZstd compresses the batches with zstd.
+	CompressionZstd = "zstd"
+)
+
+func validateCompression(compression string) error {
+	switch compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("unknown compression %q, want one of %q, %q or %q",
+		compression, CompressionNone, CompressionGzip, CompressionZstd)
+}
+
+// compressingTransport compresses the body of the requests before passing
+// them to the underlying transport, and sets their Content-Encoding.
+type compressingTransport struct {
+	encoding  string
+	newWriter func(io.Writer) (io.WriteCloser, error)
+	base      http.RoundTripper
+}
+
+var _ http.RoundTripper = (*compressingTransport)(nil)
+
+// newCompressingTransport returns the base transport wrapped to compress the
+// request bodies, or base itself if no compression is done by the wrapper.
+func newCompressingTransport(compression string, base http.RoundTripper) http.RoundTripper {
+	switch compression {
+	case CompressionGzip:
+		return &compressingTransport{
+			encoding: "gzip",
+			newWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+			base: base,
+		}
+	case CompressionZstd:
+		return &compressingTransport{
+			encoding: "zstd",
+			newWriter: func(w io.Writer) (io.WriteCloser, error) {
+				return zstd.NewWriter(w)
+			},
+			base: base,
+		}
+	}
+	return base
+}
+
+func (ct *compressingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Header.Get("Content-Encoding") != "" {
+		return ct.base.RoundTrip(req)
+	}
+
+	var buf bytes.Buffer
+	err := ct.compress(&buf, req.Body)
+	// A RoundTripper must always close the body, including on errors.
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// A RoundTripper must not modify the request, so a copy is sent.
+	compressed := new(http.Request)
+	*compressed = *req
+	compressed.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		compressed.Header[k] = v
+	}
+	compressed.Header.Set("Content-Encoding", ct.encoding)
+
+	body := buf.Bytes()
+	compressed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	compressed.ContentLength = int64(len(body))
+	compressed.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return ct.base.RoundTrip(compressed)
+}
+
+func (ct *compressingTransport) compress(dst io.Writer, src io.Reader) error {
+	w, err := ct.newWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
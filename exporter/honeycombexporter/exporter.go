@@ -0,0 +1,701 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package honeycombexporter
+
+// The code in this file started as a copy of
+// https://github.com/honeycombio/opencensus-exporter/blob/v1.0.1/honeycomb/honeycomb.go
+// It lives here so that the service can configure the libhoney transport
+// (TLS, API host, etc.), which the upstream constructor does not allow, and
+// uses its own libhoney client so that multiple exporters, or other libhoney
+// users, do not share the global libhoney state.
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"go.opencensus.io/resource"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// Version is the version of the exporter reported to Honeycomb as part of the
+// user agent. It can be set at build time by passing
+// "-X github.com/census-instrumentation/opencensus-service/exporter/honeycombexporter.Version=<version>"
+// to -ldflags.
+var Version = "1.0.1"
+
+var userAgentOnce sync.Once
+
+// initUserAgent sets the libhoney user agent addition, only the first call
+// has an effect so that creating multiple exporters does not race on it.
+func initUserAgent() {
+	userAgentOnce.Do(func() {
+		libhoney.UserAgentAddition = "Honeycomb-OpenCensus-exporter/" + Version
+	})
+}
+
+// Exporter is an implementation of trace.Exporter that uploads a span to Honeycomb.
+type Exporter struct {
+	Builder *libhoney.Builder
+	// SampleFraction is the fraction of the spans sent, between 0 (none) and
+	// 1 (all of them). Use Validate to check it is in range.
+	SampleFraction float64
+	// ServiceName identifies your application. While optional, setting this
+	// field is extremely valuable when you instrument multiple services. If set
+	// it will be added to all events as `service_name`.
+	ServiceName string
+	// Resource, if non-nil, has its labels added to all the events. They do
+	// not override the fields set by the exporter, e.g. `service_name`, nor
+	// the attributes of the spans.
+	Resource *resource.Resource
+	// BatchAnnotations, if true, stops annotations and message events from
+	// being sent as separate events: they are only exported as part of the
+	// `annotations` field of the span event. This means a single event, and
+	// thus a single transmission, per span.
+	BatchAnnotations bool
+	// KeyMapping renames span and annotation attribute keys before they are
+	// added to the events, keys that are not in the map are kept as is.
+	// Use Validate to check that no reserved field is renamed.
+	KeyMapping map[string]string
+	// SamplerFunc, if non-nil, overrides SampleFraction on a per span basis,
+	// e.g. to keep all the error spans but only a fraction of the others.
+	// Spans for which it returns 0 (or less) are not sent, the fractions
+	// above 1 are taken as 1.
+	SamplerFunc func(*trace.SpanData) float64
+	// ExportLinks sends the links of a span as separate events, tied to the
+	// span by `trace.trace_id` and `trace.parent_id`. It defaults to true.
+	ExportLinks bool
+	// Retry configures how the events rejected by Honeycomb with a 429 or a
+	// 5xx status code are sent again, retries are disabled by default. It
+	// must be set before the first span is exported.
+	Retry RetryConfig
+
+	client *libhoney.Client
+	// mu prevents events from being added while the transmission is flushed.
+	mu          sync.RWMutex
+	responses   *responseReader
+	debugLogger *log.Logger
+
+	shutdownOnce sync.Once
+	shutdownDone chan struct{}
+	shutdownErr  error
+}
+
+// reservedFields are the fields set by the exporter itself, attributes can
+// neither be renamed from nor to them.
+var reservedFields = map[string]bool{
+	"trace.trace_id":  true,
+	"trace.span_id":   true,
+	"trace.parent_id": true,
+	"name":            true,
+	"duration_ms":     true,
+	"timestamp":       true,
+	"annotations":     true,
+	"service_name":    true,
+	"status.code":     true,
+	"status.message":  true,
+	"meta.span_type":  true,
+	"span_kind":       true,
+	// Only set on the events of span links.
+	"trace.link_trace_id": true,
+	"trace.link_span_id":  true,
+	"link_type":           true,
+}
+
+// Validate returns an error if the sample fraction is out of range or the
+// configuration of the exporter would overwrite the fields it sets itself.
+func (e *Exporter) Validate() error {
+	var errs []error
+	if !(e.SampleFraction >= 0 && e.SampleFraction <= 1) {
+		errs = append(errs, fmt.Errorf("the sample fraction must be between 0 and 1, got %v", e.SampleFraction))
+	}
+	for from, to := range e.KeyMapping {
+		if reservedFields[from] {
+			errs = append(errs, fmt.Errorf("cannot rename the reserved field %q", from))
+		}
+		if reservedFields[to] {
+			errs = append(errs, fmt.Errorf("cannot rename %q to the reserved field %q", from, to))
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// sampleRate returns the Honeycomb sample rate, one event sent for that many,
+// of a positive sample fraction. The fractions above 1 send every event, and
+// the tiny ones are capped to the largest rate instead of overflowing.
+func sampleRate(sampleFraction float64) uint {
+	if sampleFraction >= 1 {
+		return 1
+	}
+	rate := 1 / sampleFraction
+	if rate > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint(rate)
+}
+
+// mapKey returns the name of the field an attribute is exported as.
+func (e *Exporter) mapKey(key string) string {
+	if mapped, ok := e.KeyMapping[key]; ok {
+		return mapped
+	}
+	return key
+}
+
+// ExporterConfig holds the settings used to create an Exporter via
+// NewExporterWithConfig.
+type ExporterConfig struct {
+	// WriteKey is the Honeycomb write key (also known as the API key).
+	WriteKey string
+	// Dataset is the name of the Honeycomb dataset to send trace events to.
+	Dataset string
+	// APIHost overrides the Honeycomb API endpoint, e.g. to go through a proxy.
+	// If empty the libhoney default is used.
+	APIHost string
+	// TLSConfig, if non-nil, is used by the HTTP transport that uploads the
+	// events, e.g. to present a client certificate for mutual TLS.
+	TLSConfig *tls.Config
+	// Compression of the uploaded batches of events, one of CompressionNone,
+	// CompressionGzip or CompressionZstd. If empty the libhoney default,
+	// gzip, is used.
+	Compression string
+	// PendingWorkCapacity is the number of events that can be queued before
+	// new ones are dropped. If 0 the libhoney default is used.
+	PendingWorkCapacity uint
+	// Transport configures the pool of the connections to the API.
+	Transport TransportConfig
+	// Transmission overrides the libhoney sender used to upload the events.
+	// It takes precedence over TLSConfig, Compression, PendingWorkCapacity and
+	// Transport and is mostly useful for tests.
+	Transmission transmission.Sender
+}
+
+// TransportConfig configures the pool of the connections used to upload the
+// events. Behind load balancers that close the idle connections, e.g. after
+// 60s for an AWS ALB, IdleConnTimeout should be lower than their timeout.
+type TransportConfig struct {
+	// IdleConnTimeout is how long an idle connection is kept open. It
+	// defaults to 90s.
+	IdleConnTimeout time.Duration
+	// MaxIdleConns is the maximum number of idle connections. It defaults
+	// to 100.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections to the
+	// API host. It defaults to 2.
+	MaxIdleConnsPerHost int
+}
+
+// Annotation represents an annotation with a value and a timestamp.
+type Annotation struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     string    `json:"value"`
+}
+
+// Span is the format of trace events that Honeycomb accepts.
+type Span struct {
+	TraceID     string       `json:"trace.trace_id"`
+	Name        string       `json:"name"`
+	ID          string       `json:"trace.span_id"`
+	ParentID    string       `json:"trace.parent_id,omitempty"`
+	DurationMs  float64      `json:"duration_ms"`
+	SpanKind    string       `json:"span_kind"`
+	Timestamp   time.Time    `json:"timestamp,omitempty"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+// Close waits for all in-flight messages to be sent. You should
+// call Close() before app termination. Events waiting to be retried are
+// dropped, call Flush first to wait for them.
+func (e *Exporter) Close() {
+	e.responses.close()
+}
+
+// Shutdown flushes the pending events and closes the exporter, like Close,
+// but stops waiting once ctx is done, in which case ctx.Err() is returned and
+// the events are still sent in the background. This makes the exporter usable
+// in process exit hooks that have a hard deadline. Calling Shutdown again
+// waits for the same shutdown to complete.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.shutdownOnce.Do(func() {
+		e.shutdownDone = make(chan struct{})
+		go func() {
+			defer close(e.shutdownDone)
+			e.shutdownErr = e.Flush()
+			e.Close()
+		}()
+	})
+
+	select {
+	case <-e.shutdownDone:
+		return e.shutdownErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush blocks until all the pending events have been sent to Honeycomb.
+// Unlike Close, the exporter can still be used after Flush returns. The
+// returned error combines all the failed transmissions that were reported
+// since the responses were last read. When retries are enabled, Flush also
+// waits for the events to be retried.
+func (e *Exporter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.responses.isStarted() {
+		e.responses.flush()
+		return e.responses.wait()
+	}
+	e.client.Flush()
+	return drainResponses(e.client.TxResponses())
+}
+
+// drainResponses reads all the responses currently queued and returns the
+// combination of the errors they carry.
+func drainResponses(responses chan transmission.Response) error {
+	var errs []error
+	for {
+		select {
+		case resp := <-responses:
+			if err := responseToError(resp); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			return internal.CombineErrors(errs)
+		}
+	}
+}
+
+func responseToError(resp transmission.Response) error {
+	if resp.Err != nil {
+		return resp.Err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("honeycomb returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(resp.Body)))
+	}
+	return nil
+}
+
+// Option configures optional settings of an Exporter.
+type Option func(*Exporter)
+
+// WithServiceVersion adds the version of your application to all the events
+// as `service_version`.
+func WithServiceVersion(version string) Option {
+	return func(e *Exporter) {
+		e.Builder.AddField("service_version", version)
+	}
+}
+
+// WithHostname adds the name of the host running your application to all the
+// events as `host_name`.
+func WithHostname(host string) Option {
+	return func(e *Exporter) {
+		e.Builder.AddField("host_name", host)
+	}
+}
+
+// WithDebugResponseLogger logs the failed transmissions, with their status
+// code and the error returned by Honeycomb, to logger. The responses are read
+// in the background until the exporter is closed. The errors are still
+// returned by Flush.
+func WithDebugResponseLogger(logger *log.Logger) Option {
+	return func(e *Exporter) {
+		e.debugLogger = logger
+		e.responses.start(e)
+	}
+}
+
+// NewExporter returns an implementation of trace.Exporter that uploads spans to Honeycomb.
+//
+// writeKey is your Honeycomb writeKey (also known as your API key)
+// dataset is the name of your Honeycomb dataset to send trace events to
+func NewExporter(writeKey, dataset string, opts ...Option) (*Exporter, error) {
+	return NewExporterWithConfig(ExporterConfig{
+		WriteKey: writeKey,
+		Dataset:  dataset,
+	}, opts...)
+}
+
+// Environment variables read by NewExporterFromEnv.
+const (
+	EnvWriteKey       = "HONEYCOMB_WRITE_KEY"
+	EnvDataset        = "HONEYCOMB_DATASET"
+	EnvAPIHost        = "HONEYCOMB_API_HOST"
+	EnvSampleFraction = "HONEYCOMB_SAMPLE_FRACTION"
+)
+
+// NewExporterFromEnv is like NewExporter but reads the write key and the
+// dataset from the HONEYCOMB_WRITE_KEY and HONEYCOMB_DATASET environment
+// variables, e.g. mounted from a Kubernetes secret. HONEYCOMB_API_HOST and
+// HONEYCOMB_SAMPLE_FRACTION are optional and override the API host and the
+// SampleFraction of the exporter.
+func NewExporterFromEnv(opts ...Option) (*Exporter, error) {
+	cfg := ExporterConfig{
+		WriteKey: os.Getenv(EnvWriteKey),
+		Dataset:  os.Getenv(EnvDataset),
+		APIHost:  os.Getenv(EnvAPIHost),
+	}
+	if cfg.WriteKey == "" {
+		return nil, fmt.Errorf("the %s environment variable is not set", EnvWriteKey)
+	}
+	if cfg.Dataset == "" {
+		return nil, fmt.Errorf("the %s environment variable is not set", EnvDataset)
+	}
+
+	sampleFraction := 1.0
+	if raw := os.Getenv(EnvSampleFraction); raw != "" {
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("%s must be a number between 0 and 1, got %q", EnvSampleFraction, raw)
+		}
+		sampleFraction = f
+	}
+
+	e, err := NewExporterWithConfig(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	e.SampleFraction = sampleFraction
+	return e, nil
+}
+
+// NewExporterWithConfig is like NewExporter but allows the API host and the
+// transport used to upload the events to be configured.
+func NewExporterWithConfig(cfg ExporterConfig, opts ...Option) (*Exporter, error) {
+	initUserAgent()
+
+	if err := validateCompression(cfg.Compression); err != nil {
+		return nil, err
+	}
+	client, err := libhoney.NewClient(libhoney.ClientConfig{
+		APIKey:       cfg.WriteKey,
+		Dataset:      cfg.Dataset,
+		APIHost:      cfg.APIHost,
+		Transmission: newTransmission(cfg),
+	})
+	if err != nil {
+		return nil, err
+	}
+	builder := client.NewBuilder()
+	// default sample rate is 1: aka no sampling.
+	// set sampleRate on the exporter to be the sample rate given to the
+	// ProbabilitySampler if used.
+	e := &Exporter{
+		Builder:        builder,
+		SampleFraction: 1,
+		ServiceName:    "",
+		ExportLinks:    true,
+		client:         client,
+		responses:      newResponseReader(client),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// newTransmission returns the libhoney sender to be used for the given
+// configuration, nil means that the libhoney default should be used.
+func newTransmission(cfg ExporterConfig) transmission.Sender {
+	if cfg.Transmission != nil {
+		return cfg.Transmission
+	}
+	if cfg.TLSConfig == nil && cfg.Compression == "" && cfg.PendingWorkCapacity == 0 &&
+		cfg.Transport == (TransportConfig{}) {
+		return nil
+	}
+
+	idleConnTimeout := cfg.Transport.IdleConnTimeout
+	if idleConnTimeout <= 0 {
+		idleConnTimeout = 90 * time.Second
+	}
+	maxIdleConns := cfg.Transport.MaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = 100
+	}
+	// Same settings as http.DefaultTransport, plus the given TLS and
+	// connection pool configuration.
+	var transport http.RoundTripper = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   cfg.Transport.MaxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       cfg.TLSConfig,
+	}
+	pendingWorkCapacity := cfg.PendingWorkCapacity
+	if pendingWorkCapacity == 0 {
+		pendingWorkCapacity = libhoney.DefaultPendingWorkCapacity
+	}
+	return &transmission.Honeycomb{
+		MaxBatchSize:         libhoney.DefaultMaxBatchSize,
+		BatchTimeout:         libhoney.DefaultBatchTimeout,
+		MaxConcurrentBatches: libhoney.DefaultMaxConcurrentBatches,
+		PendingWorkCapacity:  pendingWorkCapacity,
+		UserAgentAddition:    libhoney.UserAgentAddition,
+		Transport:            newCompressingTransport(cfg.Compression, transport),
+		// An explicit compression, or its absence, is applied by the transport.
+		DisableGzipCompression: cfg.Compression != "",
+	}
+}
+
+// ExportSpan exports a span to Honeycomb
+func (e *Exporter) ExportSpan(sd *trace.SpanData) {
+	e.ExportSpanWithContext(context.Background(), sd)
+}
+
+// ExportSpanWithContext is like ExportSpan but stops sending the events of the
+// span as soon as ctx is done, in which case ctx.Err() is returned. This allows
+// callers to abandon in-flight exports, e.g. during a graceful shutdown.
+func (e *Exporter) ExportSpanWithContext(ctx context.Context, sd *trace.SpanData) error {
+	sampleFraction := e.SampleFraction
+	if e.SamplerFunc != nil {
+		sampleFraction = e.SamplerFunc(sd)
+	}
+	// A sample fraction of zero (or less) means "send nothing".
+	if !(sampleFraction > 0) {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ev := e.newEvent()
+	if sd.StartTime != (time.Time{}) {
+		ev.Timestamp = sd.StartTime
+	}
+	hs := honeycombSpan(sd)
+	ev.Add(hs)
+
+	if !e.BatchAnnotations {
+		if err := e.sendSpanEvents(ctx, sd, hs); err != nil {
+			return err
+		}
+	}
+	if e.ExportLinks {
+		if err := e.sendLinks(ctx, sd, hs); err != nil {
+			return err
+		}
+	}
+
+	ev.SampleRate = sampleRate(sampleFraction)
+	if e.ServiceName != "" {
+		ev.AddField("service_name", e.ServiceName)
+	}
+	ev.AddField("status.code", sd.Status.Code)
+	ev.AddField("status.message", sd.Status.Message)
+	for k, v := range sd.Attributes {
+		ev.AddField(e.mapKey(k), v)
+	}
+	return e.sendWithContext(ctx, ev)
+}
+
+// sendSpanEvents sends the annotations and message events of the span as
+// 0 duration spans.
+func (e *Exporter) sendSpanEvents(ctx context.Context, sd *trace.SpanData, hs Span) error {
+	for _, a := range sd.Annotations {
+		spanEv := e.newSpanEvent(hs, a.Time, a.Message, "span_event")
+		for k, v := range a.Attributes {
+			spanEv.AddField(e.mapKey(k), v)
+		}
+		if err := e.sendWithContext(ctx, spanEv); err != nil {
+			return err
+		}
+	}
+	for _, m := range sd.MessageEvents {
+		eventType := messageEventTypeString(m.EventType)
+		spanEv := e.newSpanEvent(hs, m.Time, eventType, "message_event")
+		spanEv.AddField("message_event_type", eventType)
+		spanEv.AddField("message_id", m.MessageID)
+		spanEv.AddField("uncompressed_byte_size", m.UncompressedByteSize)
+		spanEv.AddField("compressed_byte_size", m.CompressedByteSize)
+		if err := e.sendWithContext(ctx, spanEv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendLinks sends the links of the span as 0 duration spans holding the
+// linked trace and span IDs.
+func (e *Exporter) sendLinks(ctx context.Context, sd *trace.SpanData, hs Span) error {
+	for _, l := range sd.Links {
+		linkEv := e.newSpanEvent(hs, sd.StartTime, "link", "link")
+		linkEv.AddField("trace.link_trace_id", getHoneycombTraceID(l.TraceID[:]))
+		linkEv.AddField("trace.link_span_id", l.SpanID.String())
+		linkEv.AddField("link_type", linkTypeString(l.Type))
+		for k, v := range l.Attributes {
+			linkEv.AddField(e.mapKey(k), v)
+		}
+		if err := e.sendWithContext(ctx, linkEv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendWithContext sends the already sampled event unless ctx is done.
+func (e *Exporter) sendWithContext(ctx context.Context, ev *libhoney.Event) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if e.Retry.enabled() {
+		e.responses.start(e)
+	}
+	if e.responses.isStarted() {
+		return e.responses.send(ev)
+	}
+	return ev.SendPresampled()
+}
+
+// newEvent returns an event holding the resource labels, which must be added
+// before any other field so that they have the lowest priority.
+func (e *Exporter) newEvent() *libhoney.Event {
+	ev := e.Builder.NewEvent()
+	if e.Resource != nil {
+		for k, v := range e.Resource.Labels {
+			ev.AddField(k, v)
+		}
+	}
+	return ev
+}
+
+// newSpanEvent returns an event that is linked to the given span, it is used
+// to export the annotations, message events and links of the span.
+func (e *Exporter) newSpanEvent(hs Span, timestamp time.Time, name, spanType string) *libhoney.Event {
+	spanEv := e.newEvent()
+	if e.ServiceName != "" {
+		spanEv.AddField("service_name", e.ServiceName)
+	}
+	spanEv.Timestamp = timestamp
+	spanEv.AddField("trace.trace_id", hs.TraceID)
+	spanEv.AddField("trace.parent_id", hs.ID)
+	spanEv.AddField("name", name)
+	spanEv.AddField("duration_ms", 0)
+	spanEv.AddField("meta.span_type", spanType)
+	return spanEv
+}
+
+func messageEventTypeString(t trace.MessageEventType) string {
+	switch t {
+	case trace.MessageEventTypeSent:
+		return "SENT"
+	case trace.MessageEventTypeRecv:
+		return "RECEIVED"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+func linkTypeString(t trace.LinkType) string {
+	switch t {
+	case trace.LinkTypeParent:
+		return "PARENT"
+	case trace.LinkTypeChild:
+		return "CHILD"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+func spanKindString(kind int) string {
+	switch kind {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+func honeycombSpan(s *trace.SpanData) Span {
+	sc := s.SpanContext
+	hcSpan := Span{
+		TraceID:   getHoneycombTraceID(sc.TraceID[:]),
+		ID:        sc.SpanID.String(),
+		Name:      s.Name,
+		SpanKind:  spanKindString(s.SpanKind),
+		Timestamp: s.StartTime,
+	}
+	if s.ParentSpanID != (trace.SpanID{}) {
+		hcSpan.ParentID = s.ParentSpanID.String()
+	}
+
+	if s, e := s.StartTime, s.EndTime; !s.IsZero() && !e.IsZero() {
+		hcSpan.DurationMs = float64(e.Sub(s)) / float64(time.Millisecond)
+	}
+
+	if len(s.Annotations) != 0 || len(s.MessageEvents) != 0 {
+		hcSpan.Annotations = make([]Annotation, 0, len(s.Annotations)+len(s.MessageEvents))
+		for _, a := range s.Annotations {
+			hcSpan.Annotations = append(hcSpan.Annotations, Annotation{
+				Timestamp: a.Time,
+				Value:     a.Message,
+			})
+		}
+		for _, m := range s.MessageEvents {
+			hcSpan.Annotations = append(hcSpan.Annotations, Annotation{
+				Timestamp: m.Time,
+				Value:     messageEventTypeString(m.EventType),
+			})
+		}
+	}
+	return hcSpan
+}
+
+// getHoneycombTraceID returns a trace ID suitable for use in honeycomb. Before
+// encoding the bytes as a hex string, we want to handle cases where we are
+// given 128-bit IDs with zero padding, e.g. 0000000000000000f798a1e7f33c8af6.
+// To do this, we borrow a strategy from Jaeger [1] wherein we split the byte
+// sequence into two parts. The leftmost part could contain all zeros. We use
+// that to determine whether to return a 64-bit hex encoded string or a 128-bit
+// one.
+//
+// [1]: https://github.com/jaegertracing/jaeger-client-go/blob/master/trace_id.go#L47
+func getHoneycombTraceID(traceID []byte) string {
+	if len(traceID) < 16 {
+		return hex.EncodeToString(traceID)
+	}
+	// Check if the leftmost 8 bytes are all zeros.
+	for _, b := range traceID[:8] {
+		if b != 0 {
+			return hex.EncodeToString(traceID)
+		}
+	}
+	return hex.EncodeToString(traceID[8:16])
+}
@@ -18,7 +18,12 @@ package honeycombexporter
 // ask them to make an exporter that uses OpenCensus-Proto instead of OpenCensus-Go.
 
 import (
-	"github.com/honeycombio/opencensus-exporter/honeycomb"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
 	"github.com/spf13/viper"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
@@ -26,8 +31,76 @@ import (
 )
 
 type honeycombConfig struct {
-	WriteKey    string `mapstructure:"write_key"`
-	DatasetName string `mapstructure:"dataset_name"`
+	WriteKey    string              `mapstructure:"write_key"`
+	DatasetName string              `mapstructure:"dataset_name"`
+	APIHost     string              `mapstructure:"api_host,omitempty"`
+	TLS         *honeycombTLSConfig `mapstructure:"tls,omitempty"`
+	// BatchAnnotations sends the annotations and message events of a span as
+	// part of the span event instead of as separate events.
+	BatchAnnotations bool `mapstructure:"batch_annotations,omitempty"`
+	// KeyMapping renames attribute keys to the given Honeycomb field names.
+	KeyMapping map[string]string `mapstructure:"key_mapping,omitempty"`
+	// ExportLinks, if false, stops the links of a span from being sent as
+	// separate events. It defaults to true.
+	ExportLinks *bool `mapstructure:"export_links,omitempty"`
+	// Compression of the uploaded events: "none", "gzip" or "zstd". It
+	// defaults to gzip.
+	Compression string `mapstructure:"compression,omitempty"`
+	// PendingWorkCapacity is the number of events queued, waiting to be
+	// uploaded, before new ones are dropped.
+	PendingWorkCapacity uint `mapstructure:"pending_work_capacity,omitempty"`
+	// Transport configures the pool of the connections to the API.
+	Transport *honeycombTransportConfig `mapstructure:"transport,omitempty"`
+}
+
+// honeycombTransportConfig holds the settings of the connection pool, see
+// TransportConfig.
+type honeycombTransportConfig struct {
+	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout,omitempty"`
+	MaxIdleConns        int           `mapstructure:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host,omitempty"`
+}
+
+// honeycombTLSConfig holds the client side TLS settings used when talking to
+// the Honeycomb API, e.g. through a proxy that requires mutual TLS.
+type honeycombTLSConfig struct {
+	// CAFile is the file path containing the CA certificates used to verify the server.
+	CAFile string `mapstructure:"ca_file,omitempty"`
+	// CertFile is the file path containing the client TLS certificate.
+	CertFile string `mapstructure:"cert_file,omitempty"`
+	// KeyFile is the file path containing the client TLS key.
+	KeyFile string `mapstructure:"key_file,omitempty"`
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify,omitempty"`
+}
+
+// toTLSConfig loads the files referenced by the configuration and returns the
+// equivalent tls.Config.
+func (htc *honeycombTLSConfig) toTLSConfig() (*tls.Config, error) {
+	if htc == nil {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{InsecureSkipVerify: htc.InsecureSkipVerify}
+	if htc.CAFile != "" {
+		caPEM, err := ioutil.ReadFile(htc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA file %q: %v", htc.CAFile, err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("failed to parse the CA file %q", htc.CAFile)
+		}
+		tlsCfg.RootCAs = certPool
+	}
+	if htc.CertFile != "" || htc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(htc.CertFile, htc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %v", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
 }
 
 // HoneycombTraceExportersFromViper unmarshals the viper and returns an exporter.TraceExporter
@@ -45,7 +118,42 @@ func HoneycombTraceExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsu
 		return nil, nil, nil, nil
 	}
 
-	rawExp := honeycomb.NewExporter(hc.WriteKey, hc.DatasetName)
+	tlsCfg, err := hc.TLS.toTLSConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var transportCfg TransportConfig
+	if hc.Transport != nil {
+		transportCfg = TransportConfig{
+			IdleConnTimeout:     hc.Transport.IdleConnTimeout,
+			MaxIdleConns:        hc.Transport.MaxIdleConns,
+			MaxIdleConnsPerHost: hc.Transport.MaxIdleConnsPerHost,
+		}
+	}
+
+	rawExp, err := NewExporterWithConfig(ExporterConfig{
+		WriteKey:  hc.WriteKey,
+		Dataset:   hc.DatasetName,
+		APIHost:   hc.APIHost,
+		TLSConfig: tlsCfg,
+
+		Compression:         hc.Compression,
+		PendingWorkCapacity: hc.PendingWorkCapacity,
+		Transport:           transportCfg,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	rawExp.BatchAnnotations = hc.BatchAnnotations
+	rawExp.KeyMapping = hc.KeyMapping
+	if hc.ExportLinks != nil {
+		rawExp.ExportLinks = *hc.ExportLinks
+	}
+	if err := rawExp.Validate(); err != nil {
+		rawExp.Close()
+		return nil, nil, nil, err
+	}
 
 	hcte, err := exporterwrapper.NewExporterWrapper("honeycomb", "ocservice.exporter.HoneyComb.ConsumeTraceData", rawExp)
 	if err != nil {
@@ -14,4 +14,1271 @@
 
 package honeycombexporter
 
-// TODO: Add tests.
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+	"github.com/klauspost/compress/zstd"
+	"go.opencensus.io/resource"
+	"go.opencensus.io/trace"
+)
+
+func newTestExporter(t *testing.T, cfg ExporterConfig, opts ...Option) *Exporter {
+	exp, err := NewExporterWithConfig(cfg, opts...)
+	if err != nil {
+		t.Fatalf("NewExporterWithConfig() error = %v", err)
+	}
+	return exp
+}
+
+func TestNewTransmissionDefault(t *testing.T) {
+	if got := newTransmission(ExporterConfig{WriteKey: "key", Dataset: "dataset"}); got != nil {
+		t.Fatalf("newTransmission() = %v, want nil to use the libhoney default", got)
+	}
+}
+
+func TestNewTransmissionForwardsTLSConfig(t *testing.T) {
+	tlsCfg := &tls.Config{ServerName: "honeycomb.test"}
+	sender := newTransmission(ExporterConfig{TLSConfig: tlsCfg})
+
+	hc, ok := sender.(*transmission.Honeycomb)
+	if !ok {
+		t.Fatalf("newTransmission() returned %T, want *transmission.Honeycomb", sender)
+	}
+	transport, ok := hc.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport is %T, want *http.Transport", hc.Transport)
+	}
+	if transport.TLSClientConfig != tlsCfg {
+		t.Errorf("TLSClientConfig = %v, want %v", transport.TLSClientConfig, tlsCfg)
+	}
+}
+
+func TestNewTransmissionPrefersExplicitSender(t *testing.T) {
+	mock := &transmission.MockSender{}
+	got := newTransmission(ExporterConfig{
+		TLSConfig:    &tls.Config{},
+		Transmission: mock,
+	})
+	if got != mock {
+		t.Fatalf("newTransmission() = %v, want the explicit sender", got)
+	}
+}
+
+func TestNewTransmissionTransportConfig(t *testing.T) {
+	sender := newTransmission(ExporterConfig{Transport: TransportConfig{
+		IdleConnTimeout:     50 * time.Second,
+		MaxIdleConns:        20,
+		MaxIdleConnsPerHost: 10,
+	}})
+
+	hc, ok := sender.(*transmission.Honeycomb)
+	if !ok {
+		t.Fatalf("newTransmission() returned %T, want *transmission.Honeycomb", sender)
+	}
+	transport, ok := hc.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport is %T, want *http.Transport", hc.Transport)
+	}
+	if transport.IdleConnTimeout != 50*time.Second {
+		t.Errorf("IdleConnTimeout = %v, want 50s", transport.IdleConnTimeout)
+	}
+	if transport.MaxIdleConns != 20 {
+		t.Errorf("MaxIdleConns = %d, want 20", transport.MaxIdleConns)
+	}
+	if transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 10", transport.MaxIdleConnsPerHost)
+	}
+}
+
+func TestNewExporterFromEnv(t *testing.T) {
+	// setEnv sets exactly the given Honeycomb variables and returns a function
+	// restoring their previous values.
+	setEnv := func(env map[string]string) func() {
+		var restore []func()
+		for _, key := range []string{EnvWriteKey, EnvDataset, EnvAPIHost, EnvSampleFraction} {
+			key := key
+			if old, ok := os.LookupEnv(key); ok {
+				restore = append(restore, func() { os.Setenv(key, old) })
+			} else {
+				restore = append(restore, func() { os.Unsetenv(key) })
+			}
+			if value, ok := env[key]; ok {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		return func() {
+			for _, fn := range restore {
+				fn()
+			}
+		}
+	}
+
+	tests := []struct {
+		name               string
+		env                map[string]string
+		wantErr            string
+		wantSampleFraction float64
+	}{
+		{
+			name:    "missing_write_key",
+			env:     map[string]string{EnvDataset: "dataset"},
+			wantErr: EnvWriteKey,
+		},
+		{
+			name:    "missing_dataset",
+			env:     map[string]string{EnvWriteKey: "key"},
+			wantErr: EnvDataset,
+		},
+		{
+			name:    "invalid_sample_fraction",
+			env:     map[string]string{EnvWriteKey: "key", EnvDataset: "dataset", EnvSampleFraction: "half"},
+			wantErr: EnvSampleFraction,
+		},
+		{
+			name:    "out_of_range_sample_fraction",
+			env:     map[string]string{EnvWriteKey: "key", EnvDataset: "dataset", EnvSampleFraction: "2"},
+			wantErr: EnvSampleFraction,
+		},
+		{
+			name:               "defaults",
+			env:                map[string]string{EnvWriteKey: "key", EnvDataset: "dataset"},
+			wantSampleFraction: 1,
+		},
+		{
+			name: "all_set",
+			env: map[string]string{
+				EnvWriteKey:       "key",
+				EnvDataset:        "dataset",
+				EnvAPIHost:        "http://localhost:8080",
+				EnvSampleFraction: "0.1",
+			},
+			wantSampleFraction: 0.1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(tt.env)()
+			exp, err := NewExporterFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewExporterFromEnv() error = %v, want it to mention %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewExporterFromEnv() error = %v", err)
+			}
+			defer exp.Close()
+			if exp.SampleFraction != tt.wantSampleFraction {
+				t.Errorf("SampleFraction = %v, want %v", exp.SampleFraction, tt.wantSampleFraction)
+			}
+		})
+	}
+}
+
+func TestTLSConfigMissingFiles(t *testing.T) {
+	htc := &honeycombTLSConfig{CertFile: "does-not-exist.crt", KeyFile: "does-not-exist.key"}
+	if _, err := htc.toTLSConfig(); err == nil {
+		t.Fatal("toTLSConfig() succeeded with missing files, want an error")
+	}
+
+	var nilCfg *honeycombTLSConfig
+	tlsCfg, err := nilCfg.toTLSConfig()
+	if err != nil || tlsCfg != nil {
+		t.Fatalf("toTLSConfig() on nil = (%v, %v), want (nil, nil)", tlsCfg, err)
+	}
+}
+
+func TestExportSpan(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey:     "key",
+		Dataset:      "dataset",
+		Transmission: mock,
+	})
+	defer exp.Close()
+	exp.ServiceName = "honeycomb-test"
+
+	start := time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)
+	exp.ExportSpan(&trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanID:  trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		},
+		Name:       "span",
+		StartTime:  start,
+		EndTime:    start.Add(15 * time.Millisecond),
+		Attributes: map[string]interface{}{"http.method": "GET"},
+	})
+
+	events := mock.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+	wantFields := map[string]interface{}{
+		"trace.trace_id": "0102030405060708090a0b0c0d0e0f10",
+		"trace.span_id":  "1112131415161718",
+		"name":           "span",
+		"duration_ms":    float64(15),
+		"service_name":   "honeycomb-test",
+		"span_kind":      "UNSPECIFIED",
+		"http.method":    "GET",
+	}
+	for k, want := range wantFields {
+		if got := ev.Data[k]; got != want {
+			t.Errorf("field %q = %v, want %v", k, got, want)
+		}
+	}
+	if !ev.Timestamp.Equal(start) {
+		t.Errorf("Timestamp = %v, want %v", ev.Timestamp, start)
+	}
+}
+
+func TestExportersDoNotShareState(t *testing.T) {
+	first, second := &transmission.MockSender{}, &transmission.MockSender{}
+	firstExp := newTestExporter(t, ExporterConfig{Transmission: first})
+	defer firstExp.Close()
+	secondExp := newTestExporter(t, ExporterConfig{Transmission: second})
+	defer secondExp.Close()
+
+	firstExp.ExportSpan(&trace.SpanData{Name: "first"})
+	secondExp.ExportSpan(&trace.SpanData{Name: "second"})
+
+	for _, tt := range []struct {
+		sender *transmission.MockSender
+		want   string
+	}{{first, "first"}, {second, "second"}} {
+		events := tt.sender.Events()
+		if len(events) != 1 || events[0].Data["name"] != tt.want {
+			t.Errorf("got events %v, want only the %q span", events, tt.want)
+		}
+	}
+}
+
+func TestExportSpanResourceLabels(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+	exp.ServiceName = "checkout"
+	exp.Resource = &resource.Resource{
+		Type: "k8s",
+		Labels: map[string]string{
+			"k8s.pod.name": "checkout-5d8f",
+			"service_name": "from-resource",
+			"http.method":  "from-resource",
+		},
+	}
+
+	exp.ExportSpan(&trace.SpanData{
+		Name:        "span",
+		Attributes:  map[string]interface{}{"http.method": "GET"},
+		Annotations: []trace.Annotation{{Message: "annotation"}},
+	})
+
+	events := mock.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for i, ev := range events {
+		if got := ev.Data["k8s.pod.name"]; got != "checkout-5d8f" {
+			t.Errorf("event #%d: k8s.pod.name = %v, want checkout-5d8f", i, got)
+		}
+		if got := ev.Data["service_name"]; got != "checkout" {
+			t.Errorf("event #%d: service_name = %v, want the ServiceName to take precedence", i, got)
+		}
+	}
+	if got := events[1].Data["http.method"]; got != "GET" {
+		t.Errorf("http.method = %v, want the span attribute to take precedence", got)
+	}
+}
+
+func TestHoneycombSpanKind(t *testing.T) {
+	tests := []struct {
+		kind int
+		want string
+	}{
+		{kind: trace.SpanKindUnspecified, want: "UNSPECIFIED"},
+		{kind: trace.SpanKindServer, want: "SERVER"},
+		{kind: trace.SpanKindClient, want: "CLIENT"},
+	}
+	for _, tt := range tests {
+		if got := honeycombSpan(&trace.SpanData{SpanKind: tt.kind}).SpanKind; got != tt.want {
+			t.Errorf("SpanKind %d: span_kind = %q, want %q", tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestGetHoneycombTraceID(t *testing.T) {
+	tests := []struct {
+		traceID []byte
+		want    string
+	}{
+		{
+			traceID: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf7, 0x98, 0xa1, 0xe7, 0xf3, 0x3c, 0x8a, 0xf6},
+			want:    "f798a1e7f33c8af6",
+		},
+		{
+			traceID: []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf7, 0x98, 0xa1, 0xe7, 0xf3, 0x3c, 0x8a, 0xf6},
+			want:    "0100000000000000f798a1e7f33c8af6",
+		},
+	}
+	for _, tt := range tests {
+		if got := getHoneycombTraceID(tt.traceID); got != tt.want {
+			t.Errorf("getHoneycombTraceID(%x) = %q, want %q", tt.traceID, got, tt.want)
+		}
+	}
+}
+
+func TestExportSpanMessageEvents(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+
+	start := time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)
+	exp.ExportSpan(&trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanID:  trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		},
+		Name:      "stream",
+		StartTime: start,
+		EndTime:   start.Add(time.Second),
+		MessageEvents: []trace.MessageEvent{
+			{
+				Time:                 start.Add(10 * time.Millisecond),
+				EventType:            trace.MessageEventTypeSent,
+				MessageID:            1,
+				UncompressedByteSize: 512,
+				CompressedByteSize:   128,
+			},
+			{
+				Time:                 start.Add(20 * time.Millisecond),
+				EventType:            trace.MessageEventTypeRecv,
+				MessageID:            1,
+				UncompressedByteSize: 256,
+				CompressedByteSize:   64,
+			},
+		},
+	})
+
+	events := mock.Events()
+	// One event per message event plus the span itself, which is sent last.
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	wantTypes := []string{"SENT", "RECEIVED"}
+	wantSizes := [][2]int64{{512, 128}, {256, 64}}
+	for i, ev := range events[:2] {
+		if got := ev.Data["message_event_type"]; got != wantTypes[i] {
+			t.Errorf("event #%d: message_event_type = %v, want %v", i, got, wantTypes[i])
+		}
+		if got := ev.Data["uncompressed_byte_size"]; got != wantSizes[i][0] {
+			t.Errorf("event #%d: uncompressed_byte_size = %v, want %v", i, got, wantSizes[i][0])
+		}
+		if got := ev.Data["compressed_byte_size"]; got != wantSizes[i][1] {
+			t.Errorf("event #%d: compressed_byte_size = %v, want %v", i, got, wantSizes[i][1])
+		}
+		if got := ev.Data["trace.trace_id"]; got != "0102030405060708090a0b0c0d0e0f10" {
+			t.Errorf("event #%d: trace.trace_id = %v", i, got)
+		}
+		if got := ev.Data["trace.parent_id"]; got != "1112131415161718" {
+			t.Errorf("event #%d: trace.parent_id = %v, want the span ID", i, got)
+		}
+	}
+
+	annotations, ok := events[2].Data["annotations"].([]Annotation)
+	if !ok || len(annotations) != 2 {
+		t.Fatalf("span annotations = %v, want the 2 message events", events[2].Data["annotations"])
+	}
+}
+
+func TestExportSpanLinks(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanID:  trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		},
+		Name: "batch",
+		Links: []trace.Link{
+			{
+				TraceID:    trace.TraceID{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28, 0x29, 0x2a, 0x2b, 0x2c, 0x2d, 0x2e, 0x2f, 0x30},
+				SpanID:     trace.SpanID{0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38},
+				Type:       trace.LinkTypeParent,
+				Attributes: map[string]interface{}{"queue": "jobs"},
+			},
+			{
+				TraceID: trace.TraceID{0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4a, 0x4b, 0x4c, 0x4d, 0x4e, 0x4f, 0x50},
+				SpanID:  trace.SpanID{0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58},
+				Type:    trace.LinkTypeChild,
+			},
+			{Type: trace.LinkTypeUnspecified},
+		},
+	}
+	exp.ExportSpan(sd)
+
+	events := mock.Events()
+	// One event per link plus the span itself, which is sent last.
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 4", len(events))
+	}
+	spanEv := events[3]
+	wantLinks := []struct {
+		traceID, spanID, linkType string
+	}{
+		{"2122232425262728292a2b2c2d2e2f30", "3132333435363738", "PARENT"},
+		{"4142434445464748494a4b4c4d4e4f50", "5152535455565758", "CHILD"},
+		{"0000000000000000", "0000000000000000", "UNSPECIFIED"},
+	}
+	for i, ev := range events[:3] {
+		if got, want := ev.Data["trace.trace_id"], spanEv.Data["trace.trace_id"]; got != want {
+			t.Errorf("link #%d: trace.trace_id = %v, want the span trace ID %v", i, got, want)
+		}
+		if got, want := ev.Data["trace.parent_id"], spanEv.Data["trace.span_id"]; got != want {
+			t.Errorf("link #%d: trace.parent_id = %v, want the span ID %v", i, got, want)
+		}
+		if got := ev.Data["meta.span_type"]; got != "link" {
+			t.Errorf("link #%d: meta.span_type = %v, want link", i, got)
+		}
+		if got := ev.Data["trace.link_trace_id"]; got != wantLinks[i].traceID {
+			t.Errorf("link #%d: trace.link_trace_id = %v, want %v", i, got, wantLinks[i].traceID)
+		}
+		if got := ev.Data["trace.link_span_id"]; got != wantLinks[i].spanID {
+			t.Errorf("link #%d: trace.link_span_id = %v, want %v", i, got, wantLinks[i].spanID)
+		}
+		if got := ev.Data["link_type"]; got != wantLinks[i].linkType {
+			t.Errorf("link #%d: link_type = %v, want %v", i, got, wantLinks[i].linkType)
+		}
+	}
+	if got := events[0].Data["queue"]; got != "jobs" {
+		t.Errorf("link attribute queue = %v, want jobs", got)
+	}
+
+	exp.ExportLinks = false
+	exp.ExportSpan(sd)
+	if events := mock.Events(); len(events) != 5 {
+		t.Errorf("got %d events, want only one more for the span with ExportLinks disabled", len(events))
+	}
+}
+
+func TestExportSpanZeroSampleFraction(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+	exp.SampleFraction = 0
+
+	start := time.Date(2019, 7, 1, 10, 0, 0, 0, time.UTC)
+	exp.ExportSpan(&trace.SpanData{
+		Name:        "dropped",
+		StartTime:   start,
+		EndTime:     start.Add(time.Millisecond),
+		Annotations: []trace.Annotation{{Time: start, Message: "dropped too"}},
+	})
+
+	if events := mock.Events(); len(events) != 0 {
+		t.Fatalf("got %d events, want none with a zero SampleFraction", len(events))
+	}
+}
+
+func TestExportSpanSampleRate(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+	exp.SampleFraction = 0.25
+
+	exp.ExportSpan(&trace.SpanData{Name: "sampled"})
+
+	events := mock.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if events[0].SampleRate != 4 {
+		t.Errorf("SampleRate = %d, want 4", events[0].SampleRate)
+	}
+}
+
+func TestExportSpanSamplerFunc(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+	// The global fraction must be ignored when a sampler is set.
+	exp.SampleFraction = 0
+	exp.SamplerFunc = func(sd *trace.SpanData) float64 {
+		if sd.Status.Code != trace.StatusCodeOK {
+			return 1
+		}
+		return 0
+	}
+
+	exp.ExportSpan(&trace.SpanData{Name: "ok"})
+	exp.ExportSpan(&trace.SpanData{Name: "error", Status: trace.Status{Code: trace.StatusCodeInternal}})
+	exp.ExportSpan(&trace.SpanData{Name: "ok-again"})
+
+	events := mock.Events()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want only the error span", len(events))
+	}
+	if got := events[0].Data["name"]; got != "error" {
+		t.Errorf("name = %v, want error", got)
+	}
+	if events[0].SampleRate != 1 {
+		t.Errorf("SampleRate = %d, want 1", events[0].SampleRate)
+	}
+
+	exp.SamplerFunc = func(*trace.SpanData) float64 { return 0.01 }
+	exp.ExportSpan(&trace.SpanData{Name: "sampled"})
+	if events := mock.Events(); len(events) != 2 || events[1].SampleRate != 100 {
+		t.Errorf("got events %v, want a second one with SampleRate 100", events)
+	}
+}
+
+func TestExportSpanWithContext(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+
+	sd := &trace.SpanData{
+		Name:        "span",
+		Annotations: []trace.Annotation{{Message: "annotation"}},
+	}
+	if err := exp.ExportSpanWithContext(context.Background(), sd); err != nil {
+		t.Fatalf("ExportSpanWithContext() = %v, want nil", err)
+	}
+	if events := mock.Events(); len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+}
+
+func TestExportSpanWithContextDone(t *testing.T) {
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	expiredCtx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		wantErr error
+	}{
+		{name: "canceled", ctx: canceledCtx, wantErr: context.Canceled},
+		{name: "deadline_exceeded", ctx: expiredCtx, wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &transmission.MockSender{}
+			exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+			defer exp.Close()
+
+			sd := &trace.SpanData{
+				Name:        "span",
+				Annotations: []trace.Annotation{{Message: "annotation"}},
+			}
+			if err := exp.ExportSpanWithContext(tt.ctx, sd); err != tt.wantErr {
+				t.Fatalf("ExportSpanWithContext() = %v, want %v", err, tt.wantErr)
+			}
+			if events := mock.Events(); len(events) != 0 {
+				t.Fatalf("got %d events, want none once the context is done", len(events))
+			}
+		})
+	}
+}
+
+// fakeHoneycomb is a fake Honeycomb batch API that records all the batches
+// of events it receives and replies to them with statusCode.
+type fakeHoneycomb struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	batches    [][]map[string]interface{}
+	encodings  []string
+	statusCode int
+}
+
+func newFakeHoneycomb(statusCode int) *fakeHoneycomb {
+	fh := &fakeHoneycomb{statusCode: statusCode}
+	fh.Server = httptest.NewServer(http.HandlerFunc(fh.handleBatch))
+	return fh
+}
+
+func (fh *fakeHoneycomb) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	encoding := r.Header.Get("Content-Encoding")
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+	var batch []map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fh.mu.Lock()
+	fh.batches = append(fh.batches, batch)
+	fh.encodings = append(fh.encodings, encoding)
+	statusCode := fh.statusCode
+	fh.mu.Unlock()
+
+	if statusCode != http.StatusOK {
+		http.Error(w, `{"error":"fake failure"}`, statusCode)
+		return
+	}
+	statuses := make([]map[string]int, len(batch))
+	for i := range statuses {
+		statuses[i] = map[string]int{"status": http.StatusAccepted}
+	}
+	json.NewEncoder(w).Encode(statuses)
+}
+
+func (fh *fakeHoneycomb) allBatches() [][]map[string]interface{} {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	return fh.batches[:]
+}
+
+func (fh *fakeHoneycomb) eventCount() int {
+	count := 0
+	for _, batch := range fh.allBatches() {
+		count += len(batch)
+	}
+	return count
+}
+
+func (fh *fakeHoneycomb) allEncodings() []string {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	return fh.encodings[:]
+}
+
+func decompress(t *testing.T, encoding string, compressed []byte) []byte {
+	var r io.Reader
+	switch encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatalf("gzip.NewReader() error = %v", err)
+		}
+		defer gz.Close()
+		r = gz
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatalf("zstd.NewReader() error = %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		t.Fatalf("unexpected Content-Encoding %q", encoding)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decompress the %s body: %v", encoding, err)
+	}
+	return b
+}
+
+func TestCompressingTransport(t *testing.T) {
+	const spanJSON = `[{"data":{"name":"compressed","trace.trace_id":"0102030405060708090a0b0c0d0e0f10"}}]`
+	for _, compression := range []string{CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			var gotEncoding string
+			var gotBody []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotEncoding = r.Header.Get("Content-Encoding")
+				gotBody, _ = ioutil.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: newCompressingTransport(compression, http.DefaultTransport)}
+			req, _ := http.NewRequest("POST", server.URL, strings.NewReader(spanJSON))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("client.Do() error = %v", err)
+			}
+			resp.Body.Close()
+
+			if gotEncoding != compression {
+				t.Fatalf("Content-Encoding = %q, want %q", gotEncoding, compression)
+			}
+			if got := string(decompress(t, gotEncoding, gotBody)); got != spanJSON {
+				t.Errorf("decompressed body = %q, want %q", got, spanJSON)
+			}
+			if req.Header.Get("Content-Encoding") != "" {
+				t.Error("the original request was modified")
+			}
+		})
+	}
+
+	if rt := newCompressingTransport(CompressionNone, http.DefaultTransport); rt != http.DefaultTransport {
+		t.Errorf("newCompressingTransport(%q) = %v, want the base transport", CompressionNone, rt)
+	}
+}
+
+func TestExportSpanCompression(t *testing.T) {
+	tests := []struct {
+		compression  string
+		wantEncoding string
+	}{
+		{compression: "", wantEncoding: "gzip"},
+		{compression: CompressionNone, wantEncoding: ""},
+		{compression: CompressionGzip, wantEncoding: "gzip"},
+		{compression: CompressionZstd, wantEncoding: "zstd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.compression, func(t *testing.T) {
+			server := newFakeHoneycomb(http.StatusOK)
+			defer server.Close()
+
+			exp := newTestExporter(t, ExporterConfig{
+				WriteKey:    "key",
+				Dataset:     "dataset",
+				APIHost:     server.URL,
+				Compression: tt.compression,
+			})
+			defer exp.Close()
+
+			exp.ExportSpan(&trace.SpanData{Name: "compressed"})
+			if err := exp.Flush(); err != nil {
+				t.Fatalf("Flush() = %v, want nil", err)
+			}
+
+			encodings := server.allEncodings()
+			if len(encodings) != 1 || encodings[0] != tt.wantEncoding {
+				t.Fatalf("got Content-Encodings %q, want [%q]", encodings, tt.wantEncoding)
+			}
+			batches := server.allBatches()
+			if got := batches[0][0]["data"].(map[string]interface{})["name"]; got != "compressed" {
+				t.Errorf("got span name %v, want %q", got, "compressed")
+			}
+		})
+	}
+}
+
+func TestUnknownCompression(t *testing.T) {
+	if _, err := NewExporterWithConfig(ExporterConfig{Compression: "brotli"}); err == nil {
+		t.Fatal("NewExporterWithConfig() succeeded with an unknown compression, want an error")
+	}
+}
+
+func TestExportSpanBatchAnnotations(t *testing.T) {
+	server := newFakeHoneycomb(http.StatusOK)
+	defer server.Close()
+
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey: "key",
+		Dataset:  "dataset",
+		APIHost:  server.URL,
+	})
+	exp.BatchAnnotations = true
+
+	annotations := make([]trace.Annotation, 12)
+	for i := range annotations {
+		annotations[i] = trace.Annotation{Message: fmt.Sprintf("log line %d", i)}
+	}
+	exp.ExportSpan(&trace.SpanData{Name: "chatty", Annotations: annotations})
+	// Close flushes all the pending events.
+	exp.Close()
+
+	batches := server.allBatches()
+	if len(batches) != 1 {
+		t.Fatalf("got %d batch requests, want 1", len(batches))
+	}
+	if len(batches[0]) != 1 {
+		t.Fatalf("got %d events in the batch, want only the span event", len(batches[0]))
+	}
+	data, _ := batches[0][0]["data"].(map[string]interface{})
+	if got, _ := data["annotations"].([]interface{}); len(got) != len(annotations) {
+		t.Errorf("span event has %d annotations, want %d", len(got), len(annotations))
+	}
+}
+
+func TestFlush(t *testing.T) {
+	server := newFakeHoneycomb(http.StatusOK)
+	defer server.Close()
+
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey: "key",
+		Dataset:  "dataset",
+		APIHost:  server.URL,
+	})
+	defer exp.Close()
+
+	const numGoroutines, spansPerGoroutine = 4, 25
+	var wg sync.WaitGroup
+	for i := 0; i < numGoroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < spansPerGoroutine; j++ {
+				exp.ExportSpan(&trace.SpanData{Name: "concurrent"})
+			}
+		}()
+	}
+	// Flushing concurrently with the exports must be safe.
+	if err := exp.Flush(); err != nil {
+		t.Errorf("Flush() = %v, want nil", err)
+	}
+	wg.Wait()
+
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush() = %v, want nil", err)
+	}
+	if got, want := server.eventCount(), numGoroutines*spansPerGoroutine; got != want {
+		t.Fatalf("got %d events after Flush, want %d", got, want)
+	}
+}
+
+func TestFlushReturnsFailedTransmissions(t *testing.T) {
+	server := newFakeHoneycomb(http.StatusInternalServerError)
+	defer server.Close()
+
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey: "key",
+		Dataset:  "dataset",
+		APIHost:  server.URL,
+	})
+	defer exp.Close()
+
+	exp.ExportSpan(&trace.SpanData{Name: "failed"})
+	if err := exp.Flush(); err == nil {
+		t.Fatal("Flush() = nil, want an error for the rejected batch")
+	}
+}
+
+// sequenceTransport answers the batch requests with the given status codes,
+// in order, and then with the last one.
+type sequenceTransport struct {
+	mu          sync.Mutex
+	statusCodes []int
+	// errorBody is the body of the non 200 responses.
+	errorBody string
+	requests  int
+}
+
+func (st *sequenceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	st.mu.Lock()
+	i := st.requests
+	if i >= len(st.statusCodes) {
+		i = len(st.statusCodes) - 1
+	}
+	statusCode := st.statusCodes[i]
+	st.requests++
+	st.mu.Unlock()
+
+	body := st.errorBody
+	if body == "" {
+		body = `{"error": "slow down"}`
+	}
+	if statusCode == http.StatusOK {
+		// Every test request holds a single event.
+		body = `[{"status": 202}]`
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+func (st *sequenceTransport) requestCount() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.requests
+}
+
+func newRetryTestExporter(t *testing.T, transport http.RoundTripper, maxAttempts int) *Exporter {
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey: "key",
+		Dataset:  "dataset",
+		Transmission: &transmission.Honeycomb{
+			MaxBatchSize:         1,
+			BatchTimeout:         time.Millisecond,
+			MaxConcurrentBatches: 1,
+			PendingWorkCapacity:  libhoney.DefaultPendingWorkCapacity,
+			Transport:            transport,
+		},
+	})
+	exp.Retry = RetryConfig{
+		MaxAttempts:    maxAttempts,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+	return exp
+}
+
+func TestExportSpanRetries(t *testing.T) {
+	transport := &sequenceTransport{
+		statusCodes: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+	}
+	exp := newRetryTestExporter(t, transport, 3)
+	defer exp.Close()
+
+	exp.ExportSpan(&trace.SpanData{Name: "retried"})
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush() = %v, want nil once the event is accepted", err)
+	}
+	if got := transport.requestCount(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+}
+
+func TestExportSpanRetriesExhausted(t *testing.T) {
+	transport := &sequenceTransport{statusCodes: []int{http.StatusServiceUnavailable}}
+	exp := newRetryTestExporter(t, transport, 2)
+	defer exp.Close()
+
+	exp.ExportSpan(&trace.SpanData{Name: "rejected"})
+	err := exp.Flush()
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("Flush() = %v, want the 503 of the last attempt", err)
+	}
+	if got := transport.requestCount(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestExportSpanDoesNotRetryClientErrors(t *testing.T) {
+	transport := &sequenceTransport{statusCodes: []int{http.StatusBadRequest}}
+	exp := newRetryTestExporter(t, transport, 3)
+	defer exp.Close()
+
+	exp.ExportSpan(&trace.SpanData{Name: "invalid"})
+	if err := exp.Flush(); err == nil {
+		t.Fatal("Flush() = nil, want the 400 error")
+	}
+	if got := transport.requestCount(); got != 1 {
+		t.Errorf("got %d requests, want no retry for a 400", got)
+	}
+}
+
+func TestExportSpanRetriesClosedConnections(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		first := requests == 1
+		mu.Unlock()
+		if first {
+			// Like a load balancer closing the connection mid-export.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Hijack() error = %v", err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		io.Copy(ioutil.Discard, r.Body)
+		io.WriteString(w, `[{"status": 202}]`)
+	}))
+	defer server.Close()
+
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey:  "key",
+		Dataset:   "dataset",
+		APIHost:   server.URL,
+		Transport: TransportConfig{IdleConnTimeout: time.Second, MaxIdleConnsPerHost: 2},
+	})
+	defer exp.Close()
+	exp.Retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	exp.ExportSpan(&trace.SpanData{Name: "reconnected"})
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush() = %v, want nil once the event is sent again", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestRetryConfigBackoff(t *testing.T) {
+	rc := RetryConfig{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond}
+	for i, w := range want {
+		if got := rc.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := (RetryConfig{}).backoff(1); got != defaultInitialBackoff {
+		t.Errorf("default backoff(1) = %v, want %v", got, defaultInitialBackoff)
+	}
+}
+
+func TestWithDebugResponseLogger(t *testing.T) {
+	transport := &sequenceTransport{
+		statusCodes: []int{http.StatusUnauthorized},
+		errorBody:   `{"error": "unknown API key - check your credentials"}`,
+	}
+	var logs bytes.Buffer
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey: "key",
+		Dataset:  "dataset",
+		Transmission: &transmission.Honeycomb{
+			MaxBatchSize:         1,
+			BatchTimeout:         time.Millisecond,
+			MaxConcurrentBatches: 1,
+			PendingWorkCapacity:  libhoney.DefaultPendingWorkCapacity,
+			Transport:            transport,
+		},
+	}, WithDebugResponseLogger(log.New(&logs, "", 0)))
+	defer exp.Close()
+
+	exp.ExportSpan(&trace.SpanData{Name: "unauthorized"})
+	// Flush waits for the response to be read.
+	if err := exp.Flush(); err == nil {
+		t.Error("Flush() = nil, want the 401 error")
+	}
+
+	got := logs.String()
+	for _, want := range []string{"401", "unknown API key - check your credentials"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("logs %q do not contain %q", got, want)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+
+	exp.ExportSpan(&trace.SpanData{Name: "span"})
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v, want nil", err)
+	}
+	if events := mock.Events(); len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+}
+
+// blockingTransport blocks all the requests until unblock is closed.
+type blockingTransport struct {
+	unblock chan struct{}
+}
+
+func (bt *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-bt.unblock
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(`[{"status": 202}]`)),
+		Header:     make(http.Header),
+		Request:    req,
+	}, nil
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	transport := &blockingTransport{unblock: make(chan struct{})}
+	exp := newTestExporter(t, ExporterConfig{
+		WriteKey: "key",
+		Dataset:  "dataset",
+		Transmission: &transmission.Honeycomb{
+			MaxBatchSize:         1,
+			BatchTimeout:         time.Millisecond,
+			MaxConcurrentBatches: 1,
+			PendingWorkCapacity:  libhoney.DefaultPendingWorkCapacity,
+			Transport:            transport,
+		},
+	})
+	exp.ExportSpan(&trace.SpanData{Name: "stuck"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := exp.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown() returned after %v, want it to give up at the deadline", elapsed)
+	}
+
+	close(transport.unblock)
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v once unblocked, want nil", err)
+	}
+}
+
+func TestExporterOptions(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t,
+		ExporterConfig{Transmission: mock},
+		WithServiceVersion("1.2.3"),
+		WithHostname("host-1"),
+	)
+	defer exp.Close()
+
+	exp.ExportSpan(&trace.SpanData{
+		Name:        "span",
+		Annotations: []trace.Annotation{{Message: "annotation"}},
+	})
+
+	events := mock.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	for i, ev := range events {
+		if got := ev.Data["service_version"]; got != "1.2.3" {
+			t.Errorf("event #%d: service_version = %v, want 1.2.3", i, got)
+		}
+		if got := ev.Data["host_name"]; got != "host-1" {
+			t.Errorf("event #%d: host_name = %v, want host-1", i, got)
+		}
+	}
+}
+
+func TestInitUserAgentConcurrently(t *testing.T) {
+	// Run with -race to detect unsynchronized writes to the user agent.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			initUserAgent()
+		}()
+	}
+	wg.Wait()
+
+	if want := "Honeycomb-OpenCensus-exporter/" + Version; libhoney.UserAgentAddition != want {
+		t.Fatalf("UserAgentAddition = %q, want %q", libhoney.UserAgentAddition, want)
+	}
+}
+
+func TestExportSpanKeyMapping(t *testing.T) {
+	mock := &transmission.MockSender{}
+	exp := newTestExporter(t, ExporterConfig{Transmission: mock})
+	defer exp.Close()
+	exp.KeyMapping = map[string]string{"http.status_code": "response.status_code"}
+	if err := exp.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+
+	exp.ExportSpan(&trace.SpanData{
+		Name: "span",
+		Attributes: map[string]interface{}{
+			"http.status_code": 200,
+			"http.method":      "GET",
+		},
+		Annotations: []trace.Annotation{{
+			Message:    "annotation",
+			Attributes: map[string]interface{}{"http.status_code": 500},
+		}},
+	})
+
+	events := mock.Events()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	annotationEv, spanEv := events[0], events[1]
+	if got := spanEv.Data["response.status_code"]; got != 200 {
+		t.Errorf("response.status_code = %v, want 200", got)
+	}
+	if _, ok := spanEv.Data["http.status_code"]; ok {
+		t.Error("the renamed key http.status_code is still present")
+	}
+	if got := spanEv.Data["http.method"]; got != "GET" {
+		t.Errorf("unmapped http.method = %v, want GET", got)
+	}
+	if got := annotationEv.Data["response.status_code"]; got != 500 {
+		t.Errorf("annotation response.status_code = %v, want 500", got)
+	}
+}
+
+func TestValidateKeyMapping(t *testing.T) {
+	tests := []struct {
+		mapping map[string]string
+		wantErr bool
+	}{
+		{mapping: nil},
+		{mapping: map[string]string{"http.url": "url"}},
+		{mapping: map[string]string{"trace.trace_id": "otel.trace_id"}, wantErr: true},
+		{mapping: map[string]string{"request_id": "trace.trace_id"}, wantErr: true},
+		{mapping: map[string]string{"operation": "name"}, wantErr: true},
+		{mapping: map[string]string{"svc": "service_name"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		exp := &Exporter{KeyMapping: tt.mapping}
+		if err := exp.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with %v = %v, wantErr %v", tt.mapping, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSampleFraction(t *testing.T) {
+	tests := []struct {
+		sampleFraction float64
+		wantErr        bool
+	}{
+		{sampleFraction: 0},
+		{sampleFraction: 0.25},
+		{sampleFraction: 1},
+		{sampleFraction: -0.5, wantErr: true},
+		{sampleFraction: 2, wantErr: true},
+		{sampleFraction: math.NaN(), wantErr: true},
+	}
+	for _, tt := range tests {
+		exp := &Exporter{SampleFraction: tt.sampleFraction}
+		if err := exp.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with SampleFraction %v = %v, wantErr %v", tt.sampleFraction, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	tests := []struct {
+		sampleFraction float64
+		want           uint
+	}{
+		{sampleFraction: 1, want: 1},
+		{sampleFraction: 0.25, want: 4},
+		{sampleFraction: 0.1, want: 10},
+		{sampleFraction: 4, want: 1},
+		{sampleFraction: math.Inf(1), want: 1},
+		{sampleFraction: 1e-30, want: math.MaxUint32},
+	}
+	for _, tt := range tests {
+		if got := sampleRate(tt.sampleFraction); got != tt.want {
+			t.Errorf("sampleRate(%v) = %d, want %d", tt.sampleFraction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package honeycombexporter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	libhoney "github.com/honeycombio/libhoney-go"
+	"github.com/honeycombio/libhoney-go/transmission"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+var errClosedBeforeRetry = errors.New("honeycomb exporter closed before the event could be retried")
+
+// pendingEvent is the metadata of the events sent while the responses are
+// read, it holds what is needed to send them again.
+type pendingEvent struct {
+	fields     map[string]interface{}
+	timestamp  time.Time
+	sampleRate uint
+	attempt    int
+}
+
+// responseReader reads the libhoney responses once retries or the debug
+// logger are enabled. It sends the events that failed with a transient error
+// again and keeps track of the events that are not done yet so that Flush can
+// wait for them.
+type responseReader struct {
+	client    *libhoney.Client
+	startOnce sync.Once
+	// txMu prevents events from being retried while the transmission is
+	// stopped, by a flush or by closing the exporter.
+	txMu sync.RWMutex
+
+	mu sync.Mutex
+	// cond is broadcast when pending drops to zero, when the transmission is
+	// restarted by a flush and when the exporter is closed.
+	cond    *sync.Cond
+	started bool
+	closed  bool
+	// generation is incremented every time the transmission, and thus the
+	// responses channel, is restarted.
+	generation int
+	pending    int
+	errs       []error
+}
+
+func newResponseReader(client *libhoney.Client) *responseReader {
+	r := &responseReader{client: client}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+func (r *responseReader) start(e *Exporter) {
+	r.startOnce.Do(func() {
+		r.mu.Lock()
+		r.started = true
+		r.mu.Unlock()
+		go r.readResponses(e)
+	})
+}
+
+func (r *responseReader) isStarted() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.started
+}
+
+func (r *responseReader) readResponses(e *Exporter) {
+	for {
+		r.mu.Lock()
+		generation := r.generation
+		r.mu.Unlock()
+
+		for resp := range r.client.TxResponses() {
+			r.handleResponse(e, resp)
+		}
+
+		// The channel is closed when the transmission is stopped, wait for it
+		// to be restarted, or for the exporter to be closed.
+		r.mu.Lock()
+		for r.generation == generation && !r.closed {
+			r.cond.Wait()
+		}
+		closed := r.closed
+		r.mu.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+func (r *responseReader) handleResponse(e *Exporter, resp transmission.Response) {
+	err := responseToError(resp)
+	if err != nil && e.debugLogger != nil {
+		e.debugLogger.Printf("Honeycomb exporter: failed to send an event: %v", err)
+	}
+
+	pe, ok := resp.Metadata.(*pendingEvent)
+	if ok && isRetriable(resp) && pe.attempt < e.Retry.MaxAttempts {
+		time.AfterFunc(e.Retry.backoff(pe.attempt), func() {
+			r.resend(e, pe)
+		})
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errs = append(r.errs, err)
+	}
+	if ok {
+		r.doneLocked()
+	}
+}
+
+func (r *responseReader) resend(e *Exporter, pe *pendingEvent) {
+	r.txMu.RLock()
+	defer r.txMu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The transmission cannot be used anymore once the exporter is closed.
+	if r.closed {
+		r.errs = append(r.errs, errClosedBeforeRetry)
+		r.doneLocked()
+		return
+	}
+
+	pe.attempt++
+	ev := e.Builder.NewEvent()
+	for k, v := range pe.fields {
+		ev.AddField(k, v)
+	}
+	ev.Timestamp = pe.timestamp
+	ev.SampleRate = pe.sampleRate
+	ev.Metadata = pe
+	if err := ev.SendPresampled(); err != nil {
+		r.errs = append(r.errs, err)
+		r.doneLocked()
+	}
+}
+
+// send sends the event, keeping what is needed to retry it and to wait for
+// its response.
+func (r *responseReader) send(ev *libhoney.Event) error {
+	ev.Metadata = &pendingEvent{
+		fields:     ev.Fields(),
+		timestamp:  ev.Timestamp,
+		sampleRate: ev.SampleRate,
+		attempt:    1,
+	}
+	r.mu.Lock()
+	r.pending++
+	r.mu.Unlock()
+
+	if err := ev.SendPresampled(); err != nil {
+		r.mu.Lock()
+		r.doneLocked()
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *responseReader) doneLocked() {
+	r.pending--
+	if r.pending == 0 {
+		r.cond.Broadcast()
+	}
+}
+
+// flush flushes the transmission, which restarts it.
+func (r *responseReader) flush() {
+	r.txMu.Lock()
+	r.client.Flush()
+	r.txMu.Unlock()
+
+	r.mu.Lock()
+	r.generation++
+	r.mu.Unlock()
+	r.cond.Broadcast()
+}
+
+// wait blocks until all the events are done, including their retries, and
+// returns the errors of the ones that failed since the last call.
+func (r *responseReader) wait() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.pending > 0 && !r.closed {
+		r.cond.Wait()
+	}
+	errs := r.errs
+	r.errs = nil
+	return internal.CombineErrors(errs)
+}
+
+// close drops the events waiting to be retried and closes the transmission.
+func (r *responseReader) close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.cond.Broadcast()
+
+	r.txMu.Lock()
+	r.client.Close()
+	r.txMu.Unlock()
+}
@@ -0,0 +1,75 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package honeycombexporter
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/honeycombio/libhoney-go/transmission"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+)
+
+// RetryConfig configures how the events that failed with a transient error,
+// i.e. a 429 or a 5xx status code or a connection error such as one closed by
+// a load balancer, are sent again.
+type RetryConfig struct {
+	// MaxAttempts is the number of times an event is sent, including the
+	// first one. Retries are disabled if it is less than 2.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, it doubles with
+	// every attempt. It defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts. It defaults to 10s.
+	MaxBackoff time.Duration
+}
+
+func (rc RetryConfig) enabled() bool {
+	return rc.MaxAttempts > 1
+}
+
+// backoff returns the delay before the given retry, the first one being 1.
+func (rc RetryConfig) backoff(retry int) time.Duration {
+	initial, max := rc.InitialBackoff, rc.MaxBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := initial
+	for i := 1; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func isRetriable(resp transmission.Response) bool {
+	if resp.Err != nil {
+		// The errors of the HTTP client, the other ones are libhoney's own,
+		// e.g. a full queue, that sending again would not fix.
+		_, ok := resp.Err.(net.Error)
+		return ok
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
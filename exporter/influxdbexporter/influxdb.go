@@ -0,0 +1,203 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package influxdbexporter writes the received spans to InfluxDB, as points of
+// the "traces" measurement, for time-series analysis of their durations.
+package influxdbexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultEndpoint  = "http://localhost:8086"
+	defaultBatchSize = 5000
+)
+
+type influxDBConfig struct {
+	// Endpoint is the URL of the InfluxDB server, http://localhost:8086 by
+	// default.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Org and Bucket are the organization and the bucket the points are
+	// written to.
+	Org    string `mapstructure:"org"`
+	Bucket string `mapstructure:"bucket"`
+
+	// Token is the API token of the writes, if the server requires one.
+	Token string `mapstructure:"token"`
+
+	// BatchSize is the maximum number of points of each write, 5000 by
+	// default.
+	BatchSize int `mapstructure:"batch_size"`
+
+	// Timeout of each write, 10s by default.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+var (
+	errMissingBucket = errors.New("expecting a non-blank bucket for the influxdb exporter")
+	errMissingOrg    = errors.New("expecting a non-blank org for the influxdb exporter")
+)
+
+// InfluxDBExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// writing to InfluxDB according to the configuration settings. The errors of
+// the writes are logged to the global zap logger, see
+// InfluxDBExportersFromViperWithLogger.
+func InfluxDBExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	return InfluxDBExportersFromViperWithLogger(v, zap.L())
+}
+
+// InfluxDBExportersFromViperWithLogger is InfluxDBExportersFromViper logging
+// the errors of the writes, sent in the background, to logger.
+func InfluxDBExportersFromViperWithLogger(v *viper.Viper, logger *zap.Logger) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		InfluxDB *influxDBConfig `mapstructure:"influxdb"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	ic := cfg.InfluxDB
+	if ic == nil {
+		return nil, nil, nil, nil
+	}
+
+	ie, err := newInfluxDBExporter(ic, func(err error) {
+		logger.Warn("Failed to write the spans to InfluxDB", zap.String("bucket", ic.Bucket), zap.Error(err))
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure influxdb exporter: %v", err)
+	}
+
+	ite, err := exporterhelper.NewTraceExporter(
+		"influxdb",
+		ie.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.InfluxDB.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		ie.Close()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, ite)
+	doneFns = append(doneFns, func() error {
+		ie.Close()
+		return nil
+	})
+	return
+}
+
+// influxDBExporter writes the spans as points with the non-blocking write API
+// of the InfluxDB v2 client, which sends them in batches of at most BatchSize
+// points in the background. The errors of the writes are passed to logError.
+type influxDBExporter struct {
+	client   influxdb2.Client
+	writeAPI api.WriteAPI
+	done     chan struct{}
+}
+
+func newInfluxDBExporter(ic *influxDBConfig, logError func(error)) (*influxDBExporter, error) {
+	if ic.Bucket == "" {
+		return nil, errMissingBucket
+	}
+	if ic.Org == "" {
+		return nil, errMissingOrg
+	}
+
+	endpoint := ic.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the influxdb endpoint %q", u.Scheme, endpoint)
+	}
+
+	batchSize := ic.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	timeout := ic.Timeout
+	if timeout <= 0 {
+		timeout = exporterhelper.DefaultHTTPTimeout
+	}
+	opts := influxdb2.DefaultOptions().
+		SetBatchSize(uint(batchSize)).
+		SetPrecision(time.Nanosecond).
+		// The client only takes whole seconds.
+		SetHTTPRequestTimeout(uint(math.Ceil(timeout.Seconds())))
+	client := influxdb2.NewClientWithOptions(endpoint, ic.Token, opts)
+
+	ie := &influxDBExporter{
+		client:   client,
+		writeAPI: client.WriteAPI(ic.Org, ic.Bucket),
+		done:     make(chan struct{}),
+	}
+	errs := ie.writeAPI.Errors()
+	go func() {
+		defer close(ie.done)
+		for err := range errs {
+			logError(err)
+		}
+	}()
+	return ie, nil
+}
+
+// pushTraceData hands the spans to the write API, only the spans that can't
+// be converted are reported as dropped.
+func (ie *influxDBExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	dropped := 0
+	res := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource)
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		spandatatranslator.AddResourceAttributes(sd, res)
+		ie.writeAPI.WritePoint(spanToPoint(sd))
+	}
+	return dropped, internal.CombineErrors(errs)
+}
+
+// Close writes the points not sent yet and closes the client.
+func (ie *influxDBExporter) Close() {
+	ie.writeAPI.Flush()
+	// Closing the client closes the error channel of the write API once the
+	// pending writes are done.
+	ie.client.Close()
+	<-ie.done
+}
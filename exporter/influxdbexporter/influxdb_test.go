@@ -0,0 +1,164 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdbexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+func TestSpanToPoint(t *testing.T) {
+	start := time.Unix(1, 0)
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanID:  trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		},
+		ParentSpanID: trace.SpanID{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+		Name:         "GET /api, v1",
+		StartTime:    start,
+		EndTime:      start.Add(1500 * time.Microsecond),
+		Attributes: map[string]interface{}{
+			"http.host":        "a=b c",
+			"http.status_code": int64(200),
+			"ratio":            0.5,
+			"error":            false,
+			"empty":            "",
+			"duration_ms":      int64(7),
+			"trace_id":         "shadowed",
+		},
+	}
+
+	// The tags and fields are sorted, and the special characters of the tag
+	// values escaped, by the line protocol encoding of the client.
+	want := `traces,http.host=a\=b\ c,span_name=GET\ /api\,\ v1 ` +
+		`duration_ms=1.5,error=false,http.status_code=200i,parent_span_id="0807060504030201",ratio=0.5,` +
+		`span_id="0102030405060708",trace_id="0102030405060708090a0b0c0d0e0f10" 1000000000` + "\n"
+	if got := write.PointToLineProtocol(spanToPoint(sd), time.Nanosecond); got != want {
+		t.Errorf("Got point\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSpanToPoint_rootSpan(t *testing.T) {
+	start := time.Unix(0, 42)
+	got := write.PointToLineProtocol(spanToPoint(&trace.SpanData{StartTime: start, EndTime: start.Add(time.Second)}), time.Nanosecond)
+	want := `traces duration_ms=1000,span_id="0000000000000000",trace_id="00000000000000000000000000000000" 42` + "\n"
+	if got != want {
+		t.Errorf("Got point\n%s\nwant\n%s", got, want)
+	}
+}
+
+// newFakeInfluxDB returns a fake InfluxDB server replying to the writes with
+// status.
+func newFakeInfluxDB(status int) *testutils.HTTPRecorder {
+	return &testutils.HTTPRecorder{Status: status, ErrorBody: `{"code":"invalid","message":"unable to parse"}`}
+}
+
+func TestInfluxDBExporterBatchesWrites(t *testing.T) {
+	fake := newFakeInfluxDB(http.StatusNoContent)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("influxdb:\n  endpoint: " + srv.URL + "/\n  org: my-org\n  bucket: spans\n  token: secret\n  batch_size: 2\n"))
+	tps, _, doneFns, err := InfluxDBExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), testutils.TraceDataWithSpans(3)); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+	// Closing the exporter writes the last batch.
+	for _, done := range doneFns {
+		done()
+	}
+
+	writes, bodies := fake.Requests(), fake.Bodies()
+	if len(writes) != 2 {
+		t.Fatalf("Got %d writes, want 2", len(writes))
+	}
+	for i, r := range writes {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v2/write" {
+			t.Errorf("Write #%d: got %s %s, want POST /api/v2/write", i, r.Method, r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("org") != "my-org" || q.Get("bucket") != "spans" || q.Get("precision") != "ns" {
+			t.Errorf("Write #%d: got query %v", i, q)
+		}
+		if got, want := r.Header.Get("Authorization"), "Token secret"; got != want {
+			t.Errorf("Write #%d: got Authorization %q, want %q", i, got, want)
+		}
+	}
+	for i, want := range []int{2, 1} {
+		lines := strings.Split(strings.TrimSuffix(bodies[i], "\n"), "\n")
+		if len(lines) != want {
+			t.Errorf("Write #%d: got %d points, want %d", i, len(lines), want)
+		}
+		for _, line := range lines {
+			if !strings.HasPrefix(line, "traces,k8s.namespace=staging,span_name=span duration_ms=1000,") {
+				t.Errorf("Write #%d: unexpected point %q", i, line)
+			}
+		}
+	}
+}
+
+func TestInfluxDBExporterWriteError(t *testing.T) {
+	srv := httptest.NewServer(newFakeInfluxDB(http.StatusBadRequest))
+	defer srv.Close()
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("influxdb:\n  endpoint: " + srv.URL + "\n  org: o\n  bucket: b\n"))
+	tps, _, doneFns, err := InfluxDBExportersFromViperWithLogger(v, zap.New(core))
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), testutils.TraceDataWithSpans(3)); err != nil {
+		t.Fatalf("Got error %v, want the write error to be logged", err)
+	}
+	for _, done := range doneFns {
+		done()
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("Got %d log entries, want 1", len(entries))
+	}
+	if got := entries[0].ContextMap()["error"]; !strings.Contains(got.(string), "unable to parse") {
+		t.Errorf("Got logged error %q, want the message of the server", got)
+	}
+}
+
+func TestInfluxDBExportersFromViperErrors(t *testing.T) {
+	testutils.CheckExportersFromViperErrors(t, InfluxDBExportersFromViper, []testutils.InvalidConfig{
+		{Name: "missing bucket", Config: "influxdb:\n  org: o\n"},
+		{Name: "missing org", Config: "influxdb:\n  bucket: b\n"},
+		{Name: "invalid scheme", Config: "influxdb:\n  endpoint: udp://localhost:8089\n  org: o\n  bucket: b\n"},
+	})
+}
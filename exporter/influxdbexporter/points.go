@@ -0,0 +1,82 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdbexporter
+
+import (
+	"math"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// measurement is the measurement of the points of the spans.
+	measurement = "traces"
+
+	spanNameTag       = "span_name"
+	durationField     = "duration_ms"
+	traceIDField      = "trace_id"
+	spanIDField       = "span_id"
+	parentSpanIDField = "parent_span_id"
+)
+
+// reservedFields are the fields of every point, the span attributes with the
+// same keys are ignored, including the string ones which would be tags with
+// the same keys as fields.
+var reservedFields = map[string]bool{
+	durationField:     true,
+	traceIDField:      true,
+	spanIDField:       true,
+	parentSpanIDField: true,
+}
+
+// spanToPoint returns the point of sd. The string attributes are tags, along
+// with the span name, and the numeric and boolean attributes are fields,
+// along with the duration and the IDs of the span. The timestamp is the start
+// of the span. The client escapes the keys and values in the line protocol.
+func spanToPoint(sd *trace.SpanData) *write.Point {
+	tags := map[string]string{}
+	fields := map[string]interface{}{
+		durationField: float64(sd.EndTime.Sub(sd.StartTime)) / float64(time.Millisecond),
+		traceIDField:  sd.TraceID.String(),
+		spanIDField:   sd.SpanID.String(),
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		fields[parentSpanIDField] = sd.ParentSpanID.String()
+	}
+	for k, v := range sd.Attributes {
+		if k == "" || reservedFields[k] {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			// Empty tag values are invalid.
+			if v != "" {
+				tags[k] = v
+			}
+		case bool, int64:
+			fields[k] = v
+		case float64:
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				fields[k] = v
+			}
+		}
+	}
+	if sd.Name != "" {
+		tags[spanNameTag] = sd.Name
+	}
+	return write.NewPoint(measurement, tags, fields, sd.StartTime)
+}
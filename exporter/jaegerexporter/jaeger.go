@@ -15,45 +15,77 @@
 package jaegerexporter
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
 	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
 )
 
-// Slight modified version of go/src/contrib.go.opencensus.io/exporter/jaeger/jaeger.go
-type jaegerConfig struct {
+// JaegerConfig is a slight modified version of go/src/contrib.go.opencensus.io/exporter/jaeger/jaeger.go
+type JaegerConfig struct {
 	CollectorEndpoint string `mapstructure:"collector_endpoint,omitempty"`
+	AgentEndpoint     string `mapstructure:"agent_endpoint,omitempty"`
 	Username          string `mapstructure:"username,omitempty"`
 	Password          string `mapstructure:"password,omitempty"`
 	ServiceName       string `mapstructure:"service_name,omitempty"`
+
+	// MaxRetries is the number of times a batch is re-sent after the agent at
+	// AgentEndpoint refused the connection. Other upload errors are not
+	// retried, nor are the batches sent to the CollectorEndpoint.
+	MaxRetries int `mapstructure:"max_retries,omitempty"`
+	// RetryBackoff is the base delay between retries, it is doubled on every
+	// attempt and jittered. Defaults to 100ms.
+	RetryBackoff time.Duration `mapstructure:"retry_backoff,omitempty"`
+	// OnDrop is called with the spans given up after MaxRetries. It can't be
+	// set from viper, see JaegerExportersFromConfig.
+	OnDrop func(spans []*trace.SpanData) `mapstructure:"-"`
 }
 
 // JaegerExportersFromViper unmarshals the viper and returns exporter.TraceExporters targeting
 // Jaeger according to the configuration settings.
 func JaegerExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
 	var cfg struct {
-		Jaeger *jaegerConfig `mapstructure:"jaeger"`
+		Jaeger *JaegerConfig `mapstructure:"jaeger"`
 	}
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, nil, nil, err
 	}
-	jc := cfg.Jaeger
+
+	return JaegerExportersFromConfig(cfg.Jaeger)
+}
+
+// JaegerExportersFromConfig returns exporter.TraceExporters targeting Jaeger
+// according to jc, none if jc is nil.
+func JaegerExportersFromConfig(jc *JaegerConfig) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
 	if jc == nil {
 		return nil, nil, nil, nil
 	}
 
-	// jaeger.NewExporter performs configurqtion validation
-	je, err := jaeger.NewExporter(jaeger.Options{
+	opts := jaeger.Options{
 		CollectorEndpoint: jc.CollectorEndpoint,
+		AgentEndpoint:     jc.AgentEndpoint,
 		Username:          jc.Username,
 		Password:          jc.Password,
 		Process: jaeger.Process{
 			ServiceName: jc.ServiceName,
 		},
-	})
+	}
+	// Only the refusals of the agent are retried: the batches are then
+	// serialized, and flushed one by one, to know which one was refused.
+	var re *retryingExporter
+	if jc.AgentEndpoint != "" && jc.MaxRetries > 0 {
+		re = newRetryingExporter(jc.MaxRetries, jc.RetryBackoff, jc.OnDrop)
+		opts.OnError = re.onError
+	}
+
+	// jaeger.NewExporter performs configurqtion validation
+	je, err := jaeger.NewExporter(opts)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -63,7 +95,20 @@ func JaegerExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 		return nil
 	})
 
-	jte, err := exporterwrapper.NewExporterWrapper("jaeger", "ocservice.exporter.Jaeger.ConsumeTraceData", je)
+	// The Process of the exporter is static, the node attributes and resource
+	// labels of the spans are added to their tags instead.
+	var jte consumer.TraceConsumer
+	if re != nil {
+		re.exporter = je
+		jte, err = exporterhelper.NewTraceExporter(
+			"jaeger",
+			re.pushTraceData,
+			exporterhelper.WithSpanName("ocservice.exporter.Jaeger.ConsumeTraceData"),
+			exporterhelper.WithRecordMetrics(true),
+		)
+	} else {
+		jte, err = exporterwrapper.NewExporterWrapper("jaeger", "ocservice.exporter.Jaeger.ConsumeTraceData", je, exporterwrapper.WithResourceAttributes())
+	}
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -14,4 +14,226 @@
 
 package jaegerexporter
 
-// TODO: Add tests.
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// fakeJaeger fails the first failures flushes with err.
+type fakeJaeger struct {
+	re       *retryingExporter
+	failures int
+	err      error
+
+	flushes  int
+	exported []*trace.SpanData
+}
+
+func (fj *fakeJaeger) ExportSpan(sd *trace.SpanData) {
+	fj.exported = append(fj.exported, sd)
+}
+
+func (fj *fakeJaeger) Flush() {
+	fj.flushes++
+	if fj.flushes <= fj.failures {
+		fj.re.onError(fj.err)
+	}
+}
+
+var errConnRefused = &net.OpError{
+	Op:  "write",
+	Net: "udp",
+	Err: os.NewSyscallError("write", syscall.ECONNREFUSED),
+}
+
+func newTestExporter(maxRetries, failures int, err error) (*retryingExporter, *fakeJaeger, *[][]*trace.SpanData, *[]time.Duration) {
+	var dropped [][]*trace.SpanData
+	var sleeps []time.Duration
+	re := newRetryingExporter(maxRetries, time.Millisecond, func(spans []*trace.SpanData) {
+		dropped = append(dropped, spans)
+	})
+	re.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	fj := &fakeJaeger{re: re, failures: failures, err: err}
+	re.exporter = fj
+	return re, fj, &dropped, &sleeps
+}
+
+func testTraceData() data.TraceData {
+	return data.TraceData{
+		Spans: []*tracepb.Span{
+			{
+				TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:    &tracepb.TruncatableString{Value: "first"},
+			},
+			{
+				TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+				Name:    &tracepb.TruncatableString{Value: "second"},
+			},
+		},
+	}
+}
+
+func TestRetryingExporterRetriesConnectionRefused(t *testing.T) {
+	re, fj, dropped, sleeps := newTestExporter(3, 2, errConnRefused)
+
+	droppedSpans, err := re.pushTraceData(context.Background(), testTraceData())
+	if err != nil || droppedSpans != 0 {
+		t.Fatalf("pushTraceData() = (%d, %v), want (0, nil)", droppedSpans, err)
+	}
+	if fj.flushes != 3 {
+		t.Errorf("Got %d uploads, want 3", fj.flushes)
+	}
+	if len(fj.exported) != 6 {
+		t.Errorf("Got %d exported spans, want 6 (2 spans, 3 attempts)", len(fj.exported))
+	}
+	if len(*sleeps) != 2 {
+		t.Errorf("Got %d backoffs, want 2", len(*sleeps))
+	}
+	if len(*dropped) != 0 {
+		t.Errorf("OnDrop called for a batch that was eventually uploaded")
+	}
+}
+
+func TestRetryingExporterDropsAfterMaxRetries(t *testing.T) {
+	re, fj, dropped, _ := newTestExporter(2, 10, errConnRefused)
+
+	droppedSpans, err := re.pushTraceData(context.Background(), testTraceData())
+	if err == nil {
+		t.Fatal("Expected an error after exhausting the retries")
+	}
+	if droppedSpans != 2 {
+		t.Errorf("Got %d dropped spans, want 2", droppedSpans)
+	}
+	if fj.flushes != 3 {
+		t.Errorf("Got %d uploads, want 3 (1 attempt, 2 retries)", fj.flushes)
+	}
+	if len(*dropped) != 1 {
+		t.Fatalf("Got %d OnDrop calls, want 1", len(*dropped))
+	}
+	if got := (*dropped)[0]; len(got) != 2 || got[0].Name != "first" || got[1].Name != "second" {
+		t.Errorf("OnDrop got unexpected spans: %v", got)
+	}
+}
+
+func TestRetryingExporterDoesNotRetryOtherErrors(t *testing.T) {
+	re, fj, dropped, sleeps := newTestExporter(5, 1, errors.New("message too long"))
+
+	if _, err := re.pushTraceData(context.Background(), testTraceData()); err == nil {
+		t.Fatal("Expected the upload error to be returned")
+	}
+	if fj.flushes != 1 {
+		t.Errorf("Got %d uploads, want 1", fj.flushes)
+	}
+	if len(*sleeps) != 0 {
+		t.Errorf("Got %d backoffs, want none", len(*sleeps))
+	}
+	if len(*dropped) != 1 {
+		t.Errorf("Got %d OnDrop calls, want 1", len(*dropped))
+	}
+}
+
+func TestRetryingExporterResendsPreviousBatch(t *testing.T) {
+	re, fj, dropped, _ := newTestExporter(1, 0, errConnRefused)
+	if _, err := re.pushTraceData(context.Background(), testTraceData()); err != nil {
+		t.Fatalf("pushTraceData() error: %v", err)
+	}
+
+	// The refusal of the first batch is reported by the write of the second.
+	fj.failures = fj.flushes + 1
+	fj.exported = nil
+	next := testTraceData()
+	next.Spans = next.Spans[:1]
+	next.Spans[0].Name = &tracepb.TruncatableString{Value: "third"}
+	droppedSpans, err := re.pushTraceData(context.Background(), next)
+	if err != nil || droppedSpans != 0 {
+		t.Fatalf("pushTraceData() = (%d, %v), want (0, nil)", droppedSpans, err)
+	}
+	var names []string
+	for _, sd := range fj.exported {
+		names = append(names, sd.Name)
+	}
+	if want := []string{"third", "first", "second", "third"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Exported spans %v, want %v", names, want)
+	}
+	if len(*dropped) != 0 {
+		t.Errorf("OnDrop called for batches that were eventually uploaded")
+	}
+
+	// The batches resent last are resent along with the next one, and given
+	// up with it.
+	fj.failures = fj.flushes + 2
+	fj.exported = nil
+	if droppedSpans, err := re.pushTraceData(context.Background(), next); err == nil || droppedSpans != 1 {
+		t.Fatalf("pushTraceData() = (%d, %v), want 1 dropped span and an error", droppedSpans, err)
+	}
+	if len(*dropped) != 1 || len((*dropped)[0]) != 4 {
+		t.Errorf("Got OnDrop calls %v, want the 4 spans of the last flushes", *dropped)
+	}
+}
+
+func TestRetryingExporterAddsResourceAttributes(t *testing.T) {
+	re, fj, _, _ := newTestExporter(3, 0, nil)
+
+	td := testTraceData()
+	td.Node = &commonpb.Node{Attributes: map[string]string{"host.name": "h1"}}
+	td.Resource = &resourcepb.Resource{Labels: map[string]string{"zone": "us-east1-b"}}
+	if _, err := re.pushTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to push the spans: %v", err)
+	}
+	if len(fj.exported) != 2 {
+		t.Fatalf("Got %d exported spans, want 2", len(fj.exported))
+	}
+	want := map[string]interface{}{"host.name": "h1", "zone": "us-east1-b"}
+	for _, sd := range fj.exported {
+		if !reflect.DeepEqual(sd.Attributes, want) {
+			t.Errorf("Got attributes %v for span %q, want %v", sd.Attributes, sd.Name, want)
+		}
+	}
+}
+
+func TestIsConnectionRefused(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errConnRefused, true},
+		{syscall.ECONNREFUSED, true},
+		{&net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("write", syscall.EMSGSIZE)}, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isConnectionRefused(tt.err); got != tt.want {
+			t.Errorf("isConnectionRefused(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestJitteredBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	for attempt := 0; attempt < 5; attempt++ {
+		max := base << uint(attempt)
+		for i := 0; i < 50; i++ {
+			if d := jitteredBackoff(base, attempt); d < max/2 || d > max {
+				t.Fatalf("jitteredBackoff(%v, %d) = %v, want within [%v, %v]", base, attempt, d, max/2, max)
+			}
+		}
+	}
+	if d := jitteredBackoff(time.Second, 1000); d <= 0 {
+		t.Errorf("jitteredBackoff overflowed: %v", d)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jaegerexporter
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultRetryBackoff = 100 * time.Millisecond
+	// maxBackoffDoublings keeps the exponential backoff from overflowing.
+	maxBackoffDoublings = 16
+)
+
+// spanFlusher is the subset of the Jaeger exporter used by retryingExporter.
+// This enables passing in fake exporters in unit tests.
+type spanFlusher interface {
+	ExportSpan(sd *trace.SpanData)
+	Flush()
+}
+
+// retryingExporter uploads each TraceData as one Jaeger batch and retries it
+// when the agent refused the connection. The underlying Jaeger exporter only
+// reports upload failures through its OnError callback, so batches are
+// serialized to be able to attribute those errors to the spans that caused
+// them.
+type retryingExporter struct {
+	mu       sync.Mutex
+	exporter spanFlusher
+	// unconfirmed is the last batch flushed without errors. The agent
+	// refusing it is only reported by the next write to the UDP socket, when
+	// flushing the next batch.
+	unconfirmed []*trace.SpanData
+
+	maxRetries int
+	backoff    time.Duration
+	onDrop     func(spans []*trace.SpanData)
+	sleep      func(time.Duration)
+
+	errMu sync.Mutex
+	errs  []error
+}
+
+func newRetryingExporter(maxRetries int, backoff time.Duration, onDrop func(spans []*trace.SpanData)) *retryingExporter {
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	return &retryingExporter{
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		onDrop:     onDrop,
+		sleep:      time.Sleep,
+	}
+}
+
+// onError is meant to be used as the OnError callback of the Jaeger exporter.
+func (re *retryingExporter) onError(err error) {
+	re.errMu.Lock()
+	re.errs = append(re.errs, err)
+	re.errMu.Unlock()
+}
+
+func (re *retryingExporter) takeErrors() []error {
+	re.errMu.Lock()
+	defer re.errMu.Unlock()
+	errs := re.errs
+	re.errs = nil
+	return errs
+}
+
+func (re *retryingExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	sds := make([]*trace.SpanData, 0, len(td.Spans))
+	res := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource)
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		spandatatranslator.AddResourceAttributes(sd, res)
+		sds = append(sds, sd)
+	}
+	droppedSpans := len(td.Spans) - len(sds)
+
+	if len(sds) > 0 {
+		if err := re.exportBatch(sds); err != nil {
+			errs = append(errs, err)
+			droppedSpans = len(td.Spans)
+		}
+	}
+	return droppedSpans, internal.CombineErrors(errs)
+}
+
+func (re *retryingExporter) exportBatch(sds []*trace.SpanData) error {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	batch := sds
+	for attempt := 0; ; attempt++ {
+		for _, sd := range batch {
+			re.exporter.ExportSpan(sd)
+		}
+		re.exporter.Flush()
+
+		errs := re.takeErrors()
+		if len(errs) == 0 {
+			re.unconfirmed = batch
+			return nil
+		}
+		refused := anyConnectionRefused(errs)
+		if refused && attempt == 0 && len(re.unconfirmed) > 0 {
+			// The refusal was caused by the previous batch, the write of this
+			// one failed with it: both of them are resent.
+			batch = append(append([]*trace.SpanData(nil), re.unconfirmed...), sds...)
+		}
+		re.unconfirmed = nil
+		if attempt >= re.maxRetries || !refused {
+			if re.onDrop != nil {
+				re.onDrop(batch)
+			}
+			return internal.CombineErrors(errs)
+		}
+		re.sleep(jitteredBackoff(re.backoff, attempt))
+	}
+}
+
+// jitteredBackoff doubles base for every attempt and picks a random duration
+// between half and all of it.
+func jitteredBackoff(base time.Duration, attempt int) time.Duration {
+	if attempt > maxBackoffDoublings {
+		attempt = maxBackoffDoublings
+	}
+	d := base << uint(attempt)
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func anyConnectionRefused(errs []error) bool {
+	for _, err := range errs {
+		if isConnectionRefused(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConnectionRefused reports whether err was caused by ECONNREFUSED, which a
+// connected UDP socket returns when no agent is listening on the destination.
+func isConnectionRefused(err error) bool {
+	for {
+		switch e := err.(type) {
+		case *net.OpError:
+			err = e.Err
+		case *os.SyscallError:
+			err = e.Err
+		case syscall.Errno:
+			return e == syscall.ECONNREFUSED
+		default:
+			return false
+		}
+	}
+}
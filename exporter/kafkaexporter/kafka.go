@@ -15,23 +15,69 @@
 package kafkaexporter
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"fmt"
 
+	"github.com/Shopify/sarama"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 	"github.com/spf13/viper"
-	kafka "github.com/yancl/opencensus-go-exporter-kafka"
+	"go.uber.org/zap"
 
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
-	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	encodingProtobuf = "protobuf"
+	encodingJSON     = "json"
 )
 
 type kafkaConfig struct {
 	Brokers []string `mapstructure:"brokers,omitempty"`
 	Topic   string   `mapstructure:"topic,omitempty"`
+
+	// Encoding of the messages, either "protobuf" (default) or "json". Each
+	// message is an ExportTraceServiceRequest holding a single span together
+	// with the node and resource it was received with.
+	Encoding string `mapstructure:"encoding,omitempty"`
+	// Compression is one of "none" (default), "gzip", "snappy" or "lz4".
+	Compression string `mapstructure:"compression,omitempty"`
+	// RequiredAcks is one of "none", "local" (default) or "all".
+	RequiredAcks string `mapstructure:"required_acks,omitempty"`
+}
+
+var compressionCodecs = map[string]sarama.CompressionCodec{
+	"":       sarama.CompressionNone,
+	"none":   sarama.CompressionNone,
+	"gzip":   sarama.CompressionGZIP,
+	"snappy": sarama.CompressionSnappy,
+	"lz4":    sarama.CompressionLZ4,
+}
+
+var requiredAcks = map[string]sarama.RequiredAcks{
+	"none":  sarama.NoResponse,
+	"":      sarama.WaitForLocal,
+	"local": sarama.WaitForLocal,
+	"all":   sarama.WaitForAll,
 }
 
 // KafkaExportersFromViper unmarshals the viper and returns an consumer.TraceConsumer targeting
-// Kafka according to the configuration settings.
+// Kafka according to the configuration settings. The errors of the producer
+// are logged to the global zap logger, see KafkaExportersFromViperWithLogger.
 func KafkaExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	return KafkaExportersFromViperWithLogger(v, zap.L())
+}
+
+// KafkaExportersFromViperWithLogger is KafkaExportersFromViper logging the
+// errors of the producer, reported after the spans were pushed, to logger.
+func KafkaExportersFromViperWithLogger(v *viper.Viper, logger *zap.Logger) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
 	var cfg struct {
 		Kafka *kafkaConfig `mapstructure:"kafka"`
 	}
@@ -44,24 +90,146 @@ func KafkaExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 		return nil, nil, nil, nil
 	}
 
-	kde, kerr := kafka.NewExporter(kafka.Options{
-		Brokers: kc.Brokers,
-		Topic:   kc.Topic,
-	})
-
+	saramaConfig, err := kc.saramaConfig()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure Kafka Trace exporter: %v", err)
+	}
+	producer, kerr := sarama.NewAsyncProducer(kc.Brokers, saramaConfig)
 	if kerr != nil {
 		return nil, nil, nil, fmt.Errorf("Cannot configure Kafka Trace exporter: %v", kerr)
 	}
 
-	kte, err := exporterwrapper.NewExporterWrapper("kafka", "ocservice.exporter.Kafka.ConsumeTraceData", kde)
+	kde, err := newKafkaExporter(producer, kc.Topic, kc.Encoding, func(err error) {
+		logger.Warn("Failed to publish a span to Kafka", zap.String("topic", kc.Topic), zap.Error(err))
+	})
+	if err != nil {
+		producer.AsyncClose()
+		return nil, nil, nil, fmt.Errorf("Cannot configure Kafka Trace exporter: %v", err)
+	}
+
+	kte, err := exporterhelper.NewTraceExporter(
+		"kafka",
+		kde.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Kafka.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
 	if err != nil {
+		kde.Close()
 		return nil, nil, nil, err
 	}
 
 	tps = append(tps, kte)
 	doneFns = append(doneFns, func() error {
-		kde.Flush()
+		kde.Close()
 		return nil
 	})
 	return
 }
+
+func (kc *kafkaConfig) saramaConfig() (*sarama.Config, error) {
+	codec, ok := compressionCodecs[kc.Compression]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression %q", kc.Compression)
+	}
+	acks, ok := requiredAcks[kc.RequiredAcks]
+	if !ok {
+		return nil, fmt.Errorf("unknown required_acks %q", kc.RequiredAcks)
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.Compression = codec
+	config.Producer.RequiredAcks = acks
+	config.Producer.Return.Errors = true
+	return config, nil
+}
+
+// kafkaExporter publishes every span as its own message, keyed by its trace
+// ID so that all the spans of a trace land on the same partition.
+type kafkaExporter struct {
+	producer sarama.AsyncProducer
+	topic    string
+	marshal  func(*agenttracepb.ExportTraceServiceRequest) ([]byte, error)
+	onError  func(error)
+
+	errorsDone chan struct{}
+}
+
+func newKafkaExporter(producer sarama.AsyncProducer, topic, encoding string, onError func(error)) (*kafkaExporter, error) {
+	var marshal func(*agenttracepb.ExportTraceServiceRequest) ([]byte, error)
+	switch encoding {
+	case "", encodingProtobuf:
+		marshal = marshalProtobuf
+	case encodingJSON:
+		marshal = marshalJSON
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+
+	kde := &kafkaExporter{
+		producer:   producer,
+		topic:      topic,
+		marshal:    marshal,
+		onError:    onError,
+		errorsDone: make(chan struct{}),
+	}
+	go kde.handleErrors()
+	return kde, nil
+}
+
+func (kde *kafkaExporter) handleErrors() {
+	defer close(kde.errorsDone)
+	// The channel is closed once the producer has shut down.
+	for perr := range kde.producer.Errors() {
+		if kde.onError != nil {
+			kde.onError(perr.Err)
+		}
+	}
+}
+
+func (kde *kafkaExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	for i, span := range td.Spans {
+		value, err := kde.marshal(&agenttracepb.ExportTraceServiceRequest{
+			Node:     td.Node,
+			Resource: td.Resource,
+			Spans:    []*tracepb.Span{span},
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		msg := &sarama.ProducerMessage{
+			Topic: kde.topic,
+			Key:   sarama.StringEncoder(hex.EncodeToString(span.TraceId)),
+			Value: sarama.ByteEncoder(value),
+		}
+		select {
+		case kde.producer.Input() <- msg:
+		case <-ctx.Done():
+			// The producer is backed up, the spans not handed to it yet are
+			// dropped.
+			dropped := len(errs) + len(td.Spans) - i
+			return dropped, internal.CombineErrors(append(errs, ctx.Err()))
+		}
+	}
+	return len(errs), internal.CombineErrors(errs)
+}
+
+// Close flushes the buffered messages and waits for their errors, if any, to
+// be reported.
+func (kde *kafkaExporter) Close() {
+	kde.producer.AsyncClose()
+	<-kde.errorsDone
+}
+
+func marshalProtobuf(req *agenttracepb.ExportTraceServiceRequest) ([]byte, error) {
+	return proto.Marshal(req)
+}
+
+func marshalJSON(req *agenttracepb.ExportTraceServiceRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, req); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -14,4 +14,181 @@
 
 package kafkaexporter
 
-// TODO: Add tests.
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+func testTraceData() data.TraceData {
+	return data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "kafka-svc"}},
+		Spans: []*tracepb.Span{
+			{
+				TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:    &tracepb.TruncatableString{Value: "first"},
+			},
+			{
+				TraceId: []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20},
+				SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+				Name:    &tracepb.TruncatableString{Value: "second"},
+			},
+		},
+	}
+}
+
+func newMockProducer(t *testing.T) *mocks.AsyncProducer {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	return mocks.NewAsyncProducer(t, config)
+}
+
+func TestKafkaExporterEncodings(t *testing.T) {
+	decoders := map[string]func([]byte, *agenttracepb.ExportTraceServiceRequest) error{
+		encodingProtobuf: func(b []byte, req *agenttracepb.ExportTraceServiceRequest) error {
+			return proto.Unmarshal(b, req)
+		},
+		encodingJSON: func(b []byte, req *agenttracepb.ExportTraceServiceRequest) error {
+			return jsonpb.UnmarshalString(string(b), req)
+		},
+	}
+
+	for encoding, decode := range decoders {
+		producer := newMockProducer(t)
+		producer.ExpectInputAndSucceed()
+		producer.ExpectInputAndSucceed()
+
+		kde, err := newKafkaExporter(producer, "spans", encoding, func(err error) {
+			t.Errorf("%s: unexpected send error: %v", encoding, err)
+		})
+		if err != nil {
+			t.Fatalf("%s: newKafkaExporter() error: %v", encoding, err)
+		}
+
+		td := testTraceData()
+		if dropped, err := kde.pushTraceData(context.Background(), td); dropped != 0 || err != nil {
+			t.Fatalf("%s: pushTraceData() = (%d, %v), want (0, nil)", encoding, dropped, err)
+		}
+
+		for i, span := range td.Spans {
+			msg := <-producer.Successes()
+			if msg.Topic != "spans" {
+				t.Errorf("%s #%d: topic = %q, want %q", encoding, i, msg.Topic, "spans")
+			}
+			key, _ := msg.Key.Encode()
+			if g, w := string(key), []string{"0102030405060708090a0b0c0d0e0f10", "1112131415161718191a1b1c1d1e1f20"}[i]; g != w {
+				t.Errorf("%s #%d: key = %q, want the trace ID %q", encoding, i, g, w)
+			}
+
+			value, _ := msg.Value.Encode()
+			var req agenttracepb.ExportTraceServiceRequest
+			if err := decode(value, &req); err != nil {
+				t.Fatalf("%s #%d: failed to decode the message: %v", encoding, i, err)
+			}
+			if len(req.Spans) != 1 || !proto.Equal(req.Spans[0], span) {
+				t.Errorf("%s #%d: got spans %v, want %v", encoding, i, req.Spans, span)
+			}
+			if !proto.Equal(req.Node, td.Node) {
+				t.Errorf("%s #%d: got node %v, want %v", encoding, i, req.Node, td.Node)
+			}
+		}
+
+		kde.Close()
+	}
+}
+
+func TestKafkaExporterOnError(t *testing.T) {
+	producer := newMockProducer(t)
+	sendErr := errors.New("broker unavailable")
+	producer.ExpectInputAndFail(sendErr)
+	producer.ExpectInputAndSucceed()
+
+	var gotErrs []error
+	kde, err := newKafkaExporter(producer, "spans", "", func(err error) {
+		gotErrs = append(gotErrs, err)
+	})
+	if err != nil {
+		t.Fatalf("newKafkaExporter() error: %v", err)
+	}
+	if _, err := kde.pushTraceData(context.Background(), testTraceData()); err != nil {
+		t.Fatalf("pushTraceData() error: %v", err)
+	}
+	<-producer.Successes()
+
+	// Close waits for all the errors to be reported.
+	kde.Close()
+	if len(gotErrs) != 1 || gotErrs[0] != sendErr {
+		t.Errorf("OnError got %v, want [%v]", gotErrs, sendErr)
+	}
+}
+
+// blockedProducer is a sarama.AsyncProducer whose input is never consumed.
+type blockedProducer struct {
+	sarama.AsyncProducer
+	input  chan *sarama.ProducerMessage
+	errors chan *sarama.ProducerError
+}
+
+func (bp *blockedProducer) Input() chan<- *sarama.ProducerMessage { return bp.input }
+func (bp *blockedProducer) Errors() <-chan *sarama.ProducerError  { return bp.errors }
+func (bp *blockedProducer) AsyncClose()                           { close(bp.errors) }
+
+func TestKafkaExporterContextDone(t *testing.T) {
+	producer := &blockedProducer{
+		input:  make(chan *sarama.ProducerMessage),
+		errors: make(chan *sarama.ProducerError),
+	}
+	kde, err := newKafkaExporter(producer, "spans", "", nil)
+	if err != nil {
+		t.Fatalf("newKafkaExporter() error: %v", err)
+	}
+	defer kde.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	dropped, err := kde.pushTraceData(ctx, testTraceData())
+	if dropped != 2 || err != context.DeadlineExceeded {
+		t.Errorf("pushTraceData() = (%d, %v), want (2, %v)", dropped, err, context.DeadlineExceeded)
+	}
+}
+
+func TestKafkaConfigValidation(t *testing.T) {
+	tests := []struct {
+		cfg     kafkaConfig
+		wantErr string
+	}{
+		{cfg: kafkaConfig{}},
+		{cfg: kafkaConfig{Compression: "snappy", RequiredAcks: "all"}},
+		{cfg: kafkaConfig{Compression: "brotli"}, wantErr: `unknown compression "brotli"`},
+		{cfg: kafkaConfig{RequiredAcks: "some"}, wantErr: `unknown required_acks "some"`},
+	}
+	for i, tt := range tests {
+		_, err := tt.cfg.saramaConfig()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("#%d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("#%d: got error %v, want %q", i, err, tt.wantErr)
+		}
+	}
+
+	if _, err := newKafkaExporter(newMockProducer(t), "spans", "avro", nil); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lokiexporter pushes the annotations of the received spans to Grafana
+// Loki, as log lines of streams labeled with the IDs of their trace and span.
+package lokiexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultPushURL = "http://localhost:3100/loki/api/v1/push"
+	defaultTimeout = 5 * time.Second
+
+	traceIDLabel = "trace_id"
+	spanIDLabel  = "span_id"
+
+	// maxErrorBodySize bounds the part of the body of a failed push added to
+	// the error.
+	maxErrorBodySize = 1024
+)
+
+type basicAuthConfig struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+type lokiConfig struct {
+	// PushURL is the URL of the push API of Loki,
+	// http://localhost:3100/loki/api/v1/push by default.
+	PushURL string `mapstructure:"push_url"`
+
+	// BasicAuth if set, authenticates the pushes, e.g. to Grafana Cloud.
+	BasicAuth *basicAuthConfig `mapstructure:"basic_auth"`
+
+	// Labels are added to the labels of every stream, e.g. a job label.
+	Labels map[string]string `mapstructure:"labels"`
+
+	// Timeout of each push, 5s by default.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+var errReservedLabel = errors.New("the trace_id and span_id labels of the loki exporter can't be configured")
+
+// LokiExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// pushing the span annotations to Loki according to the configuration settings.
+func LokiExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		Loki *lokiConfig `mapstructure:"loki"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	lc := cfg.Loki
+	if lc == nil {
+		return nil, nil, nil, nil
+	}
+
+	le, err := newLokiExporter(lc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure loki exporter: %v", err)
+	}
+
+	lte, err := exporterhelper.NewTraceExporter(
+		"loki",
+		le.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Loki.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, lte)
+	return
+}
+
+// lokiExporter pushes, for every span with annotations, a stream of their log
+// lines labeled with the trace and span IDs.
+type lokiExporter struct {
+	pushURL   string
+	basicAuth *basicAuthConfig
+	labels    map[string]string
+	client    *http.Client
+}
+
+func newLokiExporter(lc *lokiConfig) (*lokiExporter, error) {
+	pushURL := lc.PushURL
+	if pushURL == "" {
+		pushURL = defaultPushURL
+	}
+	u, err := url.Parse(pushURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the loki push URL %q", u.Scheme, pushURL)
+	}
+	if _, ok := lc.Labels[traceIDLabel]; ok {
+		return nil, errReservedLabel
+	}
+	if _, ok := lc.Labels[spanIDLabel]; ok {
+		return nil, errReservedLabel
+	}
+
+	timeout := lc.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &lokiExporter{
+		pushURL:   pushURL,
+		basicAuth: lc.BasicAuth,
+		labels:    lc.Labels,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// pushRequest is the JSON body of the push API, see
+// https://github.com/grafana/loki/blob/master/docs/api.md#post-lokiapiv1push
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+type stream struct {
+	Labels map[string]string `json:"stream"`
+	// Values are the timestamps, in nanoseconds, and the lines of the
+	// entries, in the order of the timestamps.
+	Values [][2]string `json:"values"`
+}
+
+func (le *lokiExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	var req pushRequest
+	// The spans of the streams, all of them are dropped if the push fails.
+	pushed := 0
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pushed++
+		if len(sd.Annotations) == 0 {
+			continue
+		}
+		req.Streams = append(req.Streams, le.spanStream(sd))
+	}
+
+	dropped := len(td.Spans) - pushed
+	if len(req.Streams) > 0 {
+		if err := le.push(ctx, &req); err != nil {
+			errs = append(errs, err)
+			dropped = len(td.Spans)
+		}
+	}
+	return dropped, internal.CombineErrors(errs)
+}
+
+// spanStream returns the stream of the annotations of sd.
+func (le *lokiExporter) spanStream(sd *trace.SpanData) stream {
+	s := stream{
+		Labels: make(map[string]string, len(le.labels)+2),
+		Values: make([][2]string, 0, len(sd.Annotations)),
+	}
+	for k, v := range le.labels {
+		s.Labels[k] = v
+	}
+	s.Labels[traceIDLabel] = sd.TraceID.String()
+	s.Labels[spanIDLabel] = sd.SpanID.String()
+
+	annotations := make([]trace.Annotation, len(sd.Annotations))
+	copy(annotations, sd.Annotations)
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].Time.Before(annotations[j].Time)
+	})
+	for _, a := range annotations {
+		s.Values = append(s.Values, [2]string{
+			strconv.FormatInt(a.Time.UnixNano(), 10),
+			logLine(a),
+		})
+	}
+	return s
+}
+
+// logLine returns the message of the annotation followed by its attributes
+// in the logfmt format, sorted by key.
+func logLine(a trace.Annotation) string {
+	if len(a.Attributes) == 0 {
+		return a.Message
+	}
+	keys := make([]string, 0, len(a.Attributes))
+	for k := range a.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(a.Message)
+	for _, k := range keys {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		v := fmt.Sprint(a.Attributes[k])
+		if v == "" || strings.ContainsAny(v, " =\"") {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
+
+func (le *lokiExporter) push(ctx context.Context, pr *pushRequest) error {
+	body, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, le.pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if le.basicAuth != nil {
+		req.SetBasicAuth(le.basicAuth.Username, le.basicAuth.Password)
+	}
+
+	resp, err := le.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		// Drain the body so that the connection is reused.
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return fmt.Errorf("loki push failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,206 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lokiexporter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+// fakeLoki records the pushes it receives and replies with status.
+type fakeLoki struct {
+	status int
+
+	mu       sync.Mutex
+	pushes   []pushRequest
+	requests []*http.Request
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var pr pushRequest
+	err := json.NewDecoder(r.Body).Decode(&pr)
+	f.mu.Lock()
+	f.pushes = append(f.pushes, pr)
+	f.requests = append(f.requests, r)
+	f.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.status != http.StatusNoContent {
+		http.Error(w, "ingestion rate limit exceeded", f.status)
+		return
+	}
+	w.WriteHeader(f.status)
+}
+
+func annotation(seconds int64, message string, attrs map[string]*tracepb.AttributeValue) *tracepb.Span_TimeEvent {
+	te := &tracepb.Span_TimeEvent{
+		Time: &timestamp.Timestamp{Seconds: seconds},
+		Value: &tracepb.Span_TimeEvent_Annotation_{
+			Annotation: &tracepb.Span_TimeEvent_Annotation{
+				Description: &tracepb.TruncatableString{Value: message},
+			},
+		},
+	}
+	if attrs != nil {
+		te.GetAnnotation().Attributes = &tracepb.Span_Attributes{AttributeMap: attrs}
+	}
+	return te
+}
+
+var testTraceData = data.TraceData{
+	Spans: []*tracepb.Span{
+		{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			TimeEvents: &tracepb.Span_TimeEvents{
+				TimeEvent: []*tracepb.Span_TimeEvent{
+					annotation(2, "cache miss", map[string]*tracepb.AttributeValue{
+						"key": {Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "user 42"}}},
+						"ttl": {Value: &tracepb.AttributeValue_IntValue{IntValue: 60}},
+					}),
+					annotation(1, "request received", nil),
+				},
+			},
+		},
+		{
+			// No annotations, no stream.
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		},
+	},
+}
+
+func TestLokiExporterPushesAnnotations(t *testing.T) {
+	fake := &fakeLoki{status: http.StatusNoContent}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(`loki:
+  push_url: ` + srv.URL + `/loki/api/v1/push
+  basic_auth:
+    username: tenant
+    password: secret
+  labels:
+    job: occollector
+`))
+	tps, _, _, err := LokiExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	if len(fake.pushes) != 1 {
+		t.Fatalf("Got %d pushes, want 1", len(fake.pushes))
+	}
+	r := fake.requests[0]
+	if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Got push to %s of %q, want JSON to /loki/api/v1/push", r.URL.Path, r.Header.Get("Content-Type"))
+	}
+	if user, password, ok := r.BasicAuth(); !ok || user != "tenant" || password != "secret" {
+		t.Errorf("Got basic auth %q:%q (%v), want tenant:secret", user, password, ok)
+	}
+
+	want := pushRequest{Streams: []stream{{
+		Labels: map[string]string{
+			"job":      "occollector",
+			"trace_id": "0102030405060708090a0b0c0d0e0f10",
+			"span_id":  "0102030405060708",
+		},
+		Values: [][2]string{
+			{"1000000000", "request received"},
+			{"2000000000", `cache miss key="user 42" ttl=60`},
+		},
+	}}}
+	if !reflect.DeepEqual(fake.pushes[0], want) {
+		t.Errorf("Got push\n%+v\nwant\n%+v", fake.pushes[0], want)
+	}
+}
+
+func TestLokiExporterPushError(t *testing.T) {
+	fake := &fakeLoki{status: http.StatusTooManyRequests}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	le, err := newLokiExporter(&lokiConfig{PushURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	dropped, err := le.pushTraceData(context.Background(), testTraceData)
+	if err == nil || !strings.Contains(err.Error(), "status 429") || !strings.Contains(err.Error(), "rate limit") {
+		t.Errorf("Got error %v, want the status and the message of the server", err)
+	}
+	if dropped != 2 {
+		t.Errorf("Got %d dropped spans, want 2", dropped)
+	}
+	if _, _, ok := fake.requests[0].BasicAuth(); ok {
+		t.Error("Got basic auth without credentials")
+	}
+}
+
+func TestLokiExporterNoAnnotations(t *testing.T) {
+	fake := &fakeLoki{status: http.StatusNoContent}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	le, err := newLokiExporter(&lokiConfig{PushURL: srv.URL})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	td := data.TraceData{Spans: testTraceData.Spans[1:]}
+	if dropped, err := le.pushTraceData(context.Background(), td); dropped != 0 || err != nil {
+		t.Errorf("Got %d dropped spans and error %v, want none", dropped, err)
+	}
+	if len(fake.pushes) != 0 {
+		t.Errorf("Got %d pushes without annotations, want none", len(fake.pushes))
+	}
+}
+
+func TestLokiExportersFromViperErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"invalid scheme", "loki:\n  push_url: grpc://localhost:9095\n"},
+		{"trace_id label", "loki:\n  labels:\n    trace_id: x\n"},
+		{"span_id label", "loki:\n  labels:\n    span_id: x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := viperutils.ViperFromYAMLBytes([]byte(tt.config))
+			if _, _, _, err := LokiExportersFromViper(v); err == nil {
+				t.Error("Got no error")
+			}
+		})
+	}
+}
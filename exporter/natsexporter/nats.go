@@ -0,0 +1,308 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package natsexporter publishes the received spans to NATS, either NATS Core
+// or JetStream.
+package natsexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/viper"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	defaultSubject = "opencensus.spans"
+	defaultTimeout = 5 * time.Second
+
+	modeCore      = "core"
+	modeJetStream = "jetstream"
+)
+
+type natsConfig struct {
+	// URL of the NATS server, nats://127.0.0.1:4222 by default. It can hold
+	// the username and password, and a tls:// scheme for TLS connections,
+	// which are also used if the server requires them.
+	URL string `mapstructure:"url"`
+
+	// Subject is the template of the subject of the messages, given the
+	// .ServiceName and the hex encoded .TraceID of the span, e.g.
+	// "spans.{{.ServiceName}}". It is opencensus.spans by default.
+	Subject string `mapstructure:"subject"`
+
+	// Mode is either "core" (default), for the spans to be published to the
+	// NATS subscribers, or "jetstream" for them to be stored by the JetStream
+	// stream bound to their subject, which acknowledges them.
+	Mode string `mapstructure:"mode"`
+
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Token    string `mapstructure:"token"`
+
+	// Timeout of connecting and of the JetStream acknowledgments, 5s by
+	// default.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxReconnects is the number of attempts to reconnect to the server once
+	// the connection is lost, after which the exporter gives up, 60 by
+	// default and unlimited if negative. ReconnectWait is the delay between
+	// the attempts, 2s by default. The spans published in between are
+	// buffered by the client and sent once reconnected.
+	MaxReconnects *int          `mapstructure:"max_reconnects"`
+	ReconnectWait time.Duration `mapstructure:"reconnect_wait"`
+}
+
+// NATSExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// publishing the spans to NATS according to the configuration settings.
+func NATSExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		NATS *natsConfig `mapstructure:"nats"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	nc := cfg.NATS
+	if nc == nil {
+		return nil, nil, nil, nil
+	}
+
+	ne, err := newNATSExporter(nc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure nats exporter: %v", err)
+	}
+
+	nte, err := exporterhelper.NewTraceExporter(
+		"nats",
+		ne.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.NATS.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		ne.Close()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, nte)
+	doneFns = append(doneFns, func() error {
+		ne.Close()
+		return nil
+	})
+	return
+}
+
+// subjectData is the data of the subject template.
+type subjectData struct {
+	ServiceName string
+	TraceID     string
+}
+
+// natsExporter publishes every span as its own message, an
+// ExportTraceServiceRequest holding the span with the node and resource it
+// was received with, encoded in JSON.
+type natsExporter struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject *template.Template
+	timeout time.Duration
+}
+
+func newNATSExporter(nc *natsConfig) (*natsExporter, error) {
+	url := nc.URL
+	if url == "" {
+		url = nats.DefaultURL
+	}
+
+	subject := nc.Subject
+	if subject == "" {
+		subject = defaultSubject
+	}
+	tmpl, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %v", err)
+	}
+	if _, err := renderSubject(tmpl, subjectData{ServiceName: "service", TraceID: "0"}); err != nil {
+		return nil, err
+	}
+
+	var jetStream bool
+	switch nc.Mode {
+	case "", modeCore:
+	case modeJetStream:
+		jetStream = true
+	default:
+		return nil, fmt.Errorf("unknown mode %q", nc.Mode)
+	}
+
+	timeout := nc.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	opts := []nats.Option{
+		nats.Name("opencensus-service"),
+		nats.Timeout(timeout),
+	}
+	if nc.MaxReconnects != nil {
+		opts = append(opts, nats.MaxReconnects(*nc.MaxReconnects))
+	}
+	if nc.ReconnectWait > 0 {
+		opts = append(opts, nats.ReconnectWait(nc.ReconnectWait))
+	}
+	if nc.Username != "" {
+		opts = append(opts, nats.UserInfo(nc.Username, nc.Password))
+	}
+	if nc.Token != "" {
+		opts = append(opts, nats.Token(nc.Token))
+	}
+
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ne := &natsExporter{conn: conn, subject: tmpl, timeout: timeout}
+	if jetStream {
+		js, err := conn.JetStream(nats.MaxWait(timeout))
+		if err == nil {
+			// The account info is only returned if JetStream is enabled.
+			_, err = js.AccountInfo()
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("JetStream is not available: %v", err)
+		}
+		ne.js = js
+	}
+	return ne, nil
+}
+
+func renderSubject(tmpl *template.Template, sd subjectData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, sd); err != nil {
+		return "", fmt.Errorf("failed to render the subject: %v", err)
+	}
+	subject := buf.String()
+	if strings.ContainsAny(subject, " \t\r\n*>") {
+		return "", fmt.Errorf("invalid subject %q: it can't hold whitespaces or wildcards", subject)
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return "", fmt.Errorf("invalid subject %q: it can't have empty tokens", subject)
+		}
+	}
+	return subject, nil
+}
+
+func (ne *natsExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	type pendingAck struct {
+		subject string
+		future  nats.PubAckFuture
+	}
+	var errs []error
+	var acks []pendingAck
+	published := 0
+	for _, span := range td.Spans {
+		subject, err := ne.subjectOf(td.Node, span)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		value, err := marshalJSON(&agenttracepb.ExportTraceServiceRequest{
+			Node:     td.Node,
+			Resource: td.Resource,
+			Spans:    []*tracepb.Span{span},
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if ne.js == nil {
+			err = ne.conn.Publish(subject, value)
+		} else {
+			var future nats.PubAckFuture
+			future, err = ne.js.PublishAsync(subject, value)
+			if err == nil {
+				acks = append(acks, pendingAck{subject: subject, future: future})
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish the span to %q: %v", subject, err))
+			if err == nats.ErrConnectionClosed {
+				break
+			}
+			continue
+		}
+		published++
+	}
+	if ne.js == nil {
+		return len(td.Spans) - published, internal.CombineErrors(errs)
+	}
+
+	timer := time.NewTimer(ne.timeout)
+	defer timer.Stop()
+	acked := 0
+	for i, ack := range acks {
+		select {
+		case <-ack.future.Ok():
+			acked++
+		case err := <-ack.future.Err():
+			if err == nats.ErrNoResponders {
+				err = fmt.Errorf("no JetStream stream is bound to the subject %q", ack.subject)
+			}
+			errs = append(errs, err)
+		case <-timer.C:
+			errs = append(errs, fmt.Errorf("no JetStream acknowledgment of %d spans within %v", len(acks)-i, ne.timeout))
+			return len(td.Spans) - acked, internal.CombineErrors(errs)
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+			return len(td.Spans) - acked, internal.CombineErrors(errs)
+		}
+	}
+	return len(td.Spans) - acked, internal.CombineErrors(errs)
+}
+
+func (ne *natsExporter) subjectOf(node *commonpb.Node, span *tracepb.Span) (string, error) {
+	return renderSubject(ne.subject, subjectData{
+		ServiceName: node.GetServiceInfo().GetName(),
+		TraceID:     hex.EncodeToString(span.TraceId),
+	})
+}
+
+// Close closes the connection. The spans pushed afterwards are dropped.
+func (ne *natsExporter) Close() {
+	ne.conn.Close()
+}
+
+func marshalJSON(req *agenttracepb.ExportTraceServiceRequest) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&buf, req); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,326 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natsexporter
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+func testTraceData() data.TraceData {
+	return data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "nats-svc"}},
+		Spans: []*tracepb.Span{
+			{
+				TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:    &tracepb.TruncatableString{Value: "first"},
+			},
+			{
+				TraceId: []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20},
+				SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+				Name:    &tracepb.TruncatableString{Value: "second"},
+			},
+		},
+	}
+}
+
+// runServer runs an embedded NATS server listening on a random port, with
+// JetStream enabled if jetStream is set.
+func runServer(t *testing.T, token string, jetStream bool) *server.Server {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.Authorization = token
+	if jetStream {
+		dir, err := ioutil.TempDir("", "natsexporter")
+		if err != nil {
+			t.Fatalf("Failed to create the JetStream store: %v", err)
+		}
+		opts.JetStream = true
+		opts.StoreDir = dir
+	}
+	return natsserver.RunServer(&opts)
+}
+
+func stopServer(s *server.Server) {
+	jsConfig := s.JetStreamConfig()
+	s.Shutdown()
+	if jsConfig != nil {
+		os.RemoveAll(jsConfig.StoreDir)
+	}
+}
+
+// subscribe returns a client of s subscribed to subject.
+func subscribe(t *testing.T, s *server.Server, token, subject string) (*nats.Conn, *nats.Subscription) {
+	nc, err := nats.Connect(s.ClientURL(), nats.Token(token))
+	if err != nil {
+		t.Fatalf("Failed to connect the subscriber: %v", err)
+	}
+	sub, err := nc.SubscribeSync(subject)
+	if err != nil {
+		t.Fatalf("Failed to subscribe to %q: %v", subject, err)
+	}
+	if err := nc.Flush(); err != nil {
+		t.Fatalf("Failed to flush the subscription: %v", err)
+	}
+	return nc, sub
+}
+
+func nextMessage(t *testing.T, sub *nats.Subscription) (string, *agenttracepb.ExportTraceServiceRequest) {
+	t.Helper()
+	msg, err := sub.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatalf("Failed to receive a message: %v", err)
+	}
+	req := new(agenttracepb.ExportTraceServiceRequest)
+	if err := jsonpb.UnmarshalString(string(msg.Data), req); err != nil {
+		t.Fatalf("Failed to decode the message %q: %v", msg.Data, err)
+	}
+	return msg.Subject, req
+}
+
+func TestNATSExportersFromViper(t *testing.T) {
+	s := runServer(t, "secret", false)
+	defer stopServer(s)
+	subscriber, sub := subscribe(t, s, "secret", "spans.>")
+	defer subscriber.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(`nats:
+  url: ` + s.ClientURL() + `
+  subject: "spans.{{.ServiceName}}.{{.TraceID}}"
+  token: secret
+`))
+	tps, _, doneFns, err := NATSExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer func() {
+		for _, done := range doneFns {
+			done()
+		}
+	}()
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+	td := testTraceData()
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	wantSubjects := []string{
+		"spans.nats-svc.0102030405060708090a0b0c0d0e0f10",
+		"spans.nats-svc.1112131415161718191a1b1c1d1e1f20",
+	}
+	for i, wantSubject := range wantSubjects {
+		subject, req := nextMessage(t, sub)
+		if subject != wantSubject {
+			t.Errorf("Got subject %q, want %q", subject, wantSubject)
+		}
+		if len(req.Spans) != 1 || req.Spans[0].GetName().GetValue() != td.Spans[i].Name.Value {
+			t.Errorf("Got spans %v, want the span %q", req.Spans, td.Spans[i].Name.Value)
+		}
+		if req.Node.GetServiceInfo().GetName() != "nats-svc" {
+			t.Errorf("Got node %v, want the one of the spans", req.Node)
+		}
+	}
+}
+
+func TestNATSExportersFromViper_errors(t *testing.T) {
+	s := runServer(t, "secret", false)
+	defer stopServer(s)
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "unknown mode", config: "mode: queue", wantErr: `unknown mode "queue"`},
+		{name: "invalid template", config: `subject: "spans.{{.ServiceName"`, wantErr: "invalid subject template"},
+		{name: "unknown field", config: `subject: "spans.{{.Service}}"`, wantErr: "failed to render the subject"},
+		{name: "wildcard", config: `subject: "spans.>"`, wantErr: "wildcards"},
+		{name: "authorization", config: "token: wrong", wantErr: "Authorization Violation"},
+		{name: "jetstream not enabled", config: "token: secret\n  mode: jetstream\n  timeout: 100ms", wantErr: "JetStream is not available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := "nats:\n  url: " + s.ClientURL() + "\n  " + tt.config + "\n"
+			v, _ := viperutils.ViperFromYAMLBytes([]byte(config))
+			_, _, _, err := NATSExportersFromViper(v)
+			if err == nil || !strings.Contains(strings.ToLower(err.Error()), strings.ToLower(tt.wantErr)) {
+				t.Errorf("Got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNATSExporterSubjects(t *testing.T) {
+	s := runServer(t, "", false)
+	defer stopServer(s)
+
+	ne, err := newNATSExporter(&natsConfig{URL: s.ClientURL(), Subject: "spans.{{.ServiceName}}"})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer ne.Close()
+
+	// A blank service name leaves an empty token in the subject.
+	td := testTraceData()
+	td.Node = nil
+	dropped, err := ne.pushTraceData(context.Background(), td)
+	if dropped != 2 || err == nil || !strings.Contains(err.Error(), "empty tokens") {
+		t.Errorf("Got %d dropped spans and error %v, want 2 and the invalid subject", dropped, err)
+	}
+}
+
+func TestNATSExporterJetStream(t *testing.T) {
+	s := runServer(t, "", true)
+	defer stopServer(s)
+
+	newExporter := func(subject string) *natsExporter {
+		ne, err := newNATSExporter(&natsConfig{URL: s.ClientURL(), Subject: subject, Mode: modeJetStream, Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("Failed to create the exporter: %v", err)
+		}
+		return ne
+	}
+
+	ne := newExporter("spans.stored.{{.ServiceName}}")
+	defer ne.Close()
+	// The stream keeps 2 spans and refuses the next ones.
+	if _, err := ne.js.AddStream(&nats.StreamConfig{
+		Name:     "SPANS",
+		Subjects: []string{"spans.stored.>"},
+		MaxMsgs:  2,
+		Discard:  nats.DiscardNew,
+	}); err != nil {
+		t.Fatalf("Failed to create the stream: %v", err)
+	}
+
+	if dropped, err := ne.pushTraceData(context.Background(), testTraceData()); dropped != 0 || err != nil {
+		t.Errorf("Got %d dropped spans and error %v, want none", dropped, err)
+	}
+	info, err := ne.js.StreamInfo("SPANS")
+	if err != nil {
+		t.Fatalf("Failed to get the stream info: %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Errorf("Got %d stored spans, want 2", info.State.Msgs)
+	}
+
+	dropped, err := ne.pushTraceData(context.Background(), testTraceData())
+	if dropped != 2 || err == nil || !strings.Contains(err.Error(), "maximum messages exceeded") {
+		t.Errorf("Got %d dropped spans and error %v, want 2 and the refusal of the stream", dropped, err)
+	}
+
+	unbound := newExporter("spans.unbound.{{.ServiceName}}")
+	defer unbound.Close()
+	dropped, err = unbound.pushTraceData(context.Background(), testTraceData())
+	if dropped != 2 || err == nil || !strings.Contains(err.Error(), "no JetStream stream is bound") {
+		t.Errorf("Got %d dropped spans and error %v, want 2 and no bound stream", dropped, err)
+	}
+}
+
+// waitFor waits up to 5s for cond to hold.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNATSExporterReconnects(t *testing.T) {
+	s := runServer(t, "", false)
+	port := s.Addr().(*net.TCPAddr).Port
+
+	unlimited := -1
+	ne, err := newNATSExporter(&natsConfig{URL: s.ClientURL(), MaxReconnects: &unlimited, ReconnectWait: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer ne.Close()
+
+	stopServer(s)
+	waitFor(t, "the disconnection", func() bool { return !ne.conn.IsConnected() })
+
+	opts := natsserver.DefaultTestOptions
+	opts.Port = port
+	s = natsserver.RunServer(&opts)
+	defer stopServer(s)
+	waitFor(t, "the reconnection", ne.conn.IsConnected)
+
+	subscriber, sub := subscribe(t, s, "", defaultSubject)
+	defer subscriber.Close()
+	if dropped, err := ne.pushTraceData(context.Background(), testTraceData()); dropped != 0 || err != nil {
+		t.Errorf("Got %d dropped spans and error %v after reconnecting, want none", dropped, err)
+	}
+	nextMessage(t, sub)
+	nextMessage(t, sub)
+	if got := ne.conn.Stats().Reconnects; got != 1 {
+		t.Errorf("Got %d reconnections, want 1", got)
+	}
+}
+
+func TestNATSExporterMaxReconnects(t *testing.T) {
+	s := runServer(t, "", false)
+
+	maxReconnects := 2
+	ne, err := newNATSExporter(&natsConfig{URL: s.ClientURL(), MaxReconnects: &maxReconnects, ReconnectWait: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer ne.Close()
+
+	stopServer(s)
+	waitFor(t, "the client to give up reconnecting", ne.conn.IsClosed)
+	dropped, err := ne.pushTraceData(context.Background(), testTraceData())
+	if dropped != 2 || err == nil || !strings.Contains(err.Error(), nats.ErrConnectionClosed.Error()) {
+		t.Errorf("Got %d dropped spans and error %v, want 2 and %v", dropped, err, nats.ErrConnectionClosed)
+	}
+}
+
+func TestNATSExporterClose(t *testing.T) {
+	s := runServer(t, "", false)
+	defer stopServer(s)
+
+	ne, err := newNATSExporter(&natsConfig{URL: s.ClientURL()})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	ne.Close()
+	dropped, err := ne.pushTraceData(context.Background(), testTraceData())
+	if dropped != 2 || err == nil || !strings.Contains(err.Error(), nats.ErrConnectionClosed.Error()) {
+		t.Errorf("Got %d dropped spans and error %v, want 2 and %v", dropped, err, nats.ErrConnectionClosed)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package newrelicexporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+)
+
+// harvester buffers the spans and posts them to the trace API every period,
+// as the telemetry.Harvester of the New Relic Telemetry SDK does. The spans
+// of a failed harvest are dropped.
+type harvester struct {
+	url       string
+	insertKey string
+	client    *http.Client
+	logError  func(error)
+
+	mu    sync.Mutex
+	spans []span
+
+	closeOnce sync.Once
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+	closeErr  error
+}
+
+func newHarvester(url, insertKey string, period, timeout time.Duration, logError func(error)) *harvester {
+	h := &harvester{
+		url:       url,
+		insertKey: insertKey,
+		client:    exporterhelper.NewHTTPClient(timeout),
+		logError:  logError,
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+	go h.loop(period)
+	return h
+}
+
+func (h *harvester) loop(period time.Duration) {
+	defer close(h.doneCh)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := h.harvestNow(); err != nil {
+				h.logError(err)
+			}
+		case <-h.stopCh:
+			return
+		}
+	}
+}
+
+func (h *harvester) recordSpan(s span) {
+	h.mu.Lock()
+	h.spans = append(h.spans, s)
+	h.mu.Unlock()
+}
+
+// harvestNow posts the spans recorded since the last harvest.
+func (h *harvester) harvestNow() error {
+	h.mu.Lock()
+	spans := h.spans
+	h.spans = nil
+	h.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// The trace API takes a list of batches, the spans are sent in one.
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode([]struct {
+		Spans []span `json:"spans"`
+	}{{Spans: spans}}); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("X-Insert-Key", h.insertKey)
+	req.Header.Set("Data-Format", "newrelic")
+	req.Header.Set("Data-Format-Version", "1")
+	if err := exporterhelper.SendHTTPRequest(context.Background(), h.client, req); err != nil {
+		return fmt.Errorf("newrelic request of %d spans failed: %v", len(spans), err)
+	}
+	return nil
+}
+
+// Close stops the background harvest and harvests the remaining spans.
+func (h *harvester) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.stopCh)
+		<-h.doneCh
+		h.closeErr = h.harvestNow()
+	})
+	return h.closeErr
+}
@@ -0,0 +1,206 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package newrelicexporter sends the received spans to the trace API of
+// New Relic, in the format of the New Relic Telemetry SDK.
+package newrelicexporter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
+)
+
+const (
+	defaultSpansURL       = "https://trace-api.newrelic.com/trace/v1"
+	defaultServiceName    = "ocservice"
+	defaultHarvestPeriod  = 5 * time.Second
+	defaultHarvestTimeout = 15 * time.Second
+)
+
+type newRelicConfig struct {
+	// InsertKey is the Insights insert key of the account.
+	InsertKey string `mapstructure:"insert_key"`
+
+	// SpansURL is the URL of the trace API,
+	// https://trace-api.newrelic.com/trace/v1 by default.
+	SpansURL string `mapstructure:"spans_url"`
+
+	// ServiceName is the service name of the spans without a service.name
+	// attribute, "ocservice" by default.
+	ServiceName string `mapstructure:"service_name"`
+
+	// HarvestPeriod is the period of the background flush of the spans,
+	// 5s by default.
+	HarvestPeriod time.Duration `mapstructure:"harvest_period"`
+
+	// HarvestTimeout is the timeout of each flush, 15s by default.
+	HarvestTimeout time.Duration `mapstructure:"harvest_timeout"`
+}
+
+var errMissingInsertKey = errors.New("expecting a non-blank insert_key for the newrelic exporter")
+
+// NewRelicExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// sending the spans to New Relic according to the configuration settings. The
+// errors of the harvests are logged to the global zap logger, see
+// NewRelicExportersFromViperWithLogger.
+func NewRelicExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	return NewRelicExportersFromViperWithLogger(v, zap.L())
+}
+
+// NewRelicExportersFromViperWithLogger is NewRelicExportersFromViper logging
+// the errors of the harvests, sent in the background, to logger.
+func NewRelicExportersFromViperWithLogger(v *viper.Viper, logger *zap.Logger) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		NewRelic *newRelicConfig `mapstructure:"newrelic"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	nc := cfg.NewRelic
+	if nc == nil {
+		return nil, nil, nil, nil
+	}
+
+	ne, err := newNewRelicExporter(nc, func(err error) {
+		logger.Warn("Failed to harvest the spans to New Relic", zap.Error(err))
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure newrelic exporter: %v", err)
+	}
+
+	nte, err := exporterwrapper.NewExporterWrapper("newrelic", "ocservice.exporter.NewRelic.ConsumeTraceData", ne)
+	if err != nil {
+		ne.harvester.Close()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, nte)
+	doneFns = append(doneFns, ne.harvester.Close)
+	return
+}
+
+// newRelicExporter is a trace.Exporter recording the spans in a harvester,
+// which sends them to New Relic in the background.
+type newRelicExporter struct {
+	serviceName string
+	harvester   *harvester
+}
+
+var _ trace.Exporter = (*newRelicExporter)(nil)
+
+func newNewRelicExporter(nc *newRelicConfig, logError func(error)) (*newRelicExporter, error) {
+	if nc.InsertKey == "" {
+		return nil, errMissingInsertKey
+	}
+	spansURL := nc.SpansURL
+	if spansURL == "" {
+		spansURL = defaultSpansURL
+	}
+	u, err := url.Parse(spansURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the newrelic spans_url %q", u.Scheme, spansURL)
+	}
+
+	serviceName := nc.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	period := nc.HarvestPeriod
+	if period <= 0 {
+		period = defaultHarvestPeriod
+	}
+	timeout := nc.HarvestTimeout
+	if timeout <= 0 {
+		timeout = defaultHarvestTimeout
+	}
+	return &newRelicExporter{
+		serviceName: serviceName,
+		harvester:   newHarvester(spansURL, nc.InsertKey, period, timeout, logError),
+	}, nil
+}
+
+func (ne *newRelicExporter) ExportSpan(sd *trace.SpanData) {
+	ne.harvester.recordSpan(spanDataToSpan(sd, ne.serviceName))
+}
+
+// span is a span of the trace API, with the fields of the telemetry.Span of
+// the New Relic Telemetry SDK, see
+// https://docs.newrelic.com/docs/understand-dependencies/distributed-tracing/trace-api/report-new-relic-format-traces-trace-api
+type span struct {
+	ID         string                 `json:"id"`
+	TraceID    string                 `json:"trace.id"`
+	Timestamp  int64                  `json:"timestamp"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// attributeNames maps the names of the OpenCensus attributes to the names New
+// Relic gives them, the other attributes keep their names.
+var attributeNames = map[string]string{
+	"http.status_code": "http.statusCode",
+}
+
+// spanDataToSpan converts sd to a span of the trace API. The attributes of sd
+// take precedence over the intrinsic attributes but for service.name, taken
+// from the attributes of sd if there is one.
+func spanDataToSpan(sd *trace.SpanData, serviceName string) span {
+	attrs := make(map[string]interface{}, len(sd.Attributes)+6)
+	for k, v := range sd.Attributes {
+		if name, ok := attributeNames[k]; ok {
+			k = name
+		}
+		attrs[k] = v
+	}
+	setDefault := func(k string, v interface{}) {
+		if _, ok := attrs[k]; !ok {
+			attrs[k] = v
+		}
+	}
+	setDefault("service.name", serviceName)
+	setDefault("name", sd.Name)
+	setDefault("duration.ms", float64(sd.EndTime.Sub(sd.StartTime))/float64(time.Millisecond))
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		setDefault("parent.id", sd.ParentSpanID.String())
+	}
+	switch sd.SpanKind {
+	case trace.SpanKindServer:
+		setDefault("span.kind", "server")
+	case trace.SpanKindClient:
+		setDefault("span.kind", "client")
+	}
+	if sd.Code != 0 {
+		setDefault("error", true)
+		setDefault("status.code", sd.Code)
+		if sd.Message != "" {
+			setDefault("status.message", sd.Message)
+		}
+	}
+	return span{
+		ID:         sd.SpanID.String(),
+		TraceID:    sd.TraceID.String(),
+		Timestamp:  sd.StartTime.UnixNano() / int64(time.Millisecond),
+		Attributes: attrs,
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package newrelicexporter
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+// newFakeTraceAPI returns a fake trace API replying with status.
+func newFakeTraceAPI(status int) *testutils.HTTPRecorder {
+	return &testutils.HTTPRecorder{
+		Status:    status,
+		Body:      `{"requestId":"f0e1d2c3"}`,
+		ErrorBody: `{"error":"invalid insert key"}`,
+	}
+}
+
+// spanBatches returns the batches of spans of the gzipped bodies.
+func spanBatches(t *testing.T, bodies []string) [][]map[string]interface{} {
+	var spans [][]map[string]interface{}
+	for _, body := range bodies {
+		var batches []struct {
+			Spans []map[string]interface{} `json:"spans"`
+		}
+		zr, err := gzip.NewReader(strings.NewReader(body))
+		if err == nil {
+			err = json.NewDecoder(zr).Decode(&batches)
+		}
+		if err != nil {
+			t.Fatalf("Failed to decode the batches: %v", err)
+		}
+		for _, b := range batches {
+			spans = append(spans, b.Spans)
+		}
+	}
+	return spans
+}
+
+func TestNewRelicExporterSendsSpans(t *testing.T) {
+	fake := newFakeTraceAPI(http.StatusAccepted)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("newrelic:\n  insert_key: secret\n  spans_url: " + srv.URL + "/trace/v1\n" +
+		"  service_name: frontend\n  harvest_period: 1h\n"))
+	tps, _, doneFns, err := NewRelicExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 || len(doneFns) != 1 {
+		t.Fatalf("Got %d trace exporters and %d done functions, want 1 and 1", len(tps), len(doneFns))
+	}
+
+	td := data.TraceData{
+		Resource: &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "staging"}},
+		Spans: []*tracepb.Span{{
+			TraceId:      []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			ParentSpanId: []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+			Name:         &tracepb.TruncatableString{Value: "GET /api"},
+			Kind:         tracepb.Span_SERVER,
+			StartTime:    &timestamp.Timestamp{Seconds: 1564617600, Nanos: 2000000},
+			EndTime:      &timestamp.Timestamp{Seconds: 1564617600, Nanos: 3500000},
+			Attributes: &tracepb.Span_Attributes{
+				AttributeMap: map[string]*tracepb.AttributeValue{
+					"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 500}},
+					"http.method":      {Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "GET"}}},
+				},
+			},
+			Status: &tracepb.Status{Code: 13, Message: "internal"},
+		}},
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+	if n := len(fake.Requests()); n != 0 {
+		t.Fatalf("Got %d requests before the harvest, want 0", n)
+	}
+	if err := doneFns[0](); err != nil {
+		t.Fatalf("Failed to harvest the spans: %v", err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Got %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/trace/v1" {
+		t.Errorf("Got %s %s, want POST /trace/v1", r.Method, r.URL.Path)
+	}
+	for header, want := range map[string]string{
+		"X-Insert-Key":        "secret",
+		"Data-Format":         "newrelic",
+		"Data-Format-Version": "1",
+		"Content-Encoding":    "gzip",
+	} {
+		if got := r.Header.Get(header); got != want {
+			t.Errorf("Got %s %q, want %q", header, got, want)
+		}
+	}
+
+	want := [][]map[string]interface{}{{{
+		"id":        "0102030405060708",
+		"trace.id":  "0102030405060708090a0b0c0d0e0f10",
+		"timestamp": float64(1564617600002),
+		"attributes": map[string]interface{}{
+			"name":            "GET /api",
+			"service.name":    "frontend",
+			"parent.id":       "0807060504030201",
+			"duration.ms":     1.5,
+			"span.kind":       "server",
+			"http.statusCode": float64(500),
+			"http.method":     "GET",
+			"k8s.namespace":   "staging",
+			"error":           true,
+			"status.code":     float64(13),
+			"status.message":  "internal",
+		},
+	}}}
+	if got := spanBatches(t, fake.Bodies()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got batches\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestNewRelicExporterHarvestsPeriodically(t *testing.T) {
+	fake := newFakeTraceAPI(http.StatusAccepted)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ne, err := newNewRelicExporter(&newRelicConfig{InsertKey: "secret", SpansURL: srv.URL, HarvestPeriod: 10 * time.Millisecond}, func(err error) {
+		t.Errorf("Got harvest error %v, want none", err)
+	})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer ne.harvester.Close()
+
+	start := time.Unix(1, 0)
+	ne.ExportSpan(&trace.SpanData{Name: "a", StartTime: start, EndTime: start.Add(time.Second)})
+	ne.ExportSpan(&trace.SpanData{Name: "b", StartTime: start, EndTime: start.Add(time.Second)})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.Requests()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	batches := spanBatches(t, fake.Bodies())
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("Got batches %v, want a single batch of 2 spans", batches)
+	}
+	if got := batches[0][0]["attributes"].(map[string]interface{})["service.name"]; got != defaultServiceName {
+		t.Errorf("Got service.name %v, want %q", got, defaultServiceName)
+	}
+}
+
+func TestNewRelicExporterHarvestError(t *testing.T) {
+	srv := httptest.NewServer(newFakeTraceAPI(http.StatusForbidden))
+	defer srv.Close()
+
+	ne, err := newNewRelicExporter(&newRelicConfig{InsertKey: "wrong", SpansURL: srv.URL, HarvestPeriod: time.Hour}, func(error) {})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	ne.ExportSpan(&trace.SpanData{Name: "a"})
+	err = ne.harvester.Close()
+	if err == nil || !strings.Contains(err.Error(), "status 403") || !strings.Contains(err.Error(), "invalid insert key") {
+		t.Errorf("Got error %v, want the status and the message of the trace API", err)
+	}
+	if err := ne.harvester.Close(); err == nil {
+		t.Error("Got no error from the second Close, want the error of the first one")
+	}
+}
+
+func TestNewRelicExportersFromViperErrors(t *testing.T) {
+	testutils.CheckExportersFromViperErrors(t, NewRelicExportersFromViper, []testutils.InvalidConfig{
+		{Name: "missing insert key", Config: "newrelic:\n  service_name: frontend\n"},
+		{Name: "invalid scheme", Config: "newrelic:\n  insert_key: secret\n  spans_url: ftp://trace-api.newrelic.com\n"},
+	})
+}
@@ -0,0 +1,292 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package orderedexporter provides an exporter that sends the spans it
+// receives in batches sorted by start time, for the backends, such as some
+// log stores, that require the spans in chronological order.
+package orderedexporter
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+const (
+	// DefaultBufferSize is the default number of spans after which the
+	// buffered spans are flushed.
+	DefaultBufferSize = 1024
+	// DefaultMaxDelay is the default time after which the buffered spans are
+	// flushed, regardless of their number.
+	DefaultMaxDelay = 5 * time.Second
+)
+
+var (
+	errEmptyExporterFormat = errors.New("empty exporter format")
+	errNilBatchExporter    = errors.New("nil batch exporter")
+)
+
+// Span is a span buffered by the OrderedExporter, along with the node and
+// resource it was received with.
+type Span struct {
+	Node         *commonpb.Node
+	Resource     *resourcepb.Resource
+	SourceFormat string
+	Span         *tracepb.Span
+}
+
+// BatchExporter exports the batches flushed by the OrderedExporter, the spans
+// of a batch being sorted by ascending start time.
+type BatchExporter interface {
+	ExportBatch(ctx context.Context, spans []*Span) error
+}
+
+// BatchExporterFunc is an adapter to use a function as a BatchExporter.
+type BatchExporterFunc func(ctx context.Context, spans []*Span) error
+
+var _ BatchExporter = (BatchExporterFunc)(nil)
+
+// ExportBatch calls f(ctx, spans).
+func (f BatchExporterFunc) ExportBatch(ctx context.Context, spans []*Span) error {
+	return f(ctx, spans)
+}
+
+// Option is an option to the OrderedExporter.
+type Option func(*OrderedExporter)
+
+// WithBufferSize sets the number of spans after which the buffered spans are
+// flushed.
+func WithBufferSize(bufferSize int) Option {
+	return func(e *OrderedExporter) {
+		e.bufferSize = bufferSize
+	}
+}
+
+// WithMaxDelay sets the time after which the buffered spans are flushed,
+// regardless of their number. It is counted from the first span added to an
+// empty buffer.
+func WithMaxDelay(maxDelay time.Duration) Option {
+	return func(e *OrderedExporter) {
+		e.maxDelay = maxDelay
+	}
+}
+
+// WithLogger sets the logger used to report the errors of the flushes
+// triggered by the MaxDelay, which have no caller to return them to.
+func WithLogger(logger *zap.Logger) Option {
+	return func(e *OrderedExporter) {
+		e.logger = logger
+	}
+}
+
+// OrderedExporter is an exporter.TraceExporter that buffers the spans it
+// receives and, once BufferSize spans are buffered or MaxDelay elapsed,
+// sends them sorted by start time to a BatchExporter. The batches are sent
+// one at a time, in the order they are flushed.
+type OrderedExporter struct {
+	exporterFormat string
+	next           BatchExporter
+	bufferSize     int
+	maxDelay       time.Duration
+	logger         *zap.Logger
+
+	// exportMu serializes the exports so the batches are received by next in
+	// the order they were flushed.
+	exportMu sync.Mutex
+
+	mu     sync.Mutex
+	buffer []*Span
+	timer  *time.Timer
+	// generation is incremented on each flush so a timer firing late doesn't
+	// flush the following batch early.
+	generation uint64
+	stopped    bool
+}
+
+var _ exporter.TraceExporter = (*OrderedExporter)(nil)
+
+// NewOrderedExporter creates an OrderedExporter sending the sorted batches of
+// spans to next.
+func NewOrderedExporter(exporterFormat string, next BatchExporter, opts ...Option) (*OrderedExporter, error) {
+	if exporterFormat == "" {
+		return nil, errEmptyExporterFormat
+	}
+	if next == nil {
+		return nil, errNilBatchExporter
+	}
+
+	e := &OrderedExporter{
+		exporterFormat: exporterFormat,
+		next:           next,
+		bufferSize:     DefaultBufferSize,
+		maxDelay:       DefaultMaxDelay,
+		logger:         zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// TraceExportFormat returns the format given to NewOrderedExporter.
+func (e *OrderedExporter) TraceExportFormat() string {
+	return e.exporterFormat
+}
+
+// ConsumeTraceData buffers the spans of td. When the buffer is full it is
+// flushed before returning, and the error of the BatchExporter is returned.
+// Once the OrderedExporter is stopped the spans are sent right away.
+func (e *OrderedExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	spans := make([]*Span, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		if span != nil {
+			spans = append(spans, &Span{
+				Node:         td.Node,
+				Resource:     td.Resource,
+				SourceFormat: td.SourceFormat,
+				Span:         span,
+			})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		return e.export(ctx, spans)
+	}
+	if len(e.buffer) == 0 && e.maxDelay > 0 {
+		generation := e.generation
+		e.timer = time.AfterFunc(e.maxDelay, func() {
+			if err := e.flush(context.Background(), &generation); err != nil {
+				e.logger.Warn("Failed to export the ordered spans",
+					zap.String("exporter", e.exporterFormat), zap.Error(err))
+			}
+		})
+	}
+	e.buffer = append(e.buffer, spans...)
+	full := len(e.buffer) >= e.bufferSize
+	e.mu.Unlock()
+
+	if full {
+		return e.flush(ctx, nil)
+	}
+	return nil
+}
+
+// Flush sends the buffered spans to the BatchExporter and returns its error.
+func (e *OrderedExporter) Flush(ctx context.Context) error {
+	return e.flush(ctx, nil)
+}
+
+// Stop flushes the buffered spans. The spans received afterwards are sent
+// right away, one TraceData at a time.
+func (e *OrderedExporter) Stop() error {
+	e.mu.Lock()
+	e.stopped = true
+	e.mu.Unlock()
+	return e.flush(context.Background(), nil)
+}
+
+// flush sends the buffered spans. If generation is non-nil they are only sent
+// if no flush happened since that generation.
+func (e *OrderedExporter) flush(ctx context.Context, generation *uint64) error {
+	e.exportMu.Lock()
+	defer e.exportMu.Unlock()
+
+	e.mu.Lock()
+	if generation != nil && *generation != e.generation {
+		e.mu.Unlock()
+		return nil
+	}
+	spans := e.buffer
+	e.buffer = nil
+	e.generation++
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	e.mu.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	return e.next.ExportBatch(ctx, sortByStartTime(spans))
+}
+
+func (e *OrderedExporter) export(ctx context.Context, spans []*Span) error {
+	e.exportMu.Lock()
+	defer e.exportMu.Unlock()
+	return e.next.ExportBatch(ctx, sortByStartTime(spans))
+}
+
+// sortByStartTime sorts the spans by ascending start time, the spans without
+// one first, keeping the order of the spans starting at the same time.
+func sortByStartTime(spans []*Span) []*Span {
+	sort.SliceStable(spans, func(i, j int) bool {
+		ti, tj := spans[i].Span.StartTime, spans[j].Span.StartTime
+		switch {
+		case tj == nil:
+			return false
+		case ti == nil:
+			return true
+		case ti.Seconds != tj.Seconds:
+			return ti.Seconds < tj.Seconds
+		}
+		return ti.Nanos < tj.Nanos
+	})
+	return spans
+}
+
+// NewTraceDataBatchExporter returns a BatchExporter sending the batches to
+// next, the consecutive spans of a batch received with the same node,
+// resource and source format being sent in the same TraceData.
+func NewTraceDataBatchExporter(next consumer.TraceConsumer) BatchExporter {
+	return BatchExporterFunc(func(ctx context.Context, spans []*Span) error {
+		var errs []error
+		var td data.TraceData
+		for _, span := range spans {
+			if len(td.Spans) > 0 && (span.Node != td.Node || span.Resource != td.Resource || span.SourceFormat != td.SourceFormat) {
+				if err := next.ConsumeTraceData(ctx, td); err != nil {
+					errs = append(errs, err)
+				}
+				td = data.TraceData{}
+			}
+			if len(td.Spans) == 0 {
+				td.Node, td.Resource, td.SourceFormat = span.Node, span.Resource, span.SourceFormat
+			}
+			td.Spans = append(td.Spans, span.Span)
+		}
+		if len(td.Spans) > 0 {
+			if err := next.ConsumeTraceData(ctx, td); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return internal.CombineErrors(errs)
+	})
+}
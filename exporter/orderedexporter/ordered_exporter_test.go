@@ -0,0 +1,239 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package orderedexporter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+type recordingBatchExporter struct {
+	mu      sync.Mutex
+	batches [][]*Span
+	err     error
+	flushed chan struct{}
+}
+
+func newRecordingBatchExporter() *recordingBatchExporter {
+	return &recordingBatchExporter{flushed: make(chan struct{}, 16)}
+}
+
+func (r *recordingBatchExporter) ExportBatch(ctx context.Context, spans []*Span) error {
+	r.mu.Lock()
+	r.batches = append(r.batches, spans)
+	r.mu.Unlock()
+	r.flushed <- struct{}{}
+	return r.err
+}
+
+func (r *recordingBatchExporter) allBatches() [][]*Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.batches
+}
+
+// spansInReverseOrder returns n spans, named by their index, starting 1ms
+// apart in descending order.
+func spansInReverseOrder(n int) []*tracepb.Span {
+	spans := make([]*tracepb.Span, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		spans = append(spans, &tracepb.Span{
+			Name:      &tracepb.TruncatableString{Value: string('a' + rune(i))},
+			StartTime: &timestamp.Timestamp{Seconds: 1000, Nanos: int32(i * 1e6)},
+		})
+	}
+	return spans
+}
+
+func checkSorted(t *testing.T, spans []*Span, wantLen int) {
+	t.Helper()
+	if len(spans) != wantLen {
+		t.Fatalf("Got %d spans, want %d", len(spans), wantLen)
+	}
+	for i, span := range spans {
+		if want := string('a' + rune(i)); span.Span.Name.Value != want {
+			t.Errorf("Got span %q at index %d, want %q", span.Span.Name.Value, i, want)
+		}
+	}
+}
+
+func TestNewOrderedExporterErrors(t *testing.T) {
+	if _, err := NewOrderedExporter("", newRecordingBatchExporter()); err != errEmptyExporterFormat {
+		t.Errorf("Got error %v, want %v", err, errEmptyExporterFormat)
+	}
+	if _, err := NewOrderedExporter("test", nil); err != errNilBatchExporter {
+		t.Errorf("Got error %v, want %v", err, errNilBatchExporter)
+	}
+}
+
+func TestFlushSortsByStartTime(t *testing.T) {
+	next := newRecordingBatchExporter()
+	e, err := NewOrderedExporter("test", next, WithMaxDelay(0))
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if got := e.TraceExportFormat(); got != "test" {
+		t.Errorf("Got format %q, want %q", got, "test")
+	}
+
+	// Send the spans in reverse order, split across several TraceData.
+	spans := spansInReverseOrder(6)
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc"}}
+	for i := 0; i < len(spans); i += 2 {
+		td := data.TraceData{Node: node, Spans: spans[i : i+2]}
+		if err := e.ConsumeTraceData(context.Background(), td); err != nil {
+			t.Fatalf("ConsumeTraceData failed: %v", err)
+		}
+	}
+	if got := next.allBatches(); len(got) != 0 {
+		t.Fatalf("Got %d batches before the flush, want none", len(got))
+	}
+
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	batches := next.allBatches()
+	if len(batches) != 1 {
+		t.Fatalf("Got %d batches, want 1", len(batches))
+	}
+	checkSorted(t, batches[0], len(spans))
+	if batches[0][0].Node != node {
+		t.Errorf("Got node %v, want %v", batches[0][0].Node, node)
+	}
+
+	// An empty buffer isn't flushed.
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if got := len(next.allBatches()); got != 1 {
+		t.Errorf("Got %d batches after flushing an empty buffer, want 1", got)
+	}
+}
+
+func TestFlushOnBufferSize(t *testing.T) {
+	next := newRecordingBatchExporter()
+	next.err = errors.New("export failed")
+	e, _ := NewOrderedExporter("test", next, WithBufferSize(4), WithMaxDelay(0))
+
+	spans := spansInReverseOrder(4)
+	if err := e.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans[:3]}); err != nil {
+		t.Fatalf("ConsumeTraceData failed: %v", err)
+	}
+	if got := len(next.allBatches()); got != 0 {
+		t.Fatalf("Got %d batches, want none", got)
+	}
+	if err := e.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans[3:]}); err != next.err {
+		t.Fatalf("Got error %v, want %v", err, next.err)
+	}
+	batches := next.allBatches()
+	if len(batches) != 1 {
+		t.Fatalf("Got %d batches, want 1", len(batches))
+	}
+	checkSorted(t, batches[0], len(spans))
+}
+
+func TestFlushOnMaxDelay(t *testing.T) {
+	next := newRecordingBatchExporter()
+	e, _ := NewOrderedExporter("test", next, WithMaxDelay(10*time.Millisecond))
+
+	if err := e.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansInReverseOrder(3)}); err != nil {
+		t.Fatalf("ConsumeTraceData failed: %v", err)
+	}
+	select {
+	case <-next.flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The spans were not flushed after the max delay")
+	}
+	batches := next.allBatches()
+	if len(batches) != 1 {
+		t.Fatalf("Got %d batches, want 1", len(batches))
+	}
+	checkSorted(t, batches[0], 3)
+}
+
+func TestStop(t *testing.T) {
+	next := newRecordingBatchExporter()
+	e, _ := NewOrderedExporter("test", next)
+
+	if err := e.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansInReverseOrder(2)}); err != nil {
+		t.Fatalf("ConsumeTraceData failed: %v", err)
+	}
+	if err := e.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if got := next.allBatches(); len(got) != 1 {
+		t.Fatalf("Got %d batches after Stop, want 1", len(got))
+	}
+
+	// The spans received after Stop are sent right away.
+	if err := e.ConsumeTraceData(context.Background(), data.TraceData{Spans: spansInReverseOrder(2)}); err != nil {
+		t.Fatalf("ConsumeTraceData failed: %v", err)
+	}
+	batches := next.allBatches()
+	if len(batches) != 2 {
+		t.Fatalf("Got %d batches, want 2", len(batches))
+	}
+	checkSorted(t, batches[1], 2)
+}
+
+func TestSortByStartTimeWithoutStartTime(t *testing.T) {
+	spans := []*Span{
+		{Span: &tracepb.Span{Name: &tracepb.TruncatableString{Value: "b"}, StartTime: &timestamp.Timestamp{Seconds: 1}}},
+		{Span: &tracepb.Span{Name: &tracepb.TruncatableString{Value: "a"}}},
+		{Span: &tracepb.Span{Name: &tracepb.TruncatableString{Value: "c"}, StartTime: &timestamp.Timestamp{Seconds: 1, Nanos: 1}}},
+	}
+	checkSorted(t, sortByStartTime(spans), 3)
+}
+
+func TestTraceDataBatchExporter(t *testing.T) {
+	sink := new(exportertest.SinkTraceExporter)
+	next := NewTraceDataBatchExporter(sink)
+
+	node1 := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc1"}}
+	node2 := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc2"}}
+	spans := spansInReverseOrder(4)
+	batch := []*Span{
+		{Node: node1, Span: spans[0]},
+		{Node: node1, Span: spans[1]},
+		{Node: node2, Span: spans[2]},
+		{Node: node1, Span: spans[3]},
+	}
+	if err := next.ExportBatch(context.Background(), batch); err != nil {
+		t.Fatalf("ExportBatch failed: %v", err)
+	}
+
+	got := sink.AllTraces()
+	wantNodes := []*commonpb.Node{node1, node2, node1}
+	wantLens := []int{2, 1, 1}
+	if len(got) != len(wantNodes) {
+		t.Fatalf("Got %d TraceData, want %d", len(got), len(wantNodes))
+	}
+	for i, td := range got {
+		if td.Node != wantNodes[i] || len(td.Spans) != wantLens[i] {
+			t.Errorf("TraceData %d: got node %v with %d spans, want node %v with %d spans",
+				i, td.Node, len(td.Spans), wantNodes[i], wantLens[i])
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp sends the received spans to an OpenTelemetry collector, or any
+// other backend ingesting OTLP over gRPC, for the migrations from OpenCensus
+// to OpenTelemetry.
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	durationpb "github.com/golang/protobuf/ptypes/duration"
+	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	otlptranslator "github.com/census-instrumentation/opencensus-service/translator/trace/otlp"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultEndpoint            = "localhost:4317"
+	defaultTimeout             = 10 * time.Second
+	defaultMaxRetries          = 5
+	defaultRetryBackoff        = 100 * time.Millisecond
+	defaultMaxReconnectBackoff = 30 * time.Second
+
+	// ExportMethod is the method of the OTLP trace service.
+	ExportMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+
+	// retryInfoTypeURL is the type of the google.rpc.RetryInfo details of the
+	// errors, holding the delay before retrying.
+	retryInfoTypeURL = "type.googleapis.com/google.rpc.RetryInfo"
+
+	serviceNameAttribute = "service.name"
+)
+
+// Config is the configuration of an OTLP exporter.
+type Config struct {
+	// Endpoint is the host:port of the OTLP gRPC receiver, localhost:4317 by
+	// default.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Headers are added to the metadata of the exports.
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Insecure disables TLS. Otherwise CertPemFile is the certificate of the
+	// CA of the server, the system ones are used if it's blank.
+	Insecure    bool   `mapstructure:"insecure"`
+	CertPemFile string `mapstructure:"cert_pem_file"`
+
+	// Timeout is the deadline of each Export RPC, 10s by default. The RPCs
+	// wait for the connection to be reestablished up to it.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxRetries is the number of retries of the exports refused because the
+	// server is unavailable or overloaded, 5 by default. RetryBackoff is the
+	// delay before the first retry, doubled before each of the next ones,
+	// unless the server returned the delay to wait for.
+	MaxRetries   int           `mapstructure:"max_retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+
+	// MaxReconnectBackoff caps the exponential backoff between the attempts
+	// to reconnect to the server, 30s by default.
+	MaxReconnectBackoff time.Duration `mapstructure:"max_reconnect_backoff"`
+}
+
+// OTLPExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// sending the spans over OTLP according to the configuration settings.
+func OTLPExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		OTLP *Config `mapstructure:"otlp"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	oc := cfg.OTLP
+	if oc == nil {
+		return nil, nil, nil, nil
+	}
+
+	oe, err := NewExporter(oc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure otlp exporter: %v", err)
+	}
+
+	ote, err := exporterhelper.NewTraceExporter(
+		"otlp",
+		oe.PushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.OTLP.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		oe.Close()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, ote)
+	doneFns = append(doneFns, oe.Close)
+	return
+}
+
+// TraceServiceClient is the client of the OTLP trace service, the
+// CollectorTraceServiceClient of the generated OTLP code, which the module
+// doesn't depend on. The ExportTraceServiceResponse is ignored, its partial
+// success is only informative.
+type TraceServiceClient interface {
+	Export(ctx context.Context, req *otlptranslator.ExportRequest, opts ...grpc.CallOption) error
+}
+
+type traceServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewTraceServiceClient returns the client of the OTLP trace service of cc.
+func NewTraceServiceClient(cc *grpc.ClientConn) TraceServiceClient {
+	return &traceServiceClient{cc: cc}
+}
+
+func (c *traceServiceClient) Export(ctx context.Context, req *otlptranslator.ExportRequest, opts ...grpc.CallOption) error {
+	in := otlptranslator.MarshalExportRequest(req)
+	var out []byte
+	return c.cc.Invoke(ctx, ExportMethod, &in, &out, append(opts, grpc.CallCustomCodec(RawCodec{}))...)
+}
+
+// Exporter sends an OTLP ExportTraceServiceRequest for every batch of spans,
+// retrying it while the server pushes back. The connection is reestablished
+// by gRPC with an exponential backoff.
+type Exporter struct {
+	conn         *grpc.ClientConn
+	client       TraceServiceClient
+	headers      metadata.MD
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// NewExporter returns an Exporter connecting to the server of oc.
+func NewExporter(oc *Config) (*Exporter, error) {
+	endpoint := oc.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	maxReconnectBackoff := oc.MaxReconnectBackoff
+	if maxReconnectBackoff <= 0 {
+		maxReconnectBackoff = defaultMaxReconnectBackoff
+	}
+
+	opts := []grpc.DialOption{grpc.WithBackoffMaxDelay(maxReconnectBackoff)}
+	switch {
+	case oc.Insecure:
+		opts = append(opts, grpc.WithInsecure())
+	case oc.CertPemFile != "":
+		creds, err := credentials.NewClientTLSFromFile(oc.CertPemFile, "")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	default:
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(nil, "")))
+	}
+	conn, err := grpc.Dial(endpoint, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	oe := &Exporter{
+		conn:         conn,
+		client:       NewTraceServiceClient(conn),
+		headers:      metadata.New(oc.Headers),
+		timeout:      oc.Timeout,
+		maxRetries:   oc.MaxRetries,
+		retryBackoff: oc.RetryBackoff,
+	}
+	if oe.timeout <= 0 {
+		oe.timeout = defaultTimeout
+	}
+	if oe.maxRetries <= 0 {
+		oe.maxRetries = defaultMaxRetries
+	}
+	if oe.retryBackoff <= 0 {
+		oe.retryBackoff = defaultRetryBackoff
+	}
+	return oe, nil
+}
+
+// Close closes the connection to the server.
+func (oe *Exporter) Close() error {
+	return oe.conn.Close()
+}
+
+// PushTraceData is the exporterhelper.PushTraceData sending the spans of td.
+func (oe *Exporter) PushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	spans := make([]*trace.SpanData, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		spans = append(spans, sd)
+	}
+
+	dropped := len(td.Spans) - len(spans)
+	if len(spans) > 0 {
+		req := &otlptranslator.ExportRequest{
+			ResourceSpans: []*otlptranslator.ResourceSpans{
+				otlptranslator.SpanDataToResourceSpans(resourceLabels(td), spans),
+			},
+		}
+		if err := oe.export(ctx, req); err != nil {
+			errs = append(errs, err)
+			dropped = len(td.Spans)
+		}
+	}
+	return dropped, internal.CombineErrors(errs)
+}
+
+// resourceLabels returns the node attributes and the resource labels of td,
+// with the service name of the node unless they already have one.
+func resourceLabels(td data.TraceData) map[string]string {
+	labels := map[string]string{}
+	if ocResource := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource); ocResource != nil {
+		labels = ocResource.Labels
+	}
+	if name := td.Node.GetServiceInfo().GetName(); name != "" {
+		if _, ok := labels[serviceNameAttribute]; !ok {
+			labels[serviceNameAttribute] = name
+		}
+	}
+	return labels
+}
+
+// export sends the request. The exports refused because the server is
+// unavailable, or overloaded and telling how long to wait, are retried after
+// the delay returned by the server or else an exponential backoff, like the
+// OpenTelemetry exporters do.
+func (oe *Exporter) export(ctx context.Context, req *otlptranslator.ExportRequest) error {
+	ctx = metadata.NewOutgoingContext(ctx, oe.headers)
+	backoff := oe.retryBackoff
+	for retries := 0; ; retries++ {
+		err := oe.exportOnce(ctx, req)
+		if err == nil {
+			return nil
+		}
+		delay, retryable := retryDelay(err)
+		if !retryable || retries >= oe.maxRetries {
+			return err
+		}
+		if delay <= 0 {
+			delay = backoff
+			backoff *= 2
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+func (oe *Exporter) exportOnce(ctx context.Context, req *otlptranslator.ExportRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, oe.timeout)
+	defer cancel()
+	// Waiting for the connection to be ready rather than failing fast lets
+	// the exports made while reconnecting through, up to their deadline.
+	return oe.client.Export(ctx, req, grpc.WaitForReady(true))
+}
+
+// retryDelay tells whether the error of an export is retryable and the delay
+// before retrying it returned by the server, if any. The overloaded servers
+// return RESOURCE_EXHAUSTED, which is only retryable with such a delay.
+func retryDelay(err error) (time.Duration, bool) {
+	s, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	var delay time.Duration
+	for _, detail := range s.Proto().GetDetails() {
+		if detail.GetTypeUrl() == retryInfoTypeURL {
+			delay = decodeRetryInfo(detail.GetValue())
+		}
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+		return delay, true
+	case codes.ResourceExhausted:
+		return delay, delay > 0
+	}
+	return 0, false
+}
+
+// decodeRetryInfo returns the retry_delay of a RetryInfo, its only field, or
+// 0 if it can't be decoded.
+func decodeRetryInfo(b []byte) time.Duration {
+	buf := proto.NewBuffer(b)
+	if key, err := buf.DecodeVarint(); err != nil || key != 1<<3|2 {
+		return 0
+	}
+	raw, err := buf.DecodeRawBytes(false)
+	if err != nil {
+		return 0
+	}
+	var d durationpb.Duration
+	if err := proto.Unmarshal(raw, &d); err != nil {
+		return 0
+	}
+	delay, err := ptypes.Duration(&d)
+	if err != nil {
+		return 0
+	}
+	return delay
+}
+
+// RawCodec passes the already encoded messages, held by *[]byte, to gRPC, as
+// the OTLP messages are encoded by the otlp translator rather than generated
+// code.
+type RawCodec struct{}
+
+var _ grpc.Codec = RawCodec{}
+
+// Marshal returns the bytes held by v, a *[]byte.
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("RawCodec can't marshal %T", v)
+	}
+	return *b, nil
+}
+
+// Unmarshal copies data to v, a *[]byte.
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("RawCodec can't unmarshal to %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (RawCodec) String() string {
+	return "proto"
+}
@@ -0,0 +1,256 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp"
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp/otlptest"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+var testTraceData = data.TraceData{
+	Node: &commonpb.Node{
+		ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"},
+		Attributes:  map[string]string{"host.name": "h1"},
+	},
+	Resource: &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "prod"}},
+	Spans: []*tracepb.Span{
+		{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			Name:    &tracepb.TruncatableString{Value: "first"},
+		},
+		{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			Name:    &tracepb.TruncatableString{Value: "second"},
+		},
+	},
+}
+
+func startServer(t *testing.T, errs ...error) *otlptest.Server {
+	srv, err := otlptest.NewServer(errs...)
+	if err != nil {
+		t.Fatalf("Failed to start the OTLP server: %v", err)
+	}
+	return srv
+}
+
+func newExporter(t *testing.T, oc *otlp.Config) *otlp.Exporter {
+	oe, err := otlp.NewExporter(oc)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	return oe
+}
+
+func TestOTLPExportersFromViper(t *testing.T) {
+	srv := startServer(t)
+	defer srv.Stop()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(`otlp:
+  endpoint: ` + srv.Addr() + `
+  insecure: true
+  headers:
+    x-extra: extra
+`))
+	tps, _, doneFns, err := otlp.OTLPExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer func() {
+		for _, done := range doneFns {
+			done()
+		}
+	}()
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Got %d exports, want 1", len(requests))
+	}
+	if got := srv.Metadata()[0].Get("x-extra"); len(got) != 1 || got[0] != "extra" {
+		t.Errorf("Got x-extra header %v, want [extra]", got)
+	}
+
+	rss := requests[0].ResourceSpans
+	if len(rss) != 1 || len(rss[0].ScopeSpans) != 1 {
+		t.Fatalf("Got resource spans %+v, want a single scope", rss)
+	}
+	attrs := map[string]string{}
+	for _, kv := range rss[0].Resource.Attributes {
+		attrs[kv.Key] = *kv.Value.StringValue
+	}
+	want := map[string]string{"service.name": "checkout", "host.name": "h1", "k8s.namespace": "prod"}
+	if len(attrs) != len(want) {
+		t.Errorf("Got resource attributes %v, want %v", attrs, want)
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("Got resource attribute %s=%q, want %q", k, attrs[k], v)
+		}
+	}
+	spans := rss[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "first" || spans[1].Name != "second" {
+		t.Errorf("Got spans %+v, want the first and second ones", spans)
+	}
+}
+
+func TestExporterRetriesBackpressure(t *testing.T) {
+	overloaded, err := status.New(codes.ResourceExhausted, "overloaded").WithDetails(&otlptest.RetryInfo{Delay: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to add the retry info: %v", err)
+	}
+	srv := startServer(t, overloaded.Err(), status.Error(codes.Unavailable, "restarting"))
+	defer srv.Stop()
+
+	oe := newExporter(t, &otlp.Config{Endpoint: srv.Addr(), Insecure: true, RetryBackoff: time.Millisecond})
+	defer oe.Close()
+
+	start := time.Now()
+	dropped, err := oe.PushTraceData(context.Background(), testTraceData)
+	if err != nil || dropped != 0 {
+		t.Fatalf("Got %d dropped spans and error %v, want none", dropped, err)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("Got %d exports, want 3", n)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Retried after %v, want the 50ms of the retry info", elapsed)
+	}
+}
+
+func TestExporterNonRetryableErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad spans")},
+		{"resource exhausted without retry info", status.Error(codes.ResourceExhausted, "quota exceeded")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := startServer(t, tt.err)
+			defer srv.Stop()
+
+			oe := newExporter(t, &otlp.Config{Endpoint: srv.Addr(), Insecure: true, RetryBackoff: time.Millisecond})
+			defer oe.Close()
+
+			dropped, err := oe.PushTraceData(context.Background(), testTraceData)
+			if status.Code(err) != status.Code(tt.err) {
+				t.Errorf("Got error %v, want %v", err, tt.err)
+			}
+			if dropped != 2 {
+				t.Errorf("Got %d dropped spans, want 2", dropped)
+			}
+			if n := len(srv.Requests()); n != 1 {
+				t.Errorf("Got %d exports, want no retry", n)
+			}
+		})
+	}
+}
+
+func TestExporterGivesUpRetrying(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+	srv := startServer(t, unavailable, unavailable, unavailable)
+	defer srv.Stop()
+
+	oe := newExporter(t, &otlp.Config{Endpoint: srv.Addr(), Insecure: true, MaxRetries: 2, RetryBackoff: time.Millisecond})
+	defer oe.Close()
+
+	if _, err := oe.PushTraceData(context.Background(), testTraceData); status.Code(err) != codes.Unavailable {
+		t.Errorf("Got error %v, want %v", err, unavailable)
+	}
+	if n := len(srv.Requests()); n != 3 {
+		t.Errorf("Got %d exports, want 3", n)
+	}
+}
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestExporterReconnects(t *testing.T) {
+	addr := freeAddr(t)
+	oe := newExporter(t, &otlp.Config{Endpoint: addr, Insecure: true, Timeout: 5 * time.Second, MaxReconnectBackoff: 20 * time.Millisecond})
+	defer oe.Close()
+
+	// The export waits for the server, started after the first attempts to
+	// connect failed.
+	errc := make(chan error, 1)
+	go func() {
+		_, err := oe.PushTraceData(context.Background(), testTraceData)
+		errc <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	srv, err := otlptest.NewServerAt(addr)
+	if err != nil {
+		t.Fatalf("Failed to start the OTLP server: %v", err)
+	}
+	defer srv.Stop()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("Failed to export the spans after reconnecting: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the export")
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Errorf("Got %d exports, want 1", n)
+	}
+}
+
+func TestExporterDeadlinePerRPC(t *testing.T) {
+	oe := newExporter(t, &otlp.Config{Endpoint: freeAddr(t), Insecure: true, Timeout: 50 * time.Millisecond, MaxRetries: 1, RetryBackoff: time.Millisecond})
+	defer oe.Close()
+
+	start := time.Now()
+	dropped, err := oe.PushTraceData(context.Background(), testTraceData)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Got error %v, want the deadline of the RPC to be exceeded", err)
+	}
+	if dropped != 2 {
+		t.Errorf("Got %d dropped spans, want 2", dropped)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Gave up after %v, want the 2 attempts to time out after 50ms", elapsed)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlptest provides a mock OTLP trace service for the tests of the
+// exporters sending OTLP over gRPC.
+package otlptest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp"
+	otlptranslator "github.com/census-instrumentation/opencensus-service/translator/trace/otlp"
+)
+
+// traceServiceServer is the OTLP trace service, with the messages encoded.
+type traceServiceServer interface {
+	export(ctx context.Context, req []byte) ([]byte, error)
+}
+
+var traceServiceDesc = grpc.ServiceDesc{
+	ServiceName: "opentelemetry.proto.collector.trace.v1.TraceService",
+	HandlerType: (*traceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Export",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			var req []byte
+			if err := dec(&req); err != nil {
+				return nil, err
+			}
+			resp, err := srv.(traceServiceServer).export(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			return &resp, nil
+		},
+	}},
+	Metadata: "opentelemetry/proto/collector/trace/v1/trace_service.proto",
+}
+
+// Server is an OTLP trace service recording the exports it receives, and
+// failing the first ones with the errors it was started with.
+type Server struct {
+	addr string
+	srv  *grpc.Server
+
+	mu       sync.Mutex
+	errs     []error
+	requests []*otlptranslator.ExportRequest
+	metadata []metadata.MD
+}
+
+// NewServer starts a Server on a local port.
+func NewServer(errs ...error) (*Server, error) {
+	return NewServerAt("127.0.0.1:0", errs...)
+}
+
+// NewServerAt starts a Server listening on addr.
+func NewServerAt(addr string, errs ...error) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		addr: ln.Addr().String(),
+		srv:  grpc.NewServer(grpc.CustomCodec(otlp.RawCodec{})),
+		errs: errs,
+	}
+	s.srv.RegisterService(&traceServiceDesc, s)
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {
+	s.srv.Stop()
+}
+
+// Requests returns the exports received so far.
+func (s *Server) Requests() []*otlptranslator.ExportRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*otlptranslator.ExportRequest(nil), s.requests...)
+}
+
+// Metadata returns the metadata of the exports received so far.
+func (s *Server) Metadata() []metadata.MD {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]metadata.MD(nil), s.metadata...)
+}
+
+func (s *Server) export(ctx context.Context, b []byte) ([]byte, error) {
+	req, err := otlptranslator.UnmarshalExportRequest(b)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	s.metadata = append(s.metadata, md)
+	if len(s.errs) > 0 {
+		err, s.errs = s.errs[0], s.errs[1:]
+		return nil, err
+	}
+	return nil, nil
+}
+
+// RetryInfo is a google.rpc.RetryInfo, encoded by hand, to add to the details
+// of the errors of the server.
+type RetryInfo struct {
+	Delay time.Duration
+}
+
+var _ proto.Message = (*RetryInfo)(nil)
+
+func (ri *RetryInfo) Reset()         { *ri = RetryInfo{} }
+func (ri *RetryInfo) String() string { return ri.Delay.String() }
+func (ri *RetryInfo) ProtoMessage()  {}
+
+// XXX_MessageName returns the name of the message, making its type URL the
+// one of RetryInfo.
+func (ri *RetryInfo) XXX_MessageName() string { return "google.rpc.RetryInfo" }
+
+// Marshal encodes the retry_delay field.
+func (ri *RetryInfo) Marshal() ([]byte, error) {
+	d, err := proto.Marshal(ptypes.DurationProto(ri.Delay))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{1<<3 | 2, byte(len(d))}, d...), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusexporter
+
+import (
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+)
+
+// cardinalityLimiter caps the number of distinct label value combinations
+// exported for each metric. Every scraped combination stays in the Prometheus
+// registry forever, so unbounded label values eventually exhaust memory.
+type cardinalityLimiter struct {
+	max    int
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	seen   map[string]map[string]struct{}
+	warned map[string]bool
+}
+
+func newCardinalityLimiter(max int, logger *zap.Logger) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		max:    max,
+		logger: logger,
+		seen:   make(map[string]map[string]struct{}),
+		warned: make(map[string]bool),
+	}
+}
+
+// limit returns the metric without the timeseries whose label values would
+// exceed the cap. The given metric is not modified.
+func (cl *cardinalityLimiter) limit(metric *metricspb.Metric) *metricspb.Metric {
+	name := metric.GetMetricDescriptor().GetName()
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	seen := cl.seen[name]
+	if seen == nil {
+		seen = make(map[string]struct{})
+		cl.seen[name] = seen
+	}
+
+	kept := make([]*metricspb.TimeSeries, 0, len(metric.Timeseries))
+	for _, ts := range metric.Timeseries {
+		sig := labelValuesSignature(ts.LabelValues)
+		if _, ok := seen[sig]; !ok {
+			if len(seen) >= cl.max {
+				if !cl.warned[name] {
+					cl.warned[name] = true
+					cl.logger.Warn("Metric exceeded the label value combinations, dropping the new ones",
+						zap.String("metric", name), zap.Int("max_label_combinations", cl.max))
+				}
+				continue
+			}
+			seen[sig] = struct{}{}
+		}
+		kept = append(kept, ts)
+	}
+
+	if len(kept) == len(metric.Timeseries) {
+		return metric
+	}
+	limited := *metric
+	limited.Timeseries = kept
+	return &limited
+}
+
+func labelValuesSignature(values []*metricspb.LabelValue) string {
+	var b strings.Builder
+	for _, v := range values {
+		// Distinguish unset values from empty ones.
+		if v.GetHasValue() {
+			b.WriteByte('+')
+		} else {
+			b.WriteByte('-')
+		}
+		b.WriteString(v.GetValue())
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
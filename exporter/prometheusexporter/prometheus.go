@@ -24,12 +24,16 @@ import (
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/spf13/viper"
+	"go.opencensus.io/stats/view"
+	"go.uber.org/zap"
 
 	// TODO: once this repository has been transferred to the
 	// official census-ecosystem location, update this import path.
 	"github.com/orijtech/prometheus-go-metrics-exporter"
 
 	prometheus_golang "github.com/prometheus/client_golang/prometheus"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 )
 
 type prometheusConfig struct {
@@ -41,14 +45,37 @@ type prometheusConfig struct {
 
 	// The address on which the Prometheus scrape handler will be run on.
 	Address string `mapstructure:"address"`
+
+	// MetricsPath is the HTTP path of the scrape handler, "/metrics" by default.
+	MetricsPath string `mapstructure:"metrics_path"`
+
+	// MaxLabelCombinations if set, caps the number of distinct label value
+	// combinations exported per metric. Timeseries with new combinations are
+	// dropped once the cap is reached.
+	MaxLabelCombinations int `mapstructure:"max_label_combinations"`
+
+	// SpanDurations if set, makes the exporter a trace exporter too, recording
+	// the durations of the spans in the span_duration histogram. The exporter
+	// then exports the OpenCensus views of the process, that one included.
+	SpanDurations bool `mapstructure:"span_durations"`
 }
 
+const defaultMetricsPath = "/metrics"
+
 var errBlankPrometheusAddress = errors.New("expecting a non-blank address to run the Prometheus metrics handler")
 
 // PrometheusExportersFromViper unmarshals the viper and returns consumer.MetricsConsumers
 // targeting Prometheus according to the configuration settings.
-// It allows HTTP clients to scrape it on endpoint path "/metrics".
+// It allows HTTP clients to scrape it on endpoint path "/metrics", unless
+// configured otherwise. The warnings are logged to the global zap logger, see
+// PrometheusExportersFromViperWithLogger.
 func PrometheusExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	return PrometheusExportersFromViperWithLogger(v, zap.L())
+}
+
+// PrometheusExportersFromViperWithLogger is PrometheusExportersFromViper
+// logging the metrics exceeding the label value combinations to logger.
+func PrometheusExportersFromViperWithLogger(v *viper.Viper, logger *zap.Logger) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
 	var cfg struct {
 		Prometheus *prometheusConfig `mapstructure:"prometheus"`
 	}
@@ -82,8 +109,12 @@ func PrometheusExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer,
 
 	// The Prometheus metrics exporter has to run on the provided address
 	// as a server that'll be scraped by Prometheus.
+	metricsPath := strings.TrimSpace(pcfg.MetricsPath)
+	if metricsPath == "" {
+		metricsPath = defaultMetricsPath
+	}
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", pe)
+	mux.Handle(metricsPath, pe)
 
 	srv := &http.Server{Handler: mux}
 	go func() {
@@ -92,20 +123,44 @@ func PrometheusExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer,
 
 	doneFns = append(doneFns, ln.Close)
 	pexp := &prometheusExporter{exporter: pe}
+	if pcfg.MaxLabelCombinations > 0 {
+		pexp.limiter = newCardinalityLimiter(pcfg.MaxLabelCombinations, logger)
+	}
 	mps = append(mps, pexp)
 
+	if pcfg.SpanDurations {
+		if err := view.Register(SpanDurationView); err != nil {
+			ln.Close()
+			return nil, nil, nil, err
+		}
+		view.RegisterExporter(pexp)
+		doneFns = append(doneFns, func() error {
+			view.UnregisterExporter(pexp)
+			view.Unregister(SpanDurationView)
+			return nil
+		})
+		tps = append(tps, pexp)
+	}
 	return
 }
 
 type prometheusExporter struct {
 	exporter *prometheus.Exporter
+	limiter  *cardinalityLimiter
 }
 
 var _ consumer.MetricsConsumer = (*prometheusExporter)(nil)
 
 func (pe *prometheusExporter) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
 	for _, metric := range md.Metrics {
-		_ = pe.exporter.ExportMetric(ctx, md.Node, md.Resource, metric)
+		pe.exportMetric(ctx, md, metric)
 	}
 	return nil
 }
+
+func (pe *prometheusExporter) exportMetric(ctx context.Context, md data.MetricsData, metric *metricspb.Metric) {
+	if pe.limiter != nil {
+		metric = pe.limiter.limit(metric)
+	}
+	_ = pe.exporter.ExportMetric(ctx, md.Node, md.Resource, metric)
+}
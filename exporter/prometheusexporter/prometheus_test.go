@@ -20,10 +20,14 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"go.opencensus.io/stats/view"
 
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
 	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/data"
 	viperutils "github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 )
@@ -173,3 +177,141 @@ test_this_one_there_where_{arch="x86",code="one",foo="bar",os="windows"} 99
 		t.Errorf("Response mismatch\nGot:\n%s\n\nWant:\n%s", got, want)
 	}
 }
+
+func TestPrometheusExporter_labelCardinalityCap(t *testing.T) {
+	config := []byte(`
+prometheus:
+    namespace: "test"
+    address: ":7778"
+    metrics_path: "/custom/metrics"
+    max_label_combinations: 1
+`)
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(config))
+	_, mes, doneFns, err := PrometheusExportersFromViper(v)
+	defer func() {
+		for _, doneFn := range doneFns {
+			doneFn()
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if len(mes) == 0 {
+		t.Fatal("Unexpectedly got back 0 metrics exporters")
+	}
+
+	timeseries := func(os string, value int64) *metricspb.TimeSeries {
+		return &metricspb.TimeSeries{
+			StartTimestamp: &timestamp.Timestamp{Seconds: 1543160298},
+			LabelValues:    []*metricspb.LabelValue{{Value: os, HasValue: true}},
+			Points: []*metricspb.Point{
+				{
+					Timestamp: &timestamp.Timestamp{Seconds: 1543160299},
+					Value:     &metricspb.Point_Int64Value{Int64Value: value},
+				},
+			},
+		}
+	}
+	metric := &metricspb.Metric{
+		MetricDescriptor: &metricspb.MetricDescriptor{
+			Name:        "requests",
+			Description: "Requests by OS",
+			Unit:        "1",
+			LabelKeys:   []*metricspb.LabelKey{{Key: "os"}},
+		},
+		Timeseries: []*metricspb.TimeSeries{
+			timeseries("windows", 10),
+			timeseries("linux", 20),
+		},
+	}
+	me := mes[0]
+	me.ConsumeMetricsData(context.Background(), data.MetricsData{Metrics: []*metricspb.Metric{metric}})
+
+	if g, w := len(metric.Timeseries), 2; g != w {
+		t.Errorf("The consumed metric was modified: got %d timeseries want %d", g, w)
+	}
+
+	res, err := http.Get("http://localhost:7778/custom/metrics")
+	if err != nil {
+		t.Fatalf("Failed to perform a scrape: %v", err)
+	}
+	if g, w := res.StatusCode, 200; g != w {
+		t.Errorf("Mismatched HTTP response status code: Got: %d Want: %d", g, w)
+	}
+	blob, _ := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	want := `# HELP test_requests Requests by OS
+# TYPE test_requests counter
+test_requests{os="windows"} 10
+`
+	if got := string(blob); got != want {
+		t.Errorf("Response mismatch\nGot:\n%s\n\nWant:\n%s", got, want)
+	}
+}
+
+func TestPrometheusExporter_spanDurations(t *testing.T) {
+	config := []byte(`
+prometheus:
+    namespace: "test"
+    address: ":7779"
+    span_durations: true
+`)
+
+	view.SetReportingPeriod(100 * time.Millisecond)
+	defer view.SetReportingPeriod(10 * time.Second)
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(config))
+	tes, _, doneFns, err := PrometheusExportersFromViper(v)
+	defer func() {
+		for _, doneFn := range doneFns {
+			doneFn()
+		}
+	}()
+
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if len(tes) == 0 {
+		t.Fatal("Unexpectedly got back 0 trace exporters")
+	}
+
+	td := data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "frontend"}},
+		Spans: []*tracepb.Span{
+			{
+				Name:      &tracepb.TruncatableString{Value: "get"},
+				StartTime: &timestamp.Timestamp{Seconds: 1543160298, Nanos: 990000000},
+				EndTime:   &timestamp.Timestamp{Seconds: 1543160299, Nanos: 20000000},
+			},
+		},
+	}
+	if err := tes[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to consume the spans: %v", err)
+	}
+
+	// The span lasted 30ms, it is counted from the 50ms bucket on.
+	wantBuckets := []string{
+		`test_span_duration_bucket{service="frontend",span_name="get",le="25"} 0`,
+		`test_span_duration_bucket{service="frontend",span_name="get",le="50"} 1`,
+		`test_span_duration_count{service="frontend",span_name="get"} 1`,
+	}
+	var got string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		res, err := http.Get("http://localhost:7779/metrics")
+		if err != nil {
+			t.Fatalf("Failed to perform a scrape: %v", err)
+		}
+		blob, _ := ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if got = string(blob); strings.Contains(got, "test_span_duration_count") {
+			break
+		}
+	}
+	for _, want := range wantBuckets {
+		if !strings.Contains(got, want) {
+			t.Errorf("Response mismatch\nGot:\n%s\n\nWant a line:\n%s", got, want)
+		}
+	}
+}
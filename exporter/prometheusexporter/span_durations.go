@@ -0,0 +1,188 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusexporter
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// Variables related to the histogram of the durations of the spans.
+var (
+	tagServiceKey, _  = tag.NewKey("service")
+	tagSpanNameKey, _ = tag.NewKey("span_name")
+
+	statSpanDuration = stats.Float64("span_duration", "Duration of the spans received by the exporter", stats.UnitMilliseconds)
+
+	// SpanDurationView is the histogram of the durations of the spans, by
+	// service and span name.
+	SpanDurationView = &view.View{
+		Name:        statSpanDuration.Name(),
+		Measure:     statSpanDuration,
+		Description: statSpanDuration.Description(),
+		TagKeys:     []tag.Key{tagServiceKey, tagSpanNameKey},
+		Aggregation: view.Distribution(1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000),
+	}
+)
+
+var _ consumer.TraceConsumer = (*prometheusExporter)(nil)
+var _ view.Exporter = (*prometheusExporter)(nil)
+
+// ConsumeTraceData records the durations of the spans, exported as the
+// SpanDurationView histogram.
+func (pe *prometheusExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	service := td.Node.GetServiceInfo().GetName()
+	for _, span := range td.Spans {
+		if span.StartTime == nil || span.EndTime == nil {
+			continue
+		}
+		durationMs := float64(span.EndTime.Seconds-span.StartTime.Seconds)*1e3 +
+			float64(span.EndTime.Nanos-span.StartTime.Nanos)/1e6
+		// The tag values that are not printable ASCII are rejected, the
+		// duration of the span is then not recorded.
+		_ = stats.RecordWithTags(ctx,
+			[]tag.Mutator{tag.Upsert(tagServiceKey, service), tag.Upsert(tagSpanNameKey, span.GetName().GetValue())},
+			statSpanDuration.M(durationMs))
+	}
+	return nil
+}
+
+// ExportView exports the views of the process, SpanDurationView included, as
+// Prometheus metrics.
+func (pe *prometheusExporter) ExportView(vd *view.Data) {
+	if metric := viewDataToMetric(vd); metric != nil {
+		pe.exportMetric(context.Background(), data.MetricsData{}, metric)
+	}
+}
+
+// viewDataToMetric returns the metric of the rows of vd, or nil if the
+// aggregation of the view is unknown.
+func viewDataToMetric(vd *view.Data) *metricspb.Metric {
+	metricType, ok := viewMetricType(vd.View)
+	if !ok {
+		return nil
+	}
+	description := vd.View.Description
+	if description == "" {
+		description = vd.View.Measure.Description()
+	}
+	descriptor := &metricspb.MetricDescriptor{
+		Name:        vd.View.Name,
+		Description: description,
+		Unit:        vd.View.Measure.Unit(),
+		Type:        metricType,
+	}
+	for _, key := range vd.View.TagKeys {
+		descriptor.LabelKeys = append(descriptor.LabelKeys, &metricspb.LabelKey{Key: key.Name()})
+	}
+
+	start, end := internal.TimeToTimestamp(vd.Start), internal.TimeToTimestamp(vd.End)
+	if metricType == metricspb.MetricDescriptor_GAUGE_INT64 || metricType == metricspb.MetricDescriptor_GAUGE_DOUBLE {
+		start = nil
+	}
+	metric := &metricspb.Metric{MetricDescriptor: descriptor}
+	for _, row := range vd.Rows {
+		point := rowPoint(vd.View, metricType, row.Data)
+		if point == nil {
+			continue
+		}
+		point.Timestamp = end
+		metric.Timeseries = append(metric.Timeseries, &metricspb.TimeSeries{
+			StartTimestamp: start,
+			LabelValues:    rowLabelValues(vd.View.TagKeys, row.Tags),
+			Points:         []*metricspb.Point{point},
+		})
+	}
+	return metric
+}
+
+func viewMetricType(v *view.View) (metricspb.MetricDescriptor_Type, bool) {
+	_, isInt := v.Measure.(*stats.Int64Measure)
+	switch v.Aggregation.Type {
+	case view.AggTypeCount:
+		return metricspb.MetricDescriptor_CUMULATIVE_INT64, true
+	case view.AggTypeSum:
+		if isInt {
+			return metricspb.MetricDescriptor_CUMULATIVE_INT64, true
+		}
+		return metricspb.MetricDescriptor_CUMULATIVE_DOUBLE, true
+	case view.AggTypeDistribution:
+		return metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION, true
+	case view.AggTypeLastValue:
+		if isInt {
+			return metricspb.MetricDescriptor_GAUGE_INT64, true
+		}
+		return metricspb.MetricDescriptor_GAUGE_DOUBLE, true
+	}
+	return metricspb.MetricDescriptor_UNSPECIFIED, false
+}
+
+func rowPoint(v *view.View, metricType metricspb.MetricDescriptor_Type, ad view.AggregationData) *metricspb.Point {
+	switch ad := ad.(type) {
+	case *view.CountData:
+		return &metricspb.Point{Value: &metricspb.Point_Int64Value{Int64Value: ad.Value}}
+	case *view.SumData:
+		if metricType == metricspb.MetricDescriptor_CUMULATIVE_INT64 {
+			return &metricspb.Point{Value: &metricspb.Point_Int64Value{Int64Value: int64(ad.Value)}}
+		}
+		return &metricspb.Point{Value: &metricspb.Point_DoubleValue{DoubleValue: ad.Value}}
+	case *view.LastValueData:
+		if metricType == metricspb.MetricDescriptor_GAUGE_INT64 {
+			return &metricspb.Point{Value: &metricspb.Point_Int64Value{Int64Value: int64(ad.Value)}}
+		}
+		return &metricspb.Point{Value: &metricspb.Point_DoubleValue{DoubleValue: ad.Value}}
+	case *view.DistributionData:
+		buckets := make([]*metricspb.DistributionValue_Bucket, len(ad.CountPerBucket))
+		for i, count := range ad.CountPerBucket {
+			buckets[i] = &metricspb.DistributionValue_Bucket{Count: count}
+		}
+		return &metricspb.Point{Value: &metricspb.Point_DistributionValue{DistributionValue: &metricspb.DistributionValue{
+			Count:                 ad.Count,
+			Sum:                   ad.Mean * float64(ad.Count),
+			SumOfSquaredDeviation: ad.SumOfSquaredDev,
+			BucketOptions: &metricspb.DistributionValue_BucketOptions{
+				Type: &metricspb.DistributionValue_BucketOptions_Explicit_{
+					Explicit: &metricspb.DistributionValue_BucketOptions_Explicit{Bounds: v.Aggregation.Buckets},
+				},
+			},
+			Buckets: buckets,
+		}}}
+	}
+	return nil
+}
+
+// rowLabelValues returns the values of the tags of a row in the order of the
+// keys of the view, the missing ones being unset.
+func rowLabelValues(keys []tag.Key, tags []tag.Tag) []*metricspb.LabelValue {
+	values := make([]*metricspb.LabelValue, len(keys))
+	for i, key := range keys {
+		values[i] = &metricspb.LabelValue{}
+		for _, t := range tags {
+			if t.Key == key {
+				values[i] = &metricspb.LabelValue{Value: t.Value, HasValue: true}
+				break
+			}
+		}
+	}
+	return values
+}
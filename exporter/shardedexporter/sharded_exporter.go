@@ -0,0 +1,123 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package shardedexporter provides an exporter that splits the spans it
+// receives among several shards, all the spans of a trace going to the same
+// shard so it can correlate them.
+package shardedexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+var (
+	errEmptyExporterFormat = errors.New("empty exporter format")
+	errNoShards            = errors.New("no shard exporters")
+	errNilShard            = errors.New("nil shard exporter")
+)
+
+// ShardedExporter is an exporter.TraceExporter sending each span to the shard
+// exporters[fnv32(traceID) % len(exporters)]. If that shard fails to export
+// the spans, they are sent to the following shards, wrapping around, until
+// one of them succeeds. The spans of a trace received together thus stay
+// together on the fallback shard.
+type ShardedExporter struct {
+	exporterFormat string
+	shards         []exporter.TraceExporter
+}
+
+var _ exporter.TraceExporter = (*ShardedExporter)(nil)
+
+// NewShardedExporter creates a ShardedExporter splitting the spans among
+// the given shards. The order of the shards determines the shard of each
+// trace, so it must be the same on all the instances meant to agree on it.
+func NewShardedExporter(exporterFormat string, shards []exporter.TraceExporter) (*ShardedExporter, error) {
+	if exporterFormat == "" {
+		return nil, errEmptyExporterFormat
+	}
+	if len(shards) == 0 {
+		return nil, errNoShards
+	}
+	for _, shard := range shards {
+		if shard == nil {
+			return nil, errNilShard
+		}
+	}
+
+	return &ShardedExporter{
+		exporterFormat: exporterFormat,
+		shards:         append([]exporter.TraceExporter(nil), shards...),
+	}, nil
+}
+
+// TraceExportFormat returns the format given to NewShardedExporter.
+func (e *ShardedExporter) TraceExportFormat() string {
+	return e.exporterFormat
+}
+
+// ConsumeTraceData sends the spans of td to their shards, one TraceData per
+// shard. The error returned combines those of the spans no shard accepted.
+func (e *ShardedExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	shardSpans := make([][]*tracepb.Span, len(e.shards))
+	for _, span := range td.Spans {
+		if span == nil {
+			continue
+		}
+		i := e.shardIndex(span.TraceId)
+		shardSpans[i] = append(shardSpans[i], span)
+	}
+
+	var errs []error
+	for i, spans := range shardSpans {
+		if len(spans) == 0 {
+			continue
+		}
+		shardTD := td
+		shardTD.Spans = spans
+		if err := e.export(ctx, i, shardTD); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// export sends td to the shard at index first or, if it fails, to the next
+// shards in order until one succeeds.
+func (e *ShardedExporter) export(ctx context.Context, first int, td data.TraceData) error {
+	var err error
+	for n := 0; n < len(e.shards); n++ {
+		if err = e.shards[(first+n)%len(e.shards)].ConsumeTraceData(ctx, td); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("failed to export %d spans to any shard, last error: %v", len(td.Spans), err)
+}
+
+func (e *ShardedExporter) shardIndex(traceID []byte) int {
+	h := fnv.New32()
+	h.Write(traceID)
+	return int(h.Sum32() % uint32(len(e.shards)))
+}
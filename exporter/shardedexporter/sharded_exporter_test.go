@@ -0,0 +1,188 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shardedexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestNewShardedExporter(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	tests := []struct {
+		name           string
+		exporterFormat string
+		shards         []exporter.TraceExporter
+		wantErr        error
+	}{
+		{name: "valid", exporterFormat: "sharded", shards: []exporter.TraceExporter{sink}},
+		{name: "empty_format", shards: []exporter.TraceExporter{sink}, wantErr: errEmptyExporterFormat},
+		{name: "no_shards", exporterFormat: "sharded", wantErr: errNoShards},
+		{name: "nil_shard", exporterFormat: "sharded", shards: []exporter.TraceExporter{sink, nil}, wantErr: errNilShard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewShardedExporter(tt.exporterFormat, tt.shards)
+			if err != tt.wantErr {
+				t.Errorf("NewShardedExporter() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// failingShard fails the exports while err is set.
+type failingShard struct {
+	exportertest.SinkTraceExporter
+	err error
+}
+
+func (fs *failingShard) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if fs.err != nil {
+		return fs.err
+	}
+	return fs.SinkTraceExporter.ConsumeTraceData(ctx, td)
+}
+
+func newShards(n int) ([]*failingShard, []exporter.TraceExporter) {
+	shards := make([]*failingShard, n)
+	exporters := make([]exporter.TraceExporter, n)
+	for i := range shards {
+		shards[i] = &failingShard{}
+		exporters[i] = shards[i]
+	}
+	return shards, exporters
+}
+
+// traceSpans returns numTraces traces of spansPerTrace spans each.
+func traceSpans(numTraces, spansPerTrace int) []*tracepb.Span {
+	var spans []*tracepb.Span
+	for i := 0; i < numTraces; i++ {
+		traceID := []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, byte(i)}
+		for j := 0; j < spansPerTrace; j++ {
+			spans = append(spans, &tracepb.Span{
+				TraceId: traceID,
+				SpanId:  []byte{0, 0, 0, 0, 0, 0, byte(i), byte(j)},
+			})
+		}
+	}
+	return spans
+}
+
+// shardsByTrace returns the index of the shard that received each trace,
+// failing the test if a trace was split among multiple shards.
+func shardsByTrace(t *testing.T, shards []*failingShard) map[string]int {
+	t.Helper()
+	byTrace := make(map[string]int)
+	for i, shard := range shards {
+		for _, td := range shard.AllTraces() {
+			for _, span := range td.Spans {
+				traceID := string(span.TraceId)
+				if j, ok := byTrace[traceID]; ok && j != i {
+					t.Errorf("Trace %x sent to shards %d and %d", span.TraceId, j, i)
+				}
+				byTrace[traceID] = i
+			}
+		}
+	}
+	return byTrace
+}
+
+func TestShardedExporterKeepsTracesTogether(t *testing.T) {
+	shards, exporters := newShards(4)
+	se, err := NewShardedExporter("sharded", exporters)
+	if err != nil {
+		t.Fatalf("NewShardedExporter() error = %v", err)
+	}
+
+	spans := traceSpans(32, 5)
+	// The spans of each trace are received in 2 separate TraceData.
+	for _, batch := range [][]*tracepb.Span{spans[:len(spans)/2], spans[len(spans)/2:], {nil}} {
+		if err := se.ConsumeTraceData(context.Background(), data.TraceData{Spans: batch, SourceFormat: "test"}); err != nil {
+			t.Fatalf("ConsumeTraceData() error = %v", err)
+		}
+	}
+
+	byTrace := shardsByTrace(t, shards)
+	if len(byTrace) != 32 {
+		t.Errorf("Got %d traces exported, want 32", len(byTrace))
+	}
+	numSpans := 0
+	usedShards := make(map[int]bool)
+	for i, shard := range shards {
+		for _, td := range shard.AllTraces() {
+			if td.SourceFormat != "test" {
+				t.Errorf("SourceFormat = %q, want %q", td.SourceFormat, "test")
+			}
+			numSpans += len(td.Spans)
+			usedShards[i] = true
+		}
+	}
+	if numSpans != len(spans) {
+		t.Errorf("Got %d spans exported, want %d", numSpans, len(spans))
+	}
+	if len(usedShards) < 2 {
+		t.Errorf("All the traces were sent to the same shard")
+	}
+	for traceID, i := range byTrace {
+		if want := se.shardIndex([]byte(traceID)); i != want {
+			t.Errorf("Trace %x sent to shard %d, want %d", traceID, i, want)
+		}
+	}
+}
+
+func TestShardedExporterFallsBackToNextShard(t *testing.T) {
+	shards, exporters := newShards(3)
+	se, _ := NewShardedExporter("sharded", exporters)
+
+	spans := traceSpans(1, 3)
+	failed := se.shardIndex(spans[0].TraceId)
+	shards[failed].err = errors.New("shard unavailable")
+	next := (failed + 1) % len(shards)
+
+	if err := se.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+	if got := shardsByTrace(t, shards)[string(spans[0].TraceId)]; got != next {
+		t.Errorf("Trace sent to shard %d, want the shard %d following the failed one", got, next)
+	}
+
+	// Once the next shard also fails, the trace moves on to the one after it.
+	shards[next].err = errors.New("shard unavailable")
+	if err := se.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans}); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+	if got := shards[(next+1)%len(shards)].AllTraces(); len(got) != 1 || len(got[0].Spans) != len(spans) {
+		t.Errorf("Got %v on shard %d, want the %d spans of the trace", got, (next+1)%len(shards), len(spans))
+	}
+}
+
+func TestShardedExporterAllShardsFailing(t *testing.T) {
+	shards, exporters := newShards(2)
+	for _, shard := range shards {
+		shard.err = errors.New("shard unavailable")
+	}
+	se, _ := NewShardedExporter("sharded", exporters)
+
+	if err := se.ConsumeTraceData(context.Background(), data.TraceData{Spans: traceSpans(4, 2)}); err == nil {
+		t.Error("ConsumeTraceData() should fail when no shard accepts the spans")
+	}
+}
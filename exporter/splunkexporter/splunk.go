@@ -0,0 +1,325 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package splunkexporter sends the received spans to the HTTP Event Collector
+// (HEC) of Splunk, as events of the opencensus:span source type.
+package splunkexporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+const (
+	defaultEndpoint  = "https://localhost:8088/services/collector/event"
+	defaultBatchSize = 100
+
+	// sourceType is the source type of the events of the spans.
+	sourceType = "opencensus:span"
+)
+
+type splunkConfig struct {
+	// Endpoint is the URL of the event endpoint of the HEC,
+	// https://localhost:8088/services/collector/event by default.
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Token is the HEC token, sent in the Authorization header.
+	Token string `mapstructure:"token"`
+
+	// Index, Source and Host are the fields of the events, left to the
+	// defaults of the token if blank. Host is the host name of the node the
+	// spans were received from if blank.
+	Index  string `mapstructure:"index"`
+	Source string `mapstructure:"source"`
+	Host   string `mapstructure:"host"`
+
+	// BatchSize is the maximum number of events of each request, 100 by
+	// default.
+	BatchSize int `mapstructure:"batch_size"`
+
+	// Timeout of each request, 10s by default.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the HEC, often self-signed.
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+}
+
+var errMissingToken = errors.New("expecting a non-blank token for the splunk exporter")
+
+// SplunkExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// sending the spans to the Splunk HEC according to the configuration settings.
+func SplunkExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		Splunk *splunkConfig `mapstructure:"splunk"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	sc := cfg.Splunk
+	if sc == nil {
+		return nil, nil, nil, nil
+	}
+
+	se, err := newSplunkExporter(sc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure splunk exporter: %v", err)
+	}
+
+	ste, err := exporterhelper.NewTraceExporter(
+		"splunk",
+		se.pushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Splunk.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, ste)
+	return
+}
+
+// splunkExporter posts the events of the spans to the HEC, at most batchSize
+// of them per request.
+type splunkExporter struct {
+	endpoint  string
+	token     string
+	index     string
+	source    string
+	host      string
+	batchSize int
+	client    *http.Client
+}
+
+func newSplunkExporter(sc *splunkConfig) (*splunkExporter, error) {
+	if sc.Token == "" {
+		return nil, errMissingToken
+	}
+	endpoint := sc.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of the splunk endpoint %q", u.Scheme, endpoint)
+	}
+
+	batchSize := sc.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	client := exporterhelper.NewHTTPClient(sc.Timeout)
+	if sc.InsecureSkipVerify {
+		client.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return &splunkExporter{
+		endpoint:  endpoint,
+		token:     sc.Token,
+		index:     sc.Index,
+		source:    sc.Source,
+		host:      sc.Host,
+		batchSize: batchSize,
+		client:    client,
+	}, nil
+}
+
+// hecEvent is an event of the HEC, see
+// https://docs.splunk.com/Documentation/Splunk/latest/Data/FormateventsforHTTPEventCollector
+type hecEvent struct {
+	// Time is the start of the span, in seconds since the epoch with
+	// milliseconds.
+	Time       json.Number `json:"time"`
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	SourceType string      `json:"sourcetype"`
+	Index      string      `json:"index,omitempty"`
+	Event      *spanEvent  `json:"event"`
+}
+
+// spanEvent is the span of an event. The IDs are hex encoded, the times are
+// in RFC 3339 format with nanoseconds.
+type spanEvent struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	ServiceName  string                 `json:"service_name,omitempty"`
+	StartTime    string                 `json:"start_time"`
+	EndTime      string                 `json:"end_time"`
+	DurationMs   float64                `json:"duration_ms"`
+	Status       spanStatus             `json:"status"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Annotations  []spanAnnotation       `json:"annotations,omitempty"`
+	Resource     map[string]string      `json:"resource,omitempty"`
+}
+
+type spanStatus struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type spanAnnotation struct {
+	Time       string                 `json:"time"`
+	Message    string                 `json:"message"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (se *splunkExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
+	var errs []error
+	dropped := 0
+	host := se.host
+	if host == "" {
+		host = td.Node.GetIdentifier().GetHostName()
+	}
+	serviceName := td.Node.GetServiceInfo().GetName()
+	var resource map[string]string
+	if res := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource); res != nil {
+		resource = res.Labels
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	events := 0
+	flush := func() {
+		if events == 0 {
+			return
+		}
+		if err := se.post(ctx, buf.Bytes()); err != nil {
+			errs = append(errs, err)
+			dropped += events
+		}
+		buf.Reset()
+		events = 0
+	}
+	for _, span := range td.Spans {
+		sd, err := spandatatranslator.ProtoSpanToOCSpanData(span)
+		if err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		// The events of a batch are concatenated, the HEC doesn't take arrays.
+		if err := enc.Encode(&hecEvent{
+			Time:       json.Number(strconv.FormatFloat(float64(sd.StartTime.UnixNano()/int64(time.Millisecond))/1e3, 'f', 3, 64)),
+			Host:       host,
+			Source:     se.source,
+			SourceType: sourceType,
+			Index:      se.index,
+			Event:      spanDataToEvent(sd, serviceName, resource),
+		}); err != nil {
+			errs = append(errs, err)
+			dropped++
+			continue
+		}
+		events++
+		if events == se.batchSize {
+			flush()
+		}
+	}
+	flush()
+
+	return dropped, internal.CombineErrors(errs)
+}
+
+func spanDataToEvent(sd *trace.SpanData, serviceName string, resource map[string]string) *spanEvent {
+	ev := &spanEvent{
+		TraceID:     sd.TraceID.String(),
+		SpanID:      sd.SpanID.String(),
+		Name:        sd.Name,
+		Kind:        spanKind(sd.SpanKind),
+		ServiceName: serviceName,
+		StartTime:   formatTime(sd.StartTime),
+		EndTime:     formatTime(sd.EndTime),
+		DurationMs:  float64(sd.EndTime.Sub(sd.StartTime)) / float64(time.Millisecond),
+		Status:      spanStatus{Code: sd.Code, Message: sd.Message},
+		Attributes:  sd.Attributes,
+		Resource:    resource,
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		ev.ParentSpanID = sd.ParentSpanID.String()
+	}
+	for _, a := range sd.Annotations {
+		ev.Annotations = append(ev.Annotations, spanAnnotation{
+			Time:       formatTime(a.Time),
+			Message:    a.Message,
+			Attributes: a.Attributes,
+		})
+	}
+	return ev
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func spanKind(kind int) string {
+	switch kind {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	}
+	return "UNSPECIFIED"
+}
+
+// hecResponse is the body of the responses of the HEC.
+type hecResponse struct {
+	Text string `json:"text"`
+	Code int    `json:"code"`
+}
+
+func (se *splunkExporter) post(ctx context.Context, events []byte) error {
+	req, err := http.NewRequest(http.MethodPost, se.endpoint, bytes.NewReader(events))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Splunk "+se.token)
+
+	err = exporterhelper.SendHTTPRequest(ctx, se.client, req)
+	if err == nil {
+		return nil
+	}
+	// The HEC describes the errors in a JSON body.
+	var hr hecResponse
+	if statusErr, ok := err.(*exporterhelper.HTTPStatusError); ok && json.Unmarshal(statusErr.Body, &hr) == nil && hr.Text != "" {
+		return fmt.Errorf("splunk HEC request failed with status %d: %s (code %d)", statusErr.StatusCode, hr.Text, hr.Code)
+	}
+	return fmt.Errorf("splunk HEC request failed: %v", err)
+}
@@ -0,0 +1,156 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package splunkexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+// newFakeHEC returns a fake HTTP Event Collector replying with status.
+func newFakeHEC(status int) *testutils.HTTPRecorder {
+	return &testutils.HTTPRecorder{
+		Status:    status,
+		Body:      `{"text":"Success","code":0}`,
+		ErrorBody: `{"text":"Invalid token","code":4}`,
+	}
+}
+
+func TestSplunkExporterEventFormat(t *testing.T) {
+	fake := newFakeHEC(http.StatusOK)
+	srv := httptest.NewTLSServer(fake)
+	defer srv.Close()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("splunk:\n  endpoint: " + srv.URL + "/services/collector/event\n" +
+		"  token: 00000000-0000-0000-0000-000000000000\n  index: traces\n  source: ocservice\n  insecure_skip_verify: true\n"))
+	tps, _, _, err := SplunkExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+
+	td := testutils.TraceDataWithSpans(0)
+	td.Spans = []*tracepb.Span{{
+		TraceId:      []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanId:       []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		ParentSpanId: []byte{0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+		Name:         &tracepb.TruncatableString{Value: "GET /api"},
+		Kind:         tracepb.Span_SERVER,
+		StartTime:    &timestamp.Timestamp{Seconds: 1564617600, Nanos: 2500000},
+		EndTime:      &timestamp.Timestamp{Seconds: 1564617600, Nanos: 4000000},
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"http.status_code": {Value: &tracepb.AttributeValue_IntValue{IntValue: 200}},
+			},
+		},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{{
+				Time: &timestamp.Timestamp{Seconds: 1564617600, Nanos: 3000000},
+				Value: &tracepb.Span_TimeEvent_Annotation_{
+					Annotation: &tracepb.Span_TimeEvent_Annotation{
+						Description: &tracepb.TruncatableString{Value: "cache miss"},
+					},
+				},
+			}},
+		},
+		Status: &tracepb.Status{Code: 2, Message: "unknown"},
+	}}
+	if err := tps[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Got %d requests, want 1", len(requests))
+	}
+	r := requests[0]
+	if r.Method != http.MethodPost || r.URL.Path != "/services/collector/event" {
+		t.Errorf("Got %s %s, want POST /services/collector/event", r.Method, r.URL.Path)
+	}
+	if got, want := r.Header.Get("Authorization"), "Splunk 00000000-0000-0000-0000-000000000000"; got != want {
+		t.Errorf("Got Authorization %q, want %q", got, want)
+	}
+	want := `{"time":1564617600.002,"host":"web-1","source":"ocservice","sourcetype":"opencensus:span","index":"traces",` +
+		`"event":{"trace_id":"0102030405060708090a0b0c0d0e0f10","span_id":"0102030405060708","parent_span_id":"0807060504030201",` +
+		`"name":"GET /api","kind":"SERVER","service_name":"frontend",` +
+		`"start_time":"2019-08-01T00:00:00.0025Z","end_time":"2019-08-01T00:00:00.004Z","duration_ms":1.5,` +
+		`"status":{"code":2,"message":"unknown"},"attributes":{"http.status_code":200},` +
+		`"annotations":[{"time":"2019-08-01T00:00:00.003Z","message":"cache miss"}],` +
+		`"resource":{"k8s.namespace":"staging"}}}` + "\n"
+	if got := fake.Bodies()[0]; got != want {
+		t.Errorf("Got body\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSplunkExporterBatchesEvents(t *testing.T) {
+	fake := newFakeHEC(http.StatusOK)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	se, err := newSplunkExporter(&splunkConfig{Endpoint: srv.URL, Token: "t", Host: "collector-1", BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	dropped, err := se.pushTraceData(context.Background(), testutils.TraceDataWithSpans(3))
+	if err != nil || dropped != 0 {
+		t.Fatalf("Got %d dropped spans and error %v, want none", dropped, err)
+	}
+
+	bodies := fake.Bodies()
+	if len(bodies) != 2 {
+		t.Fatalf("Got %d requests, want 2", len(bodies))
+	}
+	for i, want := range []int{2, 1} {
+		events := strings.Split(strings.TrimSuffix(bodies[i], "\n"), "\n")
+		if len(events) != want {
+			t.Errorf("Request #%d: got %d events, want %d", i, len(events), want)
+		}
+		for _, ev := range events {
+			if !strings.HasPrefix(ev, `{"time":1.000,"host":"collector-1","sourcetype":"opencensus:span","event":{`) {
+				t.Errorf("Request #%d: unexpected event %q", i, ev)
+			}
+		}
+	}
+}
+
+func TestSplunkExporterRequestError(t *testing.T) {
+	srv := httptest.NewServer(newFakeHEC(http.StatusForbidden))
+	defer srv.Close()
+
+	se, err := newSplunkExporter(&splunkConfig{Endpoint: srv.URL, Token: "wrong", BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	dropped, err := se.pushTraceData(context.Background(), testutils.TraceDataWithSpans(3))
+	testutils.CheckPushError(t, dropped, err, 3, "status 403", "Invalid token")
+}
+
+func TestSplunkExportersFromViperErrors(t *testing.T) {
+	testutils.CheckExportersFromViperErrors(t, SplunkExportersFromViper, []testutils.InvalidConfig{
+		{Name: "missing token", Config: "splunk:\n  index: traces\n"},
+		{Name: "invalid scheme", Config: "splunk:\n  endpoint: tcp://localhost:9997\n  token: t\n"},
+	})
+}
@@ -17,13 +17,16 @@ package stackdriverexporter
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"cloud.google.com/go/compute/metadata"
 	"contrib.go.opencensus.io/exporter/stackdriver"
 	"github.com/spf13/viper"
 	"go.opencensus.io/trace"
+	"google.golang.org/api/option"
 
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
 	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
@@ -35,15 +38,48 @@ import (
 	"github.com/census-instrumentation/opencensus-service/exporter/exporterwrapper"
 )
 
-const agentLabel = "g.co/agent"
+const (
+	agentLabel = "g.co/agent"
+
+	// projectIDEnvVar is consulted when no project ID is configured and the
+	// collector is not running on GCP.
+	projectIDEnvVar = "GCLOUD_PROJECT"
+
+	// Stackdriver Metrics mandates a minimum of 60 seconds for
+	// reporting metrics. We have to enforce this as per the advisory
+	// at https://cloud.google.com/monitoring/custom-metrics/creating-metrics#writing-ts
+	// which says:
+	//
+	// "If you want to write more than one point to the same time series, then use a separate call
+	//  to the timeSeries.create method for each point. Don't make the calls faster than one time per
+	//  minute. If you are adding data points to different time series, then there is no rate limitation."
+	minMetricsBundleDelayThreshold = 60 * time.Second
+	defaultBundleDelayThreshold    = 61 * time.Second
+)
 
 type stackdriverConfig struct {
 	ProjectID     string `mapstructure:"project,omitempty"`
 	EnableTracing bool   `mapstructure:"enable_tracing,omitempty"`
 	EnableMetrics bool   `mapstructure:"enable_metrics,omitempty"`
 	MetricPrefix  string `mapstructure:"metric_prefix,omitempty"`
+
+	// BundleDelayThreshold is the maximum time spans and metrics are buffered
+	// before being uploaded. It must be at least 60s when metrics are enabled.
+	BundleDelayThreshold time.Duration `mapstructure:"bundle_delay_threshold,omitempty"`
+	// BundleCountThreshold is the number of spans that triggers an upload
+	// of a single BatchWriteSpans call.
+	BundleCountThreshold int `mapstructure:"bundle_count_threshold,omitempty"`
+	// CredentialsFile is the path to a service account key file used instead
+	// of the application default credentials.
+	CredentialsFile string `mapstructure:"credentials_file,omitempty"`
 }
 
+// These are variables so that tests can fake the metadata server.
+var (
+	metadataOnGCE     = metadata.OnGCE
+	metadataProjectID = metadata.ProjectID
+)
+
 // This interface and factory function type enable passing a fake Stackdriver
 // exporter for a unit test.
 type stackdriverExporterInterface interface {
@@ -88,22 +124,32 @@ func stackdriverTraceExportersFromViperInternal(v *viper.Viper, sef stackdriverE
 	// TODO:  For each ProjectID, create a different exporter
 	// or at least a unique Stackdriver client per ProjectID.
 
+	projectID := resolveProjectID(sc.ProjectID)
+
+	bundleDelayThreshold := defaultBundleDelayThreshold
+	if sc.BundleDelayThreshold > 0 {
+		bundleDelayThreshold = sc.BundleDelayThreshold
+	}
+	if sc.EnableMetrics && bundleDelayThreshold < minMetricsBundleDelayThreshold {
+		return nil, nil, nil, fmt.Errorf("Cannot configure Stackdriver exporter: bundle_delay_threshold must be at least %v when metrics are enabled, got %v",
+			minMetricsBundleDelayThreshold, bundleDelayThreshold)
+	}
+
+	var clientOptions []option.ClientOption
+	if sc.CredentialsFile != "" {
+		clientOptions = append(clientOptions, option.WithCredentialsFile(sc.CredentialsFile))
+	}
+
 	sde, serr := sef(stackdriver.Options{
-		// If the project ID is an empty string, it will be set by default based on
-		// the project this is running on in GCP.
-		ProjectID: sc.ProjectID,
+		ProjectID: projectID,
 
 		MetricPrefix: sc.MetricPrefix,
 
-		// Stackdriver Metrics mandates a minimum of 60 seconds for
-		// reporting metrics. We have to enforce this as per the advisory
-		// at https://cloud.google.com/monitoring/custom-metrics/creating-metrics#writing-ts
-		// which says:
-		//
-		// "If you want to write more than one point to the same time series, then use a separate call
-		//  to the timeSeries.create method for each point. Don't make the calls faster than one time per
-		//  minute. If you are adding data points to different time series, then there is no rate limitation."
-		BundleDelayThreshold: 61 * time.Second,
+		BundleDelayThreshold: bundleDelayThreshold,
+		BundleCountThreshold: sc.BundleCountThreshold,
+
+		TraceClientOptions:      clientOptions,
+		MonitoringClientOptions: clientOptions,
 	})
 	if serr != nil {
 		return nil, nil, nil, fmt.Errorf("Cannot configure Stackdriver Trace exporter: %v", serr)
@@ -142,11 +188,28 @@ func stackdriverTraceExportersFromViperInternal(v *viper.Viper, sef stackdriverE
 	return
 }
 
+// resolveProjectID returns the configured project ID or, when it is empty, the
+// project the collector is running on according to the GCP metadata server,
+// falling back to the GCLOUD_PROJECT environment variable. Otherwise it is
+// left empty, for the Stackdriver exporter to detect the project of the
+// application default credentials, e.g. of the credentials file.
+func resolveProjectID(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	if metadataOnGCE() {
+		if projectID, err := metadataProjectID(); err == nil && projectID != "" {
+			return projectID
+		}
+	}
+	return os.Getenv(projectIDEnvVar)
+}
+
 // ExportSpans is the method that translates OpenCensus-Proto Traces into AWS X-Ray spans.
 // It uniquely maintains
 func (sde *stackdriverExporter) pushTraceData(ctx context.Context, td data.TraceData) (int, error) {
 	setAgentLabelFromNode(td)
-	return exporterwrapper.PushOcProtoSpansToOCTraceExporter(sde.exporter, td)
+	return exporterwrapper.PushOcProtoSpansToOCTraceExporter(ctx, sde.exporter, td)
 }
 
 func setAgentLabelFromNode(td data.TraceData) {
@@ -16,6 +16,8 @@ package stackdriverexporter
 
 import (
 	"context"
+	"errors"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -228,3 +230,92 @@ stackdriver:
 		}
 	}
 }
+
+func TestResolveProjectID(t *testing.T) {
+	defer func(onGCE func() bool, projectID func() (string, error)) {
+		metadataOnGCE, metadataProjectID = onGCE, projectID
+	}(metadataOnGCE, metadataProjectID)
+	defer os.Setenv(projectIDEnvVar, os.Getenv(projectIDEnvVar))
+
+	tests := []struct {
+		name       string
+		configured string
+		onGCE      bool
+		metadataID string
+		env        string
+		want       string
+	}{
+		{name: "configured wins", configured: "cfg-project", onGCE: true, metadataID: "gce-project", env: "env-project", want: "cfg-project"},
+		{name: "metadata server", onGCE: true, metadataID: "gce-project", env: "env-project", want: "gce-project"},
+		{name: "env fallback off GCP", env: "env-project", want: "env-project"},
+		{name: "env fallback when metadata fails", onGCE: true, env: "env-project", want: "env-project"},
+		{name: "left to the credentials", want: ""},
+	}
+	for _, tt := range tests {
+		metadataOnGCE = func() bool { return tt.onGCE }
+		metadataProjectID = func() (string, error) {
+			if tt.metadataID == "" {
+				return "", errors.New("metadata unavailable")
+			}
+			return tt.metadataID, nil
+		}
+		os.Setenv(projectIDEnvVar, tt.env)
+
+		if got := resolveProjectID(tt.configured); got != tt.want {
+			t.Errorf("%s: resolveProjectID() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStackdriverBundleOptions(t *testing.T) {
+	v := viper.New()
+	configYAML := []byte(`
+stackdriver:
+  project: 'test-project'
+  enable_tracing: true
+  bundle_delay_threshold: 2s
+  bundle_count_threshold: 50
+  credentials_file: '/etc/collector/sa.json'`)
+	if err := viperutils.LoadYAMLBytes(v, configYAML); err != nil {
+		t.Fatalf("Failed to load the config: %v", err)
+	}
+
+	var gotOpts stackdriver.Options
+	tps, _, _, err := stackdriverTraceExportersFromViperInternal(v, func(opts stackdriver.Options) (stackdriverExporterInterface, error) {
+		gotOpts = opts
+		return &fakeStackdriverExporter{}, nil
+	})
+	if err != nil || len(tps) != 1 {
+		t.Fatalf("Unexpected result: %d TraceConsumers, err %v", len(tps), err)
+	}
+	if gotOpts.BundleDelayThreshold != 2*time.Second {
+		t.Errorf("BundleDelayThreshold = %v, want 2s", gotOpts.BundleDelayThreshold)
+	}
+	if gotOpts.BundleCountThreshold != 50 {
+		t.Errorf("BundleCountThreshold = %v, want 50", gotOpts.BundleCountThreshold)
+	}
+	if len(gotOpts.TraceClientOptions) != 1 || len(gotOpts.MonitoringClientOptions) != 1 {
+		t.Errorf("Expected the credentials file to be passed to both clients, got %v and %v",
+			gotOpts.TraceClientOptions, gotOpts.MonitoringClientOptions)
+	}
+}
+
+func TestStackdriverBundleDelayTooShortForMetrics(t *testing.T) {
+	v := viper.New()
+	configYAML := []byte(`
+stackdriver:
+  project: 'test-project'
+  enable_metrics: true
+  bundle_delay_threshold: 10s`)
+	if err := viperutils.LoadYAMLBytes(v, configYAML); err != nil {
+		t.Fatalf("Failed to load the config: %v", err)
+	}
+
+	_, _, _, err := stackdriverTraceExportersFromViperInternal(v, func(opts stackdriver.Options) (stackdriverExporterInterface, error) {
+		t.Error("The exporter should not be created with an invalid bundle delay")
+		return &fakeStackdriverExporter{}, nil
+	})
+	if err == nil {
+		t.Error("Expected an error for a bundle delay below the metrics minimum")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tempoexporter sends the received spans to Grafana Tempo, or any
+// other backend ingesting OTLP over gRPC.
+package tempoexporter
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp"
+)
+
+// tenantHeader is the header of the tenant of the multi-tenant Tempo
+// deployments.
+const tenantHeader = "X-Scope-OrgID"
+
+type tempoConfig struct {
+	otlp.Config `mapstructure:",squash"`
+
+	// TenantID if set, is sent in the X-Scope-OrgID header of the exports.
+	TenantID string `mapstructure:"tenant_id"`
+}
+
+// TempoExportersFromViper unmarshals the viper and returns a consumer.TraceConsumer
+// sending the spans to Tempo according to the configuration settings.
+func TempoExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
+	var cfg struct {
+		Tempo *tempoConfig `mapstructure:"tempo"`
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	tc := cfg.Tempo
+	if tc == nil {
+		return nil, nil, nil, nil
+	}
+
+	te, err := newTempoExporter(tc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Cannot configure tempo exporter: %v", err)
+	}
+
+	tte, err := exporterhelper.NewTraceExporter(
+		"tempo",
+		te.PushTraceData,
+		exporterhelper.WithSpanName("ocservice.exporter.Tempo.ConsumeTraceData"),
+		exporterhelper.WithRecordMetrics(true),
+	)
+	if err != nil {
+		te.Close()
+		return nil, nil, nil, err
+	}
+
+	tps = append(tps, tte)
+	doneFns = append(doneFns, te.Close)
+	return
+}
+
+// newTempoExporter returns an OTLP exporter sending the tenant of tc.
+func newTempoExporter(tc *tempoConfig) (*otlp.Exporter, error) {
+	oc := tc.Config
+	if tc.TenantID != "" {
+		oc.Headers = make(map[string]string, len(tc.Headers)+1)
+		for k, v := range tc.Headers {
+			oc.Headers[k] = v
+		}
+		oc.Headers[tenantHeader] = tc.TenantID
+	}
+	return otlp.NewExporter(&oc)
+}
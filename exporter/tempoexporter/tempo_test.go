@@ -0,0 +1,136 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tempoexporter
+
+import (
+	"context"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp"
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp/otlptest"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+func startMockTempo(t *testing.T) *otlptest.Server {
+	srv, err := otlptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start the OTLP server: %v", err)
+	}
+	return srv
+}
+
+var testTraceData = data.TraceData{
+	Node: &commonpb.Node{
+		ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"},
+		Attributes:  map[string]string{"host.name": "h1"},
+	},
+	Resource: &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "prod"}},
+	Spans: []*tracepb.Span{
+		{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			Name:    &tracepb.TruncatableString{Value: "first"},
+		},
+		{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			Name:    &tracepb.TruncatableString{Value: "second"},
+		},
+	},
+}
+
+func TestTempoExporterSendsTenantHeader(t *testing.T) {
+	mt := startMockTempo(t)
+	defer mt.Stop()
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(`tempo:
+  endpoint: ` + mt.Addr() + `
+  insecure: true
+  tenant_id: team-a
+  headers:
+    x-extra: extra
+`))
+	tps, _, doneFns, err := TempoExportersFromViper(v)
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer func() {
+		for _, done := range doneFns {
+			done()
+		}
+	}()
+	if len(tps) != 1 {
+		t.Fatalf("Got %d trace exporters, want 1", len(tps))
+	}
+	if err := tps[0].ConsumeTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	requests := mt.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Got %d exports, want 1", len(requests))
+	}
+	md := mt.Metadata()[0]
+	if got := md.Get(tenantHeader); len(got) != 1 || got[0] != "team-a" {
+		t.Errorf("Got tenant header %v, want [team-a]", got)
+	}
+	if got := md.Get("x-extra"); len(got) != 1 || got[0] != "extra" {
+		t.Errorf("Got x-extra header %v, want [extra]", got)
+	}
+
+	rss := requests[0].ResourceSpans
+	if len(rss) != 1 || len(rss[0].ScopeSpans) != 1 {
+		t.Fatalf("Got resource spans %+v, want a single scope", rss)
+	}
+	attrs := map[string]string{}
+	for _, kv := range rss[0].Resource.Attributes {
+		attrs[kv.Key] = *kv.Value.StringValue
+	}
+	want := map[string]string{"service.name": "checkout", "host.name": "h1", "k8s.namespace": "prod"}
+	if len(attrs) != len(want) {
+		t.Errorf("Got resource attributes %v, want %v", attrs, want)
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("Got resource attribute %s=%q, want %q", k, attrs[k], v)
+		}
+	}
+	spans := rss[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "first" || spans[1].Name != "second" {
+		t.Errorf("Got spans %+v, want the first and second ones", spans)
+	}
+}
+
+func TestTempoExporterNoTenant(t *testing.T) {
+	mt := startMockTempo(t)
+	defer mt.Stop()
+
+	te, err := newTempoExporter(&tempoConfig{Config: otlp.Config{Endpoint: mt.Addr(), Insecure: true}})
+	if err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer te.Close()
+	if _, err := te.PushTraceData(context.Background(), testTraceData); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+	if got := mt.Metadata()[0].Get(tenantHeader); len(got) != 0 {
+		t.Errorf("Got tenant header %v without tenant, want none", got)
+	}
+}
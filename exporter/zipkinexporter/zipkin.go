@@ -16,9 +16,12 @@ package zipkinexporter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -43,6 +46,13 @@ type ZipkinConfig struct {
 	Endpoint         string         `mapstructure:"endpoint,omitempty"`
 	LocalEndpointURI string         `mapstructure:"local_endpoint,omitempty"`
 	UploadPeriod     *time.Duration `mapstructure:"upload_period,omitempty"`
+	// BatchSize is the maximum number of spans sent in a single request, the
+	// batch is sent as soon as it is full even if UploadPeriod has not elapsed.
+	BatchSize int `mapstructure:"batch_size,omitempty"`
+	// ErrorHandler is called with the errors of the uploads, they go to the
+	// default logger of the reporter if it is nil. It can't be set from viper, see
+	// ZipkinExportersFromConfig.
+	ErrorHandler func(error) `mapstructure:"-"`
 }
 
 // zipkinExporter is a multiplexing exporter that spawns a new OpenCensus-Go Zipkin
@@ -87,7 +97,12 @@ func ZipkinExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 		return nil, nil, nil, err
 	}
 
-	zc := cfg.Zipkin
+	return ZipkinExportersFromConfig(cfg.Zipkin)
+}
+
+// ZipkinExportersFromConfig returns an exporter.TraceExporter targeting Zipkin according
+// to zc, none if zc is nil.
+func ZipkinExportersFromConfig(zc *ZipkinConfig) (tps []consumer.TraceConsumer, mps []consumer.MetricsConsumer, doneFns []func() error, err error) {
 	if zc == nil {
 		return nil, nil, nil, nil
 	}
@@ -105,7 +120,7 @@ func ZipkinExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 	if zc.UploadPeriod != nil && *zc.UploadPeriod > 0 {
 		uploadPeriod = *zc.UploadPeriod
 	}
-	zle, err := newZipkinExporter(endpoint, serviceName, localEndpointURI, uploadPeriod)
+	zle, err := newZipkinExporter(endpoint, serviceName, localEndpointURI, uploadPeriod, zc.BatchSize, zc.ErrorHandler)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("Cannot configure Zipkin exporter: %v", err)
 	}
@@ -114,11 +129,18 @@ func ZipkinExportersFromViper(v *viper.Viper) (tps []consumer.TraceConsumer, mps
 	return
 }
 
-func newZipkinExporter(finalEndpointURI, defaultServiceName, defaultLocalEndpointURI string, uploadPeriod time.Duration) (*zipkinExporter, error) {
+func newZipkinExporter(finalEndpointURI, defaultServiceName, defaultLocalEndpointURI string, uploadPeriod time.Duration, batchSize int, errorHandler func(error)) (*zipkinExporter, error) {
 	var opts []zipkinhttp.ReporterOption
 	if uploadPeriod > 0 {
 		opts = append(opts, zipkinhttp.BatchInterval(uploadPeriod))
 	}
+	if batchSize > 0 {
+		opts = append(opts, zipkinhttp.BatchSize(batchSize))
+	}
+	if errorHandler != nil {
+		// The reporter only reports its errors to a logger.
+		opts = append(opts, zipkinhttp.Logger(log.New(errorHandlerWriter(errorHandler), "", 0)))
+	}
 	reporter := zipkinhttp.NewReporter(finalEndpointURI, opts...)
 	zle := &zipkinExporter{
 		endpointURI:             finalEndpointURI,
@@ -129,6 +151,15 @@ func newZipkinExporter(finalEndpointURI, defaultServiceName, defaultLocalEndpoin
 	return zle, nil
 }
 
+// errorHandlerWriter is an io.Writer passing each line written by a logger to
+// the error handler.
+type errorHandlerWriter func(error)
+
+func (w errorHandlerWriter) Write(p []byte) (int, error) {
+	w(errors.New(strings.TrimSpace(string(p))))
+	return len(p), nil
+}
+
 func lookupAttribute(node *commonpb.Node, key string) string {
 	if node == nil {
 		return ""
@@ -21,17 +21,21 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	zipkinreporter "github.com/openzipkin/zipkin-go/reporter"
 
+	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 	"github.com/census-instrumentation/opencensus-service/internal/testutils"
 	"github.com/census-instrumentation/opencensus-service/processor/multiconsumer"
@@ -234,6 +238,138 @@ zipkin:
 	}
 }
 
+func TestZipkinExportersFromViper_batchSize(t *testing.T) {
+	batches := make(chan []map[string]interface{}, 10)
+	cst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("Failed to decode the uploaded spans: %v", err)
+		}
+		r.Body.Close()
+		batches <- batch
+	}))
+	defer cst.Close()
+
+	// A long upload period ensures that only a full batch triggers an upload.
+	config := `
+zipkin:
+  upload_period: 1h
+  batch_size: 2
+  endpoint: ` + cst.URL
+	v, _ := viperutils.ViperFromYAMLBytes([]byte(config))
+	tes, _, doneFns, err := ZipkinExportersFromViper(v)
+	if len(tes) == 0 || err != nil {
+		t.Fatalf("Failed to parse out exporters: %v", err)
+	}
+	defer func() {
+		for _, fn := range doneFns {
+			fn()
+		}
+	}()
+
+	td := data.TraceData{
+		Node: &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "batch-svc"}},
+		Spans: []*tracepb.Span{
+			{
+				TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				Name:    &tracepb.TruncatableString{Value: "first"},
+				Kind:    tracepb.Span_SERVER,
+			},
+			{
+				TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+				SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+				Name:    &tracepb.TruncatableString{Value: "second"},
+				Kind:    tracepb.Span_CLIENT,
+			},
+		},
+	}
+	if err := tes[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	var batch []map[string]interface{}
+	select {
+	case batch = <-batches:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a full batch to be uploaded")
+	}
+
+	if g, w := len(batch), 2; g != w {
+		t.Fatalf("Number of spans in the batch: Got %d Want %d", g, w)
+	}
+	wantKinds := []string{"SERVER", "CLIENT"}
+	for i, span := range batch {
+		for _, key := range []string{"traceId", "id", "name", "localEndpoint"} {
+			if _, ok := span[key]; !ok {
+				t.Errorf("Span #%d is missing the %q field: %v", i, key, span)
+			}
+		}
+		if g, w := span["kind"], wantKinds[i]; g != w {
+			t.Errorf("Span #%d kind: Got %v Want %v", i, g, w)
+		}
+	}
+}
+
+func TestZipkinExportersFromConfig_errorHandler(t *testing.T) {
+	cst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer cst.Close()
+
+	errs := make(chan error, 10)
+	uploadPeriod := time.Hour
+	tes, _, doneFns, err := ZipkinExportersFromConfig(&ZipkinConfig{
+		Endpoint:     cst.URL,
+		UploadPeriod: &uploadPeriod,
+		BatchSize:    1,
+		ErrorHandler: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	if len(tes) == 0 || err != nil {
+		t.Fatalf("Failed to create the exporter: %v", err)
+	}
+	defer func() {
+		for _, fn := range doneFns {
+			fn()
+		}
+	}()
+
+	td := data.TraceData{
+		Spans: []*tracepb.Span{{
+			TraceId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+			SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+			Name:    &tracepb.TruncatableString{Value: "rejected"},
+		}},
+	}
+	if err := tes[0].ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Failed to export the spans: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "503") {
+			t.Errorf("Got error %q, want it to mention the status code 503", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the upload error")
+	}
+}
+
+func TestErrorHandlerWriter(t *testing.T) {
+	var got []string
+	logger := log.New(errorHandlerWriter(func(err error) { got = append(got, err.Error()) }), "", 0)
+	logger.Printf("failed the request with status code %d\n", 500)
+	logger.Print("second")
+	if want := []string{"failed the request with status code 500", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got errors %q, want %q", got, want)
+	}
+}
+
 type mockZipkinReporter struct {
 	url    string
 	client *http.Client
@@ -1,6 +1,7 @@
 module github.com/census-instrumentation/opencensus-service
 
 require (
+	cloud.google.com/go v0.43.0
 	contrib.go.opencensus.io/exporter/aws v0.0.0-20181029163544-2befc13012d0
 	contrib.go.opencensus.io/exporter/jaeger v0.1.1-0.20190430175949-e8b55949d948
 	contrib.go.opencensus.io/exporter/ocagent v0.6.0
@@ -10,19 +11,28 @@ require (
 	contrib.go.opencensus.io/resource v0.1.2
 	github.com/DataDog/datadog-go v2.2.0+incompatible // indirect
 	github.com/DataDog/opencensus-go-exporter-datadog v0.0.0-20181026070331-e7c4bd17b329
+	github.com/Shopify/sarama v1.19.0
 	github.com/VividCortex/gohistogram v1.0.0 // indirect
+	github.com/alicebob/miniredis/v2 v2.14.3
 	github.com/apache/thrift v0.0.0-20161221203622-b2a4d4ae21c7
 	github.com/bmizerany/perks v0.0.0-20141205001514-d9a9656a3a4b // indirect
 	github.com/census-instrumentation/opencensus-proto v0.2.1
+	github.com/fsnotify/fsnotify v1.4.7
 	github.com/go-kit/kit v0.8.0
+	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/gogo/googleapis v1.2.0 // indirect
 	github.com/golang/protobuf v1.3.2
 	github.com/google/go-cmp v0.3.1
 	github.com/gorilla/mux v1.6.2
 	github.com/grpc-ecosystem/grpc-gateway v1.9.4
-	github.com/honeycombio/opencensus-exporter v1.0.1
+	github.com/honeycombio/libhoney-go v1.10.0
+	github.com/influxdata/influxdb-client-go/v2 v2.2.0
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/jaegertracing/jaeger v1.9.0
+	github.com/klauspost/compress v1.8.2
+	github.com/mitchellh/mapstructure v1.0.0
+	github.com/nats-io/nats-server/v2 v2.2.6
+	github.com/nats-io/nats.go v1.11.0
 	github.com/omnition/scribe-go v0.0.0-20190131012523-9e3c68f31124
 	github.com/opentracing/opentracing-go v1.1.0 // indirect
 	github.com/openzipkin/zipkin-go v0.1.6
@@ -48,12 +58,12 @@ require (
 	github.com/uber/tchannel-go v1.10.0
 	github.com/wavefronthq/opencensus-exporter v0.0.0-20190506162721-983d7cdaceaf
 	github.com/wavefronthq/wavefront-sdk-go v0.9.2
-	github.com/yancl/opencensus-go-exporter-kafka v0.0.0-20181029030031-9c471c1bfbeb
 	go.opencensus.io v0.22.0
 	go.uber.org/atomic v1.3.2 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
 	go.uber.org/zap v1.9.1
 	golang.org/x/lint v0.0.0-20190409202823-959b441ac422
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
 	google.golang.org/api v0.7.0
 	google.golang.org/grpc v1.22.1
 	gopkg.in/DataDog/dd-trace-go.v1 v1.12.1 // indirect
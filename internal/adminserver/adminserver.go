@@ -0,0 +1,141 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package adminserver runs the HTTP server used to probe and monitor a
+// running process: liveness on /healthz, readiness on /readyz, the span
+// counters, in Prometheus format, on /metrics, the runtime statistics of
+// expvar on /debug/vars and, optionally, the profiles of net/http/pprof on
+// /debug/pprof/.
+package adminserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync/atomic"
+
+	"contrib.go.opencensus.io/exporter/prometheus"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+var (
+	viewSpansReceived = &view.View{
+		Name:        "spans_received_total",
+		Description: "Total number of spans received",
+		Measure:     observability.ViewReceiverReceivedSpans.Measure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{observability.TagKeyReceiver},
+	}
+	viewSpansExported = &view.View{
+		Name:        "spans_exported_total",
+		Description: "Total number of spans exported",
+		Measure:     observability.ViewExporterExportedSpans.Measure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{observability.TagKeyExporter},
+	}
+	viewSpansDropped = &view.View{
+		Name:        "spans_dropped_total",
+		Description: "Total number of spans dropped by the exporters",
+		Measure:     observability.ViewExporterDroppedSpans.Measure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{observability.TagKeyExporter},
+	}
+
+	views = []*view.View{viewSpansReceived, viewSpansExported, viewSpansDropped, viewExporterErrors}
+)
+
+// Server is a running admin HTTP server.
+type Server struct {
+	ln    net.Listener
+	srv   *http.Server
+	pe    *prometheus.Exporter
+	ready int32
+}
+
+// Run runs the admin HTTP endpoints on the given port. The server reports
+// that it is not ready until SetReady is called. The pprof endpoints are only
+// served if enablePprof is true, /debug/pprof/ is not found otherwise.
+func Run(asyncErrorChannel chan<- error, port int, enablePprof bool) (*Server, error) {
+	addr := fmt.Sprintf(":%d", port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind to run the admin server on %q: %v", addr, err)
+	}
+
+	if err := view.Register(views...); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to register the admin metrics views: %v", err)
+	}
+	pe, err := prometheus.NewExporter(prometheus.Options{})
+	if err != nil {
+		ln.Close()
+		view.Unregister(views...)
+		return nil, fmt.Errorf("failed to create the admin metrics exporter: %v", err)
+	}
+	view.RegisterExporter(pe)
+	view.RegisterExporter(expvarExporter{})
+
+	s := &Server{ln: ln, pe: pe}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.Handle("/metrics", pe)
+	mux.Handle("/debug/vars", expvarHandler())
+	if enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	s.srv = &http.Server{Handler: mux}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			asyncErrorChannel <- fmt.Errorf("failed to serve the admin endpoints: %v", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// SetReady makes /readyz report that the process is ready, it is called
+// once the exporters are connected.
+func (s *Server) SetReady() {
+	atomic.StoreInt32(&s.ready, 1)
+}
+
+// Close stops the server and the collection of its metrics.
+func (s *Server) Close() error {
+	view.UnregisterExporter(s.pe)
+	view.UnregisterExporter(expvarExporter{})
+	view.Unregister(views...)
+	return s.srv.Close()
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
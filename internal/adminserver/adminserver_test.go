@@ -0,0 +1,234 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exporterhelper"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+func get(t *testing.T, s *Server, path string) (int, string) {
+	resp, err := http.Get("http://" + s.ln.Addr().String() + path)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: failed to read the body: %v", path, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestAdminServerLifecycle(t *testing.T) {
+	asyncErrChan := make(chan error, 1)
+	s, err := Run(asyncErrChan, 0, false)
+	if err != nil {
+		t.Fatalf("failed to setup the admin server: %v", err)
+	}
+	defer s.Close()
+
+	if code, _ := get(t, s, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz before ready: got %v want %v", code, http.StatusOK)
+	}
+	if code, _ := get(t, s, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before ready: got %v want %v", code, http.StatusServiceUnavailable)
+	}
+
+	s.SetReady()
+
+	if code, _ := get(t, s, "/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz after ready: got %v want %v", code, http.StatusOK)
+	}
+	if code, _ := get(t, s, "/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz after ready: got %v want %v", code, http.StatusOK)
+	}
+
+	select {
+	case err := <-asyncErrChan:
+		t.Fatalf("async err received from the admin server: %v", err)
+	default:
+	}
+}
+
+func TestAdminServerMetrics(t *testing.T) {
+	view.SetReportingPeriod(10 * time.Millisecond)
+	defer view.SetReportingPeriod(10 * time.Second)
+
+	s, err := Run(make(chan error, 1), 0, false)
+	if err != nil {
+		t.Fatalf("failed to setup the admin server: %v", err)
+	}
+	defer s.Close()
+
+	ctx := observability.ContextWithReceiverName(context.Background(), "fake_receiver")
+	observability.RecordTraceReceiverMetrics(ctx, 7, 0)
+	ctx = observability.ContextWithExporterName(ctx, "fake_exporter")
+	observability.RecordTraceExporterMetrics(ctx, 7, 2)
+
+	want := []string{
+		`spans_received_total{oc_receiver="fake_receiver"} 7`,
+		`spans_exported_total{oc_exporter="fake_exporter"} 5`,
+		`spans_dropped_total{oc_exporter="fake_exporter"} 2`,
+	}
+	var body string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		var code int
+		code, body = get(t, s, "/metrics")
+		if code != http.StatusOK {
+			t.Fatalf("/metrics: got %v want %v", code, http.StatusOK)
+		}
+		if containsAll(body, want) {
+			return
+		}
+	}
+	t.Fatalf("/metrics does not report %v:\n%s", want, body)
+}
+
+func TestAdminServerExpvar(t *testing.T) {
+	view.SetReportingPeriod(10 * time.Millisecond)
+	defer view.SetReportingPeriod(10 * time.Second)
+
+	s, err := Run(make(chan error, 1), 0, false)
+	if err != nil {
+		t.Fatalf("failed to setup the admin server: %v", err)
+	}
+	defer s.Close()
+
+	ctx := observability.ContextWithReceiverName(context.Background(), "fake_receiver")
+	observability.RecordTraceReceiverMetrics(ctx, 7, 0)
+	ctx = observability.ContextWithExporterName(ctx, "fake_exporter")
+	sink := &exportertest.SinkTraceExporter{}
+	failing := exportertest.NewNopTraceExporter(exportertest.WithReturnError(errors.New("export failed")))
+	for _, exp := range []consumer.TraceConsumer{sink, failing} {
+		te, err := exporterhelper.NewTraceExporter(
+			"fake",
+			func(ctx context.Context, td data.TraceData) (int, error) {
+				err := exp.ConsumeTraceData(ctx, td)
+				if err != nil {
+					return len(td.Spans), err
+				}
+				return 0, nil
+			},
+			exporterhelper.WithRecordMetrics(true))
+		if err != nil {
+			t.Fatalf("NewTraceExporter() error: %v", err)
+		}
+		te.ConsumeTraceData(ctx, data.TraceData{Spans: make([]*tracepb.Span, 3)})
+	}
+
+	keys := []string{"SpansReceived", "SpansExported", "SpansDropped", "ExporterErrors", "UptimeSeconds"}
+	var vars map[string]interface{}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		code, body := get(t, s, "/debug/vars")
+		if code != http.StatusOK {
+			t.Fatalf("/debug/vars: got %v want %v", code, http.StatusOK)
+		}
+		vars = nil
+		if err := json.Unmarshal([]byte(body), &vars); err != nil {
+			t.Fatalf("/debug/vars is not valid JSON: %v", err)
+		}
+		if allNonZero(vars, keys) {
+			return
+		}
+	}
+	t.Fatalf("/debug/vars does not report non-zero %v: %v", keys, vars)
+}
+
+func allNonZero(vars map[string]interface{}, keys []string) bool {
+	for _, key := range keys {
+		if v, ok := vars[key].(float64); !ok || v == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAdminServerPortInUse(t *testing.T) {
+	s, err := Run(make(chan error, 1), 0, false)
+	if err != nil {
+		t.Fatalf("failed to setup the admin server: %v", err)
+	}
+	defer s.Close()
+
+	port := s.ln.Addr().(*net.TCPAddr).Port
+	if other, err := Run(make(chan error, 1), port, false); err == nil {
+		other.Close()
+		t.Fatalf("expected error, got nil")
+	}
+}
+
+func TestAdminServerPprof(t *testing.T) {
+	s, err := Run(make(chan error, 1), 0, true)
+	if err != nil {
+		t.Fatalf("failed to setup the admin server: %v", err)
+	}
+	defer s.Close()
+
+	code, body := get(t, s, "/debug/pprof/heap")
+	if code != http.StatusOK {
+		t.Fatalf("/debug/pprof/heap: got %v want %v", code, http.StatusOK)
+	}
+	// The profiles are gzipped protocol buffers.
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatalf("/debug/pprof/heap is not a gzipped profile: %v", err)
+	}
+	profile, err := ioutil.ReadAll(zr)
+	if err != nil || len(profile) == 0 {
+		t.Fatalf("/debug/pprof/heap: failed to read the profile (%d bytes): %v", len(profile), err)
+	}
+}
+
+func TestAdminServerPprofDisabled(t *testing.T) {
+	s, err := Run(make(chan error, 1), 0, false)
+	if err != nil {
+		t.Fatalf("failed to setup the admin server: %v", err)
+	}
+	defer s.Close()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap"} {
+		if code, _ := get(t, s, path); code != http.StatusNotFound {
+			t.Errorf("%s: got %v want %v", path, code, http.StatusNotFound)
+		}
+	}
+}
+
+func containsAll(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			return false
+		}
+	}
+	return true
+}
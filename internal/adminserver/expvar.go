@@ -0,0 +1,85 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adminserver
+
+import (
+	"expvar"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/observability"
+)
+
+// The variables published on /debug/vars, the counters are updated from the
+// views below every time their data is reported.
+var (
+	varSpansReceived  = expvar.NewInt("SpansReceived")
+	varSpansExported  = expvar.NewInt("SpansExported")
+	varSpansDropped   = expvar.NewInt("SpansDropped")
+	varExporterErrors = expvar.NewInt("ExporterErrors")
+	varUptimeSeconds  = expvar.NewFloat("UptimeSeconds")
+
+	startTime = time.Now()
+)
+
+var (
+	viewExporterErrors = &view.View{
+		Name:        "exporter_errors_total",
+		Description: "Total number of errors returned by the exporters",
+		Measure:     observability.ViewExporterErrors.Measure,
+		Aggregation: view.Sum(),
+		TagKeys:     []tag.Key{observability.TagKeyExporter},
+	}
+
+	expvarCounters = map[string]*expvar.Int{
+		viewSpansReceived.Name:  varSpansReceived,
+		viewSpansExported.Name:  varSpansExported,
+		viewSpansDropped.Name:   varSpansDropped,
+		viewExporterErrors.Name: varExporterErrors,
+	}
+)
+
+// expvarExporter is a view.Exporter setting the expvar counters to the sum of
+// all the rows of their views.
+type expvarExporter struct{}
+
+var _ view.Exporter = expvarExporter{}
+
+func (expvarExporter) ExportView(vd *view.Data) {
+	counter, ok := expvarCounters[vd.View.Name]
+	if !ok {
+		return
+	}
+	var total float64
+	for _, row := range vd.Rows {
+		if sum, ok := row.Data.(*view.SumData); ok {
+			total += sum.Value
+		}
+	}
+	counter.Set(int64(total))
+}
+
+// expvarHandler serves the published variables, the uptime being computed
+// for each request.
+func expvarHandler() http.Handler {
+	h := expvar.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varUptimeSeconds.Set(time.Since(startTime).Seconds())
+		h.ServeHTTP(w, r)
+	})
+}
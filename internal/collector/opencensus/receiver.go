@@ -34,7 +34,7 @@ import (
 
 // Start starts the OpenCensus receiver endpoint.
 func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsumer, asyncErrorChan chan<- error) (receiver.TraceReceiver, error) {
-	addr, opts, zapFields, err := receiverOptions(v)
+	addr, opts, zapFields, err := receiverOptions(logger, v)
 	if err != nil {
 		return nil, err
 	}
@@ -53,13 +53,13 @@ func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsu
 	return ocr, nil
 }
 
-func receiverOptions(v *viper.Viper) (addr string, opts []opencensusreceiver.Option, zapFields []zap.Field, err error) {
+func receiverOptions(logger *zap.Logger, v *viper.Viper) (addr string, opts []opencensusreceiver.Option, zapFields []zap.Field, err error) {
 	rOpts, err := builder.NewDefaultOpenCensusReceiverCfg().InitFromViper(v)
 	if err != nil {
 		return addr, opts, zapFields, err
 	}
 
-	tlsCredsOption, hasTLSCreds, err := rOpts.TLSCredentials.ToOpenCensusReceiverServerOption()
+	tlsCredsOption, hasTLSCreds, err := rOpts.TLSCredentials.ToOpenCensusReceiverServerOption(logger)
 	if err != nil {
 		return addr, opts, zapFields, fmt.Errorf("OpenCensus receiver TLS Credentials: %v", err)
 	}
@@ -74,6 +74,19 @@ func receiverOptions(v *viper.Viper) (addr string, opts []opencensusreceiver.Opt
 		opts = append(opts, opencensusreceiver.WithGRPCServerOptions(grpcServerOptions...))
 	}
 
+	if rOpts.MaxHTTPRequestBodyBytes > 0 {
+		opts = append(opts, opencensusreceiver.WithMaxHTTPRequestBodyBytes(rOpts.MaxHTTPRequestBodyBytes))
+		zapFields = append(zapFields, zap.Int64("max-http-request-body-bytes", rOpts.MaxHTTPRequestBodyBytes))
+	}
+	if rl := rOpts.HTTPRateLimit; rl != nil && rl.RequestsPerSecond > 0 {
+		opts = append(opts, opencensusreceiver.WithHTTPRateLimit(rl.RequestsPerSecond, rl.Burst))
+		zapFields = append(zapFields, zap.Any("http-rate-limit", rl))
+	}
+	if len(rOpts.APIKeys) > 0 {
+		opts = append(opts, opencensusreceiver.WithAPIKeys(rOpts.APIKeys))
+		zapFields = append(zapFields, zap.Int("api-keys", len(rOpts.APIKeys)))
+	}
+
 	addr = ":" + strconv.FormatInt(int64(rOpts.Port), 10)
 	zapFields = append(zapFields, zap.Int("port", rOpts.Port))
 
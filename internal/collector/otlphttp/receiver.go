@@ -0,0 +1,53 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlphttpreceiver wraps the functionality to start the end-point that
+// receives OTLP/HTTP traces.
+package otlphttpreceiver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/cmd/occollector/app/builder"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/otlphttpreceiver"
+)
+
+// Start starts the OTLP/HTTP receiver endpoint.
+func Start(logger *zap.Logger, v *viper.Viper, traceConsumer consumer.TraceConsumer, asyncErrorChan chan<- error) (receiver.TraceReceiver, error) {
+	rOpts, err := builder.NewDefaultOTLPHTTPReceiverCfg().InitFromViper(v)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := ":" + strconv.FormatInt(int64(rOpts.Port), 10)
+	otr, err := otlphttpreceiver.New(addr, traceConsumer)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the OTLP/HTTP receiver: %v", err)
+	}
+
+	if err := otr.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+		return nil, fmt.Errorf("Cannot start OTLP/HTTP receiver to address %q: %v", addr, err)
+	}
+
+	logger.Info("OTLP/HTTP receiver is running.", zap.Int("port", rOpts.Port))
+
+	return otr, nil
+}
@@ -72,13 +72,26 @@ type batcher struct {
 	tickTime          time.Duration
 	timeout           time.Duration
 
+	// bucketMu guards closed, spans are only added to the buckets while it is
+	// read locked so Close doesn't miss any of them.
 	bucketMu sync.RWMutex
+	closed   bool
 }
 
-var _ consumer.TraceConsumer = (*batcher)(nil)
+// Batcher is a consumer.TraceConsumer that batches the spans it receives
+// before sending them to the next consumer.
+type Batcher interface {
+	consumer.TraceConsumer
+
+	// Close stops the batcher and sends the batches in progress. The spans
+	// received afterwards are sent without being batched.
+	Close() error
+}
+
+var _ Batcher = (*batcher)(nil)
 
 // NewBatcher creates a new batcher that batches spans by node and resource
-func NewBatcher(name string, logger *zap.Logger, sender consumer.TraceConsumer, opts ...Option) consumer.TraceConsumer {
+func NewBatcher(name string, logger *zap.Logger, sender consumer.TraceConsumer, opts ...Option) Batcher {
 	// Init with defaults
 	b := &batcher{
 		name:   name,
@@ -105,12 +118,43 @@ func NewBatcher(name string, logger *zap.Logger, sender consumer.TraceConsumer,
 // ConsumeTraceData implements batcher as a SpanProcessor and takes the provided spans and adds them to
 // batches
 func (b *batcher) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	b.bucketMu.RLock()
+	defer b.bucketMu.RUnlock()
+	if b.closed {
+		return b.sender.ConsumeTraceData(ctx, td)
+	}
+
 	bucketID := b.genBucketID(td.Node, td.Resource, td.SourceFormat)
 	bucket := b.getOrAddBucket(bucketID, td.Node, td.Resource, td.SourceFormat)
 	bucket.add(td.Spans)
 	return nil
 }
 
+func (b *batcher) Close() error {
+	b.bucketMu.Lock()
+	alreadyClosed := b.closed
+	b.closed = true
+	b.bucketMu.Unlock()
+	if alreadyClosed {
+		return nil
+	}
+
+	for _, ticker := range b.tickers {
+		ticker.stop()
+	}
+	b.buckets.Range(func(_, bucket interface{}) bool {
+		nb := bucket.(*nodeBatch)
+		nb.mu.Lock()
+		itemsToProcess, itemCount := nb.getAndReset()
+		nb.mu.Unlock()
+		if len(itemsToProcess) > 0 {
+			nb.sendItems(itemsToProcess, itemCount, statCloseTriggerSend)
+		}
+		return true
+	})
+	return nil
+}
+
 func (b *batcher) genBucketID(node *commonpb.Node, resource *resourcepb.Resource, spanFormat string) string {
 	h := md5.New()
 	if node != nil {
@@ -252,6 +296,7 @@ type bucketTicker struct {
 	pendingNodes chan string
 	stopCn       chan struct{}
 	once         sync.Once
+	stopOnce     sync.Once
 }
 
 func newStartedBucketTickersForBatch(b *batcher) []*bucketTicker {
@@ -332,5 +377,7 @@ func (bt *bucketTicker) processNodeBatch(nbKey string, nb *nodeBatch) {
 }
 
 func (bt *bucketTicker) stop() {
-	close(bt.stopCn)
+	bt.stopOnce.Do(func() {
+		close(bt.stopCn)
+	})
 }
@@ -264,6 +264,51 @@ func TestBucketTickerStop(t *testing.T) {
 	}
 }
 
+func TestBatcherClose(t *testing.T) {
+	sender := newTestSender()
+	batcher := NewBatcher(
+		"test",
+		zap.NewNop(),
+		sender,
+		WithTimeout(time.Hour),
+		WithTickTime(time.Hour),
+		WithSendBatchSize(1000),
+	)
+
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc"}}
+	newRequest := func(requestIndex int) data.TraceData {
+		spans := make([]*tracepb.Span, 0, 3)
+		for spanIndex := 0; spanIndex < 3; spanIndex++ {
+			spans = append(spans, &tracepb.Span{Name: getTestSpanName(requestIndex, spanIndex)})
+		}
+		return data.TraceData{Node: node, Spans: spans, SourceFormat: "oc_trace"}
+	}
+	batcher.ConsumeTraceData(context.Background(), newRequest(0))
+	batcher.ConsumeTraceData(context.Background(), newRequest(1))
+	if len(sender.reqChan) != 0 {
+		t.Fatalf("Spans were sent before the batcher was closed")
+	}
+
+	if err := batcher.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(sender.reqChan) != 1 {
+		t.Fatalf("got %d batches sent on Close, want 1", len(sender.reqChan))
+	}
+	if got := len((<-sender.reqChan).Spans); got != 6 {
+		t.Fatalf("got %d spans sent on Close, want 6", got)
+	}
+
+	// Closing twice is harmless, and the spans are no longer batched.
+	if err := batcher.Close(); err != nil {
+		t.Fatalf("Second Close() error = %v", err)
+	}
+	batcher.ConsumeTraceData(context.Background(), newRequest(2))
+	if len(sender.reqChan) != 1 {
+		t.Fatalf("Spans received after Close were not sent immediately")
+	}
+}
+
 func TestConcurrentBatchAdds(t *testing.T) {
 	sender := newTestSender()
 	batcher := NewBatcher("test", zap.NewNop(), sender, WithSendBatchSize(128)).(*batcher)
@@ -0,0 +1,28 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tailsampling
+
+// Option is an option to the tail sampling processor.
+type Option func(tsp *tailSamplingSpanProcessor)
+
+// WithMaxNumSpans limits the number of spans kept on memory waiting for the
+// sampling decisions. The traces that arrived first are dropped, as when the
+// maximum number of traces is reached, until the spans on memory are back
+// under the limit. Zero, the default, means no limit.
+func WithMaxNumSpans(maxNumSpans uint64) Option {
+	return func(tsp *tailSamplingSpanProcessor) {
+		tsp.maxNumSpans = maxNumSpans
+	}
+}
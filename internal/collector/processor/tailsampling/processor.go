@@ -63,6 +63,8 @@ type tailSamplingSpanProcessor struct {
 	decisionBatcher idbatcher.Batcher
 	deleteChan      chan traceKey
 	numTracesOnMap  uint64
+	maxNumSpans     uint64
+	numSpansOnMap   int64
 }
 
 const (
@@ -79,7 +81,8 @@ func NewTailSamplingSpanProcessor(
 	policies []*Policy,
 	maxNumTraces, expectedNewTracesPerSec uint64,
 	decisionWait time.Duration,
-	logger *zap.Logger) (consumer.TraceConsumer, error) {
+	logger *zap.Logger,
+	opts ...Option) (consumer.TraceConsumer, error) {
 
 	numDecisionBatches := uint64(decisionWait.Seconds())
 	inBatcher, err := idbatcher.New(numDecisionBatches, expectedNewTracesPerSec, uint64(2*runtime.NumCPU()))
@@ -93,6 +96,9 @@ func NewTailSamplingSpanProcessor(
 		logger:          logger,
 		decisionBatcher: inBatcher,
 	}
+	for _, opt := range opts {
+		opt(tsp)
+	}
 
 	for _, policy := range policies {
 		policyCtx, err := tag.New(tsp.ctx, tag.Upsert(tagPolicyKey, policy.Name), tag.Upsert(observability.TagKeyReceiver, sourceFormat))
@@ -162,9 +168,7 @@ func (tsp *tailSamplingSpanProcessor) samplingPolicyOnTick() {
 		}
 
 		// Sampled or not, remove the batches
-		trace.Lock()
-		trace.ReceivedBatches = nil
-		trace.Unlock()
+		tsp.releaseBatches(trace)
 	}
 
 	stats.Record(tsp.ctx,
@@ -246,6 +250,7 @@ func (tsp *tailSamplingSpanProcessor) ConsumeTraceData(ctx context.Context, td d
 				// be duplicated in the final trace.
 				traceTd := prepareTraceBatch(spans, singleTrace, td)
 				actualData.ReceivedBatches = append(actualData.ReceivedBatches, traceTd)
+				atomic.AddInt64(&tsp.numSpansOnMap, lenSpans)
 				actualData.Unlock()
 				break
 			}
@@ -275,10 +280,41 @@ func (tsp *tailSamplingSpanProcessor) ConsumeTraceData(ctx context.Context, td d
 		}
 	}
 
+	tsp.enforceMaxNumSpans()
+
 	stats.Record(tsp.ctx, statNewTraceIDReceivedCount.M(newTraceIDs))
 	return nil
 }
 
+// enforceMaxNumSpans drops the oldest traces until the number of spans on
+// memory is not above maxNumSpans.
+func (tsp *tailSamplingSpanProcessor) enforceMaxNumSpans() {
+	if tsp.maxNumSpans == 0 {
+		return
+	}
+	for uint64(atomic.LoadInt64(&tsp.numSpansOnMap)) > tsp.maxNumSpans {
+		select {
+		case traceKeyToDrop := <-tsp.deleteChan:
+			tsp.dropTrace(traceKeyToDrop, time.Now())
+		default:
+			return
+		}
+	}
+}
+
+// releaseBatches removes the batches kept for the trace and accounts for the
+// spans that are no longer on memory.
+func (tsp *tailSamplingSpanProcessor) releaseBatches(trace *sampling.TraceData) {
+	trace.Lock()
+	var numSpans int64
+	for _, batch := range trace.ReceivedBatches {
+		numSpans += int64(len(batch.Spans))
+	}
+	trace.ReceivedBatches = nil
+	atomic.AddInt64(&tsp.numSpansOnMap, -numSpans)
+	trace.Unlock()
+}
+
 func (tsp *tailSamplingSpanProcessor) dropTrace(traceID traceKey, deletionTime time.Time) {
 	var trace *sampling.TraceData
 	if d, ok := tsp.idToTrace.Load(traceID); ok {
@@ -304,6 +340,7 @@ func (tsp *tailSamplingSpanProcessor) dropTrace(traceID traceKey, deletionTime t
 			}
 		}
 	}
+	tsp.releaseBatches(trace)
 }
 
 func prepareTraceBatch(spans []*tracepb.Span, singleTrace bool, td data.TraceData) data.TraceData {
@@ -17,6 +17,7 @@ package tailsampling
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -104,6 +105,32 @@ func TestSequentialTraceMapSize(t *testing.T) {
 	}
 }
 
+func TestSequentialTraceMapSpanLimit(t *testing.T) {
+	// Trace i has i+1 spans, 210 spans in total.
+	traceIds, batches := generateIdsAndBatches(20)
+	const maxNumSpans = 100
+	sp, _ := NewTailSamplingSpanProcessor(newTestPolicy(), 1000, 64, defaultTestDecisionWait, zap.NewNop(), WithMaxNumSpans(maxNumSpans))
+	tsp := sp.(*tailSamplingSpanProcessor)
+	for _, batch := range batches {
+		tsp.ConsumeTraceData(context.Background(), batch)
+	}
+
+	// Only the last 5 traces, with 16 to 20 spans each, fit under the limit.
+	const firstKept = 15
+	for i := range traceIds {
+		_, ok := tsp.idToTrace.Load(traceKey(traceIds[i]))
+		if i < firstKept && ok {
+			t.Fatalf("Found unexpected traceId[%d] still on map (id: %v)", i, traceIds[i])
+		}
+		if i >= firstKept && !ok {
+			t.Fatalf("Expected traceId[%d] to be on the map (id: %v)", i, traceIds[i])
+		}
+	}
+	if got, want := atomic.LoadInt64(&tsp.numSpansOnMap), int64(16+17+18+19+20); got != want {
+		t.Fatalf("got %d spans on memory, want %d", got, want)
+	}
+}
+
 func TestConcurrentTraceMapSize(t *testing.T) {
 	_, batches := generateIdsAndBatches(210)
 	const maxSize = 100
@@ -180,6 +207,10 @@ func TestSamplingPolicyTypicalPath(t *testing.T) {
 	if msp.TotalSpans != numSpansPerBatchWindow {
 		t.Fatalf("not all spans of first window were accounted for: got %d, want %d", msp.TotalSpans, numSpansPerBatchWindow)
 	}
+	wantSpansOnMemory := int64(numSpansPerBatchWindow * (decisionWaitSeconds - 1))
+	if got := atomic.LoadInt64(&tsp.numSpansOnMap); got != wantSpansOnMemory {
+		t.Fatalf("spans of decided traces still accounted as on memory: got %d, want %d", got, wantSpansOnMemory)
+	}
 
 	// Late span of a sampled trace should be sent directly down the pipeline exporter
 	tsp.ConsumeTraceData(context.Background(), batches[0])
@@ -0,0 +1,125 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveSamplerWindow is the period over which the incoming spans are
+	// counted before the moving average of the rate is updated.
+	adaptiveSamplerWindow = time.Second
+	// adaptiveSamplerAlpha is the weight of the latest window in the
+	// exponentially weighted moving average of the rate.
+	adaptiveSamplerAlpha = 0.3
+)
+
+// AdaptiveSamplerStats holds the current state of an AdaptiveSampler.
+type AdaptiveSamplerStats struct {
+	// SpansPerSecond is the moving average of the rate of incoming spans.
+	SpansPerSecond float64
+	// Probability is the fraction of the spans currently sampled.
+	Probability float64
+}
+
+// AdaptiveSampler computes the probability of sampling that keeps the rate of
+// sampled spans close to a target, based on an exponentially weighted moving
+// average of the rate of incoming spans.
+type AdaptiveSampler struct {
+	targetSpansPerSecond float64
+	minSampleFraction    float64
+	now                  func() time.Time
+
+	mu             sync.Mutex
+	windowStart    time.Time
+	spansInWindow  int64
+	spansPerSecond float64
+	hasRate        bool
+	probability    float64
+}
+
+// NewAdaptiveSampler creates an AdaptiveSampler targeting targetSpansPerSecond
+// sampled spans. The probability never goes below minSampleFraction nor
+// above 1.0.
+func NewAdaptiveSampler(targetSpansPerSecond, minSampleFraction float64) *AdaptiveSampler {
+	return newAdaptiveSampler(targetSpansPerSecond, minSampleFraction, time.Now)
+}
+
+func newAdaptiveSampler(targetSpansPerSecond, minSampleFraction float64, now func() time.Time) *AdaptiveSampler {
+	if minSampleFraction < 0 {
+		minSampleFraction = 0
+	}
+	if minSampleFraction > 1 {
+		minSampleFraction = 1
+	}
+	return &AdaptiveSampler{
+		targetSpansPerSecond: targetSpansPerSecond,
+		minSampleFraction:    minSampleFraction,
+		now:                  now,
+		windowStart:          now(),
+		probability:          1.0,
+	}
+}
+
+// Observe records the arrival of numSpans spans, sampled or not, and returns
+// the probability with which they should be sampled.
+func (as *AdaptiveSampler) Observe(numSpans int) float64 {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	now := as.now()
+	if elapsed := now.Sub(as.windowStart); elapsed >= adaptiveSamplerWindow {
+		windowRate := float64(as.spansInWindow) / elapsed.Seconds()
+		if as.hasRate {
+			// A window longer than usual, e.g. after an idle period, weighs
+			// as much as the same number of consecutive windows.
+			numWindows := float64(elapsed) / float64(adaptiveSamplerWindow)
+			alpha := 1 - math.Pow(1-adaptiveSamplerAlpha, numWindows)
+			as.spansPerSecond = alpha*windowRate + (1-alpha)*as.spansPerSecond
+		} else {
+			as.spansPerSecond = windowRate
+			as.hasRate = true
+		}
+		as.probability = as.probabilityForRate(as.spansPerSecond)
+		as.windowStart = now
+		as.spansInWindow = 0
+	}
+	as.spansInWindow += int64(numSpans)
+	return as.probability
+}
+
+func (as *AdaptiveSampler) probabilityForRate(spansPerSecond float64) float64 {
+	if spansPerSecond <= as.targetSpansPerSecond {
+		return 1.0
+	}
+	probability := as.targetSpansPerSecond / spansPerSecond
+	if probability < as.minSampleFraction {
+		return as.minSampleFraction
+	}
+	return probability
+}
+
+// Stats returns the current rate of incoming spans and sampling probability.
+func (as *AdaptiveSampler) Stats() AdaptiveSamplerStats {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	return AdaptiveSamplerStats{
+		SpansPerSecond: as.spansPerSecond,
+		Probability:    as.probability,
+	}
+}
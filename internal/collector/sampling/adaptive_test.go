@@ -0,0 +1,96 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+// observeWindows simulates numWindows seconds receiving spansPerSecond spans,
+// and returns the probability returned by the last call to Observe.
+func observeWindows(as *AdaptiveSampler, clock *fakeClock, numWindows, spansPerSecond int) float64 {
+	var probability float64
+	for i := 0; i < numWindows; i++ {
+		probability = as.Observe(spansPerSecond)
+		clock.now = clock.now.Add(adaptiveSamplerWindow)
+	}
+	return probability
+}
+
+func TestAdaptiveSamplerSpike(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	as := newAdaptiveSampler(100, 0.01, clock.Now)
+
+	if p := observeWindows(as, clock, 10, 100); p != 1.0 {
+		t.Fatalf("At the target rate the probability is %v, want 1.0", p)
+	}
+
+	// A 10x spike, the probability must decrease as the average catches up.
+	previous := 1.0
+	for i := 0; i < 30; i++ {
+		p := observeWindows(as, clock, 1, 1000)
+		if p > previous {
+			t.Fatalf("The probability increased from %v to %v during the spike", previous, p)
+		}
+		previous = p
+	}
+	stats := as.Stats()
+	if math.Abs(stats.SpansPerSecond-1000) > 1 {
+		t.Errorf("Measured rate %v, want about 1000", stats.SpansPerSecond)
+	}
+	if math.Abs(stats.Probability-0.1) > 0.001 {
+		t.Errorf("Probability %v during a 10x spike, want about 0.1", stats.Probability)
+	}
+
+	// Back to normal.
+	if p := observeWindows(as, clock, 30, 100); p < 0.999 {
+		t.Errorf("After the spike the probability is %v, want about 1.0", p)
+	}
+}
+
+func TestAdaptiveSamplerMinSampleFraction(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	as := newAdaptiveSampler(100, 0.05, clock.Now)
+
+	if p := observeWindows(as, clock, 5, 100000); p != 0.05 {
+		t.Errorf("Probability %v, want the minimum of 0.05", p)
+	}
+	if got := as.Stats().Probability; got != 0.05 {
+		t.Errorf("Stats().Probability = %v, want 0.05", got)
+	}
+}
+
+func TestAdaptiveSamplerIdle(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1500000000, 0)}
+	as := newAdaptiveSampler(100, 0.01, clock.Now)
+	observeWindows(as, clock, 5, 1000)
+
+	// No spans for a long time, the next window covers the whole gap.
+	clock.now = clock.now.Add(time.Hour)
+	as.Observe(1)
+	if stats := as.Stats(); stats.Probability != 1.0 {
+		t.Errorf("After an idle period the probability is %v, want 1.0 (rate %v)", stats.Probability, stats.SpansPerSecond)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+type composite struct {
+	evaluators []PolicyEvaluator
+	// combine returns the decision of the composite given whether any, and
+	// whether all, of the evaluators decided to sample the trace.
+	combine func(anySampled, allSampled bool) Decision
+}
+
+var _ PolicyEvaluator = (*composite)(nil)
+
+// NewAnd creates a policy evaluator that samples the traces sampled by all the
+// given evaluators. All of them evaluate every trace, even once the decision
+// is known, so that they can keep their internal state, e.g. rate limits, up
+// to date.
+func NewAnd(evaluators ...PolicyEvaluator) PolicyEvaluator {
+	return &composite{
+		evaluators: evaluators,
+		combine: func(anySampled, allSampled bool) Decision {
+			if allSampled {
+				return Sampled
+			}
+			return NotSampled
+		},
+	}
+}
+
+// NewOr creates a policy evaluator that samples the traces sampled by any of
+// the given evaluators. All of them evaluate every trace, even once the
+// decision is known, so that they can keep their internal state, e.g. rate
+// limits, up to date.
+func NewOr(evaluators ...PolicyEvaluator) PolicyEvaluator {
+	return &composite{
+		evaluators: evaluators,
+		combine: func(anySampled, allSampled bool) Decision {
+			if anySampled {
+				return Sampled
+			}
+			return NotSampled
+		},
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (c *composite) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	var errs []error
+	for _, evaluator := range c.evaluators {
+		if err := evaluator.OnLateArrivingSpans(earlyDecision, spans); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+// An evaluator failing counts as not sampling the trace, the errors are only
+// returned if the trace is not sampled.
+func (c *composite) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	decision, errs := c.decide(func(evaluator PolicyEvaluator) (Decision, error) {
+		return evaluator.Evaluate(traceID, trace)
+	})
+	if decision == Sampled {
+		return Sampled, nil
+	}
+	return decision, internal.CombineErrors(errs)
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (c *composite) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	decision, errs := c.decide(func(evaluator PolicyEvaluator) (Decision, error) {
+		return evaluator.OnDroppedSpans(traceID, trace)
+	})
+	return decision, internal.CombineErrors(errs)
+}
+
+func (c *composite) decide(eval func(PolicyEvaluator) (Decision, error)) (Decision, []error) {
+	var errs []error
+	anySampled, allSampled := false, true
+	for _, evaluator := range c.evaluators {
+		decision, err := eval(evaluator)
+		if err != nil {
+			errs = append(errs, err)
+			decision = NotSampled
+		}
+		sampled := decision == Sampled
+		anySampled = anySampled || sampled
+		allSampled = allSampled && sampled
+	}
+	return c.combine(anySampled, allSampled), errs
+}
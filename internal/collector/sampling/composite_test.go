@@ -0,0 +1,166 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"errors"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+type countingEvaluator struct {
+	decision      Decision
+	err           error
+	evaluations   int
+	lateArrivals  int
+	droppedTraces int
+}
+
+var _ PolicyEvaluator = (*countingEvaluator)(nil)
+
+func (ce *countingEvaluator) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	ce.lateArrivals++
+	return ce.err
+}
+
+func (ce *countingEvaluator) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	ce.evaluations++
+	return ce.decision, ce.err
+}
+
+func (ce *countingEvaluator) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	ce.droppedTraces++
+	return ce.decision, ce.err
+}
+
+func TestCompositeDecisions(t *testing.T) {
+	errEval := errors.New("evaluation failed")
+	sampled := func() *countingEvaluator { return &countingEvaluator{decision: Sampled} }
+	notSampled := func() *countingEvaluator { return &countingEvaluator{decision: NotSampled} }
+	failing := func() *countingEvaluator { return &countingEvaluator{decision: Sampled, err: errEval} }
+
+	tests := []struct {
+		name       string
+		evaluators []*countingEvaluator
+		wantAnd    Decision
+		wantAndErr bool
+		wantOr     Decision
+		wantOrErr  bool
+	}{
+		{
+			name:    "none",
+			wantAnd: Sampled,
+			wantOr:  NotSampled,
+		},
+		{
+			name:       "all_sampled",
+			evaluators: []*countingEvaluator{sampled(), sampled()},
+			wantAnd:    Sampled,
+			wantOr:     Sampled,
+		},
+		{
+			name:       "none_sampled",
+			evaluators: []*countingEvaluator{notSampled(), notSampled()},
+			wantAnd:    NotSampled,
+			wantOr:     NotSampled,
+		},
+		{
+			// The first decision settles AND, the others must still be evaluated.
+			name:       "first_not_sampled",
+			evaluators: []*countingEvaluator{notSampled(), sampled(), sampled()},
+			wantAnd:    NotSampled,
+			wantOr:     Sampled,
+		},
+		{
+			// The first decision settles OR, the others must still be evaluated.
+			name:       "first_sampled",
+			evaluators: []*countingEvaluator{sampled(), notSampled(), notSampled()},
+			wantAnd:    NotSampled,
+			wantOr:     Sampled,
+		},
+		{
+			name:       "failing_and_sampled",
+			evaluators: []*countingEvaluator{failing(), sampled()},
+			wantAnd:    NotSampled,
+			wantAndErr: true,
+			wantOr:     Sampled,
+		},
+		{
+			name:       "failing_and_not_sampled",
+			evaluators: []*countingEvaluator{failing(), notSampled()},
+			wantAnd:    NotSampled,
+			wantAndErr: true,
+			wantOr:     NotSampled,
+			wantOrErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluators := make([]PolicyEvaluator, len(tt.evaluators))
+			for i, ce := range tt.evaluators {
+				evaluators[i] = ce
+			}
+			trace := &TraceData{SpanCount: 1}
+
+			decision, err := NewAnd(evaluators...).Evaluate([]byte{1}, trace)
+			if decision != tt.wantAnd || (err != nil) != tt.wantAndErr {
+				t.Errorf("And: Evaluate() = (%v, %v), want (%v, error: %v)", decision, err, tt.wantAnd, tt.wantAndErr)
+			}
+			decision, err = NewOr(evaluators...).Evaluate([]byte{1}, trace)
+			if decision != tt.wantOr || (err != nil) != tt.wantOrErr {
+				t.Errorf("Or: Evaluate() = (%v, %v), want (%v, error: %v)", decision, err, tt.wantOr, tt.wantOrErr)
+			}
+
+			for i, ce := range tt.evaluators {
+				if ce.evaluations != 2 {
+					t.Errorf("Evaluator #%d was evaluated %d times, want 2", i, ce.evaluations)
+				}
+			}
+		})
+	}
+}
+
+func TestCompositeForwardsNotifications(t *testing.T) {
+	errLate := errors.New("late spans")
+	first := &countingEvaluator{decision: NotSampled, err: errLate}
+	second := &countingEvaluator{decision: Sampled}
+
+	for _, composite := range []PolicyEvaluator{NewAnd(first, second), NewOr(first, second)} {
+		if err := composite.OnLateArrivingSpans(Sampled, nil); err == nil {
+			t.Error("OnLateArrivingSpans() should return the error of the first evaluator")
+		}
+		if _, err := composite.OnDroppedSpans([]byte{1}, &TraceData{}); err == nil {
+			t.Error("OnDroppedSpans() should return the error of the first evaluator")
+		}
+	}
+
+	for i, ce := range []*countingEvaluator{first, second} {
+		if ce.lateArrivals != 2 || ce.droppedTraces != 2 {
+			t.Errorf("Evaluator #%d got %d late arrivals and %d dropped traces, want 2 of each", i, ce.lateArrivals, ce.droppedTraces)
+		}
+	}
+}
+
+func TestCompositeNested(t *testing.T) {
+	// (sampled AND not sampled) OR (sampled AND sampled)
+	composite := NewOr(
+		NewAnd(&countingEvaluator{decision: Sampled}, &countingEvaluator{decision: NotSampled}),
+		NewAnd(&countingEvaluator{decision: Sampled}, &countingEvaluator{decision: Sampled}),
+	)
+	if decision, err := composite.Evaluate([]byte{1}, &TraceData{}); decision != Sampled || err != nil {
+		t.Errorf("Evaluate() = (%v, %v), want (%v, nil)", decision, err, Sampled)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/resource"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	spandatatranslator "github.com/census-instrumentation/opencensus-service/translator/trace/spandata"
+)
+
+// SampleParams are the parameters of the sampling decision of a span, as
+// passed to a trace.Sampler.
+type SampleParams trace.SamplingParameters
+
+// ResourceAwareSampler makes the sampling decision of a span knowing the
+// resource it comes from, including the attributes added by the collector,
+// e.g. by the k8s enricher processor.
+type ResourceAwareSampler interface {
+	ShouldSample(params SampleParams, res *resource.Resource) trace.SamplingDecision
+}
+
+// ResourceSamplerFunc is a function implementing ResourceAwareSampler.
+type ResourceSamplerFunc func(params SampleParams, res *resource.Resource) trace.SamplingDecision
+
+var _ ResourceAwareSampler = (ResourceSamplerFunc)(nil)
+
+// ShouldSample returns f(params, res).
+func (f ResourceSamplerFunc) ShouldSample(params SampleParams, res *resource.Resource) trace.SamplingDecision {
+	return f(params, res)
+}
+
+type samplerWrapper struct {
+	sampler trace.Sampler
+}
+
+var _ ResourceAwareSampler = (*samplerWrapper)(nil)
+
+// WrapSampler returns a ResourceAwareSampler making the decisions of sampler,
+// which ignores the resource.
+func WrapSampler(sampler trace.Sampler) ResourceAwareSampler {
+	return &samplerWrapper{sampler: sampler}
+}
+
+func (sw *samplerWrapper) ShouldSample(params SampleParams, _ *resource.Resource) trace.SamplingDecision {
+	return sw.sampler(trace.SamplingParameters(params))
+}
+
+// SampleParamsFromSpan returns the sampling parameters of a span received by
+// the collector. Its parent, if any, is considered sampled since it was
+// sampled by the application that sent the span.
+func SampleParamsFromSpan(span *tracepb.Span) SampleParams {
+	var params SampleParams
+	copy(params.TraceID[:], span.TraceId)
+	copy(params.SpanID[:], span.SpanId)
+	params.Name = span.GetName().GetValue()
+	if len(span.ParentSpanId) > 0 {
+		params.ParentContext.TraceID = params.TraceID
+		copy(params.ParentContext.SpanID[:], span.ParentSpanId)
+		params.ParentContext.TraceOptions = 1 // Sampled.
+		params.HasRemoteParent = span.SameProcessAsParentSpan != nil && !span.SameProcessAsParentSpan.Value
+	}
+	return params
+}
+
+// SampleTraceData returns the trace data with only the spans sampled by
+// sampler. The resource passed to it has the node attributes and resource
+// labels of the trace data.
+func SampleTraceData(sampler ResourceAwareSampler, td data.TraceData) data.TraceData {
+	res := spandatatranslator.ProtoResourceToOCResource(td.Node, td.Resource)
+	sampled := data.TraceData{
+		Node:         td.Node,
+		Resource:     td.Resource,
+		SourceFormat: td.SourceFormat,
+		Spans:        make([]*tracepb.Span, 0, len(td.Spans)),
+	}
+	for _, span := range td.Spans {
+		if span == nil {
+			continue
+		}
+		if sampler.ShouldSample(SampleParamsFromSpan(span), res).Sample {
+			sampled.Spans = append(sampled.Spans, span)
+		}
+	}
+	return sampled
+}
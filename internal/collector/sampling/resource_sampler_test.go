@@ -0,0 +1,115 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"testing"
+
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"go.opencensus.io/resource"
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// dropStaging drops the spans of the staging namespace, added to the resource
+// by the k8s enricher processor.
+var dropStaging = ResourceSamplerFunc(func(params SampleParams, res *resource.Resource) trace.SamplingDecision {
+	if res != nil && res.Labels["k8s.namespace"] == "staging" {
+		return trace.SamplingDecision{Sample: false}
+	}
+	return trace.SamplingDecision{Sample: true}
+})
+
+func TestSampleTraceData_ResourceFilter(t *testing.T) {
+	spans := []*tracepb.Span{
+		{TraceId: []byte{1}, SpanId: []byte{1}},
+		{TraceId: []byte{1}, SpanId: []byte{2}, ParentSpanId: []byte{1}},
+	}
+	tests := []struct {
+		name     string
+		resource *resourcepb.Resource
+		want     int
+	}{
+		{"staging", &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "staging"}}, 0},
+		{"production", &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "production"}}, 2},
+		{"no namespace", &resourcepb.Resource{Type: "k8s"}, 2},
+		{"no resource", nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := data.TraceData{Resource: tt.resource, Spans: spans, SourceFormat: "oc_trace"}
+			got := SampleTraceData(dropStaging, td)
+			if len(got.Spans) != tt.want {
+				t.Errorf("SampleTraceData() kept %d spans, want %d", len(got.Spans), tt.want)
+			}
+			if got.Resource != td.Resource || got.SourceFormat != td.SourceFormat {
+				t.Errorf("SampleTraceData() = %+v, want the resource and source format of %+v", got, td)
+			}
+		})
+	}
+}
+
+func TestWrapSampler(t *testing.T) {
+	res := &resource.Resource{Labels: map[string]string{"k8s.namespace": "staging"}}
+	params := SampleParams{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}}
+	if !WrapSampler(trace.AlwaysSample()).ShouldSample(params, res).Sample {
+		t.Error("AlwaysSample() wrapper didn't sample")
+	}
+	if WrapSampler(trace.NeverSample()).ShouldSample(params, res).Sample {
+		t.Error("NeverSample() wrapper sampled")
+	}
+
+	var got SampleParams
+	recording := func(p trace.SamplingParameters) trace.SamplingDecision {
+		got = SampleParams(p)
+		return trace.SamplingDecision{Sample: true}
+	}
+	WrapSampler(recording).ShouldSample(params, res)
+	if got != params {
+		t.Errorf("wrapped sampler got %+v, want %+v", got, params)
+	}
+}
+
+func TestSampleParamsFromSpan(t *testing.T) {
+	span := &tracepb.Span{
+		TraceId:                 []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanId:                  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		ParentSpanId:            []byte{8, 7, 6, 5, 4, 3, 2, 1},
+		Name:                    &tracepb.TruncatableString{Value: "get"},
+		SameProcessAsParentSpan: &wrappers.BoolValue{Value: false},
+	}
+	want := SampleParams{
+		ParentContext: trace.SpanContext{
+			TraceID:      trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			SpanID:       trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+			TraceOptions: 1,
+		},
+		TraceID:         trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:          trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		Name:            "get",
+		HasRemoteParent: true,
+	}
+	if got := SampleParamsFromSpan(span); got != want {
+		t.Errorf("SampleParamsFromSpan() = %+v, want %+v", got, want)
+	}
+
+	root := SampleParamsFromSpan(&tracepb.Span{TraceId: span.TraceId, SpanId: span.SpanId})
+	if root.ParentContext != (trace.SpanContext{}) || root.HasRemoteParent {
+		t.Errorf("SampleParamsFromSpan() of a root span = %+v, want no parent", root)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"encoding/binary"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+type traceIDRatio struct {
+	upperBound uint64
+}
+
+var _ PolicyEvaluator = (*traceIDRatio)(nil)
+
+// NewTraceIDRatio creates a policy evaluator that samples the given fraction
+// of the traces. The decision only depends on the trace ID: like the
+// probability sampler of the OpenCensus libraries, the first 8 bytes of the
+// trace ID, as a big-endian integer, are compared to the fraction of their
+// range. So the same traces are sampled by every collector and by the
+// applications using the same fraction.
+func NewTraceIDRatio(fraction float64) PolicyEvaluator {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	// The lowest bit is ignored so that the bound of 1.0 still fits in 64 bits.
+	return &traceIDRatio{
+		upperBound: uint64(fraction * (1 << 63)),
+	}
+}
+
+// OnLateArrivingSpans notifies the evaluator that the given list of spans arrived
+// after the sampling decision was already taken for the trace.
+// This gives the evaluator a chance to log any message/metrics and/or update any
+// related internal state.
+func (tir *traceIDRatio) OnLateArrivingSpans(earlyDecision Decision, spans []*tracepb.Span) error {
+	return nil
+}
+
+// Evaluate looks at the trace data and returns a corresponding SamplingDecision.
+func (tir *traceIDRatio) Evaluate(traceID []byte, trace *TraceData) (Decision, error) {
+	// Trace IDs shorter than 8 bytes are invalid, they are not sampled.
+	if len(traceID) >= 8 && binary.BigEndian.Uint64(traceID[:8])>>1 < tir.upperBound {
+		return Sampled, nil
+	}
+	return NotSampled, nil
+}
+
+// OnDroppedSpans is called when the trace needs to be dropped, due to memory
+// pressure, before the decision_wait time has been reached.
+func (tir *traceIDRatio) OnDroppedSpans(traceID []byte, trace *TraceData) (Decision, error) {
+	return tir.Evaluate(traceID, trace)
+}
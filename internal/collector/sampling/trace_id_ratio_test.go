@@ -0,0 +1,80 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestTraceIDRatioSampleRate(t *testing.T) {
+	const numTraces = 10000
+	rnd := rand.New(rand.NewSource(42))
+	traceIDs := make([][]byte, numTraces)
+	for i := range traceIDs {
+		traceIDs[i] = make([]byte, 16)
+		rnd.Read(traceIDs[i])
+	}
+
+	for _, fraction := range []float64{0, 0.01, 0.1, 0.25, 0.5, 0.9, 1} {
+		evaluator := NewTraceIDRatio(fraction)
+		sampled := 0
+		for _, traceID := range traceIDs {
+			decision, err := evaluator.Evaluate(traceID, &TraceData{})
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if decision == Sampled {
+				sampled++
+			}
+		}
+		if rate := float64(sampled) / numTraces; math.Abs(rate-fraction) > 0.02 {
+			t.Errorf("Fraction %v: sampled %v of the traces", fraction, rate)
+		}
+	}
+}
+
+func TestTraceIDRatioIsConsistent(t *testing.T) {
+	low := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	middle := []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	high := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+	tests := []struct {
+		traceID  []byte
+		fraction float64
+		want     Decision
+	}{
+		{traceID: low, fraction: 0, want: NotSampled},
+		{traceID: low, fraction: 0.01, want: Sampled},
+		{traceID: middle, fraction: 0.5, want: NotSampled},
+		{traceID: middle, fraction: 0.51, want: Sampled},
+		{traceID: high, fraction: 0.99, want: NotSampled},
+		{traceID: high, fraction: 1, want: Sampled},
+		{traceID: high, fraction: 2, want: Sampled},
+		{traceID: []byte{0x00}, fraction: 1, want: NotSampled},
+	}
+	for i, tt := range tests {
+		evaluator := NewTraceIDRatio(tt.fraction)
+		// The decision must not change, whatever the trace or the number of
+		// evaluations.
+		for j := 0; j < 3; j++ {
+			decision, _ := evaluator.Evaluate(tt.traceID, &TraceData{SpanCount: int64(j)})
+			if decision != tt.want {
+				t.Errorf("#%d: Evaluate() = %v, want %v", i, decision, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selftracing traces the collector itself: the spans flowing through
+// the pipeline are wrapped in internal OpenCensus spans, one per batch and
+// per step, which are exported apart from the pipeline to avoid any feedback
+// loop.
+package selftracing
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// The names of the internal spans: the root span of a batch received by the
+// pipeline, and its children for the processor steps and the exporter calls.
+const (
+	ReceiveSpanName = "collector/receive"
+	ProcessSpanName = "collector/process"
+	ExportSpanName  = "collector/export"
+)
+
+const (
+	numSpansAttribute = "num_spans"
+	stepAttribute     = "step"
+)
+
+type tracedConsumer struct {
+	nextConsumer consumer.TraceConsumer
+	spanName     string
+	step         string
+}
+
+var _ processor.TraceProcessor = (*tracedConsumer)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that passes the spans
+// to nextConsumer within an internal span named spanName, with a step
+// attribute naming the processor or the exporter unless step is empty. It is
+// the child of the internal span of the previous step, if any.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, spanName, step string) processor.TraceProcessor {
+	return &tracedConsumer{
+		nextConsumer: nextConsumer,
+		spanName:     spanName,
+		step:         step,
+	}
+}
+
+func (tc *tracedConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	ctx, span := trace.StartSpan(ctx, tc.spanName)
+	defer span.End()
+	if tc.step != "" && span.IsRecordingEvents() {
+		span.AddAttributes(trace.StringAttribute(stepAttribute, tc.step))
+	}
+
+	err := tc.nextConsumer.ConsumeTraceData(ctx, td)
+	if span.IsRecordingEvents() {
+		span.AddAttributes(trace.Int64Attribute(numSpansAttribute, int64(len(td.Spans))))
+		if err != nil {
+			span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+		}
+	}
+	return err
+}
+
+// Enable samples all the internal spans and exports them to internalExporter,
+// until the returned function is called. internalExporter must not pass the
+// spans to the pipeline, they would be traced again.
+func Enable(internalExporter trace.Exporter) (disable func()) {
+	trace.RegisterExporter(internalExporter)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	return func() {
+		trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(1e-4)})
+		trace.UnregisterExporter(internalExporter)
+	}
+}
+
+type loggingExporter struct {
+	logger *zap.Logger
+}
+
+var _ trace.Exporter = (*loggingExporter)(nil)
+
+// NewLoggingExporter returns the trace.Exporter logging the internal spans at
+// debug level.
+func NewLoggingExporter(logger *zap.Logger) trace.Exporter {
+	return &loggingExporter{logger: logger}
+}
+
+func (le *loggingExporter) ExportSpan(sd *trace.SpanData) {
+	le.logger.Debug("Internal span",
+		zap.String("name", sd.Name),
+		zap.Stringer("trace-id", sd.TraceID),
+		zap.Stringer("span-id", sd.SpanID),
+		zap.Stringer("parent-span-id", sd.ParentSpanID),
+		zap.Duration("duration", sd.EndTime.Sub(sd.StartTime)),
+		zap.Int32("status-code", sd.Status.Code),
+		zap.Any("attributes", sd.Attributes))
+}
@@ -0,0 +1,136 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selftracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/trace"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (re *recordingExporter) ExportSpan(sd *trace.SpanData) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.spans = append(re.spans, sd)
+}
+
+func (re *recordingExporter) allSpans() []*trace.SpanData {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	return append([]*trace.SpanData(nil), re.spans...)
+}
+
+func TestSelfTracing(t *testing.T) {
+	internal := &recordingExporter{}
+	disable := Enable(internal)
+	defer disable()
+
+	sink := &exportertest.SinkTraceExporter{}
+	pipeline := NewTraceProcessor(
+		NewTraceProcessor(
+			NewTraceProcessor(sink, ExportSpanName, "sink"),
+			ProcessSpanName, "truncator"),
+		ReceiveSpanName, "")
+
+	td := data.TraceData{Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "user-span"}}}}
+	if err := pipeline.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	if got := sink.AllTraces(); len(got) != 1 || len(got[0].Spans) != 1 {
+		t.Fatalf("The pipeline exported %v, want only the received span", got)
+	}
+
+	spans := internal.allSpans()
+	if len(spans) != 3 {
+		t.Fatalf("Got %d internal spans, want 3: %v", len(spans), spans)
+	}
+	byName := make(map[string]*trace.SpanData)
+	for _, sd := range spans {
+		byName[sd.Name] = sd
+		if got := sd.Attributes[numSpansAttribute]; got != int64(1) {
+			t.Errorf("Span %q attribute %s = %v, want 1", sd.Name, numSpansAttribute, got)
+		}
+	}
+	root, processor, exporter := byName[ReceiveSpanName], byName[ProcessSpanName], byName[ExportSpanName]
+	if root == nil || processor == nil || exporter == nil {
+		t.Fatalf("Got internal spans %v, want pipeline, processor and exporter", spans)
+	}
+	if _, ok := root.Attributes[stepAttribute]; ok {
+		t.Errorf("The pipeline span has a %s attribute", stepAttribute)
+	}
+	if got := processor.Attributes[stepAttribute]; got != "truncator" {
+		t.Errorf("Processor span attribute %s = %v, want truncator", stepAttribute, got)
+	}
+	if got := exporter.Attributes[stepAttribute]; got != "sink" {
+		t.Errorf("Exporter span attribute %s = %v, want sink", stepAttribute, got)
+	}
+	if root.ParentSpanID != (trace.SpanID{}) {
+		t.Errorf("The pipeline span has a parent %v", root.ParentSpanID)
+	}
+	if processor.ParentSpanID != root.SpanID {
+		t.Errorf("The processor span is not a child of the pipeline span")
+	}
+	if exporter.ParentSpanID != processor.SpanID {
+		t.Errorf("The exporter span is not a child of the processor span")
+	}
+}
+
+func TestSelfTracingError(t *testing.T) {
+	internal := &recordingExporter{}
+	disable := Enable(internal)
+	defer disable()
+
+	exportErr := errors.New("export failed")
+	tc := NewTraceProcessor(exportertest.NewNopTraceExporter(exportertest.WithReturnError(exportErr)), ExportSpanName, "nop")
+	if err := tc.ConsumeTraceData(context.Background(), data.TraceData{}); err != exportErr {
+		t.Fatalf("ConsumeTraceData() = %v, want %v", err, exportErr)
+	}
+
+	spans := internal.allSpans()
+	if len(spans) != 1 || spans[0].Status.Code != trace.StatusCodeUnknown {
+		t.Errorf("Got internal spans %v, want one with an error status", spans)
+	}
+}
+
+func TestSelfTracingDisabled(t *testing.T) {
+	internal := &recordingExporter{}
+	Enable(internal)()
+
+	tc := NewTraceProcessor(exportertest.NewNopTraceExporter(), ExportSpanName, "nop")
+	tc.ConsumeTraceData(context.Background(), data.TraceData{})
+	if spans := internal.allSpans(); len(spans) != 0 {
+		t.Errorf("Got internal spans %v once disabled, want none", spans)
+	}
+}
+
+func TestLoggingExporter(t *testing.T) {
+	// Only checks that logging never fails, e.g. on spans without a parent.
+	le := NewLoggingExporter(zap.NewNop())
+	le.ExportSpan(&trace.SpanData{Name: "span"})
+}
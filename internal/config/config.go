@@ -20,6 +20,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -27,18 +28,31 @@ import (
 	"google.golang.org/grpc/credentials"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/exporter/appdynamicsexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/awsexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/datadogexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/dynatraceexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/elasticsearchexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/fileexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/honeycombexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/influxdbexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/jaegerexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/kafkaexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/lokiexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/natsexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/newrelicexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/opencensusexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/otlp"
 	"github.com/census-instrumentation/opencensus-service/exporter/prometheusexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/splunkexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/stackdriverexporter"
+	"github.com/census-instrumentation/opencensus-service/exporter/tempoexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/wavefrontexporter"
 	"github.com/census-instrumentation/opencensus-service/exporter/zipkinexporter"
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/otlphttpreceiver"
 	"github.com/census-instrumentation/opencensus-service/receiver/prometheusreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/statsdreceiver"
 )
 
 // We expect the configuration.yaml file to look like this:
@@ -69,10 +83,14 @@ import (
 //
 //  zpages:
 //      port: 55679
+//
+//  admin:
+//      port: 13133
 
 const (
 	defaultOCReceiverAddress = ":55678"
 	defaultZPagesPort        = 55679
+	defaultAdminPort         = 13133
 )
 
 var defaultOCReceiverCorsAllowedOrigins = []string{}
@@ -85,17 +103,30 @@ var defaultScribeConfiguration = &ScribeReceiverConfig{
 // Config denotes the configuration for the various elements of an agent, that is:
 // * Receivers
 // * ZPages
+// * Admin
 // * Exporters
 type Config struct {
 	Receivers *Receivers    `mapstructure:"receivers"`
 	ZPages    *ZPagesConfig `mapstructure:"zpages"`
+	Admin     *AdminConfig  `mapstructure:"admin"`
 	Exporters *Exporters    `mapstructure:"exporters"`
+	Batching  *Batching     `mapstructure:"batching"`
+}
+
+// Batching denotes the configuration for batching the spans received before
+// sending them to the exporters. A batch is sent once it holds more than
+// SendBatchSize spans or when Timeout elapsed since the previous one.
+type Batching struct {
+	SendBatchSize int           `mapstructure:"send_batch_size"`
+	Timeout       time.Duration `mapstructure:"timeout"`
 }
 
 // Receivers denotes configurations for the various telemetry ingesters, such as:
 // * Jaeger (traces)
 // * OpenCensus (metrics and traces)
+// * OTLP/HTTP (traces)
 // * Prometheus (metrics)
+// * StatsD (metrics)
 // * Zipkin (traces)
 type Receivers struct {
 	OpenCensus *ReceiverConfig       `mapstructure:"opencensus"`
@@ -103,6 +134,8 @@ type Receivers struct {
 	Jaeger     *ReceiverConfig       `mapstructure:"jaeger"`
 	Scribe     *ScribeReceiverConfig `mapstructure:"zipkin-scribe"`
 	VMMetrics  *ReceiverConfig       `mapstructure:"vmmetrics"`
+	StatsD     *StatsDReceiverConfig `mapstructure:"statsd"`
+	OTLPHTTP   *ReceiverConfig       `mapstructure:"otlphttp"`
 
 	// Prometheus contains the Prometheus configurations.
 	// Such as:
@@ -138,6 +171,11 @@ type ReceiverConfig struct {
 
 	// TLSCredentials is a (cert_file, key_file) configuration.
 	TLSCredentials *TLSCredentials `mapstructure:"tls_credentials"`
+
+	// MaxRecvMsgSizeMiB sets the maximum size (in MiB) of the gRPC messages
+	// accepted by the OpenCensus receiver, larger messages are rejected with
+	// a ResourceExhausted status. The gRPC default of 4 MiB is used if unset.
+	MaxRecvMsgSizeMiB uint64 `mapstructure:"max_recv_msg_size_mib"`
 }
 
 // ScribeReceiverConfig carries the settings for the Zipkin Scribe receiver.
@@ -155,6 +193,15 @@ type ScribeReceiverConfig struct {
 	Category string `mapstructure:"category" mapstructure:"category"`
 }
 
+// StatsDReceiverConfig carries the settings for the StatsD receiver.
+type StatsDReceiverConfig struct {
+	// Address is the UDP address the receiver listens on, it defaults to ":8125".
+	Address string `mapstructure:"address"`
+	// FlushInterval is how often the aggregated metrics are exported, it
+	// defaults to 10s.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+}
+
 // Exporters denotes the configurations for the various backends
 // that this service exports observability signals to.
 type Exporters struct {
@@ -167,6 +214,14 @@ type ZPagesConfig struct {
 	Port     int  `mapstructure:"port"`
 }
 
+// AdminConfig denotes the configuration that the admin server, serving the
+// /healthz, /readyz and /metrics endpoints, will be run with.
+type AdminConfig struct {
+	Port int `mapstructure:"port"`
+	// EnablePprof if set, serves the net/http/pprof profiles under /debug/pprof/.
+	EnablePprof bool `mapstructure:"enable_pprof"`
+}
+
 // OpenCensusReceiverAddress is a helper to safely retrieve the address
 // that the OpenCensus receiver will be bound to.
 // If Config is nil or the OpenCensus receiver's configuration is nil, it
@@ -195,6 +250,16 @@ func (c *Config) OpenCensusReceiverCorsAllowedOrigins() []string {
 	return inCfg.OpenCensus.CorsAllowedOrigins
 }
 
+// OpenCensusReceiverMaxRecvMsgSizeMiB is a helper to safely retrieve the
+// maximum size of the gRPC messages accepted by the OpenCensus receiver, it
+// returns 0 if unset.
+func (c *Config) OpenCensusReceiverMaxRecvMsgSizeMiB() uint64 {
+	if c == nil || c.Receivers == nil || c.Receivers.OpenCensus == nil {
+		return 0
+	}
+	return c.Receivers.OpenCensus.MaxRecvMsgSizeMiB
+}
+
 // CanRunOpenCensusTraceReceiver returns true if the configuration
 // permits running the OpenCensus Trace receiver.
 func (c *Config) CanRunOpenCensusTraceReceiver() bool {
@@ -239,6 +304,27 @@ func (c *Config) ZPagesPort() (int, bool) {
 	return port, true
 }
 
+// AdminPort tries to dereference the port on which the admin server will be
+// served.
+// If the admin server is not configured, it returns (-1, false)
+// Else if no port is set, it returns the default 13133
+func (c *Config) AdminPort() (int, bool) {
+	if c == nil || c.Admin == nil {
+		return -1, false
+	}
+	port := defaultAdminPort
+	if c.Admin.Port > 0 {
+		port = c.Admin.Port
+	}
+	return port, true
+}
+
+// AdminPprofEnabled returns true if the admin server is configured to serve
+// the net/http/pprof profiles.
+func (c *Config) AdminPprofEnabled() bool {
+	return c != nil && c.Admin != nil && c.Admin.EnablePprof
+}
+
 // ZipkinReceiverEnabled returns true if Config is non-nil
 // and if the Zipkin receiver configuration is also non-nil.
 func (c *Config) ZipkinReceiverEnabled() bool {
@@ -248,6 +334,15 @@ func (c *Config) ZipkinReceiverEnabled() bool {
 	return c.Receivers != nil && c.Receivers.Zipkin != nil
 }
 
+// OTLPHTTPReceiverEnabled returns true if Config is non-nil
+// and if the OTLP/HTTP receiver configuration is also non-nil.
+func (c *Config) OTLPHTTPReceiverEnabled() bool {
+	if c == nil {
+		return false
+	}
+	return c.Receivers != nil && c.Receivers.OTLPHTTP != nil
+}
+
 // ZipkinScribeReceiverEnabled returns true if Config is non-nil
 // and if the Scribe receiver configuration is also non-nil.
 func (c *Config) ZipkinScribeReceiverEnabled() bool {
@@ -299,6 +394,21 @@ func (c *Config) ZipkinReceiverAddress() string {
 	return inCfg.Zipkin.Address
 }
 
+// OTLPHTTPReceiverAddress is a helper to safely retrieve the address
+// that the OTLP/HTTP receiver will run on.
+// If Config is nil or the OTLP/HTTP receiver's configuration is nil, it
+// will return the default of ":4318"
+func (c *Config) OTLPHTTPReceiverAddress() string {
+	if c == nil || c.Receivers == nil {
+		return otlphttpreceiver.DefaultAddress
+	}
+	inCfg := c.Receivers
+	if inCfg.OTLPHTTP == nil || inCfg.OTLPHTTP.Address == "" {
+		return otlphttpreceiver.DefaultAddress
+	}
+	return inCfg.OTLPHTTP.Address
+}
+
 // ZipkinScribeConfig is a helper to safely retrieve the Zipkin Scribe
 // configuration.
 func (c *Config) ZipkinScribeConfig() *ScribeReceiverConfig {
@@ -353,16 +463,19 @@ func (c *Config) OpenCensusReceiverTLSServerCredentials() *TLSCredentials {
 // it will return opencensusreceiver.WithNoopOption() and a nil error.
 // Otherwise, it will try to retrieve gRPC transport credentials from the file combinations,
 // and create a option, along with any errors encountered while retrieving the credentials.
-func (tlsCreds *TLSCredentials) ToOpenCensusReceiverServerOption() (opt opencensusreceiver.Option, ok bool, err error) {
+// The certificate is reloaded when its files change, the reload errors being
+// logged to logger.
+func (tlsCreds *TLSCredentials) ToOpenCensusReceiverServerOption(logger *zap.Logger) (opt opencensusreceiver.Option, ok bool, err error) {
 	if tlsCreds == nil {
 		return opencensusreceiver.WithNoopOption(), false, nil
 	}
 
-	transportCreds, err := credentials.NewServerTLSFromFile(tlsCreds.CertFile, tlsCreds.KeyFile)
+	// The certificate files are watched for the lifetime of the process.
+	tlsConfig, _, err := tlsCreds.ServerTLSConfig(logger)
 	if err != nil {
 		return nil, false, err
 	}
-	gRPCCredsOpt := grpc.Creds(transportCreds)
+	gRPCCredsOpt := grpc.Creds(credentials.NewTLS(tlsConfig))
 	return opencensusreceiver.WithGRPCServerOptions(gRPCCredsOpt), true, nil
 }
 
@@ -371,9 +484,9 @@ func (tlsCreds *TLSCredentials) ToOpenCensusReceiverServerOption() (opt opencens
 // have any, it will return opencensusreceiver.WithNoopOption() and a nil error.
 // Otherwise, it will try to retrieve gRPC transport credentials from the file combinations,
 // and create a option, along with any errors encountered while retrieving the credentials.
-func (c *Config) OpenCensusReceiverTLSCredentialsServerOption() (opt opencensusreceiver.Option, ok bool, err error) {
+func (c *Config) OpenCensusReceiverTLSCredentialsServerOption(logger *zap.Logger) (opt opencensusreceiver.Option, ok bool, err error) {
 	tlsCreds := c.OpenCensusReceiverTLSServerCredentials()
-	return tlsCreds.ToOpenCensusReceiverServerOption()
+	return tlsCreds.ToOpenCensusReceiverServerOption(logger)
 }
 
 // VMMetricsReceiverEnabled returns true if Config is non-nil.
@@ -384,6 +497,37 @@ func (c *Config) VMMetricsReceiverEnabled() bool {
 	return c.Receivers != nil && c.Receivers.VMMetrics != nil
 }
 
+// StatsDReceiverEnabled returns true if Config is non-nil
+// and if the StatsD receiver configuration is also non-nil.
+func (c *Config) StatsDReceiverEnabled() bool {
+	if c == nil {
+		return false
+	}
+	return c.Receivers != nil && c.Receivers.StatsD != nil
+}
+
+// StatsDConfig is a helper to safely retrieve the StatsD receiver
+// configuration with the defaults applied.
+func (c *Config) StatsDConfig() *StatsDReceiverConfig {
+	cfg := &StatsDReceiverConfig{}
+	if c != nil && c.Receivers != nil && c.Receivers.StatsD != nil {
+		*cfg = *c.Receivers.StatsD
+	}
+	if cfg.Address == "" {
+		cfg.Address = statsdreceiver.DefaultAddress
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = statsdreceiver.DefaultFlushInterval
+	}
+	return cfg
+}
+
+// BatchingEnabled returns true if Config is non-nil
+// and if the batching configuration is also non-nil.
+func (c *Config) BatchingEnabled() bool {
+	return c != nil && c.Batching != nil
+}
+
 // CheckLogicalConflicts serves to catch logical errors such as
 // if the Zipkin receiver port conflicts with that of the exporter,
 // lest we'll have a self DOS because spans will be exported "out" from
@@ -453,6 +597,17 @@ func eqLocalHost(host string) bool {
 //  + prometheus
 //  + aws-xray
 //  + honeycomb
+//  + file
+//  + influxdb
+//  + loki
+//  + tempo
+//  + elasticsearch
+//  + splunk
+//  + newrelic
+//  + appdynamics
+//  + dynatrace
+//  + otlp
+//  + nats
 func ExportersFromViperConfig(logger *zap.Logger, v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
 	parseFns := []struct {
 		name string
@@ -463,11 +618,22 @@ func ExportersFromViperConfig(logger *zap.Logger, v *viper.Viper) ([]consumer.Tr
 		{name: "stackdriver", fn: stackdriverexporter.StackdriverTraceExportersFromViper},
 		{name: "zipkin", fn: zipkinexporter.ZipkinExportersFromViper},
 		{name: "jaeger", fn: jaegerexporter.JaegerExportersFromViper},
-		{name: "kafka", fn: kafkaexporter.KafkaExportersFromViper},
+		{name: "kafka", fn: withLogger(logger, kafkaexporter.KafkaExportersFromViperWithLogger)},
 		{name: "opencensus", fn: opencensusexporter.OpenCensusTraceExportersFromViper},
-		{name: "prometheus", fn: prometheusexporter.PrometheusExportersFromViper},
-		{name: "aws-xray", fn: awsexporter.AWSXRayTraceExportersFromViper},
+		{name: "prometheus", fn: withLogger(logger, prometheusexporter.PrometheusExportersFromViperWithLogger)},
+		{name: "aws-xray", fn: withLogger(logger, awsexporter.AWSXRayTraceExportersFromViperWithLogger)},
 		{name: "honeycomb", fn: honeycombexporter.HoneycombTraceExportersFromViper},
+		{name: "file", fn: fileexporter.FileExportersFromViper},
+		{name: "influxdb", fn: withLogger(logger, influxdbexporter.InfluxDBExportersFromViperWithLogger)},
+		{name: "loki", fn: lokiexporter.LokiExportersFromViper},
+		{name: "tempo", fn: tempoexporter.TempoExportersFromViper},
+		{name: "elasticsearch", fn: elasticsearchexporter.ElasticsearchExportersFromViper},
+		{name: "splunk", fn: splunkexporter.SplunkExportersFromViper},
+		{name: "newrelic", fn: withLogger(logger, newrelicexporter.NewRelicExportersFromViperWithLogger)},
+		{name: "appdynamics", fn: appdynamicsexporter.AppDynamicsExportersFromViper},
+		{name: "dynatrace", fn: dynatraceexporter.DynatraceExportersFromViper},
+		{name: "otlp", fn: otlp.OTLPExportersFromViper},
+		{name: "nats", fn: natsexporter.NATSExportersFromViper},
 	}
 
 	var traceExporters []consumer.TraceConsumer
@@ -506,3 +672,14 @@ func ExportersFromViperConfig(logger *zap.Logger, v *viper.Viper) ([]consumer.Tr
 	}
 	return traceExporters, metricsExporters, doneFns, nil
 }
+
+// withLogger binds logger to the FromViper function of an exporter logging the
+// errors reported after the spans were pushed.
+func withLogger(
+	logger *zap.Logger,
+	fn func(*viper.Viper, *zap.Logger) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error),
+) func(*viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+	return func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error) {
+		return fn(v, logger)
+	}
+}
@@ -14,6 +14,17 @@
 
 package config
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
 // TLSCredentials holds the fields for TLS credentials
 // that are used for starting a server.
 type TLSCredentials struct {
@@ -22,6 +33,15 @@ type TLSCredentials struct {
 
 	// KeyFile is the file path containing the TLS key.
 	KeyFile string `mapstructure:"key_file"`
+
+	// CAFile is the file path containing the PEM certificates of the CAs
+	// the client certificates are verified against.
+	CAFile string `mapstructure:"ca_file"`
+
+	// ClientAuth is the policy for the client certificates, one of "none",
+	// "request", "require", "verify_if_given" and "require_and_verify". It
+	// defaults to "require_and_verify" if CAFile is set and "none" otherwise.
+	ClientAuth string `mapstructure:"client_auth"`
 }
 
 // nonEmpty returns true if the TLSCredentials are non-nil and
@@ -29,3 +49,122 @@ type TLSCredentials struct {
 func (tc *TLSCredentials) nonEmpty() bool {
 	return tc != nil && (tc.CertFile != "" || tc.KeyFile != "")
 }
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// ServerTLSConfig returns the tls.Config of a server using these credentials.
+// The certificate and key files are watched and reloaded when they change, so
+// that the certificate can be rotated without restarting the server. The
+// returned CertificateReloader must be closed to stop watching them.
+func (tc *TLSCredentials) ServerTLSConfig(logger *zap.Logger) (*tls.Config, *CertificateReloader, error) {
+	clientAuth := tls.NoClientCert
+	if tc.CAFile != "" {
+		clientAuth = tls.RequireAndVerifyClientCert
+	}
+	if tc.ClientAuth != "" {
+		var ok bool
+		if clientAuth, ok = clientAuthTypes[tc.ClientAuth]; !ok {
+			return nil, nil, fmt.Errorf("unknown client_auth %q", tc.ClientAuth)
+		}
+	}
+
+	var clientCAs *x509.CertPool
+	if tc.CAFile != "" {
+		pem, err := ioutil.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no CA certificate found in %q", tc.CAFile)
+		}
+	} else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+		return nil, nil, errors.New("verifying the client certificates requires a ca_file")
+	}
+
+	reloader, err := NewCertificateReloader(tc.CertFile, tc.KeyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		ClientAuth:     clientAuth,
+		ClientCAs:      clientCAs,
+	}, reloader, nil
+}
+
+// CertificateReloader holds a TLS certificate loaded from files and reloads it
+// every time one of the files changes.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	watchers []*Watcher
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertificateReloader loads the certificate from certFile and keyFile and
+// starts watching them. A certificate failing to load, e.g. because only one
+// of the files was replaced yet, is logged and the previous one is kept until
+// the files are consistent again.
+func NewCertificateReloader(certFile, keyFile string, logger *zap.Logger) (*CertificateReloader, error) {
+	cr := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+
+	paths := []string{certFile}
+	if keyFile != certFile {
+		paths = append(paths, keyFile)
+	}
+	for _, path := range paths {
+		w, err := NewWatcher(path, logger, cr.reload)
+		if err != nil {
+			cr.Close()
+			return nil, err
+		}
+		cr.watchers = append(cr.watchers, w)
+	}
+	return cr, nil
+}
+
+func (cr *CertificateReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, it is meant to be used as
+// tls.Config.GetCertificate.
+func (cr *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// Close stops watching the certificate files, the current certificate is still
+// returned by GetCertificate.
+func (cr *CertificateReloader) Close() error {
+	var err error
+	for _, w := range cr.watchers {
+		if werr := w.Close(); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
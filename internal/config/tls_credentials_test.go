@@ -15,10 +15,29 @@
 package config
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
 )
@@ -126,3 +145,154 @@ receivers:
 		}
 	}
 }
+
+// writeSelfSignedCert writes a self-signed certificate for localhost, and its
+// key, to certFile and keyFile, and returns the certificate.
+func writeSelfSignedCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create the certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal the key: %v", err)
+	}
+	// The key is written first, so that the certificate written last is
+	// the one matching it when both are reloaded.
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write the key: %v", err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatalf("Failed to write the certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func TestServerTLSConfigReloadsCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	firstCert := writeSelfSignedCert(t, certFile, keyFile, 1)
+
+	tlsCreds := &TLSCredentials{CertFile: certFile, KeyFile: keyFile}
+	tlsConfig, reloader, err := tlsCreds.ServerTLSConfig(zap.NewNop())
+	if err != nil {
+		t.Fatalf("ServerTLSConfig() error = %v", err)
+	}
+	defer reloader.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	// healthCheck calls the server trusting only the given certificate.
+	healthCheck := func(trusted *x509.Certificate) error {
+		roots := x509.NewCertPool()
+		roots.AddCert(trusted)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cc, err := grpc.DialContext(ctx, ln.Addr().String(),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "localhost"})))
+		if err != nil {
+			return err
+		}
+		defer cc.Close()
+		_, err = healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+	// servedSerial returns the serial number of the certificate presented by
+	// the server to a new connection.
+	servedSerial := func() int64 {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if err := healthCheck(firstCert); err != nil {
+		t.Fatalf("Health check over TLS failed: %v", err)
+	}
+
+	secondCert := writeSelfSignedCert(t, certFile, keyFile, 2)
+	for deadline := time.Now().Add(5 * time.Second); servedSerial() != 2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("The rotated certificate was not picked up")
+		}
+	}
+	if err := healthCheck(secondCert); err != nil {
+		t.Errorf("Health check with the rotated certificate failed: %v", err)
+	}
+}
+
+func TestServerTLSConfigClientAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	writeSelfSignedCert(t, certFile, keyFile, 1)
+
+	tests := []struct {
+		name           string
+		caFile         string
+		clientAuth     string
+		wantErr        bool
+		wantClientAuth tls.ClientAuthType
+	}{
+		{name: "default", wantClientAuth: tls.NoClientCert},
+		{name: "default_with_ca", caFile: certFile, wantClientAuth: tls.RequireAndVerifyClientCert},
+		{name: "request", clientAuth: "request", wantClientAuth: tls.RequestClientCert},
+		{name: "verify_if_given", caFile: certFile, clientAuth: "verify_if_given", wantClientAuth: tls.VerifyClientCertIfGiven},
+		{name: "verify_without_ca", clientAuth: "require_and_verify", wantErr: true},
+		{name: "unknown", clientAuth: "always", wantErr: true},
+		{name: "invalid_ca", caFile: keyFile, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsCreds := &TLSCredentials{CertFile: certFile, KeyFile: keyFile, CAFile: tt.caFile, ClientAuth: tt.clientAuth}
+			tlsConfig, reloader, err := tlsCreds.ServerTLSConfig(zap.NewNop())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ServerTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer reloader.Close()
+			if tlsConfig.ClientAuth != tt.wantClientAuth {
+				t.Errorf("ClientAuth = %v, want %v", tlsConfig.ClientAuth, tt.wantClientAuth)
+			}
+			if (tlsConfig.ClientCAs != nil) != (tt.caFile != "") {
+				t.Errorf("ClientCAs = %v, want them set only with a ca_file", tlsConfig.ClientCAs)
+			}
+		})
+	}
+}
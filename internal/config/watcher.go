@@ -0,0 +1,115 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// Watcher calls a function every time a configuration file changes, so that
+// the configuration can be reloaded without restarting the process.
+type Watcher struct {
+	path     string
+	onChange func() error
+	logger   *zap.Logger
+
+	watcher   *fsnotify.Watcher
+	realPath  string
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewWatcher starts watching the file at path, onChange is called after every
+// change of its content. An error returned by onChange, e.g. because the new
+// configuration is invalid, is logged and the watcher keeps going so that the
+// file can be fixed.
+//
+// The directory of the file is watched rather than the file itself, so that
+// the changes made by replacing the file, as editors and Kubernetes ConfigMap
+// volumes do, are seen too.
+func NewWatcher(path string, logger *zap.Logger, onChange func() error) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path = filepath.Clean(path)
+	if err := fw.Add(filepath.Dir(path)); err != nil {
+		fw.Close()
+		return nil, err
+	}
+
+	w := &Watcher{
+		path:     path,
+		onChange: onChange,
+		logger:   logger,
+		watcher:  fw,
+		done:     make(chan struct{}),
+	}
+	w.realPath, _ = filepath.EvalSymlinks(path)
+	go w.watch()
+	return w, nil
+}
+
+func (w *Watcher) watch() {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.changed(event) {
+				w.logger.Info("Configuration file changed, reloading it", zap.String("path", w.path))
+				if err := w.onChange(); err != nil {
+					w.logger.Error("Failed to reload the configuration, keeping the current one", zap.Error(err))
+				}
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Warn("Error watching the configuration file", zap.String("path", w.path), zap.Error(err))
+		}
+	}
+}
+
+// changed reports whether the event changed the content of the file, either
+// directly or by changing the file a symbolic link points to.
+func (w *Watcher) changed(event fsnotify.Event) bool {
+	realPath, err := filepath.EvalSymlinks(w.path)
+	if err != nil {
+		// The file is being replaced, it will be reloaded once it is created.
+		return false
+	}
+	if realPath != w.realPath {
+		w.realPath = realPath
+		return true
+	}
+	return filepath.Clean(event.Name) == w.path && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+}
+
+// Close stops watching the file, it waits for a call to onChange in progress.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		err = w.watcher.Close()
+		<-w.done
+	})
+	return err
+}
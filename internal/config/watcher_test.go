@@ -0,0 +1,155 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/config"
+	"github.com/census-instrumentation/opencensus-service/processor/swapprocessor"
+)
+
+func TestWatcherSwapsExporters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig := func(exporter string) {
+		content := []byte(fmt.Sprintf("exporter: %s\n", exporter))
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to write the configuration: %v", err)
+		}
+	}
+	writeConfig("first")
+
+	exporters := map[string]*exportertest.SinkTraceExporter{
+		"first":  {},
+		"second": {},
+	}
+	sp, _ := swapprocessor.NewTraceProcessor(exporters["first"])
+
+	reloaded := make(chan error, 10)
+	onChange := func() error {
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			reloaded <- err
+			return err
+		}
+		exporter, ok := exporters[v.GetString("exporter")]
+		if !ok {
+			err := fmt.Errorf("unknown exporter %q", v.GetString("exporter"))
+			reloaded <- err
+			return err
+		}
+		_, err := sp.Swap(exporter, time.Second)
+		reloaded <- err
+		return err
+	}
+
+	w, err := config.NewWatcher(path, zap.NewNop(), onChange)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	defer w.Close()
+
+	// waitForReload returns the error of the last reload once the change of
+	// the file was processed, a file change can trigger multiple reloads.
+	waitForReload := func() error {
+		var err error
+		select {
+		case err = <-reloaded:
+		case <-time.After(5 * time.Second):
+			t.Fatal("The configuration was not reloaded")
+		}
+		for {
+			select {
+			case err = <-reloaded:
+			case <-time.After(100 * time.Millisecond):
+				return err
+			}
+		}
+	}
+	send := func(name string) {
+		sp.ConsumeTraceData(context.Background(), data.TraceData{
+			Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: name}}},
+		})
+	}
+
+	send("before")
+
+	// An invalid configuration keeps the current exporter.
+	writeConfig("unknown")
+	if err := waitForReload(); err == nil {
+		t.Fatal("Reloading an unknown exporter should fail")
+	}
+	send("invalid")
+
+	writeConfig("second")
+	if err := waitForReload(); err != nil {
+		t.Fatalf("Reload error = %v", err)
+	}
+	send("after")
+
+	if got := exporters["first"].AllTraces(); len(got) != 2 {
+		t.Errorf("The first exporter got %d batches, want 2", len(got))
+	}
+	got := exporters["second"].AllTraces()
+	if len(got) != 1 || got[0].Spans[0].Name.Value != "after" {
+		t.Errorf("The second exporter got %v, want the spans sent right after the change", got)
+	}
+}
+
+func TestWatcherClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watcher")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	ioutil.WriteFile(path, []byte("exporter: first\n"), 0644)
+
+	w, err := config.NewWatcher(path, zap.NewNop(), func() error {
+		t.Error("onChange called after Close")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	// Closing twice is fine.
+	w.Close()
+
+	ioutil.WriteFile(path, []byte("exporter: second\n"), 0644)
+	time.Sleep(100 * time.Millisecond)
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
 	"github.com/census-instrumentation/opencensus-service/internal/configmodels"
 	"github.com/census-instrumentation/opencensus-service/internal/factories"
@@ -43,6 +44,7 @@ const (
 	errDuplicateExporterName
 	errDuplicateProcessorName
 	errDuplicatePipelineName
+	errMissingExporters
 	errMissingPipelines
 	errPipelineMustHaveReceiver
 	errPipelineMustHaveExporter
@@ -83,30 +85,36 @@ const typeAndNameSeparator = "/"
 
 // Load loads a ConfigV2 from Viper.
 func Load(v *viper.Viper) (*configmodels.ConfigV2, error) {
+	return load(v, nil)
+}
+
+// load loads a ConfigV2 from Viper, logging the unknown settings if logger is
+// not nil.
+func load(v *viper.Viper, logger *zap.Logger) (*configmodels.ConfigV2, error) {
 
 	var config configmodels.ConfigV2
 
 	// Load the config.
 
-	receivers, err := loadReceivers(v)
+	receivers, err := loadReceivers(v, logger)
 	if err != nil {
 		return nil, err
 	}
 	config.Receivers = receivers
 
-	exporters, err := loadExporters(v)
+	exporters, err := loadExporters(v, logger)
 	if err != nil {
 		return nil, err
 	}
 	config.Exporters = exporters
 
-	processors, err := loadProcessors(v)
+	processors, err := loadProcessors(v, logger)
 	if err != nil {
 		return nil, err
 	}
 	config.Processors = processors
 
-	pipelines, err := loadPipelines(v)
+	pipelines, err := loadPipelines(v, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +167,7 @@ func decodeTypeAndName(key string) (typeStr, fullName string, err error) {
 	return
 }
 
-func loadReceivers(v *viper.Viper) (configmodels.Receivers, error) {
+func loadReceivers(v *viper.Viper, logger *zap.Logger) (configmodels.Receivers, error) {
 	// Get the list of all "receivers" sub vipers from config source.
 	subViper := v.Sub(receiversKeyName)
 
@@ -200,7 +208,7 @@ func loadReceivers(v *viper.Viper) (configmodels.Receivers, error) {
 			err = customUnmarshaler(subViper, key, receiverCfg)
 		} else {
 			// Standard viper unmarshaler is fine.
-			err = subViper.UnmarshalKey(key, receiverCfg)
+			err = unmarshalKey(subViper, receiversKeyName, key, receiverCfg, logger)
 		}
 
 		if err != nil {
@@ -223,7 +231,7 @@ func loadReceivers(v *viper.Viper) (configmodels.Receivers, error) {
 	return receivers, nil
 }
 
-func loadExporters(v *viper.Viper) (configmodels.Exporters, error) {
+func loadExporters(v *viper.Viper, logger *zap.Logger) (configmodels.Exporters, error) {
 	// Get the list of all "exporters" sub vipers from config source.
 	subViper := v.Sub(exportersKeyName)
 
@@ -260,7 +268,7 @@ func loadExporters(v *viper.Viper) (configmodels.Exporters, error) {
 
 		// Now that the default config struct is created we can Unmarshal into it
 		// and it will apply user-defined config on top of the default.
-		if err := subViper.UnmarshalKey(key, exporterCfg); err != nil {
+		if err := unmarshalKey(subViper, exportersKeyName, key, exporterCfg, logger); err != nil {
 			return nil, &configError{
 				code: errUnmarshalError,
 				msg:  fmt.Sprintf("error reading settings for exporter type %q: %v", typeStr, err),
@@ -280,7 +288,7 @@ func loadExporters(v *viper.Viper) (configmodels.Exporters, error) {
 	return exporters, nil
 }
 
-func loadProcessors(v *viper.Viper) (configmodels.Processors, error) {
+func loadProcessors(v *viper.Viper, logger *zap.Logger) (configmodels.Processors, error) {
 	// Get the list of all "processors" sub vipers from config source.
 	subViper := v.Sub(processorsKeyName)
 
@@ -316,7 +324,7 @@ func loadProcessors(v *viper.Viper) (configmodels.Processors, error) {
 
 		// Now that the default config struct is created we can Unmarshal into it
 		// and it will apply user-defined config on top of the default.
-		if err := subViper.UnmarshalKey(key, processorCfg); err != nil {
+		if err := unmarshalKey(subViper, processorsKeyName, key, processorCfg, logger); err != nil {
 			return nil, &configError{
 				code: errUnmarshalError,
 				msg:  fmt.Sprintf("error reading settings for processor type %q: %v", typeStr, err),
@@ -336,7 +344,7 @@ func loadProcessors(v *viper.Viper) (configmodels.Processors, error) {
 	return processors, nil
 }
 
-func loadPipelines(v *viper.Viper) (configmodels.Pipelines, error) {
+func loadPipelines(v *viper.Viper, logger *zap.Logger) (configmodels.Pipelines, error) {
 	// Get the list of all "pipelines" sub vipers from config source.
 	subViper := v.Sub(pipelinesKeyName)
 
@@ -375,7 +383,7 @@ func loadPipelines(v *viper.Viper) (configmodels.Pipelines, error) {
 
 		// Now that the default config struct is created we can Unmarshal into it
 		// and it will apply user-defined config on top of the default.
-		if err := subViper.UnmarshalKey(key, &pipelineCfg); err != nil {
+		if err := unmarshalKey(subViper, pipelinesKeyName, key, &pipelineCfg, logger); err != nil {
 			return nil, &configError{
 				code: errUnmarshalError,
 				msg:  fmt.Sprintf("error reading settings for pipeline type %q: %v", typeStr, err),
@@ -402,6 +410,11 @@ func validateConfig(cfg *configmodels.ConfigV2) error {
 	// invalid cases that we currently don't check for but which we may want to add in
 	// the future (e.g. disallowing receiving and exporting on the same endpoint).
 
+	// Must have at least one exporter, data would otherwise be received for nothing.
+	if len(cfg.Exporters) < 1 {
+		return &configError{code: errMissingExporters, msg: "must have at least one exporter"}
+	}
+
 	if err := validatePipelines(cfg); err != nil {
 		return err
 	}
@@ -155,7 +155,7 @@ func TestDecodeConfig_Invalid(t *testing.T) {
 		{name: "empty-config"},
 		{name: "missing-all-sections"},
 		{name: "missing-receivers"},
-		{name: "missing-exporters"},
+		{name: "missing-exporters", expected: errMissingExporters},
 		{name: "missing-processors"},
 		{name: "invalid-receiver-name"},
 		{name: "invalid-receiver-reference", expected: errPipelineReceiverNotExists},
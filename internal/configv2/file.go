@@ -0,0 +1,104 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configv2
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/internal/configmodels"
+)
+
+// envVarRegexp matches the ${VAR} references to environment variables. The
+// $VAR form is not expanded, so that values like regular expressions or
+// replacement patterns can contain a $ without being escaped.
+var envVarRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadFromFile loads a ConfigV2 from the given YAML or TOML file, see
+// ReadFile. The settings that do not match any field of the configuration of
+// their component are ignored, a warning is logged for each of them.
+func LoadFromFile(path string, logger *zap.Logger) (*configmodels.ConfigV2, error) {
+	v := viper.New()
+	if err := ReadFile(v, path); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return load(v, logger)
+}
+
+// ReadFile reads the given file into v. Its format is given by its extension:
+// .yaml or .yml for YAML and .toml for TOML. The ${VAR} references in the
+// file are replaced by the value of the environment variable VAR, by an empty
+// string if it is not set.
+func ReadFile(v *viper.Viper, path string) error {
+	var configType string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		configType = "yaml"
+	case ".toml":
+		configType = "toml"
+	default:
+		return fmt.Errorf("unsupported configuration file format %q, it must be .yaml, .yml or .toml", path)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content = envVarRegexp.ReplaceAllFunc(content, func(ref []byte) []byte {
+		return []byte(os.Getenv(string(ref[2 : len(ref)-1])))
+	})
+
+	v.SetConfigType(configType)
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("error reading configuration file %q: %v", path, err)
+	}
+	return nil
+}
+
+// unmarshalKey unmarshals the settings of the given key, of the given section,
+// into cfg. If logger is not nil the settings that do not match any field of
+// cfg are logged.
+func unmarshalKey(v *viper.Viper, section, key string, cfg interface{}, logger *zap.Logger) error {
+	if logger == nil {
+		return v.UnmarshalKey(key, cfg)
+	}
+
+	var md mapstructure.Metadata
+	err := v.UnmarshalKey(key, cfg, func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = &md
+	})
+	if err != nil {
+		return err
+	}
+	for _, setting := range md.Unused {
+		logger.Warn("Ignoring unknown configuration setting",
+			zap.String("section", section),
+			zap.String("component", key),
+			zap.String("setting", setting))
+	}
+	return nil
+}
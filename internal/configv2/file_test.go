@@ -0,0 +1,115 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configv2
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/census-instrumentation/opencensus-service/internal/configmodels"
+)
+
+func TestLoadFromFile_Formats(t *testing.T) {
+	want, err := LoadConfigFile(t, path.Join(".", "testdata", "valid-config.yaml"))
+	if err != nil {
+		t.Fatalf("unable to load config, %v", err)
+	}
+
+	for _, name := range []string{"valid-config.yaml", "valid-config.toml"} {
+		config, err := LoadFromFile(path.Join(".", "testdata", name), nil)
+		if err != nil {
+			t.Fatalf("%s: unable to load config, %v", name, err)
+		}
+		assert.Equal(t, want, config, "Did not load %s correctly", name)
+	}
+}
+
+func TestLoadFromFile_Invalid(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		expected configErrorCode // expected config error, if 0 any error is acceptable
+	}{
+		{name: "missing-exporters.yaml", expected: errMissingExporters},
+		{name: "pipeline-must-have-receiver.yaml", expected: errPipelineMustHaveReceiver},
+		{name: "does-not-exist.yaml"},
+		{name: "valid-config.json"},
+	}
+
+	for _, test := range testCases {
+		_, err := LoadFromFile(path.Join(".", "testdata", test.name), nil)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if test.expected == 0 {
+			continue
+		}
+		if cfgErr, ok := err.(*configError); !ok || cfgErr.code != test.expected {
+			t.Errorf("%s: expected config error code %v but got '%v'", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestLoadFromFile_EnvVars(t *testing.T) {
+	os.Setenv("CONFIGV2_TEST_HOST", "example.com")
+	os.Setenv("CONFIGV2_TEST_PORT", "4321")
+	os.Unsetenv("CONFIGV2_TEST_UNSET")
+	defer os.Unsetenv("CONFIGV2_TEST_HOST")
+	defer os.Unsetenv("CONFIGV2_TEST_PORT")
+
+	config, err := LoadFromFile(path.Join(".", "testdata", "env-config.yaml"), nil)
+	if err != nil {
+		t.Fatalf("unable to load config, %v", err)
+	}
+
+	assert.Equal(t, config.Receivers["examplereceiver"],
+		&ExampleReceiver{
+			ReceiverSettings: configmodels.ReceiverSettings{
+				Endpoint: "example.com:4321",
+			},
+			ExtraSetting: "",
+		}, "Did not replace the environment variables")
+
+	assert.Equal(t, "$CONFIGV2_TEST_HOST $1",
+		config.Exporters["exampleexporter"].(*ExampleExporter).ExtraSetting,
+		"Only the ${VAR} references should be replaced")
+}
+
+func TestLoadFromFile_UnknownSetting(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+
+	config, err := LoadFromFile(path.Join(".", "testdata", "unknown-setting.yaml"), zap.New(core))
+	if err != nil {
+		t.Fatalf("unknown settings should not fail the load, got %v", err)
+	}
+	assert.Equal(t, "some export string",
+		config.Exporters["exampleexporter"].(*ExampleExporter).ExtraSetting,
+		"Did not load exporter config correctly")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected a single warning, got %v", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["component"] != "exampleexporter" || fields["setting"] != "unknown-setting" {
+		t.Errorf("unexpected warning fields %v", fields)
+	}
+}
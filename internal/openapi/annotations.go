@@ -0,0 +1,185 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The directives of the annotations, written in the comments of the HTTP
+// handlers as "openapi:<directive> <arguments>" lines. An operation starts
+// with an operation directive, the directives following it in the same
+// comment describe it:
+//
+//	openapi:operation POST /v1/traces exportTraces
+//	openapi:summary Exports spans in the OTLP format.
+//	openapi:tag otlp
+//	openapi:request application/json otlp.ExportRequest
+//	openapi:response 200 application/json object
+//	openapi:response 400 text/plain text
+//	openapi:security apiKey
+//
+// The schemas are the names of the Builder Types, or one of the builtin
+// binary, text, object and array schemas.
+const (
+	directivePrefix = "openapi:"
+
+	directiveOperation = "operation"
+	directiveSummary   = "summary"
+	directiveTag       = "tag"
+	directiveRequest   = "request"
+	directiveResponse  = "response"
+	directiveSecurity  = "security"
+)
+
+// Operation is an HTTP operation described by the annotations.
+type Operation struct {
+	Method    string
+	Path      string
+	ID        string
+	Summary   string
+	Tags      []string
+	Requests  []Content
+	Responses []Response
+	Security  []string
+	// Pos is the position of the operation directive, for the errors.
+	Pos token.Position
+}
+
+// Content is a body of the given media type.
+type Content struct {
+	MediaType string
+	Schema    string
+}
+
+// Response is a response of the given HTTP status code.
+type Response struct {
+	Status int
+	Content
+}
+
+// ParseDir returns the operations annotated in the comments of the Go files,
+// tests excluded, of dir.
+func ParseDir(dir string) ([]*Operation, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files are sorted for the operations to be in a stable order.
+	var files []*ast.File
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return fset.Position(files[i].Pos()).Filename < fset.Position(files[j].Pos()).Filename
+	})
+
+	var ops []*Operation
+	for _, f := range files {
+		for _, cg := range f.Comments {
+			cgOps, err := parseComment(fset, cg)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, cgOps...)
+		}
+	}
+	return ops, nil
+}
+
+func parseComment(fset *token.FileSet, cg *ast.CommentGroup) ([]*Operation, error) {
+	var ops []*Operation
+	var op *Operation
+	for _, c := range cg.List {
+		line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+		pos := fset.Position(c.Pos())
+		directive, args := splitDirective(strings.TrimPrefix(line, directivePrefix))
+		if directive != directiveOperation && op == nil {
+			return nil, fmt.Errorf("%v: openapi:%s before any openapi:operation", pos, directive)
+		}
+
+		var err error
+		switch directive {
+		case directiveOperation:
+			if len(args) != 3 {
+				return nil, fmt.Errorf("%v: want openapi:operation <method> <path> <id>", pos)
+			}
+			op = &Operation{Method: strings.ToUpper(args[0]), Path: args[1], ID: args[2], Pos: pos}
+			ops = append(ops, op)
+		case directiveSummary:
+			op.Summary = strings.Join(args, " ")
+		case directiveTag:
+			op.Tags = append(op.Tags, args...)
+		case directiveRequest:
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%v: want openapi:request <media type> <schema>", pos)
+			}
+			op.Requests = append(op.Requests, Content{MediaType: args[0], Schema: args[1]})
+		case directiveResponse:
+			var resp Response
+			resp, err = parseResponse(args)
+			op.Responses = append(op.Responses, resp)
+		case directiveSecurity:
+			op.Security = append(op.Security, args...)
+		default:
+			err = fmt.Errorf("unknown directive openapi:%s", directive)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", pos, err)
+		}
+	}
+	return ops, nil
+}
+
+func splitDirective(s string) (string, []string) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+// parseResponse parses the arguments "<status> [<media type> <schema>]" of a
+// response directive, a response without body having no media type.
+func parseResponse(args []string) (Response, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return Response{}, fmt.Errorf("want openapi:response <status> [<media type> <schema>]")
+	}
+	status, err := strconv.Atoi(args[0])
+	if err != nil || status < 100 || status > 599 {
+		return Response{}, fmt.Errorf("invalid HTTP status %q", args[0])
+	}
+	resp := Response{Status: status}
+	if len(args) == 3 {
+		resp.MediaType, resp.Schema = args[1], args[2]
+	}
+	return resp, nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package openapi generates the OpenAPI 3.0 specification of the HTTP
+// endpoints of the receivers, from the annotations in the comments of their
+// handlers and the json tags of their request and response types.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Version is the version of the OpenAPI specification generated.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                                 `yaml:"openapi"`
+	Info       Info                                   `yaml:"info"`
+	Paths      map[string]map[string]*OperationObject `yaml:"paths"`
+	Components Components                             `yaml:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description,omitempty"`
+	Version     string `yaml:"version"`
+}
+
+// OperationObject describes an operation of a path.
+type OperationObject struct {
+	OperationID string                     `yaml:"operationId"`
+	Summary     string                     `yaml:"summary,omitempty"`
+	Tags        []string                   `yaml:"tags,omitempty"`
+	RequestBody *RequestBody               `yaml:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `yaml:"responses"`
+	Security    []map[string][]string      `yaml:"security,omitempty"`
+}
+
+// RequestBody describes the bodies accepted by an operation.
+type RequestBody struct {
+	Required bool                  `yaml:"required"`
+	Content  map[string]*MediaType `yaml:"content"`
+}
+
+// ResponseObject describes a response of an operation.
+type ResponseObject struct {
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body of a media type.
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Components holds the schemas referred to by the operations.
+type Components struct {
+	Schemas         map[string]*Schema         `yaml:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `yaml:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is an authentication scheme of the operations.
+type SecurityScheme struct {
+	Type        string `yaml:"type"`
+	Description string `yaml:"description,omitempty"`
+	Name        string `yaml:"name,omitempty"`
+	In          string `yaml:"in,omitempty"`
+	Scheme      string `yaml:"scheme,omitempty"`
+}
+
+// Builder builds the OpenAPI documents of the annotated operations.
+type Builder struct {
+	Info Info
+	// Types are the Go types named by the schemas of the annotations. The
+	// structs are described by the components of the same names.
+	Types map[string]reflect.Type
+	// Overrides are the schemas of the types whose JSON encoding isn't
+	// described by their json tags, e.g. the well-known protobuf types.
+	Overrides map[reflect.Type]*Schema
+	// SecuritySchemes are the schemes named by the security annotations.
+	SecuritySchemes map[string]*SecurityScheme
+}
+
+var httpMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// Build returns the document describing ops.
+func (b *Builder) Build(ops []*Operation) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    b.Info,
+		Paths:   make(map[string]map[string]*OperationObject),
+		Components: Components{
+			SecuritySchemes: b.SecuritySchemes,
+		},
+	}
+	sg := newSchemaGenerator(b.Types, b.Overrides)
+	ids := make(map[string]bool)
+
+	for _, op := range ops {
+		if !httpMethods[op.Method] {
+			return nil, fmt.Errorf("%v: unknown HTTP method %q", op.Pos, op.Method)
+		}
+		if !strings.HasPrefix(op.Path, "/") {
+			return nil, fmt.Errorf("%v: the path %q doesn't start with /", op.Pos, op.Path)
+		}
+		if ids[op.ID] {
+			return nil, fmt.Errorf("%v: duplicate operation ID %q", op.Pos, op.ID)
+		}
+		ids[op.ID] = true
+		item := doc.Paths[op.Path]
+		if item == nil {
+			item = make(map[string]*OperationObject)
+			doc.Paths[op.Path] = item
+		}
+		method := strings.ToLower(op.Method)
+		if item[method] != nil {
+			return nil, fmt.Errorf("%v: duplicate operation %s %s", op.Pos, op.Method, op.Path)
+		}
+
+		oo, err := b.operationObject(sg, op)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", op.Pos, err)
+		}
+		item[method] = oo
+	}
+
+	if len(sg.components) > 0 {
+		doc.Components.Schemas = sg.components
+	}
+	return doc, nil
+}
+
+func (b *Builder) operationObject(sg *schemaGenerator, op *Operation) (*OperationObject, error) {
+	oo := &OperationObject{
+		OperationID: op.ID,
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		Responses:   make(map[string]*ResponseObject),
+	}
+
+	for _, req := range op.Requests {
+		s, err := b.schema(sg, req.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if oo.RequestBody == nil {
+			oo.RequestBody = &RequestBody{Required: true, Content: make(map[string]*MediaType)}
+		}
+		oo.RequestBody.Content[req.MediaType] = &MediaType{Schema: s}
+	}
+
+	if len(op.Responses) == 0 {
+		return nil, fmt.Errorf("the operation %s has no response", op.ID)
+	}
+	for _, resp := range op.Responses {
+		code := strconv.Itoa(resp.Status)
+		ro := oo.Responses[code]
+		if ro == nil {
+			ro = &ResponseObject{Description: http.StatusText(resp.Status)}
+			if ro.Description == "" {
+				ro.Description = "Status " + code
+			}
+			oo.Responses[code] = ro
+		}
+		if resp.MediaType == "" {
+			continue
+		}
+		s, err := b.schema(sg, resp.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if ro.Content == nil {
+			ro.Content = make(map[string]*MediaType)
+		}
+		ro.Content[resp.MediaType] = &MediaType{Schema: s}
+	}
+
+	// Several security requirements are alternatives.
+	for _, name := range op.Security {
+		if b.SecuritySchemes[name] == nil {
+			return nil, fmt.Errorf("unknown security scheme %q", name)
+		}
+		oo.Security = append(oo.Security, map[string][]string{name: {}})
+	}
+	return oo, nil
+}
+
+func (b *Builder) schema(sg *schemaGenerator, name string) (*Schema, error) {
+	if s, ok := builtinSchemas[name]; ok {
+		return s, nil
+	}
+	t, ok := b.Types[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q", name)
+	}
+	return sg.schemaOf(t, false), nil
+}
+
+// YAML returns the YAML encoding of the document.
+func (d *Document) YAML() ([]byte, error) {
+	return yaml.Marshal(d)
+}
@@ -0,0 +1,287 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+type exportRequest struct {
+	Spans    []*span `json:"spans"`
+	Count    int64   `json:"count"`
+	internal string
+	Skipped  string `json:"-"`
+}
+
+type span struct {
+	Name       string                 `json:"name"`
+	ID         []byte                 `json:"id"`
+	Start      time.Time              `json:"start"`
+	Parent     *span                  `json:"parent,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Embedded
+}
+
+type Embedded struct {
+	Kind uint32 `json:"kind"`
+}
+
+type protoMessage struct {
+	Count            int64               `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Value            isProtoMessageValue `protobuf_oneof:"value"`
+	XXX_unrecognized []byte              `json:"-"`
+}
+
+type isProtoMessageValue interface {
+	isProtoMessageValue()
+}
+
+type protoMessageText struct {
+	Text string `protobuf:"bytes,2,opt,name=text,proto3,oneof" json:"text,omitempty"`
+}
+
+func (*protoMessageText) isProtoMessageValue() {}
+
+func (*protoMessage) XXX_OneofWrappers() []interface{} {
+	return []interface{}{(*protoMessageText)(nil)}
+}
+
+var testBuilder = &Builder{
+	Info: Info{Title: "Test API", Version: "1.0"},
+	Types: map[string]reflect.Type{
+		"test.ExportRequest": reflect.TypeOf(exportRequest{}),
+		"test.ProtoMessage":  reflect.TypeOf(protoMessage{}),
+	},
+	SecuritySchemes: map[string]*SecurityScheme{
+		"apiKey": {Type: "apiKey", In: "header", Name: "X-Api-Key"},
+	},
+}
+
+func TestGeneratedSpec(t *testing.T) {
+	ops, err := ParseDir("testdata/handlers")
+	if err != nil {
+		t.Fatalf("ParseDir() error = %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("ParseDir() returned %d operations, want 2", len(ops))
+	}
+	doc, err := testBuilder.Build(ops)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	out, err := doc.YAML()
+	if err != nil {
+		t.Fatalf("YAML() error = %v", err)
+	}
+
+	// The spec is checked as parsed back by a YAML decoder.
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal(out, &spec); err != nil {
+		t.Fatalf("The generated spec isn't valid YAML: %v\n%s", err, out)
+	}
+	wantValues := []struct {
+		path string
+		want interface{}
+	}{
+		{"openapi", Version},
+		{"info.title", "Test API"},
+		{"info.version", "1.0"},
+		{"paths./v1/traces.post.operationId", "exportTraces"},
+		{"paths./v1/traces.post.summary", "Exports spans."},
+		{"paths./v1/traces.post.tags", []interface{}{"traces"}},
+		{"paths./v1/traces.post.requestBody.required", true},
+		{"paths./v1/traces.post.requestBody.content.application/json.schema.$ref", "#/components/schemas/test.ExportRequest"},
+		{"paths./v1/traces.post.requestBody.content.application/x-protobuf.schema.format", "binary"},
+		{"paths./v1/traces.post.responses.200.description", "OK"},
+		{"paths./v1/traces.post.responses.200.content.application/json.schema.type", "object"},
+		{"paths./v1/traces.post.responses.200.content.application/x-protobuf.schema.format", "binary"},
+		{"paths./v1/traces.post.responses.400.content.text/plain.schema.type", "string"},
+		{"paths./v1/traces.post.security", []interface{}{map[interface{}]interface{}{"apiKey": []interface{}{}}}},
+		{"paths./v1/proto.post.responses.204.description", "No Content"},
+		{"components.securitySchemes.apiKey.type", "apiKey"},
+		{"components.securitySchemes.apiKey.in", "header"},
+		{"components.securitySchemes.apiKey.name", "X-Api-Key"},
+		{"components.schemas.test.ExportRequest.properties.spans.items.$ref", "#/components/schemas/openapi.span"},
+		{"components.schemas.test.ExportRequest.properties.count.type", "integer"},
+		{"components.schemas.openapi.span.properties.name.type", "string"},
+		{"components.schemas.openapi.span.properties.id.format", "byte"},
+		{"components.schemas.openapi.span.properties.start.format", "date-time"},
+		{"components.schemas.openapi.span.properties.parent.$ref", "#/components/schemas/openapi.span"},
+		{"components.schemas.openapi.span.properties.attributes.type", "object"},
+		{"components.schemas.openapi.span.properties.kind.format", "int64"},
+		{"components.schemas.test.ProtoMessage.properties.count.type", "string"},
+		{"components.schemas.test.ProtoMessage.properties.text.type", "string"},
+	}
+	for _, wv := range wantValues {
+		got, ok := lookup(spec, wv.path)
+		if !ok {
+			t.Errorf("%s is missing from the spec", wv.path)
+			continue
+		}
+		if !reflect.DeepEqual(got, wv.want) {
+			t.Errorf("%s = %#v, want %#v", wv.path, got, wv.want)
+		}
+	}
+	for _, path := range []string{
+		"paths./v1/proto.post.responses.204.content",
+		"components.schemas.test.ExportRequest.properties.internal",
+		"components.schemas.test.ExportRequest.properties.Skipped",
+		"components.schemas.test.ProtoMessage.properties.value",
+		"components.schemas.test.ProtoMessage.properties.XXX_unrecognized",
+	} {
+		if _, ok := lookup(spec, path); ok {
+			t.Errorf("%s is in the spec, want it missing", path)
+		}
+	}
+	checkRefs(t, spec, spec)
+}
+
+// lookup returns the value of the dotted path in the spec. As the component
+// and media type names contain dots and slashes, the keys are matched
+// greedily.
+func lookup(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		if sm, isSpec := v.(map[string]interface{}); isSpec {
+			m = make(map[interface{}]interface{}, len(sm))
+			for k, v := range sm {
+				m[k] = v
+			}
+		} else {
+			return nil, false
+		}
+	}
+	parts := strings.Split(path, ".")
+	for i := len(parts); i > 0; i-- {
+		child, ok := m[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+		if got, ok := lookup(child, strings.Join(parts[i:], ".")); ok {
+			return got, true
+		}
+	}
+	return nil, false
+}
+
+// checkRefs checks the $refs of v refer to schemas of the spec.
+func checkRefs(t *testing.T, spec map[string]interface{}, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			checkRefs(t, spec, child)
+		}
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			if k == "$ref" {
+				name := strings.TrimPrefix(child.(string), "#/components/schemas/")
+				if _, ok := lookup(spec, "components.schemas."+name); !ok {
+					t.Errorf("The schema %v is referred to but missing", child)
+				}
+				continue
+			}
+			checkRefs(t, spec, child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			checkRefs(t, spec, child)
+		}
+	}
+}
+
+func TestInvalidAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		wantErr string
+	}{
+		{
+			name:    "directive before operation",
+			comment: "// openapi:summary Orphan.",
+			wantErr: "before any openapi:operation",
+		},
+		{
+			name:    "incomplete operation",
+			comment: "// openapi:operation POST /v1/traces",
+			wantErr: "want openapi:operation",
+		},
+		{
+			name:    "unknown directive",
+			comment: "// openapi:operation POST /v1/traces id\n// openapi:deprecated",
+			wantErr: "unknown directive",
+		},
+		{
+			name:    "invalid status",
+			comment: "// openapi:operation POST /v1/traces id\n// openapi:response 2xx",
+			wantErr: "invalid HTTP status",
+		},
+		{
+			name:    "unknown schema",
+			comment: "// openapi:operation POST /v1/traces id\n// openapi:request application/json Unknown\n// openapi:response 200",
+			wantErr: `unknown schema "Unknown"`,
+		},
+		{
+			name:    "unknown security scheme",
+			comment: "// openapi:operation POST /v1/traces id\n// openapi:response 200\n// openapi:security basic",
+			wantErr: `unknown security scheme "basic"`,
+		},
+		{
+			name:    "no response",
+			comment: "// openapi:operation POST /v1/traces id",
+			wantErr: "has no response",
+		},
+		{
+			name:    "unknown method",
+			comment: "// openapi:operation SEND /v1/traces id\n// openapi:response 200",
+			wantErr: "unknown HTTP method",
+		},
+		{
+			name:    "duplicate operation",
+			comment: "// openapi:operation POST /v1/traces a\n// openapi:response 200\n// openapi:operation POST /v1/traces b\n// openapi:response 200",
+			wantErr: "duplicate operation POST /v1/traces",
+		},
+		{
+			name:    "duplicate ID",
+			comment: "// openapi:operation POST /v1/traces a\n// openapi:response 200\n// openapi:operation POST /v1/spans a\n// openapi:response 200",
+			wantErr: `duplicate operation ID "a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "handler.go", "package handler\n\n"+tt.comment+"\nfunc Handler() {}\n", parser.ParseComments)
+			if err != nil {
+				t.Fatalf("ParseFile() error = %v", err)
+			}
+			ops, err := parseComment(fset, f.Comments[0])
+			if err == nil {
+				_, err = testBuilder.Build(ops)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object, limited to what describes the Go types.
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty"`
+	Type                 string             `yaml:"type,omitempty"`
+	Format               string             `yaml:"format,omitempty"`
+	Description          string             `yaml:"description,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty"`
+	Items                *Schema            `yaml:"items,omitempty"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties,omitempty"`
+}
+
+// The builtin schemas, usable in the annotations along the Go types: raw
+// bytes, plain text, any object and array of objects.
+var builtinSchemas = map[string]*Schema{
+	"binary": {Type: "string", Format: "binary"},
+	"text":   {Type: "string"},
+	"object": {Type: "object"},
+	"array":  {Type: "array", Items: &Schema{Type: "object"}},
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+
+	versionRegexp = regexp.MustCompile(`^v[0-9]+$`)
+)
+
+// schemaGenerator generates the schemas of the Go types from their json
+// tags, the structs being added to the components.
+type schemaGenerator struct {
+	overrides  map[reflect.Type]*Schema
+	components map[string]*Schema
+	names      map[reflect.Type]string
+	// typeNames are the names of the types given to the Builder, which are
+	// the ones of their components.
+	typeNames map[reflect.Type]string
+}
+
+func newSchemaGenerator(types map[string]reflect.Type, overrides map[reflect.Type]*Schema) *schemaGenerator {
+	sg := &schemaGenerator{
+		overrides:  overrides,
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+		typeNames:  make(map[reflect.Type]string, len(types)),
+	}
+	for name, t := range types {
+		sg.typeNames[t] = name
+	}
+	return sg
+}
+
+// schemaOf returns the schema of t. isProto tells whether t is the type of a
+// field of a protobuf message, encoded by jsonpb rather than encoding/json.
+func (sg *schemaGenerator) schemaOf(t reflect.Type, isProto bool) *Schema {
+	if s, ok := sg.overrides[t]; ok {
+		return s
+	}
+	if t.Kind() == reflect.Ptr {
+		return sg.schemaOf(t.Elem(), isProto)
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		// Nothing is known of the custom encodings.
+		return &Schema{Description: "Custom JSON encoding of " + t.String() + "."}
+	}
+	if _, isEnum := reflect.PtrTo(t).MethodByName("EnumDescriptor"); isEnum && t.Kind() == reflect.Int32 {
+		// jsonpb encodes the enums with their names.
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		if isProto {
+			// jsonpb encodes the 64 bits integers as strings.
+			return &Schema{Type: "string", Format: "int64"}
+		}
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: sg.schemaOf(t.Elem(), isProto)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: sg.schemaOf(t.Elem(), isProto)}
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + sg.component(t)}
+	}
+	// Interfaces and alike can hold any value.
+	return &Schema{}
+}
+
+// component adds the schema of the struct t to the components if needed and
+// returns its name.
+func (sg *schemaGenerator) component(t reflect.Type) string {
+	if name, ok := sg.names[t]; ok {
+		return name
+	}
+	name := sg.componentName(t)
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	// The schema is registered before the fields are, for the recursive
+	// types to refer to it.
+	sg.names[t] = name
+	sg.components[name] = s
+	sg.addFields(s, t)
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return name
+}
+
+// componentName names the schema of t as the Builder types do, or after its
+// package and type names, the version elements of the package paths, e.g.
+// trace/v1, being skipped.
+func (sg *schemaGenerator) componentName(t reflect.Type) string {
+	if name, ok := sg.typeNames[t]; ok && sg.components[name] == nil {
+		return name
+	}
+	pkg := path.Base(t.PkgPath())
+	if versionRegexp.MatchString(pkg) {
+		pkg = path.Base(path.Dir(t.PkgPath()))
+	}
+	base := pkg + "." + t.Name()
+	if t.Name() == "" {
+		base = "Anonymous"
+	}
+	name := base
+	for i := 2; sg.components[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+func (sg *schemaGenerator) addFields(s *Schema, t reflect.Type) {
+	hasOneofs := false
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		if f.Tag.Get("protobuf_oneof") != "" {
+			if !hasOneofs {
+				sg.addOneofFields(s, t)
+				hasOneofs = true
+			}
+			continue
+		}
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		if name == "" && f.Anonymous {
+			// The fields of the embedded structs are inlined.
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				sg.addFields(s, ft)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = sg.schemaOf(f.Type, f.Tag.Get("protobuf") != "")
+	}
+}
+
+// addOneofFields adds the fields of all the oneofs of the protobuf message t,
+// which jsonpb encodes as fields of the message.
+func (sg *schemaGenerator) addOneofFields(s *Schema, t reflect.Type) {
+	m := reflect.New(t).MethodByName("XXX_OneofWrappers")
+	if !m.IsValid() {
+		return
+	}
+	wrappers, ok := m.Call(nil)[0].Interface().([]interface{})
+	if !ok {
+		return
+	}
+	for _, w := range wrappers {
+		wt := reflect.TypeOf(w)
+		if wt.Kind() == reflect.Ptr {
+			wt = wt.Elem()
+		}
+		if wt.Kind() == reflect.Struct && wt.NumField() == 1 {
+			sg.addFields(s, wt)
+		}
+	}
+}
+
+// jsonName returns the name of the JSON field of f, empty if it has none,
+// and false if it isn't encoded.
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag, true
+}
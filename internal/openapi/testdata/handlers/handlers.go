@@ -0,0 +1,40 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import "net/http"
+
+// ServeTraces serves the spans posted to /v1/traces.
+//
+// openapi:operation POST /v1/traces exportTraces
+// openapi:summary Exports spans.
+// openapi:tag traces
+// openapi:request application/json test.ExportRequest
+// openapi:request application/x-protobuf binary
+// openapi:response 200 application/json object
+// openapi:response 200 application/x-protobuf binary
+// openapi:response 400 text/plain text
+// openapi:security apiKey
+func ServeTraces(w http.ResponseWriter, r *http.Request) {}
+
+// ProtoHandler serves the protobuf messages posted to /v1/proto.
+type ProtoHandler struct{}
+
+func (ph *ProtoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// openapi:operation POST /v1/proto exportProto
+	// openapi:request application/json test.ProtoMessage
+	// openapi:response 204
+	// openapi:response 400 application/json test.ProtoMessage
+}
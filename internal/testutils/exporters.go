@@ -0,0 +1,153 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutils
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/spf13/viper"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/config/viperutils"
+)
+
+// HTTPRecorder is a http.Handler faking the server of an HTTP exporter: it
+// records the requests it receives and replies with Status, 200 if zero, and
+// Body. The replies with a non 2xx Status have ErrorBody instead, sent with
+// http.Error. If Reply is set, it replies instead. It is safe for concurrent
+// use.
+type HTTPRecorder struct {
+	Status    int
+	Body      string
+	ErrorBody string
+	Reply     http.HandlerFunc
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (hr *HTTPRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	hr.mu.Lock()
+	hr.requests = append(hr.requests, r)
+	hr.bodies = append(hr.bodies, string(body))
+	hr.mu.Unlock()
+
+	if hr.Reply != nil {
+		hr.Reply(w, r)
+		return
+	}
+	status := hr.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status/100 != 2 {
+		http.Error(w, hr.ErrorBody, status)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(hr.Body))
+}
+
+// Requests returns the requests received so far. Their bodies are consumed,
+// see Bodies.
+func (hr *HTTPRecorder) Requests() []*http.Request {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	return append([]*http.Request(nil), hr.requests...)
+}
+
+// Bodies returns the bodies of the requests received so far.
+func (hr *HTTPRecorder) Bodies() []string {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	return append([]string(nil), hr.bodies...)
+}
+
+// TraceDataWithSpans returns a TraceData of n client spans named "span",
+// lasting one second, sent by the frontend service of the host web-1 in the
+// staging k8s.namespace.
+func TraceDataWithSpans(n int) data.TraceData {
+	td := data.TraceData{
+		Node: &commonpb.Node{
+			Identifier:  &commonpb.ProcessIdentifier{HostName: "web-1"},
+			ServiceInfo: &commonpb.ServiceInfo{Name: "frontend"},
+		},
+		Resource: &resourcepb.Resource{Labels: map[string]string{"k8s.namespace": "staging"}},
+	}
+	for i := 0; i < n; i++ {
+		td.Spans = append(td.Spans, &tracepb.Span{
+			TraceId:   []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, byte(i)},
+			SpanId:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, byte(i >> 8), byte(i)},
+			Name:      &tracepb.TruncatableString{Value: "span"},
+			Kind:      tracepb.Span_CLIENT,
+			StartTime: &timestamp.Timestamp{Seconds: 1},
+			EndTime:   &timestamp.Timestamp{Seconds: 2},
+		})
+	}
+	return td
+}
+
+// CheckPushError checks a push of spans failed with the status and the
+// message of the server, dropping wantDropped spans.
+func CheckPushError(t *testing.T, dropped int, err error, wantDropped int, status, message string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), status) || !strings.Contains(err.Error(), message) {
+		t.Errorf("Got error %v, want the %s and the message %q of the server", err, status, message)
+	}
+	if dropped != wantDropped {
+		t.Errorf("Got %d dropped spans, want %d", dropped, wantDropped)
+	}
+}
+
+// ExportersFromViper is the signature of the XExportersFromViper functions of
+// the exporters.
+type ExportersFromViper func(v *viper.Viper) ([]consumer.TraceConsumer, []consumer.MetricsConsumer, []func() error, error)
+
+// InvalidConfig is a YAML configuration an exporter must refuse.
+type InvalidConfig struct {
+	Name   string
+	Config string
+}
+
+// CheckExportersFromViperErrors checks fromViper fails with each of the
+// invalid configurations, and creates no exporter without its configuration.
+func CheckExportersFromViperErrors(t *testing.T, fromViper ExportersFromViper, invalid []InvalidConfig) {
+	t.Helper()
+	for _, tt := range invalid {
+		t.Run(tt.Name, func(t *testing.T) {
+			v, _ := viperutils.ViperFromYAMLBytes([]byte(tt.Config))
+			if _, _, _, err := fromViper(v); err == nil {
+				t.Error("Got no error")
+			}
+		})
+	}
+
+	v, _ := viperutils.ViperFromYAMLBytes([]byte("file:\n  path: spans.jsonl\n"))
+	tps, _, _, err := fromViper(v)
+	if err != nil || len(tps) != 0 {
+		t.Errorf("Got %d exporters and error %v without their configuration, want none", len(tps), err)
+	}
+}
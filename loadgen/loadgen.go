@@ -0,0 +1,226 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadgen generates synthetic OpenCensus spans at a steady rate, to
+// load test the collector without instrumenting real services.
+package loadgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+const (
+	// minTickInterval bounds the frequency of the ticks; at the higher rates
+	// several spans are generated per tick.
+	minTickInterval = 10 * time.Millisecond
+	// spanDuration is the duration of the generated spans.
+	spanDuration = time.Millisecond
+)
+
+var (
+	errNilExporter   = errors.New("the exporter is nil")
+	errInvalidRate   = errors.New("the rate must be positive")
+	errInvalidCounts = errors.New("the attribute and annotation counts can't be negative")
+)
+
+// Config configures the spans generated and their rate.
+type Config struct {
+	// SpansPerSecond is the rate of the spans.
+	SpansPerSecond float64
+	// AttributesPerSpan is the number of string attributes of each span.
+	AttributesPerSpan int
+	// AnnotationsPerSpan is the number of annotations of each span.
+	AnnotationsPerSpan int
+	// TraceDepth is the number of spans of each trace, every span being the
+	// child of the previous one. It is 1 by default, i.e. only root spans.
+	TraceDepth int
+	// SpanName is the prefix of the span names, followed by the depth of the
+	// span in its trace. It is "loadgen" by default.
+	SpanName string
+}
+
+// Stats counts the spans of a Generator.
+type Stats struct {
+	// Generated is the number of spans passed to the exporter.
+	Generated uint64
+	// Dropped is the number of spans not generated on time, because the
+	// exporter didn't keep up with the rate.
+	Dropped uint64
+}
+
+// Generator sends synthetic spans to a trace.Exporter at the configured rate.
+type Generator struct {
+	cfg      Config
+	exporter trace.Exporter
+	rand     *rand.Rand
+
+	// The current trace, whose next span is a child of parentSpanID.
+	traceID      trace.TraceID
+	parentSpanID trace.SpanID
+	depth        int
+
+	generated uint64
+	dropped   uint64
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	done      chan struct{}
+}
+
+// NewGenerator creates a Generator of the spans of cfg, sent to exporter once
+// started.
+func NewGenerator(exporter trace.Exporter, cfg Config) (*Generator, error) {
+	if exporter == nil {
+		return nil, errNilExporter
+	}
+	if cfg.SpansPerSecond <= 0 || math.IsInf(cfg.SpansPerSecond, 0) || math.IsNaN(cfg.SpansPerSecond) {
+		return nil, errInvalidRate
+	}
+	if cfg.AttributesPerSpan < 0 || cfg.AnnotationsPerSpan < 0 {
+		return nil, errInvalidCounts
+	}
+	if cfg.TraceDepth <= 0 {
+		cfg.TraceDepth = 1
+	}
+	if cfg.SpanName == "" {
+		cfg.SpanName = "loadgen"
+	}
+	return &Generator{
+		cfg:      cfg,
+		exporter: exporter,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start starts generating the spans, until Stop is called.
+func (g *Generator) Start() {
+	g.startOnce.Do(func() {
+		go g.run()
+	})
+}
+
+// Stop stops generating the spans and waits for the span being exported, if
+// any. The Generator can't be started again.
+func (g *Generator) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stopCh)
+		g.startOnce.Do(func() {
+			// Never started, there is nothing to wait for.
+			close(g.done)
+		})
+		<-g.done
+	})
+}
+
+// Stats returns the counts of the spans so far.
+func (g *Generator) Stats() Stats {
+	return Stats{
+		Generated: atomic.LoadUint64(&g.generated),
+		Dropped:   atomic.LoadUint64(&g.dropped),
+	}
+}
+
+// run generates the spans due at every tick. The spans due are the ones of
+// the rate since the start, those that couldn't be generated on the previous
+// ticks are dropped rather than sent in a burst.
+func (g *Generator) run() {
+	defer close(g.done)
+
+	interval := time.Duration(float64(time.Second) / g.cfg.SpansPerSecond)
+	if interval < minTickInterval {
+		interval = minTickInterval
+	}
+	perTick := uint64(math.Ceil(g.cfg.SpansPerSecond * interval.Seconds()))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var handled uint64
+	for {
+		select {
+		case <-g.stopCh:
+			return
+		case now := <-ticker.C:
+			due := uint64(g.cfg.SpansPerSecond*now.Sub(start).Seconds()) - handled
+			if due > perTick {
+				atomic.AddUint64(&g.dropped, due-perTick)
+				handled += due - perTick
+				due = perTick
+			}
+			for i := uint64(0); i < due; i++ {
+				select {
+				case <-g.stopCh:
+					return
+				default:
+				}
+				g.exporter.ExportSpan(g.nextSpan(time.Now()))
+				atomic.AddUint64(&g.generated, 1)
+				handled++
+			}
+		}
+	}
+}
+
+// nextSpan returns the next span of the current trace, starting a new one
+// once its depth is reached. It ends at now.
+func (g *Generator) nextSpan(now time.Time) *trace.SpanData {
+	if g.depth == 0 {
+		g.rand.Read(g.traceID[:])
+		g.parentSpanID = trace.SpanID{}
+	}
+
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:      g.traceID,
+			TraceOptions: 1, // Sampled.
+		},
+		ParentSpanID: g.parentSpanID,
+		SpanKind:     trace.SpanKindServer,
+		Name:         fmt.Sprintf("%s-%d", g.cfg.SpanName, g.depth),
+		StartTime:    now.Add(-spanDuration),
+		EndTime:      now,
+		Status:       trace.Status{Code: trace.StatusCodeOK},
+	}
+	g.rand.Read(sd.SpanID[:])
+	if g.depth > 0 {
+		sd.SpanKind = trace.SpanKindClient
+	}
+	if g.cfg.AttributesPerSpan > 0 {
+		sd.Attributes = make(map[string]interface{}, g.cfg.AttributesPerSpan)
+		for i := 0; i < g.cfg.AttributesPerSpan; i++ {
+			sd.Attributes[fmt.Sprintf("loadgen.attribute.%d", i)] = fmt.Sprintf("value-%d", i)
+		}
+	}
+	for i := 0; i < g.cfg.AnnotationsPerSpan; i++ {
+		sd.Annotations = append(sd.Annotations, trace.Annotation{
+			Time:    sd.StartTime,
+			Message: fmt.Sprintf("annotation %d", i),
+		})
+	}
+
+	g.parentSpanID = sd.SpanID
+	g.depth = (g.depth + 1) % g.cfg.TraceDepth
+	return sd
+}
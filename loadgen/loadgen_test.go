@@ -0,0 +1,160 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestNewGenerator_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter trace.Exporter
+		cfg      Config
+		want     error
+	}{
+		{"nil exporter", nil, Config{SpansPerSecond: 1}, errNilExporter},
+		{"zero rate", new(exportertest.SpanDataExporter), Config{}, errInvalidRate},
+		{"negative rate", new(exportertest.SpanDataExporter), Config{SpansPerSecond: -1}, errInvalidRate},
+		{"negative attributes", new(exportertest.SpanDataExporter), Config{SpansPerSecond: 1, AttributesPerSpan: -1}, errInvalidCounts},
+		{"negative annotations", new(exportertest.SpanDataExporter), Config{SpansPerSecond: 1, AnnotationsPerSpan: -1}, errInvalidCounts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGenerator(tt.exporter, tt.cfg); err != tt.want {
+				t.Fatalf("NewGenerator() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_SpanShape(t *testing.T) {
+	g, err := NewGenerator(new(exportertest.SpanDataExporter), Config{
+		SpansPerSecond:     1,
+		AttributesPerSpan:  3,
+		AnnotationsPerSpan: 2,
+		TraceDepth:         3,
+	})
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	now := time.Now()
+	var spans []*trace.SpanData
+	for i := 0; i < 6; i++ {
+		spans = append(spans, g.nextSpan(now))
+	}
+
+	for i, sd := range spans {
+		if got, want := len(sd.Attributes), 3; got != want {
+			t.Errorf("span %d has %d attributes, want %d", i, got, want)
+		}
+		if got, want := len(sd.Annotations), 2; got != want {
+			t.Errorf("span %d has %d annotations, want %d", i, got, want)
+		}
+		if !sd.IsSampled() {
+			t.Errorf("span %d isn't sampled", i)
+		}
+		if !sd.EndTime.Equal(now) || !sd.StartTime.Before(now) {
+			t.Errorf("span %d times = [%v, %v], want ending at %v", i, sd.StartTime, sd.EndTime, now)
+		}
+
+		if i%3 == 0 {
+			if sd.ParentSpanID != (trace.SpanID{}) {
+				t.Errorf("span %d is a root span with a parent %v", i, sd.ParentSpanID)
+			}
+			if i > 0 && sd.TraceID == spans[i-1].TraceID {
+				t.Errorf("span %d didn't start a new trace", i)
+			}
+			continue
+		}
+		if sd.TraceID != spans[i-1].TraceID {
+			t.Errorf("span %d trace = %v, want %v", i, sd.TraceID, spans[i-1].TraceID)
+		}
+		if sd.ParentSpanID != spans[i-1].SpanID {
+			t.Errorf("span %d parent = %v, want %v", i, sd.ParentSpanID, spans[i-1].SpanID)
+		}
+	}
+}
+
+func TestGenerator_Rate(t *testing.T) {
+	sr := new(exportertest.SpanDataExporter)
+	g, err := NewGenerator(sr, Config{SpansPerSecond: 1000})
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	g.Start()
+	time.Sleep(300 * time.Millisecond)
+	g.Stop()
+
+	stats := g.Stats()
+	// Allow for slow test machines, the ticks aren't exact.
+	if stats.Generated < 100 || stats.Generated > 400 {
+		t.Errorf("generated %d spans in 300ms at 1000 spans/s", stats.Generated)
+	}
+	sr.AssertSpanCount(t, int(stats.Generated))
+
+	// Stopped, nothing else is generated.
+	time.Sleep(50 * time.Millisecond)
+	if got := g.Stats().Generated; got != stats.Generated {
+		t.Errorf("generated %d spans after Stop, want %d", got, stats.Generated)
+	}
+}
+
+func TestGenerator_DropsWhenExporterLags(t *testing.T) {
+	g, err := NewGenerator(&slowExporter{delay: 5 * time.Millisecond}, Config{SpansPerSecond: 1000})
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	g.Start()
+	time.Sleep(200 * time.Millisecond)
+	g.Stop()
+
+	stats := g.Stats()
+	if stats.Generated == 0 {
+		t.Error("no span generated")
+	}
+	if stats.Dropped == 0 {
+		t.Errorf("no span dropped, stats = %+v", stats)
+	}
+}
+
+func TestGenerator_StopWithoutStart(t *testing.T) {
+	g, err := NewGenerator(new(exportertest.SpanDataExporter), Config{SpansPerSecond: 1})
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	g.Stop()
+	g.Stop()
+	if got := g.Stats(); got != (Stats{}) {
+		t.Errorf("Stats() = %+v, want none", got)
+	}
+}
+
+// slowExporter takes delay to export every span.
+type slowExporter struct {
+	delay time.Duration
+}
+
+func (se *slowExporter) ExportSpan(*trace.SpanData) {
+	time.Sleep(se.delay)
+}
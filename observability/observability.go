@@ -20,6 +20,7 @@ package observability
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/grpc"
 
@@ -36,6 +37,9 @@ var (
 
 	mExporterReceivedSpans = stats.Int64("oc.io/exporter/received_spans", "Counts the number of spans received by the exporter", "1")
 	mExporterDroppedSpans  = stats.Int64("oc.io/exporter/dropped_spans", "Counts the number of spans received by the exporter", "1")
+	mExporterExportedSpans = stats.Int64("oc.io/exporter/exported_spans", "Counts the number of spans exported by the exporter", "1")
+	mExporterErrors        = stats.Int64("oc.io/exporter/errors", "Counts the number of errors returned by the exporter", "1")
+	mExporterLatency       = stats.Float64("oc.io/exporter/latency", "Latency of the exports of the exporter", stats.UnitMilliseconds)
 )
 
 // TagKeyReceiver defines tag key for Receiver.
@@ -80,12 +84,45 @@ var ViewExporterDroppedSpans = &view.View{
 	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyExporter},
 }
 
+// ViewExporterExportedSpans defines the view for the exporter exported spans metric.
+var ViewExporterExportedSpans = &view.View{
+	Name:        mExporterExportedSpans.Name(),
+	Description: mExporterExportedSpans.Description(),
+	Measure:     mExporterExportedSpans,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyExporter},
+}
+
+// ViewExporterErrors defines the view for the exporter errors metric.
+var ViewExporterErrors = &view.View{
+	Name:        mExporterErrors.Name(),
+	Description: mExporterErrors.Description(),
+	Measure:     mExporterErrors,
+	Aggregation: view.Sum(),
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyExporter},
+}
+
+// ViewExporterLatency defines the view for the exporter latency metric.
+var ViewExporterLatency = &view.View{
+	Name:        mExporterLatency.Name(),
+	Description: mExporterLatency.Description(),
+	Measure:     mExporterLatency,
+	Aggregation: ExporterLatencyDistribution,
+	TagKeys:     []tag.Key{TagKeyReceiver, TagKeyExporter},
+}
+
+// ExporterLatencyDistribution is the distribution, in milliseconds, of the exporter latency views.
+var ExporterLatencyDistribution = view.Distribution(1, 2, 5, 10, 25, 50, 75, 100, 150, 200, 300, 400, 500, 750, 1000, 2000, 5000, 10000, 30000)
+
 // AllViews has the views for the metrics provided by the agent.
 var AllViews = []*view.View{
 	ViewReceiverReceivedSpans,
 	ViewReceiverDroppedSpans,
 	ViewExporterReceivedSpans,
 	ViewExporterDroppedSpans,
+	ViewExporterExportedSpans,
+	ViewExporterErrors,
+	ViewExporterLatency,
 }
 
 // ContextWithReceiverName adds the tag "oc_receiver" and the name of the receiver as the value,
@@ -110,10 +147,27 @@ func ContextWithExporterName(ctx context.Context, exporterName string) context.C
 	return ctx
 }
 
-// RecordTraceExporterMetrics records the number of the spans received and dropped by the exporter.
-// Use it with a context.Context generated using ContextWithExporterName().
+// RecordTraceExporterMetrics records the number of the spans received and dropped by the exporter,
+// the other spans received being exported. Use it with a context.Context generated using
+// ContextWithExporterName().
 func RecordTraceExporterMetrics(ctx context.Context, receivedSpans int, droppedSpans int) {
-	stats.Record(ctx, mExporterReceivedSpans.M(int64(receivedSpans)), mExporterDroppedSpans.M(int64(droppedSpans)))
+	stats.Record(
+		ctx,
+		mExporterReceivedSpans.M(int64(receivedSpans)),
+		mExporterDroppedSpans.M(int64(droppedSpans)),
+		mExporterExportedSpans.M(int64(receivedSpans-droppedSpans)))
+}
+
+// RecordTraceExporterLatency records how long the exporter took to export a batch of spans.
+// Use it with a context.Context generated using ContextWithExporterName().
+func RecordTraceExporterLatency(ctx context.Context, latency time.Duration) {
+	stats.Record(ctx, mExporterLatency.M(float64(latency)/float64(time.Millisecond)))
+}
+
+// RecordTraceExporterError records that the exporter failed to export some or all the spans
+// of a batch. Use it with a context.Context generated using ContextWithExporterName().
+func RecordTraceExporterError(ctx context.Context) {
+	stats.Record(ctx, mExporterErrors.M(1))
 }
 
 // GRPCServerWithObservabilityEnabled creates a gRPC server that at a bare minimum has
@@ -0,0 +1,34 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package observability
+
+import (
+	"go.opencensus.io/trace"
+)
+
+// ParentSampler returns a trace.Sampler following the sampling decision of the
+// parent span, which is usually a remote one received with the request, and
+// delegating to fallback for the root spans.
+func ParentSampler(fallback trace.Sampler) trace.Sampler {
+	return func(p trace.SamplingParameters) trace.SamplingDecision {
+		if p.ParentContext.SpanID == (trace.SpanID{}) {
+			return fallback(p)
+		}
+		if p.ParentContext.IsSampled() {
+			return trace.AlwaysSample()(p)
+		}
+		return trace.NeverSample()(p)
+	}
+}
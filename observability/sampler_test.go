@@ -0,0 +1,67 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package observability
+
+import (
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+func TestParentSampler(t *testing.T) {
+	parentSpanID := trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	tests := []struct {
+		name        string
+		parent      trace.SpanContext
+		fallback    trace.Sampler
+		wantSampled bool
+	}{
+		{
+			name:        "sampled_parent",
+			parent:      trace.SpanContext{SpanID: parentSpanID, TraceOptions: 1},
+			fallback:    trace.NeverSample(),
+			wantSampled: true,
+		},
+		{
+			name:        "unsampled_parent",
+			parent:      trace.SpanContext{SpanID: parentSpanID, TraceOptions: 0},
+			fallback:    trace.AlwaysSample(),
+			wantSampled: false,
+		},
+		{
+			name:        "root_sampled_by_fallback",
+			fallback:    trace.AlwaysSample(),
+			wantSampled: true,
+		},
+		{
+			name:        "root_unsampled_by_fallback",
+			fallback:    trace.NeverSample(),
+			wantSampled: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler := ParentSampler(tt.fallback)
+			got := sampler(trace.SamplingParameters{
+				ParentContext:   tt.parent,
+				SpanID:          trace.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+				HasRemoteParent: tt.parent.SpanID != trace.SpanID{},
+			})
+			if got.Sample != tt.wantSampled {
+				t.Errorf("Got sampled %v, want %v", got.Sample, tt.wantSampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admissioncontrolprocessor drops the spans too large to go through
+// the pipeline, e.g. the ones with many attributes or long annotations, that
+// would otherwise consume a disproportionate amount of memory.
+package admissioncontrolprocessor
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/jsonpb"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// Option is an option to the admission control processor.
+type Option func(acp *admissioncontrolprocessor)
+
+// WithLogger sets the logger used to warn about every rejected span.
+func WithLogger(logger *zap.Logger) Option {
+	return func(acp *admissioncontrolprocessor) {
+		acp.logger = logger
+	}
+}
+
+type admissioncontrolprocessor struct {
+	nextConsumer consumer.TraceConsumer
+	maxSpanBytes int
+	logger       *zap.Logger
+
+	marshaler *jsonpb.Marshaler
+	buffers   sync.Pool
+}
+
+var _ processor.TraceProcessor = (*admissioncontrolprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that passes to
+// nextConsumer only the spans whose JSON encoding is at most maxSpanBytes
+// long. The larger ones are rejected.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, maxSpanBytes int, opts ...Option) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if maxSpanBytes <= 0 {
+		return nil, errors.New("the maximum size of the spans must be positive")
+	}
+
+	acp := &admissioncontrolprocessor{
+		nextConsumer: nextConsumer,
+		maxSpanBytes: maxSpanBytes,
+		marshaler:    &jsonpb.Marshaler{},
+		buffers: sync.Pool{
+			New: func() interface{} { return new(bytes.Buffer) },
+		},
+	}
+	for _, opt := range opts {
+		opt(acp)
+	}
+	return acp, nil
+}
+
+func (acp *admissioncontrolprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	var admitted []*tracepb.Span
+	for i, span := range td.Spans {
+		size, tooLarge := acp.tooLarge(span)
+		if !tooLarge {
+			if admitted != nil {
+				admitted = append(admitted, span)
+			}
+			continue
+		}
+
+		if admitted == nil {
+			admitted = append(make([]*tracepb.Span, 0, len(td.Spans)-1), td.Spans[:i]...)
+		}
+		recordSpanRejected(ctx)
+		if acp.logger != nil {
+			acp.logger.Warn("Rejecting a span larger than the maximum size",
+				zap.String("span-id", hex.EncodeToString(span.SpanId)),
+				zap.Int("size", size),
+				zap.Int("max-span-bytes", acp.maxSpanBytes))
+		}
+	}
+	if admitted == nil {
+		return acp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+	if len(admitted) == 0 {
+		return nil
+	}
+
+	td.Spans = admitted
+	return acp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+// tooLarge returns the size of the JSON encoding of the span and whether it
+// is over the maximum size.
+func (acp *admissioncontrolprocessor) tooLarge(span *tracepb.Span) (int, bool) {
+	if span == nil {
+		return 0, false
+	}
+
+	buf := acp.buffers.Get().(*bytes.Buffer)
+	defer acp.buffers.Put(buf)
+	buf.Reset()
+	if err := acp.marshaler.Marshal(buf, span); err != nil {
+		// Spans that can't be measured are not rejected, the exporters
+		// handle the invalid ones.
+		return 0, false
+	}
+	return buf.Len(), buf.Len() > acp.maxSpanBytes
+}
@@ -0,0 +1,122 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admissioncontrolprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	if _, err := NewTraceProcessor(nil, 1024); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 0); err == nil {
+		t.Error("NewTraceProcessor() with a zero maximum size should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 1024); err != nil {
+		t.Errorf("NewTraceProcessor() error = %v", err)
+	}
+}
+
+func spanWithAnnotation(name, description string) *tracepb.Span {
+	return &tracepb.Span{
+		SpanId: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Name:   &tracepb.TruncatableString{Value: name},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{{
+				Value: &tracepb.Span_TimeEvent_Annotation_{
+					Annotation: &tracepb.Span_TimeEvent_Annotation{
+						Description: &tracepb.TruncatableString{Value: description},
+					},
+				},
+			}},
+		},
+	}
+}
+
+func rejectedTooLarge(t *testing.T) int64 {
+	rows, err := view.RetrieveData(statSpansRejectedTooLarge.Name())
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return int64(rows[0].Data.(*view.SumData).Value)
+}
+
+func TestRejectsSpansOverMaxSpanBytes(t *testing.T) {
+	views := MetricViews(telemetry.Normal)
+	if err := view.Register(views...); err != nil {
+		t.Fatalf("view.Register() error = %v", err)
+	}
+	defer view.Unregister(views...)
+
+	sink := &exportertest.SinkTraceExporter{}
+	acp, err := NewTraceProcessor(sink, 1024)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+
+	small := spanWithAnnotation("small", "short")
+	large := spanWithAnnotation("large", strings.Repeat("x", 2048))
+	before := rejectedTooLarge(t)
+
+	td := data.TraceData{Spans: []*tracepb.Span{small, large, nil}}
+	if err := acp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	got := sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 2 || got[0].Spans[0] != small || got[0].Spans[1] != nil {
+		t.Fatalf("Got %v, want only the small span and the nil one", got)
+	}
+	if got := rejectedTooLarge(t) - before; got != 1 {
+		t.Errorf("Got %d spans rejected for being too large, want 1", got)
+	}
+
+	// A batch of rejected spans only is not passed to the next consumer.
+	acp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{large}})
+	if got := len(sink.AllTraces()); got != 1 {
+		t.Errorf("Got %d batches, want the empty batch to be dropped", got)
+	}
+	if got := rejectedTooLarge(t) - before; got != 2 {
+		t.Errorf("Got %d spans rejected for being too large, want 2", got)
+	}
+}
+
+func TestBatchUnderMaxSpanBytesIsPassedAsIs(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	acp, _ := NewTraceProcessor(sink, 1024)
+
+	spans := []*tracepb.Span{spanWithAnnotation("a", "short"), spanWithAnnotation("b", "short")}
+	acp.ConsumeTraceData(context.Background(), data.TraceData{Spans: spans})
+
+	got := sink.AllTraces()
+	if len(got) != 1 || &got[0].Spans[0] != &spans[0] {
+		t.Errorf("The batch without large spans should be passed as is, got %v", got)
+	}
+}
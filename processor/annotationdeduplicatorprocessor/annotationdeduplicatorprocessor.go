@@ -0,0 +1,110 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package annotationdeduplicatorprocessor drops the annotations repeating the
+// message of a previous annotation of the same span, e.g. the ones emitted by
+// instrumented polling loops.
+package annotationdeduplicatorprocessor
+
+import (
+	"context"
+	"errors"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+type annotationdeduplicatorprocessor struct {
+	nextConsumer consumer.TraceConsumer
+}
+
+var _ processor.TraceProcessor = (*annotationdeduplicatorprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that keeps only the
+// first annotation with a given message within each span. The annotations
+// dropped are added to the DroppedAnnotationsCount of the span.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	return &annotationdeduplicatorprocessor{nextConsumer: nextConsumer}, nil
+}
+
+func (adp *annotationdeduplicatorprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	var spans []*tracepb.Span
+	numDeduplicated := 0
+	for i, span := range td.Spans {
+		timeEvents, n := deduplicate(span.GetTimeEvents())
+		if n == 0 {
+			continue
+		}
+		if spans == nil {
+			// The spans can be shared with other pipelines, copy the ones
+			// that are modified.
+			spans = append([]*tracepb.Span(nil), td.Spans...)
+		}
+		deduplicated := *span
+		deduplicated.TimeEvents = timeEvents
+		spans[i] = &deduplicated
+		numDeduplicated += n
+	}
+	if spans == nil {
+		return adp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+
+	recordAnnotationsDeduplicated(ctx, numDeduplicated)
+	td.Spans = spans
+	return adp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+// deduplicate returns the time events without the annotations repeating the
+// message of a previous one, and the number of annotations dropped. The time
+// events are returned as is if there is no repeated annotation.
+func deduplicate(timeEvents *tracepb.Span_TimeEvents) (*tracepb.Span_TimeEvents, int) {
+	var kept []*tracepb.Span_TimeEvent
+	seen := make(map[string]bool)
+	for i, te := range timeEvents.GetTimeEvent() {
+		annotation := te.GetAnnotation()
+		if annotation == nil {
+			if kept != nil {
+				kept = append(kept, te)
+			}
+			continue
+		}
+		message := annotation.GetDescription().GetValue()
+		if !seen[message] {
+			seen[message] = true
+			if kept != nil {
+				kept = append(kept, te)
+			}
+			continue
+		}
+		if kept == nil {
+			kept = append(make([]*tracepb.Span_TimeEvent, 0, len(timeEvents.TimeEvent)-1), timeEvents.TimeEvent[:i]...)
+		}
+	}
+	if kept == nil {
+		return timeEvents, 0
+	}
+
+	n := len(timeEvents.TimeEvent) - len(kept)
+	return &tracepb.Span_TimeEvents{
+		TimeEvent:                 kept,
+		DroppedAnnotationsCount:   timeEvents.DroppedAnnotationsCount + int32(n),
+		DroppedMessageEventsCount: timeEvents.DroppedMessageEventsCount,
+	}, n
+}
@@ -0,0 +1,140 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotationdeduplicatorprocessor
+
+import (
+	"context"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	if _, err := NewTraceProcessor(nil); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+}
+
+func annotation(message string) *tracepb.Span_TimeEvent {
+	return &tracepb.Span_TimeEvent{
+		Value: &tracepb.Span_TimeEvent_Annotation_{
+			Annotation: &tracepb.Span_TimeEvent_Annotation{
+				Description: &tracepb.TruncatableString{Value: message},
+			},
+		},
+	}
+}
+
+func messageEvent() *tracepb.Span_TimeEvent {
+	return &tracepb.Span_TimeEvent{
+		Value: &tracepb.Span_TimeEvent_MessageEvent_{
+			MessageEvent: &tracepb.Span_TimeEvent_MessageEvent{Id: 1},
+		},
+	}
+}
+
+func annotationsDeduplicated(t *testing.T) int64 {
+	rows, err := view.RetrieveData(statAnnotationsDeduplicated.Name())
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return int64(rows[0].Data.(*view.SumData).Value)
+}
+
+func TestDeduplicatesAnnotations(t *testing.T) {
+	views := MetricViews(telemetry.Normal)
+	if err := view.Register(views...); err != nil {
+		t.Fatalf("view.Register() error = %v", err)
+	}
+	defer view.Unregister(views...)
+
+	sink := &exportertest.SinkTraceExporter{}
+	adp, err := NewTraceProcessor(sink)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+
+	// 5 identical annotations, interleaved with 2 annotations of another
+	// message and a message event.
+	polling := &tracepb.Span{
+		Name: &tracepb.TruncatableString{Value: "polling"},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{
+				annotation("poll"),
+				annotation("poll"),
+				annotation("connected"),
+				annotation("poll"),
+				messageEvent(),
+				annotation("poll"),
+				annotation("connected"),
+				annotation("poll"),
+			},
+			DroppedAnnotationsCount: 1,
+		},
+	}
+	unique := &tracepb.Span{
+		Name: &tracepb.TruncatableString{Value: "unique"},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{annotation("a"), annotation("b")},
+		},
+	}
+	before := annotationsDeduplicated(t)
+
+	td := data.TraceData{Spans: []*tracepb.Span{polling, unique, nil}}
+	if err := adp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	got := sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 3 {
+		t.Fatalf("Got %v, want the 3 spans", got)
+	}
+	if got[0].Spans[1] != unique || got[0].Spans[2] != nil {
+		t.Errorf("Got %v, want the spans without repeated annotations as is", got[0].Spans[1:])
+	}
+
+	timeEvents := got[0].Spans[0].TimeEvents
+	var messages []string
+	for _, te := range timeEvents.TimeEvent {
+		if a := te.GetAnnotation(); a != nil {
+			messages = append(messages, a.Description.Value)
+		}
+	}
+	if len(messages) != 2 || messages[0] != "poll" || messages[1] != "connected" {
+		t.Errorf("Got annotations %v, want [poll connected]", messages)
+	}
+	if len(timeEvents.TimeEvent) != 3 {
+		t.Errorf("Got %d time events, want the 2 annotations and the message event", len(timeEvents.TimeEvent))
+	}
+	if timeEvents.DroppedAnnotationsCount != 6 {
+		t.Errorf("Got %d dropped annotations, want 6", timeEvents.DroppedAnnotationsCount)
+	}
+	if got := annotationsDeduplicated(t) - before; got != 5 {
+		t.Errorf("Got %d annotations deduplicated, want 5", got)
+	}
+
+	// The received span is left untouched.
+	if n := len(polling.TimeEvents.TimeEvent); n != 8 {
+		t.Errorf("Got %d time events in the received span, want 8", n)
+	}
+}
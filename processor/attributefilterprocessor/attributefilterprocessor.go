@@ -0,0 +1,148 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package attributefilterprocessor limits the attributes of spans to a set of
+// keys, for the exporters charging per attribute or limiting their
+// cardinality.
+package attributefilterprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// Config holds the patterns of the attribute keys kept or dropped. The
+// patterns use the syntax of path.Match, e.g. "http.*" matches all the keys
+// starting with "http.". Only one of the lists can be set.
+type Config struct {
+	// Allowlist, if not empty, holds the patterns of the only attribute keys
+	// kept.
+	Allowlist []string `mapstructure:"allowlist"`
+	// Denylist holds the patterns of the attribute keys dropped.
+	Denylist []string `mapstructure:"denylist"`
+}
+
+type attributefilterprocessor struct {
+	nextConsumer consumer.TraceConsumer
+	patterns     []string
+	// keepMatches is set for an allowlist, the keys not matching any of the
+	// patterns are dropped, and unset for a denylist, the keys matching one
+	// of them are dropped.
+	keepMatches bool
+}
+
+var _ processor.TraceProcessor = (*attributefilterprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that drops the
+// attributes of spans filtered out by cfg. The spans received are not
+// modified, filtered copies are passed to nextConsumer, with the number of
+// attributes removed added to their DroppedAttributesCount.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, cfg Config) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if len(cfg.Allowlist) > 0 && len(cfg.Denylist) > 0 {
+		return nil, errors.New("the attribute allowlist and denylist are mutually exclusive")
+	}
+
+	patterns, keepMatches := cfg.Denylist, false
+	if len(cfg.Allowlist) > 0 {
+		patterns, keepMatches = cfg.Allowlist, true
+	}
+	for _, pattern := range patterns {
+		// path.Match only reports malformed patterns while matching them.
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("attribute key pattern %q: %v", pattern, err)
+		}
+	}
+
+	return &attributefilterprocessor{
+		nextConsumer: nextConsumer,
+		patterns:     append([]string(nil), patterns...),
+		keepMatches:  keepMatches,
+	}, nil
+}
+
+func (afp *attributefilterprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if len(afp.patterns) == 0 {
+		return afp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+
+	var spans []*tracepb.Span
+	for i, span := range td.Spans {
+		attrs, changed := afp.filterAttributes(span.GetAttributes())
+		if !changed {
+			continue
+		}
+		if spans == nil {
+			// The spans can be shared with other pipelines, copy the ones
+			// that are filtered.
+			spans = append([]*tracepb.Span(nil), td.Spans...)
+		}
+		filtered := *span
+		filtered.Attributes = attrs
+		spans[i] = &filtered
+	}
+	if spans != nil {
+		td.Spans = spans
+	}
+	return afp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+// filterAttributes returns the attributes without the filtered out keys and
+// whether any was dropped. The given attributes are returned when unchanged.
+func (afp *attributefilterprocessor) filterAttributes(attrs *tracepb.Span_Attributes) (*tracepb.Span_Attributes, bool) {
+	if attrs == nil || len(attrs.AttributeMap) == 0 {
+		return attrs, false
+	}
+
+	var filteredMap map[string]*tracepb.AttributeValue
+	for key := range attrs.AttributeMap {
+		if afp.keep(key) {
+			continue
+		}
+		if filteredMap == nil {
+			filteredMap = make(map[string]*tracepb.AttributeValue, len(attrs.AttributeMap))
+			for k, v := range attrs.AttributeMap {
+				filteredMap[k] = v
+			}
+		}
+		delete(filteredMap, key)
+	}
+	if filteredMap == nil {
+		return attrs, false
+	}
+
+	filtered := *attrs
+	filtered.AttributeMap = filteredMap
+	filtered.DroppedAttributesCount += int32(len(attrs.AttributeMap) - len(filteredMap))
+	return &filtered, true
+}
+
+func (afp *attributefilterprocessor) keep(key string) bool {
+	for _, pattern := range afp.patterns {
+		if matched, _ := path.Match(pattern, key); matched {
+			return afp.keepMatches
+		}
+	}
+	return !afp.keepMatches
+}
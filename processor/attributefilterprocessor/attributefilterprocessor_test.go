@@ -0,0 +1,170 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attributefilterprocessor
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "allowlist", cfg: Config{Allowlist: []string{"http.*", "component"}}},
+		{name: "denylist", cfg: Config{Denylist: []string{"db.statement"}}},
+		{name: "both_lists", cfg: Config{Allowlist: []string{"http.*"}, Denylist: []string{"http.url"}}, wantErr: true},
+		{name: "invalid_pattern", cfg: Config{Denylist: []string{"http.["}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTraceProcessor(nopProcessor, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTraceProcessor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewTraceProcessor(nil, Config{}); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+}
+
+func stringValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: s}},
+	}
+}
+
+func testSpan() *tracepb.Span {
+	return &tracepb.Span{
+		Name: &tracepb.TruncatableString{Value: "GET /users"},
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"http.method":       stringValue("GET"),
+				"http.url":          stringValue("https://example.com/users"),
+				"http.status_code":  {Value: &tracepb.AttributeValue_IntValue{IntValue: 200}},
+				"component":         stringValue("net/http"),
+				"db.statement":      stringValue("SELECT * FROM users"),
+				"httpclient.errors": {Value: &tracepb.AttributeValue_IntValue{IntValue: 0}},
+			},
+			DroppedAttributesCount: 1,
+		},
+	}
+}
+
+func attributeKeys(span *tracepb.Span) []string {
+	var keys []string
+	for key := range span.GetAttributes().GetAttributeMap() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestAttributeFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantKeys    []string
+		wantDropped int32
+	}{
+		{
+			name:        "allowlist",
+			cfg:         Config{Allowlist: []string{"http.*", "component"}},
+			wantKeys:    []string{"component", "http.method", "http.status_code", "http.url"},
+			wantDropped: 3,
+		},
+		{
+			name:        "denylist",
+			cfg:         Config{Denylist: []string{"http.*", "db.statement"}},
+			wantKeys:    []string{"component", "httpclient.errors"},
+			wantDropped: 5,
+		},
+		{
+			name:        "denylist_character_class",
+			cfg:         Config{Denylist: []string{"http.[mu]*"}},
+			wantKeys:    []string{"component", "db.statement", "http.status_code", "httpclient.errors"},
+			wantDropped: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := testSpan()
+			untouched := proto.Clone(original).(*tracepb.Span)
+
+			sink := &exportertest.SinkTraceExporter{}
+			afp, err := NewTraceProcessor(sink, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewTraceProcessor() error = %v", err)
+			}
+
+			td := data.TraceData{Spans: []*tracepb.Span{original, nil}}
+			if err := afp.ConsumeTraceData(context.Background(), td); err != nil {
+				t.Fatalf("ConsumeTraceData() error = %v", err)
+			}
+
+			if !proto.Equal(original, untouched) {
+				t.Errorf("The received span was modified:\n%v", original)
+			}
+			if td.Spans[0] != original {
+				t.Errorf("The received TraceData was modified")
+			}
+
+			got := sink.AllTraces()
+			if len(got) != 1 || len(got[0].Spans) != 2 {
+				t.Fatalf("Unexpected data passed to the next consumer: %v", got)
+			}
+			if got[0].Spans[1] != nil {
+				t.Errorf("A nil span should be passed through, got %v", got[0].Spans[1])
+			}
+			span := got[0].Spans[0]
+			if g := attributeKeys(span); !reflect.DeepEqual(g, tt.wantKeys) {
+				t.Errorf("Attribute keys = %v, want %v", g, tt.wantKeys)
+			}
+			if g := span.Attributes.DroppedAttributesCount; g != tt.wantDropped {
+				t.Errorf("DroppedAttributesCount = %d, want %d", g, tt.wantDropped)
+			}
+			if g, w := span.Name.GetValue(), "GET /users"; g != w {
+				t.Errorf("Span name = %q, want %q", g, w)
+			}
+		})
+	}
+}
+
+func TestAttributeFilterWithoutMatchesPassesSpansThrough(t *testing.T) {
+	span := testSpan()
+
+	sink := &exportertest.SinkTraceExporter{}
+	afp, _ := NewTraceProcessor(sink, Config{Denylist: []string{"rpc.*"}})
+	afp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span}})
+
+	if got := sink.AllTraces()[0].Spans[0]; got != span {
+		t.Errorf("A span without filtered attributes should not be copied")
+	}
+}
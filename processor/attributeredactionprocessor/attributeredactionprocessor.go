@@ -0,0 +1,282 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attributeredactionprocessor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// Action is what is done to the value of a redacted attribute.
+type Action string
+
+const (
+	// Drop removes the attribute.
+	Drop Action = "drop"
+	// Hash replaces the value with the hex encoded SHA-256 of its string form.
+	Hash Action = "hash"
+	// Regex replaces the matches of Pattern, in string values, with Replacement.
+	Regex Action = "regex"
+)
+
+// RedactionRule identifies an attribute key and how its value is redacted.
+type RedactionRule struct {
+	// Key the attribute key to be redacted.
+	Key string `mapstructure:"key"`
+	// Action applied to the attribute.
+	Action Action `mapstructure:"action"`
+	// Pattern is the regular expression matched by the Regex action.
+	Pattern string `mapstructure:"pattern"`
+	// Replacement replaces each match of Pattern, it can refer to submatches
+	// as $1, ${name}, etc.
+	Replacement string `mapstructure:"replacement"`
+}
+
+type compiledRule struct {
+	RedactionRule
+	re *regexp.Regexp
+}
+
+type attributeredactionprocessor struct {
+	nextConsumer consumer.TraceConsumer
+	rules        map[string]compiledRule
+}
+
+var _ processor.TraceProcessor = (*attributeredactionprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that redacts the
+// attributes of spans, of their annotations and of their links. The spans
+// received are not modified, redacted copies are passed to nextConsumer.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, rules ...RedactionRule) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+
+	compiled := make(map[string]compiledRule, len(rules))
+	for _, rule := range rules {
+		if _, ok := compiled[rule.Key]; ok {
+			return nil, fmt.Errorf("redaction key %q already specified", rule.Key)
+		}
+		cr := compiledRule{RedactionRule: rule}
+		switch rule.Action {
+		case Drop, Hash:
+		case Regex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("redaction key %q: %v", rule.Key, err)
+			}
+			cr.re = re
+		default:
+			return nil, fmt.Errorf("redaction key %q: unknown action %q", rule.Key, rule.Action)
+		}
+		compiled[rule.Key] = cr
+	}
+
+	return &attributeredactionprocessor{
+		nextConsumer: nextConsumer,
+		rules:        compiled,
+	}, nil
+}
+
+func (arp *attributeredactionprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if len(arp.rules) == 0 {
+		return arp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+
+	// The spans can be shared with other pipelines, so only copies of them,
+	// and of the attribute maps that are redacted, are modified.
+	spans := make([]*tracepb.Span, len(td.Spans))
+	for i, span := range td.Spans {
+		spans[i] = arp.redactSpan(span)
+	}
+	td.Spans = spans
+	return arp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+func (arp *attributeredactionprocessor) redactSpan(span *tracepb.Span) *tracepb.Span {
+	if span == nil {
+		return nil
+	}
+
+	attrs, attrsChanged := arp.redactAttributes(span.Attributes)
+	timeEvents, timeEventsChanged := arp.redactTimeEvents(span.TimeEvents)
+	links, linksChanged := arp.redactLinks(span.Links)
+	if !attrsChanged && !timeEventsChanged && !linksChanged {
+		return span
+	}
+
+	redacted := *span
+	redacted.Attributes = attrs
+	redacted.TimeEvents = timeEvents
+	redacted.Links = links
+	return &redacted
+}
+
+func (arp *attributeredactionprocessor) redactTimeEvents(tes *tracepb.Span_TimeEvents) (*tracepb.Span_TimeEvents, bool) {
+	if tes == nil {
+		return nil, false
+	}
+
+	var events []*tracepb.Span_TimeEvent
+	for i, te := range tes.TimeEvent {
+		annotation, ok := te.GetValue().(*tracepb.Span_TimeEvent_Annotation_)
+		if !ok || annotation.Annotation == nil {
+			continue
+		}
+		attrs, changed := arp.redactAttributes(annotation.Annotation.Attributes)
+		if !changed {
+			continue
+		}
+		if events == nil {
+			events = append([]*tracepb.Span_TimeEvent(nil), tes.TimeEvent...)
+		}
+		redactedAnnotation := *annotation.Annotation
+		redactedAnnotation.Attributes = attrs
+		redactedEvent := *te
+		redactedEvent.Value = &tracepb.Span_TimeEvent_Annotation_{Annotation: &redactedAnnotation}
+		events[i] = &redactedEvent
+	}
+	if events == nil {
+		return tes, false
+	}
+
+	redacted := *tes
+	redacted.TimeEvent = events
+	return &redacted, true
+}
+
+func (arp *attributeredactionprocessor) redactLinks(links *tracepb.Span_Links) (*tracepb.Span_Links, bool) {
+	if links == nil {
+		return nil, false
+	}
+
+	var redactedLinks []*tracepb.Span_Link
+	for i, link := range links.Link {
+		if link == nil {
+			continue
+		}
+		attrs, changed := arp.redactAttributes(link.Attributes)
+		if !changed {
+			continue
+		}
+		if redactedLinks == nil {
+			redactedLinks = append([]*tracepb.Span_Link(nil), links.Link...)
+		}
+		redactedLink := *link
+		redactedLink.Attributes = attrs
+		redactedLinks[i] = &redactedLink
+	}
+	if redactedLinks == nil {
+		return links, false
+	}
+
+	redacted := *links
+	redacted.Link = redactedLinks
+	return &redacted, true
+}
+
+// redactAttributes returns the attributes with the rules applied and whether
+// that changed anything. The given attributes are returned when unchanged.
+func (arp *attributeredactionprocessor) redactAttributes(attrs *tracepb.Span_Attributes) (*tracepb.Span_Attributes, bool) {
+	if attrs == nil || len(attrs.AttributeMap) == 0 {
+		return attrs, false
+	}
+
+	var redactedMap map[string]*tracepb.AttributeValue
+	for key, value := range attrs.AttributeMap {
+		rule, ok := arp.rules[key]
+		if !ok {
+			continue
+		}
+		newValue, keep, changed := rule.apply(value)
+		if !changed {
+			continue
+		}
+		if redactedMap == nil {
+			redactedMap = make(map[string]*tracepb.AttributeValue, len(attrs.AttributeMap))
+			for k, v := range attrs.AttributeMap {
+				redactedMap[k] = v
+			}
+		}
+		if keep {
+			redactedMap[key] = newValue
+		} else {
+			delete(redactedMap, key)
+		}
+	}
+	if redactedMap == nil {
+		return attrs, false
+	}
+
+	redacted := *attrs
+	redacted.AttributeMap = redactedMap
+	return &redacted, true
+}
+
+// apply returns the redacted value, whether the attribute is kept and whether
+// anything changed.
+func (cr compiledRule) apply(value *tracepb.AttributeValue) (*tracepb.AttributeValue, bool, bool) {
+	switch cr.Action {
+	case Drop:
+		return nil, false, true
+	case Hash:
+		sum := sha256.Sum256([]byte(attributeValueString(value)))
+		return stringAttributeValue(hex.EncodeToString(sum[:])), true, true
+	case Regex:
+		sv := value.GetStringValue()
+		if sv == nil {
+			return value, true, false
+		}
+		replaced := cr.re.ReplaceAllString(sv.Value, cr.Replacement)
+		if replaced == sv.Value {
+			return value, true, false
+		}
+		return stringAttributeValue(replaced), true, true
+	}
+	return value, true, false
+}
+
+func attributeValueString(value *tracepb.AttributeValue) string {
+	switch v := value.GetValue().(type) {
+	case *tracepb.AttributeValue_StringValue:
+		return v.StringValue.GetValue()
+	case *tracepb.AttributeValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *tracepb.AttributeValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *tracepb.AttributeValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	}
+	return ""
+}
+
+func stringAttributeValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{
+			StringValue: &tracepb.TruncatableString{Value: s},
+		},
+	}
+}
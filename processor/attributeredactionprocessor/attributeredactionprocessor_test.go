@@ -0,0 +1,198 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attributeredactionprocessor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	tests := []struct {
+		name    string
+		rules   []RedactionRule
+		wantErr bool
+	}{
+		{name: "no_rules"},
+		{name: "valid_rules", rules: []RedactionRule{
+			{Key: "a", Action: Drop},
+			{Key: "b", Action: Hash},
+			{Key: "c", Action: Regex, Pattern: "token=[^&]*", Replacement: "token=REDACTED"},
+		}},
+		{name: "duplicated_key", rules: []RedactionRule{{Key: "a", Action: Drop}, {Key: "a", Action: Hash}}, wantErr: true},
+		{name: "unknown_action", rules: []RedactionRule{{Key: "a", Action: "mask"}}, wantErr: true},
+		{name: "invalid_pattern", rules: []RedactionRule{{Key: "a", Action: Regex, Pattern: "("}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTraceProcessor(nopProcessor, tt.rules...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTraceProcessor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewTraceProcessor(nil); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+}
+
+func stringValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: s}},
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRedaction(t *testing.T) {
+	original := &tracepb.Span{
+		Name: &tracepb.TruncatableString{Value: "GET /login"},
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"user.email":  stringValue("jane@example.com"),
+				"user.id":     {Value: &tracepb.AttributeValue_IntValue{IntValue: 42}},
+				"http.url":    stringValue("https://example.com/login?token=s3cr3t&lang=en"),
+				"password":    stringValue("hunter2"),
+				"http.method": stringValue("GET"),
+			},
+		},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{
+				{
+					Value: &tracepb.Span_TimeEvent_Annotation_{
+						Annotation: &tracepb.Span_TimeEvent_Annotation{
+							Description: &tracepb.TruncatableString{Value: "login attempt"},
+							Attributes: &tracepb.Span_Attributes{
+								AttributeMap: map[string]*tracepb.AttributeValue{
+									"password": stringValue("hunter2"),
+									"attempt":  {Value: &tracepb.AttributeValue_IntValue{IntValue: 1}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Links: &tracepb.Span_Links{
+			Link: []*tracepb.Span_Link{
+				{
+					Attributes: &tracepb.Span_Attributes{
+						AttributeMap: map[string]*tracepb.AttributeValue{
+							"user.email": stringValue("john@example.com"),
+						},
+					},
+				},
+			},
+		},
+	}
+	untouched := proto.Clone(original).(*tracepb.Span)
+
+	sink := &exportertest.SinkTraceExporter{}
+	arp, err := NewTraceProcessor(sink,
+		RedactionRule{Key: "password", Action: Drop},
+		RedactionRule{Key: "user.email", Action: Hash},
+		RedactionRule{Key: "user.id", Action: Hash},
+		RedactionRule{Key: "http.url", Action: Regex, Pattern: `token=[^&]*`, Replacement: "token=REDACTED"},
+	)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+
+	td := data.TraceData{Spans: []*tracepb.Span{original, nil}}
+	if err := arp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	if !proto.Equal(original, untouched) {
+		t.Errorf("The received span was modified:\n%v", original)
+	}
+
+	got := sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 2 {
+		t.Fatalf("Unexpected data passed to the next consumer: %v", got)
+	}
+	if got[0].Spans[1] != nil {
+		t.Errorf("A nil span should be passed through, got %v", got[0].Spans[1])
+	}
+	span := got[0].Spans[0]
+	attrs := span.Attributes.AttributeMap
+
+	if _, ok := attrs["password"]; ok {
+		t.Error("Drop: password is still present")
+	}
+	if g, w := attrs["user.email"].GetStringValue().GetValue(), sha256Hex("jane@example.com"); g != w {
+		t.Errorf("Hash: user.email = %q, want %q", g, w)
+	}
+	if g, w := attrs["user.id"].GetStringValue().GetValue(), sha256Hex("42"); g != w {
+		t.Errorf("Hash: user.id = %q, want %q", g, w)
+	}
+	if g, w := attrs["http.url"].GetStringValue().GetValue(), "https://example.com/login?token=REDACTED&lang=en"; g != w {
+		t.Errorf("Regex: http.url = %q, want %q", g, w)
+	}
+	if g, w := attrs["http.method"].GetStringValue().GetValue(), "GET"; g != w {
+		t.Errorf("Unredacted http.method = %q, want %q", g, w)
+	}
+
+	annotationAttrs := span.TimeEvents.TimeEvent[0].GetAnnotation().Attributes.AttributeMap
+	if _, ok := annotationAttrs["password"]; ok {
+		t.Error("Drop: password is still present in the annotation")
+	}
+	if _, ok := annotationAttrs["attempt"]; !ok {
+		t.Error("Unredacted annotation attribute was removed")
+	}
+	if g, w := span.TimeEvents.TimeEvent[0].GetAnnotation().Description.GetValue(), "login attempt"; g != w {
+		t.Errorf("Annotation description = %q, want %q", g, w)
+	}
+
+	linkAttrs := span.Links.Link[0].Attributes.AttributeMap
+	if g, w := linkAttrs["user.email"].GetStringValue().GetValue(), sha256Hex("john@example.com"); g != w {
+		t.Errorf("Hash: link user.email = %q, want %q", g, w)
+	}
+}
+
+func TestRedactionWithoutMatchesPassesSpansThrough(t *testing.T) {
+	span := &tracepb.Span{
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"http.url": stringValue("https://example.com/"),
+			},
+		},
+	}
+
+	sink := &exportertest.SinkTraceExporter{}
+	arp, _ := NewTraceProcessor(sink,
+		RedactionRule{Key: "password", Action: Drop},
+		RedactionRule{Key: "http.url", Action: Regex, Pattern: `token=[^&]*`, Replacement: "token=REDACTED"},
+	)
+	arp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span}})
+
+	if got := sink.AllTraces()[0].Spans[0]; got != span {
+		t.Errorf("A span without redacted attributes should not be copied")
+	}
+}
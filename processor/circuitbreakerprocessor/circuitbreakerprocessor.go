@@ -0,0 +1,186 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package circuitbreakerprocessor stops passing spans to a failing consumer,
+// e.g. an exporter whose backend is down, for some time so that it does not
+// back up the pipeline.
+package circuitbreakerprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// ErrCircuitOpen is returned, without passing the spans to the next consumer,
+// while the circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State is the state of the circuit breaker.
+type State int
+
+const (
+	// Closed passes the spans to the next consumer.
+	Closed State = iota
+	// Open rejects the spans until the recovery timeout expires.
+	Open
+	// HalfOpen lets a single batch through to test whether the next consumer
+	// recovered, the other ones are rejected in the meantime.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// TraceProcessor is a processor.TraceProcessor that reports the state of its
+// circuit.
+type TraceProcessor interface {
+	processor.TraceProcessor
+
+	// State returns the current state of the circuit.
+	State() State
+}
+
+// Option is an option to the circuit breaker processor.
+type Option func(cbp *circuitbreakerprocessor)
+
+// WithLogger sets the logger used to report the changes of state.
+func WithLogger(logger *zap.Logger) Option {
+	return func(cbp *circuitbreakerprocessor) {
+		cbp.logger = logger
+	}
+}
+
+type circuitbreakerprocessor struct {
+	nextConsumer     consumer.TraceConsumer
+	failureThreshold int
+	recoveryTimeout  time.Duration
+	logger           *zap.Logger
+	now              func() time.Time
+
+	mu                  sync.Mutex
+	state               State
+	consecutiveFailures int
+	openedAt            time.Time
+}
+
+var _ TraceProcessor = (*circuitbreakerprocessor)(nil)
+
+// NewTraceProcessor returns a TraceProcessor that opens its circuit after
+// failureThreshold consecutive errors from nextConsumer. While open the spans
+// are dropped with ErrCircuitOpen, after recoveryTimeout the next batch is
+// passed through again and closes the circuit if it succeeds.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, failureThreshold int, recoveryTimeout time.Duration, opts ...Option) (TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if failureThreshold <= 0 || recoveryTimeout <= 0 {
+		return nil, errors.New("the failure threshold and the recovery timeout must be positive")
+	}
+
+	cbp := &circuitbreakerprocessor{
+		nextConsumer:     nextConsumer,
+		failureThreshold: failureThreshold,
+		recoveryTimeout:  recoveryTimeout,
+		logger:           zap.NewNop(),
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(cbp)
+	}
+	return cbp, nil
+}
+
+func (cbp *circuitbreakerprocessor) State() State {
+	cbp.mu.Lock()
+	defer cbp.mu.Unlock()
+	return cbp.state
+}
+
+func (cbp *circuitbreakerprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if !cbp.allow() {
+		recordCircuitOpen(ctx, len(td.Spans))
+		return ErrCircuitOpen
+	}
+
+	err := cbp.nextConsumer.ConsumeTraceData(ctx, td)
+	cbp.onResult(err)
+	return err
+}
+
+// allow returns whether the spans can be passed to the next consumer, moving
+// from open to half-open once the recovery timeout expired.
+func (cbp *circuitbreakerprocessor) allow() bool {
+	cbp.mu.Lock()
+	defer cbp.mu.Unlock()
+
+	switch cbp.state {
+	case Closed:
+		return true
+	case Open:
+		if cbp.now().Sub(cbp.openedAt) < cbp.recoveryTimeout {
+			return false
+		}
+		cbp.setState(HalfOpen)
+		return true
+	}
+	// Half-open, the trial batch is still in progress.
+	return false
+}
+
+func (cbp *circuitbreakerprocessor) onResult(err error) {
+	cbp.mu.Lock()
+	defer cbp.mu.Unlock()
+
+	if err == nil {
+		cbp.consecutiveFailures = 0
+		if cbp.state != Closed {
+			cbp.setState(Closed)
+		}
+		return
+	}
+
+	cbp.consecutiveFailures++
+	if cbp.state == HalfOpen || cbp.consecutiveFailures >= cbp.failureThreshold {
+		cbp.openedAt = cbp.now()
+		if cbp.state != Open {
+			cbp.setState(Open)
+		}
+	}
+}
+
+// setState must be called with mu held.
+func (cbp *circuitbreakerprocessor) setState(state State) {
+	cbp.logger.Info("Circuit breaker state changed",
+		zap.Stringer("from", cbp.state),
+		zap.Stringer("to", state),
+		zap.Int("consecutive-failures", cbp.consecutiveFailures))
+	cbp.state = state
+}
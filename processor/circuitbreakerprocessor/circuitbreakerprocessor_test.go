@@ -0,0 +1,204 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package circuitbreakerprocessor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	if _, err := NewTraceProcessor(nil, 3, time.Second); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 0, time.Second); err == nil {
+		t.Error("NewTraceProcessor() with a zero failure threshold should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 3, 0); err == nil {
+		t.Error("NewTraceProcessor() with a zero recovery timeout should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 3, time.Second); err != nil {
+		t.Errorf("NewTraceProcessor() error = %v", err)
+	}
+}
+
+// countingExporter counts the batches it receives and fails while err is set.
+type countingExporter struct {
+	calls int
+	err   error
+}
+
+func (ce *countingExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	ce.calls++
+	return ce.err
+}
+
+func newTestProcessor(t *testing.T, next *countingExporter, failureThreshold int) (*circuitbreakerprocessor, *time.Time) {
+	tp, err := NewTraceProcessor(next, failureThreshold, time.Minute)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	cbp := tp.(*circuitbreakerprocessor)
+	now := time.Unix(1500000000, 0)
+	cbp.now = func() time.Time { return now }
+	return cbp, &now
+}
+
+var td = data.TraceData{Spans: []*tracepb.Span{{}, {}}}
+
+func TestCircuitOpensAfterThreshold(t *testing.T) {
+	exportErr := errors.New("backend is down")
+	next := &countingExporter{err: exportErr}
+	cbp, _ := newTestProcessor(t, next, 3)
+
+	for i := 0; i < 3; i++ {
+		if state := cbp.State(); state != Closed {
+			t.Fatalf("State() after %d errors = %v, want %v", i, state, Closed)
+		}
+		if err := cbp.ConsumeTraceData(context.Background(), td); err != exportErr {
+			t.Fatalf("ConsumeTraceData() #%d = %v, want %v", i, err, exportErr)
+		}
+	}
+	if state := cbp.State(); state != Open {
+		t.Fatalf("State() after 3 errors = %v, want %v", state, Open)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := cbp.ConsumeTraceData(context.Background(), td); err != ErrCircuitOpen {
+			t.Fatalf("ConsumeTraceData() while open = %v, want %v", err, ErrCircuitOpen)
+		}
+	}
+	if next.calls != 3 {
+		t.Errorf("The next consumer got %d calls, want 3: the open circuit must short-circuit them", next.calls)
+	}
+}
+
+func TestCircuitSuccessResetsFailures(t *testing.T) {
+	next := &countingExporter{err: errors.New("transient")}
+	cbp, _ := newTestProcessor(t, next, 2)
+
+	cbp.ConsumeTraceData(context.Background(), td)
+	next.err = nil
+	cbp.ConsumeTraceData(context.Background(), td)
+	next.err = errors.New("transient")
+	cbp.ConsumeTraceData(context.Background(), td)
+
+	if state := cbp.State(); state != Closed {
+		t.Errorf("State() = %v, want %v: the errors were not consecutive", state, Closed)
+	}
+}
+
+func TestCircuitRecovery(t *testing.T) {
+	next := &countingExporter{err: errors.New("backend is down")}
+	cbp, now := newTestProcessor(t, next, 1)
+
+	cbp.ConsumeTraceData(context.Background(), td)
+	if state := cbp.State(); state != Open {
+		t.Fatalf("State() = %v, want %v", state, Open)
+	}
+
+	// A failed trial opens the circuit again for a whole recovery timeout.
+	*now = now.Add(time.Minute)
+	if err := cbp.ConsumeTraceData(context.Background(), td); err == ErrCircuitOpen {
+		t.Fatal("The trial batch was not passed to the next consumer after the recovery timeout")
+	}
+	if state := cbp.State(); state != Open {
+		t.Fatalf("State() after a failed trial = %v, want %v", state, Open)
+	}
+	*now = now.Add(time.Minute - time.Second)
+	if err := cbp.ConsumeTraceData(context.Background(), td); err != ErrCircuitOpen {
+		t.Fatalf("ConsumeTraceData() before the recovery timeout = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// A successful trial closes it.
+	*now = now.Add(time.Second)
+	next.err = nil
+	if err := cbp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() trial = %v, want nil", err)
+	}
+	if state := cbp.State(); state != Closed {
+		t.Fatalf("State() after a successful trial = %v, want %v", state, Closed)
+	}
+	if next.calls != 3 {
+		t.Errorf("The next consumer got %d calls, want 3", next.calls)
+	}
+}
+
+func TestCircuitHalfOpenLetsASingleTrialThrough(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	sink := &exportertest.SinkTraceExporter{}
+	blocking := &blockingExporter{next: sink, started: started, release: release}
+	tp, _ := NewTraceProcessor(blocking, 1, time.Millisecond)
+	cbp := tp.(*circuitbreakerprocessor)
+
+	blocking.err = errors.New("backend is down")
+	close(release)
+	cbp.ConsumeTraceData(context.Background(), td)
+	<-started
+	if state := cbp.State(); state != Open {
+		t.Fatalf("State() = %v, want %v", state, Open)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	blocking.err = nil
+	blocking.release = make(chan struct{})
+	trialDone := make(chan error)
+	go func() {
+		trialDone <- cbp.ConsumeTraceData(context.Background(), td)
+	}()
+	<-started
+
+	if state := cbp.State(); state != HalfOpen {
+		t.Fatalf("State() during the trial = %v, want %v", state, HalfOpen)
+	}
+	if err := cbp.ConsumeTraceData(context.Background(), td); err != ErrCircuitOpen {
+		t.Errorf("ConsumeTraceData() during the trial = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	close(blocking.release)
+	if err := <-trialDone; err != nil {
+		t.Fatalf("ConsumeTraceData() trial = %v, want nil", err)
+	}
+	if got := len(sink.AllTraces()); got != 1 {
+		t.Errorf("Got %d batches exported, want 1", got)
+	}
+}
+
+// blockingExporter signals each call and blocks it until release is closed.
+type blockingExporter struct {
+	next    *exportertest.SinkTraceExporter
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (be *blockingExporter) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	be.started <- struct{}{}
+	<-be.release
+	if be.err != nil {
+		return be.err
+	}
+	return be.next.ConsumeTraceData(ctx, td)
+}
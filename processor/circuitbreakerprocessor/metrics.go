@@ -0,0 +1,54 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package circuitbreakerprocessor
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var (
+	statCircuitOpen  = stats.Int64("circuit_open_total", "Count of batches rejected while the circuit breaker is open", stats.UnitDimensionless)
+	statSpansDropped = stats.Int64("circuit_open_spans_dropped_total", "Count of spans dropped while the circuit breaker is open", stats.UnitDimensionless)
+)
+
+// MetricViews return the metrics views according to given telemetry level.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	circuitOpenView := &view.View{
+		Name:        statCircuitOpen.Name(),
+		Measure:     statCircuitOpen,
+		Description: statCircuitOpen.Description(),
+		Aggregation: view.Sum(),
+	}
+	spansDroppedView := &view.View{
+		Name:        statSpansDropped.Name(),
+		Measure:     statSpansDropped,
+		Description: statSpansDropped.Description(),
+		Aggregation: view.Sum(),
+	}
+	return []*view.View{circuitOpenView, spansDroppedView}
+}
+
+func recordCircuitOpen(ctx context.Context, numDropped int) {
+	stats.Record(ctx, statCircuitOpen.M(1), statSpansDropped.M(int64(numDropped)))
+}
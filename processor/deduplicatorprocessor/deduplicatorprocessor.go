@@ -0,0 +1,139 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deduplicatorprocessor drops the spans already received recently,
+// typically duplicates sent by clients retrying a failed export.
+package deduplicatorprocessor
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+type deduplicatorprocessor struct {
+	nextConsumer consumer.TraceConsumer
+	seen         *seenSpans
+}
+
+var _ processor.TraceProcessor = (*deduplicatorprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that drops the spans
+// whose trace and span IDs were seen in the last ttl. At most cacheSize IDs
+// are remembered, the least recently seen ones are forgotten first.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, cacheSize int, ttl time.Duration) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if cacheSize <= 0 || ttl <= 0 {
+		return nil, errors.New("the cache size and the TTL must be positive")
+	}
+
+	return &deduplicatorprocessor{
+		nextConsumer: nextConsumer,
+		seen:         newSeenSpans(cacheSize, ttl, time.Now),
+	}, nil
+}
+
+func (dp *deduplicatorprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	var uniqueSpans []*tracepb.Span
+	for i, span := range td.Spans {
+		// Spans without an ID can't be told apart, pass them through.
+		if span == nil || len(span.SpanId) == 0 || !dp.seen.add(spanKey(span)) {
+			if uniqueSpans != nil {
+				uniqueSpans = append(uniqueSpans, span)
+			}
+			continue
+		}
+		if uniqueSpans == nil {
+			uniqueSpans = append(make([]*tracepb.Span, 0, len(td.Spans)), td.Spans[:i]...)
+		}
+	}
+	if uniqueSpans == nil {
+		return dp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+
+	recordSpansDeduplicated(ctx, len(td.Spans)-len(uniqueSpans))
+	if len(uniqueSpans) == 0 {
+		return nil
+	}
+	td.Spans = uniqueSpans
+	return dp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+func spanKey(span *tracepb.Span) string {
+	// Span IDs are only unique within a trace.
+	return string(span.TraceId) + string(span.SpanId)
+}
+
+// seenSpans is a LRU cache of the keys of the spans seen, whose entries
+// expire after a TTL.
+type seenSpans struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type seenSpan struct {
+	key    string
+	seenAt time.Time
+}
+
+func newSeenSpans(size int, ttl time.Duration, now func() time.Time) *seenSpans {
+	return &seenSpans{
+		size:    size,
+		ttl:     ttl,
+		now:     now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// add reports whether the key was seen less than the TTL ago, and records it
+// as seen now.
+func (ss *seenSpans) add(key string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := ss.now()
+	if elem, ok := ss.entries[key]; ok {
+		entry := elem.Value.(*seenSpan)
+		duplicate := now.Sub(entry.seenAt) < ss.ttl
+		if !duplicate {
+			entry.seenAt = now
+		}
+		ss.lru.MoveToFront(elem)
+		return duplicate
+	}
+
+	ss.entries[key] = ss.lru.PushFront(&seenSpan{key: key, seenAt: now})
+	for ss.lru.Len() > ss.size {
+		oldest := ss.lru.Back()
+		ss.lru.Remove(oldest)
+		delete(ss.entries, oldest.Value.(*seenSpan).key)
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deduplicatorprocessor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	if _, err := NewTraceProcessor(nil, 10, time.Minute); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 0, time.Minute); err == nil {
+		t.Error("NewTraceProcessor() with a zero cache size should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 10, 0); err == nil {
+		t.Error("NewTraceProcessor() with a zero TTL should fail")
+	}
+}
+
+func span(traceID, spanID byte) *tracepb.Span {
+	return &tracepb.Span{
+		TraceId: []byte{traceID, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		SpanId:  []byte{spanID, 1, 2, 3, 4, 5, 6, 7},
+	}
+}
+
+func newTestProcessor(cacheSize int, ttl time.Duration, now *time.Time) (*deduplicatorprocessor, *exportertest.SinkTraceExporter) {
+	sink := &exportertest.SinkTraceExporter{}
+	tp, _ := NewTraceProcessor(sink, cacheSize, ttl)
+	dp := tp.(*deduplicatorprocessor)
+	dp.seen = newSeenSpans(cacheSize, ttl, func() time.Time { return *now })
+	return dp, sink
+}
+
+func forwardedSpans(sink *exportertest.SinkTraceExporter) []*tracepb.Span {
+	var spans []*tracepb.Span
+	for _, td := range sink.AllTraces() {
+		spans = append(spans, td.Spans...)
+	}
+	return spans
+}
+
+func TestDeduplicationWithinAndBeyondTTL(t *testing.T) {
+	now := time.Now()
+	dp, sink := newTestProcessor(100, time.Minute, &now)
+	ctx := context.Background()
+
+	first := span(1, 1)
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{first, span(1, 2)}})
+
+	// A retry within the TTL, with a span of another trace sharing the span ID.
+	now = now.Add(30 * time.Second)
+	otherTrace := span(2, 1)
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{span(1, 1), otherTrace, nil}})
+
+	// The whole batch is a duplicate.
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{span(1, 2)}})
+
+	got := forwardedSpans(sink)
+	if len(got) != 4 || got[0] != first || got[2] != otherTrace || got[3] != nil {
+		t.Fatalf("got forwarded spans %v, want the first copies, the span of the other trace and the nil span", got)
+	}
+
+	// Beyond the TTL the span is forwarded again.
+	now = now.Add(31 * time.Second)
+	retried := span(1, 1)
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{retried}})
+	got = forwardedSpans(sink)
+	if len(got) != 5 || got[4] != retried {
+		t.Fatalf("a span seen beyond the TTL should be forwarded, got %v", got)
+	}
+}
+
+func TestDeduplicationCacheEviction(t *testing.T) {
+	now := time.Now()
+	dp, sink := newTestProcessor(2, time.Hour, &now)
+	ctx := context.Background()
+
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{span(1, 1), span(1, 2)}})
+	// Seeing span 1 again makes span 2 the least recently seen.
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{span(1, 1), span(1, 3)}})
+	dp.ConsumeTraceData(ctx, data.TraceData{Spans: []*tracepb.Span{span(1, 1), span(1, 2)}})
+
+	if got := len(forwardedSpans(sink)); got != 4 {
+		t.Fatalf("got %d spans forwarded, want 4", got)
+	}
+}
+
+func TestDeduplicationConcurrentSenders(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	dp, _ := NewTraceProcessor(sink, 1000, time.Minute)
+
+	const numSenders = 8
+	var wg sync.WaitGroup
+	for i := 0; i < numSenders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				dp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span(1, byte(j))}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, s := range forwardedSpans(sink) {
+		key := fmt.Sprintf("%x", s.SpanId)
+		if seen[key] {
+			t.Fatalf("span %s was forwarded twice", key)
+		}
+		seen[key] = true
+	}
+	if len(seen) != 100 {
+		t.Fatalf("got %d distinct spans forwarded, want 100", len(seen))
+	}
+}
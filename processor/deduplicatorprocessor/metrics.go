@@ -0,0 +1,45 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deduplicatorprocessor
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var statSpansDeduplicated = stats.Int64("deduplicator_spans_deduplicated_total", "Count of duplicate spans dropped", stats.UnitDimensionless)
+
+// MetricViews return the metrics views according to given telemetry level.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	spansDeduplicatedView := &view.View{
+		Name:        statSpansDeduplicated.Name(),
+		Measure:     statSpansDeduplicated,
+		Description: statSpansDeduplicated.Description(),
+		Aggregation: view.Sum(),
+	}
+	return []*view.View{spansDeduplicatedView}
+}
+
+func recordSpansDeduplicated(ctx context.Context, numDeduplicated int) {
+	stats.Record(ctx, statSpansDeduplicated.M(int64(numDeduplicated)))
+}
@@ -0,0 +1,170 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8senricherprocessor adds the Kubernetes metadata of the pod the
+// service runs on to the spans passing through it.
+package k8senricherprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+const (
+	// DefaultMetadataDir is the directory where the Downward API volume
+	// holding the pod metadata is typically mounted.
+	DefaultMetadataDir = "/etc/podinfo"
+	// DefaultRefreshInterval is how often the metadata is read again.
+	DefaultRefreshInterval = time.Minute
+)
+
+// metadataFiles are the files, in the Downward API volume, from which the
+// attributes are read. The volume should be declared with items like
+// "path: namespace" and "fieldRef: fieldPath: metadata.namespace".
+var metadataFiles = []struct {
+	file      string
+	attribute string
+}{
+	{file: "namespace", attribute: "k8s.namespace"},
+	{file: "pod_name", attribute: "k8s.pod_name"},
+	{file: "node_name", attribute: "k8s.node_name"},
+}
+
+// Option is an option to the k8s enricher processor.
+type Option func(kep *k8senricherprocessor)
+
+// WithMetadataDir sets the directory where the Downward API volume is
+// mounted, it defaults to DefaultMetadataDir.
+func WithMetadataDir(dir string) Option {
+	return func(kep *k8senricherprocessor) {
+		kep.metadataDir = dir
+	}
+}
+
+// WithRefreshInterval sets how often the metadata is read again, it
+// defaults to DefaultRefreshInterval.
+func WithRefreshInterval(refreshInterval time.Duration) Option {
+	return func(kep *k8senricherprocessor) {
+		kep.refreshInterval = refreshInterval
+	}
+}
+
+type k8senricherprocessor struct {
+	nextConsumer    consumer.TraceConsumer
+	metadataDir     string
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu         sync.Mutex
+	attributes map[string]*tracepb.AttributeValue
+	loadedAt   time.Time
+}
+
+var _ processor.TraceProcessor = (*k8senricherprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that adds the
+// k8s.namespace, k8s.pod_name and k8s.node_name attributes to all spans,
+// keeping the values of the attributes already on the spans.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, opts ...Option) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+
+	kep := &k8senricherprocessor{
+		nextConsumer:    nextConsumer,
+		metadataDir:     DefaultMetadataDir,
+		refreshInterval: DefaultRefreshInterval,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(kep)
+	}
+	if _, err := os.Stat(kep.metadataDir); err != nil {
+		return nil, fmt.Errorf("cannot read the pod metadata: %v", err)
+	}
+	kep.load()
+	return kep, nil
+}
+
+func (kep *k8senricherprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	attributes := kep.metadata()
+	if len(attributes) == 0 {
+		return kep.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+	for _, span := range td.Spans {
+		if span == nil {
+			continue
+		}
+		if span.Attributes == nil {
+			span.Attributes = &tracepb.Span_Attributes{}
+		}
+		if span.Attributes.AttributeMap == nil {
+			span.Attributes.AttributeMap = make(map[string]*tracepb.AttributeValue, len(attributes))
+		}
+		for key, value := range attributes {
+			if _, exists := span.Attributes.AttributeMap[key]; !exists {
+				span.Attributes.AttributeMap[key] = value
+			}
+		}
+	}
+	return kep.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+// metadata returns the cached attributes, reading them again if they are
+// older than the refresh interval.
+func (kep *k8senricherprocessor) metadata() map[string]*tracepb.AttributeValue {
+	kep.mu.Lock()
+	defer kep.mu.Unlock()
+	if kep.refreshInterval > 0 && kep.now().Sub(kep.loadedAt) >= kep.refreshInterval {
+		kep.load()
+	}
+	return kep.attributes
+}
+
+// load reads the metadata files, the previous value of an attribute is kept
+// if its file can't be read. The map is replaced, never modified, since the
+// spans already processed share its values.
+func (kep *k8senricherprocessor) load() {
+	attributes := make(map[string]*tracepb.AttributeValue, len(metadataFiles))
+	for _, mf := range metadataFiles {
+		b, err := ioutil.ReadFile(filepath.Join(kep.metadataDir, mf.file))
+		value := strings.TrimSpace(string(b))
+		if err != nil || value == "" {
+			if previous, ok := kep.attributes[mf.attribute]; ok {
+				attributes[mf.attribute] = previous
+			}
+			continue
+		}
+		attributes[mf.attribute] = &tracepb.AttributeValue{
+			Value: &tracepb.AttributeValue_StringValue{
+				StringValue: &tracepb.TruncatableString{Value: value},
+			},
+		}
+	}
+	kep.attributes = attributes
+	kep.loadedAt = kep.now()
+}
@@ -0,0 +1,143 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8senricherprocessor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func writeMetadata(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func stringAttr(span *tracepb.Span, key string) string {
+	return span.GetAttributes().GetAttributeMap()[key].GetStringValue().GetValue()
+}
+
+func TestNewTraceProcessor(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	if _, err := NewTraceProcessor(nil); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(sink, WithMetadataDir(filepath.Join(os.TempDir(), "k8senricher-does-not-exist"))); err == nil {
+		t.Error("NewTraceProcessor() with a missing metadata directory should fail")
+	}
+}
+
+func TestEnrichment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8senricher")
+	if err != nil {
+		t.Fatalf("Failed to create the metadata directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeMetadata(t, dir, map[string]string{
+		"namespace": "payments\n",
+		"pod_name":  "api-7c9f-x2k4",
+		"node_name": "node-3",
+	})
+
+	sink := &exportertest.SinkTraceExporter{}
+	kep, err := NewTraceProcessor(sink, WithMetadataDir(dir))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+
+	withPodName := &tracepb.Span{
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"k8s.pod_name": {
+					Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "from-the-app"}},
+				},
+			},
+		},
+	}
+	td := data.TraceData{Spans: []*tracepb.Span{{}, withPodName, nil}}
+	if err := kep.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	spans := sink.AllTraces()[0].Spans
+	for key, want := range map[string]string{
+		"k8s.namespace": "payments",
+		"k8s.pod_name":  "api-7c9f-x2k4",
+		"k8s.node_name": "node-3",
+	} {
+		if got := stringAttr(spans[0], key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if got := stringAttr(spans[1], "k8s.pod_name"); got != "from-the-app" {
+		t.Errorf("The existing k8s.pod_name was overwritten with %q", got)
+	}
+	if got := stringAttr(spans[1], "k8s.namespace"); got != "payments" {
+		t.Errorf("k8s.namespace = %q, want %q", got, "payments")
+	}
+	if spans[2] != nil {
+		t.Errorf("A nil span should be passed through, got %v", spans[2])
+	}
+}
+
+func TestMetadataRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8senricher")
+	if err != nil {
+		t.Fatalf("Failed to create the metadata directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeMetadata(t, dir, map[string]string{"namespace": "staging", "node_name": "node-1"})
+
+	sink := &exportertest.SinkTraceExporter{}
+	tp, err := NewTraceProcessor(sink, WithMetadataDir(dir), WithRefreshInterval(time.Minute))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	kep := tp.(*k8senricherprocessor)
+	now := kep.loadedAt
+	kep.now = func() time.Time { return now }
+
+	consume := func() *tracepb.Span {
+		span := &tracepb.Span{}
+		kep.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span}})
+		return span
+	}
+
+	writeMetadata(t, dir, map[string]string{"namespace": "production"})
+	os.Remove(filepath.Join(dir, "node_name"))
+	if got := stringAttr(consume(), "k8s.namespace"); got != "staging" {
+		t.Errorf("Before the refresh k8s.namespace = %q, want the cached %q", got, "staging")
+	}
+
+	now = now.Add(time.Minute)
+	span := consume()
+	if got := stringAttr(span, "k8s.namespace"); got != "production" {
+		t.Errorf("After the refresh k8s.namespace = %q, want %q", got, "production")
+	}
+	if got := stringAttr(span, "k8s.node_name"); got != "node-1" {
+		t.Errorf("k8s.node_name = %q, want the previous value %q kept", got, "node-1")
+	}
+}
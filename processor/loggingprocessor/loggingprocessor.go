@@ -0,0 +1,104 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loggingprocessor logs the spans going through the pipeline, with
+// their trace and span IDs, so the logs of the collector can be correlated
+// with the traces exported.
+package loggingprocessor
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// Option is an option to the logging processor.
+type Option func(lp *loggingprocessor)
+
+// WithLevel sets the level the spans are logged at, it defaults to debug.
+func WithLevel(level zapcore.Level) Option {
+	return func(lp *loggingprocessor) {
+		lp.level = level
+	}
+}
+
+type loggingprocessor struct {
+	nextConsumer consumer.TraceConsumer
+	logger       *zap.Logger
+	level        zapcore.Level
+}
+
+var _ processor.TraceProcessor = (*loggingprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that logs a line per
+// span, with its trace_id and span_id as lowercase hex strings, the
+// representation used by the exporters, before passing the spans to
+// nextConsumer.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, logger *zap.Logger, opts ...Option) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if logger == nil {
+		return nil, errors.New("logger is nil")
+	}
+
+	lp := &loggingprocessor{
+		nextConsumer: nextConsumer,
+		logger:       logger,
+		level:        zapcore.DebugLevel,
+	}
+	for _, opt := range opts {
+		opt(lp)
+	}
+	return lp, nil
+}
+
+func (lp *loggingprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if lp.logger.Core().Enabled(lp.level) {
+		serviceName := td.Node.GetServiceInfo().GetName()
+		for _, span := range td.Spans {
+			if span != nil {
+				lp.logSpan(serviceName, span)
+			}
+		}
+	}
+	return lp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+func (lp *loggingprocessor) logSpan(serviceName string, span *tracepb.Span) {
+	ce := lp.logger.Check(lp.level, "Span")
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String("trace_id", hex.EncodeToString(span.TraceId)),
+		zap.String("span_id", hex.EncodeToString(span.SpanId)),
+	}
+	if len(span.ParentSpanId) > 0 {
+		fields = append(fields, zap.String("parent_span_id", hex.EncodeToString(span.ParentSpanId)))
+	}
+	fields = append(fields, zap.String("name", span.GetName().GetValue()))
+	if serviceName != "" {
+		fields = append(fields, zap.String("service_name", serviceName))
+	}
+	ce.Write(fields...)
+}
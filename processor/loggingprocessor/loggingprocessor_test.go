@@ -0,0 +1,126 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loggingprocessor
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	if _, err := NewTraceProcessor(nil, zap.NewNop()); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, nil); err == nil {
+		t.Error("NewTraceProcessor() with a nil logger should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, zap.NewNop()); err != nil {
+		t.Errorf("NewTraceProcessor() error = %v", err)
+	}
+}
+
+func TestLogsSpanIDs(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	sink := &exportertest.SinkTraceExporter{}
+	lp, err := NewTraceProcessor(sink, zap.New(core), WithLevel(zapcore.InfoLevel))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+
+	root := &tracepb.Span{
+		TraceId: []byte{0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19},
+		SpanId:  []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		Name:    &tracepb.TruncatableString{Value: "root"},
+	}
+	child := &tracepb.Span{
+		TraceId:      root.TraceId,
+		SpanId:       []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		ParentSpanId: root.SpanId,
+		Name:         &tracepb.TruncatableString{Value: "child"},
+	}
+	td := data.TraceData{
+		Node:  &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"}},
+		Spans: []*tracepb.Span{root, nil, child},
+	}
+	if err := lp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	exported := sink.AllTraces()
+	if len(exported) != 1 || len(exported[0].Spans) != 3 {
+		t.Fatalf("Got %v, want the spans passed to the next consumer", exported)
+	}
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("Got %d log entries, want 2", len(entries))
+	}
+	for i, span := range []*tracepb.Span{exported[0].Spans[0], exported[0].Spans[2]} {
+		entry := entries[i]
+		if entry.Level != zapcore.InfoLevel {
+			t.Errorf("Got level %v, want %v", entry.Level, zapcore.InfoLevel)
+		}
+		fields := entry.ContextMap()
+		if got, want := fields["trace_id"], hex.EncodeToString(span.TraceId); got != want {
+			t.Errorf("Got trace_id %v, want %v", got, want)
+		}
+		if got, want := fields["span_id"], hex.EncodeToString(span.SpanId); got != want {
+			t.Errorf("Got span_id %v, want %v", got, want)
+		}
+		if got, want := fields["name"], span.Name.Value; got != want {
+			t.Errorf("Got name %v, want %v", got, want)
+		}
+		if got := fields["service_name"]; got != "checkout" {
+			t.Errorf("Got service_name %v, want checkout", got)
+		}
+	}
+	if got := entries[0].ContextMap()["trace_id"]; got != "0a0b0c0d0e0f10111213141516171819" {
+		t.Errorf("Got trace_id %v, want the lowercase hex encoding", got)
+	}
+	if _, ok := entries[0].ContextMap()["parent_span_id"]; ok {
+		t.Error("Got a parent_span_id for the root span")
+	}
+	if got := entries[1].ContextMap()["parent_span_id"]; got != "0102030405060708" {
+		t.Errorf("Got parent_span_id %v, want 0102030405060708", got)
+	}
+}
+
+func TestDoesNotLogBelowLevel(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	sink := &exportertest.SinkTraceExporter{}
+	lp, _ := NewTraceProcessor(sink, zap.New(core))
+
+	td := data.TraceData{Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "span"}}}}
+	if err := lp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+	if n := logs.Len(); n != 0 {
+		t.Errorf("Got %d log entries at the default debug level, want none", n)
+	}
+	if n := len(sink.AllTraces()); n != 1 {
+		t.Errorf("Got %d batches, want 1", n)
+	}
+}
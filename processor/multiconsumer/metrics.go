@@ -0,0 +1,59 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multiconsumer
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+// Variables related to metrics specific to the fan-out to multiple consumers.
+var (
+	tagConsumerKey, _ = tag.NewKey("consumer")
+	tagPanickedKey, _ = tag.NewKey("panicked")
+
+	statConsumerErrorCount = stats.Int64("multiconsumer_consumer_errors", "Count of errors, including recovered panics, returned by each of the fanned-out consumers", stats.UnitDimensionless)
+)
+
+// MetricViews return the metrics views according to given telemetry level.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	consumerErrorCountView := &view.View{
+		Name:        statConsumerErrorCount.Name(),
+		Measure:     statConsumerErrorCount,
+		Description: statConsumerErrorCount.Description(),
+		TagKeys:     []tag.Key{tagConsumerKey, tagPanickedKey},
+		Aggregation: view.Sum(),
+	}
+	return []*view.View{consumerErrorCountView}
+}
+
+func recordConsumerError(ctx context.Context, consumerName string, panicked bool) {
+	panickedValue := "false"
+	if panicked {
+		panickedValue = "true"
+	}
+	_ = stats.RecordWithTags(ctx,
+		[]tag.Mutator{tag.Upsert(tagConsumerKey, consumerName), tag.Upsert(tagPanickedKey, panickedValue)},
+		statConsumerErrorCount.M(1))
+}
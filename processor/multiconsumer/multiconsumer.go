@@ -16,9 +16,18 @@ package multiconsumer
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
+	"github.com/golang/protobuf/proto"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter"
 	"github.com/census-instrumentation/opencensus-service/internal"
 	"github.com/census-instrumentation/opencensus-service/processor"
 )
@@ -33,14 +42,22 @@ type metricsConsumers []consumer.MetricsConsumer
 var _ processor.MetricsProcessor = (*metricsConsumers)(nil)
 
 // ConsumeMetricsData exports the MetricsData to all consumers wrapped by the current one.
+// A consumer that fails, even by panicking, or blocks does not prevent the
+// others from receiving the data.
 func (mcs metricsConsumers) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
-	var errs []error
-	for _, mdp := range mcs {
-		if err := mdp.ConsumeMetricsData(ctx, md); err != nil {
-			errs = append(errs, err)
+	consumes := make([]func() error, len(mcs))
+	for i, mdp := range mcs {
+		mdp, md := mdp, md
+		if i > 0 {
+			md = cloneMetricsData(md)
+		}
+		consumes[i] = func() error {
+			return isolate(ctx, metricsConsumerName(mdp), func() error {
+				return mdp.ConsumeMetricsData(ctx, md)
+			})
 		}
 	}
-	return internal.CombineErrors(errs)
+	return fanOut(consumes)
 }
 
 // NewTraceProcessor wraps multiple trace consumers in a single one.
@@ -53,12 +70,118 @@ type traceConsumers []consumer.TraceConsumer
 var _ processor.TraceProcessor = (*traceConsumers)(nil)
 
 // ConsumeTraceData exports the span data to all trace consumers wrapped by the current one.
+// A consumer that fails, even by panicking, or blocks does not prevent the
+// others from receiving the data.
 func (tcs traceConsumers) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
-	var errs []error
-	for _, tdp := range tcs {
-		if err := tdp.ConsumeTraceData(ctx, td); err != nil {
-			errs = append(errs, err)
+	consumes := make([]func() error, len(tcs))
+	for i, tdp := range tcs {
+		tdp, td := tdp, td
+		if i > 0 {
+			td = cloneTraceData(td)
+		}
+		consumes[i] = func() error {
+			return isolate(ctx, traceConsumerName(tdp), func() error {
+				return tdp.ConsumeTraceData(ctx, td)
+			})
+		}
+	}
+	return fanOut(consumes)
+}
+
+// fanOut calls every consume function in its own goroutine and returns once
+// all of them have returned, with their combined errors.
+//
+// The consumers run concurrently, each one on its own copy of the data, cloned
+// before any of them starts, since some of them, e.g. the Stackdriver
+// exporter, modify it in place.
+func fanOut(consumes []func() error) error {
+	if len(consumes) == 1 {
+		return consumes[0]()
+	}
+
+	errs := make([]error, len(consumes))
+	var wg sync.WaitGroup
+	wg.Add(len(consumes))
+	for i, consume := range consumes {
+		go func(i int, consume func() error) {
+			defer wg.Done()
+			errs[i] = consume()
+		}(i, consume)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return internal.CombineErrors(failed)
+}
+
+// isolate calls consume, turning a panic into an error, and records the
+// failure against the consumer.
+func isolate(ctx context.Context, consumerName string, consume func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("consumer %q panicked: %v", consumerName, r)
+			recordConsumerError(ctx, consumerName, true)
+		}
+	}()
+	if err = consume(); err != nil {
+		recordConsumerError(ctx, consumerName, false)
+	}
+	return err
+}
+
+func traceConsumerName(tc consumer.TraceConsumer) string {
+	if te, ok := tc.(exporter.TraceExporter); ok {
+		return te.TraceExportFormat()
+	}
+	return fmt.Sprintf("%T", tc)
+}
+
+func metricsConsumerName(mc consumer.MetricsConsumer) string {
+	if me, ok := mc.(exporter.MetricsExporter); ok {
+		return me.MetricsExportFormat()
+	}
+	return fmt.Sprintf("%T", mc)
+}
+
+func cloneTraceData(td data.TraceData) data.TraceData {
+	clone := data.TraceData{SourceFormat: td.SourceFormat}
+	if td.Node != nil {
+		clone.Node = proto.Clone(td.Node).(*commonpb.Node)
+	}
+	if td.Resource != nil {
+		clone.Resource = proto.Clone(td.Resource).(*resourcepb.Resource)
+	}
+	if td.Spans != nil {
+		clone.Spans = make([]*tracepb.Span, len(td.Spans))
+		for i, span := range td.Spans {
+			if span != nil {
+				clone.Spans[i] = proto.Clone(span).(*tracepb.Span)
+			}
+		}
+	}
+	return clone
+}
+
+func cloneMetricsData(md data.MetricsData) data.MetricsData {
+	var clone data.MetricsData
+	if md.Node != nil {
+		clone.Node = proto.Clone(md.Node).(*commonpb.Node)
+	}
+	if md.Resource != nil {
+		clone.Resource = proto.Clone(md.Resource).(*resourcepb.Resource)
+	}
+	if md.Metrics != nil {
+		clone.Metrics = make([]*metricspb.Metric, len(md.Metrics))
+		for i, metric := range md.Metrics {
+			if metric != nil {
+				clone.Metrics[i] = proto.Clone(metric).(*metricspb.Metric)
+			}
 		}
 	}
-	return internal.CombineErrors(errs)
+	return clone
 }
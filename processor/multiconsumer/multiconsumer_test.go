@@ -16,7 +16,9 @@ package multiconsumer
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
@@ -150,6 +152,165 @@ func TestMetricsProcessorWhenOneErrors(t *testing.T) {
 	}
 }
 
+func TestTraceProcessorWhenOnePanics(t *testing.T) {
+	processors := []consumer.TraceConsumer{
+		&mockTraceConsumer{},
+		panickingTraceConsumer{},
+		&mockTraceConsumer{},
+	}
+
+	tdp := NewTraceProcessor(processors)
+	td := data.TraceData{
+		Spans: make([]*tracepb.Span, 5),
+	}
+
+	var wantSpansCount = 0
+	for i := 0; i < 2; i++ {
+		wantSpansCount += len(td.Spans)
+		err := tdp.ConsumeTraceData(context.Background(), td)
+		if err == nil || !strings.Contains(err.Error(), "panicked") {
+			t.Errorf("Wanted a panic error got %v", err)
+			return
+		}
+	}
+
+	for _, i := range []int{0, 2} {
+		m := processors[i].(*mockTraceConsumer)
+		if m.TotalSpans != wantSpansCount {
+			t.Errorf("Wanted %d spans for processor #%d but got %d", wantSpansCount, i, m.TotalSpans)
+		}
+	}
+}
+
+func TestMetricsProcessorWhenOnePanics(t *testing.T) {
+	processors := []consumer.MetricsConsumer{
+		panickingMetricsConsumer{},
+		&mockMetricsConsumer{},
+	}
+
+	mdp := NewMetricsProcessor(processors)
+	md := data.MetricsData{
+		Metrics: make([]*metricspb.Metric, 5),
+	}
+
+	err := mdp.ConsumeMetricsData(context.Background(), md)
+	if err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("Wanted a panic error got %v", err)
+	}
+	if m := processors[1].(*mockMetricsConsumer); m.TotalMetrics != len(md.Metrics) {
+		t.Errorf("Wanted %d metrics but got %d", len(md.Metrics), m.TotalMetrics)
+	}
+}
+
+func TestTraceProcessorWhenOneBlocks(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan int, 1)
+	processors := []consumer.TraceConsumer{
+		blockingTraceConsumer{release: release},
+		notifyingTraceConsumer{received: received},
+	}
+
+	tdp := NewTraceProcessor(processors)
+	td := data.TraceData{
+		Spans: make([]*tracepb.Span, 5),
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- tdp.ConsumeTraceData(context.Background(), td)
+	}()
+
+	select {
+	case n := <-received:
+		if n != len(td.Spans) {
+			t.Errorf("Wanted %d spans but got %d", len(td.Spans), n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The consumer after the blocked one did not receive the spans")
+	}
+
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wanted nil got error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ConsumeTraceData did not return once the blocked consumer returned")
+	}
+}
+
+func TestTraceProcessorCopiesTheSpans(t *testing.T) {
+	received := make(chan int, 1)
+	var gotName string
+	processors := []consumer.TraceConsumer{
+		renamingTraceConsumer{},
+		checkingTraceConsumer{check: func(td data.TraceData) { gotName = td.Spans[0].Name.GetValue() }},
+		notifyingTraceConsumer{received: received},
+	}
+
+	tdp := NewTraceProcessor(processors)
+	td := data.TraceData{
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "original"}}, nil},
+	}
+	if err := tdp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("Wanted nil got error %v", err)
+	}
+	if gotName != "original" {
+		t.Errorf("Wanted the span named original but got %q", gotName)
+	}
+	if n := <-received; n != 2 {
+		t.Errorf("Wanted 2 spans but got %d", n)
+	}
+}
+
+type blockingTraceConsumer struct {
+	release chan struct{}
+}
+
+func (btc blockingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	<-btc.release
+	return nil
+}
+
+type notifyingTraceConsumer struct {
+	received chan int
+}
+
+func (ntc notifyingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	ntc.received <- len(td.Spans)
+	return nil
+}
+
+type renamingTraceConsumer struct{}
+
+func (renamingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	td.Spans[0].Name.Value = "renamed"
+	return nil
+}
+
+type checkingTraceConsumer struct {
+	check func(data.TraceData)
+}
+
+func (ctc checkingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	// Give the renaming consumer the time to modify its spans.
+	time.Sleep(10 * time.Millisecond)
+	ctc.check(td)
+	return nil
+}
+
+type panickingTraceConsumer struct{}
+
+func (panickingTraceConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	panic("boom")
+}
+
+type panickingMetricsConsumer struct{}
+
+func (panickingMetricsConsumer) ConsumeMetricsData(ctx context.Context, md data.MetricsData) error {
+	panic("boom")
+}
+
 type mockTraceConsumer struct {
 	TotalSpans int
 	MustFail   bool
@@ -0,0 +1,423 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package persistentbufferprocessor provides a processor that writes the spans
+// it receives to a log on disk before passing them on, so that the spans not
+// exported yet survive a crash or a restart of the process.
+package persistentbufferprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+const (
+	// DefaultMaxBytes is the default maximum size of the log on disk.
+	DefaultMaxBytes = 256 << 20
+	// DefaultRetryDelay is the default time waited before sending again the
+	// spans whose export failed.
+	DefaultRetryDelay = 5 * time.Second
+
+	segmentSuffix = ".wal"
+	ackFileName   = "ack"
+	// Each segment holds at most this fraction of the log, so that evicting
+	// the oldest one only drops a small part of the spans.
+	segmentsPerLog = 16
+)
+
+var (
+	errNilNextConsumer = errors.New("nextConsumer is nil")
+	errClosed          = errors.New("persistent buffer is closed")
+)
+
+// Option is an option to the PersistentBuffer.
+type Option func(*PersistentBuffer)
+
+// WithMaxBytes sets the maximum size of the log on disk. When it is reached
+// the oldest spans are evicted, whether they were exported or not.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(pb *PersistentBuffer) {
+		pb.maxBytes = maxBytes
+	}
+}
+
+// WithRetryDelay sets the time waited before sending again the spans whose
+// export failed.
+func WithRetryDelay(retryDelay time.Duration) Option {
+	return func(pb *PersistentBuffer) {
+		pb.retryDelay = retryDelay
+	}
+}
+
+// WithLogger sets the logger used to report the failed exports and the
+// evicted spans.
+func WithLogger(logger *zap.Logger) Option {
+	return func(pb *PersistentBuffer) {
+		pb.logger = logger
+	}
+}
+
+// segment is a file of the log, named after its id. The records are only
+// appended to the last segment.
+type segment struct {
+	id   uint64
+	size int64
+}
+
+// position identifies a record of the log.
+type position struct {
+	segmentID uint64
+	offset    int64
+}
+
+// PersistentBuffer is a processor.TraceProcessor appending the TraceData it
+// receives to a log on disk, made of segment files, and sending them from
+// there, one at a time and in order, to the next consumer. The position of
+// the last TraceData exported is saved after each export, and the fully
+// exported segments are deleted. When a PersistentBuffer is created on the
+// directory of a previous one, the TraceData it did not export are sent
+// again: the delivery is at least once, a crash right after an export makes
+// the TraceData be sent again.
+//
+// ConsumeTraceData returns once the TraceData is synced to disk, the errors
+// of the next consumer are logged and the TraceData retried until it succeeds.
+type PersistentBuffer struct {
+	dir          string
+	next         consumer.TraceConsumer
+	maxBytes     int64
+	segmentBytes int64
+	retryDelay   time.Duration
+	logger       *zap.Logger
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// segments are sorted by id, the last one is being written to by writer.
+	segments []*segment
+	writer   *os.File
+	// sendPos is the position of the next record to send, the records before
+	// it were exported.
+	sendPos position
+	closed  bool
+	done    chan struct{}
+	stopCh  chan struct{}
+}
+
+var _ processor.TraceProcessor = (*PersistentBuffer)(nil)
+
+// NewTraceProcessor creates a PersistentBuffer storing its log in dir and
+// starts sending the TraceData left unexported in it, if any, to
+// nextConsumer.
+func NewTraceProcessor(dir string, nextConsumer consumer.TraceConsumer, opts ...Option) (*PersistentBuffer, error) {
+	if nextConsumer == nil {
+		return nil, errNilNextConsumer
+	}
+
+	pb := &PersistentBuffer{
+		dir:        dir,
+		next:       nextConsumer,
+		maxBytes:   DefaultMaxBytes,
+		retryDelay: DefaultRetryDelay,
+		logger:     zap.NewNop(),
+		done:       make(chan struct{}),
+		stopCh:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(pb)
+	}
+	if pb.maxBytes <= 0 {
+		return nil, errors.New("the maximum size of the log must be positive")
+	}
+	pb.segmentBytes = pb.maxBytes / segmentsPerLog
+	pb.cond = sync.NewCond(&pb.mu)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := pb.open(); err != nil {
+		return nil, err
+	}
+	go pb.send()
+	return pb, nil
+}
+
+// open loads the segments left by a previous PersistentBuffer and starts a
+// new segment to write to.
+func (pb *PersistentBuffer) open() error {
+	files, err := ioutil.ReadDir(pb.dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range files {
+		name := fi.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		pb.segments = append(pb.segments, &segment{id: id})
+	}
+	sort.Slice(pb.segments, func(i, j int) bool { return pb.segments[i].id < pb.segments[j].id })
+
+	pb.sendPos = readAckFile(pb.ackFilePath())
+	var kept []*segment
+	for _, seg := range pb.segments {
+		if seg.id < pb.sendPos.segmentID {
+			os.Remove(pb.segmentPath(seg.id))
+			continue
+		}
+		// A crash while appending a record leaves it incomplete, the
+		// segment ends at the last complete one.
+		size, err := validSize(pb.segmentPath(seg.id))
+		if err != nil {
+			return err
+		}
+		seg.size = size
+		kept = append(kept, seg)
+	}
+	pb.segments = kept
+	if len(pb.segments) > 0 && pb.sendPos.segmentID < pb.segments[0].id {
+		pb.sendPos = position{segmentID: pb.segments[0].id}
+	}
+
+	nextID := pb.sendPos.segmentID
+	if len(pb.segments) > 0 {
+		nextID = pb.segments[len(pb.segments)-1].id + 1
+	}
+	if len(pb.segments) == 0 {
+		pb.sendPos = position{segmentID: nextID}
+	}
+	return pb.startSegment(nextID)
+}
+
+func (pb *PersistentBuffer) startSegment(id uint64) error {
+	f, err := os.OpenFile(pb.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if pb.writer != nil {
+		pb.writer.Close()
+	}
+	pb.writer = f
+	pb.segments = append(pb.segments, &segment{id: id})
+	return nil
+}
+
+func (pb *PersistentBuffer) segmentPath(id uint64) string {
+	return filepath.Join(pb.dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+func (pb *PersistentBuffer) ackFilePath() string {
+	return filepath.Join(pb.dir, ackFileName)
+}
+
+// ConsumeTraceData appends td to the log and returns once it is synced to
+// disk. The oldest TraceData are evicted if the log would exceed its maximum
+// size.
+func (pb *PersistentBuffer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	record, err := encodeRecord(td)
+	if err != nil {
+		return err
+	}
+	if int64(len(record)) > pb.maxBytes {
+		return fmt.Errorf("the %d bytes of the spans exceed the maximum size of the log", len(record))
+	}
+
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	if pb.closed {
+		return errClosed
+	}
+
+	current := pb.segments[len(pb.segments)-1]
+	if current.size > 0 && current.size+int64(len(record)) > pb.segmentBytes {
+		if err := pb.startSegment(current.id + 1); err != nil {
+			return err
+		}
+		current = pb.segments[len(pb.segments)-1]
+	}
+	pb.evict(int64(len(record)))
+
+	if _, err := pb.writer.Write(record); err != nil {
+		// Drop what was written of the record, so the next ones can be read.
+		pb.writer.Truncate(current.size)
+		pb.writer.Seek(current.size, 0)
+		return err
+	}
+	if err := pb.writer.Sync(); err != nil {
+		return err
+	}
+	current.size += int64(len(record))
+	pb.cond.Broadcast()
+	return nil
+}
+
+// evict deletes the oldest segments until n more bytes fit in the log. The
+// segment being written to is never evicted.
+func (pb *PersistentBuffer) evict(n int64) {
+	var total int64
+	for _, seg := range pb.segments {
+		total += seg.size
+	}
+	for total+n > pb.maxBytes && len(pb.segments) > 1 {
+		oldest := pb.segments[0]
+		pb.segments = pb.segments[1:]
+		total -= oldest.size
+		os.Remove(pb.segmentPath(oldest.id))
+		if pb.sendPos.segmentID == oldest.id {
+			pb.logger.Warn("Persistent buffer full, evicting unexported spans",
+				zap.String("dir", pb.dir), zap.Int64("bytes", oldest.size-pb.sendPos.offset))
+			pb.sendPos = position{segmentID: pb.segments[0].id}
+		}
+	}
+}
+
+// send exports the records of the log in order until the PersistentBuffer is
+// closed.
+func (pb *PersistentBuffer) send() {
+	defer close(pb.done)
+	for {
+		pos, td, n, ok := pb.nextRecord()
+		if !ok {
+			return
+		}
+		for {
+			err := pb.next.ConsumeTraceData(context.Background(), td)
+			if err == nil {
+				break
+			}
+			pb.logger.Warn("Failed to export the persisted spans, retrying",
+				zap.String("dir", pb.dir), zap.Int("spans", len(td.Spans)), zap.Error(err))
+			select {
+			case <-pb.stopCh:
+				return
+			case <-time.After(pb.retryDelay):
+			}
+		}
+		pb.acknowledge(pos, n)
+	}
+}
+
+// nextRecord waits for a record to send and returns it with its position and
+// size. It returns false once the PersistentBuffer is closed.
+func (pb *PersistentBuffer) nextRecord() (position, data.TraceData, int64, bool) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	for {
+		if pb.closed {
+			return position{}, data.TraceData{}, 0, false
+		}
+		seg := pb.segment(pb.sendPos.segmentID)
+		if seg != nil && pb.sendPos.offset < seg.size {
+			pos := pb.sendPos
+			td, n, err := readRecord(pb.segmentPath(seg.id), pos.offset)
+			if err == nil {
+				return pos, td, n, true
+			}
+			if n == 0 {
+				// The remaining records of the segment can't be found, it is
+				// skipped.
+				pb.logger.Error("Failed to read the persisted spans, skipping the rest of the segment",
+					zap.String("segment", pb.segmentPath(seg.id)), zap.Error(err))
+				n = seg.size - pos.offset
+			} else {
+				pb.logger.Error("Failed to decode the persisted spans, skipping them",
+					zap.String("segment", pb.segmentPath(seg.id)), zap.Error(err))
+			}
+			pb.advance(position{segmentID: pos.segmentID, offset: pos.offset + n})
+			continue
+		}
+		if seg != pb.segments[len(pb.segments)-1] {
+			// The segment was fully sent, move on to the next one.
+			pb.advance(position{segmentID: pb.segments[pb.segmentIndex(pb.sendPos.segmentID)+1].id})
+			continue
+		}
+		pb.cond.Wait()
+	}
+}
+
+// acknowledge records the export of the record of n bytes at pos, unless it
+// was evicted during the export.
+func (pb *PersistentBuffer) acknowledge(pos position, n int64) {
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	if pb.sendPos != pos {
+		return
+	}
+	pb.advance(position{segmentID: pos.segmentID, offset: pos.offset + n})
+}
+
+// advance moves sendPos to pos, deleting the segments fully exported, and
+// saves it.
+func (pb *PersistentBuffer) advance(pos position) {
+	for len(pb.segments) > 1 && pb.segments[0].id < pos.segmentID {
+		os.Remove(pb.segmentPath(pb.segments[0].id))
+		pb.segments = pb.segments[1:]
+	}
+	pb.sendPos = pos
+	if err := writeAckFile(pb.ackFilePath(), pos); err != nil {
+		pb.logger.Warn("Failed to save the position of the exported spans, they will be sent again after a restart",
+			zap.String("dir", pb.dir), zap.Error(err))
+	}
+}
+
+func (pb *PersistentBuffer) segment(id uint64) *segment {
+	if i := pb.segmentIndex(id); i >= 0 {
+		return pb.segments[i]
+	}
+	return nil
+}
+
+func (pb *PersistentBuffer) segmentIndex(id uint64) int {
+	for i, seg := range pb.segments {
+		if seg.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close stops sending the spans and closes the log. The spans not exported
+// yet are kept on disk, they are sent by the next PersistentBuffer created on
+// the same directory. An export in progress is waited for.
+func (pb *PersistentBuffer) Close() error {
+	pb.mu.Lock()
+	if pb.closed {
+		pb.mu.Unlock()
+		return errClosed
+	}
+	pb.closed = true
+	close(pb.stopCh)
+	pb.cond.Broadcast()
+	pb.mu.Unlock()
+
+	<-pb.done
+	return pb.writer.Close()
+}
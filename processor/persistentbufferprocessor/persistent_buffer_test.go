@@ -0,0 +1,277 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistentbufferprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := NewTraceProcessor(dir, nil); err != errNilNextConsumer {
+		t.Errorf("NewTraceProcessor() with a nil nextConsumer error = %v, want %v", err, errNilNextConsumer)
+	}
+	if _, err := NewTraceProcessor(dir, &exportertest.SinkTraceExporter{}, WithMaxBytes(0)); err == nil {
+		t.Error("NewTraceProcessor() with a zero maximum size should fail")
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "persistentbuffer")
+	if err != nil {
+		t.Fatalf("Failed to create a temporary directory: %v", err)
+	}
+	return dir
+}
+
+func traceData(name string) data.TraceData {
+	return data.TraceData{
+		Node:         &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "persisted"}},
+		Spans:        []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: name}}},
+		SourceFormat: "test",
+	}
+}
+
+// flakyConsumer exports the first numSuccesses TraceData then fails, the
+// names of the spans exported and of those attempted being recorded.
+type flakyConsumer struct {
+	mu           sync.Mutex
+	numSuccesses int
+	exported     []string
+	attempted    []string
+}
+
+func (fc *flakyConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	name := td.Spans[0].Name.Value
+	fc.attempted = append(fc.attempted, name)
+	if len(fc.exported) >= fc.numSuccesses {
+		return errors.New("backend unavailable")
+	}
+	fc.exported = append(fc.exported, name)
+	return nil
+}
+
+func (fc *flakyConsumer) numAttempts() int {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return len(fc.attempted)
+}
+
+// spanNames returns the names of the spans received by sink once the last one
+// is named last, or after a timeout.
+func spanNames(t *testing.T, sink *exportertest.SinkTraceExporter, last string) []string {
+	t.Helper()
+	var names []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		names = names[:0]
+		for _, td := range sink.AllTraces() {
+			if td.SourceFormat != "test" || td.Node.GetServiceInfo().GetName() != "persisted" {
+				t.Errorf("The node or source format of the TraceData was not preserved: %v", td)
+			}
+			for _, span := range td.Spans {
+				names = append(names, span.Name.Value)
+			}
+		}
+		if len(names) > 0 && names[len(names)-1] == last {
+			break
+		}
+	}
+	return names
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		t.Fatalf("Failed to list the segments: %v", err)
+	}
+	return files
+}
+
+func TestReplaysSpansAfterCrash(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// The first TraceData is exported, the export of the second one is in
+	// progress, failing, when the process crashes.
+	flaky := &flakyConsumer{numSuccesses: 1}
+	crashed, err := NewTraceProcessor(dir, flaky, WithRetryDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	defer crashed.Close()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		if err := crashed.ConsumeTraceData(context.Background(), traceData(name)); err != nil {
+			t.Fatalf("ConsumeTraceData() error = %v", err)
+		}
+	}
+	for deadline := time.Now().Add(5 * time.Second); flaky.numAttempts() < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("The persisted spans were not sent")
+		}
+	}
+
+	// The crash also interrupted the append of a record.
+	segments := segmentFiles(t, dir)
+	f, err := os.OpenFile(segments[len(segments)-1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open the last segment: %v", err)
+	}
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad})
+	f.Close()
+
+	// The spans are replayed by the buffer of the restarted process, without
+	// the one exported before the crash.
+	sink := &exportertest.SinkTraceExporter{}
+	restarted, err := NewTraceProcessor(dir, sink)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() after the crash error = %v", err)
+	}
+	if got, want := spanNames(t, sink, "e"), []string{"b", "c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Replayed spans %v, want %v", got, want)
+	}
+
+	// New spans are appended after the replayed ones.
+	if err := restarted.ConsumeTraceData(context.Background(), traceData("f")); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+	if got, want := spanNames(t, sink, "f"), []string{"b", "c", "d", "e", "f"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Exported spans %v, want %v", got, want)
+	}
+	if err := restarted.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Once exported the spans are not replayed again.
+	sink = &exportertest.SinkTraceExporter{}
+	reopened, err := NewTraceProcessor(dir, sink)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	defer reopened.Close()
+	time.Sleep(100 * time.Millisecond)
+	if got := sink.AllTraces(); len(got) != 0 {
+		t.Errorf("Exported spans were replayed: %v", got)
+	}
+}
+
+func TestDeletesExportedSegments(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	sink := &exportertest.SinkTraceExporter{}
+	// Segments of 1KiB hold a few records each, and the log is large enough
+	// for the spans not to be evicted.
+	pb, err := NewTraceProcessor(dir, sink, WithMaxBytes(1024*segmentsPerLog))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	defer pb.Close()
+
+	var want []string
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("span-%03d", i)
+		want = append(want, name)
+		if err := pb.ConsumeTraceData(context.Background(), traceData(name)); err != nil {
+			t.Fatalf("ConsumeTraceData() error = %v", err)
+		}
+	}
+	if got := spanNames(t, sink, want[len(want)-1]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Exported spans %v, want %v", got, want)
+	}
+	if segments := segmentFiles(t, dir); len(segments) > 2 {
+		t.Errorf("Got %d segments once all the spans were exported, want at most 2", len(segments))
+	}
+}
+
+func TestEvictsOldestSpansWhenFull(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	const maxBytes = 256 * segmentsPerLog
+	failing := &flakyConsumer{}
+	pb, err := NewTraceProcessor(dir, failing, WithMaxBytes(maxBytes), WithRetryDelay(time.Hour))
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	for i := 0; i < 300; i++ {
+		if err := pb.ConsumeTraceData(context.Background(), traceData(fmt.Sprintf("span-%04d", i))); err != nil {
+			t.Fatalf("ConsumeTraceData() error = %v", err)
+		}
+	}
+	pb.Close()
+
+	var size int64
+	for _, segment := range segmentFiles(t, dir) {
+		fi, err := os.Stat(segment)
+		if err != nil {
+			t.Fatalf("Failed to stat %q: %v", segment, err)
+		}
+		size += fi.Size()
+	}
+	if size > maxBytes {
+		t.Errorf("The log takes %d bytes, want at most %d", size, maxBytes)
+	}
+
+	sink := &exportertest.SinkTraceExporter{}
+	replayed, err := NewTraceProcessor(dir, sink)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	defer replayed.Close()
+	got := spanNames(t, sink, "span-0299")
+	if len(got) == 0 || len(got) >= 300 {
+		t.Fatalf("Replayed %d spans, want only the most recent ones", len(got))
+	}
+	if last := got[len(got)-1]; last != "span-0299" {
+		t.Errorf("Last replayed span = %q, want the last one received", last)
+	}
+	for i := 1; i < len(got); i++ {
+		if strings.Compare(got[i-1], got[i]) >= 0 {
+			t.Fatalf("Replayed spans out of order: %q before %q", got[i-1], got[i])
+		}
+	}
+}
+
+func TestConsumeAfterClose(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	pb, _ := NewTraceProcessor(dir, &exportertest.SinkTraceExporter{})
+	pb.Close()
+	if err := pb.ConsumeTraceData(context.Background(), traceData("late")); err != errClosed {
+		t.Errorf("ConsumeTraceData() after Close error = %v, want %v", err, errClosed)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package persistentbufferprocessor
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+)
+
+// A record of the log is made of:
+//   - the length of the payload, as a big endian uint32,
+//   - the CRC-32 (IEEE) of the payload, as a big endian uint32,
+//   - the payload: the length of the SourceFormat as a uvarint, the
+//     SourceFormat and the ExportTraceServiceRequest holding the node,
+//     resource and spans of the TraceData.
+const recordHeaderSize = 8
+
+var errCorruptRecord = errors.New("corrupt record")
+
+func encodeRecord(td data.TraceData) ([]byte, error) {
+	req, err := proto.Marshal(&agenttracepb.ExportTraceServiceRequest{
+		Node:     td.Node,
+		Resource: td.Resource,
+		Spans:    td.Spans,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(td.SourceFormat)+len(req))
+	payload = payload[:binary.PutUvarint(payload, uint64(len(td.SourceFormat)))]
+	payload = append(payload, td.SourceFormat...)
+	payload = append(payload, req...)
+
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	return append(record, payload...), nil
+}
+
+func decodePayload(payload []byte) (data.TraceData, error) {
+	n, l := binary.Uvarint(payload)
+	if l <= 0 || n > uint64(len(payload)-l) {
+		return data.TraceData{}, errCorruptRecord
+	}
+	sourceFormat := string(payload[l : l+int(n)])
+	req := new(agenttracepb.ExportTraceServiceRequest)
+	if err := proto.Unmarshal(payload[l+int(n):], req); err != nil {
+		return data.TraceData{}, err
+	}
+	return data.TraceData{
+		Node:         req.Node,
+		Resource:     req.Resource,
+		Spans:        req.Spans,
+		SourceFormat: sourceFormat,
+	}, nil
+}
+
+// readRecord reads the record at offset in the segment at path, and returns
+// it with its size. If the payload is intact but can't be decoded the size is
+// returned along with the error, so that the record can be skipped. A zero
+// size means that the record boundaries are unknown.
+func readRecord(path string, offset int64) (data.TraceData, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return data.TraceData{}, 0, err
+	}
+	defer f.Close()
+
+	var header [recordHeaderSize]byte
+	if _, err := f.ReadAt(header[:], offset); err != nil {
+		return data.TraceData{}, 0, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := f.ReadAt(payload, offset+recordHeaderSize); err != nil {
+		return data.TraceData{}, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return data.TraceData{}, 0, errCorruptRecord
+	}
+	size := int64(recordHeaderSize + len(payload))
+	td, err := decodePayload(payload)
+	return td, size, err
+}
+
+// validSize returns the size of the complete and intact records at the start
+// of the segment at path.
+func validSize(path string) (int64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for rest := content; len(rest) >= recordHeaderSize; {
+		n := int(binary.BigEndian.Uint32(rest[0:4]))
+		if n > len(rest)-recordHeaderSize {
+			break
+		}
+		payload := rest[recordHeaderSize : recordHeaderSize+n]
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(rest[4:8]) {
+			break
+		}
+		size += int64(recordHeaderSize + n)
+		rest = rest[recordHeaderSize+n:]
+	}
+	return size, nil
+}
+
+// The ack file holds the position of the next record to send, as the segment
+// id and offset, both big endian uint64.
+
+func readAckFile(path string) position {
+	content, err := ioutil.ReadFile(path)
+	if err != nil || len(content) != 16 {
+		return position{}
+	}
+	return position{
+		segmentID: binary.BigEndian.Uint64(content[0:8]),
+		offset:    int64(binary.BigEndian.Uint64(content[8:16])),
+	}
+}
+
+// writeAckFile replaces the ack file atomically, so that a crash leaves
+// either the previous or the new position.
+func writeAckFile(path string, pos position) error {
+	var content [16]byte
+	binary.BigEndian.PutUint64(content[0:8], pos.segmentID)
+	binary.BigEndian.PutUint64(content[8:16], uint64(pos.offset))
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content[:], 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,55 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processorchain
+
+import (
+	"context"
+	"strconv"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+// Variables related to metrics specific to the processor chain.
+var (
+	tagStepKey, _ = tag.NewKey("step")
+
+	statProcessorErrors = stats.Int64("processor_errors_total", "Count of errors aborting a processor chain, by 1-based position of the failing processor", stats.UnitDimensionless)
+)
+
+// MetricViews return the metrics views according to given telemetry level.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	processorErrorsView := &view.View{
+		Name:        statProcessorErrors.Name(),
+		Measure:     statProcessorErrors,
+		Description: statProcessorErrors.Description(),
+		TagKeys:     []tag.Key{tagStepKey},
+		Aggregation: view.Sum(),
+	}
+	return []*view.View{processorErrorsView}
+}
+
+func recordStepError(ctx context.Context, step int) {
+	_ = stats.RecordWithTags(ctx,
+		[]tag.Mutator{tag.Upsert(tagStepKey, strconv.Itoa(step))},
+		statProcessorErrors.M(1))
+}
@@ -0,0 +1,60 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package processorchain provides a TraceProcessor that runs a sequence of
+// processors over the same data, stopping at the first one that fails.
+package processorchain
+
+import (
+	"context"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// Chain calls each of its processors in order with the same TraceData. Unlike
+// the fan-out done by multiconsumer, the first processor returning an error
+// aborts the chain: the processors after it are not called and the error is
+// returned to the caller.
+type Chain struct {
+	processors []processor.TraceProcessor
+}
+
+var _ processor.TraceProcessor = (*Chain)(nil)
+
+// NewTraceProcessor creates a Chain calling the given processors in order.
+func NewTraceProcessor(processors []processor.TraceProcessor) *Chain {
+	return &Chain{processors: append([]processor.TraceProcessor(nil), processors...)}
+}
+
+// Append adds p at the end of the chain and returns the chain, so that calls
+// can be chained while building it. It must not be called concurrently with
+// ConsumeTraceData.
+func (c *Chain) Append(p processor.TraceProcessor) *Chain {
+	c.processors = append(c.processors, p)
+	return c
+}
+
+// ConsumeTraceData passes td to each processor of the chain in order. If one
+// of them fails its error is recorded, tagged with its 1-based position in the
+// chain, and returned without calling the remaining processors.
+func (c *Chain) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	for i, p := range c.processors {
+		if err := p.ConsumeTraceData(ctx, td); err != nil {
+			recordStepError(ctx, i+1)
+			return err
+		}
+	}
+	return nil
+}
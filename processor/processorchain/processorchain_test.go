@@ -0,0 +1,112 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processorchain
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+type mockProcessor struct {
+	calls int
+	err   error
+}
+
+var _ processor.TraceProcessor = (*mockProcessor)(nil)
+
+func (mp *mockProcessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	mp.calls++
+	return mp.err
+}
+
+func stepErrors(t *testing.T, step string) int64 {
+	rows, err := view.RetrieveData(statProcessorErrors.Name())
+	if err != nil {
+		t.Fatalf("view.RetrieveData() error = %v", err)
+	}
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == tagStepKey && tg.Value == step {
+				return int64(row.Data.(*view.SumData).Value)
+			}
+		}
+	}
+	return 0
+}
+
+func TestChainAbortsOnError(t *testing.T) {
+	views := MetricViews(telemetry.Normal)
+	if err := view.Register(views...); err != nil {
+		t.Fatalf("view.Register() error = %v", err)
+	}
+	defer view.Unregister(views...)
+
+	wantErr := errors.New("processor #2 failed")
+	p1 := &mockProcessor{}
+	p2 := &mockProcessor{err: wantErr}
+	p3 := &mockProcessor{}
+	chain := NewTraceProcessor([]processor.TraceProcessor{p1, p2, p3})
+
+	if err := chain.ConsumeTraceData(context.Background(), data.TraceData{}); err != wantErr {
+		t.Fatalf("ConsumeTraceData() error = %v, want %v", err, wantErr)
+	}
+	if p1.calls != 1 || p2.calls != 1 {
+		t.Errorf("processors #1 and #2 called %d and %d times, want 1 each", p1.calls, p2.calls)
+	}
+	if p3.calls != 0 {
+		t.Errorf("processor #3 called %d times after #2 failed, want 0", p3.calls)
+	}
+	if got := stepErrors(t, "2"); got != 1 {
+		t.Errorf("processor_errors_total{step=\"2\"} = %d, want 1", got)
+	}
+	if got := stepErrors(t, "3"); got != 0 {
+		t.Errorf("processor_errors_total{step=\"3\"} = %d, want 0", got)
+	}
+}
+
+func TestChainAppend(t *testing.T) {
+	p1 := &mockProcessor{}
+	p2 := &mockProcessor{}
+	chain := NewTraceProcessor(nil)
+	if got := chain.Append(p1).Append(p2); got != chain {
+		t.Fatalf("Append() = %p, want the chain itself %p", got, chain)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := chain.ConsumeTraceData(context.Background(), data.TraceData{}); err != nil {
+			t.Fatalf("ConsumeTraceData() error = %v", err)
+		}
+	}
+	if p1.calls != 2 || p2.calls != 2 {
+		t.Errorf("processors called %d and %d times, want 2 each", p1.calls, p2.calls)
+	}
+}
+
+func TestChainDoesNotAliasInput(t *testing.T) {
+	processors := make([]processor.TraceProcessor, 1, 2)
+	processors[0] = &mockProcessor{}
+	chain := NewTraceProcessor(processors)
+	chain.Append(&mockProcessor{})
+	if processors[:2][1] != nil {
+		t.Error("Append() wrote to the slice given to NewTraceProcessor")
+	}
+}
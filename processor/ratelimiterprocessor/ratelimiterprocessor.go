@@ -0,0 +1,152 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimiterprocessor caps the rate of spans passed to the next
+// consumer, protecting the exporters from bursts of traffic.
+package ratelimiterprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+const defaultWarnInterval = 10 * time.Second
+
+// TraceProcessor is a processor.TraceProcessor whose limit can be changed
+// while it is running.
+type TraceProcessor interface {
+	processor.TraceProcessor
+
+	// Update changes the number of spans per second and the burst allowed.
+	// It returns an error, keeping the current limit, if either isn't
+	// positive.
+	Update(spansPerSecond float64, burst int) error
+}
+
+var errInvalidLimit = errors.New("the rate of spans and the burst must be positive")
+
+// Option is an option to the rate limiter processor.
+type Option func(rlp *ratelimiterprocessor)
+
+// WithLogger sets the logger used to warn, at most once per warnInterval,
+// that spans are being dropped.
+func WithLogger(logger *zap.Logger, warnInterval time.Duration) Option {
+	return func(rlp *ratelimiterprocessor) {
+		rlp.logger = logger
+		if warnInterval > 0 {
+			rlp.warnInterval = warnInterval
+		}
+	}
+}
+
+type ratelimiterprocessor struct {
+	nextConsumer consumer.TraceConsumer
+	logger       *zap.Logger
+	warnInterval time.Duration
+
+	limiter *rate.Limiter
+
+	warnMu      sync.Mutex
+	lastWarning time.Time
+	dropped     int64
+}
+
+var _ TraceProcessor = (*ratelimiterprocessor)(nil)
+
+// NewTraceProcessor returns a TraceProcessor that passes at most
+// spansPerSecond spans, allowing bursts of up to burst spans, to
+// nextConsumer. The spans over the limit are dropped.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, spansPerSecond float64, burst int, opts ...Option) (TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if spansPerSecond <= 0 || burst <= 0 {
+		return nil, errInvalidLimit
+	}
+
+	rlp := &ratelimiterprocessor{
+		nextConsumer: nextConsumer,
+		warnInterval: defaultWarnInterval,
+		limiter:      rate.NewLimiter(rate.Limit(spansPerSecond), burst),
+	}
+	for _, opt := range opts {
+		opt(rlp)
+	}
+	return rlp, nil
+}
+
+func (rlp *ratelimiterprocessor) Update(spansPerSecond float64, burst int) error {
+	if spansPerSecond <= 0 || burst <= 0 {
+		return errInvalidLimit
+	}
+	// The tokens accumulated so far are kept, up to the new burst.
+	now := time.Now()
+	rlp.limiter.SetLimitAt(now, rate.Limit(spansPerSecond))
+	rlp.limiter.SetBurstAt(now, burst)
+	return nil
+}
+
+func (rlp *ratelimiterprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	now := time.Now()
+	if rlp.limiter.AllowN(now, len(td.Spans)) {
+		return rlp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+
+	// Not enough tokens for the whole batch, pass what the limit allows.
+	allowedSpans := make([]*tracepb.Span, 0, len(td.Spans))
+	for _, span := range td.Spans {
+		if !rlp.limiter.AllowN(now, 1) {
+			break
+		}
+		allowedSpans = append(allowedSpans, span)
+	}
+	rlp.recordDropped(ctx, len(td.Spans)-len(allowedSpans))
+	if len(allowedSpans) == 0 {
+		return nil
+	}
+
+	td.Spans = allowedSpans
+	return rlp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+func (rlp *ratelimiterprocessor) recordDropped(ctx context.Context, numDropped int) {
+	recordSpansDropped(ctx, numDropped)
+	if rlp.logger == nil {
+		return
+	}
+
+	rlp.warnMu.Lock()
+	defer rlp.warnMu.Unlock()
+	rlp.dropped += int64(numDropped)
+	now := time.Now()
+	if now.Sub(rlp.lastWarning) < rlp.warnInterval {
+		return
+	}
+	rlp.logger.Warn("Dropping spans over the rate limit",
+		zap.Float64("spans-per-second", float64(rlp.limiter.Limit())),
+		zap.Int("burst", rlp.limiter.Burst()),
+		zap.Int64("dropped", rlp.dropped))
+	rlp.lastWarning = now
+	rlp.dropped = 0
+}
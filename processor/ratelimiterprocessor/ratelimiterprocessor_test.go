@@ -0,0 +1,145 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimiterprocessor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	if _, err := NewTraceProcessor(nil, 10, 10); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 0, 10); err == nil {
+		t.Error("NewTraceProcessor() with a zero rate should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 10, 0); err == nil {
+		t.Error("NewTraceProcessor() with a zero burst should fail")
+	}
+	if _, err := NewTraceProcessor(nopProcessor, 10, 10); err != nil {
+		t.Errorf("NewTraceProcessor() error = %v", err)
+	}
+}
+
+func numSpans(tds []data.TraceData) int {
+	n := 0
+	for _, td := range tds {
+		n += len(td.Spans)
+	}
+	return n
+}
+
+func batchOf(n int) data.TraceData {
+	spans := make([]*tracepb.Span, n)
+	for i := range spans {
+		spans[i] = &tracepb.Span{}
+	}
+	return data.TraceData{Spans: spans}
+}
+
+func TestRateLimitWithConcurrentSenders(t *testing.T) {
+	const (
+		spansPerSecond = 200
+		burst          = 20
+		numSenders     = 8
+		sendFor        = 500 * time.Millisecond
+	)
+	sink := &exportertest.SinkTraceExporter{}
+	rlp, _ := NewTraceProcessor(sink, spansPerSecond, burst)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	sent := make([]int, numSenders)
+	for i := 0; i < numSenders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for time.Since(start) < sendFor {
+				rlp.ConsumeTraceData(context.Background(), batchOf(3))
+				sent[i] += 3
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	totalSent := 0
+	for _, n := range sent {
+		totalSent += n
+	}
+	got := numSpans(sink.AllTraces())
+	maxAllowed := burst + int(elapsed.Seconds()*spansPerSecond) + 1
+	if got > maxAllowed {
+		t.Errorf("got %d spans passed in %v, want at most %d", got, elapsed, maxAllowed)
+	}
+	if got < burst {
+		t.Errorf("got %d spans passed, want at least the burst of %d", got, burst)
+	}
+	if totalSent <= got {
+		t.Errorf("expected spans to be dropped, sent %d and passed %d", totalSent, got)
+	}
+}
+
+func TestPartialBatchAndUpdate(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	rlp, _ := NewTraceProcessor(sink, 0.001, 5)
+
+	// Only the burst of the first batch fits the limit.
+	rlp.ConsumeTraceData(context.Background(), batchOf(8))
+	rlp.ConsumeTraceData(context.Background(), batchOf(8))
+	if got := numSpans(sink.AllTraces()); got != 5 {
+		t.Fatalf("got %d spans passed, want 5", got)
+	}
+
+	if err := rlp.Update(1000, 100); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	// The limiter is updated in place, its bucket fills up at the new rate.
+	time.Sleep(100 * time.Millisecond)
+	rlp.ConsumeTraceData(context.Background(), batchOf(50))
+	if got := numSpans(sink.AllTraces()); got != 55 {
+		t.Fatalf("got %d spans passed after the update, want 55", got)
+	}
+}
+
+func TestUpdateRejectsInvalidLimits(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	rlp, _ := NewTraceProcessor(sink, 1000, 10)
+
+	for _, limit := range []struct {
+		spansPerSecond float64
+		burst          int
+	}{{0, 10}, {-1, 10}, {1000, 0}, {1000, -1}} {
+		if err := rlp.Update(limit.spansPerSecond, limit.burst); err != errInvalidLimit {
+			t.Errorf("Update(%v, %d) = %v, want %v", limit.spansPerSecond, limit.burst, err, errInvalidLimit)
+		}
+	}
+
+	// The limit is unchanged, the whole burst still passes.
+	rlp.ConsumeTraceData(context.Background(), batchOf(10))
+	if got := numSpans(sink.AllTraces()); got != 10 {
+		t.Errorf("got %d spans passed after the rejected updates, want 10", got)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redisbufferprocessor provides a processor buffering the spans in a
+// Redis stream shared by a cluster of collectors, so that the spans received
+// by any of them can be exported by any other.
+package redisbufferprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+const (
+	// DefaultStream is the default key of the Redis stream.
+	DefaultStream = "ocservice:spans"
+	// DefaultGroup is the default name of the consumer group.
+	DefaultGroup = "ocservice"
+	// DefaultBlockTimeout is the default time a read of the stream waits for
+	// new entries.
+	DefaultBlockTimeout = time.Second
+	// DefaultRetryDelay is the default time waited before retrying a failed
+	// export or Redis command.
+	DefaultRetryDelay = 5 * time.Second
+
+	dialTimeout = 5 * time.Second
+	readCount   = 16
+
+	// The fields of the stream entries.
+	fieldTraceData    = "td"
+	fieldSourceFormat = "format"
+)
+
+var (
+	errNilNextConsumer = errors.New("nextConsumer is nil")
+	errMissingAddr     = errors.New("the address of the Redis server is missing")
+	errClosed          = errors.New("redis buffer is closed")
+)
+
+// Config holds the settings of the RedisBuffer.
+type Config struct {
+	// Addr is the host:port address of the Redis server.
+	Addr string `mapstructure:"addr"`
+	// Password if set, is used to authenticate to the Redis server.
+	Password string `mapstructure:"password"`
+	// Stream is the key of the Redis stream, DefaultStream by default.
+	Stream string `mapstructure:"stream"`
+	// Group is the consumer group shared by all the collectors exporting the
+	// spans of the stream, DefaultGroup by default.
+	Group string `mapstructure:"group"`
+	// Consumer is the name of this collector in the group, unique to it. It
+	// defaults to the host name.
+	Consumer string `mapstructure:"consumer"`
+	// MaxLen if positive, is the approximate number of entries the stream is
+	// trimmed to, the oldest ones being dropped whether exported or not.
+	MaxLen int64 `mapstructure:"max-len"`
+	// BlockTimeout is the time a read of the stream waits for new entries,
+	// DefaultBlockTimeout by default.
+	BlockTimeout time.Duration `mapstructure:"block-timeout"`
+	// RetryDelay is the time waited before retrying a failed export or Redis
+	// command, DefaultRetryDelay by default.
+	RetryDelay time.Duration `mapstructure:"retry-delay"`
+}
+
+// RedisBuffer is a processor.TraceProcessor adding the TraceData it receives
+// to a Redis stream, and sending the entries of the stream it reads as a
+// member of a consumer group to the next consumer. Each entry is read by a
+// single member of the group, whichever collector added it, and acknowledged
+// once exported. The entries read but not acknowledged when a collector
+// stops are read again by the next RedisBuffer with the same Consumer name,
+// the delivery is thus at least once.
+type RedisBuffer struct {
+	cfg    Config
+	next   consumer.TraceConsumer
+	logger *zap.Logger
+	client *redis.Client
+
+	closeOnce sync.Once
+	stopCh    chan struct{}
+	done      chan struct{}
+}
+
+var _ processor.TraceProcessor = (*RedisBuffer)(nil)
+var _ io.Closer = (*RedisBuffer)(nil)
+
+// NewTraceProcessor connects to the Redis server of cfg, creates the stream
+// and consumer group if needed and starts sending the entries of the stream
+// to nextConsumer.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, cfg Config, logger *zap.Logger) (*RedisBuffer, error) {
+	if nextConsumer == nil {
+		return nil, errNilNextConsumer
+	}
+	if cfg.Addr == "" {
+		return nil, errMissingAddr
+	}
+	if cfg.Stream == "" {
+		cfg.Stream = DefaultStream
+	}
+	if cfg.Group == "" {
+		cfg.Group = DefaultGroup
+	}
+	if cfg.Consumer == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("no consumer name and the host name is unknown: %v", err)
+		}
+		cfg.Consumer = hostname
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = DefaultBlockTimeout
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultRetryDelay
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:        cfg.Addr,
+		Password:    cfg.Password,
+		DialTimeout: dialTimeout,
+	})
+	// Starting at 0 lets the group read the entries added before it existed.
+	err := client.XGroupCreateMkStream(cfg.Stream, cfg.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		client.Close()
+		return nil, fmt.Errorf("failed to create the consumer group: %v", err)
+	}
+
+	rb := &RedisBuffer{
+		cfg:    cfg,
+		next:   nextConsumer,
+		logger: logger,
+		client: client,
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go rb.read()
+	return rb, nil
+}
+
+// ConsumeTraceData adds td to the stream.
+func (rb *RedisBuffer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	if rb.stopped() {
+		return errClosed
+	}
+	payload, err := proto.Marshal(&agenttracepb.ExportTraceServiceRequest{
+		Node:     td.Node,
+		Resource: td.Resource,
+		Spans:    td.Spans,
+	})
+	if err != nil {
+		return err
+	}
+	return rb.client.XAdd(&redis.XAddArgs{
+		Stream:       rb.cfg.Stream,
+		MaxLenApprox: rb.cfg.MaxLen,
+		Values: map[string]interface{}{
+			fieldTraceData:    payload,
+			fieldSourceFormat: td.SourceFormat,
+		},
+	}).Err()
+}
+
+// read sends the entries of the stream to the next consumer until the
+// RedisBuffer is closed. The entries delivered to this consumer but not
+// acknowledged, e.g. by a previous process, are read first.
+func (rb *RedisBuffer) read() {
+	defer close(rb.done)
+	startID := "0"
+	for !rb.stopped() {
+		args := &redis.XReadGroupArgs{
+			Group:    rb.cfg.Group,
+			Consumer: rb.cfg.Consumer,
+			Streams:  []string{rb.cfg.Stream, startID},
+			Count:    readCount,
+			// A negative Block doesn't block, the pending entries are
+			// read as they are.
+			Block: -1,
+		}
+		if startID == ">" {
+			args.Block = rb.cfg.BlockTimeout
+		}
+		streams, err := rb.client.XReadGroup(args).Result()
+		if err == redis.Nil {
+			// The block timeout elapsed without new entries.
+			continue
+		}
+		if err != nil {
+			if !rb.stopped() {
+				rb.logger.Warn("Failed to read the Redis stream, retrying",
+					zap.String("stream", rb.cfg.Stream), zap.Error(err))
+				rb.wait(rb.cfg.RetryDelay)
+			}
+			continue
+		}
+
+		var entries []redis.XMessage
+		for _, stream := range streams {
+			entries = append(entries, stream.Messages...)
+		}
+		if startID == "0" && len(entries) == 0 {
+			// All the pending entries were handled, read the new ones.
+			startID = ">"
+			continue
+		}
+		for _, entry := range entries {
+			if !rb.export(entry) {
+				return
+			}
+		}
+	}
+}
+
+// export sends the TraceData of entry to the next consumer, retrying until it
+// succeeds, and acknowledges it. It returns false if the RedisBuffer was
+// closed before the entry could be exported.
+func (rb *RedisBuffer) export(entry redis.XMessage) bool {
+	// An entry without values was trimmed from the stream while pending, it
+	// is only acknowledged.
+	if len(entry.Values) > 0 {
+		td, err := decodeEntry(entry.Values)
+		if err != nil {
+			rb.logger.Error("Failed to decode an entry of the Redis stream, dropping it",
+				zap.String("id", entry.ID), zap.Error(err))
+		} else {
+			for {
+				err := rb.next.ConsumeTraceData(context.Background(), td)
+				if err == nil {
+					break
+				}
+				rb.logger.Warn("Failed to export the spans of the Redis stream, retrying",
+					zap.String("id", entry.ID), zap.Int("spans", len(td.Spans)), zap.Error(err))
+				if !rb.wait(rb.cfg.RetryDelay) {
+					return false
+				}
+			}
+		}
+	}
+	for {
+		err := rb.client.XAck(rb.cfg.Stream, rb.cfg.Group, entry.ID).Err()
+		if err == nil {
+			return true
+		}
+		rb.logger.Warn("Failed to acknowledge an entry of the Redis stream, retrying",
+			zap.String("id", entry.ID), zap.Error(err))
+		if !rb.wait(rb.cfg.RetryDelay) {
+			return false
+		}
+	}
+}
+
+func (rb *RedisBuffer) stopped() bool {
+	select {
+	case <-rb.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait returns after d, or false as soon as the RedisBuffer is closed.
+func (rb *RedisBuffer) wait(d time.Duration) bool {
+	select {
+	case <-rb.stopCh:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Close stops reading the stream and closes the connections to Redis. The
+// read in progress is waited for, at most BlockTimeout, as well as its
+// export. The entries not acknowledged yet are read again by the next
+// RedisBuffer with the same Consumer.
+func (rb *RedisBuffer) Close() error {
+	err := errClosed
+	rb.closeOnce.Do(func() {
+		close(rb.stopCh)
+		<-rb.done
+		err = rb.client.Close()
+	})
+	return err
+}
+
+func decodeEntry(values map[string]interface{}) (data.TraceData, error) {
+	payload, _ := values[fieldTraceData].(string)
+	sourceFormat, _ := values[fieldSourceFormat].(string)
+	req := new(agenttracepb.ExportTraceServiceRequest)
+	if err := proto.Unmarshal([]byte(payload), req); err != nil {
+		return data.TraceData{}, err
+	}
+	return data.TraceData{
+		Node:         req.Node,
+		Resource:     req.Resource,
+		Spans:        req.Spans,
+		SourceFormat: sourceFormat,
+	}, nil
+}
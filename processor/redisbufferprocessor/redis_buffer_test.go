@@ -0,0 +1,210 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisbufferprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	if _, err := NewTraceProcessor(nil, Config{Addr: "localhost:6379"}, zap.NewNop()); err != errNilNextConsumer {
+		t.Errorf("NewTraceProcessor() with a nil nextConsumer error = %v, want %v", err, errNilNextConsumer)
+	}
+	if _, err := NewTraceProcessor(&exportertest.SinkTraceExporter{}, Config{}, zap.NewNop()); err != errMissingAddr {
+		t.Errorf("NewTraceProcessor() without an address error = %v, want %v", err, errMissingAddr)
+	}
+
+	mr := runRedis(t, "secret")
+	defer mr.Close()
+	if _, err := NewTraceProcessor(&exportertest.SinkTraceExporter{}, Config{Addr: mr.Addr(), Password: "wrong"}, zap.NewNop()); err == nil {
+		t.Error("NewTraceProcessor() with a wrong password should fail")
+	}
+}
+
+func TestExportsEachSpanOnceAcrossConsumers(t *testing.T) {
+	mr := runRedis(t, "secret")
+	defer mr.Close()
+
+	const numTraceData = 50
+	sinks := []*countingConsumer{{}, {}}
+	var buffers []*RedisBuffer
+	for i, sink := range sinks {
+		rb, err := NewTraceProcessor(sink, Config{
+			Addr:         mr.Addr(),
+			Password:     "secret",
+			Consumer:     fmt.Sprintf("node-%d", i),
+			BlockTimeout: 10 * time.Millisecond,
+		}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewTraceProcessor() error = %v", err)
+		}
+		defer rb.Close()
+		buffers = append(buffers, rb)
+	}
+
+	for i := 0; i < numTraceData; i++ {
+		// Each node adds to the stream the spans it receives.
+		rb := buffers[i%len(buffers)]
+		if err := rb.ConsumeTraceData(context.Background(), traceData(fmt.Sprintf("span-%d", i))); err != nil {
+			t.Fatalf("ConsumeTraceData() error = %v", err)
+		}
+	}
+
+	waitFor(t, func() bool { return sinks[0].count()+sinks[1].count() >= numTraceData })
+	for _, rb := range buffers {
+		if err := rb.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	}
+
+	seen := make(map[string]int)
+	for _, sink := range sinks {
+		for _, td := range sink.all() {
+			if td.SourceFormat != "test" {
+				t.Errorf("SourceFormat = %q, want %q", td.SourceFormat, "test")
+			}
+			seen[td.Spans[0].Name.Value]++
+		}
+	}
+	for i := 0; i < numTraceData; i++ {
+		name := fmt.Sprintf("span-%d", i)
+		if seen[name] != 1 {
+			t.Errorf("%s exported %d times, want once", name, seen[name])
+		}
+	}
+	if pending := numPending(t, mr, "secret"); pending != 0 {
+		t.Errorf("%d entries still pending, want 0", pending)
+	}
+}
+
+func TestRereadsPendingEntries(t *testing.T) {
+	mr := runRedis(t, "")
+	defer mr.Close()
+	cfg := Config{
+		Addr:         mr.Addr(),
+		Consumer:     "node-0",
+		BlockTimeout: 10 * time.Millisecond,
+		RetryDelay:   10 * time.Millisecond,
+	}
+
+	failing := exportertest.NewNopTraceExporter(exportertest.WithReturnError(errors.New("backend unavailable")))
+	rb, err := NewTraceProcessor(failing, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	if err := rb.ConsumeTraceData(context.Background(), traceData("span-0")); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+	waitFor(t, func() bool { return numPending(t, mr, "") == 1 })
+	if err := rb.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := rb.ConsumeTraceData(context.Background(), traceData("span-1")); err != errClosed {
+		t.Errorf("ConsumeTraceData() after Close() error = %v, want %v", err, errClosed)
+	}
+
+	// A buffer with the same consumer name reads the entry again.
+	sink := &countingConsumer{}
+	rb, err = NewTraceProcessor(sink, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	defer rb.Close()
+	waitFor(t, func() bool { return sink.count() == 1 && numPending(t, mr, "") == 0 })
+	if got := sink.all()[0].Spans[0].Name.Value; got != "span-0" {
+		t.Errorf("Exported %q, want %q", got, "span-0")
+	}
+}
+
+func traceData(name string) data.TraceData {
+	return data.TraceData{
+		Node:         &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "buffered"}},
+		Spans:        []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: name}}},
+		SourceFormat: "test",
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the spans to be exported")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type countingConsumer struct {
+	mu     sync.Mutex
+	traces []data.TraceData
+}
+
+func (cc *countingConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.traces = append(cc.traces, td)
+	return nil
+}
+
+func (cc *countingConsumer) count() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.traces)
+}
+
+func (cc *countingConsumer) all() []data.TraceData {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return append([]data.TraceData(nil), cc.traces...)
+}
+
+// runRedis runs an in-memory Redis server, requiring password if not empty.
+func runRedis(t *testing.T, password string) *miniredis.Miniredis {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to run the Redis server: %v", err)
+	}
+	if password != "" {
+		mr.RequireAuth(password)
+	}
+	return mr
+}
+
+// numPending returns the number of entries of the default stream delivered to
+// the default group and not acknowledged yet.
+func numPending(t *testing.T, mr *miniredis.Miniredis, password string) int64 {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), Password: password})
+	defer client.Close()
+	pending, err := client.XPending(DefaultStream, DefaultGroup).Result()
+	if err != nil {
+		t.Fatalf("XPENDING failed: %v", err)
+	}
+	return pending.Count
+}
@@ -0,0 +1,168 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routerprocessor splits the spans it receives among different
+// destinations according to their attributes.
+package routerprocessor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// Route sends the spans having a given attribute to a destination.
+type Route struct {
+	// Name identifies the route in errors.
+	Name string
+	// Key of the attribute the route matches on.
+	Key string
+	// Values if not empty, restricts the route to the spans whose attribute,
+	// in its string form, is one of them. Otherwise having the attribute is
+	// enough for a span to match.
+	Values []string
+	// FallThrough if set, the routes after this one are still evaluated for
+	// the spans it matched, so they can be sent to multiple destinations.
+	FallThrough bool
+	// Destination receives the spans matching the route.
+	Destination consumer.TraceConsumer
+}
+
+type compiledRoute struct {
+	Route
+	values map[string]bool
+}
+
+type routerprocessor struct {
+	routes          []compiledRoute
+	defaultConsumer consumer.TraceConsumer
+}
+
+var _ processor.TraceProcessor = (*routerprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that evaluates the
+// routes in order for each span and sends it to the destinations of the
+// routes it matched, each destination getting its own copy of the span.
+// The spans matching no route are sent to defaultConsumer.
+func NewTraceProcessor(defaultConsumer consumer.TraceConsumer, routes ...Route) (processor.TraceProcessor, error) {
+	if defaultConsumer == nil {
+		return nil, errors.New("defaultConsumer is nil")
+	}
+
+	compiled := make([]compiledRoute, 0, len(routes))
+	for _, route := range routes {
+		if route.Key == "" {
+			return nil, fmt.Errorf("route %q: missing attribute key", route.Name)
+		}
+		if route.Destination == nil {
+			return nil, fmt.Errorf("route %q: destination is nil", route.Name)
+		}
+		cr := compiledRoute{Route: route}
+		if len(route.Values) > 0 {
+			cr.values = make(map[string]bool, len(route.Values))
+			for _, value := range route.Values {
+				cr.values[value] = true
+			}
+		}
+		compiled = append(compiled, cr)
+	}
+
+	return &routerprocessor{
+		routes:          compiled,
+		defaultConsumer: defaultConsumer,
+	}, nil
+}
+
+func (rp *routerprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	routedSpans := make([][]*tracepb.Span, len(rp.routes))
+	var defaultSpans []*tracepb.Span
+	for _, span := range td.Spans {
+		matched := false
+		for i, route := range rp.routes {
+			if !route.matches(span) {
+				continue
+			}
+			if matched {
+				// Destinations may modify the spans, so they can't share them.
+				span = proto.Clone(span).(*tracepb.Span)
+			}
+			routedSpans[i] = append(routedSpans[i], span)
+			matched = true
+			if !route.FallThrough {
+				break
+			}
+		}
+		if !matched {
+			defaultSpans = append(defaultSpans, span)
+		}
+	}
+
+	var errs []error
+	for i, spans := range routedSpans {
+		if len(spans) == 0 {
+			continue
+		}
+		if err := rp.routes[i].Destination.ConsumeTraceData(ctx, withSpans(td, spans)); err != nil {
+			errs = append(errs, fmt.Errorf("route %q: %v", rp.routes[i].Name, err))
+		}
+	}
+	if len(defaultSpans) > 0 {
+		if err := rp.defaultConsumer.ConsumeTraceData(ctx, withSpans(td, defaultSpans)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return internal.CombineErrors(errs)
+}
+
+func withSpans(td data.TraceData, spans []*tracepb.Span) data.TraceData {
+	td.Spans = spans
+	return td
+}
+
+func (cr *compiledRoute) matches(span *tracepb.Span) bool {
+	if span == nil || span.Attributes == nil {
+		return false
+	}
+	value, ok := span.Attributes.AttributeMap[cr.Key]
+	if !ok {
+		return false
+	}
+	if cr.values == nil {
+		return true
+	}
+	return cr.values[attributeValueString(value)]
+}
+
+func attributeValueString(value *tracepb.AttributeValue) string {
+	switch v := value.GetValue().(type) {
+	case *tracepb.AttributeValue_StringValue:
+		return v.StringValue.GetValue()
+	case *tracepb.AttributeValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *tracepb.AttributeValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *tracepb.AttributeValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+	}
+	return ""
+}
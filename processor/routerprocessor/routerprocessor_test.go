@@ -0,0 +1,153 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routerprocessor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/processor/processortest"
+)
+
+func TestNewTraceProcessor(t *testing.T) {
+	nopProcessor := processortest.NewNopTraceProcessor(nil)
+	tests := []struct {
+		name           string
+		routes         []Route
+		wantErr        bool
+		nilDefaultDest bool
+	}{
+		{name: "no_routes"},
+		{name: "valid_routes", routes: []Route{
+			{Name: "errors", Key: "error", Values: []string{"true"}, Destination: nopProcessor},
+			{Name: "http", Key: "http.method", Destination: nopProcessor},
+		}},
+		{name: "missing_key", routes: []Route{{Name: "errors", Destination: nopProcessor}}, wantErr: true},
+		{name: "missing_destination", routes: []Route{{Name: "errors", Key: "error"}}, wantErr: true},
+		{name: "nil_default", nilDefaultDest: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.nilDefaultDest {
+				_, err = NewTraceProcessor(nil, tt.routes...)
+			} else {
+				_, err = NewTraceProcessor(nopProcessor, tt.routes...)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTraceProcessor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func spanWithAttributes(name string, attrs map[string]*tracepb.AttributeValue) *tracepb.Span {
+	return &tracepb.Span{
+		Name:       &tracepb.TruncatableString{Value: name},
+		Attributes: &tracepb.Span_Attributes{AttributeMap: attrs},
+	}
+}
+
+func boolValue(b bool) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{Value: &tracepb.AttributeValue_BoolValue{BoolValue: b}}
+}
+
+func stringValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: s}},
+	}
+}
+
+func spanNames(tds []data.TraceData) []string {
+	var names []string
+	for _, td := range tds {
+		for _, span := range td.Spans {
+			names = append(names, span.GetName().GetValue())
+		}
+	}
+	return names
+}
+
+func TestRouting(t *testing.T) {
+	alerting := &exportertest.SinkTraceExporter{}
+	all := &exportertest.SinkTraceExporter{}
+	defaultDest := &exportertest.SinkTraceExporter{}
+	rp, err := NewTraceProcessor(defaultDest,
+		Route{Name: "errors", Key: "error", Values: []string{"true"}, FallThrough: true, Destination: alerting},
+		Route{Name: "http", Key: "http.method", Destination: all},
+		Route{Name: "never", Key: "http.method", Destination: defaultDest},
+	)
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+
+	failedRequest := spanWithAttributes("failed-request", map[string]*tracepb.AttributeValue{
+		"error":       boolValue(true),
+		"http.method": stringValue("GET"),
+	})
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "svc"}}
+	td := data.TraceData{
+		Node: node,
+		Spans: []*tracepb.Span{
+			failedRequest,
+			spanWithAttributes("request", map[string]*tracepb.AttributeValue{
+				"error":       boolValue(false),
+				"http.method": stringValue("GET"),
+			}),
+			spanWithAttributes("failed-job", map[string]*tracepb.AttributeValue{
+				"error": boolValue(true),
+			}),
+			spanWithAttributes("job", nil),
+			nil,
+		},
+		SourceFormat: "test",
+	}
+	if err := rp.ConsumeTraceData(context.Background(), td); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	if got, want := spanNames(alerting.AllTraces()), []string{"failed-request", "failed-job"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("errors route got %v, want %v", got, want)
+	}
+	if got, want := spanNames(all.AllTraces()), []string{"failed-request", "request"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("http route got %v, want %v", got, want)
+	}
+	if got, want := spanNames(defaultDest.AllTraces()), []string{"job", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("default destination got %v, want %v", got, want)
+	}
+
+	// The span matching two routes is copied for the second one.
+	sentToAlerting := alerting.AllTraces()[0].Spans[0]
+	sentToAll := all.AllTraces()[0].Spans[0]
+	if sentToAlerting == sentToAll {
+		t.Error("The same span instance was sent to two destinations")
+	}
+	if !proto.Equal(sentToAlerting, failedRequest) || !proto.Equal(sentToAll, failedRequest) {
+		t.Errorf("The routed copies differ from the received span")
+	}
+
+	for _, td := range append(alerting.AllTraces(), all.AllTraces()...) {
+		if td.Node != node || td.SourceFormat != "test" {
+			t.Errorf("The node and source format were not kept: %v", td)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package swapprocessor allows replacing the consumer spans are passed to,
+// e.g. the pipeline built from a configuration file, while spans are flowing.
+package swapprocessor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// TraceProcessor is a processor.TraceProcessor whose next consumer can be
+// replaced while it is running.
+type TraceProcessor interface {
+	processor.TraceProcessor
+
+	// Swap makes nextConsumer receive all the spans from now on. It waits for
+	// the spans being passed to the previous consumer, for at most
+	// gracePeriod, and reports whether they were all done. The previous
+	// consumer can then be shut down.
+	Swap(nextConsumer consumer.TraceConsumer, gracePeriod time.Duration) (bool, error)
+}
+
+// generation is a consumer and the calls to it still in progress.
+type generation struct {
+	nextConsumer consumer.TraceConsumer
+	inFlight     sync.WaitGroup
+}
+
+type swapprocessor struct {
+	mu      sync.RWMutex
+	current *generation
+}
+
+var _ TraceProcessor = (*swapprocessor)(nil)
+
+// NewTraceProcessor returns a TraceProcessor passing the spans to
+// nextConsumer until it is swapped.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer) (TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	return &swapprocessor{
+		current: &generation{nextConsumer: nextConsumer},
+	}, nil
+}
+
+func (sp *swapprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	// The read lock only protects taking a reference to the current
+	// generation, so a slow consumer never delays a swap nor the spans
+	// passed to the new consumer.
+	sp.mu.RLock()
+	g := sp.current
+	g.inFlight.Add(1)
+	sp.mu.RUnlock()
+	defer g.inFlight.Done()
+
+	return g.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+func (sp *swapprocessor) Swap(nextConsumer consumer.TraceConsumer, gracePeriod time.Duration) (bool, error) {
+	if nextConsumer == nil {
+		return false, errors.New("nextConsumer is nil")
+	}
+
+	sp.mu.Lock()
+	previous := sp.current
+	sp.current = &generation{nextConsumer: nextConsumer}
+	sp.mu.Unlock()
+
+	// No call can start using the previous generation anymore, so the wait
+	// group only goes down from here.
+	done := make(chan struct{})
+	go func() {
+		previous.inFlight.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true, nil
+	case <-timer.C:
+		return false, nil
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package swapprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+// blockingConsumer blocks the calls to ConsumeTraceData until release is
+// closed.
+type blockingConsumer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingConsumer() *blockingConsumer {
+	return &blockingConsumer{
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+func (bc *blockingConsumer) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	select {
+	case bc.started <- struct{}{}:
+	default:
+	}
+	<-bc.release
+	return nil
+}
+
+func testTraceData(name string) data.TraceData {
+	return data.TraceData{Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: name}}}}
+}
+
+func TestNewTraceProcessor(t *testing.T) {
+	if _, err := NewTraceProcessor(nil); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+
+	sp, err := NewTraceProcessor(&exportertest.SinkTraceExporter{})
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	if _, err := sp.Swap(nil, time.Second); err == nil {
+		t.Error("Swap() with a nil nextConsumer should fail")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	before := &exportertest.SinkTraceExporter{}
+	after := &exportertest.SinkTraceExporter{}
+	sp, _ := NewTraceProcessor(before)
+
+	sp.ConsumeTraceData(context.Background(), testTraceData("before"))
+	if drained, err := sp.Swap(after, time.Second); !drained || err != nil {
+		t.Fatalf("Swap() = (%v, %v), want (true, nil)", drained, err)
+	}
+	sp.ConsumeTraceData(context.Background(), testTraceData("after"))
+
+	if got := before.AllTraces(); len(got) != 1 || got[0].Spans[0].Name.Value != "before" {
+		t.Errorf("The previous consumer got %v, want only the spans sent before the swap", got)
+	}
+	if got := after.AllTraces(); len(got) != 1 || got[0].Spans[0].Name.Value != "after" {
+		t.Errorf("The new consumer got %v, want only the spans sent after the swap", got)
+	}
+}
+
+func TestSwapWaitsForSpansInFlight(t *testing.T) {
+	before := newBlockingConsumer()
+	after := &exportertest.SinkTraceExporter{}
+	sp, _ := NewTraceProcessor(before)
+
+	go sp.ConsumeTraceData(context.Background(), testTraceData("in flight"))
+	<-before.started
+
+	swapped := make(chan bool)
+	go func() {
+		drained, _ := sp.Swap(after, time.Minute)
+		swapped <- drained
+	}()
+
+	// The spans sent during the grace period go to the new consumer without
+	// waiting for the previous one. They are sent concurrently, the ones sent
+	// before the swap being blocked as well.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(after.AllTraces()) == 0 && time.Now().Before(deadline) {
+		go sp.ConsumeTraceData(context.Background(), testTraceData("after"))
+		time.Sleep(time.Millisecond)
+	}
+	if len(after.AllTraces()) == 0 {
+		t.Fatal("The new consumer didn't receive any span")
+	}
+
+	select {
+	case <-swapped:
+		t.Fatal("Swap() returned while spans were still in flight")
+	default:
+	}
+
+	close(before.release)
+	if drained := <-swapped; !drained {
+		t.Error("Swap() reported spans still in flight once they were done")
+	}
+}
+
+func TestSwapGracePeriod(t *testing.T) {
+	before := newBlockingConsumer()
+	defer close(before.release)
+	sp, _ := NewTraceProcessor(before)
+
+	go sp.ConsumeTraceData(context.Background(), testTraceData("in flight"))
+	<-before.started
+
+	if drained, err := sp.Swap(&exportertest.SinkTraceExporter{}, 10*time.Millisecond); drained || err != nil {
+		t.Errorf("Swap() = (%v, %v), want (false, nil) once the grace period expired", drained, err)
+	}
+}
@@ -24,13 +24,16 @@ import (
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal/collector/sampling"
 	"github.com/census-instrumentation/opencensus-service/processor"
 )
 
 const (
 	// The constants below are tags used to read the configuration via viper.
-	samplingPercentageCfgTag = "sampling-percentage"
-	hashSeedCfgTag           = "hash-seed"
+	samplingPercentageCfgTag   = "sampling-percentage"
+	hashSeedCfgTag             = "hash-seed"
+	targetSpansPerSecondCfgTag = "target-spans-per-second"
+	minSampleFractionCfgTag    = "min-sample-fraction"
 
 	// The constants help translate user friendly percentages to numbers direct used in sampling.
 	numHashBuckets        = 0x4000 // Using a power of 2 to avoid division.
@@ -47,6 +50,11 @@ type TraceSamplerCfg struct {
 	// have different sampling rates: if they use the same seed all passing one layer may pass the other even if they have
 	// different sampling rates, configuring different seeds avoids that.
 	HashSeed uint32
+	// TargetSpansPerSecond if set, replaces SamplingPercentage by a percentage adjusted to the observed rate of spans
+	// so that about this number of spans per second is sampled.
+	TargetSpansPerSecond float64
+	// MinSampleFraction is the lowest fraction of the spans sampled when TargetSpansPerSecond is set.
+	MinSampleFraction float64
 }
 
 // InitFromViper updates TraceSamplerCfg according to the viper configuration.
@@ -60,6 +68,12 @@ func (tsc *TraceSamplerCfg) InitFromViper(v *viper.Viper) (*TraceSamplerCfg, err
 	if err := v.UnmarshalKey(hashSeedCfgTag, &tsc.HashSeed); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal %q: %v", hashSeedCfgTag, err)
 	}
+	if err := v.UnmarshalKey(targetSpansPerSecondCfgTag, &tsc.TargetSpansPerSecond); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %q: %v", targetSpansPerSecondCfgTag, err)
+	}
+	if err := v.UnmarshalKey(minSampleFractionCfgTag, &tsc.MinSampleFraction); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %q: %v", minSampleFractionCfgTag, err)
+	}
 	return tsc, nil
 }
 
@@ -67,6 +81,7 @@ type tracesamplerprocessor struct {
 	nextConsumer       consumer.TraceConsumer
 	scaledSamplingRate uint32
 	hashSeed           uint32
+	adaptiveSampler    *sampling.AdaptiveSampler
 }
 
 var _ processor.TraceProcessor = (*tracesamplerprocessor)(nil)
@@ -78,16 +93,25 @@ func NewTraceProcessor(nextConsumer consumer.TraceConsumer, cfg TraceSamplerCfg)
 		return nil, errors.New("nextConsumer is nil")
 	}
 
-	return &tracesamplerprocessor{
+	tsp := &tracesamplerprocessor{
 		nextConsumer: nextConsumer,
 		// Adjust sampling percentage on private so recalculations are avoided.
 		scaledSamplingRate: uint32(cfg.SamplingPercentage * percentageScaleFactor),
 		hashSeed:           cfg.HashSeed,
-	}, nil
+	}
+	if cfg.TargetSpansPerSecond > 0 {
+		tsp.adaptiveSampler = sampling.NewAdaptiveSampler(cfg.TargetSpansPerSecond, cfg.MinSampleFraction)
+	}
+	return tsp, nil
 }
 
 func (tsp *tracesamplerprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
 	scaledSamplingRate := tsp.scaledSamplingRate
+	if tsp.adaptiveSampler != nil {
+		// Trace IDs are still hashed, so all spans of a trace sampled while the probability doesn't change are
+		// kept together.
+		scaledSamplingRate = uint32(tsp.adaptiveSampler.Observe(len(td.Spans)) * numHashBuckets)
+	}
 	if scaledSamplingRate >= numHashBuckets {
 		return tsp.nextConsumer.ConsumeTraceData(ctx, td)
 	}
@@ -93,6 +93,19 @@ func TestTraceSamplerCfg_InitFromViper(t *testing.T) {
 				HashSeed:           1234,
 			},
 		},
+		{
+			name: "happy_path_adaptive",
+			genViperFn: func() *viper.Viper {
+				v := viper.New()
+				v.Set(targetSpansPerSecondCfgTag, 500)
+				v.Set(minSampleFractionCfgTag, 0.01)
+				return v
+			},
+			want: &TraceSamplerCfg{
+				TargetSpansPerSecond: 500,
+				MinSampleFraction:    0.01,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -164,6 +177,26 @@ func TestNewTraceProcessor(t *testing.T) {
 	}
 }
 
+func TestNewTraceProcessorAdaptive(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	tp, err := NewTraceProcessor(sink, TraceSamplerCfg{TargetSpansPerSecond: 1000, MinSampleFraction: 0.1})
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	if tp.(*tracesamplerprocessor).adaptiveSampler == nil {
+		t.Fatalf("Expected an adaptive sampler for a non-zero TargetSpansPerSecond")
+	}
+
+	// The rate is unknown until the first window ends, all spans are sampled.
+	const numSpans = 100
+	if err := tp.ConsumeTraceData(context.Background(), genRandomTestData(1, numSpans, "adaptive")[0]); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+	if got := len(sink.AllTraces()[0].Spans); got != numSpans {
+		t.Errorf("got %d spans sampled, want all %d", got, numSpans)
+	}
+}
+
 // Test_tracesamplerprocessor_SamplingPercentageRange checks for different sampling rates and ensures
 // that they are within acceptable deltas.
 func Test_tracesamplerprocessor_SamplingPercentageRange(t *testing.T) {
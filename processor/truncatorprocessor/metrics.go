@@ -0,0 +1,45 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truncatorprocessor
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/census-instrumentation/opencensus-service/internal/collector/telemetry"
+)
+
+var statAttributesTruncated = stats.Int64("truncator_attributes_truncated_total", "Count of span values truncated for exceeding the size limits", stats.UnitDimensionless)
+
+// MetricViews return the metrics views according to given telemetry level.
+func MetricViews(level telemetry.Level) []*view.View {
+	if level == telemetry.None {
+		return nil
+	}
+
+	attributesTruncatedView := &view.View{
+		Name:        statAttributesTruncated.Name(),
+		Measure:     statAttributesTruncated,
+		Description: statAttributesTruncated.Description(),
+		Aggregation: view.Sum(),
+	}
+	return []*view.View{attributesTruncatedView}
+}
+
+func recordAttributesTruncated(ctx context.Context, numTruncated int) {
+	stats.Record(ctx, statAttributesTruncated.M(int64(numTruncated)))
+}
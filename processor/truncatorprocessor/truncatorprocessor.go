@@ -0,0 +1,159 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package truncatorprocessor shortens the string values of spans exceeding
+// the size limits of the tracing backends.
+package truncatorprocessor
+
+import (
+	"context"
+	"errors"
+	"unicode/utf8"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/processor"
+)
+
+// DefaultSuffix is appended to the truncated values if no other suffix is
+// configured.
+const DefaultSuffix = "...[truncated]"
+
+// Config holds the limits of the truncator processor, a zero limit disables
+// the truncation of the respective values.
+type Config struct {
+	// MaxAttributeValueBytes is the maximum length of the string values of
+	// the attributes of spans, annotations and links.
+	MaxAttributeValueBytes int `mapstructure:"max-attribute-value-bytes"`
+	// MaxAnnotationMessageBytes is the maximum length of the descriptions of
+	// annotations.
+	MaxAnnotationMessageBytes int `mapstructure:"max-annotation-message-bytes"`
+	// Suffix is appended to the truncated values, within the limit. It
+	// defaults to DefaultSuffix.
+	Suffix *string `mapstructure:"suffix"`
+}
+
+type truncatorprocessor struct {
+	nextConsumer       consumer.TraceConsumer
+	maxAttributeBytes  int
+	maxAnnotationBytes int
+	suffix             string
+}
+
+var _ processor.TraceProcessor = (*truncatorprocessor)(nil)
+
+// NewTraceProcessor returns a processor.TraceProcessor that truncates the
+// string values longer than the limits in cfg. The values are cut at a rune
+// boundary and the TruncatedByteCount of their TruncatableString is
+// updated. The spans received are not modified, truncated copies are passed
+// to nextConsumer.
+func NewTraceProcessor(nextConsumer consumer.TraceConsumer, cfg Config) (processor.TraceProcessor, error) {
+	if nextConsumer == nil {
+		return nil, errors.New("nextConsumer is nil")
+	}
+	if cfg.MaxAttributeValueBytes < 0 || cfg.MaxAnnotationMessageBytes < 0 {
+		return nil, errors.New("the truncation limits can't be negative")
+	}
+
+	suffix := DefaultSuffix
+	if cfg.Suffix != nil {
+		suffix = *cfg.Suffix
+	}
+	return &truncatorprocessor{
+		nextConsumer:       nextConsumer,
+		maxAttributeBytes:  cfg.MaxAttributeValueBytes,
+		maxAnnotationBytes: cfg.MaxAnnotationMessageBytes,
+		suffix:             suffix,
+	}, nil
+}
+
+func (tp *truncatorprocessor) ConsumeTraceData(ctx context.Context, td data.TraceData) error {
+	var spans []*tracepb.Span
+	numTruncated := 0
+	for i, span := range td.Spans {
+		if span == nil || tp.truncateSpan(span, false) == 0 {
+			continue
+		}
+		if spans == nil {
+			// The spans can be shared with other pipelines, copy the ones
+			// that are truncated.
+			spans = append([]*tracepb.Span(nil), td.Spans...)
+		}
+		truncated := proto.Clone(span).(*tracepb.Span)
+		numTruncated += tp.truncateSpan(truncated, true)
+		spans[i] = truncated
+	}
+	if spans == nil {
+		return tp.nextConsumer.ConsumeTraceData(ctx, td)
+	}
+
+	recordAttributesTruncated(ctx, numTruncated)
+	td.Spans = spans
+	return tp.nextConsumer.ConsumeTraceData(ctx, td)
+}
+
+// truncateSpan returns the number of values of the span over the limits,
+// truncating them if apply is set.
+func (tp *truncatorprocessor) truncateSpan(span *tracepb.Span, apply bool) int {
+	n := tp.truncateAttributes(span.Attributes, apply)
+	for _, te := range span.GetTimeEvents().GetTimeEvent() {
+		if annotation := te.GetAnnotation(); annotation != nil {
+			n += tp.truncateString(annotation.Description, tp.maxAnnotationBytes, apply)
+			n += tp.truncateAttributes(annotation.Attributes, apply)
+		}
+	}
+	for _, link := range span.GetLinks().GetLink() {
+		n += tp.truncateAttributes(link.GetAttributes(), apply)
+	}
+	return n
+}
+
+func (tp *truncatorprocessor) truncateAttributes(attrs *tracepb.Span_Attributes, apply bool) int {
+	n := 0
+	for _, value := range attrs.GetAttributeMap() {
+		n += tp.truncateString(value.GetStringValue(), tp.maxAttributeBytes, apply)
+	}
+	return n
+}
+
+// truncateString returns 1 if the string is longer than maxBytes, and if
+// apply is set truncates it, 0 otherwise.
+func (tp *truncatorprocessor) truncateString(ts *tracepb.TruncatableString, maxBytes int, apply bool) int {
+	if ts == nil || maxBytes == 0 || len(ts.Value) <= maxBytes {
+		return 0
+	}
+	if apply {
+		original := len(ts.Value)
+		ts.Value = truncate(ts.Value, maxBytes, tp.suffix)
+		ts.TruncatedByteCount += int32(original - len(ts.Value))
+	}
+	return 1
+}
+
+// truncate returns the longest prefix of s ending at a rune boundary that,
+// with the suffix appended, fits in maxBytes. If the suffix alone doesn't
+// fit it is omitted.
+func truncate(s string, maxBytes int, suffix string) string {
+	if len(suffix) >= maxBytes {
+		suffix = ""
+	}
+	cut := maxBytes - len(suffix)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + suffix
+}
@@ -0,0 +1,177 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package truncatorprocessor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s        string
+		maxBytes int
+		suffix   string
+		want     string
+	}{
+		{s: "hello world", maxBytes: 8, suffix: "...", want: "hello..."},
+		{s: "hello world", maxBytes: 5, suffix: "", want: "hello"},
+		// The suffix doesn't fit, it is omitted.
+		{s: "hello world", maxBytes: 3, suffix: "...", want: "hel"},
+		// Each of these runes takes 3 bytes, cutting at 7 bytes would split one.
+		{s: "日本語のテキスト", maxBytes: 7, suffix: "", want: "日本"},
+		{s: "日本語のテキスト", maxBytes: 11, suffix: "…", want: "日本…"},
+		{s: "日本語のテキスト", maxBytes: 12, suffix: "…", want: "日本語…"},
+		// 2 bytes runes.
+		{s: "ééééé", maxBytes: 5, suffix: "", want: "éé"},
+		// A 4 bytes rune in the first position can't be kept at all.
+		{s: "😀😀", maxBytes: 3, suffix: "", want: ""},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.maxBytes, tt.suffix)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d, %q) = %q, want %q", tt.s, tt.maxBytes, tt.suffix, got, tt.want)
+		}
+		if !utf8.ValidString(got) || len(got) > tt.maxBytes {
+			t.Errorf("truncate(%q, %d, %q) = %q is not valid UTF-8 within %d bytes", tt.s, tt.maxBytes, tt.suffix, got, tt.maxBytes)
+		}
+	}
+}
+
+func stringValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: s}},
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	longValue := strings.Repeat("ü", 20) // 40 bytes
+	original := &tracepb.Span{
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"db.statement": stringValue(longValue),
+				"http.method":  stringValue("GET"),
+				"retries":      {Value: &tracepb.AttributeValue_IntValue{IntValue: 3}},
+			},
+		},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{
+				{
+					Value: &tracepb.Span_TimeEvent_Annotation_{
+						Annotation: &tracepb.Span_TimeEvent_Annotation{
+							Description: &tracepb.TruncatableString{Value: strings.Repeat("a", 30)},
+							Attributes: &tracepb.Span_Attributes{
+								AttributeMap: map[string]*tracepb.AttributeValue{"stack": stringValue(longValue)},
+							},
+						},
+					},
+				},
+			},
+		},
+		Links: &tracepb.Span_Links{
+			Link: []*tracepb.Span_Link{
+				{Attributes: &tracepb.Span_Attributes{
+					AttributeMap: map[string]*tracepb.AttributeValue{"reason": stringValue(longValue)},
+				}},
+			},
+		},
+	}
+	untouched := proto.Clone(original).(*tracepb.Span)
+	short := &tracepb.Span{
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{"http.method": stringValue("GET")},
+		},
+	}
+
+	sink := &exportertest.SinkTraceExporter{}
+	suffix := "~"
+	tp, err := NewTraceProcessor(sink, Config{
+		MaxAttributeValueBytes:    10,
+		MaxAnnotationMessageBytes: 20,
+		Suffix:                    &suffix,
+	})
+	if err != nil {
+		t.Fatalf("NewTraceProcessor() error = %v", err)
+	}
+	if err := tp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{original, short, nil}}); err != nil {
+		t.Fatalf("ConsumeTraceData() error = %v", err)
+	}
+
+	if !proto.Equal(original, untouched) {
+		t.Errorf("The received span was modified:\n%v", original)
+	}
+	spans := sink.AllTraces()[0].Spans
+	if spans[1] != short || spans[2] != nil {
+		t.Errorf("The spans without values to truncate should be passed as is")
+	}
+
+	truncated := spans[0]
+	wantAttribute := strings.Repeat("ü", 4) + "~" // 9 bytes, the next rune doesn't fit.
+	checkTruncated := func(name string, ts *tracepb.TruncatableString, want string, originalLen int) {
+		if ts.GetValue() != want {
+			t.Errorf("%s = %q, want %q", name, ts.GetValue(), want)
+		}
+		if got, want := ts.GetTruncatedByteCount(), int32(originalLen-len(want)); got != want {
+			t.Errorf("%s TruncatedByteCount = %d, want %d", name, got, want)
+		}
+	}
+	checkTruncated("db.statement", truncated.Attributes.AttributeMap["db.statement"].GetStringValue(), wantAttribute, len(longValue))
+	annotation := truncated.TimeEvents.TimeEvent[0].GetAnnotation()
+	checkTruncated("annotation description", annotation.Description, strings.Repeat("a", 19)+"~", 30)
+	checkTruncated("annotation stack", annotation.Attributes.AttributeMap["stack"].GetStringValue(), wantAttribute, len(longValue))
+	checkTruncated("link reason", truncated.Links.Link[0].Attributes.AttributeMap["reason"].GetStringValue(), wantAttribute, len(longValue))
+	checkTruncated("http.method", truncated.Attributes.AttributeMap["http.method"].GetStringValue(), "GET", 3)
+}
+
+func TestNewTraceProcessorDefaults(t *testing.T) {
+	sink := &exportertest.SinkTraceExporter{}
+	if _, err := NewTraceProcessor(nil, Config{}); err == nil {
+		t.Error("NewTraceProcessor() with a nil nextConsumer should fail")
+	}
+	if _, err := NewTraceProcessor(sink, Config{MaxAttributeValueBytes: -1}); err == nil {
+		t.Error("NewTraceProcessor() with a negative limit should fail")
+	}
+
+	tp, _ := NewTraceProcessor(sink, Config{MaxAttributeValueBytes: 20})
+	span := &tracepb.Span{
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{"k": stringValue(strings.Repeat("x", 30))},
+		},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{
+				{Value: &tracepb.Span_TimeEvent_Annotation_{Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Description: &tracepb.TruncatableString{Value: strings.Repeat("y", 100)},
+				}}},
+			},
+		},
+	}
+	tp.ConsumeTraceData(context.Background(), data.TraceData{Spans: []*tracepb.Span{span}})
+
+	got := sink.AllTraces()[0].Spans[0]
+	if v := got.Attributes.AttributeMap["k"].GetStringValue().GetValue(); v != "xxxxxx"+DefaultSuffix {
+		t.Errorf("got %q, want the value truncated with the default suffix", v)
+	}
+	if d := got.TimeEvents.TimeEvent[0].GetAnnotation().Description.GetValue(); len(d) != 100 {
+		t.Errorf("The annotation description was truncated without a limit: %q", d)
+	}
+}
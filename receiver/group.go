@@ -0,0 +1,114 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// ReceiverGroup runs several TraceReceivers, e.g. OpenCensus, Jaeger and
+// Zipkin ones each on its own listener, feeding the same pipeline. It is a
+// TraceReceiver itself, and closing it stops all of its receivers.
+type ReceiverGroup struct {
+	receivers []TraceReceiver
+}
+
+var _ TraceReceiver = (*ReceiverGroup)(nil)
+var _ io.Closer = (*ReceiverGroup)(nil)
+
+// NewReceiverGroup returns a ReceiverGroup of the given receivers. The
+// consumer of each receiver is set at its creation, as usual, so that they
+// feed the same pipeline if they were created with the same consumer.
+func NewReceiverGroup(receivers ...TraceReceiver) *ReceiverGroup {
+	return &ReceiverGroup{receivers: receivers}
+}
+
+// Receivers returns the receivers of the group.
+func (rg *ReceiverGroup) Receivers() []TraceReceiver {
+	return rg.receivers
+}
+
+// TraceSource returns the names of the trace data sources of the receivers.
+func (rg *ReceiverGroup) TraceSource() string {
+	sources := make([]string, 0, len(rg.receivers))
+	for _, r := range rg.receivers {
+		sources = append(sources, r.TraceSource())
+	}
+	return strings.Join(sources, ",")
+}
+
+// StartTraceReception starts all the receivers concurrently. If any of them
+// fails to start the ones that started are stopped and the errors returned.
+func (rg *ReceiverGroup) StartTraceReception(ctx context.Context, asyncErrorChannel chan<- error) error {
+	errs := rg.forEach(func(r TraceReceiver) error {
+		return r.StartTraceReception(ctx, asyncErrorChannel)
+	})
+
+	var started []TraceReceiver
+	var startErrs []error
+	for i, err := range errs {
+		if err != nil {
+			startErrs = append(startErrs, err)
+		} else {
+			started = append(started, rg.receivers[i])
+		}
+	}
+	if len(startErrs) > 0 {
+		NewReceiverGroup(started...).StopTraceReception(ctx)
+	}
+	return internal.CombineErrors(startErrs)
+}
+
+// StopTraceReception stops all the receivers in parallel and returns their
+// errors, if any.
+func (rg *ReceiverGroup) StopTraceReception(ctx context.Context) error {
+	errs := rg.forEach(func(r TraceReceiver) error {
+		return r.StopTraceReception(ctx)
+	})
+
+	var stopErrs []error
+	for _, err := range errs {
+		if err != nil {
+			stopErrs = append(stopErrs, err)
+		}
+	}
+	return internal.CombineErrors(stopErrs)
+}
+
+// Close stops all the receivers in parallel.
+func (rg *ReceiverGroup) Close() error {
+	return rg.StopTraceReception(context.Background())
+}
+
+// forEach calls fn for every receiver concurrently and returns the errors,
+// in the order of the receivers, once all the calls returned.
+func (rg *ReceiverGroup) forEach(fn func(TraceReceiver) error) []error {
+	errs := make([]error, len(rg.receivers))
+	var wg sync.WaitGroup
+	for i, r := range rg.receivers {
+		wg.Add(1)
+		go func(i int, r TraceReceiver) {
+			defer wg.Done()
+			errs[i] = fn(r)
+		}(i, r)
+	}
+	wg.Wait()
+	return errs
+}
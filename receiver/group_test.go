@@ -0,0 +1,209 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver"
+	"github.com/census-instrumentation/opencensus-service/receiver/zipkinreceiver"
+)
+
+var testNode = &commonpb.Node{Identifier: &commonpb.ProcessIdentifier{HostName: "testHost"}}
+
+func sendGRPC(t *testing.T, addr string) {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	stream, err := agenttracepb.NewTraceServiceClient(conn).Export(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start the export stream: %v", err)
+	}
+	err = stream.Send(&agenttracepb.ExportTraceServiceRequest{
+		Node:  testNode,
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "grpc"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to send the spans: %v", err)
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("Export stream ended with %v, want EOF", err)
+	}
+}
+
+func sendHTTP(t *testing.T, addr string) {
+	body, _ := proto.Marshal(&agenttracepb.ExportTraceServiceRequest{
+		Node:  testNode,
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "http"}}},
+	})
+	resp, err := http.Post(fmt.Sprintf("http://%s/v1/trace", addr), "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to post the spans: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Got status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func sendZipkin(t *testing.T, addr string) {
+	body := `[{"traceId": "4d1e00c0db9010db86154a4ba6e91385", "id": "86154a4ba6e91385", "name": "zipkin"}]`
+	resp, err := http.Post(fmt.Sprintf("http://%s/api/v2/spans", addr), "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("Failed to post the spans: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Got status %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestReceiverGroup(t *testing.T) {
+	sink := new(exportertest.SinkTraceExporter)
+
+	grpcAddr := testutils.GetAvailableLocalAddress(t)
+	grpcReceiver, err := opencensusreceiver.New(grpcAddr, sink, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the gRPC receiver: %v", err)
+	}
+	httpAddr := testutils.GetAvailableLocalAddress(t)
+	httpReceiver, err := opencensusreceiver.New(httpAddr, sink, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the HTTP receiver: %v", err)
+	}
+	zipkinAddr := testutils.GetAvailableLocalAddress(t)
+	zipkinReceiver, err := zipkinreceiver.New(zipkinAddr, sink)
+	if err != nil {
+		t.Fatalf("Failed to create the Zipkin receiver: %v", err)
+	}
+
+	rg := receiver.NewReceiverGroup(grpcReceiver, httpReceiver, zipkinReceiver)
+	asyncErrorChan := make(chan error, len(rg.Receivers()))
+	if err := rg.StartTraceReception(context.Background(), asyncErrorChan); err != nil {
+		t.Fatalf("StartTraceReception() error = %v", err)
+	}
+	defer rg.Close()
+
+	sendGRPC(t, grpcAddr)
+	sendHTTP(t, httpAddr)
+	sendZipkin(t, zipkinAddr)
+
+	// The OpenCensus receivers pass the spans to the sink asynchronously.
+	deadline := time.Now().Add(time.Second)
+	for len(sink.AllTraces()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var names []string
+	for _, td := range sink.AllTraces() {
+		for _, span := range td.Spans {
+			names = append(names, span.Name.GetValue())
+		}
+	}
+	sort.Strings(names)
+	if got, want := fmt.Sprint(names), "[grpc http zipkin]"; got != want {
+		t.Errorf("Got spans %s, want %s", got, want)
+	}
+}
+
+type fakeReceiver struct {
+	startErr error
+	stopped  bool
+	// stopBarrier, if set, blocks StopTraceReception until all the receivers
+	// sharing it are being stopped.
+	stopBarrier *sync.WaitGroup
+}
+
+func (fr *fakeReceiver) TraceSource() string { return "fake" }
+
+func (fr *fakeReceiver) StartTraceReception(ctx context.Context, asyncErrorChannel chan<- error) error {
+	return fr.startErr
+}
+
+func (fr *fakeReceiver) StopTraceReception(ctx context.Context) error {
+	if fr.stopBarrier != nil {
+		fr.stopBarrier.Done()
+		fr.stopBarrier.Wait()
+	}
+	fr.stopped = true
+	return nil
+}
+
+func TestReceiverGroupStartFailure(t *testing.T) {
+	startErr := errors.New("address already in use")
+	ok := &fakeReceiver{}
+	failing := &fakeReceiver{startErr: startErr}
+
+	rg := receiver.NewReceiverGroup(ok, failing)
+	if err := rg.StartTraceReception(context.Background(), nil); err != startErr {
+		t.Fatalf("StartTraceReception() error = %v, want %v", err, startErr)
+	}
+	if !ok.stopped {
+		t.Error("The receiver that started was not stopped")
+	}
+	if failing.stopped {
+		t.Error("The receiver that failed to start was stopped")
+	}
+}
+
+func TestReceiverGroupClosesInParallel(t *testing.T) {
+	var barrier sync.WaitGroup
+	receivers := make([]receiver.TraceReceiver, 3)
+	barrier.Add(len(receivers))
+	for i := range receivers {
+		receivers[i] = &fakeReceiver{stopBarrier: &barrier}
+	}
+
+	rg := receiver.NewReceiverGroup(receivers...)
+	closed := make(chan error)
+	go func() {
+		closed <- rg.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not stop the receivers in parallel")
+	}
+	for i, r := range receivers {
+		if !r.(*fakeReceiver).stopped {
+			t.Errorf("Receiver #%d was not stopped", i)
+		}
+	}
+}
@@ -15,12 +15,18 @@
 package jaegerreceiver
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"net"
+	"strconv"
 	"testing"
 	"time"
 
+	apachethrift "github.com/apache/thrift/lib/go/thrift"
 	"github.com/google/go-cmp/cmp"
+	"github.com/jaegertracing/jaeger/thrift-gen/agent"
+	jaegerthrift "github.com/jaegertracing/jaeger/thrift-gen/jaeger"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
 	"go.opencensus.io/trace"
@@ -30,6 +36,7 @@ import (
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
 	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
 )
 
 func TestJaegerAgentUDP_ThriftCompact_6831(t *testing.T) {
@@ -238,3 +245,87 @@ func testJaegerAgent(t *testing.T, agentEndpoint string, receiverConfig *Configu
 		t.Errorf("Mismatched responses\n-Got +Want:\n\t%s", diff)
 	}
 }
+
+// compactEmitBatch encodes the batch the way the Jaeger clients send it to
+// the agent compact Thrift UDP port.
+func compactEmitBatch(t *testing.T, batch *jaegerthrift.Batch) []byte {
+	buffer := apachethrift.NewTMemoryBuffer()
+	client := agent.NewAgentClientFactory(buffer, apachethrift.NewTCompactProtocolFactory())
+	if err := client.EmitBatch(batch); err != nil {
+		t.Fatalf("Failed to encode the batch: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestJaegerAgentUDP_ThriftCompactTruncatedPacket(t *testing.T) {
+	_, portStr, err := net.SplitHostPort(testutils.GetAvailableLocalAddress(t))
+	if err != nil {
+		t.Fatalf("Failed to split the address: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	sink := new(exportertest.SinkTraceExporter)
+	jr, err := New(context.Background(), &Configuration{AgentCompactThriftPort: port}, sink)
+	if err != nil {
+		t.Fatalf("Failed to create new Jaeger Receiver: %v", err)
+	}
+	defer jr.StopTraceReception(context.Background())
+
+	if err := jr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("StartTraceReception failed: %v", err)
+	}
+
+	parentSpanID := int64(0x1F1E1D1C1B1A1918)
+	packet := compactEmitBatch(t, &jaegerthrift.Batch{
+		Process: &jaegerthrift.Process{ServiceName: "truncated-test"},
+		Spans: []*jaegerthrift.Span{
+			{
+				TraceIdHigh:   0x0102030405060708,
+				TraceIdLow:    0x090A0B0C0D0E0F80,
+				SpanId:        int64(0x2F2E2D2C2B2A2928),
+				ParentSpanId:  parentSpanID,
+				OperationName: "DBSearch",
+				StartTime:     1542158650536343,
+				Duration:      1000,
+			},
+		},
+	})
+
+	conn, err := net.Dial("udp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		t.Fatalf("Failed to dial the agent: %v", err)
+	}
+	defer conn.Close()
+
+	// A truncated packet must be dropped without bringing the agent down.
+	if _, err := conn.Write(packet[:len(packet)/2]); err != nil {
+		t.Fatalf("Failed to send the truncated packet: %v", err)
+	}
+	if _, err := conn.Write(packet); err != nil {
+		t.Fatalf("Failed to send the packet: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sink.AllTraces()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	got := sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 1 {
+		t.Fatalf("Got traces %v, want only the complete batch", got)
+	}
+	if g, w := got[0].Node.GetServiceInfo().GetName(), "truncated-test"; g != w {
+		t.Errorf("Service name = %q, want %q", g, w)
+	}
+	span := got[0].Spans[0]
+	wantTraceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x80}
+	if !bytes.Equal(span.TraceId, wantTraceID) {
+		t.Errorf("TraceId = %x, want %x", span.TraceId, wantTraceID)
+	}
+	if g, w := span.ParentSpanId, []byte{0x1F, 0x1E, 0x1D, 0x1C, 0x1B, 0x1A, 0x19, 0x18}; !bytes.Equal(g, w) {
+		t.Errorf("ParentSpanId = %x, want %x", g, w)
+	}
+	if g, w := span.Name.GetValue(), "DBSearch"; g != w {
+		t.Errorf("Name = %q, want %q", g, w)
+	}
+}
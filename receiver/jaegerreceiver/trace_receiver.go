@@ -272,6 +272,11 @@ func (jr *jReceiver) EmitZipkinBatch(spans []*zipkincore.Span) error {
 // EmitBatch implements cmd/agent/reporter.Reporter and it forwards
 // Jaeger spans received by the Jaeger agent processor.
 func (jr *jReceiver) EmitBatch(batch *jaeger.Batch) error {
+	if batch == nil {
+		// Nothing could be decoded from the packet.
+		return nil
+	}
+
 	td, err := jaegertranslator.ThriftBatchToOCProto(batch)
 	if err != nil {
 		observability.RecordTraceReceiverMetrics(jr.defaultAgentCtx, len(batch.Spans), len(batch.Spans))
@@ -360,6 +365,14 @@ func (jr *jReceiver) startCollector() error {
 	jr.tchannel = tch
 
 	// Now the collector that runs over HTTP
+	//
+	// openapi:operation POST /api/traces exportJaegerTraces
+	// openapi:summary Exports a batch of spans in the Jaeger Thrift format.
+	// openapi:tag jaeger
+	// openapi:request application/x-thrift binary
+	// openapi:response 202
+	// openapi:response 400 text/plain text
+	// openapi:response 500 text/plain text
 	caddr := jr.collectorAddr()
 	cln, cerr := net.Listen("tcp", caddr)
 	if cerr != nil {
@@ -0,0 +1,119 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeyMetadataKey is the gRPC metadata key holding the API key checked by
+// APIKeyAuthInterceptor and APIKeyAuthStreamInterceptor. The HTTP/JSON
+// requests, proxied to the gRPC server, pass it in the Grpc-Metadata-X-Api-Key
+// header.
+const APIKeyMetadataKey = "x-api-key"
+
+// apiKeyHeaders are the headers holding the API key of the binary protobuf
+// requests posted to /v1/trace, which don't go through the gRPC server.
+var apiKeyHeaders = []string{"X-Api-Key", "Grpc-Metadata-X-Api-Key"}
+
+// The health checks are not authenticated, so that load balancers can probe
+// the server without a key.
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+// APIKeyAuthInterceptor returns a unary interceptor rejecting, with an
+// Unauthenticated status, the calls whose APIKeyMetadataKey metadata is not one
+// of validKeys. Without any valid key all the calls are rejected.
+func APIKeyAuthInterceptor(validKeys []string) grpc.UnaryServerInterceptor {
+	auth := newAPIKeyAuthenticator(validKeys)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := auth.authenticate(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// APIKeyAuthStreamInterceptor is the equivalent of APIKeyAuthInterceptor for
+// the streaming calls, such as the export of traces and metrics.
+func APIKeyAuthStreamInterceptor(validKeys []string) grpc.StreamServerInterceptor {
+	auth := newAPIKeyAuthenticator(validKeys)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := auth.authenticate(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+type apiKeyAuthenticator [][]byte
+
+func newAPIKeyAuthenticator(validKeys []string) apiKeyAuthenticator {
+	keys := make(apiKeyAuthenticator, 0, len(validKeys))
+	for _, key := range validKeys {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
+
+func (keys apiKeyAuthenticator) authenticate(ctx context.Context, fullMethod string) error {
+	if strings.HasPrefix(fullMethod, healthServicePrefix) {
+		return nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return keys.check(md.Get(APIKeyMetadataKey))
+}
+
+// authenticateHTTP is the equivalent of authenticate for the HTTP requests
+// served outside of the gRPC server, with the key in apiKeyHeaders.
+func (keys apiKeyAuthenticator) authenticateHTTP(r *http.Request) error {
+	var values []string
+	for _, header := range apiKeyHeaders {
+		values = append(values, r.Header[header]...)
+	}
+	return keys.check(values)
+}
+
+// check returns an Unauthenticated status unless one of the values is a valid
+// key.
+func (keys apiKeyAuthenticator) check(values []string) error {
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing API key")
+	}
+	for _, value := range values {
+		if keys.valid([]byte(value)) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid API key")
+}
+
+// valid compares key with all the valid keys in constant time, so the time
+// taken doesn't reveal how much of a valid key was guessed.
+func (keys apiKeyAuthenticator) valid(key []byte) bool {
+	found := 0
+	for _, validKey := range keys {
+		found |= subtle.ConstantTimeCompare(key, validKey)
+	}
+	return found == 1
+}
@@ -0,0 +1,247 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+func TestAPIKeyAuth_endToEnd(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+	validKeys := []string{"key-1", "key-2"}
+	sink := new(exportertest.SinkTraceExporter)
+	ocr, err := New(addr, sink, nil,
+		WithUnaryInterceptor(APIKeyAuthInterceptor(validKeys)),
+		WithStreamInterceptor(APIKeyAuthStreamInterceptor(validKeys)))
+	if err != nil {
+		t.Fatalf("Failed to create an OpenCensus receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	if err := ocr.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start the receiver: %v", err)
+	}
+
+	cc, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	defer cc.Close()
+
+	// The health checks don't need a key.
+	if _, err := healthpb.NewHealthClient(cc).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Health check without an API key failed: %v", err)
+	}
+
+	export := func(ctx context.Context, spanName string) error {
+		t.Helper()
+		stream, err := agenttracepb.NewTraceServiceClient(cc).Export(ctx)
+		if err != nil {
+			return err
+		}
+		req := &agenttracepb.ExportTraceServiceRequest{
+			Node:  &commonpb.Node{Identifier: &commonpb.ProcessIdentifier{HostName: "auth-test"}},
+			Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: spanName}}},
+		}
+		if err := stream.Send(req); err != nil && err != io.EOF {
+			return err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+		if _, err := stream.Recv(); err != io.EOF {
+			return err
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name     string
+		keys     []string
+		wantCode codes.Code
+	}{
+		{name: "valid_key", keys: []string{"key-2"}, wantCode: codes.OK},
+		{name: "one_valid_key", keys: []string{"key-0", "key-1"}, wantCode: codes.OK},
+		{name: "missing_key", wantCode: codes.Unauthenticated},
+		{name: "invalid_key", keys: []string{"key-3"}, wantCode: codes.Unauthenticated},
+		{name: "valid_key_prefix", keys: []string{"key-"}, wantCode: codes.Unauthenticated},
+	}
+	var wantSpans []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, key := range tt.keys {
+				ctx = metadata.AppendToOutgoingContext(ctx, APIKeyMetadataKey, key)
+			}
+			err := export(ctx, tt.name)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("Export() error = %v, want code %v", err, tt.wantCode)
+			}
+			if tt.wantCode == codes.OK {
+				wantSpans = append(wantSpans, tt.name)
+			}
+		})
+	}
+
+	// The spans are passed to the sink asynchronously, by the workers of the
+	// trace receiver.
+	var gotSpans []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		gotSpans = gotSpans[:0]
+		for _, td := range sink.AllTraces() {
+			for _, span := range td.Spans {
+				gotSpans = append(gotSpans, span.Name.GetValue())
+			}
+		}
+		if len(gotSpans) >= len(wantSpans) {
+			break
+		}
+	}
+	sort.Strings(gotSpans)
+	sort.Strings(wantSpans)
+	if !reflect.DeepEqual(gotSpans, wantSpans) {
+		t.Errorf("Received spans %v, want only those of the authenticated clients %v", gotSpans, wantSpans)
+	}
+}
+
+func TestAPIKeyAuth_binaryTraces(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+	sink := new(exportertest.SinkTraceExporter)
+	ocr, err := New(addr, sink, nil, WithAPIKeys([]string{"key-1", "key-2"}))
+	if err != nil {
+		t.Fatalf("Failed to create an OpenCensus receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	if err := ocr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start trace receiver: %v", err)
+	}
+
+	body, err := proto.Marshal(&agenttracepb.ExportTraceServiceRequest{
+		Node:  &commonpb.Node{Identifier: &commonpb.ProcessIdentifier{HostName: "auth-test"}},
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "binary"}}},
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal() error: %v", err)
+	}
+	post := func(header, key string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/v1/trace", addr), bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		if header != "" {
+			req.Header.Set(header, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Error posting the binary request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		key        string
+		wantStatus int
+	}{
+		{name: "missing_key", wantStatus: http.StatusUnauthorized},
+		{name: "invalid_key", header: "X-Api-Key", key: "key-3", wantStatus: http.StatusUnauthorized},
+		{name: "valid_key", header: "X-Api-Key", key: "key-1", wantStatus: http.StatusOK},
+		{name: "valid_gateway_key", header: "Grpc-Metadata-X-Api-Key", key: "key-2", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := post(tt.header, tt.key); got != tt.wantStatus {
+				t.Errorf("Got status %d, want %d", got, tt.wantStatus)
+			}
+		})
+	}
+
+	// The spans are passed to the sink asynchronously, by the workers of the
+	// trace receiver.
+	for deadline := time.Now().Add(5 * time.Second); len(sink.AllTraces()) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := sink.AllTraces(); len(got) != 2 {
+		t.Errorf("Got %d traces, want only the 2 authenticated ones", len(got))
+	}
+}
+
+func TestAPIKeyAuthInterceptor(t *testing.T) {
+	interceptor := APIKeyAuthInterceptor([]string{"secret"})
+	info := &grpc.UnaryServerInfo{FullMethod: "/opencensus.proto.agent.trace.v1.TraceService/Config"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "handled", nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadataKey, "secret"))
+	if resp, err := interceptor(ctx, nil, info, handler); err != nil || resp != "handled" {
+		t.Errorf("Interceptor with a valid key = (%v, %v), want the handler response", resp, err)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadataKey, "guess"))
+	if _, err := interceptor(ctx, nil, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Interceptor with an invalid key error = %v, want code %v", err, codes.Unauthenticated)
+	}
+
+	if _, err := APIKeyAuthInterceptor(nil)(ctx, nil, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Interceptor without valid keys error = %v, want code %v", err, codes.Unauthenticated)
+	}
+}
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	var calls []string
+	recording := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	ocr := new(Receiver)
+	for _, opt := range []Option{WithUnaryInterceptor(recording("a"), recording("b")), WithUnaryInterceptor(recording("c"))} {
+		opt.withReceiver(ocr)
+	}
+
+	chained := chainUnaryInterceptors(ocr.unaryInterceptors)
+	_, _ = chained(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return nil, nil
+	})
+	if want := []string{"a", "b", "c", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Got calls %v, want %v", calls, want)
+	}
+}
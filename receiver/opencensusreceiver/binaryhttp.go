@@ -0,0 +1,92 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/status"
+
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+)
+
+const binaryTracePath = "/v1/trace"
+
+// isBinaryProto returns true if the content type is the one of binary
+// protobuf requests, as sent by the ocagent exporter over HTTP.
+func isBinaryProto(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/octet-stream" || mediaType == "application/x-protobuf"
+}
+
+// handleBinaryTraces serves the binary protobuf ExportTraceServiceRequests
+// posted to /v1/trace and passes all the other requests, e.g. the HTTP/JSON
+// ones, to next.
+//
+// openapi:operation POST /v1/trace exportOpenCensusTraces
+// openapi:summary Exports spans in the OpenCensus format, as JSON or binary protobuf.
+// openapi:tag opencensus
+// openapi:request application/json agenttracepb.ExportTraceServiceRequest
+// openapi:request application/x-protobuf binary
+// openapi:request application/octet-stream binary
+// openapi:response 200 application/json agenttracepb.ExportTraceServiceResponse
+// openapi:response 200 application/octet-stream binary
+// openapi:response 400 application/json gateway.Error
+// openapi:response 400 text/plain text
+// openapi:response 401 application/json gateway.Error
+// openapi:response 401 text/plain text
+// openapi:response 413 text/plain text
+// openapi:response 429 text/plain text
+// openapi:security apiKey
+func (ocr *Receiver) handleBinaryTraces(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != binaryTracePath ||
+			!isBinaryProto(r.Header.Get("Content-Type")) || ocr.traceReceiver == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ocr.httpAPIKeys != nil {
+			if err := ocr.httpAPIKeys.authenticateHTTP(r); err != nil {
+				http.Error(w, status.Convert(err).Message(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := new(agenttracepb.ExportTraceServiceRequest)
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, "failed to decode the ExportTraceServiceRequest: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ocr.traceReceiver.ExportRequest(r.Context(), req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp, _ := proto.Marshal(&agenttracepb.ExportTraceServiceResponse{})
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(resp)
+	})
+}
@@ -0,0 +1,198 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+func postBinary(t *testing.T, url string, body []byte) (int, []byte) {
+	resp, err := http.Post(url, "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error posting the binary request: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, respBody
+}
+
+func TestBinaryTraces_endToEnd(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+
+	sink := new(exportertest.SinkTraceExporter)
+	ocr, err := New(addr, sink, nil)
+	if err != nil {
+		t.Fatalf("Failed to create trace receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	if err := ocr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start trace receiver: %v", err)
+	}
+
+	start := time.Unix(1544712660, 1000).UTC()
+	span := &tracepb.Span{
+		TraceId:      []byte{0x5B, 0x8E, 0xFF, 0xF7, 0x98, 0x3, 0x81, 0x3, 0xD2, 0x69, 0xB6, 0x33, 0x81, 0x3F, 0xC6, 0xC},
+		SpanId:       []byte{0xEE, 0xE1, 0x9B, 0x7E, 0xC3, 0xC1, 0xB1, 0x73},
+		ParentSpanId: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		Tracestate: &tracepb.Span_Tracestate{
+			Entries: []*tracepb.Span_Tracestate_Entry{{Key: "vendor", Value: "value"}},
+		},
+		Name:      &tracepb.TruncatableString{Value: "binarySpan", TruncatedByteCount: 3},
+		Kind:      tracepb.Span_SERVER,
+		StartTime: internal.TimeToTimestamp(start),
+		EndTime:   internal.TimeToTimestamp(start.Add(time.Second)),
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"int":    {Value: &tracepb.AttributeValue_IntValue{IntValue: 55}},
+				"bool":   {Value: &tracepb.AttributeValue_BoolValue{BoolValue: true}},
+				"double": {Value: &tracepb.AttributeValue_DoubleValue{DoubleValue: 1.5}},
+				"string": {Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: "value"}}},
+			},
+			DroppedAttributesCount: 2,
+		},
+		StackTrace: &tracepb.StackTrace{StackTraceHashId: 42},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{
+				{
+					Time: internal.TimeToTimestamp(start.Add(time.Millisecond)),
+					Value: &tracepb.Span_TimeEvent_Annotation_{
+						Annotation: &tracepb.Span_TimeEvent_Annotation{
+							Description: &tracepb.TruncatableString{Value: "annotation"},
+						},
+					},
+				},
+				{
+					Time: internal.TimeToTimestamp(start.Add(2 * time.Millisecond)),
+					Value: &tracepb.Span_TimeEvent_MessageEvent_{
+						MessageEvent: &tracepb.Span_TimeEvent_MessageEvent{
+							Type:             tracepb.Span_TimeEvent_MessageEvent_SENT,
+							Id:               1,
+							UncompressedSize: 100,
+							CompressedSize:   50,
+						},
+					},
+				},
+			},
+			DroppedAnnotationsCount: 1,
+		},
+		Links: &tracepb.Span_Links{
+			Link: []*tracepb.Span_Link{
+				{
+					TraceId: []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, 0x20},
+					SpanId:  []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+					Type:    tracepb.Span_Link_PARENT_LINKED_SPAN,
+				},
+			},
+		},
+		Status:                  &tracepb.Status{Code: 5, Message: "not found"},
+		Resource:                &resourcepb.Resource{Type: "span-resource"},
+		SameProcessAsParentSpan: &wrappers.BoolValue{Value: true},
+		ChildSpanCount:          &wrappers.UInt32Value{Value: 7},
+	}
+	req := &agenttracepb.ExportTraceServiceRequest{
+		Node: &commonpb.Node{
+			Identifier:  &commonpb.ProcessIdentifier{HostName: "testHost", Pid: 1234},
+			LibraryInfo: &commonpb.LibraryInfo{Language: commonpb.LibraryInfo_GO_LANG},
+			ServiceInfo: &commonpb.ServiceInfo{Name: "binary-svc"},
+		},
+		Resource: &resourcepb.Resource{Type: "k8s", Labels: map[string]string{"pod": "p1"}},
+		Spans:    []*tracepb.Span{span},
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/v1/trace", addr)
+	status, respBody := postBinary(t, url, body)
+	if status != http.StatusOK {
+		t.Fatalf("Got status %d (%s), want %d", status, respBody, http.StatusOK)
+	}
+	if err := proto.Unmarshal(respBody, &agenttracepb.ExportTraceServiceResponse{}); err != nil {
+		t.Errorf("Failed to decode the response: %v", err)
+	}
+
+	// Wait for the span to be passed to the sink.
+	deadline := time.Now().Add(time.Second)
+	for len(sink.AllTraces()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 1 {
+		t.Fatalf("Got traces %v, want the posted span", got)
+	}
+	if !proto.Equal(got[0].Spans[0], span) {
+		t.Errorf("Got span\n\t%v\nwant\n\t%v", got[0].Spans[0], span)
+	}
+	if !proto.Equal(got[0].Node, req.Node) {
+		t.Errorf("Got node %v, want %v", got[0].Node, req.Node)
+	}
+	if !proto.Equal(got[0].Resource, req.Resource) {
+		t.Errorf("Got resource %v, want %v", got[0].Resource, req.Resource)
+	}
+	if got[0].SourceFormat != "oc_trace" {
+		t.Errorf("Got source format %q, want %q", got[0].SourceFormat, "oc_trace")
+	}
+}
+
+func TestBinaryTracesInvalid(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+
+	sink := new(exportertest.SinkTraceExporter)
+	ocr, err := New(addr, sink, nil)
+	if err != nil {
+		t.Fatalf("Failed to create trace receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	if err := ocr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start trace receiver: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/v1/trace", addr)
+	if status, _ := postBinary(t, url, []byte{0xff, 0xff, 0xff}); status != http.StatusBadRequest {
+		t.Errorf("Malformed request: got status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	// The first message of an export must have a Node.
+	noNode, _ := proto.Marshal(&agenttracepb.ExportTraceServiceRequest{
+		Spans: []*tracepb.Span{{Name: &tracepb.TruncatableString{Value: "orphan"}}},
+	})
+	if status, _ := postBinary(t, url, noNode); status != http.StatusBadRequest {
+		t.Errorf("Request without a Node: got status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if got := sink.AllTraces(); len(got) != 0 {
+		t.Errorf("Got traces %v, want none", got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package opencensusreceiver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzParseSpan feeds random bodies to the decoding of the HTTP/JSON trace
+// requests. To run it:
+//
+//	go test -run '^$' -fuzz FuzzParseSpan ./receiver/opencensusreceiver
+//
+// It fails if parseJSONTraceRequest returns an error that is not a
+// *jsonTraceRequestError, a request along with an error, a request without
+// a node, or a request that doesn't survive being encoded and decoded again.
+func FuzzParseSpan(f *testing.F) {
+	files, err := filepath.Glob("testdata/jsontrace/*")
+	if err != nil || len(files) == 0 {
+		f.Fatalf("Failed to list the seed corpus: %v", err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatalf("Failed to read %s: %v", file, err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte(`{"node": {}}{"node": {}}`))
+	f.Add([]byte(`{"node": {}, "spans": [null]}`))
+	f.Add([]byte(`{"node": {}, "spans": [{"attributes": {"attribute_map": {"k": {}}}}]}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, err := parseJSONTraceRequest(data)
+		if err != nil {
+			if _, ok := err.(*jsonTraceRequestError); !ok {
+				t.Fatalf("Got error of type %T, want *jsonTraceRequestError: %v", err, err)
+			}
+			if req != nil {
+				t.Fatalf("Got request %v along with error %v", req, err)
+			}
+			return
+		}
+		if req == nil || req.Node == nil {
+			t.Fatalf("Got request %v without a node and no error", req)
+		}
+
+		b1, err := jsonMarshaler.Marshal(req)
+		if err != nil {
+			t.Fatalf("Failed to encode the decoded request: %v", err)
+		}
+		req2, err := parseJSONTraceRequest(b1)
+		if err != nil {
+			t.Fatalf("Failed to decode the encoded request %s: %v", b1, err)
+		}
+		if b2, _ := jsonMarshaler.Marshal(req2); !bytes.Equal(b1, b2) {
+			t.Fatalf("Got %s after decoding %s again", b2, b1)
+		}
+	})
+}
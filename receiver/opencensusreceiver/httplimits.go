@@ -0,0 +1,46 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// limitHTTPRequests wraps the HTTP/JSON handler so that it rejects the
+// requests over the rate limit and the ones whose body is too large. A
+// maxBodyBytes of zero and a nil limiter disable the respective checks.
+func limitHTTPRequests(next http.Handler, maxBodyBytes int64, limiter *rate.Limiter) http.Handler {
+	if maxBodyBytes <= 0 && limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limiter != nil && !limiter.Allow() {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		if maxBodyBytes > 0 {
+			if r.ContentLength > maxBodyBytes {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			// The content length is not known for chunked requests, reading
+			// past the limit makes the gateway fail to decode them.
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
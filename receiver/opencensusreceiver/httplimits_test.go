@@ -0,0 +1,88 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+)
+
+const limitsTraceJSON = `{
+  "node": {"identifier": {"hostName": "testHost"}},
+  "spans": [{"traceId": "W47/95gDgQPSabYzgT/GDA==", "spanId": "7uGbfsPBsXM=", "name": {"value": "testSpan"}}]
+}`
+
+func postTraceJSON(t *testing.T, url, body string) int {
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("Error posting trace to the HTTP/JSON server: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestHTTPLimits_endToEnd(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+
+	sink := new(exportertest.SinkTraceExporter)
+	maxBodyBytes := int64(len(limitsTraceJSON))
+	// Only two requests are allowed, the oversized one and the valid one, as
+	// the bucket is refilled every hour.
+	ocr, err := New(addr, sink, nil,
+		WithMaxHTTPRequestBodyBytes(maxBodyBytes),
+		WithHTTPRateLimit(1.0/3600, 2))
+	if err != nil {
+		t.Fatalf("Failed to create trace receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	if err := ocr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start trace receiver: %v", err)
+	}
+
+	url := fmt.Sprintf("http://%s/v1/trace", addr)
+
+	tooLarge := limitsTraceJSON + strings.Repeat(" ", 1)
+	if got := postTraceJSON(t, url, tooLarge); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("Oversized request: got status %d, want %d", got, http.StatusRequestEntityTooLarge)
+	}
+
+	if got := postTraceJSON(t, url, limitsTraceJSON); got != http.StatusOK {
+		t.Fatalf("Got status %d, want %d", got, http.StatusOK)
+	}
+	// Wait for the span to be passed to the sink.
+	deadline := time.Now().Add(time.Second)
+	for len(sink.AllTraces()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := sink.AllTraces(); len(got) != 1 || len(got[0].Spans) != 1 {
+		t.Fatalf("Got traces %v, want the posted span", got)
+	}
+
+	if got := postTraceJSON(t, url, limitsTraceJSON); got != http.StatusTooManyRequests {
+		t.Errorf("Request over the rate limit: got status %d, want %d", got, http.StatusTooManyRequests)
+	}
+	if got := len(sink.AllTraces()); got != 1 {
+		t.Errorf("Got %d traces, want the rate limited request to be dropped", got)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	gatewayruntime "github.com/grpc-ecosystem/grpc-gateway/runtime"
+)
+
+// jsonMarshaler is the marshaler of the HTTP/JSON gateway. It is the default
+// one of grpc-gateway, accepting the field names of the protos as well as
+// their lowerCamelCase versions.
+var jsonMarshaler = &gatewayruntime.JSONPb{OrigName: true}
+
+// The metrics are only served by the gateway, the annotations of the traces
+// are along the binary protobuf handler.
+//
+// openapi:operation POST /v1/metrics exportOpenCensusMetrics
+// openapi:summary Exports metrics in the OpenCensus format, as JSON.
+// openapi:tag opencensus
+// openapi:request application/json agentmetricspb.ExportMetricsServiceRequest
+// openapi:response 200 application/json agentmetricspb.ExportMetricsServiceResponse
+// openapi:response 400 application/json gateway.Error
+// openapi:response 401 application/json gateway.Error
+// openapi:response 413 text/plain text
+// openapi:response 429 text/plain text
+// openapi:security apiKey
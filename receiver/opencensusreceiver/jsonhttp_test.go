@@ -0,0 +1,132 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+)
+
+var errJSONTraceRequestNodeRequired = errors.New("the request has no node")
+
+// jsonTraceRequestError is the error returned for the HTTP/JSON
+// ExportTraceServiceRequests that are invalid.
+type jsonTraceRequestError struct {
+	err error
+}
+
+func (e *jsonTraceRequestError) Error() string {
+	return "invalid ExportTraceServiceRequest: " + e.err.Error()
+}
+
+// parseJSONTraceRequest decodes a HTTP/JSON ExportTraceServiceRequest with the
+// decoder of jsonMarshaler, as the gateway reads the streamed requests of
+// /v1/trace, and checks it has the Node the trace receiver requires. The
+// returned error, if any, is a *jsonTraceRequestError.
+func parseJSONTraceRequest(body []byte) (*agenttracepb.ExportTraceServiceRequest, error) {
+	req := new(agenttracepb.ExportTraceServiceRequest)
+	if err := jsonMarshaler.NewDecoder(bytes.NewReader(body)).Decode(req); err != nil {
+		return nil, &jsonTraceRequestError{err: err}
+	}
+	if req.Node == nil {
+		return nil, &jsonTraceRequestError{err: errJSONTraceRequestNodeRequired}
+	}
+	return req, nil
+}
+
+// TestParseJSONTraceRequestFuzzCorpus checks the seed corpus of FuzzParseSpan
+// has the properties the fuzzer checks.
+func TestParseJSONTraceRequestFuzzCorpus(t *testing.T) {
+	wantValid := map[string]bool{"valid": true}
+
+	files, err := filepath.Glob("testdata/jsontrace/*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to list the seed corpus: %v", err)
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+
+		req, err := parseJSONTraceRequest(data)
+		if err != nil {
+			if _, ok := err.(*jsonTraceRequestError); !ok {
+				t.Errorf("%s: got error of type %T, want *jsonTraceRequestError: %v", name, err, err)
+			}
+			if req != nil {
+				t.Errorf("%s: got request %v along with error %v", name, req, err)
+			}
+			if wantValid[name] {
+				t.Errorf("%s: got error %v, want a valid request", name, err)
+			}
+			continue
+		}
+		if !wantValid[name] {
+			t.Errorf("%s: got request %v, want an error", name, req)
+			continue
+		}
+
+		b1, err := jsonMarshaler.Marshal(req)
+		if err != nil {
+			t.Fatalf("%s: failed to encode the decoded request: %v", name, err)
+		}
+		req2, err := parseJSONTraceRequest(b1)
+		if err != nil {
+			t.Fatalf("%s: failed to decode the encoded request %s: %v", name, b1, err)
+		}
+		if b2, _ := jsonMarshaler.Marshal(req2); !bytes.Equal(b1, b2) {
+			t.Errorf("%s: got %s after decoding %s again", name, b2, b1)
+		}
+	}
+}
+
+func TestParseJSONTraceRequest(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/jsontrace/valid")
+	if err != nil {
+		t.Fatalf("Failed to read the request: %v", err)
+	}
+	req, err := parseJSONTraceRequest(data)
+	if err != nil {
+		t.Fatalf("parseJSONTraceRequest failed: %v", err)
+	}
+
+	if got := req.Node.GetServiceInfo().GetName(); got != "checkout" {
+		t.Errorf("Got service name %q, want %q", got, "checkout")
+	}
+	if len(req.Spans) != 1 {
+		t.Fatalf("Got %d spans, want 1", len(req.Spans))
+	}
+	span := req.Spans[0]
+	if got := span.GetName().GetValue(); got != "GET /cart" {
+		t.Errorf("Got span name %q, want %q", got, "GET /cart")
+	}
+	wantTraceID := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	if !bytes.Equal(span.TraceId, wantTraceID) {
+		t.Errorf("Got trace ID %x, want %x", span.TraceId, wantTraceID)
+	}
+	if got := span.GetAttributes().GetAttributeMap()["http.status_code"].GetIntValue(); got != 200 {
+		t.Errorf("Got http.status_code %d, want 200", got)
+	}
+	if got := span.GetStartTime().GetNanos(); got != 123456789 {
+		t.Errorf("Got start time nanos %d, want 123456789", got)
+	}
+}
@@ -122,16 +122,12 @@ func (ocr *Receiver) Export(tes agenttracepb.TraceService_ExportServer) error {
 			resource = recv.Resource
 		}
 
-		td := &data.TraceData{
+		ocr.sendToNextConsumer(ctxWithReceiverName, &data.TraceData{
 			Node:         lastNonNilNode,
 			Resource:     resource,
 			Spans:        recv.Spans,
 			SourceFormat: "oc_trace",
-		}
-
-		ocr.messageChan <- &traceDataWithCtx{data: td, ctx: ctxWithReceiverName}
-
-		observability.RecordTraceReceiverMetrics(ctxWithReceiverName, len(td.Spans), 0)
+		})
 
 		recv, err = tes.Recv()
 		if err != nil {
@@ -145,6 +141,29 @@ func (ocr *Receiver) Export(tes agenttracepb.TraceService_ExportServer) error {
 	}
 }
 
+// ExportRequest receives the spans of a single request, e.g. one sent over
+// HTTP instead of streamed with Export. The request must have a Node.
+func (ocr *Receiver) ExportRequest(ctx context.Context, req *agenttracepb.ExportTraceServiceRequest) error {
+	if req.Node == nil {
+		return errTraceExportProtocolViolation
+	}
+
+	ctxWithReceiverName := observability.ContextWithReceiverName(ctx, receiverTagValue)
+	ocr.sendToNextConsumer(ctxWithReceiverName, &data.TraceData{
+		Node:         req.Node,
+		Resource:     req.Resource,
+		Spans:        req.Spans,
+		SourceFormat: "oc_trace",
+	})
+	return nil
+}
+
+func (ocr *Receiver) sendToNextConsumer(ctx context.Context, td *data.TraceData) {
+	ocr.messageChan <- &traceDataWithCtx{data: td, ctx: ctx}
+
+	observability.RecordTraceReceiverMetrics(ctx, len(td.Spans), 0)
+}
+
 // Stop the receiver and its workers
 func (ocr *Receiver) Stop() {
 	for _, worker := range ocr.workers {
@@ -24,6 +24,9 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/census-instrumentation/opencensus-service/consumer"
 	"github.com/census-instrumentation/opencensus-service/observability"
@@ -32,6 +35,7 @@ import (
 	gatewayruntime "github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/rs/cors"
 	"github.com/soheilhy/cmux"
+	"golang.org/x/time/rate"
 
 	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
 	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
@@ -42,11 +46,22 @@ type Receiver struct {
 	mu                sync.Mutex
 	ln                net.Listener
 	serverGRPC        *grpc.Server
+	healthServer      *health.Server
 	serverHTTP        *http.Server
 	gatewayMux        *gatewayruntime.ServeMux
 	corsOrigins       []string
 	grpcServerOptions []grpc.ServerOption
 
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+
+	// httpAPIKeys if set, authenticates the requests served outside of the
+	// gRPC server.
+	httpAPIKeys *apiKeyAuthenticator
+
+	maxHTTPRequestBodyBytes int64
+	httpRateLimiter         *rate.Limiter
+
 	traceReceiverOpts   []octrace.Option
 	metricsReceiverOpts []ocmetrics.Option
 
@@ -69,9 +84,20 @@ var (
 
 const source string = "OpenCensus"
 
+// The names under which the health of the services is reported by the
+// grpc.health.v1 Health service, the empty name being the overall health.
+const (
+	traceServiceName   = "opencensus.proto.agent.trace.v1.TraceService"
+	metricsServiceName = "opencensus.proto.agent.metrics.v1.MetricsService"
+)
+
 // New just creates the OpenCensus receiver services. It is the caller's
 // responsibility to invoke the respective Start*Reception methods as well
 // as the various Stop*Reception methods or simply Stop to end it.
+//
+// The gRPC server also implements the gRPC Health Checking Protocol. The
+// services are reported as SERVING once started, and as NOT_SERVING when
+// stopped.
 func New(addr string, tc consumer.TraceConsumer, mc consumer.MetricsConsumer, opts ...Option) (*Receiver, error) {
 	// TODO: (@odeke-em) use options to enable address binding changes.
 	ln, err := net.Listen("tcp", addr)
@@ -82,7 +108,7 @@ func New(addr string, tc consumer.TraceConsumer, mc consumer.MetricsConsumer, op
 	ocr := &Receiver{
 		ln:          ln,
 		corsOrigins: []string{}, // Disable CORS by default.
-		gatewayMux:  gatewayruntime.NewServeMux(),
+		gatewayMux:  gatewayruntime.NewServeMux(gatewayruntime.WithMarshalerOption(gatewayruntime.MIMEWildcard, jsonMarshaler)),
 	}
 
 	for _, opt := range opts {
@@ -119,6 +145,7 @@ func (ocr *Receiver) registerTraceConsumer() error {
 		if err == nil {
 			srv := ocr.grpcServer()
 			agenttracepb.RegisterTraceServiceServer(srv, ocr.traceReceiver)
+			ocr.healthServer.SetServingStatus(traceServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
 		}
 	})
 
@@ -148,17 +175,62 @@ func (ocr *Receiver) registerMetricsConsumer() error {
 		if err == nil {
 			srv := ocr.grpcServer()
 			agentmetricspb.RegisterMetricsServiceServer(srv, ocr.metricsReceiver)
+			ocr.healthServer.SetServingStatus(metricsServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
 		}
 	})
 	return err
 }
 
+// chainUnaryInterceptors returns an interceptor calling the given ones in
+// order, each of them wrapping the following ones and the handler.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
+// chainStreamInterceptors is the equivalent of chainUnaryInterceptors for the
+// streaming calls.
+func chainStreamInterceptors(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return chained(srv, ss)
+	}
+}
+
 func (ocr *Receiver) grpcServer() *grpc.Server {
 	ocr.mu.Lock()
 	defer ocr.mu.Unlock()
 
 	if ocr.serverGRPC == nil {
-		ocr.serverGRPC = observability.GRPCServerWithObservabilityEnabled(ocr.grpcServerOptions...)
+		opts := append([]grpc.ServerOption(nil), ocr.grpcServerOptions...)
+		// gRPC accepts a single interceptor of each kind, so they are chained.
+		if len(ocr.unaryInterceptors) > 0 {
+			opts = append(opts, grpc.UnaryInterceptor(chainUnaryInterceptors(ocr.unaryInterceptors)))
+		}
+		if len(ocr.streamInterceptors) > 0 {
+			opts = append(opts, grpc.StreamInterceptor(chainStreamInterceptors(ocr.streamInterceptors)))
+		}
+		ocr.serverGRPC = observability.GRPCServerWithObservabilityEnabled(opts...)
+		ocr.healthServer = health.NewServer()
+		ocr.healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		healthpb.RegisterHealthServer(ocr.serverGRPC, ocr.healthServer)
+		// The reflection lists the services registered until it is queried,
+		// i.e. the trace and metrics services once started.
+		reflection.Register(ocr.serverGRPC)
 	}
 
 	return ocr.serverGRPC
@@ -171,6 +243,7 @@ func (ocr *Receiver) StopTraceReception(ctx context.Context) error {
 	// StopTraceReception is a noop currently.
 	// TODO: (@odeke-em) investigate whether or not gRPC
 	// provides a way to stop specific services.
+	ocr.setServingStatus(traceServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
 	ocr.traceReceiver.Stop()
 	return nil
 }
@@ -182,6 +255,7 @@ func (ocr *Receiver) StopMetricsReception(ctx context.Context) error {
 	// StopMetricsReception is a noop currently.
 	// TODO: (@odeke-em) investigate whether or not gRPC
 	// provides a way to stop specific services.
+	ocr.setServingStatus(metricsServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
 	return nil
 }
 
@@ -210,6 +284,11 @@ func (ocr *Receiver) Stop() error {
 
 	var err = errAlreadyStopped
 	ocr.stopOnce.Do(func() {
+		if ocr.healthServer != nil {
+			// Let the health checks see that the services are going away.
+			ocr.healthServer.Shutdown()
+		}
+
 		if ocr.serverHTTP != nil {
 			_ = ocr.serverHTTP.Close()
 		}
@@ -228,16 +307,38 @@ func (ocr *Receiver) Stop() error {
 	return err
 }
 
+// reportServing reports the services registered on the gRPC server, and the
+// server as a whole, as SERVING.
+func (ocr *Receiver) reportServing() {
+	ocr.setServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	if ocr.traceReceiver != nil {
+		ocr.setServingStatus(traceServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+	if ocr.metricsReceiver != nil {
+		ocr.setServingStatus(metricsServiceName, healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+func (ocr *Receiver) setServingStatus(service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	ocr.mu.Lock()
+	defer ocr.mu.Unlock()
+
+	if ocr.healthServer != nil {
+		ocr.healthServer.SetServingStatus(service, status)
+	}
+}
+
 func (ocr *Receiver) httpServer() *http.Server {
 	ocr.mu.Lock()
 	defer ocr.mu.Unlock()
 
 	if ocr.serverHTTP == nil {
-		var mux http.Handler = ocr.gatewayMux
+		mux := ocr.handleBinaryTraces(ocr.gatewayMux)
 		if len(ocr.corsOrigins) > 0 {
 			co := cors.Options{AllowedOrigins: ocr.corsOrigins}
 			mux = cors.New(co).Handler(mux)
 		}
+		mux = limitHTTPRequests(mux, ocr.maxHTTPRequestBodyBytes, ocr.httpRateLimiter)
 		ocr.serverHTTP = &http.Server{Handler: mux}
 	}
 
@@ -292,6 +393,7 @@ func (ocr *Receiver) startServer() error {
 			// No error otherwise returned in the period of 1s.
 			// We can assume that the serve is at least running.
 			err = nil
+			ocr.reportServing()
 		}
 	})
 	return err
@@ -21,18 +21,27 @@ import (
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
 
 	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
 	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
 	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/census-instrumentation/opencensus-service/data"
 	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
 	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver/ocmetrics"
 )
 
 func TestGrpcGateway_endToEnd(t *testing.T) {
@@ -349,3 +358,196 @@ func TestStopWithoutStartNeverCrashes(t *testing.T) {
 	// Stop it before ever invoking Start*.
 	ocr.Stop()
 }
+
+func TestMetricsMaxRecvMsgSize_endToEnd(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+	sink := new(exportertest.SinkMetricsExporter)
+	ocr, err := New(addr, nil, sink,
+		WithGRPCServerOptions(grpc.MaxRecvMsgSize(4096)),
+		WithMetricsReceiverOptions(ocmetrics.WithMetricBufferPeriod(10*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("Failed to create metrics receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	if err := ocr.StartMetricsReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start metrics receiver: %v", err)
+	}
+
+	cc, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	defer cc.Close()
+
+	metricWithLabel := func(labelValue string) *metricspb.Metric {
+		return &metricspb.Metric{
+			MetricDescriptor: &metricspb.MetricDescriptor{
+				Name:      "requests",
+				Type:      metricspb.MetricDescriptor_CUMULATIVE_INT64,
+				LabelKeys: []*metricspb.LabelKey{{Key: "method"}},
+			},
+			Timeseries: []*metricspb.TimeSeries{
+				{
+					LabelValues: []*metricspb.LabelValue{{Value: labelValue, HasValue: true}},
+					Points:      []*metricspb.Point{{Value: &metricspb.Point_Int64Value{Int64Value: 7}}},
+				},
+			},
+		}
+	}
+	node := &commonpb.Node{ServiceInfo: &commonpb.ServiceInfo{Name: "max-msg-size"}}
+
+	// A message under the limit makes it to the sink, labels included.
+	stream, err := agentmetricspb.NewMetricsServiceClient(cc).Export(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start the Export stream: %v", err)
+	}
+	if err := stream.Send(&agentmetricspb.ExportMetricsServiceRequest{Node: node, Metrics: []*metricspb.Metric{metricWithLabel("GET")}}); err != nil {
+		t.Fatalf("Failed to send the metrics: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sink.AllMetrics()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := sink.AllMetrics()
+	if len(got) != 1 || len(got[0].Metrics) != 1 {
+		t.Fatalf("Got metrics %v, want the single metric sent", got)
+	}
+	if g, w := got[0].Metrics[0].Timeseries[0].LabelValues[0].Value, "GET"; g != w {
+		t.Errorf("Label value = %q, want %q", g, w)
+	}
+	if g, w := got[0].Metrics[0].MetricDescriptor.LabelKeys[0].Key, "method"; g != w {
+		t.Errorf("Label key = %q, want %q", g, w)
+	}
+
+	// A message over the limit is rejected.
+	stream, err = agentmetricspb.NewMetricsServiceClient(cc).Export(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start the Export stream: %v", err)
+	}
+	tooLarge := metricWithLabel(strings.Repeat("x", 8192))
+	if err := stream.Send(&agentmetricspb.ExportMetricsServiceRequest{Node: node, Metrics: []*metricspb.Metric{tooLarge}}); err != nil {
+		t.Fatalf("Failed to send the metrics: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Got error %v, want a ResourceExhausted status", err)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+	ocr, err := New(addr, exportertest.NewNopTraceExporter(), exportertest.NewNopMetricsExporter())
+	if err != nil {
+		t.Fatalf("Failed to create an OpenCensus receiver: %v", err)
+	}
+	defer ocr.Stop()
+
+	checkHealth := func(check func(*healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error), want map[string]healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		for service, wantStatus := range want {
+			resp, err := check(&healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatalf("Check(%q) error: %v", service, err)
+			}
+			if resp.Status != wantStatus {
+				t.Errorf("Check(%q) = %v, want %v", service, resp.Status, wantStatus)
+			}
+		}
+	}
+	allServices := func(servingStatus healthpb.HealthCheckResponse_ServingStatus) map[string]healthpb.HealthCheckResponse_ServingStatus {
+		return map[string]healthpb.HealthCheckResponse_ServingStatus{
+			"":                 servingStatus,
+			traceServiceName:   servingStatus,
+			metricsServiceName: servingStatus,
+		}
+	}
+
+	// Before the server is serving the services are registered but not ready.
+	if err := ocr.registerTraceConsumer(); err != nil {
+		t.Fatalf("Failed to register the trace service: %v", err)
+	}
+	if err := ocr.registerMetricsConsumer(); err != nil {
+		t.Fatalf("Failed to register the metrics service: %v", err)
+	}
+	checkHealth(func(req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		return ocr.healthServer.Check(context.Background(), req)
+	}, allServices(healthpb.HealthCheckResponse_NOT_SERVING))
+
+	if err := ocr.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start the receiver: %v", err)
+	}
+
+	cc, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	defer cc.Close()
+	client := healthpb.NewHealthClient(cc)
+	remoteCheck := func(req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+		return client.Check(context.Background(), req)
+	}
+
+	checkHealth(remoteCheck, allServices(healthpb.HealthCheckResponse_SERVING))
+
+	// The services are reported independently.
+	ocr.StopTraceReception(context.Background())
+	checkHealth(remoteCheck, map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":                 healthpb.HealthCheckResponse_SERVING,
+		traceServiceName:   healthpb.HealthCheckResponse_NOT_SERVING,
+		metricsServiceName: healthpb.HealthCheckResponse_SERVING,
+	})
+
+	// The established connections still get the health during the shutdown.
+	ocr.Stop()
+	checkHealth(remoteCheck, allServices(healthpb.HealthCheckResponse_NOT_SERVING))
+
+	if _, err := remoteCheck(&healthpb.HealthCheckRequest{Service: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Check(\"unknown\") error = %v, want code %v", err, codes.NotFound)
+	}
+}
+
+func TestServerReflection(t *testing.T) {
+	addr := testutils.GetAvailableLocalAddress(t)
+	ocr, err := New(addr, exportertest.NewNopTraceExporter(), exportertest.NewNopMetricsExporter())
+	if err != nil {
+		t.Fatalf("Failed to create an OpenCensus receiver: %v", err)
+	}
+	defer ocr.Stop()
+	if err := ocr.Start(context.Background()); err != nil {
+		t.Fatalf("Failed to start the receiver: %v", err)
+	}
+
+	cc, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	defer cc.Close()
+
+	// The services are listed as by grpcurl list.
+	stream, err := reflectionpb.NewServerReflectionClient(cc).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo() error: %v", err)
+	}
+	req := &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}
+	if err := stream.Send(req); err != nil {
+		t.Fatalf("Failed to send the reflection request: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive the reflection response: %v", err)
+	}
+	stream.CloseSend()
+
+	services := make(map[string]bool)
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services[service.Name] = true
+	}
+	for _, want := range []string{traceServiceName, metricsServiceName, "grpc.health.v1.Health"} {
+		if !services[want] {
+			t.Errorf("The service %s isn't listed, got %v", want, services)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package opencensusreceiver
 
 import (
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 
 	"github.com/census-instrumentation/opencensus-service/receiver/opencensusreceiver/ocmetrics"
@@ -76,20 +77,108 @@ func WithCorsOrigins(origins []string) Option {
 	return &corsOrigins{origins: origins}
 }
 
+type maxHTTPRequestBodyBytes int64
+
+var _ Option = (maxHTTPRequestBodyBytes)(0)
+
+func (mb maxHTTPRequestBodyBytes) withReceiver(ocr *Receiver) {
+	ocr.maxHTTPRequestBodyBytes = int64(mb)
+}
+
+// WithMaxHTTPRequestBodyBytes is an option to reject the HTTP/JSON requests
+// whose body is larger than the given number of bytes with a 413 status code.
+// Zero, the default, means no limit.
+func WithMaxHTTPRequestBodyBytes(maxBytes int64) Option {
+	return maxHTTPRequestBodyBytes(maxBytes)
+}
+
+type httpRateLimit struct {
+	limit rate.Limit
+	burst int
+}
+
+var _ Option = (*httpRateLimit)(nil)
+
+func (hrl *httpRateLimit) withReceiver(ocr *Receiver) {
+	ocr.httpRateLimiter = rate.NewLimiter(hrl.limit, hrl.burst)
+}
+
+// WithHTTPRateLimit is an option to limit the HTTP/JSON requests accepted by
+// the receiver to requestsPerSecond, allowing bursts of up to burst requests.
+// The requests over the limit are rejected with a 429 status code. It does not
+// apply to the gRPC requests.
+func WithHTTPRateLimit(requestsPerSecond float64, burst int) Option {
+	return &httpRateLimit{limit: rate.Limit(requestsPerSecond), burst: burst}
+}
+
 var _ Option = (grpcServerOptions)(nil)
 
 type grpcServerOptions []grpc.ServerOption
 
 func (gsvo grpcServerOptions) withReceiver(ocr *Receiver) {
-	ocr.grpcServerOptions = gsvo
+	ocr.grpcServerOptions = append(ocr.grpcServerOptions, gsvo...)
 }
 
 // WithGRPCServerOptions allows one to specify the options for starting a gRPC server.
+// The options given by multiple WithGRPCServerOptions are all applied.
 func WithGRPCServerOptions(gsOpts ...grpc.ServerOption) Option {
 	gsvOpts := grpcServerOptions(gsOpts)
 	return gsvOpts
 }
 
+type unaryInterceptors []grpc.UnaryServerInterceptor
+
+var _ Option = (unaryInterceptors)(nil)
+
+func (ui unaryInterceptors) withReceiver(ocr *Receiver) {
+	ocr.unaryInterceptors = append(ocr.unaryInterceptors, ui...)
+}
+
+// WithUnaryInterceptor is an option to add interceptors to the unary calls of
+// the gRPC server, e.g. APIKeyAuthInterceptor. The interceptors given by
+// multiple WithUnaryInterceptor are all called, in order. They can't be
+// combined with a grpc.UnaryInterceptor passed to WithGRPCServerOptions.
+func WithUnaryInterceptor(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return unaryInterceptors(interceptors)
+}
+
+type streamInterceptors []grpc.StreamServerInterceptor
+
+var _ Option = (streamInterceptors)(nil)
+
+func (si streamInterceptors) withReceiver(ocr *Receiver) {
+	ocr.streamInterceptors = append(ocr.streamInterceptors, si...)
+}
+
+// WithStreamInterceptor is an option to add interceptors to the streaming
+// calls of the gRPC server, such as the exports of traces and metrics, e.g.
+// APIKeyAuthStreamInterceptor. The interceptors given by multiple
+// WithStreamInterceptor are all called, in order. They can't be combined with a
+// grpc.StreamInterceptor passed to WithGRPCServerOptions.
+func WithStreamInterceptor(interceptors ...grpc.StreamServerInterceptor) Option {
+	return streamInterceptors(interceptors)
+}
+
+type apiKeys []string
+
+var _ Option = (apiKeys)(nil)
+
+func (ak apiKeys) withReceiver(ocr *Receiver) {
+	ocr.unaryInterceptors = append(ocr.unaryInterceptors, APIKeyAuthInterceptor(ak))
+	ocr.streamInterceptors = append(ocr.streamInterceptors, APIKeyAuthStreamInterceptor(ak))
+	auth := newAPIKeyAuthenticator(ak)
+	ocr.httpAPIKeys = &auth
+}
+
+// WithAPIKeys is an option to authenticate the calls of the gRPC server with
+// APIKeyAuthInterceptor and APIKeyAuthStreamInterceptor, and the binary
+// protobuf requests posted to /v1/trace, which don't go through the gRPC
+// server, with their X-Api-Key header. The requests without one of validKeys
+// are rejected.
+func WithAPIKeys(validKeys []string) Option {
+	return apiKeys(validKeys)
+}
+
 type noopOption int
 
 var _ Option = (noopOption)(0)
@@ -17,6 +17,8 @@ package opencensusreceiver
 import (
 	"reflect"
 	"testing"
+
+	"google.golang.org/grpc"
 )
 
 func TestNoopOption(t *testing.T) {
@@ -32,3 +34,18 @@ func TestNoopOption(t *testing.T) {
 		t.Fatalf("noopOption has side effects\nGot:  %+v\nWant: %+v", subjectReceiver, plainReceiver)
 	}
 }
+
+func TestWithGRPCServerOptionsAccumulate(t *testing.T) {
+	ocr := new(Receiver)
+	opts := []Option{
+		WithGRPCServerOptions(grpc.MaxRecvMsgSize(1024)),
+		WithGRPCServerOptions(grpc.MaxConcurrentStreams(1), grpc.MaxSendMsgSize(1024)),
+	}
+	for _, opt := range opts {
+		opt.withReceiver(ocr)
+	}
+
+	if g, w := len(ocr.grpcServerOptions), 3; g != w {
+		t.Fatalf("Got %d gRPC server options, want %d", g, w)
+	}
+}
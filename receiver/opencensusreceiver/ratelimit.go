@@ -0,0 +1,129 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PerKeyRateLimiter limits the rate of the gRPC requests of each client, the
+// clients being identified by the API key in their APIKeyMetadataKey metadata.
+// The limits are enforced by the interceptors returned by UnaryInterceptor and
+// StreamInterceptor, which reject the requests over the limit with a
+// ResourceExhausted status. Every unary call and every message received on a
+// stream, e.g. each ExportTraceServiceRequest, is a request. To only limit
+// the authenticated clients, add them after the APIKeyAuthInterceptor and
+// APIKeyAuthStreamInterceptor.
+type PerKeyRateLimiter struct {
+	mu       sync.RWMutex
+	limiters map[string]*rate.Limiter
+	// defaultLimiter is shared by all the keys without their own limit, and
+	// the requests without a key.
+	defaultLimiter *rate.Limiter
+}
+
+// NewPerKeyRateLimiter creates a PerKeyRateLimiter allowing defaultLimit
+// requests per second, with bursts of up to defaultBurst requests, to all the
+// keys without a limit set by SetLimit, together.
+func NewPerKeyRateLimiter(defaultLimit rate.Limit, defaultBurst int) *PerKeyRateLimiter {
+	return &PerKeyRateLimiter{
+		limiters:       make(map[string]*rate.Limiter),
+		defaultLimiter: rate.NewLimiter(defaultLimit, defaultBurst),
+	}
+}
+
+// SetLimit sets the limit of the requests with the given key to r requests per
+// second with bursts of up to b requests. It can be called while the limiter
+// is in use, the key then starts again with a full burst.
+func (pkrl *PerKeyRateLimiter) SetLimit(key string, r rate.Limit, b int) {
+	limiter := rate.NewLimiter(r, b)
+	pkrl.mu.Lock()
+	pkrl.limiters[key] = limiter
+	pkrl.mu.Unlock()
+}
+
+// RemoveLimit makes the requests with the given key use the default limit
+// again.
+func (pkrl *PerKeyRateLimiter) RemoveLimit(key string) {
+	pkrl.mu.Lock()
+	delete(pkrl.limiters, key)
+	pkrl.mu.Unlock()
+}
+
+// UnaryInterceptor returns an interceptor enforcing the limits on the unary
+// calls, to use with WithUnaryInterceptor.
+func (pkrl *PerKeyRateLimiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := pkrl.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns an interceptor enforcing the limits on the
+// messages received on streams, to use with WithStreamInterceptor. The stream
+// is ended by the first message over the limit.
+func (pkrl *PerKeyRateLimiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &rateLimitedServerStream{ServerStream: ss, limiter: pkrl, fullMethod: info.FullMethod})
+	}
+}
+
+// allow returns a ResourceExhausted error if the request, whose key is in
+// the metadata of ctx, is over the limit.
+func (pkrl *PerKeyRateLimiter) allow(ctx context.Context, fullMethod string) error {
+	if strings.HasPrefix(fullMethod, healthServicePrefix) {
+		return nil
+	}
+	if !pkrl.limiter(ctx).Allow() {
+		return status.Error(codes.ResourceExhausted, "request rate limit exceeded")
+	}
+	return nil
+}
+
+func (pkrl *PerKeyRateLimiter) limiter(ctx context.Context) *rate.Limiter {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get(APIKeyMetadataKey); len(keys) > 0 {
+		pkrl.mu.RLock()
+		limiter, ok := pkrl.limiters[keys[0]]
+		pkrl.mu.RUnlock()
+		if ok {
+			return limiter
+		}
+	}
+	return pkrl.defaultLimiter
+}
+
+type rateLimitedServerStream struct {
+	grpc.ServerStream
+	limiter    *PerKeyRateLimiter
+	fullMethod string
+}
+
+func (rlss *rateLimitedServerStream) RecvMsg(m interface{}) error {
+	if err := rlss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return rlss.limiter.allow(rlss.Context(), rlss.fullMethod)
+}
@@ -0,0 +1,134 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opencensusreceiver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func contextWithAPIKey(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadataKey, key))
+}
+
+func TestPerKeyRateLimiterUnary(t *testing.T) {
+	// The tokens are replenished too slowly to matter during the test.
+	slow := rate.Every(time.Hour)
+	pkrl := NewPerKeyRateLimiter(slow, 1)
+	pkrl.SetLimit("tenant-a", slow, 2)
+	pkrl.SetLimit("tenant-b", slow, 5)
+
+	interceptor := pkrl.UnaryInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/opencensus.proto.agent.trace.v1.TraceService/Config"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "handled", nil
+	}
+	call := func(ctx context.Context) codes.Code {
+		_, err := interceptor(ctx, nil, info, handler)
+		return status.Code(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		want := codes.OK
+		if i == 3 {
+			want = codes.ResourceExhausted
+		}
+		if got := call(contextWithAPIKey("tenant-a")); got != want {
+			t.Errorf("tenant-a call #%d: got code %v, want %v", i, got, want)
+		}
+	}
+	// tenant-a being throttled doesn't affect tenant-b.
+	for i := 1; i <= 5; i++ {
+		if got := call(contextWithAPIKey("tenant-b")); got != codes.OK {
+			t.Errorf("tenant-b call #%d: got code %v, want %v", i, got, codes.OK)
+		}
+	}
+
+	// The keys without a limit, and the calls without a key, share the default one.
+	if got := call(contextWithAPIKey("unknown")); got != codes.OK {
+		t.Errorf("First call with an unknown key: got code %v, want %v", got, codes.OK)
+	}
+	if got := call(context.Background()); got != codes.ResourceExhausted {
+		t.Errorf("Call without a key after the default burst: got code %v, want %v", got, codes.ResourceExhausted)
+	}
+
+	// The health checks are not limited.
+	healthInfo := &grpc.UnaryServerInfo{FullMethod: healthServicePrefix + "Check"}
+	if _, err := interceptor(contextWithAPIKey("tenant-a"), nil, healthInfo, handler); err != nil {
+		t.Errorf("Health check of a throttled key error = %v", err)
+	}
+
+	// The limits can be changed at runtime.
+	pkrl.SetLimit("tenant-a", slow, 1)
+	if got := call(contextWithAPIKey("tenant-a")); got != codes.OK {
+		t.Errorf("tenant-a call after SetLimit: got code %v, want %v", got, codes.OK)
+	}
+	pkrl.RemoveLimit("tenant-b")
+	if got := call(contextWithAPIKey("tenant-b")); got != codes.ResourceExhausted {
+		t.Errorf("tenant-b call with the exhausted default limit: got code %v, want %v", got, codes.ResourceExhausted)
+	}
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (fss *fakeServerStream) Context() context.Context {
+	return fss.ctx
+}
+
+func (fss *fakeServerStream) RecvMsg(m interface{}) error {
+	return nil
+}
+
+func TestPerKeyRateLimiterStream(t *testing.T) {
+	slow := rate.Every(time.Hour)
+	pkrl := NewPerKeyRateLimiter(slow, 0)
+	pkrl.SetLimit("tenant-a", slow, 3)
+	pkrl.SetLimit("tenant-b", slow, 10)
+
+	interceptor := pkrl.StreamInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/opencensus.proto.agent.trace.v1.TraceService/Export"}
+	// received returns the number of messages received before the stream is
+	// ended, up to max.
+	received := func(key string, max int) (int, error) {
+		n := 0
+		err := interceptor(nil, &fakeServerStream{ctx: contextWithAPIKey(key)}, info, func(srv interface{}, ss grpc.ServerStream) error {
+			for ; n < max; n++ {
+				if err := ss.RecvMsg(nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return n, err
+	}
+
+	n, err := received("tenant-a", 10)
+	if n != 3 || status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("tenant-a: received %d messages with error %v, want 3 and code %v", n, err, codes.ResourceExhausted)
+	}
+	if n, err := received("tenant-b", 10); n != 10 || err != nil {
+		t.Errorf("tenant-b: received %d messages with error %v, want 10 without error", n, err)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlphttpreceiver receives the spans sent with OTLP/HTTP, as from
+// OpenTelemetry instrumented services, and converts them to OpenCensus spans.
+// It lets a collector receive from both OpenCensus and OpenTelemetry SDKs
+// while services are migrated.
+package otlphttpreceiver
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"sync"
+
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/observability"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+	"github.com/census-instrumentation/opencensus-service/translator/trace/otlp"
+)
+
+var (
+	errNilNextConsumer = errors.New("nil nextConsumer")
+	errAlreadyStarted  = errors.New("already started")
+	errAlreadyStopped  = errors.New("already stopped")
+)
+
+// Receiver receives the spans posted, in the OTLP protobuf or JSON format,
+// to /v1/traces.
+type Receiver struct {
+	// mu protects the fields of this struct
+	mu sync.Mutex
+
+	// addr is the address onto which the HTTP server will be bound
+	addr string
+
+	nextConsumer consumer.TraceConsumer
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	server    *http.Server
+}
+
+var _ receiver.TraceReceiver = (*Receiver)(nil)
+var _ http.Handler = (*Receiver)(nil)
+
+// New creates a new otlphttpreceiver.Receiver reference.
+func New(address string, nextConsumer consumer.TraceConsumer) (*Receiver, error) {
+	if nextConsumer == nil {
+		return nil, errNilNextConsumer
+	}
+
+	return &Receiver{
+		addr:         address,
+		nextConsumer: nextConsumer,
+	}, nil
+}
+
+// DefaultAddress is the address of the OTLP/HTTP receivers by default.
+const DefaultAddress = ":4318"
+
+func (otr *Receiver) address() string {
+	if otr.addr == "" {
+		return DefaultAddress
+	}
+	return otr.addr
+}
+
+const traceSource string = "OTLP/HTTP"
+
+// TraceSource returns the name of the trace data source.
+func (otr *Receiver) TraceSource() string {
+	return traceSource
+}
+
+// StartTraceReception spins up the receiver's HTTP server and makes the receiver start its processing.
+func (otr *Receiver) StartTraceReception(ctx context.Context, asyncErrorChan chan<- error) error {
+	otr.mu.Lock()
+	defer otr.mu.Unlock()
+
+	var err = errAlreadyStarted
+
+	otr.startOnce.Do(func() {
+		ln, lerr := net.Listen("tcp", otr.address())
+		if lerr != nil {
+			err = lerr
+			return
+		}
+
+		server := &http.Server{Handler: otr}
+		go func() {
+			if serr := server.Serve(ln); serr != http.ErrServerClosed && asyncErrorChan != nil {
+				asyncErrorChan <- serr
+			}
+		}()
+
+		otr.server = server
+
+		err = nil
+	})
+
+	return err
+}
+
+// StopTraceReception tells the receiver that should stop reception,
+// shutting down its HTTP server.
+func (otr *Receiver) StopTraceReception(ctx context.Context) error {
+	otr.mu.Lock()
+	defer otr.mu.Unlock()
+
+	var err = errAlreadyStopped
+	otr.stopOnce.Do(func() {
+		if otr.server == nil {
+			err = nil
+			return
+		}
+		err = otr.server.Close()
+	})
+	return err
+}
+
+const (
+	tracesPath       = "/v1/traces"
+	receiverTagValue = "otlp_http"
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// ServeHTTP decodes the ExportTraceServiceRequest posted to /v1/traces, and
+// sends the converted spans along to the nextConsumer.
+//
+// openapi:operation POST /v1/traces exportOTLPTraces
+// openapi:summary Exports spans in the OTLP format, as JSON or binary protobuf.
+// openapi:tag otlp
+// openapi:request application/json otlp.ExportRequest
+// openapi:request application/x-protobuf binary
+// openapi:response 200 application/json object
+// openapi:response 200 application/x-protobuf binary
+// openapi:response 400 text/plain text
+// openapi:response 415 text/plain text
+func (otr *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != tracesPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, "unsupported Content-Type, want "+contentTypeProtobuf+" or "+contentTypeJSON,
+			http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Trace this method. The span is started from the request context, for
+	// its cancellation to reach the exporters, but not as a child of its span
+	// as the caller is linked instead.
+	ctx, span := trace.StartSpan(trace.NewContext(r.Context(), nil), "OTLPHTTPReceiver.Export")
+	defer span.End()
+	observability.SetParentLink(r.Context(), span)
+
+	body, err := readBody(r)
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeInvalidArgument, Message: err.Error()})
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req *otlp.ExportRequest
+	if contentType == contentTypeJSON {
+		req = new(otlp.ExportRequest)
+		err = json.Unmarshal(body, req)
+	} else {
+		req, err = otlp.UnmarshalExportRequest(body)
+	}
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeInvalidArgument, Message: err.Error()})
+		http.Error(w, "failed to decode the ExportTraceServiceRequest: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctxWithReceiverName := observability.ContextWithReceiverName(ctx, receiverTagValue)
+	numSpans := 0
+	for _, td := range toTraceData(req) {
+		otr.nextConsumer.ConsumeTraceData(ctxWithReceiverName, td)
+		numSpans += len(td.Spans)
+	}
+	observability.RecordTraceReceiverMetrics(ctxWithReceiverName, numSpans, 0)
+
+	// The ExportTraceServiceResponse is empty, in both encodings.
+	w.Header().Set("Content-Type", contentType)
+	if contentType == contentTypeJSON {
+		io.WriteString(w, "{}")
+	}
+}
+
+// readBody reads the body of the request, decompressing it if needed, as the
+// OpenTelemetry exporters can gzip it.
+func readBody(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gzr.Close()
+		body = gzr
+	}
+	return ioutil.ReadAll(body)
+}
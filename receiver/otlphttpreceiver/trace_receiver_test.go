@@ -0,0 +1,363 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlphttpreceiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/proto"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/internal/testutils"
+	"github.com/census-instrumentation/opencensus-service/translator/trace/otlp"
+)
+
+// The protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// pb builds protobuf messages in the wire format, for the OTLP messages the
+// tests send.
+type pb []byte
+
+func (m pb) key(field, wireType int) pb {
+	return m.rawVarint(uint64(field<<3 | wireType))
+}
+
+func (m pb) rawVarint(v uint64) pb {
+	var b [binary.MaxVarintLen64]byte
+	return append(m, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func (m pb) varint(field int, v uint64) pb {
+	return m.key(field, wireVarint).rawVarint(v)
+}
+
+func (m pb) fixed64(field int, v uint64) pb {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(m.key(field, wireFixed64), b[:]...)
+}
+
+func (m pb) bytes(field int, b []byte) pb {
+	return append(m.key(field, wireBytes).rawVarint(uint64(len(b))), b...)
+}
+
+func (m pb) str(field int, s string) pb {
+	return m.bytes(field, []byte(s))
+}
+
+func (m pb) msg(field int, sub pb) pb {
+	return m.bytes(field, sub)
+}
+
+func kvProto(key string, value pb) pb {
+	return pb(nil).str(1, key).msg(2, value)
+}
+
+var (
+	traceID      = []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	spanID       = []byte{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}
+	parentSpanID = []byte{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28}
+	linkSpanID   = []byte{0x31, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38}
+	startTime    = time.Unix(1544712660, 123456789).UTC()
+)
+
+func testRequestProto() []byte {
+	resource := pb(nil).
+		msg(1, kvProto("service.name", pb(nil).str(1, "checkout"))).
+		msg(1, kvProto("host.name", pb(nil).str(1, "host-1"))).
+		msg(1, kvProto("telemetry.sdk.language", pb(nil).str(1, "go"))).
+		msg(1, kvProto("k8s.pod.name", pb(nil).str(1, "checkout-1")))
+	span := pb(nil).
+		bytes(1, traceID).
+		bytes(2, spanID).
+		str(3, "vendor=value").
+		bytes(4, parentSpanID).
+		str(5, "GET /cart").
+		varint(6, uint64(otlp.SpanKindServer)).
+		fixed64(7, uint64(startTime.UnixNano())).
+		fixed64(8, uint64(startTime.Add(time.Second).UnixNano())).
+		msg(9, kvProto("string", pb(nil).str(1, "value"))).
+		msg(9, kvProto("bool", pb(nil).varint(2, 1))).
+		msg(9, kvProto("int", pb(nil).varint(3, 42))).
+		msg(9, kvProto("double", pb(nil).fixed64(4, math.Float64bits(1.5)))).
+		msg(9, kvProto("array", pb(nil).msg(5, pb(nil).msg(1, pb(nil).str(1, "a")).msg(1, pb(nil).varint(3, 1))))).
+		msg(9, kvProto("bytes", pb(nil).bytes(7, []byte("hi")))).
+		varint(10, 1).
+		msg(11, pb(nil).
+			fixed64(1, uint64(startTime.Add(time.Millisecond).UnixNano())).
+			str(2, "cache miss").
+			msg(3, kvProto("key", pb(nil).str(1, "cart-1")))).
+		msg(13, pb(nil).bytes(1, traceID).bytes(2, linkSpanID)).
+		msg(15, pb(nil).str(2, "out of stock").varint(3, uint64(otlp.StatusCodeError))).
+		// An unknown field, which is skipped.
+		fixed64(99, 7)
+	scopeSpans := pb(nil).
+		msg(1, pb(nil).str(1, "cart-instrumentation").str(2, "1.0.0")).
+		msg(2, span)
+	return pb(nil).msg(1, pb(nil).msg(1, resource).msg(2, scopeSpans))
+}
+
+const testRequestJSON = `{
+  "resourceSpans": [{
+    "resource": {"attributes": [
+      {"key": "service.name", "value": {"stringValue": "checkout"}},
+      {"key": "host.name", "value": {"stringValue": "host-1"}},
+      {"key": "telemetry.sdk.language", "value": {"stringValue": "go"}},
+      {"key": "k8s.pod.name", "value": {"stringValue": "checkout-1"}}
+    ]},
+    "scopeSpans": [{
+      "scope": {"name": "cart-instrumentation", "version": "1.0.0"},
+      "spans": [{
+        "traceId": "0102030405060708090a0b0c0d0e0f10",
+        "spanId": "1112131415161718",
+        "traceState": "vendor=value",
+        "parentSpanId": "2122232425262728",
+        "name": "GET /cart",
+        "kind": "SPAN_KIND_SERVER",
+        "startTimeUnixNano": "1544712660123456789",
+        "endTimeUnixNano": 1544712661123456789,
+        "attributes": [
+          {"key": "string", "value": {"stringValue": "value"}},
+          {"key": "bool", "value": {"boolValue": true}},
+          {"key": "int", "value": {"intValue": "42"}},
+          {"key": "double", "value": {"doubleValue": 1.5}},
+          {"key": "array", "value": {"arrayValue": {"values": [{"stringValue": "a"}, {"intValue": 1}]}}},
+          {"key": "bytes", "value": {"bytesValue": "aGk="}}
+        ],
+        "droppedAttributesCount": 1,
+        "events": [{
+          "timeUnixNano": "1544712660124456789",
+          "name": "cache miss",
+          "attributes": [{"key": "key", "value": {"stringValue": "cart-1"}}]
+        }],
+        "links": [{"traceId": "0102030405060708090a0b0c0d0e0f10", "spanId": "3132333435363738"}],
+        "status": {"message": "out of stock", "code": 2}
+      }]
+    }]
+  }]
+}`
+
+func stringValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: s}},
+	}
+}
+
+var (
+	wantNode = &commonpb.Node{
+		Identifier:  &commonpb.ProcessIdentifier{HostName: "host-1"},
+		LibraryInfo: &commonpb.LibraryInfo{Language: commonpb.LibraryInfo_GO_LANG},
+		ServiceInfo: &commonpb.ServiceInfo{Name: "checkout"},
+	}
+	wantResource = &resourcepb.Resource{Labels: map[string]string{"k8s.pod.name": "checkout-1"}}
+	wantSpan     = &tracepb.Span{
+		TraceId:      traceID,
+		SpanId:       spanID,
+		ParentSpanId: parentSpanID,
+		Tracestate: &tracepb.Span_Tracestate{
+			Entries: []*tracepb.Span_Tracestate_Entry{{Key: "vendor", Value: "value"}},
+		},
+		Name:      &tracepb.TruncatableString{Value: "GET /cart"},
+		Kind:      tracepb.Span_SERVER,
+		StartTime: internal.TimeToTimestamp(startTime),
+		EndTime:   internal.TimeToTimestamp(startTime.Add(time.Second)),
+		Attributes: &tracepb.Span_Attributes{
+			AttributeMap: map[string]*tracepb.AttributeValue{
+				"string":             stringValue("value"),
+				"bool":               {Value: &tracepb.AttributeValue_BoolValue{BoolValue: true}},
+				"int":                {Value: &tracepb.AttributeValue_IntValue{IntValue: 42}},
+				"double":             {Value: &tracepb.AttributeValue_DoubleValue{DoubleValue: 1.5}},
+				"array":              stringValue(`["a",1]`),
+				"bytes":              stringValue("aGk="),
+				"otel.scope.name":    stringValue("cart-instrumentation"),
+				"otel.scope.version": stringValue("1.0.0"),
+			},
+			DroppedAttributesCount: 1,
+		},
+		TimeEvents: &tracepb.Span_TimeEvents{
+			TimeEvent: []*tracepb.Span_TimeEvent{{
+				Time: internal.TimeToTimestamp(startTime.Add(time.Millisecond)),
+				Value: &tracepb.Span_TimeEvent_Annotation_{
+					Annotation: &tracepb.Span_TimeEvent_Annotation{
+						Description: &tracepb.TruncatableString{Value: "cache miss"},
+						Attributes: &tracepb.Span_Attributes{
+							AttributeMap: map[string]*tracepb.AttributeValue{"key": stringValue("cart-1")},
+						},
+					},
+				},
+			}},
+		},
+		Links: &tracepb.Span_Links{
+			Link: []*tracepb.Span_Link{{TraceId: traceID, SpanId: linkSpanID}},
+		},
+		Status: &tracepb.Status{Code: statusCodeUnknown, Message: "out of stock"},
+	}
+)
+
+func startReceiver(t *testing.T) (*Receiver, *exportertest.SinkTraceExporter, string) {
+	addr := testutils.GetAvailableLocalAddress(t)
+	sink := new(exportertest.SinkTraceExporter)
+	otr, err := New(addr, sink)
+	if err != nil {
+		t.Fatalf("Failed to create the receiver: %v", err)
+	}
+	if err := otr.StartTraceReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to start the receiver: %v", err)
+	}
+	return otr, sink, fmt.Sprintf("http://%s/v1/traces", addr)
+}
+
+func TestReceiveTraces(t *testing.T) {
+	var gzipped bytes.Buffer
+	gzw := gzip.NewWriter(&gzipped)
+	gzw.Write(testRequestProto())
+	gzw.Close()
+
+	tests := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		body            []byte
+		wantResponse    string
+	}{
+		{name: "protobuf", contentType: "application/x-protobuf", body: testRequestProto()},
+		{name: "json", contentType: "application/json", body: []byte(testRequestJSON), wantResponse: "{}"},
+		{name: "gzip", contentType: "application/x-protobuf", contentEncoding: "gzip", body: gzipped.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			otr, sink, url := startReceiver(t)
+			defer otr.StopTraceReception(context.Background())
+
+			req, _ := http.NewRequest("POST", url, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", tt.contentEncoding)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to post the spans: %v", err)
+			}
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Got status %d (%s), want %d", resp.StatusCode, respBody, http.StatusOK)
+			}
+			if string(respBody) != tt.wantResponse {
+				t.Errorf("Got response %q, want %q", respBody, tt.wantResponse)
+			}
+
+			got := sink.AllTraces()
+			if len(got) != 1 || len(got[0].Spans) != 1 {
+				t.Fatalf("Got traces %v, want a single span", got)
+			}
+			if !proto.Equal(got[0].Spans[0], wantSpan) {
+				t.Errorf("Got span\n\t%v\nwant\n\t%v", got[0].Spans[0], wantSpan)
+			}
+			if !proto.Equal(got[0].Node, wantNode) {
+				t.Errorf("Got node %v, want %v", got[0].Node, wantNode)
+			}
+			if !proto.Equal(got[0].Resource, wantResource) {
+				t.Errorf("Got resource %v, want %v", got[0].Resource, wantResource)
+			}
+			if got[0].SourceFormat != "otlp" {
+				t.Errorf("Got source format %q, want %q", got[0].SourceFormat, "otlp")
+			}
+		})
+	}
+}
+
+func TestReceiveTracesInvalidRequests(t *testing.T) {
+	otr, sink, url := startReceiver(t)
+	defer otr.StopTraceReception(context.Background())
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        []byte
+		wantStatus  int
+	}{
+		{name: "unknown_path", method: "POST", path: "/v1/metrics", contentType: "application/x-protobuf", wantStatus: http.StatusNotFound},
+		{name: "get", method: "GET", contentType: "application/x-protobuf", wantStatus: http.StatusMethodNotAllowed},
+		{name: "content_type", method: "POST", contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "truncated_protobuf", method: "POST", contentType: "application/x-protobuf", body: testRequestProto()[:20], wantStatus: http.StatusBadRequest},
+		{name: "invalid_json", method: "POST", contentType: "application/json", body: []byte(`{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "xyz"}]}]}]}`), wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		u := url
+		if tt.path != "" {
+			u = url[:len(url)-len(tracesPath)] + tt.path
+		}
+		req, _ := http.NewRequest(tt.method, u, bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+	}
+
+	if got := sink.AllTraces(); len(got) != 0 {
+		t.Errorf("Got traces %v, want none", got)
+	}
+}
+
+func TestSpanKindConversion(t *testing.T) {
+	tests := []struct {
+		kind         otlp.SpanKind
+		wantKind     tracepb.Span_SpanKind
+		wantSpanKind string
+	}{
+		{kind: otlp.SpanKindUnspecified, wantKind: tracepb.Span_SPAN_KIND_UNSPECIFIED},
+		{kind: otlp.SpanKindInternal, wantKind: tracepb.Span_SPAN_KIND_UNSPECIFIED},
+		{kind: otlp.SpanKindServer, wantKind: tracepb.Span_SERVER},
+		{kind: otlp.SpanKindClient, wantKind: tracepb.Span_CLIENT},
+		{kind: otlp.SpanKindProducer, wantKind: tracepb.Span_SPAN_KIND_UNSPECIFIED, wantSpanKind: "producer"},
+		{kind: otlp.SpanKindConsumer, wantKind: tracepb.Span_SPAN_KIND_UNSPECIFIED, wantSpanKind: "consumer"},
+	}
+	for _, tt := range tests {
+		got := toSpan(&otlp.Span{Kind: tt.kind}, nil)
+		if got.Kind != tt.wantKind {
+			t.Errorf("kind %d: got %v, want %v", tt.kind, got.Kind, tt.wantKind)
+		}
+		gotSpanKind := got.GetAttributes().GetAttributeMap()[attributeSpanKind].GetStringValue().GetValue()
+		if gotSpanKind != tt.wantSpanKind {
+			t.Errorf("kind %d: got span.kind %q, want %q", tt.kind, gotSpanKind, tt.wantSpanKind)
+		}
+	}
+}
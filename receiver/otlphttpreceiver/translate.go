@@ -0,0 +1,354 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlphttpreceiver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/internal"
+	"github.com/census-instrumentation/opencensus-service/translator/trace/otlp"
+)
+
+const sourceFormat = "otlp"
+
+// The resource attributes, from the OpenTelemetry semantic conventions, that
+// are converted to the fields of the Node instead of resource labels.
+const (
+	attributeServiceName = "service.name"
+	attributeHostName    = "host.name"
+	attributeProcessPID  = "process.pid"
+	attributeSDKLanguage = "telemetry.sdk.language"
+	attributeSDKVersion  = "telemetry.sdk.version"
+)
+
+// The span attributes holding what OpenCensus spans have no field for.
+const (
+	attributeScopeName    = "otel.scope.name"
+	attributeScopeVersion = "otel.scope.version"
+	attributeSpanKind     = "span.kind"
+)
+
+// statusCodeUnknown is the OpenCensus code of the errors without a more
+// specific code.
+const statusCodeUnknown = 2
+
+var sdkLanguages = map[string]commonpb.LibraryInfo_Language{
+	"cpp":    commonpb.LibraryInfo_CPP,
+	"dotnet": commonpb.LibraryInfo_C_SHARP,
+	"erlang": commonpb.LibraryInfo_ERLANG,
+	"go":     commonpb.LibraryInfo_GO_LANG,
+	"java":   commonpb.LibraryInfo_JAVA,
+	"nodejs": commonpb.LibraryInfo_NODE_JS,
+	"php":    commonpb.LibraryInfo_PHP,
+	"python": commonpb.LibraryInfo_PYTHON,
+	"ruby":   commonpb.LibraryInfo_RUBY,
+	"webjs":  commonpb.LibraryInfo_WEB_JS,
+}
+
+// toTraceData converts the request, best-effort, to one TraceData per
+// resource. The values of the attributes without an OpenCensus equivalent,
+// i.e. arrays, maps and bytes, are converted to strings.
+func toTraceData(req *otlp.ExportRequest) []data.TraceData {
+	tds := make([]data.TraceData, 0, len(req.ResourceSpans))
+	for _, rs := range req.ResourceSpans {
+		if rs == nil {
+			continue
+		}
+		node, resource := toNodeAndResource(rs.Resource)
+		td := data.TraceData{
+			Node:         node,
+			Resource:     resource,
+			SourceFormat: sourceFormat,
+		}
+		for _, ss := range append(rs.ScopeSpans, rs.InstrumentationLibrarySpans...) {
+			if ss == nil {
+				continue
+			}
+			sc := ss.Scope
+			if sc == nil {
+				sc = ss.InstrumentationLibrary
+			}
+			for _, s := range ss.Spans {
+				if s != nil {
+					td.Spans = append(td.Spans, toSpan(s, sc))
+				}
+			}
+		}
+		tds = append(tds, td)
+	}
+	return tds
+}
+
+func toNodeAndResource(r *otlp.Resource) (*commonpb.Node, *resourcepb.Resource) {
+	node := &commonpb.Node{
+		Identifier:  &commonpb.ProcessIdentifier{},
+		LibraryInfo: &commonpb.LibraryInfo{},
+		ServiceInfo: &commonpb.ServiceInfo{},
+	}
+	if r == nil {
+		return node, nil
+	}
+
+	labels := make(map[string]string)
+	for _, kv := range r.Attributes {
+		if kv == nil {
+			continue
+		}
+		value := anyValueString(kv.Value)
+		switch kv.Key {
+		case attributeServiceName:
+			node.ServiceInfo.Name = value
+		case attributeHostName:
+			node.Identifier.HostName = value
+		case attributeProcessPID:
+			pid, _ := strconv.ParseUint(value, 10, 32)
+			node.Identifier.Pid = uint32(pid)
+		case attributeSDKLanguage:
+			node.LibraryInfo.Language = sdkLanguages[value]
+		case attributeSDKVersion:
+			node.LibraryInfo.CoreLibraryVersion = value
+		default:
+			labels[kv.Key] = value
+		}
+	}
+	if len(labels) == 0 {
+		return node, nil
+	}
+	return node, &resourcepb.Resource{Labels: labels}
+}
+
+func toSpan(s *otlp.Span, sc *otlp.Scope) *tracepb.Span {
+	attrs := toAttributes(s.Attributes, s.DroppedAttributesCount)
+	kind := tracepb.Span_SPAN_KIND_UNSPECIFIED
+	switch s.Kind {
+	case otlp.SpanKindServer:
+		kind = tracepb.Span_SERVER
+	case otlp.SpanKindClient:
+		kind = tracepb.Span_CLIENT
+	case otlp.SpanKindProducer:
+		attrs = withStringAttribute(attrs, attributeSpanKind, "producer")
+	case otlp.SpanKindConsumer:
+		attrs = withStringAttribute(attrs, attributeSpanKind, "consumer")
+	}
+	if sc != nil && sc.Name != "" {
+		attrs = withStringAttribute(attrs, attributeScopeName, sc.Name)
+		if sc.Version != "" {
+			attrs = withStringAttribute(attrs, attributeScopeVersion, sc.Version)
+		}
+	}
+
+	return &tracepb.Span{
+		TraceId:      s.TraceID,
+		SpanId:       s.SpanID,
+		ParentSpanId: s.ParentSpanID,
+		Tracestate:   toTracestate(s.TraceState),
+		Name:         &tracepb.TruncatableString{Value: s.Name},
+		Kind:         kind,
+		StartTime:    unixNanoToTimestamp(s.StartTimeUnixNano),
+		EndTime:      unixNanoToTimestamp(s.EndTimeUnixNano),
+		Attributes:   attrs,
+		TimeEvents:   toTimeEvents(s.Events, s.DroppedEventsCount),
+		Links:        toLinks(s.Links, s.DroppedLinksCount),
+		Status:       toStatus(s.Status),
+	}
+}
+
+func unixNanoToTimestamp(ns otlp.Uint64) *timestamp.Timestamp {
+	if ns == 0 {
+		return nil
+	}
+	return internal.TimeToTimestamp(time.Unix(0, int64(ns)).UTC())
+}
+
+func toTracestate(traceState string) *tracepb.Span_Tracestate {
+	if traceState == "" {
+		return nil
+	}
+	var entries []*tracepb.Span_Tracestate_Entry
+	for _, member := range strings.Split(traceState, ",") {
+		kv := strings.SplitN(strings.TrimSpace(member), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		entries = append(entries, &tracepb.Span_Tracestate_Entry{Key: kv[0], Value: kv[1]})
+	}
+	return &tracepb.Span_Tracestate{Entries: entries}
+}
+
+func toTimeEvents(events []*otlp.Event, dropped uint32) *tracepb.Span_TimeEvents {
+	if len(events) == 0 && dropped == 0 {
+		return nil
+	}
+	tes := &tracepb.Span_TimeEvents{DroppedAnnotationsCount: int32(dropped)}
+	for _, e := range events {
+		if e == nil {
+			continue
+		}
+		tes.TimeEvent = append(tes.TimeEvent, &tracepb.Span_TimeEvent{
+			Time: unixNanoToTimestamp(e.TimeUnixNano),
+			Value: &tracepb.Span_TimeEvent_Annotation_{
+				Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Description: &tracepb.TruncatableString{Value: e.Name},
+					Attributes:  toAttributes(e.Attributes, e.DroppedAttributesCount),
+				},
+			},
+		})
+	}
+	return tes
+}
+
+func toLinks(links []*otlp.Link, dropped uint32) *tracepb.Span_Links {
+	if len(links) == 0 && dropped == 0 {
+		return nil
+	}
+	sls := &tracepb.Span_Links{DroppedLinksCount: int32(dropped)}
+	for _, l := range links {
+		if l == nil {
+			continue
+		}
+		sls.Link = append(sls.Link, &tracepb.Span_Link{
+			TraceId:    l.TraceID,
+			SpanId:     l.SpanID,
+			Attributes: toAttributes(l.Attributes, l.DroppedAttributesCount),
+		})
+	}
+	return sls
+}
+
+func toStatus(s *otlp.Status) *tracepb.Status {
+	if s == nil {
+		return nil
+	}
+	switch s.Code {
+	case otlp.StatusCodeOk:
+		return &tracepb.Status{Message: s.Message}
+	case otlp.StatusCodeError:
+		return &tracepb.Status{Code: statusCodeUnknown, Message: s.Message}
+	}
+	return nil
+}
+
+func toAttributes(kvs []*otlp.KeyValue, dropped uint32) *tracepb.Span_Attributes {
+	if len(kvs) == 0 && dropped == 0 {
+		return nil
+	}
+	attrs := &tracepb.Span_Attributes{
+		AttributeMap:           make(map[string]*tracepb.AttributeValue, len(kvs)),
+		DroppedAttributesCount: int32(dropped),
+	}
+	for _, kv := range kvs {
+		if kv != nil {
+			attrs.AttributeMap[kv.Key] = toAttributeValue(kv.Value)
+		}
+	}
+	return attrs
+}
+
+func withStringAttribute(attrs *tracepb.Span_Attributes, key, value string) *tracepb.Span_Attributes {
+	if attrs == nil {
+		attrs = &tracepb.Span_Attributes{}
+	}
+	if attrs.AttributeMap == nil {
+		attrs.AttributeMap = make(map[string]*tracepb.AttributeValue)
+	}
+	attrs.AttributeMap[key] = stringAttributeValue(value)
+	return attrs
+}
+
+func toAttributeValue(av *otlp.AnyValue) *tracepb.AttributeValue {
+	switch {
+	case av == nil:
+		return stringAttributeValue("")
+	case av.BoolValue != nil:
+		return &tracepb.AttributeValue{Value: &tracepb.AttributeValue_BoolValue{BoolValue: *av.BoolValue}}
+	case av.IntValue != nil:
+		return &tracepb.AttributeValue{Value: &tracepb.AttributeValue_IntValue{IntValue: int64(*av.IntValue)}}
+	case av.DoubleValue != nil:
+		return &tracepb.AttributeValue{Value: &tracepb.AttributeValue_DoubleValue{DoubleValue: *av.DoubleValue}}
+	}
+	return stringAttributeValue(anyValueString(av))
+}
+
+func stringAttributeValue(s string) *tracepb.AttributeValue {
+	return &tracepb.AttributeValue{
+		Value: &tracepb.AttributeValue_StringValue{
+			StringValue: &tracepb.TruncatableString{Value: s},
+		},
+	}
+}
+
+// anyValueString returns the value as a string: the arrays and maps are JSON
+// encoded and the bytes base64 encoded.
+func anyValueString(av *otlp.AnyValue) string {
+	switch v := plainValue(av).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func plainValue(av *otlp.AnyValue) interface{} {
+	switch {
+	case av == nil:
+		return nil
+	case av.StringValue != nil:
+		return *av.StringValue
+	case av.BoolValue != nil:
+		return *av.BoolValue
+	case av.IntValue != nil:
+		return int64(*av.IntValue)
+	case av.DoubleValue != nil:
+		return *av.DoubleValue
+	case av.ArrayValue != nil:
+		values := make([]interface{}, 0, len(av.ArrayValue.Values))
+		for _, v := range av.ArrayValue.Values {
+			values = append(values, plainValue(v))
+		}
+		return values
+	case av.KvlistValue != nil:
+		values := make(map[string]interface{}, len(av.KvlistValue.Values))
+		for _, kv := range av.KvlistValue.Values {
+			if kv != nil {
+				values[kv.Key] = plainValue(kv.Value)
+			}
+		}
+		return values
+	case av.BytesValue != nil:
+		return av.BytesValue
+	}
+	return nil
+}
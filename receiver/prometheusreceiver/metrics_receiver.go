@@ -22,6 +22,7 @@ import (
 	"github.com/prometheus/prometheus/scrape"
 	"github.com/prometheus/prometheus/storage"
 	"go.uber.org/zap"
+	"net/url"
 	"sync"
 	"time"
 
@@ -40,6 +41,22 @@ type Configuration struct {
 	BufferPeriod  time.Duration  `mapstructure:"buffer_period"`
 	BufferCount   int            `mapstructure:"buffer_count"`
 	AdjustMetrics bool           `mapstructure:"adjust_metrics"`
+	// Targets is a shorthand for simple scrape configurations, it is only
+	// used when no Prometheus config is given.
+	Targets []ScrapeTarget `mapstructure:"targets"`
+}
+
+// ScrapeTarget is a Prometheus metrics endpoint scraped without any of the
+// discovery and relabeling features of a Prometheus scrape config.
+type ScrapeTarget struct {
+	// JobName identifies the target, it defaults to the host and path of URL.
+	JobName string `mapstructure:"job_name"`
+	// URL of the metrics endpoint, e.g. "http://localhost:9090/metrics".
+	URL string `mapstructure:"url"`
+	// ScrapeInterval defaults to the Prometheus default of 1m.
+	ScrapeInterval time.Duration `mapstructure:"scrape_interval"`
+	// Labels are added to, and override, the labels of every scraped metric.
+	Labels map[string]string `mapstructure:"labels"`
 }
 
 // Preceiver is the type that provides Prometheus scraper/receiver functionality.
@@ -59,10 +76,15 @@ var (
 	errAlreadyStarted         = errors.New("already started the Prometheus receiver")
 	errNilMetricsReceiverSink = errors.New("expecting a non-nil MetricsReceiverSink")
 	errNilScrapeConfig        = errors.New("expecting a non-nil ScrapeConfig")
+	errConfigAndTargets       = errors.New("expecting either a Prometheus config or targets, not both")
 )
 
 const (
 	prometheusConfigKey = "config"
+
+	// defaultScrapeTimeout is the Prometheus default, it can't be longer
+	// than the scrape interval.
+	defaultScrapeTimeout = 10 * time.Second
 )
 
 // New creates a new prometheus.Receiver reference.
@@ -78,10 +100,20 @@ func New(logger *zap.Logger, v *viper.Viper, next consumer.MetricsConsumer) (*Pr
 	}
 
 	// Unmarshal prometheus's config values. Since prometheus uses `yaml` tags, so use `yaml`.
-	if !v.IsSet(prometheusConfigKey) {
+	var promCfgMap map[string]interface{}
+	switch {
+	case len(cfg.Targets) > 0 && v.IsSet(prometheusConfigKey):
+		return nil, errConfigAndTargets
+	case len(cfg.Targets) > 0:
+		promCfgMap, err = promConfigFromTargets(cfg.Targets)
+		if err != nil {
+			return nil, fmt.Errorf("prometheus receiver failed to parse targets: %s", err)
+		}
+	case v.IsSet(prometheusConfigKey):
+		promCfgMap = v.Sub(prometheusConfigKey).AllSettings()
+	default:
 		return nil, errNilScrapeConfig
 	}
-	promCfgMap := v.Sub(prometheusConfigKey).AllSettings()
 	out, err := yaml.Marshal(promCfgMap)
 	if err != nil {
 		return nil, fmt.Errorf("prometheus receiver failed to marshal config to yaml: %s", err)
@@ -101,6 +133,59 @@ func New(logger *zap.Logger, v *viper.Viper, next consumer.MetricsConsumer) (*Pr
 	return pr, nil
 }
 
+// promConfigFromTargets returns the Prometheus config, in the form it is
+// given in the receiver configuration, scraping the targets.
+func promConfigFromTargets(targets []ScrapeTarget) (map[string]interface{}, error) {
+	jobs := make([]interface{}, 0, len(targets))
+	jobNames := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		u, err := url.Parse(target.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("target URL %q is not an http or https URL", target.URL)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("target URL %q has no host", target.URL)
+		}
+		jobName := target.JobName
+		if jobName == "" {
+			jobName = u.Host + u.Path
+		}
+		if jobNames[jobName] {
+			return nil, fmt.Errorf("duplicate job name %q", jobName)
+		}
+		jobNames[jobName] = true
+
+		staticConfig := map[string]interface{}{"targets": []string{u.Host}}
+		if len(target.Labels) > 0 {
+			staticConfig["labels"] = target.Labels
+		}
+		job := map[string]interface{}{
+			"job_name":       jobName,
+			"scheme":         u.Scheme,
+			"static_configs": []interface{}{staticConfig},
+		}
+		if u.Path != "" {
+			job["metrics_path"] = u.Path
+		}
+		if len(u.Query()) > 0 {
+			job["params"] = map[string][]string(u.Query())
+		}
+		if target.ScrapeInterval > 0 {
+			// Prometheus durations have a single unit.
+			interval := fmt.Sprintf("%dms", target.ScrapeInterval/time.Millisecond)
+			job["scrape_interval"] = interval
+			if target.ScrapeInterval < defaultScrapeTimeout {
+				job["scrape_timeout"] = interval
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	return map[string]interface{}{"scrape_configs": jobs}, nil
+}
+
 const metricsSource string = "Prometheus"
 
 // MetricsSource returns the name of the metrics data source.
@@ -37,6 +37,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var logger, _ = zap.NewDevelopment()
@@ -1033,3 +1034,197 @@ func TestEndToEnd(t *testing.T) {
 		tt.validateFunc(t, tt, result)
 	}
 }
+
+func TestNewWithTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			yaml: `
+targets:
+  - url: "http://localhost:9090/metrics?format=text"
+    scrape_interval: 5s
+    labels:
+      env: prod
+  - url: "https://localhost:9091/metrics"
+`,
+		},
+		{
+			name: "config_and_targets",
+			yaml: `
+targets:
+  - url: "http://localhost:9090/metrics"
+config:
+  scrape_configs:
+    - job_name: "agent"
+      static_configs:
+        - targets: ['localhost:9988']
+`,
+			wantErr: true,
+		},
+		{
+			name: "no_scheme",
+			yaml: `
+targets:
+  - url: "localhost:9090/metrics"
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate_job_names",
+			yaml: `
+targets:
+  - url: "http://localhost:9090/metrics"
+  - url: "http://localhost:9090/metrics"
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			if err := viperutils.LoadYAMLBytes(v, []byte(tt.yaml)); err != nil {
+				t.Fatalf("Failed to load yaml config into viper: %v", err)
+			}
+			pr, err := New(logger, v, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			scrapeConfigs := pr.cfg.ScrapeConfig.ScrapeConfigs
+			if len(scrapeConfigs) != 2 {
+				t.Fatalf("Got %d scrape configs, want 2", len(scrapeConfigs))
+			}
+			sc := scrapeConfigs[0]
+			if g, w := sc.JobName, "localhost:9090/metrics"; g != w {
+				t.Errorf("JobName = %q, want %q", g, w)
+			}
+			if g, w := sc.Params.Get("format"), "text"; g != w {
+				t.Errorf("Params[format] = %q, want %q", g, w)
+			}
+			if g, w := time.Duration(sc.ScrapeInterval), 5*time.Second; g != w {
+				t.Errorf("ScrapeInterval = %v, want %v", g, w)
+			}
+			if g, w := time.Duration(sc.ScrapeTimeout), 5*time.Second; g != w {
+				t.Errorf("ScrapeTimeout = %v, want %v", g, w)
+			}
+			if g, w := string(sc.ServiceDiscoveryConfig.StaticConfigs[0].Labels["env"]), "prod"; g != w {
+				t.Errorf("Labels[env] = %q, want %q", g, w)
+			}
+			if g, w := scrapeConfigs[1].Scheme, "https"; g != w {
+				t.Errorf("Scheme = %q, want %q", g, w)
+			}
+		})
+	}
+}
+
+var histogramPage1 = `
+# HELP go_threads Number of OS threads created
+# TYPE go_threads gauge
+go_threads 19
+
+# HELP http_request_duration_seconds A histogram of the request duration.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 10
+http_request_duration_seconds_bucket{le="0.5"} 20
+http_request_duration_seconds_bucket{le="1"} 30
+http_request_duration_seconds_bucket{le="+Inf"} 40
+http_request_duration_seconds_sum 10
+http_request_duration_seconds_count 40
+`
+
+var histogramPage2 = `
+# HELP go_threads Number of OS threads created
+# TYPE go_threads gauge
+go_threads 18
+
+# HELP http_request_duration_seconds A histogram of the request duration.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 15
+http_request_duration_seconds_bucket{le="0.5"} 30
+http_request_duration_seconds_bucket{le="1"} 45
+http_request_duration_seconds_bucket{le="+Inf"} 60
+http_request_duration_seconds_sum 20
+http_request_duration_seconds_count 60
+`
+
+// TestEndToEndWithTargets scrapes a target given as a ScrapeTarget and
+// verifies that its histogram is broken into buckets carrying the target
+// labels.
+func TestEndToEndWithTargets(t *testing.T) {
+	mp := newMockPrometheus(map[string][]mockPrometheusResponse{
+		"/histogram/metrics": {
+			{code: 200, data: histogramPage1},
+			{code: 200, data: histogramPage2},
+		},
+	})
+	defer mp.Close()
+
+	v := viper.New()
+	v.Set("targets", []map[string]interface{}{
+		{
+			"job_name":        "histogram",
+			"url":             mp.srv.URL + "/histogram/metrics",
+			"scrape_interval": "1s",
+			"labels":          map[string]string{"env": "prod"},
+		},
+	})
+
+	cms := new(exportertest.SinkMetricsExporter)
+	precv, err := New(logger, v, cms)
+	if err != nil {
+		t.Fatalf("Failed to create promreceiver: %v", err)
+	}
+	if err := precv.StartMetricsReception(context.Background(), nil); err != nil {
+		t.Fatalf("Failed to invoke StartMetricsReception: %v", err)
+	}
+	defer precv.StopMetricsReception(context.Background())
+
+	mp.wg.Wait()
+
+	var histogram *metricspb.Metric
+	for _, md := range cms.AllMetrics() {
+		if g, w := md.Node.GetServiceInfo().GetName(), "histogram"; g != w {
+			t.Errorf("Node service name = %q, want %q", g, w)
+		}
+		for _, metric := range md.Metrics {
+			if metric.MetricDescriptor.Name == "http_request_duration_seconds" {
+				histogram = metric
+			}
+		}
+	}
+	if histogram == nil {
+		t.Fatalf("The histogram was not received: %v", cms.AllMetrics())
+	}
+
+	if g, w := histogram.MetricDescriptor.Type, metricspb.MetricDescriptor_CUMULATIVE_DISTRIBUTION; g != w {
+		t.Errorf("Type = %v, want %v", g, w)
+	}
+	labelKeys := histogram.MetricDescriptor.LabelKeys
+	labelValues := histogram.Timeseries[0].LabelValues
+	if len(labelKeys) != 1 || labelKeys[0].Key != "env" || labelValues[0].Value != "prod" {
+		t.Errorf("Got label keys %v and values %v, want env=prod", labelKeys, labelValues)
+	}
+
+	// The values are the deltas with the first scrape.
+	dist := histogram.Timeseries[0].Points[0].GetDistributionValue()
+	if g, w := dist.GetBucketOptions().GetExplicit().GetBounds(), []float64{0.05, 0.5, 1}; !reflect.DeepEqual(g, w) {
+		t.Errorf("Bounds = %v, want %v", g, w)
+	}
+	var bucketCounts []int64
+	for _, bucket := range dist.GetBuckets() {
+		bucketCounts = append(bucketCounts, bucket.Count)
+	}
+	if g, w := bucketCounts, []int64{5, 5, 5, 5}; !reflect.DeepEqual(g, w) {
+		t.Errorf("Bucket counts = %v, want %v", g, w)
+	}
+	if dist.Count != 20 || dist.Sum != 10 {
+		t.Errorf("Count, sum = %d, %v, want 20, 10", dist.Count, dist.Sum)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsdreceiver
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// timerPercentiles are computed for every timer and exported as gauges named
+// after the timer with a "_p<percentile>" suffix.
+var timerPercentiles = []int{50, 95, 99}
+
+// series aggregates the values received for one metric name, type and set of
+// tags.
+type series struct {
+	name        string
+	typ         metricType
+	labelKeys   []*metricspb.LabelKey
+	labelValues []*metricspb.LabelValue
+	start       time.Time
+
+	// value is the counter total or the gauge value.
+	value float64
+	// samples are the timer values received since the last flush.
+	samples []float64
+	// members are the set members received since the last flush.
+	members map[string]struct{}
+}
+
+// aggregator holds the state of the StatsD metrics between two flushes,
+// counters and gauges are kept across flushes while timers and sets only
+// report the values received since the previous flush.
+type aggregator struct {
+	series map[string]*series
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{series: make(map[string]*series)}
+}
+
+func (a *aggregator) add(m *statsDMetric, now time.Time) {
+	typ := m.typ
+	if typ == histogramType {
+		typ = timerType
+	}
+
+	// Sort the tags so that their order in the packet doesn't matter.
+	idx := make([]int, len(m.tagKeys))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return m.tagKeys[idx[i]] < m.tagKeys[idx[j]] })
+
+	var key strings.Builder
+	key.WriteString(m.name)
+	key.WriteString("|")
+	key.WriteString(string(typ))
+	for _, i := range idx {
+		key.WriteString("|")
+		key.WriteString(m.tagKeys[i])
+		key.WriteString("=")
+		key.WriteString(m.tagValues[i])
+	}
+
+	s, ok := a.series[key.String()]
+	if !ok {
+		s = &series{name: m.name, typ: typ, start: now}
+		for _, i := range idx {
+			s.labelKeys = append(s.labelKeys, &metricspb.LabelKey{Key: m.tagKeys[i]})
+			s.labelValues = append(s.labelValues, &metricspb.LabelValue{Value: m.tagValues[i], HasValue: true})
+		}
+		a.series[key.String()] = s
+	}
+
+	switch typ {
+	case counterType:
+		s.value += m.floatValue() / m.sampleRate
+	case gaugeType:
+		if m.delta {
+			s.value += m.floatValue()
+		} else {
+			s.value = m.floatValue()
+		}
+	case timerType:
+		s.samples = append(s.samples, m.floatValue())
+	case setType:
+		if s.members == nil {
+			s.members = make(map[string]struct{})
+		}
+		s.members[m.value] = struct{}{}
+	}
+}
+
+// flush returns the metrics for the current state and resets the timers and
+// the sets.
+func (a *aggregator) flush(now time.Time) []*metricspb.Metric {
+	var metrics []*metricspb.Metric
+	for key, s := range a.series {
+		switch s.typ {
+		case counterType:
+			metrics = append(metrics, s.metric(s.name, "1", metricspb.MetricDescriptor_CUMULATIVE_DOUBLE, s.start,
+				&metricspb.Point{Timestamp: internal.TimeToTimestamp(now), Value: &metricspb.Point_DoubleValue{DoubleValue: s.value}}))
+		case gaugeType:
+			metrics = append(metrics, s.metric(s.name, "1", metricspb.MetricDescriptor_GAUGE_DOUBLE, time.Time{},
+				&metricspb.Point{Timestamp: internal.TimeToTimestamp(now), Value: &metricspb.Point_DoubleValue{DoubleValue: s.value}}))
+		case timerType:
+			if len(s.samples) == 0 {
+				// Nothing was received since the previous flush.
+				delete(a.series, key)
+				continue
+			}
+			sort.Float64s(s.samples)
+			for _, p := range timerPercentiles {
+				metrics = append(metrics, s.metric(s.name+"_p"+strconv.Itoa(p), "ms", metricspb.MetricDescriptor_GAUGE_DOUBLE, time.Time{},
+					&metricspb.Point{Timestamp: internal.TimeToTimestamp(now), Value: &metricspb.Point_DoubleValue{DoubleValue: percentile(s.samples, p)}}))
+			}
+			s.samples = s.samples[:0]
+		case setType:
+			if len(s.members) == 0 {
+				delete(a.series, key)
+				continue
+			}
+			metrics = append(metrics, s.metric(s.name, "1", metricspb.MetricDescriptor_GAUGE_INT64, time.Time{},
+				&metricspb.Point{Timestamp: internal.TimeToTimestamp(now), Value: &metricspb.Point_Int64Value{Int64Value: int64(len(s.members))}}))
+			s.members = nil
+		}
+	}
+	return metrics
+}
+
+func (s *series) metric(name, unit string, typ metricspb.MetricDescriptor_Type, start time.Time, point *metricspb.Point) *metricspb.Metric {
+	ts := &metricspb.TimeSeries{
+		LabelValues: s.labelValues,
+		Points:      []*metricspb.Point{point},
+	}
+	if !start.IsZero() {
+		ts.StartTimestamp = internal.TimeToTimestamp(start)
+	}
+	return &metricspb.Metric{
+		MetricDescriptor: &metricspb.MetricDescriptor{
+			Name:      name,
+			Unit:      unit,
+			Type:      typ,
+			LabelKeys: s.labelKeys,
+		},
+		Timeseries: []*metricspb.TimeSeries{ts},
+	}
+}
+
+// percentile returns the nearest-rank percentile of the sorted samples.
+func percentile(sorted []float64, p int) float64 {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
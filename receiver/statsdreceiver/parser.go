@@ -0,0 +1,133 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsdreceiver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type metricType string
+
+const (
+	counterType metricType = "c"
+	gaugeType   metricType = "g"
+	timerType   metricType = "ms"
+	// histogramType is the DogStatsD histogram, handled like a timer.
+	histogramType metricType = "h"
+	setType       metricType = "s"
+)
+
+// statsDMetric is a single line of the StatsD wire format, that is
+// <name>:<value>|<type>[|@<sample rate>][|#<tag>:<value>,...]
+type statsDMetric struct {
+	name  string
+	value string
+	// delta is true for a gauge value with an explicit sign, it is then
+	// added to the current value instead of replacing it.
+	delta      bool
+	typ        metricType
+	sampleRate float64
+	tagKeys    []string
+	tagValues  []string
+}
+
+var (
+	errEmptyName   = errors.New("empty metric name")
+	errNoValue     = errors.New("missing value")
+	errNoType      = errors.New("missing metric type")
+	errSampleRate  = errors.New("sample rate must be in (0, 1]")
+	errTagsInvalid = errors.New("invalid tags")
+)
+
+func parseMessage(line string) (*statsDMetric, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return nil, errNoType
+	}
+
+	sep := strings.LastIndex(parts[0], ":")
+	if sep < 0 {
+		return nil, errNoValue
+	}
+	m := &statsDMetric{
+		name:       parts[0][:sep],
+		value:      parts[0][sep+1:],
+		typ:        metricType(parts[1]),
+		sampleRate: 1,
+	}
+	if m.name == "" {
+		return nil, errEmptyName
+	}
+	if m.value == "" {
+		return nil, errNoValue
+	}
+
+	switch m.typ {
+	case counterType, gaugeType, timerType, histogramType:
+		if m.typ == gaugeType && (m.value[0] == '+' || m.value[0] == '-') {
+			m.delta = true
+		}
+		if _, err := strconv.ParseFloat(m.value, 64); err != nil {
+			return nil, fmt.Errorf("invalid value %q: %v", m.value, err)
+		}
+	case setType:
+	default:
+		return nil, fmt.Errorf("unsupported metric type %q", m.typ)
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, errSampleRate
+			}
+			m.sampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			if err := m.parseTags(part[1:]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unrecognized field %q", part)
+		}
+	}
+	return m, nil
+}
+
+// parseTags parses DogStatsD style tags, a tag without a value gets an empty
+// value.
+func (m *statsDMetric) parseTags(tags string) error {
+	for _, tag := range strings.Split(tags, ",") {
+		kv := strings.SplitN(tag, ":", 2)
+		if kv[0] == "" {
+			return errTagsInvalid
+		}
+		value := ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		m.tagKeys = append(m.tagKeys, kv[0])
+		m.tagValues = append(m.tagValues, value)
+	}
+	return nil
+}
+
+func (m *statsDMetric) floatValue() float64 {
+	f, _ := strconv.ParseFloat(m.value, 64)
+	return f
+}
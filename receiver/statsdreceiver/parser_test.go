@@ -0,0 +1,72 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsdreceiver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    *statsDMetric
+		wantErr bool
+	}{
+		{
+			line: "page.views:1|c",
+			want: &statsDMetric{name: "page.views", value: "1", typ: counterType, sampleRate: 1},
+		},
+		{
+			line: "page.views:2|c|@0.1",
+			want: &statsDMetric{name: "page.views", value: "2", typ: counterType, sampleRate: 0.1},
+		},
+		{
+			line: "temperature:-3.5|g",
+			want: &statsDMetric{name: "temperature", value: "-3.5", delta: true, typ: gaugeType, sampleRate: 1},
+		},
+		{
+			line: "latency:320|ms|#env:prod,canary",
+			want: &statsDMetric{
+				name: "latency", value: "320", typ: timerType, sampleRate: 1,
+				tagKeys: []string{"env", "canary"}, tagValues: []string{"prod", ""},
+			},
+		},
+		{
+			line: "users:alice|s",
+			want: &statsDMetric{name: "users", value: "alice", typ: setType, sampleRate: 1},
+		},
+		{line: "page.views", wantErr: true},
+		{line: "page.views|c", wantErr: true},
+		{line: ":1|c", wantErr: true},
+		{line: "page.views:|c", wantErr: true},
+		{line: "page.views:one|c", wantErr: true},
+		{line: "page.views:1|x", wantErr: true},
+		{line: "page.views:1|c|@0", wantErr: true},
+		{line: "page.views:1|c|@2", wantErr: true},
+		{line: "page.views:1|c|#:prod", wantErr: true},
+		{line: "page.views:1|c|unknown", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseMessage(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMessage(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMessage(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
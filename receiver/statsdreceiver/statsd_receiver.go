@@ -0,0 +1,214 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsdreceiver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/consumer"
+	"github.com/census-instrumentation/opencensus-service/data"
+	"github.com/census-instrumentation/opencensus-service/receiver"
+)
+
+var (
+	errNilNextConsumer = errors.New("nil nextConsumer")
+	errAlreadyStarted  = errors.New("already started")
+	errAlreadyStopped  = errors.New("already stopped")
+)
+
+const (
+	// DefaultAddress is the address StatsD clients send to by default.
+	DefaultAddress = ":8125"
+	// DefaultFlushInterval is how often the aggregated metrics are passed
+	// onto the next consumer by default.
+	DefaultFlushInterval = 10 * time.Second
+
+	maxPacketSize = 65535
+	metricsSource = "StatsD"
+)
+
+var _ receiver.MetricsReceiver = (*statsDReceiver)(nil)
+
+// statsDReceiver implements the receiver.MetricsReceiver for the StatsD UDP
+// protocol.
+type statsDReceiver struct {
+	sync.Mutex
+	addr          string
+	flushInterval time.Duration
+	nextConsumer  consumer.MetricsConsumer
+
+	conn net.PacketConn
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	aggMu      sync.Mutex
+	aggregator *aggregator
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// New creates the StatsD receiver listening for UDP packets on addr and
+// passing the aggregated metrics onto nextConsumer every flushInterval.
+func New(addr string, flushInterval time.Duration, nextConsumer consumer.MetricsConsumer) (receiver.MetricsReceiver, error) {
+	return newStatsDReceiver(addr, flushInterval, nextConsumer)
+}
+
+func newStatsDReceiver(addr string, flushInterval time.Duration, nextConsumer consumer.MetricsConsumer) (*statsDReceiver, error) {
+	if nextConsumer == nil {
+		return nil, errNilNextConsumer
+	}
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+
+	r := &statsDReceiver{
+		addr:          addr,
+		flushInterval: flushInterval,
+		nextConsumer:  nextConsumer,
+		done:          make(chan struct{}),
+		aggregator:    newAggregator(),
+	}
+	return r, nil
+}
+
+// MetricsSource returns the name of the metrics data source.
+func (r *statsDReceiver) MetricsSource() string {
+	return metricsSource
+}
+
+// StartMetricsReception starts listening for StatsD packets and flushing the
+// aggregated metrics.
+func (r *statsDReceiver) StartMetricsReception(ctx context.Context, asyncErrorChan chan<- error) error {
+	r.Lock()
+	defer r.Unlock()
+
+	err := errAlreadyStarted
+	r.startOnce.Do(func() {
+		r.conn, err = net.ListenPacket("udp", r.addr)
+		if err != nil {
+			return
+		}
+
+		r.wg.Add(2)
+		go r.readPackets(asyncErrorChan)
+		go r.flushPeriodically()
+	})
+	return err
+}
+
+// StopMetricsReception stops listening and flushes the metrics received so far.
+func (r *statsDReceiver) StopMetricsReception(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+
+	var err = errAlreadyStopped
+	r.stopOnce.Do(func() {
+		close(r.done)
+		err = nil
+		if r.conn != nil {
+			err = r.conn.Close()
+		}
+		r.wg.Wait()
+		r.flush()
+	})
+	return err
+}
+
+func (r *statsDReceiver) readPackets(asyncErrorChan chan<- error) {
+	defer r.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := r.conn.ReadFrom(buf)
+		if n > 0 {
+			r.handlePacket(string(buf[:n]))
+		}
+		if err != nil {
+			select {
+			case <-r.done:
+			default:
+				if asyncErrorChan != nil {
+					asyncErrorChan <- err
+				}
+			}
+			return
+		}
+	}
+}
+
+// handlePacket aggregates every metric of the packet, the malformed ones are
+// logged and dropped.
+func (r *statsDReceiver) handlePacket(packet string) {
+	now := time.Now()
+
+	r.aggMu.Lock()
+	defer r.aggMu.Unlock()
+
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m, err := parseMessage(line)
+		if err != nil {
+			log.Printf("StatsD receiver dropped %q: %v", line, err)
+			continue
+		}
+		r.aggregator.add(m, now)
+	}
+}
+
+func (r *statsDReceiver) flushPeriodically() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.flush()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *statsDReceiver) flush() {
+	r.aggMu.Lock()
+	metrics := r.aggregator.flush(time.Now())
+	r.aggMu.Unlock()
+
+	if len(metrics) == 0 {
+		return
+	}
+
+	ctx, span := trace.StartSpan(context.Background(), "StatsDReceiver.flush")
+	defer span.End()
+	if err := r.nextConsumer.ConsumeMetricsData(ctx, data.MetricsData{Metrics: metrics}); err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+}
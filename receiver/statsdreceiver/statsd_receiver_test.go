@@ -0,0 +1,172 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsdreceiver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	metricspb "github.com/census-instrumentation/opencensus-proto/gen-go/metrics/v1"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New("", 0, nil); err != errNilNextConsumer {
+		t.Errorf("New() with a nil nextConsumer error = %v, want %v", err, errNilNextConsumer)
+	}
+
+	r, err := newStatsDReceiver("", 0, new(exportertest.SinkMetricsExporter))
+	if err != nil {
+		t.Fatalf("newStatsDReceiver() error = %v", err)
+	}
+	if r.addr != DefaultAddress || r.flushInterval != DefaultFlushInterval {
+		t.Errorf("Got address %q and flush interval %v, want the defaults", r.addr, r.flushInterval)
+	}
+}
+
+func TestStatsDReceiver_endToEnd(t *testing.T) {
+	sink := new(exportertest.SinkMetricsExporter)
+	r, err := newStatsDReceiver("localhost:0", time.Hour, sink)
+	if err != nil {
+		t.Fatalf("newStatsDReceiver() error = %v", err)
+	}
+	if err := r.StartMetricsReception(context.Background(), nil); err != nil {
+		t.Fatalf("StartMetricsReception() error = %v", err)
+	}
+	defer r.StopMetricsReception(context.Background())
+
+	lines := []string{
+		"page.views:1|c",
+		"page.views:1|c",
+		"page.views:2|c|@0.5",
+		"requests:3|c|#region:eu,env:prod",
+		"temperature:20|g",
+		"temperature:+5|g",
+		"temperature:-3|g",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"not a metric",
+		"page.views:x|c",
+	}
+	for i := 100; i > 0; i-- {
+		lines = append(lines, fmt.Sprintf("latency:%d|ms", i))
+	}
+
+	conn, err := net.Dial("udp", r.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial the receiver: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(lines, "\n"))); err != nil {
+		t.Fatalf("Failed to send the packet: %v", err)
+	}
+
+	// The whole packet is aggregated at once.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		r.aggMu.Lock()
+		received := len(r.aggregator.series)
+		r.aggMu.Unlock()
+		if received > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.flush()
+
+	got := make(map[string]*metricspb.Metric)
+	for _, md := range sink.AllMetrics() {
+		for _, metric := range md.Metrics {
+			got[metric.MetricDescriptor.Name] = metric
+		}
+	}
+
+	wantDoubles := map[string]struct {
+		typ   metricspb.MetricDescriptor_Type
+		value float64
+	}{
+		"page.views":  {metricspb.MetricDescriptor_CUMULATIVE_DOUBLE, 6},
+		"requests":    {metricspb.MetricDescriptor_CUMULATIVE_DOUBLE, 3},
+		"temperature": {metricspb.MetricDescriptor_GAUGE_DOUBLE, 22},
+		"latency_p50": {metricspb.MetricDescriptor_GAUGE_DOUBLE, 50},
+		"latency_p95": {metricspb.MetricDescriptor_GAUGE_DOUBLE, 95},
+		"latency_p99": {metricspb.MetricDescriptor_GAUGE_DOUBLE, 99},
+	}
+	if len(got) != len(wantDoubles)+1 {
+		t.Errorf("Got %d metrics, want %d: %v", len(got), len(wantDoubles)+1, got)
+	}
+	for name, want := range wantDoubles {
+		metric, ok := got[name]
+		if !ok {
+			t.Errorf("Metric %q is missing", name)
+			continue
+		}
+		if metric.MetricDescriptor.Type != want.typ {
+			t.Errorf("%s: type = %v, want %v", name, metric.MetricDescriptor.Type, want.typ)
+		}
+		if g := metric.Timeseries[0].Points[0].GetDoubleValue(); g != want.value {
+			t.Errorf("%s: value = %v, want %v", name, g, want.value)
+		}
+	}
+
+	if g := got["users"].GetTimeseries()[0].Points[0].GetInt64Value(); g != 2 {
+		t.Errorf("users: got %d unique members, want 2", g)
+	}
+
+	requests := got["requests"]
+	var keys, values []string
+	for i, key := range requests.GetMetricDescriptor().GetLabelKeys() {
+		keys = append(keys, key.Key)
+		values = append(values, requests.Timeseries[0].LabelValues[i].Value)
+	}
+	if g, w := strings.Join(keys, ","), "env,region"; g != w {
+		t.Errorf("requests: label keys = %q, want %q", g, w)
+	}
+	if g, w := strings.Join(values, ","), "prod,eu"; g != w {
+		t.Errorf("requests: label values = %q, want %q", g, w)
+	}
+}
+
+func TestAggregatorFlushResetsTimersAndSets(t *testing.T) {
+	a := newAggregator()
+	now := time.Now()
+	for _, line := range []string{"hits:1|c", "level:7|g", "latency:10|ms", "users:alice|s"} {
+		m, err := parseMessage(line)
+		if err != nil {
+			t.Fatalf("parseMessage(%q) error = %v", line, err)
+		}
+		a.add(m, now)
+	}
+
+	if got := len(a.flush(now)); got != 6 {
+		t.Errorf("First flush returned %d metrics, want 6", got)
+	}
+
+	// Only the counter and the gauge are reported again.
+	second := a.flush(now)
+	names := make(map[string]bool)
+	for _, metric := range second {
+		names[metric.MetricDescriptor.Name] = true
+	}
+	if len(second) != 2 || !names["hits"] || !names["level"] {
+		t.Errorf("Second flush returned %v, want only hits and level", second)
+	}
+}
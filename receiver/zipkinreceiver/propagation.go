@@ -0,0 +1,206 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkinreceiver
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opencensus.io/plugin/ochttp/propagation/b3"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+	xrayTraceHeader   = "X-Amzn-Trace-Id"
+
+	// traceparentLen is the length of a version 00 traceparent, later
+	// versions can only append fields to it.
+	traceparentLen = 55
+	maxTracestate  = 32
+)
+
+var (
+	errInvalidTraceparent     = errors.New("invalid traceparent header")
+	errInvalidXRayTraceHeader = errors.New("invalid X-Amzn-Trace-Id header")
+)
+
+// callerSpanContext returns the span context propagated by the client that
+// sent the spans, if any. The W3C Trace Context headers are preferred over
+// the AWS X-Ray one, itself preferred over the Zipkin B3 ones. An invalid
+// traceparent or X-Amzn-Trace-Id header is an error.
+func callerSpanContext(r *http.Request) (sc trace.SpanContext, ok bool, err error) {
+	if tp := r.Header.Get(traceparentHeader); tp != "" {
+		sc, err = parseTraceparent(tp)
+		if err != nil {
+			return trace.SpanContext{}, false, err
+		}
+		// An invalid tracestate must not prevent the traceparent from
+		// being used, see https://www.w3.org/TR/trace-context/#tracestate-header.
+		sc.Tracestate = parseTracestate(r.Header[http.CanonicalHeaderKey(tracestateHeader)])
+		return sc, true, nil
+	}
+
+	if xh := r.Header.Get(xrayTraceHeader); xh != "" {
+		return parseXRayTraceHeader(xh)
+	}
+
+	sc, ok = (&b3.HTTPFormat{}).SpanContextFromRequest(r)
+	return sc, ok, nil
+}
+
+// callerLink returns the parent link to the span context of the client, with
+// its tracestate, if any, as the "tracestate" attribute.
+func callerLink(sc trace.SpanContext) trace.Link {
+	link := trace.Link{
+		TraceID: sc.TraceID,
+		SpanID:  sc.SpanID,
+		Type:    trace.LinkTypeParent,
+	}
+	if sc.Tracestate != nil {
+		var members []string
+		for _, entry := range sc.Tracestate.Entries() {
+			members = append(members, entry.Key+"="+entry.Value)
+		}
+		link.Attributes = map[string]interface{}{tracestateHeader: strings.Join(members, ",")}
+	}
+	return link
+}
+
+// parseTraceparent parses a traceparent header as specified by
+// https://www.w3.org/TR/trace-context/#traceparent-header. The fields added
+// by versions later than 00 are ignored.
+func parseTraceparent(tp string) (trace.SpanContext, error) {
+	var sc trace.SpanContext
+	if len(tp) < traceparentLen {
+		return sc, errInvalidTraceparent
+	}
+
+	version, err := hex.DecodeString(tp[0:2])
+	if err != nil || version[0] == 0xff || !isLowerHex(tp[0:2]) {
+		return sc, errInvalidTraceparent
+	}
+	if version[0] == 0 && len(tp) != traceparentLen {
+		return sc, errInvalidTraceparent
+	}
+	if len(tp) > traceparentLen && tp[traceparentLen] != '-' {
+		return sc, errInvalidTraceparent
+	}
+
+	parts := strings.Split(tp[:traceparentLen], "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errInvalidTraceparent
+	}
+	for _, part := range parts[1:] {
+		if !isLowerHex(part) {
+			return sc, errInvalidTraceparent
+		}
+	}
+
+	hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	if sc.TraceID == (trace.TraceID{}) || sc.SpanID == (trace.SpanID{}) {
+		return sc, fmt.Errorf("%v: all zero trace or parent ID", errInvalidTraceparent)
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	sc.TraceOptions = trace.TraceOptions(flags[0] & 0x01)
+	return sc, nil
+}
+
+// parseXRayTraceHeader parses a header such as
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+// see https://docs.aws.amazon.com/xray/latest/devguide/xray-concepts.html#xray-concepts-tracingheader.
+// The X-Ray trace ID, its epoch seconds followed by 96 random bits, is used as
+// is for the 128 bits trace ID. A header without a Parent, as set by the first
+// hop, doesn't identify a span and is ignored.
+func parseXRayTraceHeader(header string) (sc trace.SpanContext, ok bool, err error) {
+	var root, parent string
+	for _, field := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			root = kv[1]
+		case "Parent":
+			parent = kv[1]
+		case "Sampled":
+			if kv[1] == "1" {
+				sc.TraceOptions = 1
+			}
+		}
+	}
+
+	// The root is "1-<8 hex digits>-<24 hex digits>".
+	rootParts := strings.Split(root, "-")
+	if len(rootParts) != 3 || rootParts[0] != "1" || len(rootParts[1]) != 8 || len(rootParts[2]) != 24 {
+		return trace.SpanContext{}, false, errInvalidXRayTraceHeader
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(rootParts[1]+rootParts[2])); err != nil {
+		return trace.SpanContext{}, false, errInvalidXRayTraceHeader
+	}
+
+	if parent == "" {
+		return trace.SpanContext{}, false, nil
+	}
+	if len(parent) != 16 {
+		return trace.SpanContext{}, false, errInvalidXRayTraceHeader
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parent)); err != nil {
+		return trace.SpanContext{}, false, errInvalidXRayTraceHeader
+	}
+	return sc, true, nil
+}
+
+// parseTracestate returns nil if any of the tracestate headers is invalid.
+func parseTracestate(headers []string) *tracestate.Tracestate {
+	var entries []tracestate.Entry
+	for _, header := range headers {
+		for _, member := range strings.Split(header, ",") {
+			member = strings.TrimSpace(member)
+			if member == "" {
+				continue
+			}
+			kv := strings.SplitN(member, "=", 2)
+			if len(kv) != 2 {
+				return nil
+			}
+			entries = append(entries, tracestate.Entry{Key: kv[0], Value: kv[1]})
+		}
+	}
+	if len(entries) == 0 || len(entries) > maxTracestate {
+		return nil
+	}
+	ts, err := tracestate.New(nil, entries...)
+	if err != nil {
+		return nil
+	}
+	return ts
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
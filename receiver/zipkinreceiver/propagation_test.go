@@ -0,0 +1,246 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkinreceiver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/trace"
+
+	"github.com/census-instrumentation/opencensus-service/exporter/exportertest"
+)
+
+var (
+	w3cTraceID = trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	w3cSpanID  = trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    trace.SpanContext
+		wantErr bool
+	}{
+		{
+			name:   "version_00_sampled",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:   trace.SpanContext{TraceID: w3cTraceID, SpanID: w3cSpanID, TraceOptions: 1},
+		},
+		{
+			name:   "version_00_not_sampled",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want:   trace.SpanContext{TraceID: w3cTraceID, SpanID: w3cSpanID},
+		},
+		{
+			name:   "future_version",
+			header: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-09",
+			want:   trace.SpanContext{TraceID: w3cTraceID, SpanID: w3cSpanID, TraceOptions: 1},
+		},
+		{
+			name:   "future_version_with_more_fields",
+			header: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-holds",
+			want:   trace.SpanContext{TraceID: w3cTraceID, SpanID: w3cSpanID, TraceOptions: 1},
+		},
+		{name: "version_00_with_more_fields", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", wantErr: true},
+		{name: "future_version_bad_separator", header: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x", wantErr: true},
+		{name: "forbidden_version", header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "uppercase", header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", wantErr: true},
+		{name: "zero_trace_id", header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "zero_parent_id", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		{name: "short", header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", wantErr: true},
+		{name: "bad_field_lengths", header: "00-4bf92f3577b34da6a3ce929d0e0e47360-0f067aa0ba902b7-01", wantErr: true},
+		{name: "not_hex", header: "00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTraceparent(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTraceparent(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("parseTraceparent(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallerSpanContext(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("traceparent_without_tracestate", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		req.Header.Set("traceparent", traceparent)
+		sc, ok, err := callerSpanContext(req)
+		if err != nil || !ok {
+			t.Fatalf("callerSpanContext() = (%v, %v), want a span context", ok, err)
+		}
+		if sc.TraceID != w3cTraceID || sc.SpanID != w3cSpanID || sc.Tracestate != nil {
+			t.Errorf("Got %+v, want the traceparent IDs and no tracestate", sc)
+		}
+	})
+
+	t.Run("traceparent_with_tracestate", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		req.Header.Set("traceparent", traceparent)
+		req.Header.Add("tracestate", "congo=t61rcWkgMzE")
+		req.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+		sc, _, _ := callerSpanContext(req)
+		link := callerLink(sc)
+		if g, w := link.Attributes["tracestate"], "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"; g != w {
+			t.Errorf("tracestate = %v, want %q", g, w)
+		}
+	})
+
+	t.Run("invalid_tracestate_is_ignored", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		req.Header.Set("traceparent", traceparent)
+		req.Header.Set("tracestate", "not a list member")
+		sc, ok, err := callerSpanContext(req)
+		if err != nil || !ok || sc.Tracestate != nil {
+			t.Errorf("callerSpanContext() = (%+v, %v, %v), want the traceparent only", sc, ok, err)
+		}
+	})
+
+	t.Run("traceparent_preferred_over_b3", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		req.Header.Set("traceparent", traceparent)
+		req.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+		req.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+		sc, _, _ := callerSpanContext(req)
+		if sc.TraceID != w3cTraceID {
+			t.Errorf("TraceID = %v, want the traceparent one", sc.TraceID)
+		}
+	})
+
+	t.Run("b3", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		req.Header.Set("X-B3-TraceId", "4bf92f3577b34da6a3ce929d0e0e4736")
+		req.Header.Set("X-B3-SpanId", "00f067aa0ba902b7")
+		sc, ok, err := callerSpanContext(req)
+		if err != nil || !ok || sc.TraceID != w3cTraceID || sc.SpanID != w3cSpanID {
+			t.Errorf("callerSpanContext() = (%+v, %v, %v), want the B3 IDs", sc, ok, err)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+		if _, ok, err := callerSpanContext(req); ok || err != nil {
+			t.Errorf("callerSpanContext() = (%v, %v), want no span context", ok, err)
+		}
+	})
+}
+
+func TestParseXRayTraceHeader(t *testing.T) {
+	xrayTraceID := trace.TraceID{0x57, 0x59, 0xe9, 0x88, 0xbd, 0x86, 0x2e, 0x3f, 0xe1, 0xbe, 0x46, 0xa9, 0x94, 0x27, 0x27, 0x93}
+	xraySpanID := trace.SpanID{0x53, 0x99, 0x5c, 0x3f, 0x42, 0xcd, 0x8a, 0xd8}
+	tests := []struct {
+		name    string
+		header  string
+		want    trace.SpanContext
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:   "sampled",
+			header: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+			want:   trace.SpanContext{TraceID: xrayTraceID, SpanID: xraySpanID, TraceOptions: 1},
+			wantOK: true,
+		},
+		{
+			name:   "not_sampled",
+			header: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0",
+			want:   trace.SpanContext{TraceID: xrayTraceID, SpanID: xraySpanID},
+			wantOK: true,
+		},
+		{
+			name:   "field_order_and_unknown_fields",
+			header: "Self=1-67891234-12456789abcdef012345678;Sampled=1; Parent=53995c3f42cd8ad8;Root=1-5759e988-bd862e3fe1be46a994272793",
+			want:   trace.SpanContext{TraceID: xrayTraceID, SpanID: xraySpanID, TraceOptions: 1},
+			wantOK: true,
+		},
+		{name: "no_parent", header: "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1"},
+		{name: "no_root", header: "Parent=53995c3f42cd8ad8;Sampled=1", wantErr: true},
+		{name: "bad_root_version", header: "Root=2-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8", wantErr: true},
+		{name: "bad_root_epoch", header: "Root=1-5759e98-bd862e3fe1be46a9942727930;Parent=53995c3f42cd8ad8", wantErr: true},
+		{name: "bad_root_hex", header: "Root=1-5759e988-bd862e3fe1be46a99427279z;Parent=53995c3f42cd8ad8", wantErr: true},
+		{name: "bad_parent", header: "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8a", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseXRayTraceHeader(tt.header)
+			if (err != nil) != tt.wantErr || ok != tt.wantOK {
+				t.Fatalf("parseXRayTraceHeader(%q) = (%v, %v), want (%v, error %v)", tt.header, ok, err, tt.wantOK, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseXRayTraceHeader(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallerSpanContextPrecedence(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/v2/spans", nil)
+	req.Header.Set("X-Amzn-Trace-Id", "Root=1-4bf92f35-77b34da6a3ce929d0e0e4736;Parent=00f067aa0ba902b7;Sampled=0")
+	req.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+	req.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	req.Header.Set("X-B3-Sampled", "1")
+	sc, ok, err := callerSpanContext(req)
+	if err != nil || !ok {
+		t.Fatalf("callerSpanContext() = (%v, %v), want a span context", ok, err)
+	}
+	if sc.TraceID != w3cTraceID || sc.SpanID != w3cSpanID {
+		t.Errorf("Got %+v, want the X-Ray IDs over the B3 ones", sc)
+	}
+	if sc.IsSampled() {
+		t.Error("Sampled=0 should mark the span context as not sampled")
+	}
+
+	req.Header.Set("traceparent", "00-463ac35c9f6413ad48485a3953bb6124-a2fb4a1d1a96d312-01")
+	sc, _, _ = callerSpanContext(req)
+	if sc.TraceID == w3cTraceID {
+		t.Error("The traceparent header should be preferred over the X-Ray one")
+	}
+}
+
+func TestInvalidTraceparentIsRejected(t *testing.T) {
+	sink := new(exportertest.SinkTraceExporter)
+	zr, err := New(":0", sink)
+	if err != nil {
+		t.Fatalf("Failed to create the receiver: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v2/spans", strings.NewReader("[]"))
+	req.Header.Set("traceparent", "00-not-a-traceparent")
+	rec := httptest.NewRecorder()
+	zr.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v2/spans", strings.NewReader("[]"))
+	req.Header.Set("X-Amzn-Trace-Id", "Root=not-a-root")
+	rec = httptest.NewRecorder()
+	zr.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Got status %d for an invalid X-Amzn-Trace-Id, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := sink.AllTraces(); len(got) != 0 {
+		t.Errorf("Got traces %v, want none", got)
+	}
+}
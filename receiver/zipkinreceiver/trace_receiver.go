@@ -286,15 +286,47 @@ const (
 
 // The ZipkinReceiver receives spans from endpoint /api/v2 as JSON,
 // unmarshals them and sends them along to the nextConsumer.
+//
+// openapi:operation POST /api/v1/spans exportZipkinV1Spans
+// openapi:summary Exports spans in the Zipkin v1 format, as JSON or Thrift.
+// openapi:tag zipkin
+// openapi:request application/json array
+// openapi:request application/x-thrift binary
+// openapi:response 202
+// openapi:response 400 text/plain text
+//
+// openapi:operation POST /api/v2/spans exportZipkinV2Spans
+// openapi:summary Exports spans in the Zipkin v2 format, as JSON or protobuf.
+// openapi:tag zipkin
+// openapi:request application/json zipkin.SpanModels
+// openapi:request application/x-protobuf binary
+// openapi:response 202
+// openapi:response 400 text/plain text
 func (zr *ZipkinReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Trace this method
-	ctx, span := trace.StartSpan(context.Background(), "ZipkinReceiver.Export")
+	// Trace this method. The span is started from the request context, for
+	// its cancellation to reach the exporters, but not as a child of its span
+	// as the caller is linked instead.
+	ctx, span := trace.StartSpan(trace.NewContext(r.Context(), nil), "ZipkinReceiver.Export")
 	defer span.End()
 
-	// If the starting RPC has a parent span, then add it as a parent link.
+	// The trace context propagated by the client, if any, is added as a
+	// parent link, otherwise the one of the starting RPC.
 	// TODO: parentCtx should be direct parent for the span created here.
-	parentCtx := r.Context()
-	observability.SetParentLink(parentCtx, span)
+	callerSC, hasCaller, err := callerSpanContext(r)
+	if err != nil {
+		span.SetStatus(trace.Status{
+			Code:    trace.StatusCodeInvalidArgument,
+			Message: err.Error(),
+		})
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if hasCaller {
+		span.AddLink(callerLink(callerSC))
+	} else {
+		parentCtx := r.Context()
+		observability.SetParentLink(parentCtx, span)
+	}
 
 	pr := processBodyIfNecessary(r)
 	slurp, err := ioutil.ReadAll(pr)
@@ -28,6 +28,8 @@ import (
 	"time"
 
 	"contrib.go.opencensus.io/exporter/zipkin"
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/jaegertracing/jaeger/thrift-gen/zipkincore"
 	openzipkin "github.com/openzipkin/zipkin-go"
 	zipkinmodel "github.com/openzipkin/zipkin-go/model"
 	zhttp "github.com/openzipkin/zipkin-go/reporter/http"
@@ -214,6 +216,85 @@ func TestConvertSpansToTraceSpans_json(t *testing.T) {
 	}
 }
 
+func zipkinV1ThriftPayload(t *testing.T, spans []*zipkincore.Span) []byte {
+	buffer := thrift.NewTMemoryBuffer()
+	transport := thrift.NewTBinaryProtocolTransport(buffer)
+	if err := transport.WriteListBegin(thrift.STRUCT, len(spans)); err != nil {
+		t.Fatalf("Failed to write the thrift list header: %v", err)
+	}
+	for _, span := range spans {
+		if err := span.Write(transport); err != nil {
+			t.Fatalf("Failed to write the thrift span: %v", err)
+		}
+	}
+	if err := transport.WriteListEnd(); err != nil {
+		t.Fatalf("Failed to write the thrift list end: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestZipkinV1Thrift_endToEnd(t *testing.T) {
+	traceIDHigh := int64(0x0102030405060708)
+	parentID := int64(0x2122232425262728)
+	host := &zipkincore.Endpoint{ServiceName: "legacy-svc", Ipv4: 0x7f000001, Port: 8080}
+	spans := []*zipkincore.Span{
+		{
+			TraceID:     0x1112131415161718,
+			TraceIDHigh: &traceIDHigh,
+			ID:          0x3132333435363738,
+			ParentID:    &parentID,
+			Name:        "get /users",
+			Annotations: []*zipkincore.Annotation{
+				{Timestamp: 1544712660000000, Value: "sr", Host: host},
+				{Timestamp: 1544712660500000, Value: "cache miss", Host: host},
+				{Timestamp: 1544712661000000, Value: "ss", Host: host},
+			},
+		},
+	}
+
+	sink := new(exportertest.SinkTraceExporter)
+	zr, err := New(":0", sink)
+	if err != nil {
+		t.Fatalf("Failed to create the receiver: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/spans", bytes.NewReader(zipkinV1ThriftPayload(t, spans)))
+	req.Header.Set("Content-Type", "application/x-thrift")
+	rec := httptest.NewRecorder()
+	zr.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Got status %d, want %d: %s", rec.Code, http.StatusAccepted, rec.Body.String())
+	}
+
+	got := sink.AllTraces()
+	if len(got) != 1 || len(got[0].Spans) != 1 {
+		t.Fatalf("Got traces %v, want a single span", got)
+	}
+	if g, w := got[0].Node.GetServiceInfo().GetName(), "legacy-svc"; g != w {
+		t.Errorf("Service name = %q, want %q", g, w)
+	}
+	span := got[0].Spans[0]
+	wantTraceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18}
+	if !bytes.Equal(span.TraceId, wantTraceID) {
+		t.Errorf("TraceId = %x, want the 128 bit %x", span.TraceId, wantTraceID)
+	}
+	if g, w := span.ParentSpanId, []byte{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28}; !bytes.Equal(g, w) {
+		t.Errorf("ParentSpanId = %x, want %x", g, w)
+	}
+	if span.Kind != tracepb.Span_SERVER {
+		t.Errorf("Kind = %v, want SERVER from the sr/ss annotations", span.Kind)
+	}
+	if g, w := len(span.GetTimeEvents().GetTimeEvent()), 3; g != w {
+		t.Errorf("Got %d time events, want %d", g, w)
+	}
+	if g, w := span.StartTime, internal.TimeToTimestamp(time.Unix(1544712660, 0)); !reflect.DeepEqual(g, w) {
+		t.Errorf("StartTime = %v, want %v", g, w)
+	}
+	if g, w := span.EndTime, internal.TimeToTimestamp(time.Unix(1544712661, 0)); !reflect.DeepEqual(g, w) {
+		t.Errorf("EndTime = %v, want %v", g, w)
+	}
+}
+
 func TestConversionRoundtrip(t *testing.T) {
 	// The goal is to convert from:
 	// 1. Original Zipkin JSON as that's the format that Zipkin receivers will receive
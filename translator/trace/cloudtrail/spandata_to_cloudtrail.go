@@ -0,0 +1,194 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudtrail defines a translator from OpenCensus Go spans to AWS
+// CloudTrail event records, for the consumers of CloudTrail logs, such as
+// CloudTrail Insights, to process the AWS API calls traced by OpenCensus.
+package cloudtrail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// The span attributes describing the AWS API calls.
+const (
+	ServiceAttribute   = "aws.service"
+	OperationAttribute = "aws.operation"
+	RegionAttribute    = "aws.region"
+	RequestIDAttribute = "aws.request_id"
+	ErrorCodeAttribute = "aws.error_code"
+	UserAgentAttribute = "http.user_agent"
+	SourceIPAttribute  = "peer.ipv4"
+	// RequestParameterPrefix and ResponseElementPrefix prefix the names of
+	// the attributes holding respectively the request parameters and the
+	// response elements, e.g. "aws.request.TableName".
+	RequestParameterPrefix = "aws.request."
+	ResponseElementPrefix  = "aws.response."
+)
+
+const (
+	eventVersion = "1.08"
+	eventType    = "AwsApiCall"
+	// eventTimeFormat is the ISO 8601 format, in UTC and to the second, of
+	// the CloudTrail event times.
+	eventTimeFormat = "2006-01-02T15:04:05Z"
+)
+
+var (
+	errNilSpan     = errors.New("expected a non-nil span")
+	errNoOperation = errors.New("span has neither an " + OperationAttribute + " attribute nor a name")
+)
+
+// Event is a CloudTrail event record, of an AWS API call.
+type Event struct {
+	EventVersion        string                 `json:"eventVersion"`
+	EventTime           string                 `json:"eventTime"`
+	EventSource         string                 `json:"eventSource"`
+	EventName           string                 `json:"eventName"`
+	AWSRegion           string                 `json:"awsRegion,omitempty"`
+	SourceIPAddress     string                 `json:"sourceIPAddress,omitempty"`
+	UserAgent           string                 `json:"userAgent,omitempty"`
+	ErrorCode           string                 `json:"errorCode,omitempty"`
+	ErrorMessage        string                 `json:"errorMessage,omitempty"`
+	RequestParameters   map[string]interface{} `json:"requestParameters"`
+	ResponseElements    map[string]interface{} `json:"responseElements"`
+	AdditionalEventData map[string]interface{} `json:"additionalEventData,omitempty"`
+	RequestID           string                 `json:"requestID,omitempty"`
+	EventID             string                 `json:"eventID"`
+	EventType           string                 `json:"eventType"`
+}
+
+// SpanDataToEvent translates a span of an AWS API call into a CloudTrail
+// event.
+//
+// The event source is the aws.service attribute, e.g. "dynamodb", suffixed
+// with ".amazonaws.com" unless it is already a domain name. The event name is
+// the aws.operation attribute, or the span name without it. The request
+// parameters and response elements are the attributes with the
+// RequestParameterPrefix and ResponseElementPrefix prefixes, which are
+// stripped from their names. A non-OK status sets the error code, to the
+// aws.error_code attribute or the canonical name of the status code, and the
+// error message. The IDs of the span are kept in the additional event data,
+// and the event ID is derived from them.
+func SpanDataToEvent(sd *trace.SpanData) (*Event, error) {
+	if sd == nil {
+		return nil, errNilSpan
+	}
+
+	eventSource, _ := sd.Attributes[ServiceAttribute].(string)
+	if eventSource == "" {
+		return nil, fmt.Errorf("span %q has no %s attribute", sd.Name, ServiceAttribute)
+	}
+	if !strings.Contains(eventSource, ".") {
+		eventSource = strings.ToLower(eventSource) + ".amazonaws.com"
+	}
+	eventName, _ := sd.Attributes[OperationAttribute].(string)
+	if eventName == "" {
+		eventName = sd.Name
+	}
+	if eventName == "" {
+		return nil, errNoOperation
+	}
+
+	event := &Event{
+		EventVersion:      eventVersion,
+		EventTime:         sd.StartTime.UTC().Format(eventTimeFormat),
+		EventSource:       eventSource,
+		EventName:         eventName,
+		AWSRegion:         stringAttribute(sd.Attributes, RegionAttribute),
+		SourceIPAddress:   stringAttribute(sd.Attributes, SourceIPAttribute),
+		UserAgent:         stringAttribute(sd.Attributes, UserAgentAttribute),
+		RequestParameters: prefixedAttributes(sd.Attributes, RequestParameterPrefix),
+		ResponseElements:  prefixedAttributes(sd.Attributes, ResponseElementPrefix),
+		AdditionalEventData: map[string]interface{}{
+			"traceId":    sd.TraceID.String(),
+			"spanId":     sd.SpanID.String(),
+			"durationMs": float64(sd.EndTime.Sub(sd.StartTime)) / float64(time.Millisecond),
+		},
+		RequestID: stringAttribute(sd.Attributes, RequestIDAttribute),
+		EventID:   eventID(sd.TraceID, sd.SpanID),
+		EventType: eventType,
+	}
+	if sd.Code != trace.StatusCodeOK {
+		event.ErrorCode = stringAttribute(sd.Attributes, ErrorCodeAttribute)
+		if event.ErrorCode == "" {
+			event.ErrorCode = canonicalCodeString(sd.Code)
+		}
+		event.ErrorMessage = sd.Message
+	}
+	return event, nil
+}
+
+func stringAttribute(attrs map[string]interface{}, key string) string {
+	if value, ok := attrs[key]; ok {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// prefixedAttributes returns the attributes whose names have the prefix,
+// without it, or nil if there are none, as CloudTrail has null request
+// parameters and response elements for the calls without any.
+func prefixedAttributes(attrs map[string]interface{}, prefix string) map[string]interface{} {
+	var values map[string]interface{}
+	for key, value := range attrs {
+		if !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+			continue
+		}
+		if values == nil {
+			values = make(map[string]interface{})
+		}
+		values[key[len(prefix):]] = value
+	}
+	return values
+}
+
+// eventID formats the high 64 bits of the trace ID and the span ID, which
+// identify the span, as a GUID like the CloudTrail event IDs.
+func eventID(traceID trace.TraceID, spanID trace.SpanID) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", traceID[0:4], traceID[4:6], traceID[6:8], spanID[0:2], spanID[2:8])
+}
+
+// Replica of the exporter/zipkinexporter canonicalCodes.
+var canonicalCodes = [...]string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+func canonicalCodeString(code int32) string {
+	if code < 0 || int(code) >= len(canonicalCodes) {
+		return "error code " + fmt.Sprint(code)
+	}
+	return canonicalCodes[code]
+}
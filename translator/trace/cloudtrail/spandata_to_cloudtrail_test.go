@@ -0,0 +1,234 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudtrail
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func TestSpanDataToEvent(t *testing.T) {
+	startTime := time.Date(2019, 7, 1, 21, 22, 54, 500e6, time.UTC)
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanID:  trace.SpanID{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+		},
+		Name:      "DynamoDB.PutItem",
+		StartTime: startTime,
+		EndTime:   startTime.Add(25 * time.Millisecond),
+		Attributes: map[string]interface{}{
+			"aws.service":              "DynamoDB",
+			"aws.operation":            "PutItem",
+			"aws.region":               "us-west-2",
+			"aws.request_id":           "4KBNVRGD25RG1KEO9UT4V3FQDJVV4KQNSO5AEMVJF66Q9ASUAAJG",
+			"http.user_agent":          "aws-sdk-go/1.19.11",
+			"peer.ipv4":                "192.0.2.10",
+			"aws.request.TableName":    "users",
+			"aws.request.ReturnValues": "NONE",
+			"aws.response.Retries":     int64(1),
+			"cache_hit":                false,
+		},
+		Status: trace.Status{Code: trace.StatusCodeResourceExhausted, Message: "throughput exceeded"},
+	}
+
+	got, err := SpanDataToEvent(sd)
+	if err != nil {
+		t.Fatalf("Failed to convert the span: %v", err)
+	}
+
+	want := &Event{
+		EventVersion:    "1.08",
+		EventTime:       "2019-07-01T21:22:54Z",
+		EventSource:     "dynamodb.amazonaws.com",
+		EventName:       "PutItem",
+		AWSRegion:       "us-west-2",
+		SourceIPAddress: "192.0.2.10",
+		UserAgent:       "aws-sdk-go/1.19.11",
+		ErrorCode:       "RESOURCE_EXHAUSTED",
+		ErrorMessage:    "throughput exceeded",
+		RequestParameters: map[string]interface{}{
+			"TableName":    "users",
+			"ReturnValues": "NONE",
+		},
+		ResponseElements: map[string]interface{}{"Retries": int64(1)},
+		AdditionalEventData: map[string]interface{}{
+			"traceId":    "000102030405060708090a0b0c0d0e0f",
+			"spanId":     "f1f2f3f4f5f6f7f8",
+			"durationMs": 25.0,
+		},
+		RequestID: "4KBNVRGD25RG1KEO9UT4V3FQDJVV4KQNSO5AEMVJF66Q9ASUAAJG",
+		EventID:   "00010203-0405-0607-f1f2-f3f4f5f6f7f8",
+		EventType: "AwsApiCall",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got event\n\t%+v\nwant\n\t%+v", got, want)
+	}
+
+	records := consumeCloudTrailLog(t, got)
+	if len(records) != 1 {
+		t.Fatalf("Got %d records, want 1", len(records))
+	}
+	if r := records[0]; r.EventSource != "dynamodb.amazonaws.com" || r.EventName != "PutItem" || !r.EventTime.Equal(startTime.Truncate(time.Second)) {
+		t.Errorf("Got record %+v, want the PutItem call of %v", r, startTime)
+	}
+	if got := string(records[0].RequestParameters); got != `{"ReturnValues":"NONE","TableName":"users"}` {
+		t.Errorf("Got request parameters %s", got)
+	}
+}
+
+func TestSpanDataToEvent_withoutParametersOrError(t *testing.T) {
+	sd := &trace.SpanData{
+		Name:       "ListBuckets",
+		StartTime:  time.Date(2019, 7, 1, 21, 22, 54, 0, time.UTC),
+		Attributes: map[string]interface{}{"aws.service": "s3.amazonaws.com"},
+	}
+
+	got, err := SpanDataToEvent(sd)
+	if err != nil {
+		t.Fatalf("Failed to convert the span: %v", err)
+	}
+	if got.EventSource != "s3.amazonaws.com" || got.EventName != "ListBuckets" {
+		t.Errorf("Got event source %q and name %q, want s3.amazonaws.com and ListBuckets", got.EventSource, got.EventName)
+	}
+	if got.ErrorCode != "" || got.ErrorMessage != "" {
+		t.Errorf("Got error %q: %q, want none", got.ErrorCode, got.ErrorMessage)
+	}
+
+	blob, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Failed to marshal the event: %v", err)
+	}
+	for _, null := range []string{`"requestParameters":null`, `"responseElements":null`} {
+		if !strings.Contains(string(blob), null) {
+			t.Errorf("Event %s does not contain %s", blob, null)
+		}
+	}
+	consumeCloudTrailLog(t, got)
+}
+
+func TestSpanDataToEvent_errorCodeAttribute(t *testing.T) {
+	sd := &trace.SpanData{
+		Name: "GetObject",
+		Attributes: map[string]interface{}{
+			"aws.service":    "s3",
+			"aws.error_code": "AccessDenied",
+		},
+		Status: trace.Status{Code: trace.StatusCodePermissionDenied, Message: "Access Denied"},
+	}
+
+	got, err := SpanDataToEvent(sd)
+	if err != nil {
+		t.Fatalf("Failed to convert the span: %v", err)
+	}
+	if got.ErrorCode != "AccessDenied" || got.ErrorMessage != "Access Denied" {
+		t.Errorf("Got error %q: %q, want AccessDenied: Access Denied", got.ErrorCode, got.ErrorMessage)
+	}
+}
+
+func TestSpanDataToEvent_invalidSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		sd   *trace.SpanData
+	}{
+		{name: "nil span", sd: nil},
+		{name: "no service", sd: &trace.SpanData{Name: "PutItem"}},
+		{name: "no operation", sd: &trace.SpanData{Attributes: map[string]interface{}{"aws.service": "dynamodb"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := SpanDataToEvent(tt.sd); err == nil {
+				t.Errorf("Got event %+v, want an error", got)
+			}
+		})
+	}
+}
+
+// cloudTrailRecord holds the fields of the CloudTrail records that the
+// consumers of CloudTrail logs rely on.
+type cloudTrailRecord struct {
+	EventVersion      string          `json:"eventVersion"`
+	EventTime         time.Time       `json:"eventTime"`
+	EventSource       string          `json:"eventSource"`
+	EventName         string          `json:"eventName"`
+	EventID           string          `json:"eventID"`
+	EventType         string          `json:"eventType"`
+	RequestParameters json.RawMessage `json:"requestParameters"`
+	ResponseElements  json.RawMessage `json:"responseElements"`
+}
+
+var (
+	eventSourcePattern = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9-]+)*\.amazonaws\.com$`)
+	eventIDPattern     = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+)
+
+// consumeCloudTrailLog mocks a consumer of CloudTrail logs: it reads the
+// events from a log file, whose records are in a "Records" array, and
+// rejects the records CloudTrail would not produce.
+func consumeCloudTrailLog(t *testing.T, events ...*Event) []*cloudTrailRecord {
+	t.Helper()
+	blob, err := json.Marshal(map[string]interface{}{"Records": events})
+	if err != nil {
+		t.Fatalf("Failed to marshal the CloudTrail log: %v", err)
+	}
+
+	var log struct {
+		Records []*cloudTrailRecord `json:"Records"`
+	}
+	if err := json.Unmarshal(blob, &log); err != nil {
+		t.Fatalf("Failed to read the CloudTrail log %s: %v", blob, err)
+	}
+	for i, r := range log.Records {
+		for _, err := range cloudTrailRecordErrors(r) {
+			t.Errorf("Record #%d of the CloudTrail log %s: %v", i, blob, err)
+		}
+	}
+	return log.Records
+}
+
+func cloudTrailRecordErrors(r *cloudTrailRecord) (errs []error) {
+	if r.EventVersion != "1.08" {
+		errs = append(errs, fmt.Errorf("unsupported eventVersion %q", r.EventVersion))
+	}
+	if r.EventTime.IsZero() || r.EventTime.Location() != time.UTC {
+		errs = append(errs, fmt.Errorf("eventTime %v is not a UTC time", r.EventTime))
+	}
+	if !eventSourcePattern.MatchString(r.EventSource) {
+		errs = append(errs, fmt.Errorf("eventSource %q is not an AWS service endpoint", r.EventSource))
+	}
+	if r.EventName == "" {
+		errs = append(errs, fmt.Errorf("missing eventName"))
+	}
+	if !eventIDPattern.MatchString(r.EventID) {
+		errs = append(errs, fmt.Errorf("eventID %q is not a GUID", r.EventID))
+	}
+	if r.EventType != "AwsApiCall" {
+		errs = append(errs, fmt.Errorf("unexpected eventType %q", r.EventType))
+	}
+	for name, raw := range map[string]json.RawMessage{"requestParameters": r.RequestParameters, "responseElements": r.ResponseElements} {
+		var v map[string]interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s is neither an object nor null", name, raw))
+		}
+	}
+	return errs
+}
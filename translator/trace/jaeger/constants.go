@@ -28,6 +28,9 @@ const (
 	ocTimeEventMessageEventCSize     = "oc.timeevent.messageevent.csize"
 	ocSameProcessAsParentSpan        = "oc.sameprocessasparentspan"
 	ocSpanChildCount                 = "oc.span.childcount"
+	ocStatusCode                     = "status.code"
+	ocStatusMessage                  = "status.message"
+	jaegerError                      = "error"
 	opencensusLanguage               = "opencensus.language"
 	opencensusExporterVersion        = "opencensus.exporterversion"
 	opencensusCoreLibVersion         = "opencensus.corelibversion"
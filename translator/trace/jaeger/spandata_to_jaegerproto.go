@@ -0,0 +1,209 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jaeger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	jaeger "github.com/jaegertracing/jaeger/model"
+	"go.opencensus.io/trace"
+)
+
+// SpanDataToJaegerProto translates an OpenCensus Go span into a Jaeger Proto
+// span of the given process.
+//
+// The annotations and message events become logs, ordered by time, the
+// parent span and the links become references, and the attributes become
+// tags of the matching Jaeger type, the values of the other types being
+// formatted as strings. A non-zero status code sets the "error" tag.
+func SpanDataToJaegerProto(sd *trace.SpanData, process *jaeger.Process) *jaeger.Span {
+	if sd == nil {
+		return nil
+	}
+
+	traceID := ocTraceIDToJaeger(sd.TraceID)
+	var refs []jaeger.SpanRef
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		refs = append(refs, jaeger.SpanRef{
+			TraceID: traceID,
+			SpanID:  ocSpanIDToJaeger(sd.ParentSpanID),
+			RefType: jaeger.SpanRefType_CHILD_OF,
+		})
+	}
+	refs = append(refs, ocLinksToJaegerReferences(sd.Links)...)
+
+	var flags jaeger.Flags
+	if sd.IsSampled() {
+		flags = jaeger.SampledFlag
+	}
+
+	jSpan := &jaeger.Span{
+		TraceID:       traceID,
+		SpanID:        ocSpanIDToJaeger(sd.SpanID),
+		OperationName: sd.Name,
+		References:    refs,
+		Flags:         flags,
+		StartTime:     sd.StartTime.UTC(),
+		Duration:      sd.EndTime.Sub(sd.StartTime),
+		Tags:          ocAttributesToJaegerTags(sd.Attributes),
+		Logs:          ocEventsToJaegerLogs(sd.Annotations, sd.MessageEvents),
+		Process:       process,
+	}
+
+	if sd.Tracestate != nil {
+		for _, entry := range sd.Tracestate.Entries() {
+			jSpan.Tags = append(jSpan.Tags, jaeger.String(entry.Key, entry.Value))
+		}
+	}
+	switch sd.SpanKind {
+	case trace.SpanKindClient:
+		jSpan.Tags = append(jSpan.Tags, jaeger.String("span.kind", "client"))
+	case trace.SpanKindServer:
+		jSpan.Tags = append(jSpan.Tags, jaeger.String("span.kind", "server"))
+	}
+	if sd.Code != 0 || sd.Message != "" {
+		jSpan.Tags = append(jSpan.Tags,
+			jaeger.Int64(ocStatusCode, int64(sd.Code)),
+			jaeger.String(ocStatusMessage, sd.Message))
+	}
+	if sd.Code != 0 {
+		jSpan.Tags = append(jSpan.Tags, jaeger.Bool(jaegerError, true))
+	}
+	if sd.HasRemoteParent {
+		jSpan.Tags = append(jSpan.Tags, jaeger.Bool(ocSameProcessAsParentSpan, false))
+	}
+	if sd.ChildSpanCount > 0 {
+		jSpan.Tags = append(jSpan.Tags, jaeger.Int64(ocSpanChildCount, int64(sd.ChildSpanCount)))
+	}
+
+	return jSpan
+}
+
+func ocTraceIDToJaeger(traceID trace.TraceID) jaeger.TraceID {
+	return jaeger.NewTraceID(binary.BigEndian.Uint64(traceID[:8]), binary.BigEndian.Uint64(traceID[8:]))
+}
+
+func ocSpanIDToJaeger(spanID trace.SpanID) jaeger.SpanID {
+	return jaeger.NewSpanID(binary.BigEndian.Uint64(spanID[:]))
+}
+
+func ocLinksToJaegerReferences(links []trace.Link) []jaeger.SpanRef {
+	if len(links) == 0 {
+		return nil
+	}
+
+	jRefs := make([]jaeger.SpanRef, 0, len(links))
+	for _, link := range links {
+		// Jaeger has no unspecified reference type, only CHILD_OF and
+		// FOLLOWS_FROM, the latter being used for all the non-parent links.
+		jRefType := jaeger.SpanRefType_FOLLOWS_FROM
+		if link.Type == trace.LinkTypeParent {
+			jRefType = jaeger.SpanRefType_CHILD_OF
+		}
+		jRefs = append(jRefs, jaeger.SpanRef{
+			TraceID: ocTraceIDToJaeger(link.TraceID),
+			SpanID:  ocSpanIDToJaeger(link.SpanID),
+			RefType: jRefType,
+		})
+	}
+	return jRefs
+}
+
+// ocAttributesToJaegerTags converts the attributes, sorted by key, to tags.
+func ocAttributesToJaegerTags(attrs map[string]interface{}) []jaeger.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	jTags := make([]jaeger.KeyValue, 0, len(keys))
+	for _, key := range keys {
+		var jTag jaeger.KeyValue
+		switch value := attrs[key].(type) {
+		case string:
+			jTag = jaeger.String(key, value)
+		case bool:
+			jTag = jaeger.Bool(key, value)
+		case int64:
+			jTag = jaeger.Int64(key, value)
+		case int:
+			jTag = jaeger.Int64(key, int64(value))
+		case int32:
+			jTag = jaeger.Int64(key, int64(value))
+		case float64:
+			jTag = jaeger.Float64(key, value)
+		case float32:
+			jTag = jaeger.Float64(key, float64(value))
+		case []byte:
+			jTag = jaeger.Binary(key, value)
+		default:
+			jTag = jaeger.String(key, fmt.Sprint(value))
+		}
+		jTags = append(jTags, jTag)
+	}
+	return jTags
+}
+
+func ocEventsToJaegerLogs(annotations []trace.Annotation, messageEvents []trace.MessageEvent) []jaeger.Log {
+	if len(annotations) == 0 && len(messageEvents) == 0 {
+		return nil
+	}
+
+	jLogs := make([]jaeger.Log, 0, len(annotations)+len(messageEvents))
+	for _, annotation := range annotations {
+		fields := ocAttributesToJaegerTags(annotation.Attributes)
+		if annotation.Message != "" {
+			fields = append(fields, jaeger.String(ocTimeEventAnnotationDescription, annotation.Message))
+		}
+		jLogs = append(jLogs, jaeger.Log{
+			Timestamp: annotation.Time.UTC(),
+			Fields:    fields,
+		})
+	}
+	for _, messageEvent := range messageEvents {
+		jLogs = append(jLogs, jaeger.Log{
+			Timestamp: messageEvent.Time.UTC(),
+			Fields: []jaeger.KeyValue{
+				jaeger.String(ocTimeEventMessageEventType, ocMessageEventTypeToString(messageEvent.EventType)),
+				jaeger.Int64(ocTimeEventMessageEventID, messageEvent.MessageID),
+				jaeger.Int64(ocTimeEventMessageEventUSize, messageEvent.UncompressedByteSize),
+				jaeger.Int64(ocTimeEventMessageEventCSize, messageEvent.CompressedByteSize),
+			},
+		})
+	}
+	sort.SliceStable(jLogs, func(i, j int) bool {
+		return jLogs[i].Timestamp.Before(jLogs[j].Timestamp)
+	})
+	return jLogs
+}
+
+// ocMessageEventTypeToString returns the name of the message event type, as
+// in the OpenCensus proto.
+func ocMessageEventTypeToString(eventType trace.MessageEventType) string {
+	switch eventType {
+	case trace.MessageEventTypeSent:
+		return "SENT"
+	case trace.MessageEventTypeRecv:
+		return "RECEIVED"
+	}
+	return "TYPE_UNSPECIFIED"
+}
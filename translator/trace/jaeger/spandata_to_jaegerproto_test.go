@@ -0,0 +1,221 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jaeger
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	jaeger "github.com/jaegertracing/jaeger/model"
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+func TestSpanDataToJaegerProto_roundTrip(t *testing.T) {
+	endTime := time.Unix(1485467191, 662813000)
+	startTime := endTime.Add(-22938 * time.Microsecond)
+	annotationTime := startTime.Add(10 * time.Millisecond)
+
+	ocTracestate, err := tracestate.New(nil, tracestate.Entry{Key: "foo", Value: "bar"})
+	if err != nil {
+		t.Fatalf("Failed to create ocTracestate: %v", err)
+	}
+
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:      trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x52, 0x96, 0x9A, 0x89, 0x55, 0x57, 0x1A, 0x3F},
+			SpanID:       trace.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x7D, 0x98},
+			TraceOptions: 1,
+			Tracestate:   ocTracestate,
+		},
+		ParentSpanID: trace.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x68, 0xC4, 0xE3},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "get",
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Attributes: map[string]interface{}{
+			"http.url":   "http://127.0.0.1:15598/client_transactions",
+			"peer.port":  int64(53931),
+			"retries":    int(3),
+			"someBool":   true,
+			"someDouble": 129.8,
+			"payload":    []byte{0x01, 0x02},
+			"duration":   time.Second,
+		},
+		Annotations: []trace.Annotation{
+			{Time: annotationTime, Message: "cache miss", Attributes: map[string]interface{}{"key": "users"}},
+		},
+		MessageEvents: []trace.MessageEvent{
+			{Time: startTime, EventType: trace.MessageEventTypeRecv, MessageID: 7, UncompressedByteSize: 1024, CompressedByteSize: 512},
+		},
+		Links: []trace.Link{
+			{
+				TraceID: trace.TraceID{0xC0, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0xCA, 0xCB, 0xCC, 0xCD, 0xCE, 0xCF},
+				SpanID:  trace.SpanID{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7},
+				Type:    trace.LinkTypeChild,
+			},
+		},
+		Status:          trace.Status{Code: trace.StatusCodeNotFound, Message: "no such user"},
+		HasRemoteParent: true,
+		ChildSpanCount:  2,
+	}
+	process := &jaeger.Process{
+		ServiceName: "api",
+		Tags:        []jaeger.KeyValue{jaeger.String("ip", "10.53.69.61")},
+	}
+
+	got := roundTripJaegerSpan(t, SpanDataToJaegerProto(sd, process))
+
+	if got.TraceID.High != 0x0001020304050607 || got.TraceID.Low != 0x52969A8955571A3F {
+		t.Errorf("Got trace ID %v, want %x", got.TraceID, sd.TraceID)
+	}
+	if got.SpanID != jaeger.SpanID(binary.BigEndian.Uint64(sd.SpanID[:])) {
+		t.Errorf("Got span ID %v, want %x", got.SpanID, sd.SpanID)
+	}
+	if got.OperationName != sd.Name {
+		t.Errorf("Got operation name %q, want %q", got.OperationName, sd.Name)
+	}
+	if !got.StartTime.Equal(sd.StartTime) {
+		t.Errorf("Got start time %v, want %v", got.StartTime, sd.StartTime)
+	}
+	if want := sd.EndTime.Sub(sd.StartTime); got.Duration != want {
+		t.Errorf("Got duration %v, want %v", got.Duration, want)
+	}
+	if got.Flags != jaeger.SampledFlag {
+		t.Errorf("Got flags %v, want sampled", got.Flags)
+	}
+	if got.Process == nil || got.Process.ServiceName != "api" || len(got.Process.Tags) != 1 {
+		t.Errorf("Got process %+v, want %+v", got.Process, process)
+	}
+
+	wantRefs := []jaeger.SpanRef{
+		{
+			TraceID: got.TraceID,
+			SpanID:  jaeger.SpanID(0x68C4E3),
+			RefType: jaeger.SpanRefType_CHILD_OF,
+		},
+		{
+			TraceID: jaeger.NewTraceID(0xC0C1C2C3C4C5C6C7, 0xC8C9CACBCCCDCECF),
+			SpanID:  jaeger.SpanID(0xB0B1B2B3B4B5B6B7),
+			RefType: jaeger.SpanRefType_FOLLOWS_FROM,
+		},
+	}
+	if !reflect.DeepEqual(got.References, wantRefs) {
+		t.Errorf("Got references %+v, want %+v", got.References, wantRefs)
+	}
+
+	checkJaegerTags(t, "span", got.Tags, []jaeger.KeyValue{
+		jaeger.String("duration", "1s"),
+		jaeger.String("http.url", "http://127.0.0.1:15598/client_transactions"),
+		jaeger.Binary("payload", []byte{0x01, 0x02}),
+		jaeger.Int64("peer.port", 53931),
+		jaeger.Int64("retries", 3),
+		jaeger.Bool("someBool", true),
+		jaeger.Float64("someDouble", 129.8),
+		jaeger.String("foo", "bar"),
+		jaeger.String("span.kind", "server"),
+		jaeger.Int64("status.code", trace.StatusCodeNotFound),
+		jaeger.String("status.message", "no such user"),
+		jaeger.Bool("error", true),
+		jaeger.Bool("oc.sameprocessasparentspan", false),
+		jaeger.Int64("oc.span.childcount", 2),
+	})
+
+	if len(got.Logs) != 2 {
+		t.Fatalf("Got %d logs, want 2", len(got.Logs))
+	}
+	if !got.Logs[0].Timestamp.Equal(startTime) {
+		t.Errorf("Got first log at %v, want the message event at %v", got.Logs[0].Timestamp, startTime)
+	}
+	checkJaegerTags(t, "message event", got.Logs[0].Fields, []jaeger.KeyValue{
+		jaeger.String("oc.timeevent.messageevent.type", "RECEIVED"),
+		jaeger.Int64("oc.timeevent.messageevent.id", 7),
+		jaeger.Int64("oc.timeevent.messageevent.usize", 1024),
+		jaeger.Int64("oc.timeevent.messageevent.csize", 512),
+	})
+	if !got.Logs[1].Timestamp.Equal(annotationTime) {
+		t.Errorf("Got second log at %v, want the annotation at %v", got.Logs[1].Timestamp, annotationTime)
+	}
+	checkJaegerTags(t, "annotation", got.Logs[1].Fields, []jaeger.KeyValue{
+		jaeger.String("key", "users"),
+		jaeger.String("oc.timeevent.annotation.description", "cache miss"),
+	})
+}
+
+func TestSpanDataToJaegerProto_rootSpan(t *testing.T) {
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanID:  trace.SpanID{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+		},
+		Name:      "root",
+		StartTime: time.Unix(1485467191, 0),
+		EndTime:   time.Unix(1485467192, 0),
+		Status:    trace.Status{Code: trace.StatusCodeOK},
+	}
+
+	got := roundTripJaegerSpan(t, SpanDataToJaegerProto(sd, unknownProcessProto))
+
+	if len(got.References) != 0 {
+		t.Errorf("Got references %+v, want none for a root span", got.References)
+	}
+	if got.Flags != 0 {
+		t.Errorf("Got flags %v, want none for an unsampled span", got.Flags)
+	}
+	if got.Duration != time.Second {
+		t.Errorf("Got duration %v, want %v", got.Duration, time.Second)
+	}
+	checkJaegerTags(t, "span", got.Tags, nil)
+	if len(got.Logs) != 0 {
+		t.Errorf("Got logs %+v, want none", got.Logs)
+	}
+}
+
+func TestSpanDataToJaegerProto_nil(t *testing.T) {
+	if got := SpanDataToJaegerProto(nil, unknownProcessProto); got != nil {
+		t.Errorf("Got %+v for a nil span, want nil", got)
+	}
+}
+
+// roundTripJaegerSpan encodes the span to protobuf and decodes it back.
+func roundTripJaegerSpan(t *testing.T, jSpan *jaeger.Span) *jaeger.Span {
+	t.Helper()
+	b, err := jSpan.Marshal()
+	if err != nil {
+		t.Fatalf("Failed to marshal the Jaeger span: %v", err)
+	}
+	got := new(jaeger.Span)
+	if err := got.Unmarshal(b); err != nil {
+		t.Fatalf("Failed to unmarshal the Jaeger span: %v", err)
+	}
+	return got
+}
+
+// checkJaegerTags checks the tags have the keys, types and values of the
+// wanted ones, in the same order.
+func checkJaegerTags(t *testing.T, what string, got, want []jaeger.KeyValue) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("Got %d %s tags %+v, want %d %+v", len(got), what, got, len(want), want)
+		return
+	}
+	for i := range want {
+		if got[i].Key != want[i].Key || got[i].VType != want[i].VType || !reflect.DeepEqual(got[i].Value(), want[i].Value()) {
+			t.Errorf("Got %s tag #%d %+v, want %+v", what, i, got[i], want[i])
+		}
+	}
+}
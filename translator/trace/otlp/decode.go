@@ -0,0 +1,332 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The OTLP protobuf messages are decoded from, and encoded to, the wire format
+// directly, with the types of model.go, as only a small subset of their fields
+// is needed. The unknown fields are skipped.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+// forEachField calls fn for every field of the protobuf message b, with its
+// value for the numeric wire types and its content for the length-delimited
+// one.
+func forEachField(b []byte, fn func(field int, value uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncated
+		}
+		b = b[n:]
+
+		var value uint64
+		var data []byte
+		switch wireType := key & 7; wireType {
+		case wireVarint:
+			value, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errTruncated
+			}
+			value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return errTruncated
+			}
+			data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errTruncated
+			}
+			value = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+		}
+
+		if err := fn(int(key>>3), value, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalExportRequest decodes an ExportTraceServiceRequest.
+func UnmarshalExportRequest(b []byte) (*ExportRequest, error) {
+	req := new(ExportRequest)
+	err := forEachField(b, func(field int, _ uint64, data []byte) error {
+		if field != 1 {
+			return nil
+		}
+		rs, err := decodeResourceSpans(data)
+		req.ResourceSpans = append(req.ResourceSpans, rs)
+		return err
+	})
+	return req, err
+}
+
+func decodeResourceSpans(b []byte) (*ResourceSpans, error) {
+	rs := new(ResourceSpans)
+	err := forEachField(b, func(field int, _ uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			rs.Resource, err = decodeResource(data)
+		case 2:
+			var ss *ScopeSpans
+			ss, err = decodeScopeSpans(data)
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		case 1000:
+			var ss *ScopeSpans
+			ss, err = decodeScopeSpans(data)
+			rs.InstrumentationLibrarySpans = append(rs.InstrumentationLibrarySpans, ss)
+		}
+		return err
+	})
+	return rs, err
+}
+
+func decodeResource(b []byte) (*Resource, error) {
+	r := new(Resource)
+	err := forEachField(b, func(field int, _ uint64, data []byte) error {
+		if field != 1 {
+			return nil
+		}
+		kv, err := decodeKeyValue(data)
+		r.Attributes = append(r.Attributes, kv)
+		return err
+	})
+	return r, err
+}
+
+// decodeScopeSpans decodes a ScopeSpans, or an InstrumentationLibrarySpans
+// which has the same fields.
+func decodeScopeSpans(b []byte) (*ScopeSpans, error) {
+	ss := new(ScopeSpans)
+	err := forEachField(b, func(field int, _ uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			ss.Scope, err = decodeScope(data)
+		case 2:
+			var s *Span
+			s, err = UnmarshalSpan(data)
+			ss.Spans = append(ss.Spans, s)
+		}
+		return err
+	})
+	return ss, err
+}
+
+func decodeScope(b []byte) (*Scope, error) {
+	s := new(Scope)
+	err := forEachField(b, func(field int, _ uint64, data []byte) error {
+		switch field {
+		case 1:
+			s.Name = string(data)
+		case 2:
+			s.Version = string(data)
+		}
+		return nil
+	})
+	return s, err
+}
+
+// UnmarshalSpan decodes a Span.
+func UnmarshalSpan(b []byte) (*Span, error) {
+	s := new(Span)
+	err := forEachField(b, func(field int, value uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			s.TraceID = data
+		case 2:
+			s.SpanID = data
+		case 3:
+			s.TraceState = string(data)
+		case 4:
+			s.ParentSpanID = data
+		case 5:
+			s.Name = string(data)
+		case 6:
+			s.Kind = SpanKind(value)
+		case 7:
+			s.StartTimeUnixNano = Uint64(value)
+		case 8:
+			s.EndTimeUnixNano = Uint64(value)
+		case 9:
+			var kv *KeyValue
+			kv, err = decodeKeyValue(data)
+			s.Attributes = append(s.Attributes, kv)
+		case 10:
+			s.DroppedAttributesCount = uint32(value)
+		case 11:
+			var e *Event
+			e, err = decodeEvent(data)
+			s.Events = append(s.Events, e)
+		case 12:
+			s.DroppedEventsCount = uint32(value)
+		case 13:
+			var l *Link
+			l, err = decodeLink(data)
+			s.Links = append(s.Links, l)
+		case 14:
+			s.DroppedLinksCount = uint32(value)
+		case 15:
+			s.Status, err = decodeStatus(data)
+		}
+		return err
+	})
+	return s, err
+}
+
+func decodeEvent(b []byte) (*Event, error) {
+	e := new(Event)
+	err := forEachField(b, func(field int, value uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			e.TimeUnixNano = Uint64(value)
+		case 2:
+			e.Name = string(data)
+		case 3:
+			var kv *KeyValue
+			kv, err = decodeKeyValue(data)
+			e.Attributes = append(e.Attributes, kv)
+		case 4:
+			e.DroppedAttributesCount = uint32(value)
+		}
+		return err
+	})
+	return e, err
+}
+
+func decodeLink(b []byte) (*Link, error) {
+	l := new(Link)
+	err := forEachField(b, func(field int, value uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			l.TraceID = data
+		case 2:
+			l.SpanID = data
+		case 3:
+			l.TraceState = string(data)
+		case 4:
+			var kv *KeyValue
+			kv, err = decodeKeyValue(data)
+			l.Attributes = append(l.Attributes, kv)
+		case 5:
+			l.DroppedAttributesCount = uint32(value)
+		}
+		return err
+	})
+	return l, err
+}
+
+func decodeStatus(b []byte) (*Status, error) {
+	s := new(Status)
+	err := forEachField(b, func(field int, value uint64, data []byte) error {
+		switch field {
+		case 2:
+			s.Message = string(data)
+		case 3:
+			s.Code = StatusCode(value)
+		}
+		return nil
+	})
+	return s, err
+}
+
+func decodeKeyValue(b []byte) (*KeyValue, error) {
+	kv := new(KeyValue)
+	err := forEachField(b, func(field int, _ uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			kv.Key = string(data)
+		case 2:
+			kv.Value, err = decodeAnyValue(data)
+		}
+		return err
+	})
+	return kv, err
+}
+
+func decodeAnyValue(b []byte) (*AnyValue, error) {
+	av := new(AnyValue)
+	err := forEachField(b, func(field int, value uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			s := string(data)
+			av.StringValue = &s
+		case 2:
+			v := value != 0
+			av.BoolValue = &v
+		case 3:
+			v := Int64(value)
+			av.IntValue = &v
+		case 4:
+			v := math.Float64frombits(value)
+			av.DoubleValue = &v
+		case 5:
+			av.ArrayValue = new(ArrayValue)
+			err = forEachField(data, func(field int, _ uint64, data []byte) error {
+				if field != 1 {
+					return nil
+				}
+				v, err := decodeAnyValue(data)
+				av.ArrayValue.Values = append(av.ArrayValue.Values, v)
+				return err
+			})
+		case 6:
+			av.KvlistValue = new(KeyValueList)
+			err = forEachField(data, func(field int, _ uint64, data []byte) error {
+				if field != 1 {
+					return nil
+				}
+				kv, err := decodeKeyValue(data)
+				av.KvlistValue.Values = append(av.KvlistValue.Values, kv)
+				return err
+			})
+		case 7:
+			av.BytesValue = data
+		}
+		return err
+	})
+	return av, err
+}
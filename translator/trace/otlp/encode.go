@@ -0,0 +1,227 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// MarshalExportRequest encodes an ExportTraceServiceRequest in the protobuf
+// wire format. The spans of InstrumentationLibrarySpans are encoded as the
+// ScopeSpans of OTLP 0.19 and later.
+func MarshalExportRequest(req *ExportRequest) []byte {
+	var b []byte
+	for _, rs := range req.ResourceSpans {
+		if rs != nil {
+			b = appendMessage(b, 1, marshalResourceSpans(rs))
+		}
+	}
+	return b
+}
+
+func marshalResourceSpans(rs *ResourceSpans) []byte {
+	var b []byte
+	if rs.Resource != nil {
+		b = appendMessage(b, 1, appendKeyValues(nil, 1, rs.Resource.Attributes))
+	}
+	for _, sss := range [][]*ScopeSpans{rs.ScopeSpans, rs.InstrumentationLibrarySpans} {
+		for _, ss := range sss {
+			if ss != nil {
+				b = appendMessage(b, 2, marshalScopeSpans(ss))
+			}
+		}
+	}
+	return b
+}
+
+func marshalScopeSpans(ss *ScopeSpans) []byte {
+	var b []byte
+	scope := ss.Scope
+	if scope == nil {
+		scope = ss.InstrumentationLibrary
+	}
+	if scope != nil {
+		var sb []byte
+		sb = appendString(sb, 1, scope.Name)
+		sb = appendString(sb, 2, scope.Version)
+		b = appendMessage(b, 1, sb)
+	}
+	for _, s := range ss.Spans {
+		if s != nil {
+			b = appendMessage(b, 2, MarshalSpan(s))
+		}
+	}
+	return b
+}
+
+// MarshalSpan encodes the span in the protobuf wire format. The fields with
+// their default value are omitted, as well as the nil attributes, events and
+// links.
+func MarshalSpan(s *Span) []byte {
+	var b []byte
+	b = appendBytes(b, 1, s.TraceID)
+	b = appendBytes(b, 2, s.SpanID)
+	b = appendString(b, 3, s.TraceState)
+	b = appendBytes(b, 4, s.ParentSpanID)
+	b = appendString(b, 5, s.Name)
+	b = appendVarint(b, 6, uint64(s.Kind))
+	b = appendFixed64(b, 7, uint64(s.StartTimeUnixNano))
+	b = appendFixed64(b, 8, uint64(s.EndTimeUnixNano))
+	b = appendKeyValues(b, 9, s.Attributes)
+	b = appendVarint(b, 10, uint64(s.DroppedAttributesCount))
+	for _, e := range s.Events {
+		if e != nil {
+			b = appendMessage(b, 11, marshalEvent(e))
+		}
+	}
+	b = appendVarint(b, 12, uint64(s.DroppedEventsCount))
+	for _, l := range s.Links {
+		if l != nil {
+			b = appendMessage(b, 13, marshalLink(l))
+		}
+	}
+	b = appendVarint(b, 14, uint64(s.DroppedLinksCount))
+	if s.Status != nil {
+		b = appendMessage(b, 15, marshalStatus(s.Status))
+	}
+	return b
+}
+
+func marshalEvent(e *Event) []byte {
+	var b []byte
+	b = appendFixed64(b, 1, uint64(e.TimeUnixNano))
+	b = appendString(b, 2, e.Name)
+	b = appendKeyValues(b, 3, e.Attributes)
+	b = appendVarint(b, 4, uint64(e.DroppedAttributesCount))
+	return b
+}
+
+func marshalLink(l *Link) []byte {
+	var b []byte
+	b = appendBytes(b, 1, l.TraceID)
+	b = appendBytes(b, 2, l.SpanID)
+	b = appendString(b, 3, l.TraceState)
+	b = appendKeyValues(b, 4, l.Attributes)
+	b = appendVarint(b, 5, uint64(l.DroppedAttributesCount))
+	return b
+}
+
+func marshalStatus(s *Status) []byte {
+	var b []byte
+	b = appendString(b, 2, s.Message)
+	b = appendVarint(b, 3, uint64(s.Code))
+	return b
+}
+
+func appendKeyValues(b []byte, field int, kvs []*KeyValue) []byte {
+	for _, kv := range kvs {
+		if kv != nil {
+			b = appendMessage(b, field, marshalKeyValue(kv))
+		}
+	}
+	return b
+}
+
+func marshalKeyValue(kv *KeyValue) []byte {
+	var b []byte
+	b = appendString(b, 1, kv.Key)
+	if kv.Value != nil {
+		b = appendMessage(b, 2, marshalAnyValue(kv.Value))
+	}
+	return b
+}
+
+// marshalAnyValue encodes the first field set, even to its default value, as
+// the fields of a oneof are.
+func marshalAnyValue(av *AnyValue) []byte {
+	var b []byte
+	switch {
+	case av.StringValue != nil:
+		b = appendMessage(b, 1, []byte(*av.StringValue))
+	case av.BoolValue != nil:
+		v := uint64(0)
+		if *av.BoolValue {
+			v = 1
+		}
+		b = appendUvarint(appendKey(b, 2, wireVarint), v)
+	case av.IntValue != nil:
+		b = appendUvarint(appendKey(b, 3, wireVarint), uint64(*av.IntValue))
+	case av.DoubleValue != nil:
+		b = appendUint64(appendKey(b, 4, wireFixed64), math.Float64bits(*av.DoubleValue))
+	case av.ArrayValue != nil:
+		var values []byte
+		for _, v := range av.ArrayValue.Values {
+			if v != nil {
+				values = appendMessage(values, 1, marshalAnyValue(v))
+			}
+		}
+		b = appendMessage(b, 5, values)
+	case av.KvlistValue != nil:
+		b = appendMessage(b, 6, appendKeyValues(nil, 1, av.KvlistValue.Values))
+	case av.BytesValue != nil:
+		b = appendMessage(b, 7, av.BytesValue)
+	}
+	return b
+}
+
+func appendKey(b []byte, field, wireType int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendUvarint(appendKey(b, field, wireVarint), v)
+}
+
+func appendFixed64(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendUint64(appendKey(b, field, wireFixed64), v)
+}
+
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendMessage(b, field, []byte(s))
+}
+
+func appendBytes(b []byte, field int, data []byte) []byte {
+	if len(data) == 0 {
+		return b
+	}
+	return appendMessage(b, field, data)
+}
+
+// appendMessage appends a length-delimited field, even if empty.
+func appendMessage(b []byte, field int, data []byte) []byte {
+	return append(appendUvarint(appendKey(b, field, wireBytes), uint64(len(data))), data...)
+}
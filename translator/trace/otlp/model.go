@@ -0,0 +1,248 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otlp defines the OpenTelemetry (OTLP) trace messages, their
+// protobuf wire format and OTLP/JSON decoding, and translators from
+// OpenCensus Go spans to them.
+//
+// The messages hold the subset of the OTLP fields that has an OpenCensus
+// equivalent. They are decoded from OTLP/JSON, with the json tags, or from
+// the protobuf encoding by UnmarshalExportRequest and UnmarshalSpan.
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ExportRequest is an ExportTraceServiceRequest.
+type ExportRequest struct {
+	ResourceSpans []*ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans holds the spans of a resource.
+type ResourceSpans struct {
+	Resource   *Resource     `json:"resource"`
+	ScopeSpans []*ScopeSpans `json:"scopeSpans"`
+	// InstrumentationLibrarySpans is the name of ScopeSpans in the versions
+	// of OTLP prior to 0.19.
+	InstrumentationLibrarySpans []*ScopeSpans `json:"instrumentationLibrarySpans"`
+}
+
+// Resource is the entity producing the spans, described by its attributes.
+type Resource struct {
+	Attributes []*KeyValue `json:"attributes"`
+}
+
+// ScopeSpans holds the spans of an instrumentation scope.
+type ScopeSpans struct {
+	Scope *Scope `json:"scope"`
+	// InstrumentationLibrary is the name of Scope in the versions of OTLP
+	// prior to 0.19.
+	InstrumentationLibrary *Scope  `json:"instrumentationLibrary"`
+	Spans                  []*Span `json:"spans"`
+}
+
+// Scope is the instrumentation scope, i.e. library, producing the spans.
+type Scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Span is an OTLP span. Its trace ID is 16 bytes long, and its span and
+// parent span IDs 8 bytes long; the parent span ID of the root spans is
+// empty.
+type Span struct {
+	TraceID                HexBytes    `json:"traceId"`
+	SpanID                 HexBytes    `json:"spanId"`
+	ParentSpanID           HexBytes    `json:"parentSpanId"`
+	TraceState             string      `json:"traceState"`
+	Name                   string      `json:"name"`
+	Kind                   SpanKind    `json:"kind"`
+	StartTimeUnixNano      Uint64      `json:"startTimeUnixNano"`
+	EndTimeUnixNano        Uint64      `json:"endTimeUnixNano"`
+	Attributes             []*KeyValue `json:"attributes"`
+	DroppedAttributesCount uint32      `json:"droppedAttributesCount"`
+	Events                 []*Event    `json:"events"`
+	DroppedEventsCount     uint32      `json:"droppedEventsCount"`
+	Links                  []*Link     `json:"links"`
+	DroppedLinksCount      uint32      `json:"droppedLinksCount"`
+	Status                 *Status     `json:"status"`
+}
+
+// Event is a time-stamped event of a span.
+type Event struct {
+	TimeUnixNano           Uint64      `json:"timeUnixNano"`
+	Name                   string      `json:"name"`
+	Attributes             []*KeyValue `json:"attributes"`
+	DroppedAttributesCount uint32      `json:"droppedAttributesCount"`
+}
+
+// Link is a link from a span to another one.
+type Link struct {
+	TraceID                HexBytes    `json:"traceId"`
+	SpanID                 HexBytes    `json:"spanId"`
+	TraceState             string      `json:"traceState"`
+	Attributes             []*KeyValue `json:"attributes"`
+	DroppedAttributesCount uint32      `json:"droppedAttributesCount"`
+}
+
+// The values of the status codes.
+const (
+	StatusCodeUnset StatusCode = 0
+	StatusCodeOk    StatusCode = 1
+	StatusCodeError StatusCode = 2
+)
+
+// Status is the status of a span.
+type Status struct {
+	Message string     `json:"message"`
+	Code    StatusCode `json:"code"`
+}
+
+// KeyValue is an attribute.
+type KeyValue struct {
+	Key   string    `json:"key"`
+	Value *AnyValue `json:"value"`
+}
+
+// AnyValue holds one of its fields, like the AnyValue oneof.
+type AnyValue struct {
+	StringValue *string       `json:"stringValue"`
+	BoolValue   *bool         `json:"boolValue"`
+	IntValue    *Int64        `json:"intValue"`
+	DoubleValue *float64      `json:"doubleValue"`
+	ArrayValue  *ArrayValue   `json:"arrayValue"`
+	KvlistValue *KeyValueList `json:"kvlistValue"`
+	BytesValue  []byte        `json:"bytesValue"`
+}
+
+// ArrayValue is an array of values.
+type ArrayValue struct {
+	Values []*AnyValue `json:"values"`
+}
+
+// KeyValueList is a map of values, as a list of its entries.
+type KeyValueList struct {
+	Values []*KeyValue `json:"values"`
+}
+
+// HexBytes is an ID, hex encoded in OTLP/JSON.
+type HexBytes []byte
+
+// UnmarshalJSON decodes the hex encoded ID.
+func (hb *HexBytes) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid hex ID %q: %v", s, err)
+	}
+	*hb = decoded
+	return nil
+}
+
+// Uint64 is a 64 bit integer, encoded as a decimal string or as a number in
+// OTLP/JSON.
+type Uint64 uint64
+
+// UnmarshalJSON decodes the integer from a string or a number.
+func (v *Uint64) UnmarshalJSON(b []byte) error {
+	u, err := strconv.ParseUint(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = Uint64(u)
+	return nil
+}
+
+// Int64 is a 64 bit integer, encoded as a decimal string or as a number in
+// OTLP/JSON.
+type Int64 int64
+
+// UnmarshalJSON decodes the integer from a string or a number.
+func (v *Int64) UnmarshalJSON(b []byte) error {
+	i, err := strconv.ParseInt(strings.Trim(string(b), `"`), 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = Int64(i)
+	return nil
+}
+
+// The values of the span kinds.
+const (
+	SpanKindUnspecified SpanKind = 0
+	SpanKindInternal    SpanKind = 1
+	SpanKindServer      SpanKind = 2
+	SpanKindClient      SpanKind = 3
+	SpanKindProducer    SpanKind = 4
+	SpanKindConsumer    SpanKind = 5
+)
+
+var spanKindNames = map[string]int32{
+	"SPAN_KIND_UNSPECIFIED": int32(SpanKindUnspecified),
+	"SPAN_KIND_INTERNAL":    int32(SpanKindInternal),
+	"SPAN_KIND_SERVER":      int32(SpanKindServer),
+	"SPAN_KIND_CLIENT":      int32(SpanKindClient),
+	"SPAN_KIND_PRODUCER":    int32(SpanKindProducer),
+	"SPAN_KIND_CONSUMER":    int32(SpanKindConsumer),
+}
+
+// SpanKind is encoded as a number, or as the name of the enum value, in
+// OTLP/JSON.
+type SpanKind int32
+
+// UnmarshalJSON decodes the span kind from its number or name.
+func (sk *SpanKind) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b, spanKindNames)
+	*sk = SpanKind(v)
+	return err
+}
+
+var statusCodeNames = map[string]int32{
+	"STATUS_CODE_UNSET": int32(StatusCodeUnset),
+	"STATUS_CODE_OK":    int32(StatusCodeOk),
+	"STATUS_CODE_ERROR": int32(StatusCodeError),
+}
+
+// StatusCode is encoded as a number, or as the name of the enum value, in
+// OTLP/JSON.
+type StatusCode int32
+
+// UnmarshalJSON decodes the status code from its number or name.
+func (sc *StatusCode) UnmarshalJSON(b []byte) error {
+	v, err := unmarshalEnum(b, statusCodeNames)
+	*sc = StatusCode(v)
+	return err
+}
+
+func unmarshalEnum(b []byte, names map[string]int32) (int32, error) {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		v, ok := names[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown enum value %q", name)
+		}
+		return v, nil
+	}
+	var v int32
+	err := json.Unmarshal(b, &v)
+	return v, err
+}
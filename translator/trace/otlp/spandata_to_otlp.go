@@ -0,0 +1,239 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+// The attributes holding what OTLP spans have no field for.
+const (
+	eventNameMessage                 = "message"
+	attributeMessageType             = "message.type"
+	attributeMessageID               = "message.id"
+	attributeMessageUncompressedSize = "message.uncompressed_size"
+	attributeMessageCompressedSize   = "message.compressed_size"
+	attributeLinkType                = "opencensus.link.type"
+)
+
+// SpanDataToOTLP converts an OpenCensus Go span to an OTLP span.
+//
+// The annotations and message events are both converted to events, ordered
+// by time, the message events being named "message" with their type, ID and
+// sizes as attributes. The OpenCensus status code 0 is converted to the unset
+// status and the other codes to the error one, with the message of the
+// status. The trace options, the remote parent flag and the child span count
+// are not converted.
+func SpanDataToOTLP(sd *trace.SpanData) *Span {
+	if sd == nil {
+		return nil
+	}
+
+	s := &Span{
+		TraceID:                HexBytes(sd.TraceID[:]),
+		SpanID:                 HexBytes(sd.SpanID[:]),
+		TraceState:             ocTracestateToOTLP(sd.Tracestate),
+		Name:                   sd.Name,
+		Kind:                   ocSpanKindToOTLP(sd.SpanKind),
+		StartTimeUnixNano:      timeToUnixNano(sd.StartTime),
+		EndTimeUnixNano:        timeToUnixNano(sd.EndTime),
+		Attributes:             ocAttributesToOTLP(sd.Attributes),
+		DroppedAttributesCount: uint32(sd.DroppedAttributeCount),
+		Events:                 ocEventsToOTLP(sd.Annotations, sd.MessageEvents),
+		DroppedEventsCount:     uint32(sd.DroppedAnnotationCount + sd.DroppedMessageEventCount),
+		Links:                  ocLinksToOTLP(sd.Links),
+		DroppedLinksCount:      uint32(sd.DroppedLinkCount),
+		Status:                 ocStatusToOTLP(sd.Status),
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		s.ParentSpanID = HexBytes(sd.ParentSpanID[:])
+	}
+	return s
+}
+
+// SpanDataToResourceSpans converts the OpenCensus Go spans of a resource,
+// described by its labels, to OTLP resource spans holding a single scope. The
+// resource attributes are sorted by key.
+func SpanDataToResourceSpans(labels map[string]string, spans []*trace.SpanData) *ResourceSpans {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := &Resource{Attributes: make([]*KeyValue, 0, len(keys))}
+	for _, k := range keys {
+		res.Attributes = append(res.Attributes, stringKeyValue(k, labels[k]))
+	}
+
+	scopeSpans := &ScopeSpans{Spans: make([]*Span, 0, len(spans))}
+	for _, sd := range spans {
+		scopeSpans.Spans = append(scopeSpans.Spans, SpanDataToOTLP(sd))
+	}
+	return &ResourceSpans{Resource: res, ScopeSpans: []*ScopeSpans{scopeSpans}}
+}
+
+func timeToUnixNano(t time.Time) Uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return Uint64(t.UnixNano())
+}
+
+func ocSpanKindToOTLP(kind int) SpanKind {
+	switch kind {
+	case trace.SpanKindServer:
+		return SpanKindServer
+	case trace.SpanKindClient:
+		return SpanKindClient
+	}
+	return SpanKindUnspecified
+}
+
+func ocTracestateToOTLP(ts *tracestate.Tracestate) string {
+	if ts == nil {
+		return ""
+	}
+	entries := ts.Entries()
+	members := make([]string, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, e.Key+"="+e.Value)
+	}
+	return strings.Join(members, ",")
+}
+
+func ocEventsToOTLP(annotations []trace.Annotation, messageEvents []trace.MessageEvent) []*Event {
+	if len(annotations) == 0 && len(messageEvents) == 0 {
+		return nil
+	}
+	events := make([]*Event, 0, len(annotations)+len(messageEvents))
+	for _, a := range annotations {
+		events = append(events, &Event{
+			TimeUnixNano: timeToUnixNano(a.Time),
+			Name:         a.Message,
+			Attributes:   ocAttributesToOTLP(a.Attributes),
+		})
+	}
+	for _, me := range messageEvents {
+		events = append(events, &Event{
+			TimeUnixNano: timeToUnixNano(me.Time),
+			Name:         eventNameMessage,
+			Attributes: []*KeyValue{
+				stringKeyValue(attributeMessageType, ocMessageEventTypeToOTLP(me.EventType)),
+				intKeyValue(attributeMessageID, me.MessageID),
+				intKeyValue(attributeMessageUncompressedSize, me.UncompressedByteSize),
+				intKeyValue(attributeMessageCompressedSize, me.CompressedByteSize),
+			},
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TimeUnixNano < events[j].TimeUnixNano
+	})
+	return events
+}
+
+func ocMessageEventTypeToOTLP(t trace.MessageEventType) string {
+	switch t {
+	case trace.MessageEventTypeSent:
+		return "SENT"
+	case trace.MessageEventTypeRecv:
+		return "RECEIVED"
+	}
+	return "UNSPECIFIED"
+}
+
+func ocLinksToOTLP(links []trace.Link) []*Link {
+	if len(links) == 0 {
+		return nil
+	}
+	otlpLinks := make([]*Link, 0, len(links))
+	for _, l := range links {
+		attrs := ocAttributesToOTLP(l.Attributes)
+		switch l.Type {
+		case trace.LinkTypeChild:
+			attrs = append(attrs, stringKeyValue(attributeLinkType, "CHILD_LINKED_SPAN"))
+		case trace.LinkTypeParent:
+			attrs = append(attrs, stringKeyValue(attributeLinkType, "PARENT_LINKED_SPAN"))
+		}
+		otlpLinks = append(otlpLinks, &Link{
+			TraceID:    HexBytes(l.TraceID[:]),
+			SpanID:     HexBytes(l.SpanID[:]),
+			Attributes: attrs,
+		})
+	}
+	return otlpLinks
+}
+
+func ocStatusToOTLP(s trace.Status) *Status {
+	if s.Code == 0 {
+		if s.Message == "" {
+			return nil
+		}
+		return &Status{Message: s.Message, Code: StatusCodeUnset}
+	}
+	return &Status{Message: s.Message, Code: StatusCodeError}
+}
+
+// ocAttributesToOTLP converts the attributes, sorted by key. The values of
+// the types other than the ones of the OpenCensus attributes are converted to
+// strings.
+func ocAttributesToOTLP(attrs map[string]interface{}) []*KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]*KeyValue, 0, len(keys))
+	for _, k := range keys {
+		av := new(AnyValue)
+		switch v := attrs[k].(type) {
+		case string:
+			av.StringValue = &v
+		case bool:
+			av.BoolValue = &v
+		case int64:
+			i := Int64(v)
+			av.IntValue = &i
+		case int:
+			i := Int64(v)
+			av.IntValue = &i
+		case float64:
+			av.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			av.StringValue = &s
+		}
+		kvs = append(kvs, &KeyValue{Key: k, Value: av})
+	}
+	return kvs
+}
+
+func stringKeyValue(key, value string) *KeyValue {
+	return &KeyValue{Key: key, Value: &AnyValue{StringValue: &value}}
+}
+
+func intKeyValue(key string, value int64) *KeyValue {
+	i := Int64(value)
+	return &KeyValue{Key: key, Value: &AnyValue{IntValue: &i}}
+}
@@ -0,0 +1,251 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+)
+
+func TestSpanDataToOTLP_endToEnd(t *testing.T) {
+	endTime := time.Unix(1560000090, 123456789)
+	startTime := endTime.Add(-90 * time.Second)
+	eventTime := startTime.Add(30 * time.Second)
+
+	ocTracestate, err := tracestate.New(new(tracestate.Tracestate), tracestate.Entry{Key: "foo", Value: "bar"},
+		tracestate.Entry{Key: "a", Value: "b"})
+	if err != nil || ocTracestate == nil {
+		t.Fatalf("Failed to create ocTracestate: %v", err)
+	}
+
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:    trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanID:     trace.SpanID{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+			Tracestate: ocTracestate,
+		},
+		SpanKind:     trace.SpanKindClient,
+		ParentSpanID: trace.SpanID{0xEF, 0xEE, 0xED, 0xEC, 0xEB, 0xEA, 0xE9, 0xE8},
+		Name:         "End-To-End Here",
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Attributes: map[string]interface{}{
+			"timeout_ns": int64(12e9),
+			"agent":      "ocagent",
+			"cache_hit":  true,
+			"ping_count": int(25),
+			"ratio":      0.5,
+		},
+		Annotations: []trace.Annotation{
+			{Time: eventTime, Message: "cache miss", Attributes: map[string]interface{}{"key": "users"}},
+		},
+		MessageEvents: []trace.MessageEvent{
+			{Time: startTime, EventType: trace.MessageEventTypeSent, MessageID: 1, UncompressedByteSize: 1024, CompressedByteSize: 512},
+			{Time: endTime, EventType: trace.MessageEventTypeRecv, MessageID: 2, UncompressedByteSize: 1024, CompressedByteSize: 1000},
+		},
+		Links: []trace.Link{
+			{
+				TraceID: trace.TraceID{0xC0, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0xCA, 0xCB, 0xCC, 0xCD, 0xCE, 0xCF},
+				SpanID:  trace.SpanID{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7},
+				Type:    trace.LinkTypeParent,
+			},
+		},
+		Status: trace.Status{
+			Code:    trace.StatusCodeInternal,
+			Message: "This is not a drill!",
+		},
+		DroppedAttributeCount:    1,
+		DroppedAnnotationCount:   2,
+		DroppedMessageEventCount: 3,
+		DroppedLinkCount:         4,
+	}
+
+	want := &Span{
+		TraceID:           HexBytes{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+		SpanID:            HexBytes{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+		ParentSpanID:      HexBytes{0xEF, 0xEE, 0xED, 0xEC, 0xEB, 0xEA, 0xE9, 0xE8},
+		TraceState:        "foo=bar,a=b",
+		Name:              "End-To-End Here",
+		Kind:              SpanKindClient,
+		StartTimeUnixNano: Uint64(startTime.UnixNano()),
+		EndTimeUnixNano:   Uint64(endTime.UnixNano()),
+		Attributes: []*KeyValue{
+			stringKeyValue("agent", "ocagent"),
+			boolKeyValue("cache_hit", true),
+			intKeyValue("ping_count", 25),
+			doubleKeyValue("ratio", 0.5),
+			intKeyValue("timeout_ns", 12e9),
+		},
+		DroppedAttributesCount: 1,
+		Events: []*Event{
+			{
+				TimeUnixNano: Uint64(startTime.UnixNano()),
+				Name:         "message",
+				Attributes: []*KeyValue{
+					stringKeyValue("message.type", "SENT"),
+					intKeyValue("message.id", 1),
+					intKeyValue("message.uncompressed_size", 1024),
+					intKeyValue("message.compressed_size", 512),
+				},
+			},
+			{
+				TimeUnixNano: Uint64(eventTime.UnixNano()),
+				Name:         "cache miss",
+				Attributes:   []*KeyValue{stringKeyValue("key", "users")},
+			},
+			{
+				TimeUnixNano: Uint64(endTime.UnixNano()),
+				Name:         "message",
+				Attributes: []*KeyValue{
+					stringKeyValue("message.type", "RECEIVED"),
+					intKeyValue("message.id", 2),
+					intKeyValue("message.uncompressed_size", 1024),
+					intKeyValue("message.compressed_size", 1000),
+				},
+			},
+		},
+		DroppedEventsCount: 5,
+		Links: []*Link{
+			{
+				TraceID:    HexBytes{0xC0, 0xC1, 0xC2, 0xC3, 0xC4, 0xC5, 0xC6, 0xC7, 0xC8, 0xC9, 0xCA, 0xCB, 0xCC, 0xCD, 0xCE, 0xCF},
+				SpanID:     HexBytes{0xB0, 0xB1, 0xB2, 0xB3, 0xB4, 0xB5, 0xB6, 0xB7},
+				Attributes: []*KeyValue{stringKeyValue("opencensus.link.type", "PARENT_LINKED_SPAN")},
+			},
+		},
+		DroppedLinksCount: 4,
+		Status:            &Status{Message: "This is not a drill!", Code: StatusCodeError},
+	}
+
+	got := SpanDataToOTLP(sd)
+	if !reflect.DeepEqual(got, want) {
+		gBlob, _ := json.MarshalIndent(got, "", "  ")
+		wBlob, _ := json.MarshalIndent(want, "", "  ")
+		t.Fatalf("Converted span\n\tGot  %s\n\tWant %s", gBlob, wBlob)
+	}
+
+	// The span must survive the protobuf encoding unchanged.
+	decoded, err := UnmarshalSpan(MarshalSpan(got))
+	if err != nil {
+		t.Fatalf("Failed to decode the encoded span: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		gBlob, _ := json.MarshalIndent(decoded, "", "  ")
+		wBlob, _ := json.MarshalIndent(want, "", "  ")
+		t.Fatalf("Decoded span\n\tGot  %s\n\tWant %s", gBlob, wBlob)
+	}
+	checkIDs(t, decoded, sd)
+}
+
+func TestSpanDataToOTLP_rootSpan(t *testing.T) {
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanID:  trace.SpanID{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+		},
+		Name: "root",
+	}
+
+	s := SpanDataToOTLP(sd)
+	if s.ParentSpanID != nil {
+		t.Errorf("Got parent span ID %x, want none for the root span", s.ParentSpanID)
+	}
+	if s.Status != nil || s.Events != nil || s.Links != nil || s.Attributes != nil {
+		t.Errorf("Got status, events, links or attributes in %+v, want none", s)
+	}
+
+	decoded, err := UnmarshalSpan(MarshalSpan(s))
+	if err != nil {
+		t.Fatalf("Failed to decode the encoded span: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, s) {
+		t.Fatalf("Decoded span %+v, want %+v", decoded, s)
+	}
+	checkIDs(t, decoded, sd)
+}
+
+func TestSpanDataToOTLP_status(t *testing.T) {
+	tests := []struct {
+		status trace.Status
+		want   *Status
+	}{
+		{status: trace.Status{}, want: nil},
+		{status: trace.Status{Message: "done"}, want: &Status{Message: "done", Code: StatusCodeUnset}},
+		{status: trace.Status{Code: trace.StatusCodeNotFound}, want: &Status{Code: StatusCodeError}},
+		{status: trace.Status{Code: trace.StatusCodeUnknown, Message: "boom"}, want: &Status{Message: "boom", Code: StatusCodeError}},
+	}
+	for _, tt := range tests {
+		got := SpanDataToOTLP(&trace.SpanData{Status: tt.status}).Status
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Status %+v converted to %+v, want %+v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestSpanDataToOTLP_nil(t *testing.T) {
+	if s := SpanDataToOTLP(nil); s != nil {
+		t.Errorf("Got %+v for a nil span, want nil", s)
+	}
+}
+
+func TestSpanDataToResourceSpans(t *testing.T) {
+	rs := SpanDataToResourceSpans(
+		map[string]string{"service.name": "checkout", "k8s.namespace": "prod"},
+		[]*trace.SpanData{{Name: "first"}, {Name: "second"}},
+	)
+	wantAttrs := []*KeyValue{stringKeyValue("k8s.namespace", "prod"), stringKeyValue("service.name", "checkout")}
+	if !reflect.DeepEqual(rs.Resource.Attributes, wantAttrs) {
+		t.Errorf("Got resource attributes %+v, want %+v", rs.Resource.Attributes, wantAttrs)
+	}
+	if len(rs.ScopeSpans) != 1 {
+		t.Fatalf("Got %d scopes, want 1", len(rs.ScopeSpans))
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "first" || spans[1].Name != "second" {
+		t.Errorf("Got spans %+v, want the first and second ones", spans)
+	}
+}
+
+// checkIDs checks the lengths and values of the IDs of s, converted from sd:
+// the OpenCensus IDs are arrays, the OTLP ones slices which must be as long.
+func checkIDs(t *testing.T, s *Span, sd *trace.SpanData) {
+	t.Helper()
+	if len(s.TraceID) != 16 || !bytes.Equal(s.TraceID, sd.TraceID[:]) {
+		t.Errorf("Got trace ID %x of %d bytes, want %x of 16 bytes", []byte(s.TraceID), len(s.TraceID), sd.TraceID[:])
+	}
+	if len(s.SpanID) != 8 || !bytes.Equal(s.SpanID, sd.SpanID[:]) {
+		t.Errorf("Got span ID %x of %d bytes, want %x of 8 bytes", []byte(s.SpanID), len(s.SpanID), sd.SpanID[:])
+	}
+	if sd.ParentSpanID == (trace.SpanID{}) {
+		return
+	}
+	if len(s.ParentSpanID) != 8 || !bytes.Equal(s.ParentSpanID, sd.ParentSpanID[:]) {
+		t.Errorf("Got parent span ID %x of %d bytes, want %x of 8 bytes",
+			[]byte(s.ParentSpanID), len(s.ParentSpanID), sd.ParentSpanID[:])
+	}
+}
+
+func boolKeyValue(key string, value bool) *KeyValue {
+	return &KeyValue{Key: key, Value: &AnyValue{BoolValue: &value}}
+}
+
+func doubleKeyValue(key string, value float64) *KeyValue {
+	return &KeyValue{Key: key, Value: &AnyValue{DoubleValue: &value}}
+}
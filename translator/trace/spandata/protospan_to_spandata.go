@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package spandata defines translators from Trace proto spans to OpenCensus Go spanData.
+// Package spandata defines translators between Trace proto spans and OpenCensus Go spanData.
 package spandata
 
 import (
@@ -0,0 +1,60 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spandata
+
+import (
+	"go.opencensus.io/resource"
+	"go.opencensus.io/trace"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+)
+
+// ProtoResourceToOCResource returns the resource of the spans sent by node
+// with res: its labels are the attributes of the node, overridden by the
+// labels of res. It is nil if there is neither attributes nor labels.
+func ProtoResourceToOCResource(node *commonpb.Node, res *resourcepb.Resource) *resource.Resource {
+	if len(node.GetAttributes()) == 0 && res == nil {
+		return nil
+	}
+	ocResource := &resource.Resource{
+		Type:   res.GetType(),
+		Labels: make(map[string]string, len(node.GetAttributes())+len(res.GetLabels())),
+	}
+	for k, v := range node.GetAttributes() {
+		ocResource.Labels[k] = v
+	}
+	for k, v := range res.GetLabels() {
+		ocResource.Labels[k] = v
+	}
+	return ocResource
+}
+
+// AddResourceAttributes adds the labels of res to the attributes of sd, for
+// the exporters of trace.SpanData that don't know about resources. The
+// attributes of the span take precedence.
+func AddResourceAttributes(sd *trace.SpanData, res *resource.Resource) {
+	if res == nil || len(res.Labels) == 0 {
+		return
+	}
+	if sd.Attributes == nil {
+		sd.Attributes = make(map[string]interface{}, len(res.Labels))
+	}
+	for k, v := range res.Labels {
+		if _, ok := sd.Attributes[k]; !ok {
+			sd.Attributes[k] = v
+		}
+	}
+}
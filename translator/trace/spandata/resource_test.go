@@ -0,0 +1,85 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spandata
+
+import (
+	"reflect"
+	"testing"
+
+	"go.opencensus.io/resource"
+	"go.opencensus.io/trace"
+
+	commonpb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/common/v1"
+	resourcepb "github.com/census-instrumentation/opencensus-proto/gen-go/resource/v1"
+)
+
+func TestProtoResourceToOCResource(t *testing.T) {
+	tests := []struct {
+		name string
+		node *commonpb.Node
+		res  *resourcepb.Resource
+		want *resource.Resource
+	}{
+		{name: "none"},
+		{name: "node without attributes", node: &commonpb.Node{}},
+		{
+			name: "node attributes",
+			node: &commonpb.Node{Attributes: map[string]string{"host": "h1"}},
+			want: &resource.Resource{Labels: map[string]string{"host": "h1"}},
+		},
+		{
+			name: "resource",
+			res:  &resourcepb.Resource{Type: "k8s", Labels: map[string]string{"k8s.namespace": "prod"}},
+			want: &resource.Resource{Type: "k8s", Labels: map[string]string{"k8s.namespace": "prod"}},
+		},
+		{
+			name: "resource labels override the node attributes",
+			node: &commonpb.Node{Attributes: map[string]string{"host": "h1", "zone": "a"}},
+			res:  &resourcepb.Resource{Type: "host", Labels: map[string]string{"host": "h2"}},
+			want: &resource.Resource{Type: "host", Labels: map[string]string{"host": "h2", "zone": "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProtoResourceToOCResource(tt.node, tt.res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProtoResourceToOCResource() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddResourceAttributes(t *testing.T) {
+	res := &resource.Resource{Labels: map[string]string{"host": "h1", "zone": "a"}}
+
+	sd := &trace.SpanData{Attributes: map[string]interface{}{"zone": "b", "count": int64(1)}}
+	AddResourceAttributes(sd, res)
+	want := map[string]interface{}{"host": "h1", "zone": "b", "count": int64(1)}
+	if !reflect.DeepEqual(sd.Attributes, want) {
+		t.Errorf("Attributes = %v, want %v", sd.Attributes, want)
+	}
+
+	sd = &trace.SpanData{}
+	AddResourceAttributes(sd, res)
+	want = map[string]interface{}{"host": "h1", "zone": "a"}
+	if !reflect.DeepEqual(sd.Attributes, want) {
+		t.Errorf("Attributes without span attributes = %v, want %v", sd.Attributes, want)
+	}
+
+	sd = &trace.SpanData{}
+	AddResourceAttributes(sd, nil)
+	if sd.Attributes != nil {
+		t.Errorf("Attributes without resource = %v, want nil", sd.Attributes)
+	}
+}
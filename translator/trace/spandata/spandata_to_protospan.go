@@ -0,0 +1,214 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spandata
+
+import (
+	"fmt"
+	"sort"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	"github.com/census-instrumentation/opencensus-service/internal"
+)
+
+// OCSpanDataToProtoSpan transforms a trace.SpanData into the equivalent protobuf span. The
+// annotations and message events are both converted to time events, ordered by time.
+func OCSpanDataToProtoSpan(sd *trace.SpanData) (*tracepb.Span, error) {
+	if sd == nil {
+		return nil, errNilSpan
+	}
+
+	span := &tracepb.Span{
+		TraceId:                 append([]byte(nil), sd.TraceID[:]...),
+		SpanId:                  append([]byte(nil), sd.SpanID[:]...),
+		Tracestate:              ocTracestateToProtoTracestate(sd.Tracestate),
+		Name:                    &tracepb.TruncatableString{Value: sd.Name},
+		Kind:                    ocSpanKindToProtoSpanKind(sd.SpanKind),
+		StartTime:               internal.TimeToTimestamp(sd.StartTime),
+		EndTime:                 internal.TimeToTimestamp(sd.EndTime),
+		Attributes:              ocAttributesToProtoAttributes(sd.Attributes, sd.DroppedAttributeCount),
+		TimeEvents:              ocEventsToProtoTimeEvents(sd),
+		Links:                   ocLinksToProtoLinks(sd.Links, sd.DroppedLinkCount),
+		Status:                  ocStatusToProtoStatus(sd.Status),
+		SameProcessAsParentSpan: &wrappers.BoolValue{Value: !sd.HasRemoteParent},
+	}
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		span.ParentSpanId = append([]byte(nil), sd.ParentSpanID[:]...)
+	}
+	if sd.ChildSpanCount > 0 {
+		span.ChildSpanCount = &wrappers.UInt32Value{Value: uint32(sd.ChildSpanCount)}
+	}
+	return span, nil
+}
+
+func ocSpanKindToProtoSpanKind(kind int) tracepb.Span_SpanKind {
+	switch kind {
+	case trace.SpanKindClient:
+		return tracepb.Span_CLIENT
+	case trace.SpanKindServer:
+		return tracepb.Span_SERVER
+	default:
+		return tracepb.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func ocTracestateToProtoTracestate(ts *tracestate.Tracestate) *tracepb.Span_Tracestate {
+	if ts == nil {
+		return nil
+	}
+	entries := ts.Entries()
+	protoEntries := make([]*tracepb.Span_Tracestate_Entry, 0, len(entries))
+	for _, entry := range entries {
+		protoEntries = append(protoEntries, &tracepb.Span_Tracestate_Entry{
+			Key:   entry.Key,
+			Value: entry.Value,
+		})
+	}
+	return &tracepb.Span_Tracestate{Entries: protoEntries}
+}
+
+func ocStatusToProtoStatus(s trace.Status) *tracepb.Status {
+	if s.Code == 0 && s.Message == "" {
+		return nil
+	}
+	return &tracepb.Status{
+		Code:    s.Code,
+		Message: s.Message,
+	}
+}
+
+// ocAttributesToProtoAttributes converts the attributes, the values of the types other than the
+// ones of the OpenCensus attributes being converted to strings.
+func ocAttributesToProtoAttributes(attrs map[string]interface{}, droppedCount int) *tracepb.Span_Attributes {
+	if len(attrs) == 0 && droppedCount == 0 {
+		return nil
+	}
+
+	attrMap := make(map[string]*tracepb.AttributeValue, len(attrs))
+	for key, value := range attrs {
+		var av tracepb.AttributeValue
+		switch v := value.(type) {
+		case bool:
+			av.Value = &tracepb.AttributeValue_BoolValue{BoolValue: v}
+		case int64:
+			av.Value = &tracepb.AttributeValue_IntValue{IntValue: v}
+		case int:
+			av.Value = &tracepb.AttributeValue_IntValue{IntValue: int64(v)}
+		case float64:
+			av.Value = &tracepb.AttributeValue_DoubleValue{DoubleValue: v}
+		case string:
+			av.Value = &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: v}}
+		default:
+			av.Value = &tracepb.AttributeValue_StringValue{StringValue: &tracepb.TruncatableString{Value: fmt.Sprint(v)}}
+		}
+		attrMap[key] = &av
+	}
+	return &tracepb.Span_Attributes{
+		AttributeMap:           attrMap,
+		DroppedAttributesCount: int32(droppedCount),
+	}
+}
+
+func ocEventsToProtoTimeEvents(sd *trace.SpanData) *tracepb.Span_TimeEvents {
+	if len(sd.Annotations) == 0 && len(sd.MessageEvents) == 0 &&
+		sd.DroppedAnnotationCount == 0 && sd.DroppedMessageEventCount == 0 {
+		return nil
+	}
+
+	tes := make([]*tracepb.Span_TimeEvent, 0, len(sd.Annotations)+len(sd.MessageEvents))
+	for _, ann := range sd.Annotations {
+		tes = append(tes, &tracepb.Span_TimeEvent{
+			Time: internal.TimeToTimestamp(ann.Time),
+			Value: &tracepb.Span_TimeEvent_Annotation_{
+				Annotation: &tracepb.Span_TimeEvent_Annotation{
+					Description: &tracepb.TruncatableString{Value: ann.Message},
+					Attributes:  ocAttributesToProtoAttributes(ann.Attributes, 0),
+				},
+			},
+		})
+	}
+	for _, me := range sd.MessageEvents {
+		tes = append(tes, &tracepb.Span_TimeEvent{
+			Time: internal.TimeToTimestamp(me.Time),
+			Value: &tracepb.Span_TimeEvent_MessageEvent_{
+				MessageEvent: &tracepb.Span_TimeEvent_MessageEvent{
+					Type:             ocEventTypeToProtoMessageEventType(me.EventType),
+					Id:               uint64(me.MessageID),
+					UncompressedSize: uint64(me.UncompressedByteSize),
+					CompressedSize:   uint64(me.CompressedByteSize),
+				},
+			},
+		})
+	}
+	sort.SliceStable(tes, func(i, j int) bool {
+		ti, tj := tes[i].Time, tes[j].Time
+		if ti == nil || tj == nil {
+			return ti == nil && tj != nil
+		}
+		return ti.Seconds < tj.Seconds || (ti.Seconds == tj.Seconds && ti.Nanos < tj.Nanos)
+	})
+
+	return &tracepb.Span_TimeEvents{
+		TimeEvent:                 tes,
+		DroppedAnnotationsCount:   int32(sd.DroppedAnnotationCount),
+		DroppedMessageEventsCount: int32(sd.DroppedMessageEventCount),
+	}
+}
+
+func ocEventTypeToProtoMessageEventType(et trace.MessageEventType) tracepb.Span_TimeEvent_MessageEvent_Type {
+	switch et {
+	case trace.MessageEventTypeSent:
+		return tracepb.Span_TimeEvent_MessageEvent_SENT
+	case trace.MessageEventTypeRecv:
+		return tracepb.Span_TimeEvent_MessageEvent_RECEIVED
+	default:
+		return tracepb.Span_TimeEvent_MessageEvent_TYPE_UNSPECIFIED
+	}
+}
+
+func ocLinksToProtoLinks(links []trace.Link, droppedCount int) *tracepb.Span_Links {
+	if len(links) == 0 && droppedCount == 0 {
+		return nil
+	}
+
+	protoLinks := make([]*tracepb.Span_Link, 0, len(links))
+	for _, link := range links {
+		protoLinks = append(protoLinks, &tracepb.Span_Link{
+			TraceId:    append([]byte(nil), link.TraceID[:]...),
+			SpanId:     append([]byte(nil), link.SpanID[:]...),
+			Type:       ocLinkTypeToProtoLinkType(link.Type),
+			Attributes: ocAttributesToProtoAttributes(link.Attributes, 0),
+		})
+	}
+	return &tracepb.Span_Links{
+		Link:              protoLinks,
+		DroppedLinksCount: int32(droppedCount),
+	}
+}
+
+func ocLinkTypeToProtoLinkType(lt trace.LinkType) tracepb.Span_Link_Type {
+	switch lt {
+	case trace.LinkTypeChild:
+		return tracepb.Span_Link_CHILD_LINKED_SPAN
+	case trace.LinkTypeParent:
+		return tracepb.Span_Link_PARENT_LINKED_SPAN
+	default:
+		return tracepb.Span_Link_TYPE_UNSPECIFIED
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spandata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.opencensus.io/trace/tracestate"
+
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+)
+
+func TestOCSpanDataToProtoSpan_roundTrip(t *testing.T) {
+	endTime := time.Unix(1544712660, 0)
+	startTime := endTime.Add(-90 * time.Second)
+	ts, err := tracestate.New(nil, tracestate.Entry{Key: "foo", Value: "bar"})
+	if err != nil {
+		t.Fatalf("Failed to create the tracestate: %v", err)
+	}
+
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:    trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10},
+			SpanID:     trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			Tracestate: ts,
+		},
+		ParentSpanID: trace.SpanID{0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27, 0x28},
+		SpanKind:     trace.SpanKindServer,
+		Name:         "ping",
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Attributes: map[string]interface{}{
+			"cache_hit":  true,
+			"timeout_ns": int64(12e9),
+			"peer":       "localhost",
+		},
+		Annotations: []trace.Annotation{
+			{
+				Time:       startTime.Add(10 * time.Second),
+				Message:    "cache miss",
+				Attributes: map[string]interface{}{"ratio": 0.5},
+			},
+		},
+		MessageEvents: []trace.MessageEvent{
+			{
+				Time:                 startTime.Add(20 * time.Second),
+				EventType:            trace.MessageEventTypeRecv,
+				MessageID:            7,
+				UncompressedByteSize: 1024,
+				CompressedByteSize:   512,
+			},
+		},
+		Links: []trace.Link{
+			{
+				TraceID: trace.TraceID{0xFF, 0xFE},
+				SpanID:  trace.SpanID{0xEF},
+				Type:    trace.LinkTypeParent,
+			},
+		},
+		Status:          trace.Status{Code: 13, Message: "internal"},
+		HasRemoteParent: true,
+	}
+
+	span, err := OCSpanDataToProtoSpan(sd)
+	if err != nil {
+		t.Fatalf("OCSpanDataToProtoSpan() error = %v", err)
+	}
+	got, err := ProtoSpanToOCSpanData(span)
+	if err != nil {
+		t.Fatalf("ProtoSpanToOCSpanData() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, sd) {
+		t.Errorf("Round trip mismatch:\nGot:  %+v\nWant: %+v", got, sd)
+	}
+}
+
+func TestOCSpanDataToProtoSpan(t *testing.T) {
+	if _, err := OCSpanDataToProtoSpan(nil); err != errNilSpan {
+		t.Errorf("OCSpanDataToProtoSpan(nil) error = %v, want %v", err, errNilSpan)
+	}
+
+	start := time.Unix(1544712660, 0)
+	span, err := OCSpanDataToProtoSpan(&trace.SpanData{
+		Name:      "root",
+		StartTime: start,
+		Annotations: []trace.Annotation{
+			{Time: start.Add(2 * time.Second), Message: "second"},
+		},
+		MessageEvents: []trace.MessageEvent{
+			{Time: start.Add(time.Second), EventType: trace.MessageEventTypeSent},
+		},
+		ChildSpanCount:        3,
+		DroppedAttributeCount: 1,
+		DroppedLinkCount:      2,
+	})
+	if err != nil {
+		t.Fatalf("OCSpanDataToProtoSpan() error = %v", err)
+	}
+
+	if span.ParentSpanId != nil {
+		t.Errorf("ParentSpanId = %x, want none for a root span", span.ParentSpanId)
+	}
+	if span.Status != nil {
+		t.Errorf("Status = %v, want none", span.Status)
+	}
+	if got := span.ChildSpanCount.GetValue(); got != 3 {
+		t.Errorf("ChildSpanCount = %d, want 3", got)
+	}
+	if got := span.Attributes.GetDroppedAttributesCount(); got != 1 {
+		t.Errorf("DroppedAttributesCount = %d, want 1", got)
+	}
+	if got := span.Links.GetDroppedLinksCount(); got != 2 {
+		t.Errorf("DroppedLinksCount = %d, want 2", got)
+	}
+	// The time events are ordered by time, whatever their type.
+	tes := span.TimeEvents.GetTimeEvent()
+	if len(tes) != 2 {
+		t.Fatalf("Got %d time events, want 2", len(tes))
+	}
+	if _, ok := tes[0].Value.(*tracepb.Span_TimeEvent_MessageEvent_); !ok {
+		t.Errorf("The first time event is a %T, want the message event", tes[0].Value)
+	}
+	if _, ok := tes[1].Value.(*tracepb.Span_TimeEvent_Annotation_); !ok {
+		t.Errorf("The second time event is a %T, want the annotation", tes[1].Value)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"go.opencensus.io/trace"
+)
+
+// The tags holding the status of the spans, as in the Zipkin exporter.
+const (
+	statusCodeTagKey        = "error"
+	statusDescriptionTagKey = "opencensus.status_description"
+)
+
+// The OpenTracing attributes describing the remote endpoint of a span.
+const (
+	peerServiceKey = "peer.service"
+	peerIPv4Key    = "peer.ipv4"
+	peerIPv6Key    = "peer.ipv6"
+	peerPortKey    = "peer.port"
+)
+
+// Replica of the exporter/zipkinexporter canonicalCodes.
+var canonicalCodes = [...]string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// SpanDataToZipkinV2 translates an OpenCensus Go span into a Zipkin v2 span,
+// of the given local endpoint. The remote endpoint is built from the peer.*
+// attributes of the span, if any. The JSON encoding of the returned span is
+// the one of the Zipkin v2 API.
+func SpanDataToZipkinV2(sd *trace.SpanData, localEndpoint *zipkinmodel.Endpoint) *zipkinmodel.SpanModel {
+	if sd == nil {
+		return nil
+	}
+
+	sampled := sd.IsSampled()
+	zSpan := &zipkinmodel.SpanModel{
+		SpanContext: zipkinmodel.SpanContext{
+			TraceID: zipkinmodel.TraceID{
+				High: binary.BigEndian.Uint64(sd.TraceID[:8]),
+				Low:  binary.BigEndian.Uint64(sd.TraceID[8:]),
+			},
+			ID:      zipkinmodel.ID(binary.BigEndian.Uint64(sd.SpanID[:])),
+			Sampled: &sampled,
+		},
+		Name:           sd.Name,
+		Kind:           ocSpanKindToZipkin(sd.SpanKind),
+		Timestamp:      sd.StartTime,
+		LocalEndpoint:  localEndpoint,
+		RemoteEndpoint: remoteEndpointFromAttributes(sd.Attributes),
+		Tags:           ocAttributesToZipkinTags(sd.Attributes, sd.Status),
+		Annotations:    ocEventsToZipkinAnnotations(sd.Annotations, sd.MessageEvents),
+	}
+
+	if sd.ParentSpanID != (trace.SpanID{}) {
+		parentID := zipkinmodel.ID(binary.BigEndian.Uint64(sd.ParentSpanID[:]))
+		zSpan.ParentID = &parentID
+	}
+	if !sd.StartTime.IsZero() && !sd.EndTime.IsZero() {
+		zSpan.Duration = sd.EndTime.Sub(sd.StartTime)
+	}
+
+	return zSpan
+}
+
+func ocSpanKindToZipkin(kind int) zipkinmodel.Kind {
+	switch kind {
+	case trace.SpanKindClient:
+		return zipkinmodel.Client
+	case trace.SpanKindServer:
+		return zipkinmodel.Server
+	}
+	return zipkinmodel.Undetermined
+}
+
+// remoteEndpointFromAttributes returns the endpoint described by the peer.*
+// attributes, or nil if there are none. The IPv4 address can be a string or,
+// as allowed by OpenTracing, an integer.
+func remoteEndpointFromAttributes(attrs map[string]interface{}) *zipkinmodel.Endpoint {
+	endpoint := &zipkinmodel.Endpoint{}
+	if serviceName, ok := attrs[peerServiceKey].(string); ok {
+		endpoint.ServiceName = serviceName
+	}
+	switch ipv4 := attrs[peerIPv4Key].(type) {
+	case string:
+		endpoint.IPv4 = net.ParseIP(ipv4).To4()
+	case int64:
+		endpoint.IPv4 = make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(endpoint.IPv4, uint32(ipv4))
+	}
+	if ipv6, ok := attrs[peerIPv6Key].(string); ok {
+		endpoint.IPv6 = net.ParseIP(ipv6)
+	}
+	switch port := attrs[peerPortKey].(type) {
+	case int64:
+		endpoint.Port = uint16(port)
+	case string:
+		p, _ := strconv.ParseUint(port, 10, 16)
+		endpoint.Port = uint16(p)
+	}
+
+	if endpoint.ServiceName == "" && len(endpoint.IPv4) == 0 && len(endpoint.IPv6) == 0 && endpoint.Port == 0 {
+		return nil
+	}
+	return endpoint
+}
+
+// ocAttributesToZipkinTags converts the attributes and the status of a span to
+// tags, the values being formatted as strings.
+func ocAttributesToZipkinTags(attrs map[string]interface{}, status trace.Status) map[string]string {
+	if len(attrs) == 0 && status.Code == 0 && status.Message == "" {
+		return nil
+	}
+
+	tags := make(map[string]string, len(attrs)+2)
+	for key, value := range attrs {
+		switch v := value.(type) {
+		case string:
+			tags[key] = v
+		case bool:
+			tags[key] = strconv.FormatBool(v)
+		case int64:
+			tags[key] = strconv.FormatInt(v, 10)
+		case float64:
+			tags[key] = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			tags[key] = fmt.Sprint(v)
+		}
+	}
+	if status.Code != 0 {
+		tags[statusCodeTagKey] = canonicalCodeString(status.Code)
+	}
+	if status.Message != "" {
+		tags[statusDescriptionTagKey] = status.Message
+	}
+	return tags
+}
+
+func canonicalCodeString(code int32) string {
+	if code < 0 || int(code) >= len(canonicalCodes) {
+		return "error code " + strconv.FormatInt(int64(code), 10)
+	}
+	return canonicalCodes[code]
+}
+
+func ocEventsToZipkinAnnotations(annotations []trace.Annotation, messageEvents []trace.MessageEvent) []zipkinmodel.Annotation {
+	if len(annotations) == 0 && len(messageEvents) == 0 {
+		return nil
+	}
+
+	zAnnotations := make([]zipkinmodel.Annotation, 0, len(annotations)+len(messageEvents))
+	for _, a := range annotations {
+		zAnnotations = append(zAnnotations, zipkinmodel.Annotation{
+			Timestamp: a.Time,
+			Value:     a.Message,
+		})
+	}
+	for _, me := range messageEvents {
+		value := "<?>"
+		switch me.EventType {
+		case trace.MessageEventTypeSent:
+			value = "SENT"
+		case trace.MessageEventTypeRecv:
+			value = "RECV"
+		}
+		zAnnotations = append(zAnnotations, zipkinmodel.Annotation{
+			Timestamp: me.Time,
+			Value:     value,
+		})
+	}
+	return zAnnotations
+}
@@ -0,0 +1,326 @@
+// Copyright 2019, OpenCensus Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zipkin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	zipkinmodel "github.com/openzipkin/zipkin-go/model"
+	"go.opencensus.io/trace"
+)
+
+func TestSpanDataToZipkinV2_childSpan(t *testing.T) {
+	startTime := time.Unix(1485467191, 639875000)
+	endTime := startTime.Add(22938 * time.Microsecond)
+
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID:      trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x52, 0x96, 0x9A, 0x89, 0x55, 0x57, 0x1A, 0x3F},
+			SpanID:       trace.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x7D, 0x98},
+			TraceOptions: 1,
+		},
+		ParentSpanID: trace.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x68, 0xC4, 0xE3},
+		SpanKind:     trace.SpanKindClient,
+		Name:         "get",
+		StartTime:    startTime,
+		EndTime:      endTime,
+		Attributes: map[string]interface{}{
+			"http.url":     "http://127.0.0.1:15598/client_transactions",
+			"cache_hit":    true,
+			"ratio":        0.25,
+			"peer.service": "rtapi",
+			"peer.ipv4":    int64(3224716605),
+			"peer.port":    int64(53931),
+		},
+		Annotations: []trace.Annotation{
+			{Time: startTime.Add(time.Millisecond), Message: "cache miss"},
+		},
+		MessageEvents: []trace.MessageEvent{
+			{Time: startTime.Add(2 * time.Millisecond), EventType: trace.MessageEventTypeSent, MessageID: 1},
+		},
+		Status: trace.Status{Code: trace.StatusCodeNotFound, Message: "no such transaction"},
+	}
+	localEndpoint := &zipkinmodel.Endpoint{ServiceName: "api", IPv4: net.ParseIP("10.53.69.61").To4()}
+
+	span := validateZipkinV2JSON(t, SpanDataToZipkinV2(sd, localEndpoint))
+
+	wantFields := map[string]interface{}{
+		"traceId":   "000102030405060752969a8955571a3f",
+		"id":        "0000000000647d98",
+		"parentId":  "000000000068c4e3",
+		"kind":      "CLIENT",
+		"name":      "get",
+		"timestamp": json.Number("1485467191639875"),
+		"duration":  json.Number("22938"),
+	}
+	for key, want := range wantFields {
+		if got := span[key]; got != want {
+			t.Errorf("Got %s %v, want %v", key, got, want)
+		}
+	}
+
+	wantEndpoints := map[string]string{
+		"localEndpoint":  `{"ipv4":"10.53.69.61","serviceName":"api"}`,
+		"remoteEndpoint": `{"ipv4":"192.53.69.61","port":53931,"serviceName":"rtapi"}`,
+	}
+	for key, want := range wantEndpoints {
+		if got, _ := json.Marshal(span[key]); string(got) != want {
+			t.Errorf("Got %s %s, want %s", key, got, want)
+		}
+	}
+
+	wantTags := `{"cache_hit":"true","error":"NOT_FOUND","http.url":"http://127.0.0.1:15598/client_transactions",` +
+		`"opencensus.status_description":"no such transaction","peer.ipv4":"3224716605","peer.port":"53931",` +
+		`"peer.service":"rtapi","ratio":"0.25"}`
+	if got, _ := json.Marshal(span["tags"]); string(got) != wantTags {
+		t.Errorf("Got tags %s, want %s", got, wantTags)
+	}
+
+	wantAnnotations := `[{"timestamp":1485467191640875,"value":"cache miss"},{"timestamp":1485467191641875,"value":"SENT"}]`
+	if got, _ := json.Marshal(span["annotations"]); string(got) != wantAnnotations {
+		t.Errorf("Got annotations %s, want %s", got, wantAnnotations)
+	}
+}
+
+func TestSpanDataToZipkinV2_rootSpan(t *testing.T) {
+	startTime := time.Unix(1485467191, 0)
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x52, 0x96, 0x9A, 0x89, 0x55, 0x57, 0x1A, 0x3F},
+			SpanID:  trace.SpanID{0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x7D, 0x98},
+		},
+		SpanKind:  trace.SpanKindServer,
+		Name:      "/users",
+		StartTime: startTime,
+		EndTime:   startTime.Add(time.Second),
+	}
+
+	span := validateZipkinV2JSON(t, SpanDataToZipkinV2(sd, nil))
+
+	for _, key := range []string{"parentId", "remoteEndpoint", "localEndpoint", "tags", "annotations"} {
+		if value, ok := span[key]; ok {
+			t.Errorf("Got %s %v, want none for a root span without attributes", key, value)
+		}
+	}
+	// The trace IDs without their high 64 bits are encoded with 16 hex
+	// characters.
+	if got, want := span["traceId"], "52969a8955571a3f"; got != want {
+		t.Errorf("Got traceId %v, want %v", got, want)
+	}
+	if got, want := span["kind"], "SERVER"; got != want {
+		t.Errorf("Got kind %v, want %v", got, want)
+	}
+	if got, want := span["duration"], json.Number("1000000"); got != want {
+		t.Errorf("Got duration %v, want %v", got, want)
+	}
+}
+
+func TestSpanDataToZipkinV2_remoteEndpointFromStringAttributes(t *testing.T) {
+	sd := &trace.SpanData{
+		SpanContext: trace.SpanContext{
+			TraceID: trace.TraceID{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F},
+			SpanID:  trace.SpanID{0xF1, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6, 0xF7, 0xF8},
+		},
+		Attributes: map[string]interface{}{
+			"peer.ipv4": "10.0.0.2",
+			"peer.ipv6": "2001:db8::2",
+			"peer.port": "8080",
+		},
+	}
+
+	span := validateZipkinV2JSON(t, SpanDataToZipkinV2(sd, nil))
+
+	want := `{"ipv4":"10.0.0.2","ipv6":"2001:db8::2","port":8080}`
+	if got, _ := json.Marshal(span["remoteEndpoint"]); string(got) != want {
+		t.Errorf("Got remoteEndpoint %s, want %s", got, want)
+	}
+}
+
+func TestSpanDataToZipkinV2_nil(t *testing.T) {
+	if got := SpanDataToZipkinV2(nil, nil); got != nil {
+		t.Errorf("Got %+v for a nil span, want nil", got)
+	}
+}
+
+// The constraints of the Span and Endpoint definitions of the Zipkin v2 API,
+// https://github.com/openzipkin/zipkin-api/blob/master/zipkin2-api.yaml.
+var (
+	zipkinV2TraceIDPattern = regexp.MustCompile(`^[0-9a-f]{16}([0-9a-f]{16})?$`)
+	zipkinV2IDPattern      = regexp.MustCompile(`^[0-9a-f]{16}$`)
+	zipkinV2Kinds          = map[string]bool{"CLIENT": true, "SERVER": true, "PRODUCER": true, "CONSUMER": true}
+)
+
+// validateZipkinV2JSON encodes the span to JSON and checks the result against
+// the Zipkin v2 API definition of a span, returning the decoded JSON object
+// with its numbers as json.Number.
+func validateZipkinV2JSON(t *testing.T, zSpan *zipkinmodel.SpanModel) map[string]interface{} {
+	t.Helper()
+	blob, err := json.Marshal(zSpan)
+	if err != nil {
+		t.Fatalf("Failed to marshal the Zipkin span: %v", err)
+	}
+	dec := json.NewDecoder(strings.NewReader(string(blob)))
+	dec.UseNumber()
+	var span map[string]interface{}
+	if err := dec.Decode(&span); err != nil {
+		t.Fatalf("Failed to decode the Zipkin span JSON %s: %v", blob, err)
+	}
+
+	for _, err := range zipkinV2SpanErrors(span) {
+		t.Errorf("Zipkin span JSON %s: %v", blob, err)
+	}
+	return span
+}
+
+func zipkinV2SpanErrors(span map[string]interface{}) (errs []error) {
+	for _, key := range []string{"traceId", "id"} {
+		if _, ok := span[key]; !ok {
+			errs = append(errs, fmt.Errorf("missing required %q", key))
+		}
+	}
+	for key, value := range span {
+		var err error
+		switch key {
+		case "traceId":
+			err = checkPattern(value, zipkinV2TraceIDPattern)
+		case "id", "parentId":
+			err = checkPattern(value, zipkinV2IDPattern)
+		case "kind":
+			if s, ok := value.(string); !ok || !zipkinV2Kinds[s] {
+				err = fmt.Errorf("not one of the span kinds: %v", value)
+			}
+		case "name":
+			err = checkString(value)
+		case "timestamp":
+			err = checkInteger(value, 0)
+		case "duration":
+			err = checkInteger(value, 1)
+		case "localEndpoint", "remoteEndpoint":
+			err = checkEndpoint(value)
+		case "annotations":
+			err = checkAnnotations(value)
+		case "tags":
+			err = checkTags(value)
+		case "debug", "shared":
+			if _, ok := value.(bool); !ok {
+				err = fmt.Errorf("not a boolean: %v", value)
+			}
+		default:
+			err = fmt.Errorf("unknown property")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q: %v", key, err))
+		}
+	}
+	return errs
+}
+
+func checkPattern(value interface{}, pattern *regexp.Regexp) error {
+	if s, ok := value.(string); !ok || !pattern.MatchString(s) {
+		return fmt.Errorf("%v does not match %s", value, pattern)
+	}
+	return nil
+}
+
+func checkString(value interface{}) error {
+	if _, ok := value.(string); !ok {
+		return fmt.Errorf("not a string: %v", value)
+	}
+	return nil
+}
+
+func checkInteger(value interface{}, min int64) error {
+	n, ok := value.(json.Number)
+	if !ok {
+		return fmt.Errorf("not a number: %v", value)
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return fmt.Errorf("not an integer: %v", value)
+	}
+	if i < min {
+		return fmt.Errorf("%d is less than %d", i, min)
+	}
+	return nil
+}
+
+func checkEndpoint(value interface{}) error {
+	endpoint, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("not an object: %v", value)
+	}
+	for key, value := range endpoint {
+		var err error
+		switch key {
+		case "serviceName":
+			err = checkString(value)
+		case "ipv4":
+			if s, ok := value.(string); !ok || net.ParseIP(s).To4() == nil {
+				err = fmt.Errorf("not an IPv4 address: %v", value)
+			}
+		case "ipv6":
+			if s, ok := value.(string); !ok || net.ParseIP(s) == nil || net.ParseIP(s).To4() != nil {
+				err = fmt.Errorf("not an IPv6 address: %v", value)
+			}
+		case "port":
+			err = checkInteger(value, 0)
+		default:
+			err = fmt.Errorf("unknown property")
+		}
+		if err != nil {
+			return fmt.Errorf("%q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func checkAnnotations(value interface{}) error {
+	annotations, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("not an array: %v", value)
+	}
+	for i, a := range annotations {
+		annotation, ok := a.(map[string]interface{})
+		if !ok || len(annotation) != 2 {
+			return fmt.Errorf("annotation #%d is not an object with a timestamp and a value: %v", i, a)
+		}
+		if err := checkInteger(annotation["timestamp"], 0); err != nil {
+			return fmt.Errorf("annotation #%d timestamp: %v", i, err)
+		}
+		if err := checkString(annotation["value"]); err != nil {
+			return fmt.Errorf("annotation #%d value: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func checkTags(value interface{}) error {
+	tags, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("not an object: %v", value)
+	}
+	for key, value := range tags {
+		if err := checkString(value); err != nil {
+			return fmt.Errorf("tag %q: %v", key, err)
+		}
+	}
+	return nil
+}
@@ -107,6 +107,9 @@ func zipkinV1ThriftToOCSpan(zSpan *zipkincore.Span) (*tracepb.Span, *annotationP
 func parseZipkinV1ThriftAnnotations(ztAnnotations []*zipkincore.Annotation) *annotationParseResult {
 	annotations := make([]*annotation, 0, len(ztAnnotations))
 	for _, ztAnnot := range ztAnnotations {
+		if ztAnnot == nil {
+			continue
+		}
 		annot := &annotation{
 			Timestamp: ztAnnot.Timestamp,
 			Value:     ztAnnot.Value,
@@ -21,6 +21,7 @@ import (
 	"sort"
 	"testing"
 
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
 	"github.com/jaegertracing/jaeger/thrift-gen/zipkincore"
 )
 
@@ -90,6 +91,75 @@ func TestV1ThriftToOCProto(t *testing.T) {
 	}
 }
 
+func TestV1ThriftTraceIDAndSpanKind(t *testing.T) {
+	traceIDHigh := int64(0x0102030405060708)
+	tests := []struct {
+		name        string
+		traceIDHigh *int64
+		annotations []*zipkincore.Annotation
+		wantTraceID []byte
+		wantKind    tracepb.Span_SpanKind
+	}{
+		{
+			name:        "64bit_client",
+			annotations: []*zipkincore.Annotation{{Value: "cs", Timestamp: 1}, {Value: "cr", Timestamp: 2}},
+			wantTraceID: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			wantKind:    tracepb.Span_CLIENT,
+		},
+		{
+			name:        "128bit_server",
+			traceIDHigh: &traceIDHigh,
+			annotations: []*zipkincore.Annotation{{Value: "sr", Timestamp: 1}, {Value: "ss", Timestamp: 2}},
+			wantTraceID: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+			wantKind:    tracepb.Span_SERVER,
+		},
+		{
+			name:        "client_receive_only",
+			annotations: []*zipkincore.Annotation{{Value: "cr", Timestamp: 2}},
+			wantKind:    tracepb.Span_CLIENT,
+		},
+		{
+			name:        "server_send_only",
+			annotations: []*zipkincore.Annotation{{Value: "ss", Timestamp: 2}},
+			wantKind:    tracepb.Span_SERVER,
+		},
+		{
+			name:        "no_core_annotations",
+			annotations: []*zipkincore.Annotation{nil, {Value: "custom", Timestamp: 1}, {Value: ""}},
+			wantKind:    tracepb.Span_SPAN_KIND_UNSPECIFIED,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ocSpan, _, err := zipkinV1ThriftToOCSpan(&zipkincore.Span{
+				TraceID:     0x1112131415161718,
+				TraceIDHigh: tt.traceIDHigh,
+				ID:          1,
+				Name:        tt.name,
+				Annotations: tt.annotations,
+			})
+			if err != nil {
+				t.Fatalf("zipkinV1ThriftToOCSpan() error = %v", err)
+			}
+			if tt.wantTraceID != nil && !reflect.DeepEqual(ocSpan.TraceId, tt.wantTraceID) {
+				t.Errorf("TraceId = %x, want %x", ocSpan.TraceId, tt.wantTraceID)
+			}
+			if ocSpan.Kind != tt.wantKind {
+				t.Errorf("Kind = %v, want %v", ocSpan.Kind, tt.wantKind)
+			}
+			wantTimeEvents := 0
+			for _, a := range tt.annotations {
+				if a != nil && a.Value != "" {
+					wantTimeEvents++
+				}
+			}
+			if got := len(ocSpan.GetTimeEvents().GetTimeEvent()); got != wantTimeEvents {
+				t.Errorf("got %d time events, want one per non-empty annotation (%d)", got, wantTimeEvents)
+			}
+		})
+	}
+}
+
 func BenchmarkV1ThriftToOCProto(b *testing.B) {
 	blob, err := ioutil.ReadFile("./testdata/zipkin_v1_thrift_single_batch.json")
 	if err != nil {
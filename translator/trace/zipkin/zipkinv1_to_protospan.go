@@ -240,7 +240,7 @@ func parseZipkinV1Annotations(annotations []*annotation) *annotationParseResult
 	res := &annotationParseResult{}
 	timeEvents := make([]*tracepb.Span_TimeEvent, 0, len(annotations))
 	for _, currAnnotation := range annotations {
-		if currAnnotation == nil && currAnnotation.Value == "" {
+		if currAnnotation == nil || currAnnotation.Value == "" {
 			continue
 		}
 